@@ -0,0 +1,54 @@
+// Package addresses validates and normalizes postal addresses through a
+// pluggable provider. Without one configured every address is accepted as
+// it is.
+package addresses
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+)
+
+// Result is a provider's verdict on an address
+type Result struct {
+	// Address is the address in the provider's normalized form, such as
+	// with its street abbreviated and its postal code completed
+	Address models.PostalAddress
+	// Deliverable is false if carriers cannot deliver to the address
+	Deliverable bool
+	// Reason explains why an address is undeliverable
+	Reason string
+}
+
+// Validator checks addresses with an address provider. Implementations
+// must be safe for concurrent use.
+type Validator interface {
+	Validate(ctx context.Context, address models.PostalAddress) (Result, error)
+}
+
+// Noop accepts every address unchanged
+type Noop struct{}
+
+func (Noop) Validate(ctx context.Context, address models.PostalAddress) (Result, error) {
+	return Result{Address: address, Deliverable: true}, nil
+}
+
+var current Validator = Noop{}
+
+// Init selects the validator: the provider at the configured URL, or Noop
+func Init(cfg config.AddressConfig) {
+	current = Noop{}
+	if cfg.ValidationURL != "" {
+		current = apiValidator{cfg: cfg}
+	}
+}
+
+// Get returns the active validator
+func Get() Validator {
+	return current
+}
+
+// Set replaces the validator; mainly useful for tests
+func Set(validator Validator) {
+	current = validator
+}
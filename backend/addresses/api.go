@@ -0,0 +1,78 @@
+package addresses
+
+import (
+	"bytes"
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+	"ecommerce-backend/resilience"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const requestTimeout = 5 * time.Second
+
+// client propagates the trace context of the caller to the provider, retrying
+// failed requests and failing fast while it is down
+var client = &http.Client{
+	Transport: resilience.Transport("addresses", requestTimeout, otelhttp.NewTransport(http.DefaultTransport)),
+}
+
+// apiValidator checks addresses with an HTTP address provider, which
+// answers POST {url}/validate with {"address":{...}} by
+// {"address":{...},"deliverable":true,"reason":""}
+type apiValidator struct {
+	cfg config.AddressConfig
+}
+
+type validationRequest struct {
+	Address models.PostalAddress `json:"address"`
+}
+
+type validationResponse struct {
+	Address     models.PostalAddress `json:"address"`
+	Deliverable bool                 `json:"deliverable"`
+	Reason      string               `json:"reason"`
+}
+
+func (v apiValidator) Validate(ctx context.Context, address models.PostalAddress) (Result, error) {
+	body, err := json.Marshal(validationRequest{Address: address})
+	if err != nil {
+		return Result{}, err
+	}
+	endpoint := strings.TrimRight(v.cfg.ValidationURL, "/") + "/validate"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Result{}, fmt.Errorf("error creating address validation request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if v.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+v.cfg.APIKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("error validating address: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return Result{}, fmt.Errorf("address provider responded with status %d", resp.StatusCode)
+	}
+
+	var payload validationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return Result{}, fmt.Errorf("error decoding address validation: %v", err)
+	}
+	// Providers that do not normalize may leave the address out
+	if payload.Address == (models.PostalAddress{}) {
+		payload.Address = address
+	}
+	return Result{Address: payload.Address, Deliverable: payload.Deliverable, Reason: payload.Reason}, nil
+}
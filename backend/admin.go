@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"ecommerce-backend/config"
+	"ecommerce-backend/database"
+	"ecommerce-backend/encryption"
+	"ecommerce-backend/migrations"
+	"ecommerce-backend/models"
+	"ecommerce-backend/passwords"
+	"ecommerce-backend/search"
+	"ecommerce-backend/tenant"
+	"ecommerce-backend/utils"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// slugPattern matches store slugs, which must be usable as a DNS label
+var slugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+const (
+	generatedPasswordLength = 16
+	jwtSecretLength         = 64
+)
+
+// newAdminCmd groups operational tasks that would otherwise need direct
+// database access
+func newAdminCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Operational tasks: stores, admin users, secrets, migrations and data repair",
+	}
+	cmd.AddCommand(
+		newCreateStoreCmd(),
+		newCreateAdminCmd(),
+		newRotateJWTSecretCmd(),
+		newRotateJWTKeyCmd(),
+		newAdminMigrateCmd(),
+		newReindexCmd(),
+		newPurgeSessionsCmd(),
+		newRotatePIIKeyCmd(),
+	)
+	return cmd
+}
+
+func newCreateStoreCmd() *cobra.Command {
+	var slug, name string
+
+	cmd := &cobra.Command{
+		Use:   "create-store",
+		Short: "Create a store (tenant) served under its slug",
+		Args:  cobra.NoArgs,
+		RunE: withDB(func(cmd *cobra.Command, args []string) error {
+			if !slugPattern.MatchString(slug) {
+				return fmt.Errorf("slug must be a DNS label: lowercase letters, digits and hyphens")
+			}
+			if name == "" {
+				name = slug
+			}
+
+			store := models.Store{Slug: slug, Name: name}
+			if err := database.GetDB().Create(&store).Error; err != nil {
+				return err
+			}
+			fmt.Printf("created store %s (id %d); create its first admin with create-admin --store %s\n", slug, store.ID, slug)
+			return nil
+		}),
+	}
+	cmd.Flags().StringVar(&slug, "slug", "", "slug naming the store in subdomains and the X-Store header")
+	cmd.Flags().StringVar(&name, "name", "", "display name (defaults to the slug)")
+	cmd.MarkFlagRequired("slug")
+	return cmd
+}
+
+func newCreateAdminCmd() *cobra.Command {
+	var username, password, storeSlug string
+	var promote bool
+
+	cmd := &cobra.Command{
+		Use:   "create-admin",
+		Short: "Create an admin user, or promote an existing user with --promote",
+		Args:  cobra.NoArgs,
+		RunE: withDB(func(cmd *cobra.Command, args []string) error {
+			var store models.Store
+			if err := database.GetDB().Where("slug = ?", storeSlug).First(&store).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("store %q does not exist", storeSlug)
+				}
+				return err
+			}
+			db := database.WithContext(tenant.WithStore(cmd.Context(), store.ID))
+
+			var user models.User
+			result := db.Where("username = ?", username).Limit(1).Find(&user)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				if !promote {
+					return fmt.Errorf("user %q already exists; pass --promote to make them an admin", username)
+				}
+				if err := db.Model(&user).Update("role", models.RoleAdmin).Error; err != nil {
+					return err
+				}
+				fmt.Printf("promoted %s to admin; the change applies from their next login\n", username)
+				return nil
+			}
+
+			generated := password == ""
+			if generated {
+				var err error
+				if password, err = utils.GenerateRandomString(generatedPasswordLength); err != nil {
+					return err
+				}
+			} else {
+				// Apply the policy of registrations
+				passwords.Init(config.Get().Passwords)
+				if violations := passwords.Violations(cmd.Context(), password, username); len(violations) > 0 {
+					messages := make([]string, len(violations))
+					for i, v := range violations {
+						messages[i] = v.Message
+					}
+					return fmt.Errorf("weak password: %s", strings.Join(messages, "; "))
+				}
+			}
+
+			hash, err := utils.HashPassword(password)
+			if err != nil {
+				return err
+			}
+			user = models.User{Username: username, PasswordHash: hash, Role: models.RoleAdmin}
+			if err := db.Create(&user).Error; err != nil {
+				return err
+			}
+
+			fmt.Printf("created admin %s (id %d)\n", username, user.ID)
+			if generated {
+				fmt.Printf("generated password: %s\n", password)
+			}
+			return nil
+		}),
+	}
+	cmd.Flags().StringVar(&username, "username", "", "username of the admin")
+	cmd.Flags().StringVar(&password, "password", "", "password (generated and printed if omitted)")
+	cmd.Flags().BoolVar(&promote, "promote", false, "grant the admin role if the user already exists")
+	cmd.Flags().StringVar(&storeSlug, "store", "default", "slug of the store the admin belongs to")
+	cmd.MarkFlagRequired("username")
+	return cmd
+}
+
+func newRotateJWTSecretCmd() *cobra.Command {
+	var write bool
+
+	cmd := &cobra.Command{
+		Use:   "rotate-jwt-secret",
+		Short: "Generate a new JWT signing secret",
+		Long: "Generates a new JWT signing secret. Once the server runs with it, every " +
+			"previously issued token is rejected and users must log in again. " +
+			"To rotate without logging everyone out, use rotate-jwt-key.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			secret, err := utils.GenerateRandomString(jwtSecretLength)
+			if err != nil {
+				return err
+			}
+
+			if !write {
+				fmt.Println(secret)
+				fmt.Fprintln(os.Stderr, "set JWT_SECRET_KEY (or jwt.secret in the config file) to this value and restart the server")
+				return nil
+			}
+
+			path := os.Getenv("CONFIG_FILE")
+			if path == "" {
+				return errors.New("--write requires CONFIG_FILE to name the config file")
+			}
+			if err := writeJWTSecret(path, secret); err != nil {
+				return err
+			}
+			fmt.Printf("wrote a new jwt.secret to %s; restart the server to apply it\n", path)
+			if os.Getenv("JWT_SECRET_KEY") != "" {
+				fmt.Fprintln(os.Stderr, "warning: JWT_SECRET_KEY is set and overrides the config file")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&write, "write", false, "store the secret in the YAML file named by CONFIG_FILE instead of printing it")
+	return cmd
+}
+
+func newRotateJWTKeyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rotate-jwt-key",
+		Short: "Sign new JWTs with a fresh key, keeping issued tokens valid",
+		Long: "Stores a new JWT signing key, identified by the kid header of the tokens it signs. " +
+			"Running servers sign with it within a minute; tokens signed with the previous keys " +
+			"are accepted until they expire, after which those keys are retired.",
+		Args: cobra.NoArgs,
+		RunE: withDB(func(cmd *cobra.Command, args []string) error {
+			key, err := utils.RotateJWTKey(cmd.Context())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("new tokens are signed with key %s\n", key.KID)
+			return nil
+		}),
+	}
+}
+
+// writeJWTSecret sets jwt.secret in a YAML config file. Other settings and
+// comments are kept, though blank lines and spacing are normalized.
+func writeJWTSecret(path, secret string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s does not contain a YAML mapping", path)
+	}
+
+	jwtNode := mappingValue(root, "jwt")
+	if jwtNode == nil {
+		jwtNode = &yaml.Node{Kind: yaml.MappingNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "jwt"}, jwtNode)
+	}
+	if secretNode := mappingValue(jwtNode, "secret"); secretNode != nil {
+		secretNode.SetString(secret)
+	} else {
+		jwtNode.Content = append(jwtNode.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "secret"},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: secret})
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), 0600)
+}
+
+// mappingValue returns the value node for key in a YAML mapping, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func newAdminMigrateCmd() *cobra.Command {
+	var redo int
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply pending migrations, optionally re-running the last --redo migrations first",
+		Args:  cobra.NoArgs,
+		RunE: withDB(func(cmd *cobra.Command, args []string) error {
+			db := database.GetDB()
+
+			if redo > 0 {
+				n, err := migrations.Down(db, redo)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("rolled back %d migration(s)\n", n)
+			}
+
+			n, err := migrations.Up(db)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("applied %d migration(s)\n", n)
+			return nil
+		}),
+	}
+	cmd.Flags().IntVar(&redo, "redo", 0, "roll back and re-apply this many of the most recent migrations (destroys their data)")
+	return cmd
+}
+
+func newReindexCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reindex",
+		Short: "Index every item in the search engine",
+		Long: "Creates the search index named by SEARCH_INDEX if it is missing and indexes " +
+			"every item of every store, replacing their documents. Requires SEARCH_URL.",
+		Args: cobra.NoArgs,
+		RunE: withDB(func(cmd *cobra.Command, args []string) error {
+			search.Init(config.Get().Search)
+			engine := search.Get()
+			if engine == nil {
+				return errors.New("SEARCH_URL is not set")
+			}
+			ctx := context.Background()
+			if err := engine.EnsureIndex(ctx); err != nil {
+				return fmt.Errorf("failed to create search index: %v", err)
+			}
+
+			var items []models.Item
+			indexed := 0
+			result := database.GetDB().FindInBatches(&items, 100, func(tx *gorm.DB, batch int) error {
+				for _, item := range items {
+					if err := engine.Index(ctx, item); err != nil {
+						return fmt.Errorf("failed to index item %d: %v", item.ID, err)
+					}
+					indexed++
+				}
+				return nil
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+
+			fmt.Printf("indexed %d item(s)\n", indexed)
+			return nil
+		}),
+	}
+}
+
+func newPurgeSessionsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "purge-sessions",
+		Short: "Delete revocation entries of logged-out tokens that have expired",
+		Args:  cobra.NoArgs,
+		RunE: withDB(func(cmd *cobra.Command, args []string) error {
+			n, err := utils.PurgeExpiredRevocations(context.Background())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("purged %d expired session revocation(s)\n", n)
+			return nil
+		}),
+	}
+}
+
+func newRotatePIIKeyCmd() *cobra.Command {
+	var generate bool
+
+	cmd := &cobra.Command{
+		Use:   "rotate-pii-key",
+		Short: "Re-encrypt personal data with the current PII encryption key",
+		Long: "Re-encrypts every email address, phone number and postal address, and the secrets of the JWT " +
+			"signing keys in jwt_keys, with the first key of PII_ENCRYPTION_KEYS, including values stored " +
+			"before encryption was enabled. " +
+			"To rotate, put a new key first (--generate prints one), keeping the old key " +
+			"after it, run this command, then drop the old key.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if generate {
+				secret := make([]byte, encryption.KeySize)
+				if _, err := rand.Read(secret); err != nil {
+					return err
+				}
+				fmt.Printf("%s:%s\n", time.Now().UTC().Format("k20060102"), base64.StdEncoding.EncodeToString(secret))
+				fmt.Fprintln(os.Stderr, "put this key first in PII_ENCRYPTION_KEYS, restart the server and run rotate-pii-key")
+				return nil
+			}
+			return withDB(func(cmd *cobra.Command, args []string) error {
+				if !encryption.Enabled() {
+					return errors.New("PII_ENCRYPTION_KEYS is not set")
+				}
+				db := database.GetDB()
+				tables := []struct {
+					name string
+					run  func() (int, error)
+				}{
+					{"users", func() (int, error) { return reencrypt[models.User](db, "email", "phone") }},
+					{"cart_reminders", func() (int, error) { return reencrypt[models.CartReminder](db, "email") }},
+					{"addresses", func() (int, error) {
+						return reencrypt[models.Address](db, "name", "line1", "line2", "city", "postal_code")
+					}},
+					{"orders", func() (int, error) {
+						return reencrypt[models.Order](db, "shipping_name", "shipping_line1", "shipping_line2", "shipping_city", "shipping_postal_code")
+					}},
+					// Signing and verifying tokens fails once the key the
+					// secrets were encrypted with is dropped
+					{"jwt_keys", func() (int, error) { return reencrypt[models.JWTKey](db, "secret") }},
+				}
+				for _, table := range tables {
+					n, err := table.run()
+					if err != nil {
+						return fmt.Errorf("%s: %v", table.name, err)
+					}
+					fmt.Printf("re-encrypted %d row(s) of %s with key %s\n", n, table.name, encryption.CurrentKeyID())
+				}
+				return nil
+			})(cmd, args)
+		},
+	}
+	cmd.Flags().BoolVar(&generate, "generate", false, "print a new key instead of re-encrypting")
+	return cmd
+}
+
+// reencrypt rewrites the given encrypted columns of every row of T,
+// deleted ones included, which encrypts them with the current key
+func reencrypt[T any](db *gorm.DB, columns ...string) (int, error) {
+	var rows []T
+	n := 0
+	result := db.Unscoped().Select(append([]string{"id"}, columns...)).FindInBatches(&rows, 100, func(tx *gorm.DB, batch int) error {
+		for i := range rows {
+			if err := db.Unscoped().Model(&rows[i]).Select(columns).UpdateColumns(&rows[i]).Error; err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, result.Error
+}
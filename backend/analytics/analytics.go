@@ -0,0 +1,219 @@
+// Package analytics computes sales reports with SQL aggregation, so reports
+// over large date ranges never load individual orders. Times are bucketed
+// in the database's time zone, which should be UTC.
+package analytics
+
+import (
+	"ecommerce-backend/models"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Report periods
+const (
+	Day   = "day"
+	Week  = "week"
+	Month = "month"
+)
+
+// revenueStatuses are the order statuses that count as sales
+var revenueStatuses = []string{models.OrderCompleted, models.OrderShipped, models.OrderDelivered}
+
+// Range is a half-open time range [From, To)
+type Range struct {
+	From time.Time
+	To   time.Time
+}
+
+// RevenueBucket is the revenue of the orders placed in one period
+type RevenueBucket struct {
+	Start   string  `json:"start"`
+	Orders  int64   `json:"orders"`
+	Revenue float64 `json:"revenue"`
+}
+
+// StatusCount is the number of orders with a status
+type StatusCount struct {
+	Status string `json:"status"`
+	Orders int64  `json:"orders"`
+}
+
+// TopItem is an item's sales in a range
+type TopItem struct {
+	ItemID   uint    `json:"item_id"`
+	Name     string  `json:"name"`
+	Quantity int64   `json:"quantity"`
+	Orders   int64   `json:"orders"`
+	Revenue  float64 `json:"revenue"`
+}
+
+// Summary totals the sales in a range
+type Summary struct {
+	Orders            int64   `json:"orders"`
+	Revenue           float64 `json:"revenue"`
+	AverageOrderValue float64 `json:"average_order_value"`
+}
+
+// Funnel follows the carts created in a range on their way to an order:
+// how many still hold items, how many their owners tried to check out, and
+// how many became orders counted as sales
+type Funnel struct {
+	CartsCreated     int64 `json:"carts_created"`
+	CartsWithItems   int64 `json:"carts_with_items"`
+	CheckoutsStarted int64 `json:"checkouts_started"`
+	OrdersCompleted  int64 `json:"orders_completed"`
+}
+
+// orders selects the orders placed in r
+func orders(db *gorm.DB, r Range) *gorm.DB {
+	return db.Model(&models.Order{}).Where("created_at >= ? AND created_at < ?", r.From, r.To)
+}
+
+// Revenue returns the order count and revenue of each period in r that has
+// sales, oldest first. Buckets start on the day, the Monday of the week or
+// the first of the month, formatted as YYYY-MM-DD.
+func Revenue(db *gorm.DB, period string, r Range) ([]RevenueBucket, error) {
+	bucket, err := bucketExpr(db.Dialector.Name(), period)
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []RevenueBucket
+	err = orders(db, r).
+		Select(bucket+" AS start, COUNT(*) AS orders, COALESCE(SUM(total), 0) AS revenue").
+		Where("status IN ?", revenueStatuses).
+		Group("start").
+		Order("start").
+		Scan(&buckets).Error
+	for i := range buckets {
+		buckets[i].Revenue = round(buckets[i].Revenue)
+	}
+	return buckets, err
+}
+
+// StatusCounts returns the number of orders in r by status
+func StatusCounts(db *gorm.DB, r Range) ([]StatusCount, error) {
+	var counts []StatusCount
+	err := orders(db, r).
+		Select("status, COUNT(*) AS orders").
+		Group("status").
+		Order("status").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// Summarize returns the order count, revenue and average order value of
+// the sales in r
+func Summarize(db *gorm.DB, r Range) (Summary, error) {
+	var summary Summary
+	err := orders(db, r).
+		Select("COUNT(*) AS orders, COALESCE(SUM(total), 0) AS revenue").
+		Where("status IN ?", revenueStatuses).
+		Scan(&summary).Error
+	if err != nil {
+		return Summary{}, err
+	}
+
+	summary.Revenue = round(summary.Revenue)
+	if summary.Orders > 0 {
+		summary.AverageOrderValue = round(summary.Revenue / float64(summary.Orders))
+	}
+	return summary, nil
+}
+
+// ConversionFunnel returns the funnel of the carts created in r. Carts
+// merged into others or purged by the retention policies are not counted.
+func ConversionFunnel(db *gorm.DB, r Range) (Funnel, error) {
+	var funnel Funnel
+	err := db.Model(&models.Cart{}).
+		Select("COUNT(*) AS carts_created, "+
+			"COUNT(CASE WHEN EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.id "+
+			"AND cart_items.deleted_at IS NULL) THEN 1 END) AS carts_with_items, "+
+			"COUNT(carts.checkout_started_at) AS checkouts_started, "+
+			"COUNT(CASE WHEN EXISTS (SELECT 1 FROM orders WHERE orders.cart_id = carts.id "+
+			"AND orders.deleted_at IS NULL AND orders.status IN ?) THEN 1 END) AS orders_completed", revenueStatuses).
+		Where("carts.created_at >= ? AND carts.created_at < ?", r.From, r.To).
+		Scan(&funnel).Error
+	return funnel, err
+}
+
+// soldItems joins the items sold in r to their order lines
+func soldItems(db *gorm.DB, r Range) *gorm.DB {
+	return db.
+		Joins("JOIN cart_items ON cart_items.item_id = items.id AND cart_items.deleted_at IS NULL").
+		Joins("JOIN orders ON orders.cart_id = cart_items.cart_id AND orders.deleted_at IS NULL").
+		Where("orders.created_at >= ? AND orders.created_at < ?", r.From, r.To).
+		Where("orders.status IN ?", revenueStatuses)
+}
+
+// TopItems returns the limit items with the most units sold in r, including
+// items deleted since. Revenue is at the items' current prices.
+func TopItems(db *gorm.DB, r Range, limit int) ([]TopItem, error) {
+	var top []TopItem
+	// Unscoped keeps deleted items while the model lets the tenant plugin
+	// scope the report to the store
+	err := soldItems(db.Unscoped().Model(&models.Item{}), r).
+		Select("items.id AS item_id, items.name AS name, SUM(cart_items.quantity) AS quantity, " +
+			"COUNT(DISTINCT orders.id) AS orders, SUM(cart_items.quantity * items.price) AS revenue").
+		Group("items.id, items.name").
+		Order("quantity DESC, items.id").
+		Limit(limit).
+		Scan(&top).Error
+	for i := range top {
+		top[i].Revenue = round(top[i].Revenue)
+	}
+	return top, err
+}
+
+// Trending returns the limit listed items with the most units sold in r,
+// best-selling first, leaving out inactive items
+func Trending(db *gorm.DB, r Range, limit int) ([]models.Item, error) {
+	var items []models.Item
+	err := soldItems(db.Model(&models.Item{}), r).
+		Where("items.is_active = ?", true).
+		Select("items.*").
+		Group("items.id").
+		Order("SUM(cart_items.quantity) DESC, items.id").
+		Limit(limit).
+		Find(&items).Error
+	return items, err
+}
+
+// bucketExpr returns the SQL expression formatting created_at as the start
+// of its period
+func bucketExpr(dialect, period string) (string, error) {
+	exprs := map[string]map[string]string{
+		"sqlite": {
+			Day:   "strftime('%Y-%m-%d', created_at)",
+			Week:  "date(created_at, '-6 days', 'weekday 1')",
+			Month: "strftime('%Y-%m-01', created_at)",
+		},
+		"postgres": {
+			Day:   "to_char(date_trunc('day', created_at), 'YYYY-MM-DD')",
+			Week:  "to_char(date_trunc('week', created_at), 'YYYY-MM-DD')",
+			Month: "to_char(date_trunc('month', created_at), 'YYYY-MM-DD')",
+		},
+		"mysql": {
+			Day:   "DATE_FORMAT(created_at, '%Y-%m-%d')",
+			Week:  "DATE_FORMAT(DATE_SUB(created_at, INTERVAL WEEKDAY(created_at) DAY), '%Y-%m-%d')",
+			Month: "DATE_FORMAT(created_at, '%Y-%m-01')",
+		},
+	}
+
+	byPeriod, ok := exprs[dialect]
+	if !ok {
+		return "", fmt.Errorf("analytics: unsupported database %q", dialect)
+	}
+	expr, ok := byPeriod[period]
+	if !ok {
+		return "", fmt.Errorf("analytics: unknown period %q", period)
+	}
+	return expr, nil
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,296 @@
+package apidocs
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/visibility"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+	"github.com/gin-gonic/gin"
+)
+
+// Auth describes how an operation is authenticated
+type Auth int
+
+const (
+	AuthNone Auth = iota
+	AuthBearer
+	AuthAPIKey
+)
+
+// Param documents a query parameter
+type Param struct {
+	Name        string
+	Description string
+	Type        string // "string", "integer", "number" or "boolean"
+	Required    bool
+}
+
+// Operation annotates a route. Request and Response are zero values of the
+// Go types bound from and rendered into the body; their schemas are
+// generated by reflection so they follow the code.
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Auth        Auth
+	AdminOnly   bool
+	Query       []Param
+	Request     interface{}
+	Upload      string // form field of a multipart file upload, instead of a JSON Request
+	Response    interface{}
+	Status      int  // success status, defaults to 200
+	Deprecated  bool // superseded by a newer API version
+	IfMatch     bool // requires an If-Match header holding the ETag the update was based on
+}
+
+// ErrorResponse is the envelope returned for failed requests
+type ErrorResponse = apperrors.Response
+
+var (
+	operations  = map[string]Operation{}
+	ginParamRe  = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+	bindingRule = regexp.MustCompile(`^(min|max|gt|gte|lt|lte)=([0-9.]+)$`)
+)
+
+// Document annotates the route registered for method and path
+func Document(method, path string, op Operation) {
+	operations[method+" "+path] = op
+}
+
+// Build generates the OpenAPI 3 document for the registered routes. Every
+// route is included; routes without annotations are marked undocumented so
+// gaps are visible rather than silently missing.
+func Build(title, version string, routes gin.RoutesInfo) (*openapi3.T, error) {
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info:    &openapi3.Info{Title: title, Version: version},
+		Paths:   openapi3.NewPaths(),
+		Components: &openapi3.Components{
+			Schemas: openapi3.Schemas{},
+			SecuritySchemes: openapi3.SecuritySchemes{
+				"bearerAuth": &openapi3.SecuritySchemeRef{
+					Value: openapi3.NewJWTSecurityScheme(),
+				},
+				"apiKeyAuth": &openapi3.SecuritySchemeRef{
+					Value: openapi3.NewSecurityScheme().WithType("apiKey").WithIn("header").WithName("X-API-Key"),
+				},
+			},
+		},
+	}
+
+	gen := openapi3gen.NewGenerator(
+		openapi3gen.UseAllExportedFields(),
+		openapi3gen.SchemaCustomizer(customizeSchema),
+	)
+
+	errorSchema, err := gen.NewSchemaRefForValue(ErrorResponse{}, doc.Components.Schemas)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+
+	for _, route := range routes {
+		path := ginParamRe.ReplaceAllString(route.Path, "{$1}")
+		item := doc.Paths.Value(path)
+		if item == nil {
+			item = &openapi3.PathItem{}
+			doc.Paths.Set(path, item)
+		}
+
+		op, documented := operations[route.Method+" "+route.Path]
+		operation := openapi3.NewOperation()
+		operation.Summary = op.Summary
+		operation.Description = op.Description
+		operation.Tags = op.Tags
+		operation.Deprecated = op.Deprecated
+		if !documented {
+			operation.Summary = "Undocumented"
+		}
+
+		for _, name := range ginParamRe.FindAllStringSubmatch(route.Path, -1) {
+			operation.AddParameter(openapi3.NewPathParameter(name[1]).WithSchema(openapi3.NewStringSchema()))
+		}
+		for _, q := range op.Query {
+			param := openapi3.NewQueryParameter(q.Name).WithDescription(q.Description).WithSchema(schemaForType(q.Type))
+			param.Required = q.Required
+			operation.AddParameter(param)
+		}
+
+		switch op.Auth {
+		case AuthBearer:
+			operation.Security = &openapi3.SecurityRequirements{{"bearerAuth": []string{}}}
+			operation.AddResponse(http.StatusUnauthorized, errorResponse("Missing or invalid token", errorSchema))
+		case AuthAPIKey:
+			operation.Security = &openapi3.SecurityRequirements{{"apiKeyAuth": []string{}}}
+			operation.AddResponse(http.StatusUnauthorized, errorResponse("Missing or invalid API key", errorSchema))
+		}
+		if op.AdminOnly {
+			operation.AddResponse(http.StatusForbidden, errorResponse("Admin role required", errorSchema))
+		}
+		if op.IfMatch {
+			param := openapi3.NewHeaderParameter("If-Match").
+				WithDescription("The ETag the update was based on, or * to update whatever the current version").
+				WithSchema(openapi3.NewStringSchema())
+			param.Required = true
+			operation.AddParameter(param)
+			operation.AddResponse(http.StatusPreconditionFailed, errorResponse("Changed since the ETag was read", errorSchema))
+			operation.AddResponse(http.StatusPreconditionRequired, errorResponse("Missing If-Match header", errorSchema))
+		}
+
+		if op.Request != nil {
+			schema, err := gen.NewSchemaRefForValue(op.Request, doc.Components.Schemas)
+			if err != nil {
+				return nil, fmt.Errorf("request schema for %s %s: %v", route.Method, route.Path, err)
+			}
+			markRequired(reflect.TypeOf(op.Request), schema)
+			operation.RequestBody = &openapi3.RequestBodyRef{
+				Value: openapi3.NewRequestBody().WithRequired(true).WithJSONSchemaRef(schema),
+			}
+			operation.AddResponse(http.StatusBadRequest, errorResponse("Invalid request", errorSchema))
+		}
+		if op.Upload != "" {
+			form := openapi3.NewObjectSchema().
+				WithProperty(op.Upload, openapi3.NewStringSchema().WithFormat("binary"))
+			form.Required = []string{op.Upload}
+			operation.RequestBody = &openapi3.RequestBodyRef{
+				Value: openapi3.NewRequestBody().WithRequired(true).
+					WithContent(openapi3.NewContentWithFormDataSchema(form)),
+			}
+			operation.AddResponse(http.StatusBadRequest, errorResponse("Invalid request", errorSchema))
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := openapi3.NewResponse().WithDescription(http.StatusText(status))
+		if op.Response != nil {
+			schema, err := gen.NewSchemaRefForValue(op.Response, doc.Components.Schemas)
+			if err != nil {
+				return nil, fmt.Errorf("response schema for %s %s: %v", route.Method, route.Path, err)
+			}
+			success = success.WithJSONSchemaRef(schema)
+		}
+		operation.AddResponse(status, success)
+
+		item.SetOperation(route.Method, operation)
+	}
+
+	return doc, nil
+}
+
+func errorResponse(description string, schema *openapi3.SchemaRef) *openapi3.Response {
+	return openapi3.NewResponse().WithDescription(description).WithJSONSchemaRef(schema)
+}
+
+func schemaForType(t string) *openapi3.Schema {
+	switch t {
+	case "integer":
+		return openapi3.NewIntegerSchema()
+	case "number":
+		return openapi3.NewFloat64Schema()
+	case "boolean":
+		return openapi3.NewBoolSchema()
+	default:
+		return openapi3.NewStringSchema()
+	}
+}
+
+// customizeSchema applies the binding rules and visibility of a field to
+// its schema
+func customizeSchema(name string, t reflect.Type, tag reflect.StructTag, schema *openapi3.Schema) error {
+	if err := applyBindingRules(name, t, tag, schema); err != nil {
+		return err
+	}
+	describeVisibility(tag, schema)
+	return nil
+}
+
+// describeVisibility notes in the schema which roles see a field limited
+// to some roles
+func describeVisibility(tag reflect.StructTag, schema *openapi3.Schema) {
+	roles, ok := tag.Lookup(visibility.Tag)
+	if !ok {
+		return
+	}
+	note := "Only returned to " + strings.ReplaceAll(roles, ",", ", ") + " users."
+	if schema.Description != "" {
+		note = schema.Description + " " + note
+	}
+	schema.Description = note
+}
+
+// applyBindingRules copies numeric and length constraints from gin binding
+// tags into the generated schema
+func applyBindingRules(name string, t reflect.Type, tag reflect.StructTag, schema *openapi3.Schema) error {
+	for _, rule := range strings.Split(tag.Get("binding"), ",") {
+		m := bindingRule.FindStringSubmatch(rule)
+		if m == nil {
+			continue
+		}
+		n, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			continue
+		}
+
+		isString := t.Kind() == reflect.String
+		switch m[1] {
+		case "min", "gte":
+			if isString {
+				schema.MinLength = uint64(n)
+			} else {
+				schema.Min = &n
+			}
+		case "max", "lte":
+			if isString {
+				max := uint64(n)
+				schema.MaxLength = &max
+			} else {
+				schema.Max = &n
+			}
+		case "gt":
+			schema.Min = &n
+			schema.WithExclusiveMin(true)
+		case "lt":
+			schema.Max = &n
+			schema.WithExclusiveMax(true)
+		}
+	}
+	return nil
+}
+
+// markRequired lists fields with a "required" binding rule as required
+func markRequired(t reflect.Type, ref *openapi3.SchemaRef) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || ref == nil || ref.Value == nil {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !strings.Contains(","+field.Tag.Get("binding")+",", ",required,") {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		ref.Value.Required = append(ref.Value.Required, name)
+	}
+}
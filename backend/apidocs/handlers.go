@@ -0,0 +1,59 @@
+package apidocs
+
+import (
+	"ecommerce-backend/apperrors"
+	"net/http"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+const swaggerUIVersion = "5.17.14"
+
+// SpecHandler serves the OpenAPI document for the engine's routes. The
+// document is built on first request, after all routes are registered.
+func SpecHandler(engine *gin.Engine, title, version string) gin.HandlerFunc {
+	var (
+		once sync.Once
+		doc  *openapi3.T
+		err  error
+	)
+
+	return func(c *gin.Context) {
+		once.Do(func() {
+			doc, err = Build(title, version, engine.Routes())
+		})
+		if err != nil {
+			c.Error(apperrors.Internal("failed to build API documentation", err))
+			return
+		}
+
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// UIHandler serves Swagger UI pointed at the given spec URL
+func UIHandler(specURL string) gin.HandlerFunc {
+	page := `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>API Documentation</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@` + swaggerUIVersion + `/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "` + specURL + `", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>`
+
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+	}
+}
@@ -0,0 +1,182 @@
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error is an application error with a stable, machine-readable code. It is
+// rendered to clients as {"error": {"code": ..., "message": ..., "details": ...}}
+// by middleware.ErrorHandler; the wrapped cause is only logged.
+type Error struct {
+	Status  int         `json:"-"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+	cause   error
+	// custom is set once the default message is replaced, as free-form
+	// messages have no translation
+	custom bool
+}
+
+// Response is the envelope used for every error response
+type Response struct {
+	Error *Error `json:"error"`
+}
+
+// Generic errors
+var (
+	ErrValidation   = New(http.StatusBadRequest, "VALIDATION_FAILED", "request validation failed")
+	ErrUnauthorized = New(http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+	ErrInvalidToken = New(http.StatusUnauthorized, "INVALID_TOKEN", "invalid or expired token")
+	ErrForbidden    = New(http.StatusForbidden, "FORBIDDEN", "insufficient permissions")
+	ErrNotFound     = New(http.StatusNotFound, "NOT_FOUND", "resource not found")
+	ErrRateLimited  = New(http.StatusTooManyRequests, "RATE_LIMITED", "too many requests")
+	ErrConflict     = New(http.StatusConflict, "CONFLICT", "resource was changed by another request")
+	ErrInternal     = New(http.StatusInternalServerError, "INTERNAL", "internal server error")
+
+	ErrPayloadTooLarge      = New(http.StatusRequestEntityTooLarge, "PAYLOAD_TOO_LARGE", "request body is too large")
+	ErrMaintenance          = New(http.StatusServiceUnavailable, "MAINTENANCE", "down for maintenance; try again later")
+	ErrPreconditionRequired = New(http.StatusPreconditionRequired, "PRECONDITION_REQUIRED", "an If-Match header is required")
+	ErrPreconditionFailed   = New(http.StatusPreconditionFailed, "PRECONDITION_FAILED", "resource was changed since it was read")
+)
+
+// Domain errors
+var (
+	ErrInvalidCredentials = New(http.StatusUnauthorized, "INVALID_CREDENTIALS", "invalid credentials")
+	ErrUsernameTaken      = New(http.StatusBadRequest, "USERNAME_TAKEN", "username already exists")
+	ErrWeakPassword       = New(http.StatusBadRequest, "WEAK_PASSWORD", "password does not meet the password policy")
+	ErrAccountDeactivated = New(http.StatusForbidden, "ACCOUNT_DEACTIVATED", "account is deactivated")
+	ErrCaptchaRequired    = New(http.StatusBadRequest, "CAPTCHA_REQUIRED", "a captcha must be solved")
+	ErrCaptchaInvalid     = New(http.StatusBadRequest, "CAPTCHA_INVALID", "captcha verification failed")
+	ErrCaptchaUnavailable = New(http.StatusServiceUnavailable, "CAPTCHA_UNAVAILABLE", "captcha cannot be verified right now")
+	ErrInvalidAPIKey      = New(http.StatusUnauthorized, "INVALID_API_KEY", "invalid API key")
+	ErrSandboxKeyRequired = New(http.StatusForbidden, "SANDBOX_KEY_REQUIRED", "a sandbox API key is required")
+	ErrQuotaExceeded      = New(http.StatusTooManyRequests, "QUOTA_EXCEEDED", "the API key's request quota is used up")
+	ErrItemNotFound       = New(http.StatusNotFound, "ITEM_NOT_FOUND", "item not found")
+	ErrItemInactive       = New(http.StatusBadRequest, "ITEM_INACTIVE", "item is not for sale")
+	ErrItemInStock        = New(http.StatusConflict, "ITEM_IN_STOCK", "item is in stock")
+	ErrSKUTaken           = New(http.StatusConflict, "SKU_TAKEN", "another item has this SKU")
+	ErrSyncConflict       = New(http.StatusConflict, "SYNC_CONFLICT", "item changed since it was last synced")
+	ErrNotSubscribed      = New(http.StatusNotFound, "NOT_SUBSCRIBED", "not subscribed to the item")
+	ErrCartNotFound       = New(http.StatusBadRequest, "CART_NOT_FOUND", "no active cart found")
+	ErrCartEmpty          = New(http.StatusBadRequest, "CART_EMPTY", "cart is empty")
+	ErrCartShareInvalid   = New(http.StatusForbidden, "CART_SHARE_INVALID", "cart sharing link is invalid or has expired")
+	ErrOrderNotFound      = New(http.StatusNotFound, "ORDER_NOT_FOUND", "order not found")
+	ErrStatusTransition   = New(http.StatusConflict, "INVALID_STATUS_TRANSITION", "order cannot move to this status")
+	ErrInsufficientStock  = New(http.StatusConflict, "INSUFFICIENT_STOCK", "not enough stock")
+	ErrQuantityTooLow     = New(http.StatusBadRequest, "QUANTITY_BELOW_MINIMUM", "quantity is below the item's minimum")
+	ErrQuantityTooHigh    = New(http.StatusConflict, "QUANTITY_LIMIT_EXCEEDED", "quantity exceeds the item's purchase limit")
+	ErrCheckoutRules      = New(http.StatusBadRequest, "CHECKOUT_RULES_VIOLATED", "order does not meet the checkout rules")
+	ErrDuplicateOrder     = New(http.StatusConflict, "DUPLICATE_ORDER", "an order with the same items was just placed; confirm to order them again")
+	ErrOrderNotFlagged    = New(http.StatusBadRequest, "ORDER_NOT_FLAGGED", "order is not flagged for review")
+	ErrUserNotFound       = New(http.StatusNotFound, "USER_NOT_FOUND", "user not found")
+	ErrPhoneCodeInvalid   = New(http.StatusBadRequest, "PHONE_CODE_INVALID", "verification code is invalid or has expired")
+	ErrPhoneNotVerified   = New(http.StatusBadRequest, "PHONE_NOT_VERIFIED", "a verified phone number is required")
+	ErrSMSUnavailable     = New(http.StatusServiceUnavailable, "SMS_UNAVAILABLE", "text messages cannot be sent right now")
+	ErrVendorNotFound     = New(http.StatusNotFound, "VENDOR_NOT_FOUND", "vendor not found")
+	ErrVendorNameTaken    = New(http.StatusBadRequest, "VENDOR_NAME_TAKEN", "vendor name already exists")
+	ErrStoreNotFound      = New(http.StatusNotFound, "STORE_NOT_FOUND", "store not found")
+
+	ErrImpersonationRefused    = New(http.StatusForbidden, "IMPERSONATION_REFUSED", "this user cannot be impersonated")
+	ErrImpersonationNotAllowed = New(http.StatusForbidden, "IMPERSONATION_NOT_ALLOWED", "not allowed while impersonating a user")
+
+	ErrShippingMethodNotFound = New(http.StatusNotFound, "SHIPPING_METHOD_NOT_FOUND", "shipping method not found")
+	ErrShippingMethodRequired = New(http.StatusBadRequest, "SHIPPING_METHOD_REQUIRED", "a shipping method must be selected")
+	ErrDeliverySlotNotFound   = New(http.StatusNotFound, "DELIVERY_SLOT_NOT_FOUND", "delivery slot not found")
+	ErrDeliverySlotRequired   = New(http.StatusBadRequest, "DELIVERY_SLOT_REQUIRED", "a delivery slot and date must be selected")
+	ErrDeliverySlotClosed     = New(http.StatusBadRequest, "DELIVERY_SLOT_CLOSED", "the delivery slot cannot be booked for this date")
+	ErrDeliverySlotFull       = New(http.StatusConflict, "DELIVERY_SLOT_FULL", "the delivery slot is fully booked for this date")
+	ErrUnknownCarrier         = New(http.StatusBadRequest, "UNKNOWN_CARRIER", "unknown carrier")
+	ErrShipmentExists         = New(http.StatusBadRequest, "SHIPMENT_EXISTS", "a shipment with this tracking number already exists")
+	ErrGiftCardNotFound       = New(http.StatusNotFound, "GIFT_CARD_NOT_FOUND", "gift card not found")
+	ErrGiftCardVoided         = New(http.StatusBadRequest, "GIFT_CARD_VOIDED", "gift card has been voided")
+	ErrGiftCardEmpty          = New(http.StatusBadRequest, "GIFT_CARD_EMPTY", "gift card has no balance left")
+	ErrPromotionNotFound      = New(http.StatusNotFound, "PROMOTION_NOT_FOUND", "promotion not found")
+	ErrBundleNotFound         = New(http.StatusNotFound, "BUNDLE_NOT_FOUND", "bundle not found")
+	ErrSaleNotFound           = New(http.StatusNotFound, "SALE_NOT_FOUND", "sale not found")
+	ErrBackorderNotFound      = New(http.StatusNotFound, "BACKORDER_NOT_FOUND", "backorder not found")
+	ErrDownloadNotFound       = New(http.StatusNotFound, "DOWNLOAD_NOT_FOUND", "download not found")
+	ErrDownloadLinkInvalid    = New(http.StatusForbidden, "DOWNLOAD_LINK_INVALID", "download link is invalid or has expired")
+	ErrDownloadLimitReached   = New(http.StatusForbidden, "DOWNLOAD_LIMIT_REACHED", "download limit reached")
+	ErrWarehouseNotFound      = New(http.StatusNotFound, "WAREHOUSE_NOT_FOUND", "warehouse not found")
+	ErrAddressNotFound        = New(http.StatusNotFound, "ADDRESS_NOT_FOUND", "address not found")
+	ErrAddressUndeliverable   = New(http.StatusBadRequest, "ADDRESS_UNDELIVERABLE", "address is undeliverable")
+	ErrDeviceNotFound         = New(http.StatusNotFound, "DEVICE_NOT_FOUND", "device not found")
+	ErrFlagNotFound           = New(http.StatusNotFound, "FLAG_NOT_FOUND", "feature flag not found")
+	ErrPurgeRunNotFound       = New(http.StatusNotFound, "PURGE_RUN_NOT_FOUND", "purge run not found")
+	ErrPurgeInProgress        = New(http.StatusConflict, "PURGE_IN_PROGRESS", "a purge run is already in progress")
+
+	ErrUnsupportedCurrency      = New(http.StatusBadRequest, "UNSUPPORTED_CURRENCY", "currency is not supported")
+	ErrExchangeRatesUnavailable = New(http.StatusServiceUnavailable, "EXCHANGE_RATES_UNAVAILABLE", "exchange rates cannot be fetched right now")
+	ErrEmailTemplateNotFound    = New(http.StatusNotFound, "EMAIL_TEMPLATE_NOT_FOUND", "email template not found")
+	ErrTermsNotAccepted         = New(http.StatusForbidden, "TERMS_NOT_ACCEPTED", "the current terms must be accepted")
+)
+
+// New creates an error with the given HTTP status, code and default message
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Internal returns an INTERNAL error with a client-facing message, keeping
+// the underlying cause for logs
+func Internal(message string, cause error) *Error {
+	return ErrInternal.WithMessage(message).Wrap(cause)
+}
+
+// Validation returns a VALIDATION_FAILED error describing a rejected request
+func Validation(message string) *Error {
+	return ErrValidation.WithMessage(message)
+}
+
+// From converts any error into an *Error. Errors that are not application
+// errors become INTERNAL so their text is never shown to clients.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return ErrInternal.Wrap(err)
+}
+
+// Error implements the error interface, including the cause if there is one
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Code + ": " + e.Message + ": " + e.cause.Error()
+	}
+	return e.Code + ": " + e.Message
+}
+
+// Unwrap returns the underlying cause
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is matches errors by code, so errors.Is(err, ErrCartEmpty) holds for
+// copies made with WithMessage, WithDetails or Wrap
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithMessage returns a copy of e with a different message
+func (e *Error) WithMessage(message string) *Error {
+	c := *e
+	c.Message = message
+	c.custom = true
+	return &c
+}
+
+// WithDetails returns a copy of e carrying additional details for clients
+func (e *Error) WithDetails(details interface{}) *Error {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// Wrap returns a copy of e recording cause as the underlying error
+func (e *Error) Wrap(cause error) *Error {
+	c := *e
+	c.cause = cause
+	return &c
+}
@@ -0,0 +1,32 @@
+package apperrors
+
+import (
+	"ecommerce-backend/i18n"
+)
+
+// Localize returns a copy of e with its message, and those of rejected
+// fields, translated into lang. Messages without a translation, including
+// custom ones set with WithMessage or SetMessage, are left in English.
+func (e *Error) Localize(lang string) *Error {
+	if lang == i18n.Default {
+		return e
+	}
+
+	c := *e
+	if !e.custom {
+		if message, ok := i18n.Message(lang, "errors."+e.Code); ok {
+			c.Message = message
+		}
+	}
+	if fields, ok := e.Details.([]FieldError); ok {
+		localized := make([]FieldError, len(fields))
+		for i, f := range fields {
+			if message, ok := i18n.Message(lang, "validation."+f.key); ok && !f.custom && f.key != "" {
+				f.Message = fill(message, f.Field, f.param)
+			}
+			localized[i] = f
+		}
+		c.Details = localized
+	}
+	return &c
+}
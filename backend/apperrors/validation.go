@@ -0,0 +1,222 @@
+package apperrors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	// key and param select and fill in the message's translation;
+	// custom messages set with SetMessage are not translated
+	key    string
+	param  string
+	custom bool
+}
+
+// Violation describes a business rule a request broke, such as a rule
+// orders must meet at checkout, with the values it was checked against
+type Violation struct {
+	Rule    string                 `json:"rule"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+var (
+	messagesMu sync.RWMutex
+	// messages holds custom messages keyed by "rule" or "field.rule"
+	messages = map[string]string{}
+)
+
+func init() {
+	// Report fields by their JSON names rather than Go struct field names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+	// Reject fields the request types do not declare, such as a misspelled
+	// "qty", rather than silently ignoring them
+	binding.EnableDecoderDisallowUnknownFields = true
+}
+
+// SetMessage overrides the message for a validation rule, either for every
+// field ("min") or for one field ("quantity.min"). The placeholders {field}
+// and {param} are replaced with the field name and the rule's parameter.
+func SetMessage(key, message string) {
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+	messages[key] = message
+}
+
+// Binding converts an error from c.ShouldBind* into a VALIDATION_FAILED
+// error whose details list every rejected field
+func Binding(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, translate(fe))
+		}
+		return ErrValidation.WithDetails(fields)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return ErrValidation.WithDetails([]FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: typeErr.Field + " must be of type " + typeErr.Type.String(),
+			key:     "type",
+			param:   typeErr.Type.String(),
+		}})
+	}
+
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field, _ = strconv.Unquote(field)
+		return ErrValidation.WithDetails([]FieldError{{
+			Field:   field,
+			Rule:    "unknown",
+			Message: field + " is not a known field",
+			key:     "unknown",
+		}})
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return ErrPayloadTooLarge
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return Validation("request body is not valid JSON")
+	}
+	if errors.Is(err, io.EOF) {
+		return Validation("request body is required")
+	}
+
+	return Validation(err.Error())
+}
+
+// translate builds the client-facing description of a failed rule
+func translate(fe validator.FieldError) FieldError {
+	field := fieldPath(fe)
+
+	messagesMu.RLock()
+	message, ok := messages[field+"."+fe.Tag()]
+	if !ok {
+		message, ok = messages[fe.Tag()]
+	}
+	messagesMu.RUnlock()
+
+	key, param := messageKey(fe)
+	if ok {
+		message = fill(message, field, fe.Param())
+	} else {
+		message = field + " " + defaultMessage(fe)
+	}
+
+	return FieldError{Field: field, Rule: fe.Tag(), Message: message, key: key, param: param, custom: ok}
+}
+
+// fill substitutes the {field} and {param} placeholders of a message
+func fill(message, field, param string) string {
+	return strings.NewReplacer("{field}", field, "{param}", param).Replace(message)
+}
+
+// messageKey returns the translation key of the default message for a
+// failed rule, such as "min.string", and the parameter it mentions
+func messageKey(fe validator.FieldError) (string, string) {
+	suffix := ""
+	switch fe.Kind() {
+	case reflect.String:
+		suffix = ".string"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		suffix = ".list"
+	}
+
+	switch fe.Tag() {
+	case "required", "gt", "lt", "len", "email", "url", "unique":
+		return fe.Tag(), fe.Param()
+	case "min", "gte":
+		return "min" + suffix, fe.Param()
+	case "max", "lte":
+		return "max" + suffix, fe.Param()
+	case "oneof":
+		return "oneof", strings.Join(strings.Fields(fe.Param()), ", ")
+	}
+	return "rule", strconv.Quote(fe.Tag())
+}
+
+// fieldPath returns the field's JSON path without the request struct name,
+// e.g. "items[0].item_id"
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+func defaultMessage(fe validator.FieldError) string {
+	isString := fe.Kind() == reflect.String
+	isList := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map || fe.Kind() == reflect.Array
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		switch {
+		case isString:
+			return fmt.Sprintf("must be at least %s characters long", fe.Param())
+		case isList:
+			return fmt.Sprintf("must contain at least %s entries", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		switch {
+		case isString:
+			return fmt.Sprintf("must be at most %s characters long", fe.Param())
+		case isList:
+			return fmt.Sprintf("must contain at most %s entries", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "len":
+		return "must have length " + fe.Param()
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "unique":
+		return "must not contain duplicates"
+	}
+	return fmt.Sprintf("failed the %q rule", fe.Tag())
+}
+
+func jsonFieldName(f reflect.StructField) string {
+	name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"expvar"
+	"strconv"
+	"time"
+)
+
+// Cache stores opaque values with a TTL. Implementations must be safe for
+// concurrent use.
+type Cache interface {
+	// Get returns the value for key and whether it was found
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	// Incr atomically increments the integer stored at key and returns the
+	// new value; missing keys start at zero and never expire
+	Incr(ctx context.Context, key string) (int64, error)
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Hit and miss counters per namespace, exposed via /debug/vars
+var (
+	hits   = expvar.NewMap("cache_hits")
+	misses = expvar.NewMap("cache_misses")
+)
+
+var (
+	current    Cache = NewMemory()
+	defaultTTL       = 5 * time.Minute
+)
+
+// Init selects the cache backend: Redis when a URL is configured, otherwise
+// an in-process memory cache
+func Init(cfg config.CacheConfig) error {
+	if cfg.TTL > 0 {
+		defaultTTL = cfg.TTL
+	}
+	if cfg.RedisURL == "" {
+		current = NewMemory()
+		return nil
+	}
+
+	redisCache, err := NewRedis(cfg.RedisURL)
+	if err != nil {
+		return err
+	}
+	current = redisCache
+	return nil
+}
+
+// Get returns the active cache backend
+func Get() Cache {
+	return current
+}
+
+// Close releases the active backend's connections
+func Close() error {
+	return current.Close()
+}
+
+// Namespace groups related keys so they can be invalidated together. Keys
+// embed a generation number; Invalidate bumps the generation, which orphans
+// every existing entry until it expires.
+type Namespace struct {
+	name string
+}
+
+// NewNamespace returns a namespace with the given name
+func NewNamespace(name string) Namespace {
+	return Namespace{name: name}
+}
+
+// Get looks up key in the namespace and records a hit or miss
+func (n Namespace) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	fullKey, err := n.key(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, found, err := current.Get(ctx, fullKey)
+	if err != nil {
+		return nil, false, err
+	}
+	if found {
+		hits.Add(n.name, 1)
+	} else {
+		misses.Add(n.name, 1)
+	}
+	return value, found, nil
+}
+
+// Set stores value under key in the namespace for the configured TTL
+func (n Namespace) Set(ctx context.Context, key string, value []byte) error {
+	fullKey, err := n.key(ctx, key)
+	if err != nil {
+		return err
+	}
+	return current.Set(ctx, fullKey, value, defaultTTL)
+}
+
+// Invalidate discards every entry in the namespace
+func (n Namespace) Invalidate(ctx context.Context) error {
+	_, err := current.Incr(ctx, n.generationKey())
+	return err
+}
+
+func (n Namespace) key(ctx context.Context, key string) (string, error) {
+	value, found, err := current.Get(ctx, n.generationKey())
+	if err != nil {
+		return "", err
+	}
+
+	generation := "0"
+	if found {
+		generation = string(value)
+	}
+	return n.name + ":" + generation + ":" + key, nil
+}
+
+func (n Namespace) generationKey() string {
+	return n.name + ":generation"
+}
+
+// parseCounter decodes a value written by Incr
+func parseCounter(value []byte) int64 {
+	n, _ := strconv.ParseInt(string(value), 10, 64)
+	return n
+}
@@ -0,0 +1,107 @@
+// Package captcha checks that registrations and logins come from people by
+// having them solve a CAPTCHA, verified with hCaptcha or Cloudflare
+// Turnstile. Depending on the configuration every attempt needs one, or
+// only attempts from clients over a rate limit do. Without a provider no
+// CAPTCHA is ever asked for.
+package captcha
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/cache"
+	"ecommerce-backend/config"
+	"ecommerce-backend/logging"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRejected is returned by verifiers for tokens that are invalid, expired
+// or already used
+var ErrRejected = errors.New("captcha token was rejected")
+
+// Verifier checks the token a client got by solving a CAPTCHA.
+// Implementations must be safe for concurrent use.
+type Verifier interface {
+	Verify(ctx context.Context, token, clientIP string) error
+}
+
+var (
+	mu       sync.RWMutex
+	cfg      = config.Default().Captcha
+	verifier Verifier
+)
+
+// Init selects the CAPTCHA provider and when it is asked for
+func Init(c config.CaptchaConfig) {
+	var v Verifier
+	switch c.Provider {
+	case "hcaptcha":
+		v = newSiteVerifier(c, "https://api.hcaptcha.com/siteverify")
+	case "turnstile":
+		v = newSiteVerifier(c, "https://challenges.cloudflare.com/turnstile/v0/siteverify")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	cfg, verifier = c, v
+}
+
+// SetVerifier replaces the verifier, nil disabling CAPTCHAs; mainly useful
+// for tests
+func SetVerifier(v Verifier) {
+	mu.Lock()
+	defer mu.Unlock()
+	verifier = v
+}
+
+// Check counts a registration or login attempt from the client and, if it
+// needs a CAPTCHA, verifies the token the client solved it for. It fails
+// with CAPTCHA_REQUIRED when no token was sent, CAPTCHA_INVALID when the
+// provider rejects it and CAPTCHA_UNAVAILABLE when the provider cannot be
+// reached.
+func Check(ctx context.Context, clientIP, token string) error {
+	mu.RLock()
+	c, v := cfg, verifier
+	mu.RUnlock()
+	if v == nil || !(c.Always || overLimit(ctx, c, clientIP)) {
+		return nil
+	}
+
+	if token == "" {
+		return apperrors.ErrCaptchaRequired
+	}
+	if err := v.Verify(ctx, token, clientIP); err != nil {
+		if errors.Is(err, ErrRejected) {
+			return apperrors.ErrCaptchaInvalid
+		}
+		logging.FromContext(ctx).Error("failed to verify captcha", "error", err)
+		return apperrors.ErrCaptchaUnavailable
+	}
+	return nil
+}
+
+// overLimit counts an attempt from the client in the current rate window
+// and reports whether it has made more than the limit. Attempts are counted
+// in the cache, so that they add up across instances sharing it; when it
+// fails, no CAPTCHA is asked for.
+func overLimit(ctx context.Context, c config.CaptchaConfig, clientIP string) bool {
+	if c.RateLimit == 0 {
+		return false
+	}
+
+	window := time.Now().UnixNano() / int64(c.RateWindow)
+	key := "captcha:" + clientIP + ":" + strconv.FormatInt(window, 10)
+	value, _, err := cache.Get().Get(ctx, key)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to count captcha attempt", "error", err)
+		return false
+	}
+	attempts, _ := strconv.Atoi(string(value))
+	attempts++
+	if err := cache.Get().Set(ctx, key, []byte(strconv.Itoa(attempts)), c.RateWindow); err != nil {
+		logging.FromContext(ctx).Warn("failed to count captcha attempt", "error", err)
+	}
+	return attempts > c.RateLimit
+}
@@ -0,0 +1,106 @@
+package carriers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"ecommerce-backend/config"
+	"ecommerce-backend/resilience"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+	// maxWebhookSize bounds the body of pushed updates
+	maxWebhookSize = 1 << 20
+	// SignatureHeader carries the hex HMAC-SHA256 of a webhook body, keyed
+	// with TRACKING_WEBHOOK_SECRET
+	SignatureHeader = "X-Tracking-Signature"
+)
+
+// client propagates the trace context of the caller to the tracking API, retrying
+// failed requests and failing fast while it is down
+var client = &http.Client{
+	Transport: resilience.Transport("tracking", requestTimeout, otelhttp.NewTransport(http.DefaultTransport)),
+}
+
+// apiProvider serves a carrier through a multi-carrier tracking API. The
+// API answers GET {url}/trackings/{carrier}/{number} with {"events":[...]}
+// and pushes {"tracking_number":"...","events":[...]} to our webhook,
+// signed with the shared secret.
+type apiProvider struct {
+	carrier string
+	cfg     config.TrackingConfig
+}
+
+// trackingPayload is the body of tracking responses and webhooks
+type trackingPayload struct {
+	TrackingNumber string  `json:"tracking_number"`
+	Events         []Event `json:"events"`
+}
+
+func (p apiProvider) Track(ctx context.Context, trackingNumber string) ([]Event, error) {
+	endpoint := strings.TrimRight(p.cfg.APIURL, "/") + "/trackings/" +
+		url.PathEscape(p.carrier) + "/" + url.PathEscape(trackingNumber)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating tracking request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tracking: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("tracking API responded with status %d", resp.StatusCode)
+	}
+
+	var payload trackingPayload
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("error decoding tracking: %v", err)
+	}
+	return payload.Events, nil
+}
+
+func (p apiProvider) ParseWebhook(r *http.Request) (string, []Event, error) {
+	if p.cfg.WebhookSecret == "" {
+		return "", nil, ErrWebhooksUnsupported
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookSize))
+	if err != nil {
+		return "", nil, err
+	}
+	signature, err := hex.DecodeString(r.Header.Get(SignatureHeader))
+	if err != nil || !hmac.Equal(signature, Sign(p.cfg.WebhookSecret, body)) {
+		return "", nil, ErrInvalidSignature
+	}
+
+	var payload trackingPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", nil, fmt.Errorf("error decoding webhook: %v", err)
+	}
+	return payload.TrackingNumber, payload.Events, nil
+}
+
+// Sign returns the HMAC-SHA256 of body keyed with secret
+func Sign(secret string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
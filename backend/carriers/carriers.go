@@ -0,0 +1,93 @@
+// Package carriers fetches shipment tracking from carrier APIs. Each
+// carrier is served by a Provider that can poll for a shipment's tracking
+// events and read the updates the carrier pushes to our webhook. The
+// sandbox carrier is always available for development; real carriers are
+// served through the tracking API configured with TRACKING_API_URL.
+package carriers
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sandbox is the name of the simulated carrier
+const Sandbox = "sandbox"
+
+var (
+	// ErrWebhooksUnsupported is returned by providers that do not accept
+	// pushed updates
+	ErrWebhooksUnsupported = errors.New("carrier does not send webhooks")
+	// ErrInvalidSignature is returned for webhooks that fail verification
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Event is a tracking event reported by a carrier
+type Event struct {
+	Status      string    `json:"status"`
+	Description string    `json:"description"`
+	Location    string    `json:"location"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// Provider talks to one carrier's tracking API
+type Provider interface {
+	// Track returns every tracking event of the shipment, in any order
+	Track(ctx context.Context, trackingNumber string) ([]Event, error)
+	// ParseWebhook verifies and reads an update pushed by the carrier,
+	// returning the tracking number it concerns and its events
+	ParseWebhook(r *http.Request) (string, []Event, error)
+}
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{}
+)
+
+func init() {
+	Register(Sandbox, newSandboxProvider())
+}
+
+// Init registers a provider for each carrier served by the configured
+// tracking API
+func Init(cfg config.TrackingConfig) {
+	if cfg.APIURL == "" {
+		return
+	}
+	for _, carrier := range cfg.Carriers {
+		Register(carrier, apiProvider{carrier: carrier, cfg: cfg})
+	}
+}
+
+// Register serves the carrier with p, replacing any provider it had;
+// mainly useful for tests
+func Register(carrier string, p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[carrier] = p
+}
+
+// Get returns the provider of the carrier
+func Get(carrier string) (Provider, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := providers[carrier]
+	return p, ok
+}
+
+// Names returns the carriers with a provider, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
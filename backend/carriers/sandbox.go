@@ -0,0 +1,57 @@
+package carriers
+
+import (
+	"context"
+	"ecommerce-backend/models"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// sandboxStep is how long the sandbox carrier takes to move a shipment to
+// its next status
+const sandboxStep = time.Minute
+
+// sandboxSteps are the events the sandbox carrier reports, in order
+var sandboxSteps = []Event{
+	{Status: models.TrackingInfoReceived, Description: "Shipping label created", Location: "Sandbox Warehouse"},
+	{Status: models.TrackingInTransit, Description: "Departed facility", Location: "Sandbox Hub"},
+	{Status: models.TrackingOutForDelivery, Description: "Out for delivery", Location: "Sandbox City"},
+	{Status: models.TrackingDelivered, Description: "Delivered", Location: "Sandbox City"},
+}
+
+// sandboxProvider simulates a carrier: from the first time a tracking
+// number is tracked, it reports one more of sandboxSteps every sandboxStep.
+// Progress is kept in memory, so shipments start over after a restart.
+type sandboxProvider struct {
+	mu      sync.Mutex
+	started map[string]time.Time
+}
+
+func newSandboxProvider() *sandboxProvider {
+	return &sandboxProvider{started: map[string]time.Time{}}
+}
+
+func (p *sandboxProvider) Track(ctx context.Context, trackingNumber string) ([]Event, error) {
+	p.mu.Lock()
+	start, ok := p.started[trackingNumber]
+	if !ok {
+		start = time.Now().UTC().Truncate(time.Second)
+		p.started[trackingNumber] = start
+	}
+	p.mu.Unlock()
+
+	var events []Event
+	for i, step := range sandboxSteps {
+		step.OccurredAt = start.Add(time.Duration(i) * sandboxStep)
+		if step.OccurredAt.After(time.Now()) {
+			break
+		}
+		events = append(events, step)
+	}
+	return events, nil
+}
+
+func (p *sandboxProvider) ParseWebhook(r *http.Request) (string, []Event, error) {
+	return "", nil, ErrWebhooksUnsupported
+}
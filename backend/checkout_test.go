@@ -0,0 +1,171 @@
+package main
+
+import (
+	"ecommerce-backend/models"
+	"ecommerce-backend/testutil"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestCheckoutFlow(t *testing.T) {
+	db := testutil.Setup(t)
+	client := testutil.NewClient(t, setupRouter())
+
+	widget := testutil.CreateItem(t, db, "Widget", 2.50)
+	gadget := testutil.CreateItem(t, db, "Gadget", 10)
+
+	// Register
+	var registered struct{ Token string }
+	client.POST("/api/v1/users", map[string]string{"username": "alice", "password": "secret1"}).
+		AssertStatus(http.StatusCreated).
+		Decode(&registered)
+	alice := client.WithToken(registered.Token)
+
+	// Nothing to check out yet
+	alice.POST("/api/v1/orders", nil).
+		AssertStatus(http.StatusBadRequest).
+		AssertJSON("error.code", "CART_NOT_FOUND")
+
+	// Fill the cart; adding an item twice sums the quantities
+	alice.POST("/api/v1/carts", map[string]interface{}{"item_id": widget.ID, "quantity": 1}).AssertStatus(http.StatusOK)
+	alice.POST("/api/v1/carts", map[string]interface{}{"item_id": widget.ID, "quantity": 1}).AssertStatus(http.StatusOK)
+	alice.POST("/api/v1/carts", map[string]interface{}{"item_id": gadget.ID, "quantity": 1}).AssertStatus(http.StatusOK)
+	alice.POST("/api/v1/carts", map[string]interface{}{"item_id": 9999, "quantity": 1}).
+		AssertStatus(http.StatusNotFound).
+		AssertJSON("error.code", "ITEM_NOT_FOUND")
+
+	alice.GET("/api/v1/carts/user").
+		AssertStatus(http.StatusOK).
+		AssertLen("items", 2).
+		AssertJSON("items.0.quantity", 2).
+		AssertJSON("total", 15)
+
+	// Check out
+	orderID := alice.POST("/api/v1/orders", nil).
+		AssertStatus(http.StatusCreated).
+		JSON("order_id")
+
+	alice.GET("/api/v1/carts/user").
+		AssertStatus(http.StatusOK).
+		AssertJSON("cart", nil)
+	alice.POST("/api/v1/orders", nil).
+		AssertStatus(http.StatusBadRequest).
+		AssertJSON("error.code", "CART_NOT_FOUND")
+
+	alice.GET("/api/v1/orders/user").
+		AssertStatus(http.StatusOK).
+		AssertLen("orders", 1).
+		AssertJSON("orders.0.id", orderID).
+		AssertJSON("orders.0.total", 15).
+		AssertJSON("orders.0.status", "completed").
+		AssertLen("orders.0.items", 2)
+
+	// Customers cannot list every order; admins can
+	alice.GET("/api/v1/orders").AssertStatus(http.StatusForbidden)
+
+	admin := client.As(testutil.CreateUser(t, db, "root", models.RoleAdmin))
+	admin.GET("/api/v1/orders").
+		AssertStatus(http.StatusOK).
+		AssertLen("orders", 1).
+		AssertJSON("orders.0.username", "alice").
+		AssertJSON("orders.0.total", 15)
+}
+
+// checkoutConcurrently checks out as each client at the same time and
+// returns the responses
+func checkoutConcurrently(clients []*testutil.Client) []*testutil.Response {
+	responses := make([]*testutil.Response, len(clients))
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client *testutil.Client) {
+			defer wg.Done()
+			responses[i] = client.POST("/api/v1/orders", nil)
+		}(i, client)
+	}
+	wg.Wait()
+	return responses
+}
+
+func TestConcurrentCheckoutOfLastUnit(t *testing.T) {
+	db := testutil.Setup(t)
+	client := testutil.NewClient(t, setupRouter())
+
+	widget := testutil.CreateItem(t, db, "Widget", 5)
+	if err := db.Model(&widget).Update("stock", 1).Error; err != nil {
+		t.Fatalf("failed to stock widget: %v", err)
+	}
+
+	const shoppers = 8
+	var clients []*testutil.Client
+	for i := 0; i < shoppers; i++ {
+		shopper := client.As(testutil.CreateUser(t, db, fmt.Sprintf("shopper%d", i), models.RoleCustomer))
+		shopper.POST("/api/v1/carts", map[string]interface{}{"item_id": widget.ID, "quantity": 1}).AssertStatus(http.StatusOK)
+		clients = append(clients, shopper)
+	}
+
+	// Exactly one shopper gets the last widget
+	created := 0
+	for _, r := range checkoutConcurrently(clients) {
+		if r.Code == http.StatusCreated {
+			created++
+			continue
+		}
+		r.AssertStatus(http.StatusConflict).AssertJSON("error.code", "INSUFFICIENT_STOCK")
+	}
+	if created != 1 {
+		t.Fatalf("%d checkouts succeeded, want 1", created)
+	}
+
+	var item models.Item
+	if err := db.First(&item, widget.ID).Error; err != nil {
+		t.Fatalf("failed to reload widget: %v", err)
+	}
+	if item.Stock == nil || *item.Stock != 0 {
+		t.Fatalf("widget stock = %v, want 0", item.Stock)
+	}
+}
+
+func TestConcurrentCheckoutOfSameCart(t *testing.T) {
+	db := testutil.Setup(t)
+	client := testutil.NewClient(t, setupRouter())
+
+	widget := testutil.CreateItem(t, db, "Widget", 5)
+	if err := db.Model(&widget).Update("stock", 10).Error; err != nil {
+		t.Fatalf("failed to stock widget: %v", err)
+	}
+
+	alice := client.As(testutil.CreateUser(t, db, "alice", models.RoleCustomer))
+	alice.POST("/api/v1/carts", map[string]interface{}{"item_id": widget.ID, "quantity": 2}).AssertStatus(http.StatusOK)
+
+	// Several tabs checking out the same cart place a single order
+	tabs := make([]*testutil.Client, 8)
+	for i := range tabs {
+		tabs[i] = alice
+	}
+	created := 0
+	for _, r := range checkoutConcurrently(tabs) {
+		if r.Code == http.StatusCreated {
+			created++
+			continue
+		}
+		r.AssertStatus(http.StatusBadRequest).AssertJSON("error.code", "CART_NOT_FOUND")
+	}
+	if created != 1 {
+		t.Fatalf("%d checkouts succeeded, want 1", created)
+	}
+
+	alice.GET("/api/v1/orders/user").
+		AssertStatus(http.StatusOK).
+		AssertLen("orders", 1)
+
+	var item models.Item
+	if err := db.First(&item, widget.ID).Error; err != nil {
+		t.Fatalf("failed to reload widget: %v", err)
+	}
+	if item.Stock == nil || *item.Stock != 8 {
+		t.Fatalf("widget stock = %v, want 8", item.Stock)
+	}
+}
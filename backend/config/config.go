@@ -0,0 +1,1081 @@
+package config
+
+import (
+	"ecommerce-backend/encryption"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// minJWTSecretLength is the shortest JWT secret accepted at startup
+const minJWTSecretLength = 32
+
+type DBConfig struct {
+	Driver          string        `yaml:"driver"`
+	DSN             string        `yaml:"dsn"`
+	AutoMigrate     bool          `yaml:"auto_migrate"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	QueryTimeout    time.Duration `yaml:"query_timeout"`
+	// Replicas are DSNs of read replicas, using the same driver, that
+	// read-only endpoints query
+	Replicas []string `yaml:"replicas"`
+	// ReplicaCheckInterval is how often replicas are pinged; reads fall
+	// back to the primary while none answers
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval"`
+}
+
+type JWTConfig struct {
+	// Secret signs tokens while no Keys are configured, and verifies the
+	// tokens signed without a key ID
+	Secret string `yaml:"secret"`
+	// Keys are signing keys of the form "kid:secret", identified in tokens
+	// by their kid; the first signs new tokens
+	Keys       []string      `yaml:"keys"`
+	Expiration time.Duration `yaml:"expiration"`
+	// ImpersonationTTL is the lifetime of tokens admins obtain to act as
+	// a user
+	ImpersonationTTL time.Duration `yaml:"impersonation_ttl"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"`
+	AllowedMethods   []string      `yaml:"allowed_methods"`
+	AllowedHeaders   []string      `yaml:"allowed_headers"`
+	ExposedHeaders   []string      `yaml:"exposed_headers"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// SMSConfig configures a Twilio-compatible SMS provider, which answers
+// POST {api_url}/2010-04-01/Accounts/{account_sid}/Messages.json
+type SMSConfig struct {
+	APIURL     string `yaml:"api_url"`
+	AccountSID string `yaml:"account_sid"`
+	AuthToken  string `yaml:"auth_token"`
+	// From is the number or sender ID messages are sent from
+	From string `yaml:"from"`
+}
+
+// PushConfig configures the push notification providers: Firebase Cloud
+// Messaging for Android devices and the Apple Push Notification service for
+// iOS ones
+type PushConfig struct {
+	// FCMCredentialsFile is the JSON key of a service account allowed to
+	// send messages for the Firebase project FCMProjectID
+	FCMProjectID       string `yaml:"fcm_project_id"`
+	FCMCredentialsFile string `yaml:"fcm_credentials_file"`
+	FCMAPIURL          string `yaml:"fcm_api_url"`
+	// APNsKeyFile is the .p8 signing key APNsKeyID of the Apple developer
+	// team APNsTeamID, and APNsTopic the bundle ID of the iOS app
+	APNsKeyFile string `yaml:"apns_key_file"`
+	APNsKeyID   string `yaml:"apns_key_id"`
+	APNsTeamID  string `yaml:"apns_team_id"`
+	APNsTopic   string `yaml:"apns_topic"`
+	// APNsAPIURL is the production service, or the sandbox one for
+	// development builds of the app
+	APNsAPIURL string `yaml:"apns_api_url"`
+}
+
+type NotificationsConfig struct {
+	AdminEmails []string `yaml:"admin_emails"`
+}
+
+type CheckoutConfig struct {
+	// MinTotal is the least the items of an order may come to after
+	// discounts, before shipping; 0 for no minimum
+	MinTotal float64 `yaml:"min_total"`
+	// MaxItems is the most units an order may hold; 0 for no limit
+	MaxItems int `yaml:"max_items"`
+	// RestrictedCombinations are groups of item categories joined with
+	// "+", such as "alcohol+toys", whose items may not be ordered together
+	RestrictedCombinations []string `yaml:"restricted_combinations"`
+	// DuplicatePolicy is what checkout does with likely duplicates of an
+	// order, placed by the same user with the same items within
+	// DuplicateWindow: one of the DuplicateOrders constants. Stores may set
+	// their own.
+	DuplicatePolicy string        `yaml:"duplicate_policy"`
+	DuplicateWindow time.Duration `yaml:"duplicate_window"`
+}
+
+// Policies for likely duplicate orders
+const (
+	// DuplicateOrdersOff places them like any other order
+	DuplicateOrdersOff = "off"
+	// DuplicateOrdersBlock refuses them until the customer confirms them
+	DuplicateOrdersBlock = "block"
+	// DuplicateOrdersFlag places them, flagged for an admin to review
+	DuplicateOrdersFlag = "flag"
+)
+
+type DeliveryConfig struct {
+	// Days is how many days ahead, from today, customers can book a
+	// delivery slot
+	Days int `yaml:"days"`
+	// LeadTime is how long before a slot starts it stops taking orders
+	LeadTime time.Duration `yaml:"lead_time"`
+	// Timezone is the IANA time zone the slots' times of day are in
+	Timezone string `yaml:"timezone"`
+}
+
+type ReceiptConfig struct {
+	// Template is an html/template file replacing the built-in receipt
+	// template
+	Template  string `yaml:"template"`
+	BrandName string `yaml:"brand_name"`
+	LogoURL   string `yaml:"logo_url"`
+}
+
+type EmailConfig struct {
+	// TemplatesDir is a directory of templates replacing the built-in
+	// email templates of the same path
+	TemplatesDir string `yaml:"templates_dir"`
+	// BrandName heads and signs the emails
+	BrandName string `yaml:"brand_name"`
+}
+
+type InventoryConfig struct {
+	LowStockCheckInterval time.Duration `yaml:"low_stock_check_interval"`
+	// BackInStockCheckInterval is how often users subscribed to items that
+	// are back in stock are told
+	BackInStockCheckInterval time.Duration `yaml:"back_in_stock_check_interval"`
+}
+
+type SaleConfig struct {
+	// ScheduleInterval is how often sales are started and ended as their
+	// windows open and close
+	ScheduleInterval time.Duration `yaml:"schedule_interval"`
+}
+
+type TrackingConfig struct {
+	// PollInterval is how often undelivered shipments are tracked
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// APIURL is the base URL of the tracking API serving Carriers; without
+	// it only the sandbox carrier is available
+	APIURL        string   `yaml:"api_url"`
+	APIKey        string   `yaml:"api_key"`
+	WebhookSecret string   `yaml:"webhook_secret"`
+	Carriers      []string `yaml:"carriers"`
+}
+
+type TenancyConfig struct {
+	// BaseDomain is the domain whose subdomains name stores, so that
+	// acme.BaseDomain serves the store with slug acme
+	BaseDomain string `yaml:"base_domain"`
+}
+
+type CacheConfig struct {
+	RedisURL string        `yaml:"redis_url"`
+	TTL      time.Duration `yaml:"ttl"`
+}
+
+type SearchConfig struct {
+	// URL is the Elasticsearch or OpenSearch endpoint indexing the catalog;
+	// without it item search falls back to SQL LIKE matching
+	URL      string `yaml:"url"`
+	Index    string `yaml:"index"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type AddressConfig struct {
+	// ValidationURL is the address provider validating and normalizing
+	// shipping addresses; without it addresses are accepted as entered
+	ValidationURL string `yaml:"validation_url"`
+	APIKey        string `yaml:"api_key"`
+}
+
+type CurrencyConfig struct {
+	// Base is the ISO 4217 code of the currency prices are kept in
+	Base string `yaml:"base"`
+	// Supported are the other currencies prices may be shown and orders
+	// settled in; empty for every currency the provider has a rate for
+	Supported []string `yaml:"supported"`
+	// RatesURL is the exchange-rate provider; without it only Base is
+	// available
+	RatesURL string `yaml:"rates_url"`
+	APIKey   string `yaml:"api_key"`
+	// RefreshInterval is how long fetched rates are used before they are
+	// fetched again
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+}
+
+type StorageConfig struct {
+	// Dir is the directory uploaded files are kept in
+	Dir string `yaml:"dir"`
+	// BaseURL is the URL Dir is published at; a path such as /uploads is
+	// served by this server
+	BaseURL string `yaml:"base_url"`
+	// PrivateDir keeps the files of digital items, which are only served
+	// through signed download links; it must not be published
+	PrivateDir string `yaml:"private_dir"`
+}
+
+type DownloadConfig struct {
+	// Secret signs download links; JWT_SECRET_KEY is used if it is empty
+	Secret string `yaml:"secret"`
+	// LinkTTL is how long a download link works after it is issued
+	LinkTTL time.Duration `yaml:"link_ttl"`
+	// Limit is how many times each purchase may be downloaded; 0 is
+	// unlimited
+	Limit int `yaml:"limit"`
+}
+
+type CartConfig struct {
+	MaxOpen int `yaml:"max_open"`
+	// AbandonedAfter is how long a cart must go unchanged before its
+	// owner is reminded of it; 0 disables reminders
+	AbandonedAfter time.Duration `yaml:"abandoned_after"`
+	// ReminderInterval is how often abandoned carts are looked for
+	ReminderInterval time.Duration `yaml:"reminder_interval"`
+	// ReminderCooldown is the least time between two reminders to a user
+	ReminderCooldown time.Duration `yaml:"reminder_cooldown"`
+	// ReminderCoupon is the value of a gift card sent with each reminder;
+	// 0 sends none
+	ReminderCoupon float64 `yaml:"reminder_coupon"`
+	// ShareTTL is how long a cart sharing link works after it is issued
+	ShareTTL time.Duration `yaml:"share_ttl"`
+}
+
+type AccountConfig struct {
+	// ReactivationWindow is how long a deactivated account can be
+	// reactivated before it is anonymized
+	ReactivationWindow time.Duration `yaml:"reactivation_window"`
+	// AnonymizeInterval is how often accounts past the window are looked for
+	AnonymizeInterval time.Duration `yaml:"anonymize_interval"`
+	// TermsVersion and PrivacyVersion are the current versions of the terms
+	// of service and privacy policy, which users accept when registering;
+	// empty if the document is not tracked
+	TermsVersion   string `yaml:"terms_version"`
+	PrivacyVersion string `yaml:"privacy_version"`
+	// TermsReacceptance refuses logins until the user accepts the current
+	// versions, once either was bumped since they last accepted
+	TermsReacceptance bool `yaml:"terms_reacceptance"`
+}
+
+// PasswordConfig is the policy new passwords must meet
+type PasswordConfig struct {
+	MinLength int `yaml:"min_length"`
+	// MinClasses is how many character classes, of lowercase letters,
+	// uppercase letters, digits and symbols, a password must mix
+	MinClasses int `yaml:"min_classes"`
+	// MinScore is the least strength a password must score, from 0 (too
+	// guessable) to 4 (very unguessable) on the zxcvbn scale
+	MinScore int `yaml:"min_score"`
+	// BreachCheck refuses passwords known from data breaches, looked up in
+	// the Pwned Passwords range API at BreachAPIURL by the first five
+	// characters of their SHA-1 hash only
+	BreachCheck  bool   `yaml:"breach_check"`
+	BreachAPIURL string `yaml:"breach_api_url"`
+}
+
+// CaptchaConfig configures the CAPTCHA that registrations and logins may
+// have to be solved for
+type CaptchaConfig struct {
+	// Provider is hcaptcha or turnstile (Cloudflare Turnstile); empty
+	// disables CAPTCHAs
+	Provider  string `yaml:"provider"`
+	SecretKey string `yaml:"secret_key"`
+	// VerifyURL overrides the provider's siteverify endpoint
+	VerifyURL string `yaml:"verify_url"`
+	// Always asks for a CAPTCHA on every registration and login; otherwise
+	// only clients over RateLimit are asked for one
+	Always bool `yaml:"always"`
+	// RateLimit is how many registrations and logins a client IP may
+	// attempt per RateWindow before it is asked for a CAPTCHA; 0 for no
+	// limit
+	RateLimit  int           `yaml:"rate_limit"`
+	RateWindow time.Duration `yaml:"rate_window"`
+}
+
+type RetentionConfig struct {
+	// Interval is how often the retention policies purge expired data
+	Interval time.Duration `yaml:"interval"`
+	// CartDays is how long open carts are kept once they stop changing
+	CartDays int `yaml:"cart_days"`
+	// OutboxDays is how long relayed and abandoned outbox messages are kept
+	OutboxDays int `yaml:"outbox_days"`
+	// DeviceTokenDays is how long device tokens are kept once their app
+	// stops registering them
+	DeviceTokenDays int `yaml:"device_token_days"`
+}
+
+type OutboxConfig struct {
+	// RelayInterval is how often the outbox is checked for events to relay
+	RelayInterval time.Duration `yaml:"relay_interval"`
+	// MaxAttempts bounds the deliveries of an event to a webhook before it
+	// is given up on
+	MaxAttempts int `yaml:"max_attempts"`
+}
+
+type APIConfig struct {
+	LegacySunset string `yaml:"legacy_sunset"`
+	MaxBodySize  int    `yaml:"max_body_size"`
+	// KeyDailyQuota and KeyMonthlyQuota bound the requests made with an
+	// API key that has no quotas of its own; 0 for no limit
+	KeyDailyQuota   int `yaml:"key_daily_quota"`
+	KeyMonthlyQuota int `yaml:"key_monthly_quota"`
+}
+
+type MaintenanceConfig struct {
+	// Enabled keeps maintenance mode on, whatever is set through the admin
+	// API
+	Enabled bool `yaml:"enabled"`
+	// RetryAfter is how long clients are told to wait before retrying
+	// writes refused for maintenance
+	RetryAfter time.Duration `yaml:"retry_after"`
+}
+
+type ResilienceConfig struct {
+	// Retries is how many times a failed call to an external service is
+	// retried
+	Retries int `yaml:"retries"`
+	// BreakerFailures is how many calls to a service must fail in a row for
+	// its circuit breaker to open and fail further calls at once
+	BreakerFailures int `yaml:"breaker_failures"`
+	// BreakerCooldown is how long an open breaker waits before letting a
+	// trial call through
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown"`
+}
+
+type EncryptionConfig struct {
+	// Keys encrypt personal data at rest, each as "id:base64-key" with a
+	// 32-byte key. The first encrypts new values; the others only decrypt
+	// values written before a rotation.
+	Keys []string `yaml:"keys"`
+}
+
+type GRPCConfig struct {
+	Port string `yaml:"port"`
+}
+
+type AuditConfig struct {
+	Routes        []string `yaml:"routes"`
+	RetentionDays int      `yaml:"retention_days"`
+}
+
+// Config holds all runtime configuration for the backend
+type Config struct {
+	Port            string              `yaml:"port"`
+	ShutdownTimeout time.Duration       `yaml:"shutdown_timeout"`
+	Log             LogConfig           `yaml:"log"`
+	Tracing         TracingConfig       `yaml:"tracing"`
+	DB              DBConfig            `yaml:"db"`
+	JWT             JWTConfig           `yaml:"jwt"`
+	BcryptCost      int                 `yaml:"bcrypt_cost"`
+	CORS            CORSConfig          `yaml:"cors"`
+	SMTP            SMTPConfig          `yaml:"smtp"`
+	SMS             SMSConfig           `yaml:"sms"`
+	Push            PushConfig          `yaml:"push"`
+	Notifications   NotificationsConfig `yaml:"notifications"`
+	Receipts        ReceiptConfig       `yaml:"receipts"`
+	Emails          EmailConfig         `yaml:"emails"`
+	Tenancy         TenancyConfig       `yaml:"tenancy"`
+	Cache           CacheConfig         `yaml:"cache"`
+	Search          SearchConfig        `yaml:"search"`
+	Storage         StorageConfig       `yaml:"storage"`
+	Downloads       DownloadConfig      `yaml:"downloads"`
+	Addresses       AddressConfig       `yaml:"addresses"`
+	Currency        CurrencyConfig      `yaml:"currency"`
+	Carts           CartConfig          `yaml:"carts"`
+	Checkout        CheckoutConfig      `yaml:"checkout"`
+	Delivery        DeliveryConfig      `yaml:"delivery"`
+	Accounts        AccountConfig       `yaml:"accounts"`
+	Passwords       PasswordConfig      `yaml:"passwords"`
+	Captcha         CaptchaConfig       `yaml:"captcha"`
+	Inventory       InventoryConfig     `yaml:"inventory"`
+	Sales           SaleConfig          `yaml:"sales"`
+	Tracking        TrackingConfig      `yaml:"tracking"`
+	Audit           AuditConfig         `yaml:"audit"`
+	Retention       RetentionConfig     `yaml:"retention"`
+	API             APIConfig           `yaml:"api"`
+	Maintenance     MaintenanceConfig   `yaml:"maintenance"`
+	Resilience      ResilienceConfig    `yaml:"resilience"`
+	Outbox          OutboxConfig        `yaml:"outbox"`
+	Encryption      EncryptionConfig    `yaml:"encryption"`
+	GRPC            GRPCConfig          `yaml:"grpc"`
+}
+
+var (
+	mu      sync.RWMutex
+	current *Config
+)
+
+// Default returns the configuration used for values that are not set
+func Default() *Config {
+	return &Config{
+		Port:            "8080",
+		ShutdownTimeout: 30 * time.Second,
+		Log:             LogConfig{Level: "info", Format: "json"},
+		Tracing:         TracingConfig{Endpoint: "localhost:4318", ServiceName: "ecommerce-backend", SampleRatio: 1},
+		DB: DBConfig{
+			Driver:               "sqlite",
+			DSN:                  "ecommerce.db",
+			MaxOpenConns:         25,
+			MaxIdleConns:         10,
+			ConnMaxLifetime:      30 * time.Minute,
+			ConnMaxIdleTime:      5 * time.Minute,
+			QueryTimeout:         10 * time.Second,
+			ReplicaCheckInterval: 10 * time.Second,
+		},
+		JWT:        JWTConfig{Expiration: 24 * time.Hour, ImpersonationTTL: 15 * time.Minute},
+		BcryptCost: bcrypt.DefaultCost,
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "X-Request-ID", "X-API-Key", "X-Store"},
+			ExposedHeaders: []string{"X-Request-ID", "Deprecation", "Sunset", "Link"},
+			MaxAge:         12 * time.Hour,
+		},
+		SMTP:      SMTPConfig{Port: 587},
+		SMS:       SMSConfig{APIURL: "https://api.twilio.com"},
+		Push:      PushConfig{FCMAPIURL: "https://fcm.googleapis.com", APNsAPIURL: "https://api.push.apple.com"},
+		Cache:     CacheConfig{TTL: 5 * time.Minute},
+		Search:    SearchConfig{Index: "items"},
+		Storage:   StorageConfig{Dir: "uploads", BaseURL: "/uploads", PrivateDir: "private"},
+		Downloads: DownloadConfig{LinkTTL: 15 * time.Minute, Limit: 5},
+		Inventory: InventoryConfig{LowStockCheckInterval: 15 * time.Minute, BackInStockCheckInterval: 5 * time.Minute},
+		Sales:     SaleConfig{ScheduleInterval: time.Minute},
+		Audit:     AuditConfig{RetentionDays: 365},
+		Retention: RetentionConfig{Interval: time.Hour, CartDays: 90, OutboxDays: 7, DeviceTokenDays: 60},
+		API:       APIConfig{MaxBodySize: 1 << 20, KeyDailyQuota: 10000, KeyMonthlyQuota: 200000},
+		Tracking: TrackingConfig{
+			PollInterval: 30 * time.Minute,
+			Carriers:     []string{"ups", "fedex", "usps", "dhl"},
+		},
+		Carts: CartConfig{
+			MaxOpen:          1,
+			AbandonedAfter:   24 * time.Hour,
+			ReminderInterval: time.Hour,
+			ReminderCooldown: 7 * 24 * time.Hour,
+			ShareTTL:         72 * time.Hour,
+		},
+		Accounts: AccountConfig{
+			ReactivationWindow: 30 * 24 * time.Hour,
+			AnonymizeInterval:  time.Hour,
+			TermsReacceptance:  true,
+		},
+		Checkout:    CheckoutConfig{DuplicatePolicy: DuplicateOrdersOff, DuplicateWindow: 10 * time.Minute},
+		Delivery:    DeliveryConfig{Days: 14, LeadTime: 12 * time.Hour, Timezone: "UTC"},
+		Currency:    CurrencyConfig{Base: "USD", RefreshInterval: 24 * time.Hour},
+		Passwords:   PasswordConfig{MinLength: 6, BreachAPIURL: "https://api.pwnedpasswords.com"},
+		Captcha:     CaptchaConfig{RateLimit: 10, RateWindow: 15 * time.Minute},
+		Maintenance: MaintenanceConfig{RetryAfter: 5 * time.Minute},
+		Resilience:  ResilienceConfig{Retries: 2, BreakerFailures: 5, BreakerCooldown: 30 * time.Second},
+		Outbox:      OutboxConfig{RelayInterval: time.Second, MaxAttempts: 10},
+	}
+}
+
+// Load builds the configuration from defaults, the optional YAML file named
+// by CONFIG_FILE, and environment variables (which take precedence), then
+// validates it. The result becomes available through Get.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config file: %v", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("error parsing config file: %v", err)
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	Set(cfg)
+	return cfg, nil
+}
+
+// Get returns the loaded configuration, or the defaults if Load has not been called
+func Get() *Config {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if current == nil {
+		return Default()
+	}
+	return current
+}
+
+// Set replaces the active configuration; mainly useful for tools and tests
+func Set(cfg *Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = cfg
+}
+
+// Validate checks that required values are present and sane
+func (c *Config) Validate() error {
+	var errs []string
+
+	if c.Port == "" {
+		errs = append(errs, "PORT must not be empty")
+	}
+
+	if c.GRPC.Port != "" && c.GRPC.Port == c.Port {
+		errs = append(errs, "GRPC_PORT must differ from PORT")
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, "SHUTDOWN_TIMEOUT must be positive")
+	}
+
+	switch c.Log.Format {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Sprintf("unsupported LOG_FORMAT %q", c.Log.Format))
+	}
+
+	if c.Tracing.Enabled {
+		if c.Tracing.Endpoint == "" {
+			errs = append(errs, "OTEL_EXPORTER_OTLP_ENDPOINT is required when tracing is enabled")
+		}
+		if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+			errs = append(errs, "TRACING_SAMPLE_RATIO must be between 0 and 1")
+		}
+	}
+
+	switch c.DB.Driver {
+	case "sqlite":
+	case "postgres", "mysql":
+		if c.DB.DSN == "" {
+			errs = append(errs, "DB_DSN is required for the "+c.DB.Driver+" driver")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("unsupported DB_DRIVER %q", c.DB.Driver))
+	}
+
+	if c.DB.MaxOpenConns < 0 || c.DB.MaxIdleConns < 0 {
+		errs = append(errs, "DB_MAX_OPEN_CONNS and DB_MAX_IDLE_CONNS must not be negative")
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		errs = append(errs, "DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS")
+	}
+	if c.DB.QueryTimeout < 0 {
+		errs = append(errs, "DB_QUERY_TIMEOUT must not be negative")
+	}
+	if c.DB.ReplicaCheckInterval <= 0 {
+		errs = append(errs, "DB_REPLICA_CHECK_INTERVAL must be positive")
+	}
+
+	if c.JWT.Secret == "" {
+		errs = append(errs, "JWT_SECRET_KEY is required")
+	} else if len(c.JWT.Secret) < minJWTSecretLength {
+		errs = append(errs, fmt.Sprintf("JWT_SECRET_KEY must be at least %d characters", minJWTSecretLength))
+	}
+	if c.JWT.Expiration <= 0 {
+		errs = append(errs, "JWT_EXPIRATION must be positive")
+	}
+	if c.JWT.ImpersonationTTL <= 0 {
+		errs = append(errs, "JWT_IMPERSONATION_TTL must be positive")
+	}
+
+	if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Sprintf("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+
+	if c.CORS.AllowCredentials {
+		for _, origin := range c.CORS.AllowedOrigins {
+			if origin == "*" {
+				errs = append(errs, "CORS_ALLOWED_ORIGINS must list explicit origins when CORS_ALLOW_CREDENTIALS is true")
+				break
+			}
+		}
+	}
+
+	if c.SMTP.Host != "" && c.SMTP.From == "" {
+		errs = append(errs, "SMTP_FROM is required when SMTP_HOST is set")
+	}
+	if c.SMS.AccountSID != "" && (c.SMS.AuthToken == "" || c.SMS.From == "") {
+		errs = append(errs, "SMS_AUTH_TOKEN and SMS_FROM are required when SMS_ACCOUNT_SID is set")
+	}
+	if c.Push.FCMProjectID != "" && c.Push.FCMCredentialsFile == "" {
+		errs = append(errs, "FCM_CREDENTIALS_FILE is required when FCM_PROJECT_ID is set")
+	}
+	if c.Push.APNsKeyFile != "" && (c.Push.APNsKeyID == "" || c.Push.APNsTeamID == "" || c.Push.APNsTopic == "") {
+		errs = append(errs, "APNS_KEY_ID, APNS_TEAM_ID and APNS_TOPIC are required when APNS_KEY_FILE is set")
+	}
+
+	if c.Cache.TTL <= 0 {
+		errs = append(errs, "CACHE_TTL must be positive")
+	}
+
+	if c.Search.URL != "" && c.Search.Index == "" {
+		errs = append(errs, "SEARCH_INDEX must not be empty when SEARCH_URL is set")
+	}
+
+	if c.Storage.Dir == "" || c.Storage.BaseURL == "" {
+		errs = append(errs, "STORAGE_DIR and STORAGE_BASE_URL must not be empty")
+	}
+	if c.Storage.PrivateDir == "" {
+		errs = append(errs, "STORAGE_PRIVATE_DIR must not be empty")
+	} else if filepath.Clean(c.Storage.PrivateDir) == filepath.Clean(c.Storage.Dir) {
+		errs = append(errs, "STORAGE_PRIVATE_DIR must differ from STORAGE_DIR, which is published")
+	}
+
+	if c.Downloads.LinkTTL <= 0 {
+		errs = append(errs, "DOWNLOAD_LINK_TTL must be positive")
+	}
+	if c.Downloads.Limit < 0 {
+		errs = append(errs, "DOWNLOAD_LIMIT must not be negative")
+	}
+
+	if c.Carts.MaxOpen < 1 {
+		errs = append(errs, "MAX_OPEN_CARTS must be at least 1")
+	}
+	if c.Carts.AbandonedAfter < 0 {
+		errs = append(errs, "ABANDONED_CART_AFTER must not be negative")
+	}
+	if c.Carts.AbandonedAfter > 0 && c.Carts.ReminderInterval <= 0 {
+		errs = append(errs, "ABANDONED_CART_CHECK_INTERVAL must be positive")
+	}
+	if c.Carts.ReminderCooldown < 0 || c.Carts.ReminderCoupon < 0 {
+		errs = append(errs, "ABANDONED_CART_REMINDER_COOLDOWN and ABANDONED_CART_COUPON must not be negative")
+	}
+	if c.Carts.ShareTTL <= 0 {
+		errs = append(errs, "CART_SHARE_TTL must be positive")
+	}
+
+	if c.Checkout.MinTotal < 0 || c.Checkout.MaxItems < 0 {
+		errs = append(errs, "CHECKOUT_MIN_TOTAL and CHECKOUT_MAX_ITEMS must not be negative")
+	}
+	for _, combination := range c.Checkout.RestrictedCombinations {
+		if len(SplitCombination(combination)) < 2 {
+			errs = append(errs, fmt.Sprintf("CHECKOUT_RESTRICTED_COMBINATIONS entry %q must join at least two categories with +", combination))
+		}
+	}
+
+	switch c.Checkout.DuplicatePolicy {
+	case DuplicateOrdersOff, DuplicateOrdersBlock, DuplicateOrdersFlag:
+	default:
+		errs = append(errs, "CHECKOUT_DUPLICATE_POLICY must be off, block or flag")
+	}
+	if c.Checkout.DuplicateWindow < time.Minute {
+		errs = append(errs, "CHECKOUT_DUPLICATE_WINDOW must be at least 1m")
+	}
+	if c.Delivery.Days < 1 {
+		errs = append(errs, "DELIVERY_SLOT_DAYS must be at least 1")
+	}
+	if c.Delivery.LeadTime < 0 {
+		errs = append(errs, "DELIVERY_LEAD_TIME must not be negative")
+	}
+	if _, err := time.LoadLocation(c.Delivery.Timezone); err != nil {
+		errs = append(errs, fmt.Sprintf("DELIVERY_TIMEZONE %q is not a known time zone", c.Delivery.Timezone))
+	}
+
+	if !isCurrencyCode(c.Currency.Base) {
+		errs = append(errs, fmt.Sprintf("CURRENCY_BASE %q is not an ISO 4217 currency code", c.Currency.Base))
+	}
+	for _, code := range c.Currency.Supported {
+		if !isCurrencyCode(code) {
+			errs = append(errs, fmt.Sprintf("CURRENCY_SUPPORTED %q is not an ISO 4217 currency code", code))
+		}
+	}
+	if c.Currency.RefreshInterval < time.Minute {
+		errs = append(errs, "EXCHANGE_RATES_REFRESH must be at least 1m")
+	}
+
+	if c.Accounts.ReactivationWindow <= 0 {
+		errs = append(errs, "ACCOUNT_REACTIVATION_WINDOW must be positive")
+	}
+	if c.Accounts.AnonymizeInterval <= 0 {
+		errs = append(errs, "ACCOUNT_ANONYMIZE_INTERVAL must be positive")
+	}
+	if len(c.Accounts.TermsVersion) > 64 {
+		errs = append(errs, "TERMS_VERSION must be at most 64 characters")
+	}
+	if len(c.Accounts.PrivacyVersion) > 64 {
+		errs = append(errs, "PRIVACY_POLICY_VERSION must be at most 64 characters")
+	}
+	if c.Passwords.MinLength < 1 || c.Passwords.MinLength > 72 {
+		errs = append(errs, "PASSWORD_MIN_LENGTH must be between 1 and 72")
+	}
+	if c.Passwords.MinClasses < 0 || c.Passwords.MinClasses > 4 {
+		errs = append(errs, "PASSWORD_MIN_CLASSES must be between 0 and 4")
+	}
+	if c.Passwords.MinScore < 0 || c.Passwords.MinScore > 4 {
+		errs = append(errs, "PASSWORD_MIN_SCORE must be between 0 and 4")
+	}
+	switch c.Captcha.Provider {
+	case "", "hcaptcha", "turnstile":
+	default:
+		errs = append(errs, "CAPTCHA_PROVIDER must be hcaptcha or turnstile")
+	}
+	if c.Captcha.Provider != "" && c.Captcha.SecretKey == "" {
+		errs = append(errs, "CAPTCHA_SECRET_KEY is required when CAPTCHA_PROVIDER is set")
+	}
+	if c.Captcha.RateLimit < 0 {
+		errs = append(errs, "CAPTCHA_RATE_LIMIT must not be negative")
+	}
+	if c.Captcha.RateWindow <= 0 {
+		errs = append(errs, "CAPTCHA_RATE_WINDOW must be positive")
+	}
+
+	if c.Inventory.LowStockCheckInterval <= 0 {
+		errs = append(errs, "LOW_STOCK_CHECK_INTERVAL must be positive")
+	}
+	if c.Inventory.BackInStockCheckInterval <= 0 {
+		errs = append(errs, "BACK_IN_STOCK_CHECK_INTERVAL must be positive")
+	}
+	if c.Sales.ScheduleInterval <= 0 {
+		errs = append(errs, "SALE_SCHEDULE_INTERVAL must be positive")
+	}
+
+	if c.Tracking.PollInterval <= 0 {
+		errs = append(errs, "TRACKING_POLL_INTERVAL must be positive")
+	}
+	if c.Tracking.APIURL != "" && len(c.Tracking.Carriers) == 0 {
+		errs = append(errs, "TRACKING_CARRIERS must not be empty when TRACKING_API_URL is set")
+	}
+
+	if c.Audit.RetentionDays < 1 {
+		errs = append(errs, "AUDIT_RETENTION_DAYS must be at least 1")
+	}
+	if c.Retention.Interval <= 0 {
+		errs = append(errs, "RETENTION_INTERVAL must be positive")
+	}
+	if c.Retention.CartDays < 1 {
+		errs = append(errs, "RETENTION_CART_DAYS must be at least 1")
+	}
+	if c.Retention.OutboxDays < 1 {
+		errs = append(errs, "RETENTION_OUTBOX_DAYS must be at least 1")
+	}
+	if c.Retention.DeviceTokenDays < 1 {
+		errs = append(errs, "RETENTION_DEVICE_TOKEN_DAYS must be at least 1")
+	}
+
+	if c.API.MaxBodySize < 0 {
+		errs = append(errs, "API_MAX_BODY_SIZE must not be negative")
+	}
+	if c.API.KeyDailyQuota < 0 || c.API.KeyMonthlyQuota < 0 {
+		errs = append(errs, "API_KEY_DAILY_QUOTA and API_KEY_MONTHLY_QUOTA must not be negative")
+	}
+	if c.API.LegacySunset != "" {
+		if _, err := time.Parse("2006-01-02", c.API.LegacySunset); err != nil {
+			errs = append(errs, "API_LEGACY_SUNSET must be a date in YYYY-MM-DD format")
+		}
+	}
+	if c.Maintenance.RetryAfter <= 0 {
+		errs = append(errs, "MAINTENANCE_RETRY_AFTER must be positive")
+	}
+	if c.Resilience.Retries < 0 {
+		errs = append(errs, "RESILIENCE_RETRIES must not be negative")
+	}
+	if c.Resilience.BreakerFailures < 1 {
+		errs = append(errs, "RESILIENCE_BREAKER_FAILURES must be at least 1")
+	}
+	if c.Resilience.BreakerCooldown <= 0 {
+		errs = append(errs, "RESILIENCE_BREAKER_COOLDOWN must be positive")
+	}
+	if c.Outbox.RelayInterval <= 0 {
+		errs = append(errs, "OUTBOX_RELAY_INTERVAL must be positive")
+	}
+	if c.Outbox.MaxAttempts < 1 {
+		errs = append(errs, "OUTBOX_MAX_ATTEMPTS must be at least 1")
+	}
+	kids := map[string]bool{}
+	for _, key := range c.JWT.Keys {
+		kid, _, err := ParseJWTKey(key)
+		if err != nil {
+			errs = append(errs, "JWT_KEYS: "+err.Error())
+		} else if kids[kid] {
+			errs = append(errs, fmt.Sprintf("JWT_KEYS: key %q is listed twice", kid))
+		}
+		kids[kid] = true
+	}
+	keyIDs := map[string]bool{}
+	for _, key := range c.Encryption.Keys {
+		id, _, err := encryption.ParseKey(key)
+		if err != nil {
+			errs = append(errs, "PII_ENCRYPTION_KEYS: "+err.Error())
+		} else if keyIDs[id] {
+			errs = append(errs, fmt.Sprintf("PII_ENCRYPTION_KEYS: key %q is listed twice", id))
+		}
+		keyIDs[id] = true
+	}
+
+	if len(errs) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// applyEnv overrides cfg with any environment variables that are set
+func applyEnv(cfg *Config) error {
+	var errs []string
+
+	setString := func(key string, dst *string) {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = v
+		}
+	}
+	setInt := func(key string, dst *int) {
+		if v, ok := os.LookupEnv(key); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s must be an integer", key))
+				return
+			}
+			*dst = n
+		}
+	}
+	setFloat := func(key string, dst *float64) {
+		if v, ok := os.LookupEnv(key); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s must be a number", key))
+				return
+			}
+			*dst = f
+		}
+	}
+	setBool := func(key string, dst *bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s must be true or false", key))
+				return
+			}
+			*dst = b
+		}
+	}
+	setDuration := func(key string, dst *time.Duration) {
+		if v, ok := os.LookupEnv(key); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s must be a duration such as 24h", key))
+				return
+			}
+			*dst = d
+		}
+	}
+	setList := func(key string, dst *[]string) {
+		if v, ok := os.LookupEnv(key); ok {
+			*dst = splitList(v)
+		}
+	}
+
+	setString("PORT", &cfg.Port)
+	setDuration("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout)
+	setString("LOG_LEVEL", &cfg.Log.Level)
+	setString("LOG_FORMAT", &cfg.Log.Format)
+	setBool("TRACING_ENABLED", &cfg.Tracing.Enabled)
+	setString("OTEL_EXPORTER_OTLP_ENDPOINT", &cfg.Tracing.Endpoint)
+	setBool("OTEL_EXPORTER_OTLP_INSECURE", &cfg.Tracing.Insecure)
+	setString("OTEL_SERVICE_NAME", &cfg.Tracing.ServiceName)
+	setFloat("TRACING_SAMPLE_RATIO", &cfg.Tracing.SampleRatio)
+	setString("DB_DRIVER", &cfg.DB.Driver)
+	setString("DB_DSN", &cfg.DB.DSN)
+	setBool("DB_AUTO_MIGRATE", &cfg.DB.AutoMigrate)
+	setInt("DB_MAX_OPEN_CONNS", &cfg.DB.MaxOpenConns)
+	setInt("DB_MAX_IDLE_CONNS", &cfg.DB.MaxIdleConns)
+	setDuration("DB_CONN_MAX_LIFETIME", &cfg.DB.ConnMaxLifetime)
+	setDuration("DB_CONN_MAX_IDLE_TIME", &cfg.DB.ConnMaxIdleTime)
+	setDuration("DB_QUERY_TIMEOUT", &cfg.DB.QueryTimeout)
+	setList("DB_REPLICA_DSNS", &cfg.DB.Replicas)
+	setDuration("DB_REPLICA_CHECK_INTERVAL", &cfg.DB.ReplicaCheckInterval)
+	setString("JWT_SECRET_KEY", &cfg.JWT.Secret)
+	setList("JWT_KEYS", &cfg.JWT.Keys)
+	setDuration("JWT_EXPIRATION", &cfg.JWT.Expiration)
+	setDuration("JWT_IMPERSONATION_TTL", &cfg.JWT.ImpersonationTTL)
+	setInt("BCRYPT_COST", &cfg.BcryptCost)
+	setList("CORS_ALLOWED_ORIGINS", &cfg.CORS.AllowedOrigins)
+	setList("CORS_ALLOWED_METHODS", &cfg.CORS.AllowedMethods)
+	setList("CORS_ALLOWED_HEADERS", &cfg.CORS.AllowedHeaders)
+	setList("CORS_EXPOSED_HEADERS", &cfg.CORS.ExposedHeaders)
+	setBool("CORS_ALLOW_CREDENTIALS", &cfg.CORS.AllowCredentials)
+	setDuration("CORS_MAX_AGE", &cfg.CORS.MaxAge)
+	setString("SMTP_HOST", &cfg.SMTP.Host)
+	setInt("SMTP_PORT", &cfg.SMTP.Port)
+	setString("SMTP_USERNAME", &cfg.SMTP.Username)
+	setString("SMTP_PASSWORD", &cfg.SMTP.Password)
+	setString("SMTP_FROM", &cfg.SMTP.From)
+	setString("SMS_API_URL", &cfg.SMS.APIURL)
+	setString("SMS_ACCOUNT_SID", &cfg.SMS.AccountSID)
+	setString("SMS_AUTH_TOKEN", &cfg.SMS.AuthToken)
+	setString("SMS_FROM", &cfg.SMS.From)
+	setString("FCM_PROJECT_ID", &cfg.Push.FCMProjectID)
+	setString("FCM_CREDENTIALS_FILE", &cfg.Push.FCMCredentialsFile)
+	setString("FCM_API_URL", &cfg.Push.FCMAPIURL)
+	setString("APNS_KEY_FILE", &cfg.Push.APNsKeyFile)
+	setString("APNS_KEY_ID", &cfg.Push.APNsKeyID)
+	setString("APNS_TEAM_ID", &cfg.Push.APNsTeamID)
+	setString("APNS_TOPIC", &cfg.Push.APNsTopic)
+	setString("APNS_API_URL", &cfg.Push.APNsAPIURL)
+	setList("NOTIFY_ADMIN_EMAILS", &cfg.Notifications.AdminEmails)
+	setString("RECEIPT_TEMPLATE", &cfg.Receipts.Template)
+	setString("RECEIPT_BRAND_NAME", &cfg.Receipts.BrandName)
+	setString("RECEIPT_LOGO_URL", &cfg.Receipts.LogoURL)
+	setString("EMAIL_TEMPLATES_DIR", &cfg.Emails.TemplatesDir)
+	setString("EMAIL_BRAND_NAME", &cfg.Emails.BrandName)
+	setString("TENANT_BASE_DOMAIN", &cfg.Tenancy.BaseDomain)
+	setString("REDIS_URL", &cfg.Cache.RedisURL)
+	setDuration("CACHE_TTL", &cfg.Cache.TTL)
+	setString("SEARCH_URL", &cfg.Search.URL)
+	setString("SEARCH_INDEX", &cfg.Search.Index)
+	setString("SEARCH_USERNAME", &cfg.Search.Username)
+	setString("SEARCH_PASSWORD", &cfg.Search.Password)
+	setString("STORAGE_DIR", &cfg.Storage.Dir)
+	setString("STORAGE_BASE_URL", &cfg.Storage.BaseURL)
+	setString("STORAGE_PRIVATE_DIR", &cfg.Storage.PrivateDir)
+	setString("DOWNLOAD_SECRET", &cfg.Downloads.Secret)
+	setDuration("DOWNLOAD_LINK_TTL", &cfg.Downloads.LinkTTL)
+	setInt("DOWNLOAD_LIMIT", &cfg.Downloads.Limit)
+	setString("ADDRESS_VALIDATION_URL", &cfg.Addresses.ValidationURL)
+	setString("ADDRESS_VALIDATION_API_KEY", &cfg.Addresses.APIKey)
+	setString("CURRENCY_BASE", &cfg.Currency.Base)
+	setList("CURRENCY_SUPPORTED", &cfg.Currency.Supported)
+	setString("EXCHANGE_RATES_URL", &cfg.Currency.RatesURL)
+	setString("EXCHANGE_RATES_API_KEY", &cfg.Currency.APIKey)
+	setDuration("EXCHANGE_RATES_REFRESH", &cfg.Currency.RefreshInterval)
+	setInt("MAX_OPEN_CARTS", &cfg.Carts.MaxOpen)
+	setDuration("ABANDONED_CART_AFTER", &cfg.Carts.AbandonedAfter)
+	setDuration("ABANDONED_CART_CHECK_INTERVAL", &cfg.Carts.ReminderInterval)
+	setDuration("ABANDONED_CART_REMINDER_COOLDOWN", &cfg.Carts.ReminderCooldown)
+	setFloat("ABANDONED_CART_COUPON", &cfg.Carts.ReminderCoupon)
+	setDuration("CART_SHARE_TTL", &cfg.Carts.ShareTTL)
+	setFloat("CHECKOUT_MIN_TOTAL", &cfg.Checkout.MinTotal)
+	setInt("CHECKOUT_MAX_ITEMS", &cfg.Checkout.MaxItems)
+	setList("CHECKOUT_RESTRICTED_COMBINATIONS", &cfg.Checkout.RestrictedCombinations)
+	setString("CHECKOUT_DUPLICATE_POLICY", &cfg.Checkout.DuplicatePolicy)
+	setDuration("CHECKOUT_DUPLICATE_WINDOW", &cfg.Checkout.DuplicateWindow)
+	setInt("DELIVERY_SLOT_DAYS", &cfg.Delivery.Days)
+	setDuration("DELIVERY_LEAD_TIME", &cfg.Delivery.LeadTime)
+	setString("DELIVERY_TIMEZONE", &cfg.Delivery.Timezone)
+	setDuration("ACCOUNT_REACTIVATION_WINDOW", &cfg.Accounts.ReactivationWindow)
+	setDuration("ACCOUNT_ANONYMIZE_INTERVAL", &cfg.Accounts.AnonymizeInterval)
+	setString("TERMS_VERSION", &cfg.Accounts.TermsVersion)
+	setString("PRIVACY_POLICY_VERSION", &cfg.Accounts.PrivacyVersion)
+	setBool("TERMS_REACCEPTANCE", &cfg.Accounts.TermsReacceptance)
+	setInt("PASSWORD_MIN_LENGTH", &cfg.Passwords.MinLength)
+	setInt("PASSWORD_MIN_CLASSES", &cfg.Passwords.MinClasses)
+	setInt("PASSWORD_MIN_SCORE", &cfg.Passwords.MinScore)
+	setBool("PASSWORD_BREACH_CHECK", &cfg.Passwords.BreachCheck)
+	setString("PASSWORD_BREACH_API_URL", &cfg.Passwords.BreachAPIURL)
+	setString("CAPTCHA_PROVIDER", &cfg.Captcha.Provider)
+	setString("CAPTCHA_SECRET_KEY", &cfg.Captcha.SecretKey)
+	setString("CAPTCHA_VERIFY_URL", &cfg.Captcha.VerifyURL)
+	setBool("CAPTCHA_ALWAYS", &cfg.Captcha.Always)
+	setInt("CAPTCHA_RATE_LIMIT", &cfg.Captcha.RateLimit)
+	setDuration("CAPTCHA_RATE_WINDOW", &cfg.Captcha.RateWindow)
+	setDuration("LOW_STOCK_CHECK_INTERVAL", &cfg.Inventory.LowStockCheckInterval)
+	setDuration("BACK_IN_STOCK_CHECK_INTERVAL", &cfg.Inventory.BackInStockCheckInterval)
+	setDuration("SALE_SCHEDULE_INTERVAL", &cfg.Sales.ScheduleInterval)
+	setDuration("TRACKING_POLL_INTERVAL", &cfg.Tracking.PollInterval)
+	setString("TRACKING_API_URL", &cfg.Tracking.APIURL)
+	setString("TRACKING_API_KEY", &cfg.Tracking.APIKey)
+	setString("TRACKING_WEBHOOK_SECRET", &cfg.Tracking.WebhookSecret)
+	setList("TRACKING_CARRIERS", &cfg.Tracking.Carriers)
+	setList("AUDIT_ROUTES", &cfg.Audit.Routes)
+	setInt("AUDIT_RETENTION_DAYS", &cfg.Audit.RetentionDays)
+	setDuration("RETENTION_INTERVAL", &cfg.Retention.Interval)
+	setInt("RETENTION_CART_DAYS", &cfg.Retention.CartDays)
+	setInt("RETENTION_OUTBOX_DAYS", &cfg.Retention.OutboxDays)
+	setInt("RETENTION_DEVICE_TOKEN_DAYS", &cfg.Retention.DeviceTokenDays)
+	setString("API_LEGACY_SUNSET", &cfg.API.LegacySunset)
+	setInt("API_MAX_BODY_SIZE", &cfg.API.MaxBodySize)
+	setInt("API_KEY_DAILY_QUOTA", &cfg.API.KeyDailyQuota)
+	setInt("API_KEY_MONTHLY_QUOTA", &cfg.API.KeyMonthlyQuota)
+	setBool("MAINTENANCE_MODE", &cfg.Maintenance.Enabled)
+	setDuration("MAINTENANCE_RETRY_AFTER", &cfg.Maintenance.RetryAfter)
+	setInt("RESILIENCE_RETRIES", &cfg.Resilience.Retries)
+	setInt("RESILIENCE_BREAKER_FAILURES", &cfg.Resilience.BreakerFailures)
+	setDuration("RESILIENCE_BREAKER_COOLDOWN", &cfg.Resilience.BreakerCooldown)
+	setDuration("OUTBOX_RELAY_INTERVAL", &cfg.Outbox.RelayInterval)
+	setInt("OUTBOX_MAX_ATTEMPTS", &cfg.Outbox.MaxAttempts)
+	setList("PII_ENCRYPTION_KEYS", &cfg.Encryption.Keys)
+	setString("GRPC_PORT", &cfg.GRPC.Port)
+
+	if len(errs) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ParseJWTKey splits a configured JWT key of the form "kid:secret" into its
+// key ID and secret
+func ParseJWTKey(s string) (string, string, error) {
+	kid, secret, ok := strings.Cut(s, ":")
+	if !ok || kid == "" {
+		return "", "", errors.New("key must have the form kid:secret")
+	}
+	if len(secret) < minJWTSecretLength {
+		return "", "", fmt.Errorf("the secret of key %q must be at least %d characters", kid, minJWTSecretLength)
+	}
+	return kid, secret, nil
+}
+
+// SplitCombination returns the categories of a restricted combination
+// such as "alcohol+toys", dropping empty ones
+func SplitCombination(combination string) []string {
+	var categories []string
+	for _, category := range strings.Split(combination, "+") {
+		if category = strings.TrimSpace(category); category != "" {
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
+
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// isCurrencyCode reports whether code has the form of an ISO 4217 code,
+// three letters in either case
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package currency
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/resilience"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const requestTimeout = 10 * time.Second
+
+// client propagates the trace context of the caller to the provider, retrying
+// failed requests and failing fast while it is down
+var client = &http.Client{
+	Transport: resilience.Transport("exchange-rates", requestTimeout, otelhttp.NewTransport(http.DefaultTransport)),
+}
+
+// apiProvider fetches the daily rates of an HTTP exchange-rate provider,
+// which answers GET {url}/latest?base=USD with
+// {"base":"USD","date":"2024-05-01","rates":{"EUR":0.92,...}}
+type apiProvider struct {
+	cfg config.CurrencyConfig
+}
+
+type ratesResponse struct {
+	Base  string             `json:"base"`
+	Date  string             `json:"date"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+func (p apiProvider) Rates(ctx context.Context, base string) (map[string]float64, error) {
+	endpoint := strings.TrimRight(p.cfg.RatesURL, "/") + "/latest?base=" + url.QueryEscape(base)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating exchange rates request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching exchange rates: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("exchange rate provider responded with status %d", resp.StatusCode)
+	}
+
+	var payload ratesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("error decoding exchange rates: %v", err)
+	}
+	if payload.Base != "" && !strings.EqualFold(payload.Base, base) {
+		return nil, fmt.Errorf("exchange rate provider answered for base %s, not %s", payload.Base, base)
+	}
+	return payload.Rates, nil
+}
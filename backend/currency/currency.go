@@ -0,0 +1,138 @@
+// Package currency converts amounts from the base currency prices are
+// kept in to the other currencies of multi-currency storefronts, at
+// exchange rates from a pluggable provider. Rates are fetched once per
+// refresh interval, daily by default, and kept in memory. Without a
+// provider configured only the base currency is available.
+package currency
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/logging"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// retryAfter is how long stale rates are used after a failed fetch
+// before the provider is asked again
+const retryAfter = 5 * time.Minute
+
+// ErrUnsupported is returned for currencies the store does not offer or
+// the provider has no rate for
+var ErrUnsupported = errors.New("unsupported currency")
+
+// Provider fetches exchange rates. Implementations must be safe for
+// concurrent use.
+type Provider interface {
+	// Rates returns how much of each currency, by ISO 4217 code, one unit
+	// of base buys
+	Rates(ctx context.Context, base string) (map[string]float64, error)
+}
+
+// Converter gives the rates of the base currency to the supported ones,
+// fetching them from its provider when they are older than the refresh
+// interval
+type Converter struct {
+	base string
+	// supported is nil if every currency the provider has a rate for is
+	supported map[string]bool
+	provider  Provider
+	refresh   time.Duration
+
+	mu        sync.Mutex
+	rates     map[string]float64
+	fetchedAt time.Time
+}
+
+// NewConverter returns a converter for the configured currencies; a nil
+// provider only converts the base currency to itself
+func NewConverter(cfg config.CurrencyConfig, provider Provider) *Converter {
+	c := &Converter{base: strings.ToUpper(cfg.Base), provider: provider, refresh: cfg.RefreshInterval}
+	if len(cfg.Supported) > 0 {
+		c.supported = map[string]bool{}
+		for _, code := range cfg.Supported {
+			c.supported[strings.ToUpper(code)] = true
+		}
+	}
+	return c
+}
+
+// Base returns the code of the currency prices are kept in
+func (c *Converter) Base() string {
+	return c.base
+}
+
+// Rate returns how much of the currency one unit of the base currency
+// buys. If the rates cannot be fetched, those fetched before are used.
+func (c *Converter) Rate(ctx context.Context, code string) (float64, error) {
+	code = strings.ToUpper(code)
+	if code == c.base {
+		return 1, nil
+	}
+	if c.provider == nil || (c.supported != nil && !c.supported[code]) {
+		return 0, ErrUnsupported
+	}
+	rates, err := c.cachedRates(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rate, ok := rates[code]
+	if !ok || rate <= 0 {
+		return 0, ErrUnsupported
+	}
+	return rate, nil
+}
+
+func (c *Converter) cachedRates(ctx context.Context) (map[string]float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rates != nil && time.Since(c.fetchedAt) < c.refresh {
+		return c.rates, nil
+	}
+	rates, err := c.provider.Rates(ctx, c.base)
+	if err != nil {
+		if c.rates == nil {
+			return nil, err
+		}
+		logging.FromContext(ctx).Warn("failed to fetch exchange rates", "error", err)
+		c.fetchedAt = time.Now().Add(retryAfter - c.refresh)
+		return c.rates, nil
+	}
+	upper := make(map[string]float64, len(rates))
+	for code, rate := range rates {
+		upper[strings.ToUpper(code)] = rate
+	}
+	c.rates, c.fetchedAt = upper, time.Now()
+	return c.rates, nil
+}
+
+// Convert returns amount at rate, rounded to cents
+func Convert(amount, rate float64) float64 {
+	return math.Round(amount*rate*100) / 100
+}
+
+var current = NewConverter(config.Default().Currency, nil)
+
+// Init selects the converter: one fetching rates from the provider at the
+// configured URL, or one for the base currency alone
+func Init(cfg config.CurrencyConfig) {
+	var provider Provider
+	if cfg.RatesURL != "" {
+		provider = apiProvider{cfg: cfg}
+	}
+	current = NewConverter(cfg, provider)
+}
+
+// Get returns the active converter
+func Get() *Converter {
+	return current
+}
+
+// Set replaces the converter; mainly useful for tests
+func Set(converter *Converter) {
+	current = converter
+}
@@ -1,37 +1,151 @@
 package database
 
 import (
-	"ecommerce-backend/models"
+	"context"
+	"database/sql"
+	"ecommerce-backend/config"
+	"ecommerce-backend/migrations"
+	"ecommerce-backend/tenant"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
+const defaultSQLiteDSN = "ecommerce.db"
+
 var DB *gorm.DB
 
+// dialector returns the GORM dialector for the given driver and DSN.
+// Supported drivers are sqlite (default), postgres and mysql.
+func dialector(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case "", "sqlite":
+		if dsn == "" {
+			dsn = defaultSQLiteDSN
+		}
+		return sqlite.Open(sqliteDSN(dsn)), nil
+	case "postgres":
+		if dsn == "" {
+			return nil, fmt.Errorf("DB_DSN is required for the postgres driver")
+		}
+		return postgres.Open(dsn), nil
+	case "mysql":
+		if dsn == "" {
+			return nil, fmt.Errorf("DB_DSN is required for the mysql driver")
+		}
+		return mysql.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+// sqliteDSN has transactions take SQLite's write lock as they begin, unless
+// the DSN chooses otherwise. SQLite has no row locks, and a transaction
+// that reads before writing fails with "database is locked" if another
+// one wrote in between; taking the lock up front makes concurrent
+// transactions, such as checkouts, wait their turn instead.
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "_txlock=") {
+		return dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&_txlock=immediate"
+	}
+	return dsn + "?_txlock=immediate"
+}
+
 func InitDB() (*gorm.DB, error) {
-	var err error
-	DB, err = gorm.Open(sqlite.Open("ecommerce.db"), &gorm.Config{})
+	cfg := config.Get().DB
+	d, err := dialector(cfg.Driver, cfg.DSN)
 	if err != nil {
 		return nil, err
 	}
 
-	// Auto migrate the schema
-	err = DB.AutoMigrate(
-		&models.User{},
-		&models.Item{},
-		&models.Cart{},
-		&models.CartItem{},
-		&models.Order{},
-	)
+	DB, err = gorm.Open(d, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	txTimeout = cfg.QueryTimeout
 
+	// Scope queries to the store in their context
+	if err := DB.Use(tenant.Plugin{}); err != nil {
+		return nil, err
+	}
+
+	// Trace queries as child spans of the request span
+	if config.Get().Tracing.Enabled {
+		if err := DB.Use(tracing.NewPlugin(tracing.WithoutMetrics())); err != nil {
+			return nil, err
+		}
+	}
+
+	// Configure the connection pool
+	sqlDB, err := DB.DB()
 	if err != nil {
 		return nil, err
 	}
+	configurePool(sqlDB, cfg)
+
+	// Route the reads of read-only endpoints to replicas. Replicas that
+	// cannot even be opened, as some drivers query them when opening, are
+	// left out until the next restart rather than keeping the server down.
+	if len(cfg.Replicas) > 0 {
+		if err := initReplicas(cfg); err != nil {
+			log.Printf("read replicas unavailable, reading from the primary: %v", err)
+		}
+	}
 
 	return DB, nil
 }
 
+func configurePool(sqlDB *sql.DB, cfg config.DBConfig) {
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// CheckSchema returns an error if any migrations have not been applied,
+// so the server refuses to run against an outdated schema
+func CheckSchema() error {
+	pending, err := migrations.Pending(DB)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is behind by %d migration(s), starting with %04d_%s; run `migrate up`",
+			len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
+
+// Close closes the underlying connection pool
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
 // GetDB returns the database instance
 func GetDB() *gorm.DB {
 	return DB
 }
+
+// WithContext returns the database instance bound to ctx, so queries are
+// cancelled when the request is cancelled or its deadline expires
+func WithContext(ctx context.Context) *gorm.DB {
+	return DB.WithContext(ctx)
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"ecommerce-backend/config"
+	"log"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// replicaResolver names the resolver holding the read replicas. It is only
+// used by queries whose context asks for a replica, so every other query
+// keeps going to the primary.
+const replicaResolver = "replicas"
+
+// replicaPingTimeout bounds each health check of a replica
+const replicaPingTimeout = 2 * time.Second
+
+type replicaKey struct{}
+
+// WithReplica marks ctx so that its queries outside transactions read from
+// a healthy replica, if any. Writes and transactions always use the primary.
+func WithReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaKey{}, true)
+}
+
+// replicaSet tracks which replicas answer their health checks
+type replicaSet struct {
+	pools   []gorm.ConnPool
+	index   map[gorm.ConnPool]int
+	healthy []atomic.Bool
+}
+
+var replicas *replicaSet
+
+// Resolve implements dbresolver.Policy, picking a random healthy replica
+func (s *replicaSet) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	var candidates []gorm.ConnPool
+	for _, pool := range pools {
+		if i, ok := s.index[pool]; ok && s.healthy[i].Load() {
+			candidates = append(candidates, pool)
+		}
+	}
+	if len(candidates) == 0 {
+		// routeToReplica checked that one was healthy a moment ago
+		return pools[rand.IntN(len(pools))]
+	}
+	return candidates[rand.IntN(len(candidates))]
+}
+
+func (s *replicaSet) anyHealthy() bool {
+	for i := range s.healthy {
+		if s.healthy[i].Load() {
+			return true
+		}
+	}
+	return false
+}
+
+// initReplicas registers the configured replicas with a resolver. They are
+// opened without the usual ping, so that a replica that is down does not
+// stop the server from starting; it is used once it answers.
+func initReplicas(cfg config.DBConfig) error {
+	dialectors := make([]gorm.Dialector, 0, len(cfg.Replicas))
+	for _, dsn := range cfg.Replicas {
+		d, err := dialector(cfg.Driver, dsn)
+		if err != nil {
+			return err
+		}
+		dialectors = append(dialectors, d)
+	}
+
+	set := &replicaSet{index: map[gorm.ConnPool]int{}}
+	resolver := dbresolver.Register(dbresolver.Config{Replicas: dialectors, Policy: set}, replicaResolver)
+	DB.Config.DisableAutomaticPing = true
+	err := DB.Use(resolver)
+	DB.Config.DisableAutomaticPing = false
+	if err != nil {
+		return err
+	}
+
+	// The resolver's sources are the primary itself
+	primary := DB.ConnPool
+	resolver.Call(func(pool gorm.ConnPool) error {
+		if pool == primary {
+			return nil
+		}
+		if sqlDB, ok := pool.(*sql.DB); ok {
+			configurePool(sqlDB, cfg)
+		}
+		set.index[pool] = len(set.pools)
+		set.pools = append(set.pools, pool)
+		return nil
+	})
+	// Replicas are assumed up until checked, so that the first check logs
+	// those that are down
+	set.healthy = make([]atomic.Bool, len(set.pools))
+	for i := range set.healthy {
+		set.healthy[i].Store(true)
+	}
+	replicas = set
+
+	if err := DB.Callback().Query().Before("gorm:query").Register("app:replica", routeToReplica); err != nil {
+		return err
+	}
+	if err := DB.Callback().Row().Before("gorm:row").Register("app:replica", routeToReplica); err != nil {
+		return err
+	}
+	CheckReplicas(context.Background())
+	return nil
+}
+
+// routeToReplica sends the queries of contexts marked by WithReplica to the
+// replicas while one of them is healthy
+func routeToReplica(db *gorm.DB) {
+	ctx := db.Statement.Context
+	if ctx == nil || ctx.Value(replicaKey{}) == nil || !replicas.anyHealthy() {
+		return
+	}
+	// As db.Clauses(dbresolver.Use(...)) would, had the query asked for it
+	if use, ok := dbresolver.Use(replicaResolver).(gorm.StatementModifier); ok {
+		use.ModifyStatement(db.Statement)
+	}
+}
+
+// HasReplicas reports whether read replicas are configured
+func HasReplicas() bool {
+	return replicas != nil
+}
+
+// CheckReplicas pings every replica and records whether it answered, so
+// reads move off replicas that are down and back once they recover
+func CheckReplicas(ctx context.Context) error {
+	if replicas == nil {
+		return nil
+	}
+	for i, pool := range replicas.pools {
+		pinger, ok := pool.(interface{ PingContext(context.Context) error })
+		if !ok {
+			replicas.healthy[i].Store(true)
+			continue
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+		err := pinger.PingContext(pingCtx)
+		cancel()
+
+		if was := replicas.healthy[i].Swap(err == nil); was != (err == nil) {
+			if err != nil {
+				log.Printf("read replica %d is down: %v", i+1, err)
+			} else {
+				log.Printf("read replica %d is up", i+1)
+			}
+		}
+	}
+	return nil
+}
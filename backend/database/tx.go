@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"ecommerce-backend/logging"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+const (
+	// txAttempts bounds the runs of a transaction the database keeps
+	// aborting to serialize it
+	txAttempts = 4
+	// txBackoff is the wait before the first retry; it doubles for each
+	// further retry and is jittered so colliding transactions spread out
+	txBackoff = 20 * time.Millisecond
+)
+
+// txTimeout bounds transactions whose context has no deadline of its own,
+// such as those of background jobs; set from DB_QUERY_TIMEOUT by InitDB
+var txTimeout time.Duration
+
+// WithTx runs fn in a transaction on the application database; see RunTx
+func WithTx(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return RunTx(ctx, DB, fn)
+}
+
+// RunTx runs fn in a transaction on db, committed if fn returns nil and
+// rolled back if it returns an error or panics; a panic is logged and
+// returned as an error. Transactions aborted by a deadlock, serialization
+// failure or busy database are run again, up to txAttempts times in all,
+// after a growing delay, so fn must not have effects outside the
+// transaction. The transaction is bounded by the context's deadline, or by
+// DB_QUERY_TIMEOUT if it has none, and is not retried past it.
+func RunTx(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if _, ok := ctx.Deadline(); !ok && txTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, txTimeout)
+		defer cancel()
+	}
+
+	backoff := txBackoff
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, fn)
+		if attempt == txAttempts || !retryable(err) {
+			return err
+		}
+
+		wait := backoff/2 + rand.N(backoff)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+func runTx(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) (err error) {
+	tx := db.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			logging.FromContext(ctx).Error("panic in transaction", "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("transaction panicked: %v", r)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// retryable reports whether err aborted a transaction that may succeed if
+// run again: a serialization failure or deadlock on Postgres, a deadlock or
+// lock wait timeout on MySQL, or a busy or locked database on SQLite
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1213 || myErr.Number == 1205
+	}
+	var liteErr sqlite3.Error
+	if errors.As(err, &liteErr) {
+		return liteErr.Code == sqlite3.ErrBusy || liteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
@@ -0,0 +1,869 @@
+package main
+
+import (
+	"ecommerce-backend/apidocs"
+	"ecommerce-backend/config"
+	"ecommerce-backend/handlers"
+	"ecommerce-backend/version"
+	"net/http"
+	"strings"
+)
+
+// documentRoutes annotates the routes registered in setupRouter for the
+// generated OpenAPI document. Routes without an entry here still appear in
+// the document, flagged as undocumented.
+func documentRoutes() {
+	bearer := apidocs.AuthBearer
+	pageParams := []apidocs.Param{
+		{Name: "limit", Type: "integer", Description: "Page size (default 20, max 100)"},
+		{Name: "cursor", Description: "next_cursor from the previous page, or a cursor from the Link header"},
+	}
+	exportParams := []apidocs.Param{
+		{Name: "format", Description: "csv (default) or jsonl, one JSON object per line"},
+		{Name: "limit", Type: "integer", Description: "Number of rows to export (default all)"},
+		{Name: "cursor", Description: "Next-Cursor trailer of the previous export"},
+	}
+	exportNote := " Rows are streamed as they are read; without a limit every row after the cursor is exported, " +
+		"and the next cursor is sent as the Next-Cursor trailer."
+	ifMatchNote := " Send the item's ETag, returned to admins and vendors reading it and with earlier updates, as If-Match: " +
+		"the update fails with 412 PRECONDITION_FAILED if the item changed since, and with 428 PRECONDITION_REQUIRED without it."
+	streamParams := []apidocs.Param{
+		{Name: "limit", Type: "integer", Description: "Page size (default 20, max 10000); the response is streamed"},
+		{Name: "cursor", Description: "next_cursor from the previous page, or a cursor from the Link header"},
+	}
+
+	// v1 routes are also served, deprecated, under the unversioned /api prefix
+	v1 := func(method, path string, op apidocs.Operation) {
+		apidocs.Document(method, "/api/v1"+path, op)
+		op.Deprecated = true
+		apidocs.Document(method, "/api"+path, op)
+	}
+
+	// Users
+	v1("POST", "/users", apidocs.Operation{
+		Summary: "Register a new user", Tags: []string{"users"},
+		Description: "Fails with CAPTCHA_REQUIRED when a CAPTCHA must be solved first, then send the token it gave as captcha_token. " +
+			"Passwords breaking the password policy fail with WEAK_PASSWORD, whose details list the violated rules. " +
+			"While TERMS_VERSION or PRIVACY_POLICY_VERSION is set, accept_terms must be true, or registering fails with " +
+			"TERMS_NOT_ACCEPTED, whose details give the versions to accept by document.",
+		Request: handlers.CreateUserRequest{}, Response: handlers.TokenResponse{}, Status: http.StatusCreated,
+	})
+	v1("POST", "/users/login", apidocs.Operation{
+		Summary: "Log in and obtain a JWT", Tags: []string{"users"},
+		Description: "Fails with CAPTCHA_REQUIRED when a CAPTCHA must be solved first, then send the token it gave as captcha_token. " +
+			"With TERMS_REACCEPTANCE, fails with TERMS_NOT_ACCEPTED once a document's version was bumped since the user " +
+			"accepted it, whose details give the versions to accept by document; log in again with accept_terms to accept them.",
+		Request: handlers.LoginRequest{}, Response: handlers.TokenResponse{},
+	})
+	v1("POST", "/users/logout", apidocs.Operation{
+		Summary: "Revoke the current token", Tags: []string{"users"}, Auth: bearer,
+		Response: handlers.MessageResponse{},
+	})
+	v1("GET", "/users/me", apidocs.Operation{
+		Summary: "Get the current user's profile", Tags: []string{"users"}, Auth: bearer,
+		Description: "impersonated_by names the admin when the token is an impersonation token.",
+		Response:    handlers.UserResponse{},
+	})
+	v1("PUT", "/users/me/email", apidocs.Operation{
+		Summary: "Set the current user's email", Tags: []string{"users"}, Auth: bearer,
+		Description: "The email receives abandoned cart reminders; an empty email removes it.",
+		Request:     handlers.UpdateEmailRequest{}, Response: handlers.UserResponse{},
+	})
+	v1("PUT", "/users/me/phone", apidocs.Operation{
+		Summary: "Set the current user's phone", Tags: []string{"users"}, Auth: bearer,
+		Description: "The phone, in E.164 format, is texted a six-digit code to verify it with, valid for 10 minutes; " +
+			"another code can be requested after a minute. An empty phone removes it. Changing the phone opts the user " +
+			"out of SMS alerts. Fails with SMS_UNAVAILABLE when the code cannot be sent.",
+		Request: handlers.UpdatePhoneRequest{}, Response: handlers.UserResponse{},
+	})
+	v1("POST", "/users/me/phone/verify", apidocs.Operation{
+		Summary: "Verify the current user's phone", Tags: []string{"users"}, Auth: bearer,
+		Description: "Takes the code texted to the phone. Five wrong codes void it.",
+		Request:     handlers.VerifyPhoneRequest{}, Response: handlers.UserResponse{},
+	})
+	v1("PUT", "/users/me/notifications", apidocs.Operation{
+		Summary: "Set the current user's notification preferences", Tags: []string{"users"}, Auth: bearer,
+		Description: "sms_opt_in sends SMS alerts when the user's orders ship and are delivered; " +
+			"opting in fails with PHONE_NOT_VERIFIED until the phone is verified.",
+		Request: handlers.UpdateNotificationsRequest{}, Response: handlers.UserResponse{},
+	})
+	v1("POST", "/users/me/avatar", apidocs.Operation{
+		Summary: "Upload the current user's avatar", Tags: []string{"users"}, Auth: bearer,
+		Description: "Takes a JPEG, PNG or GIF image of up to 5 MB as the avatar form field. " +
+			"It is cropped to a centered square and stored as a 256x256 JPEG whose URL is returned as avatar_url.",
+		Upload: "avatar", Response: handlers.UserResponse{},
+	})
+	v1("GET", "/users/me/addresses", apidocs.Operation{
+		Summary: "List the current user's saved addresses", Tags: []string{"users"}, Auth: bearer,
+		Response: handlers.AddressesResponse{},
+	})
+	v1("POST", "/users/me/addresses", apidocs.Operation{
+		Summary: "Save a shipping address", Tags: []string{"users"}, Auth: bearer,
+		Description: "The address is validated and normalized by the address provider, when one is configured. " +
+			"Undeliverable addresses are saved with status undeliverable and the provider's status_reason; " +
+			"addresses the provider could not check are unverified.",
+		Request: handlers.CreateAddressRequest{}, Response: handlers.AddressResponse{}, Status: http.StatusCreated,
+	})
+	v1("DELETE", "/users/me/addresses/:id", apidocs.Operation{
+		Summary: "Delete a saved address", Tags: []string{"users"}, Auth: bearer,
+		Status: http.StatusNoContent,
+	})
+	v1("GET", "/users/me/devices", apidocs.Operation{
+		Summary: "List the current user's devices registered for push notifications", Tags: []string{"users"}, Auth: bearer,
+		Response: handlers.DevicesResponse{},
+	})
+	v1("POST", "/users/me/devices", apidocs.Operation{
+		Summary: "Register a device for push notifications", Tags: []string{"users"}, Auth: bearer,
+		Description: "Takes the FCM registration token of an android device or the APNs device token of an ios one. " +
+			"Apps should register on every start: tokens not registered for RETENTION_DEVICE_TOKEN_DAYS are purged, " +
+			"as are those the push provider refuses. A token registered by another user moves to the current one.",
+		Request: handlers.RegisterDeviceRequest{}, Response: handlers.DeviceResponse{},
+	})
+	v1("DELETE", "/users/me/devices/:id", apidocs.Operation{
+		Summary: "Unregister a device, as when logging out of the app", Tags: []string{"users"}, Auth: bearer,
+		Status: http.StatusNoContent,
+	})
+	v1("POST", "/users/me/terms", apidocs.Operation{
+		Summary: "Accept the current terms of service and privacy policy", Tags: []string{"users"}, Auth: bearer,
+		Description: "Records the acceptance of the versions the user has not accepted yet. Impersonation tokens cannot accept.",
+		Response:    handlers.MessageResponse{},
+	})
+	v1("GET", "/users/me/stock-subscriptions", apidocs.Operation{
+		Summary: "List the items the current user asked to be told are back in stock", Tags: []string{"users"}, Auth: bearer,
+		Response: handlers.StockSubscriptionsResponse{},
+	})
+	v1("POST", "/users/me/deactivate", apidocs.Operation{
+		Summary: "Deactivate the current user's account", Tags: []string{"users"}, Auth: bearer,
+		Description: "Logs the user out everywhere. The account can be reactivated until reactivate_before, after which it is anonymized.",
+		Request:     handlers.DeactivateRequest{}, Response: handlers.DeactivationResponse{},
+	})
+	v1("POST", "/users/reactivate", apidocs.Operation{
+		Summary: "Reactivate a deactivated account and log in", Tags: []string{"users"},
+		Description: "Fails with CAPTCHA_REQUIRED when a CAPTCHA must be solved first, then send the token it gave as captcha_token. " +
+			"Like logging in, may fail with TERMS_NOT_ACCEPTED, once the account is reactivated.",
+		Request: handlers.LoginRequest{}, Response: handlers.TokenResponse{},
+	})
+	v1("GET", "/users", apidocs.Operation{
+		Summary: "List active users", Tags: []string{"users"}, Auth: bearer, AdminOnly: true,
+		Query: streamParams, Response: handlers.UsersResponse{},
+	})
+	v1("GET", "/admin/users/export", apidocs.Operation{
+		Summary: "Export active users as CSV or JSON Lines", Tags: []string{"users"}, Auth: bearer, AdminOnly: true,
+		Description: "Columns: id, username, email, role, vendor_id, registered_at, order_count and lifetime_value, " +
+			"the total of completed, shipped and delivered orders." + exportNote,
+		Query: exportParams,
+	})
+	v1("GET", "/admin/users/:id", apidocs.Operation{
+		Summary: "Get a user", Tags: []string{"users"}, Auth: bearer, AdminOnly: true,
+		Description: "Includes deactivated accounts, and every version of the terms of service and privacy policy the user " +
+			"accepted, with when and from which IP and user agent.",
+		Response: handlers.AdminUserResponse{},
+	})
+	v1("POST", "/admin/users/:id/impersonate", apidocs.Operation{
+		Summary: "Act as a user", Tags: []string{"users"}, Auth: bearer, AdminOnly: true,
+		Description: "Issues a token acting as the user, valid for JWT_IMPERSONATION_TTL, whose act claim names the admin. " +
+			"Admins and deactivated accounts cannot be impersonated. The request is always audited, and audited requests " +
+			"made with the token record the admin as impersonator_id. The token cannot change the user's email, " +
+			"deactivate the account or issue API keys.",
+		Response: handlers.ImpersonationResponse{},
+	})
+
+	// Items
+	v1("GET", "/items", apidocs.Operation{
+		Summary: "List items", Tags: []string{"items"},
+		Description: "Items hidden from the catalog are only listed for admins, who may send their bearer token.",
+		Query: append([]apidocs.Param{
+			{Name: "min_price", Type: "number", Description: "Only items priced at least this"},
+			{Name: "max_price", Type: "number", Description: "Only items priced at most this"},
+			{Name: "in_stock", Type: "boolean", Description: "true for only items with stock left or untracked stock"},
+			{Name: "category", Description: "Only items in this category"},
+			{Name: "vendor_id", Type: "integer", Description: "Only items sold by this vendor"},
+			{Name: "digital", Type: "boolean", Description: "Only digital items, or only shipped ones if false"},
+			{Name: "currency", Description: "ISO 4217 code of a currency to show prices in, converted at the day's exchange rate; " +
+				"min_price and max_price stay in the base currency"},
+		}, pageParams...),
+		Response: handlers.ItemsResponse{},
+	})
+	v1("GET", "/items/trending", apidocs.Operation{
+		Summary: "List the best-selling items of the last week", Tags: []string{"items"},
+		Description: "Cached, so new orders may take a few minutes to show.",
+		Query:       []apidocs.Param{{Name: "limit", Type: "integer", Description: "Number of items (default 10, max 50)"}},
+		Response:    handlers.ItemsResponse{},
+	})
+	v1("GET", "/items/search", apidocs.Operation{
+		Summary: "Search items", Tags: []string{"items"},
+		Description: "Ranks items by relevance to q, tolerating typos when a search engine is configured. " +
+			"Facets count the items matching q before the category and price filters.",
+		Query: []apidocs.Param{
+			{Name: "q", Type: "string", Description: "Text matched against item names, categories and descriptions"},
+			{Name: "category", Type: "string", Description: "Only items of this category"},
+			{Name: "min_price", Type: "number", Description: "Only items costing at least this much"},
+			{Name: "max_price", Type: "number", Description: "Only items costing at most this much"},
+			{Name: "limit", Type: "integer", Description: "Page size (default 20, max 100)"},
+			{Name: "offset", Type: "integer", Description: "Number of matching items to skip (max 10000)"},
+		},
+		Response: handlers.SearchItemsResponse{},
+	})
+	v1("GET", "/items/:id", apidocs.Operation{
+		Summary: "Get an item", Tags: []string{"items"},
+		Description: "Items hidden from the catalog are ITEM_NOT_FOUND except for admins, who may send their bearer token. " +
+			"Admins and vendors get the item's ETag to send as If-Match with updates.",
+		Response: handlers.ItemResponse{},
+	})
+	v1("POST", "/items/:id/stock-subscription", apidocs.Operation{
+		Summary: "Ask to be told when an out-of-stock item is back in stock", Tags: []string{"items"}, Auth: bearer,
+		Description: "Items that can be bought are ITEM_IN_STOCK. Once the item is restocked the user is emailed and " +
+			"sent a push notification to their devices, and the subscription is deleted. Subscribing again is a no-op.",
+		Response: handlers.StockSubscriptionResponse{},
+	})
+	v1("DELETE", "/items/:id/stock-subscription", apidocs.Operation{
+		Summary: "Cancel a back-in-stock alert", Tags: []string{"items"}, Auth: bearer,
+		Status: http.StatusNoContent,
+	})
+	v1("GET", "/bundles", apidocs.Operation{
+		Summary: "List bundles", Tags: []string{"bundles"},
+		Response: handlers.BundlesResponse{},
+	})
+	v1("GET", "/bundles/:id", apidocs.Operation{
+		Summary: "Get a bundle", Tags: []string{"bundles"},
+		Response: handlers.BundleResponse{},
+	})
+	v1("POST", "/admin/bundles", apidocs.Operation{
+		Summary: "Create a bundle", Tags: []string{"bundles"}, Auth: bearer, AdminOnly: true,
+		Request: handlers.CreateBundleRequest{}, Response: handlers.BundleResponse{}, Status: http.StatusCreated,
+	})
+	v1("DELETE", "/admin/bundles/:id", apidocs.Operation{
+		Summary: "Delete a bundle", Tags: []string{"bundles"}, Auth: bearer, AdminOnly: true,
+		Status: http.StatusNoContent,
+	})
+	v1("POST", "/items", apidocs.Operation{
+		Summary: "Create an item", Tags: []string{"items"}, Auth: bearer,
+		Description: "Admins and vendor accounts. Items created by a vendor account belong to its vendor; admins may set vendor_id, " +
+			"cost_price and internal_notes, which only admins see.",
+		Request: handlers.CreateItemRequest{}, Response: handlers.CreateItemResponse{}, Status: http.StatusCreated,
+	})
+	v1("PUT", "/items/:id/inventory", apidocs.Operation{
+		Summary: "Set an item's stock and low-stock threshold", Tags: []string{"items"}, Auth: bearer,
+		Description: "Admins, or the vendor selling the item. A null stock stops tracking the item's stock. " +
+			"A threshold of 0 disables low-stock alerts." + ifMatchNote,
+		Request: handlers.UpdateInventoryRequest{}, Response: handlers.ItemResponse{}, IfMatch: true,
+	})
+	v1("PUT", "/items/:id/active", apidocs.Operation{
+		Summary: "List an item in the catalog or hide it", Tags: []string{"items"}, Auth: bearer,
+		Description: "Admins, or the vendor selling the item. Hidden items are left out of the catalog and search, " +
+			"and adding them to a cart or checking them out fails with ITEM_INACTIVE." + ifMatchNote,
+		Request: handlers.SetItemActiveRequest{}, Response: handlers.ItemResponse{}, IfMatch: true,
+	})
+	v1("PUT", "/items/:id/price", apidocs.Operation{
+		Summary: "Set an item's price and compare-at price", Tags: []string{"items"}, Auth: bearer,
+		Description: "Admins, or the vendor selling the item. The compare-at price is shown struck through next to the price, " +
+			"so it must be greater than it; omit it to remove it." + ifMatchNote,
+		Request: handlers.SetItemPriceRequest{}, Response: handlers.ItemResponse{}, IfMatch: true,
+	})
+	v1("PUT", "/items/:id/quantity-limits", apidocs.Operation{
+		Summary: "Limit how many units of an item customers may buy", Tags: []string{"items"}, Auth: bearer,
+		Description: "Admins, or the vendor selling the item. Orders must hold at least min_quantity units, and each customer " +
+			"may buy at most max_quantity over all their orders that are not cancelled; 0 removes a limit. Adding to the cart " +
+			"and checkout fail with QUANTITY_BELOW_MINIMUM or QUANTITY_LIMIT_EXCEEDED." + ifMatchNote,
+		Request: handlers.SetItemQuantityLimitsRequest{}, Response: handlers.ItemResponse{}, IfMatch: true,
+	})
+	v1("PUT", "/items/:id/backorder", apidocs.Operation{
+		Summary: "Let an item sell beyond its stock", Tags: []string{"items"}, Auth: bearer,
+		Description: "Admins, or the vendor selling the item. backorder or preorder lets checkout sell units beyond the stock, " +
+			"recorded on the order as backorders; an empty backorder stops it." + ifMatchNote,
+		Request: handlers.SetBackorderRequest{}, Response: handlers.ItemResponse{}, IfMatch: true,
+	})
+	v1("PUT", "/items/:id/file", apidocs.Operation{
+		Summary: "Upload the file of a digital item", Tags: []string{"items"}, Auth: bearer,
+		Description: "Admins, or the vendor selling the item. Takes a file of up to 100 MB as the file form field, " +
+			"replacing any earlier one, and makes the item digital: it is delivered by download and never shipped.",
+		Upload: "file", Response: handlers.ItemResponse{}, IfMatch: true,
+	})
+	v1("DELETE", "/items/:id/file", apidocs.Operation{
+		Summary: "Remove the file of a digital item", Tags: []string{"items"}, Auth: bearer,
+		Description: "Admins, or the vendor selling the item. The item is shipped again and earlier buyers can no longer download it.",
+		Response:    handlers.ItemResponse{}, IfMatch: true,
+	})
+	v1("GET", "/admin/items/low-stock", apidocs.Operation{
+		Summary: "List items at or below their low-stock threshold", Tags: []string{"items"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.ItemsResponse{},
+	})
+	v1("GET", "/admin/inventory/export", apidocs.Operation{
+		Summary: "Export the stock of every item as CSV or JSON Lines", Tags: []string{"items"}, Auth: bearer, AdminOnly: true,
+		Description: "Columns: id, name, category, vendor_id, price, stock (empty when not tracked), low_stock_threshold, " +
+			"backorder, expected_at, is_active and version. Hidden items are included." + exportNote,
+		Query: exportParams,
+	})
+	v1("GET", "/admin/items/:id/movements", apidocs.Operation{
+		Summary: "List the changes to an item's stock, newest first", Tags: []string{"items"}, Auth: bearer, AdminOnly: true,
+		Description: "Every change to the stock of an item is recorded with its delta, reason, the user who made it and " +
+			"the order or warehouse it concerns. Reasons are opening, checkout, backorder_fulfilled, adjustment and transfer.",
+		Query:    append([]apidocs.Param{{Name: "reason", Description: "Only movements of this reason"}}, pageParams...),
+		Response: handlers.InventoryMovementsResponse{},
+	})
+	v1("PUT", "/admin/items/:id/internal", apidocs.Operation{
+		Summary: "Set an item's cost price and internal notes", Tags: []string{"items"}, Auth: bearer, AdminOnly: true,
+		Description: "Only admins see them in item responses. An omitted cost_price removes it." + ifMatchNote,
+		Request:     handlers.SetItemInternalRequest{}, Response: handlers.ItemResponse{}, IfMatch: true,
+	})
+	v1("PATCH", "/admin/items/bulk", apidocs.Operation{
+		Summary: "Set the prices and stock of many items", Tags: []string{"items"}, Auth: bearer, AdminOnly: true,
+		Description: "Each row sets an item's price, or adjusts it by percent, and its stock, all in one transaction. " +
+			"If any row fails, nothing is changed and the error's details list the result of every row.",
+		Request: handlers.BulkUpdateItemsRequest{}, Response: handlers.BulkItemsResponse{},
+	})
+
+	// Carts
+	v1("GET", "/carts/user", apidocs.Operation{
+		Summary: "Get the current user's cart", Tags: []string{"carts"}, Auth: bearer,
+		Description: "total applies the cart's bundles, the promotions running now, the active sales and any price adjusters added to the " +
+			"store, itemized in bundles, promotions, sales and adjustments.",
+		Response: handlers.CartResponse{},
+	})
+	v1("POST", "/carts", apidocs.Operation{
+		Summary: "Add an item or a bundle to the current user's cart", Tags: []string{"carts"}, Auth: bearer,
+		Description: "Bundles are added as a unit priced at the bundle price, or with expand as separate items.",
+		Request:     handlers.AddToCartRequest{}, Response: handlers.AddToCartResponse{},
+	})
+	v1("POST", "/cart/shipping-quote", apidocs.Operation{
+		Summary: "Quote shipping for the current user's cart", Tags: []string{"carts", "shipping"}, Auth: bearer,
+		Description: "Prices the cart with each of the store's shipping methods, or only the one given.",
+		Request:     handlers.ShippingQuoteRequest{}, Response: handlers.ShippingQuoteResponse{},
+	})
+	v1("GET", "/checkout/slots", apidocs.Operation{
+		Summary: "List the delivery slots that can be booked", Tags: []string{"orders", "delivery"}, Auth: bearer,
+		Description: "Each of the store's slots on each date from today over the booking window, by date and start time, " +
+			"with how many more orders it takes. Slots starting within the lead time are left out; full ones have available false.",
+		Response: handlers.CheckoutSlotsResponse{},
+	})
+	v1("POST", "/cart/share", apidocs.Operation{
+		Summary: "Get a link to share the current user's cart", Tags: []string{"carts"}, Auth: bearer,
+		Description: "The signed link lets other users import the cart's items until it expires or the cart changes.",
+		Response:    handlers.CartShareResponse{},
+	})
+	v1("POST", "/cart/shared/:id", apidocs.Operation{
+		Summary: "Import a shared cart into the current user's cart", Tags: []string{"carts"}, Auth: bearer,
+		Description: "Expired links, and links to carts changed since they were shared, fail with CART_SHARE_INVALID.",
+		Query: []apidocs.Param{
+			{Name: "expires", Type: "integer", Description: "expires of the sharing link"},
+			{Name: "signature", Description: "signature of the sharing link"},
+		},
+		Response: handlers.AddToCartResponse{},
+	})
+	v1("GET", "/carts", apidocs.Operation{
+		Summary: "List carts", Tags: []string{"carts"}, Auth: bearer, AdminOnly: true,
+		Query: streamParams, Response: handlers.CartsResponse{},
+	})
+
+	// Orders
+	v1("POST", "/orders", apidocs.Operation{
+		Summary: "Check out the current user's cart", Tags: []string{"orders"}, Auth: bearer,
+		Description: "The body may be omitted while the store has no shipping methods; otherwise a shipping_method_id is required. " +
+			"A gift_card_code pays as much of the order as the card's balance covers; total is the amount left to charge. " +
+			"The order's number identifies it to the customer. An address_id ships the order to one of the user's saved addresses, " +
+			"checked again with the address provider; undeliverable addresses fail with ADDRESS_UNDELIVERABLE. " +
+			"metadata keeps up to 20 custom fields on the order, such as gift_message or po_number. " +
+			"Once the store has delivery slots, orders with items to ship book one with delivery_slot_id and delivery_date from " +
+			"GET /checkout/slots; slots no longer open fail with DELIVERY_SLOT_CLOSED and full ones with DELIVERY_SLOT_FULL. " +
+			"Orders breaking the store's checkout rules fail with CHECKOUT_RULES_VIOLATED, listing every violation in details. " +
+			"In stores blocking likely duplicates, an order with the same items as one the user placed within the store's window " +
+			"fails with DUPLICATE_ORDER, giving that order's order_number and placed_at, until sent again with confirm_duplicate. " +
+			"A currency settles the order in another of the store's currencies: its settlement records the exchange rate and the " +
+			"total in that currency. Currencies not offered fail with UNSUPPORTED_CURRENCY, and EXCHANGE_RATES_UNAVAILABLE is " +
+			"returned while rates cannot be fetched.",
+		Request: handlers.CreateOrderRequest{}, Response: handlers.CreateOrderResponse{}, Status: http.StatusCreated,
+	})
+	numberParam := apidocs.Param{Name: "number", Description: "Only the order with this number, such as ORD-2024-48213907"}
+	v1("GET", "/orders/user", apidocs.Operation{
+		Summary: "List the current user's orders", Tags: []string{"orders"}, Auth: bearer,
+		Description: "Newest first, paged by the orders' creation time and ID: after=next_cursor lists older orders " +
+			"and before=prev_cursor newer ones, so new orders can be polled for; the Link header has the first, prev " +
+			"and next pages. With summary=true orders are listed without their items and promotions, as " +
+			"OrderSummariesResponse; GET /orders/:id has the full detail.",
+		Query: []apidocs.Param{numberParam,
+			{Name: "status", Description: "Only orders with this status; may be repeated or comma-separated"},
+			{Name: "from", Description: "Only orders placed on or after this date (YYYY-MM-DD) or RFC 3339 time"},
+			{Name: "to", Description: "Only orders placed on or before this date, or before this RFC 3339 time"},
+			{Name: "summary", Type: "boolean", Description: "true to list the orders without their items"},
+			{Name: "limit", Type: "integer", Description: "Page size (default 20, max 100)"},
+			{Name: "after", Description: "next_cursor of a page, for the older orders following it; cursor is accepted too"},
+			{Name: "before", Description: "prev_cursor of a page, for the newer orders preceding it"}},
+		Response: handlers.OrdersResponse{},
+	})
+	v1("GET", "/orders/:id", apidocs.Operation{
+		Summary: "Get one of the current user's orders", Tags: []string{"orders"}, Auth: bearer,
+		Description: "Includes the order's shipments with their tracking events, oldest first, and its digital items " +
+			"with signed download links.",
+		Response: handlers.OrderResponse{},
+	})
+	v1("GET", "/orders/:id/receipt", apidocs.Operation{
+		Summary: "Get a printer-friendly receipt of one of the current user's orders", Tags: []string{"orders"}, Auth: bearer,
+		Description: "Served as HTML in the language of the Accept-Language header, or as a PDF with format=pdf or an " +
+			"Accept header asking for application/pdf and not text/html. The receipt is not a tax invoice.",
+		Query: []apidocs.Param{
+			{Name: "format", Type: "string", Description: "html (the default) or pdf"},
+		},
+	})
+	v1("GET", "/orders", apidocs.Operation{
+		Summary: "List orders", Tags: []string{"orders"}, Auth: bearer, AdminOnly: true,
+		Query: append([]apidocs.Param{numberParam,
+			{Name: "metadata[key]", Description: "Only orders whose metadata key has this value; may be repeated for other keys"},
+			{Name: "needs_review", Description: "true for only the orders flagged as likely duplicates that are not reviewed yet"}},
+			streamParams...),
+		Response: handlers.OrdersResponse{},
+	})
+	v1("GET", "/admin/orders/export", apidocs.Operation{
+		Summary: "Export orders as CSV or JSON Lines", Tags: []string{"orders"}, Auth: bearer, AdminOnly: true,
+		Description: "Columns: id, number, user_id, username, status, units, shipping_cost, discount, gift_card_amount, " +
+			"total, created_at, delivery_date and delivery_window." + exportNote,
+		Query: append([]apidocs.Param{numberParam,
+			{Name: "metadata[key]", Description: "Only orders whose metadata key has this value; may be repeated for other keys"},
+			{Name: "needs_review", Description: "true for only the orders flagged as likely duplicates that are not reviewed yet"}},
+			exportParams...),
+	})
+	v1("PATCH", "/orders/:id/status", apidocs.Operation{
+		Summary: "Update an order's status", Tags: []string{"orders"}, Auth: bearer, AdminOnly: true,
+		Description: "Notifies the order's owner over /ws/orders. Orders move pending to completed, shipped or cancelled, " +
+			"completed to shipped, delivered or cancelled, and shipped to delivered or cancelled; other moves fail with INVALID_STATUS_TRANSITION.",
+		Request: handlers.UpdateOrderStatusRequest{}, Response: handlers.OrderResponse{},
+	})
+	v1("PATCH", "/admin/orders/status", apidocs.Operation{
+		Summary: "Update the status of many orders", Tags: []string{"orders"}, Auth: bearer, AdminOnly: true,
+		Description: "Moves up to 1000 orders to the status in one transaction, notifying each owner as a single update does. " +
+			"If any order cannot be moved, nothing is changed and the error's details list the result of every order.",
+		Request: handlers.BulkUpdateOrderStatusRequest{}, Response: handlers.BulkOrderStatusResponse{},
+	})
+	v1("POST", "/admin/orders/:id/review", apidocs.Operation{
+		Summary: "Mark a flagged order as reviewed", Tags: []string{"orders"}, Auth: bearer, AdminOnly: true,
+		Description: "Takes an order flagged as a likely duplicate off the orders to review, leaving its status as it is; " +
+			"cancel it to undo the duplicate. Orders that were not flagged fail with ORDER_NOT_FLAGGED.",
+		Response: handlers.OrderResponse{},
+	})
+	v1("GET", "/admin/duplicate-orders", apidocs.Operation{
+		Summary: "Get the duplicate order policy", Tags: []string{"orders"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.DuplicateOrderSettingsResponse{},
+	})
+	v1("PUT", "/admin/duplicate-orders", apidocs.Operation{
+		Summary: "Set the duplicate order policy", Tags: []string{"orders"}, Auth: bearer, AdminOnly: true,
+		Description: "Sets what checkout does with orders holding the same items as one the user placed within window_minutes: " +
+			"off places them, block refuses them with DUPLICATE_ORDER until confirmed, and flag places them for an admin to review. " +
+			"An empty policy and 0 minutes use CHECKOUT_DUPLICATE_POLICY and CHECKOUT_DUPLICATE_WINDOW. The request is always audited.",
+		Request: handlers.SetDuplicateOrderSettingsRequest{}, Response: handlers.DuplicateOrderSettingsResponse{},
+	})
+	v1("POST", "/orders/:id/shipments", apidocs.Operation{
+		Summary: "Add a shipment to an order", Tags: []string{"orders", "shipping"}, Auth: bearer, AdminOnly: true,
+		Description: "Records the carrier and tracking number of a parcel; its tracking is polled until delivered.",
+		Request:     handlers.CreateShipmentRequest{}, Response: handlers.ShipmentResponse{}, Status: http.StatusCreated,
+	})
+	v1("POST", "/admin/orders/:id/downloads/reissue", apidocs.Operation{
+		Summary: "Re-issue an order's downloads", Tags: []string{"orders"}, Auth: bearer, AdminOnly: true,
+		Description: "Resets the download counts of the order's digital items and returns fresh links; links issued before stop working.",
+		Response:    handlers.DownloadsResponse{},
+	})
+	v1("GET", "/admin/backorders", apidocs.Operation{
+		Summary: "List outstanding backorders", Tags: []string{"orders"}, Auth: bearer, AdminOnly: true,
+		Query:    []apidocs.Param{{Name: "item_id", Type: "integer", Description: "Only backorders of this item"}},
+		Response: handlers.BackordersResponse{},
+	})
+	v1("POST", "/admin/backorders/:id/fulfill", apidocs.Operation{
+		Summary: "Fulfill a backorder", Tags: []string{"orders"}, Auth: bearer, AdminOnly: true,
+		Description: "Takes the backordered units from the item's stock, allocating them to warehouses; fails with " +
+			"INSUFFICIENT_STOCK while the item is short.",
+		Response: handlers.BackorderResponse{},
+	})
+	v1("GET", "/downloads/:id", apidocs.Operation{
+		Summary: "Download a digital item", Tags: []string{"orders"},
+		Description: "Serves the file through a signed link from the order. Expired or re-issued links fail with " +
+			"DOWNLOAD_LINK_INVALID, and downloads past the limit with DOWNLOAD_LIMIT_REACHED.",
+		Query: []apidocs.Param{
+			{Name: "expires", Type: "integer", Description: "Unix time the link expires at"},
+			{Name: "signature", Type: "string", Description: "Signature of the link"},
+		},
+	})
+	apidocs.Document("POST", "/webhooks/carriers/:carrier", apidocs.Operation{
+		Summary: "Receive tracking updates from a carrier", Tags: []string{"shipping"},
+		Description: "Called by carriers served through the tracking API with {\"tracking_number\":\"...\",\"events\":[...]}, " +
+			"signed with TRACKING_WEBHOOK_SECRET in the X-Tracking-Signature header.",
+		Status: http.StatusNoContent,
+	})
+	apidocs.Document("GET", "/ws/orders", apidocs.Operation{
+		Summary: "Order status updates (WebSocket)", Tags: []string{"orders"}, Auth: bearer,
+		Description: "Upgrades to a WebSocket that receives an order.created or order.status_changed event, as JSON, " +
+			"for each change to the current user's orders. The token may be passed as the access_token query parameter.",
+		Query:  []apidocs.Param{{Name: "access_token", Description: "JWT, for clients that cannot set the Authorization header"}},
+		Status: http.StatusSwitchingProtocols,
+	})
+
+	// Vendors
+	v1("POST", "/admin/vendors", apidocs.Operation{
+		Summary: "Create a vendor", Tags: []string{"vendors"}, Auth: bearer, AdminOnly: true,
+		Request: handlers.CreateVendorRequest{}, Response: handlers.VendorResponse{}, Status: http.StatusCreated,
+	})
+	v1("GET", "/admin/vendors", apidocs.Operation{
+		Summary: "List vendors", Tags: []string{"vendors"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.VendorsResponse{},
+	})
+	v1("POST", "/admin/vendors/:id/accounts", apidocs.Operation{
+		Summary: "Make a user an account of a vendor", Tags: []string{"vendors"}, Auth: bearer, AdminOnly: true,
+		Description: "Gives the user the vendor role, effective from their next login.",
+		Request:     handlers.AddVendorAccountRequest{}, Response: handlers.UserResponse{},
+	})
+	v1("GET", "/vendor/orders", apidocs.Operation{
+		Summary: "List the current vendor's sub-orders", Tags: []string{"vendors"}, Auth: bearer,
+		Description: "Vendor accounts only. Each sub-order lists the vendor's own lines of an order.",
+		Query:       pageParams, Response: handlers.SubOrdersResponse{},
+	})
+	v1("PATCH", "/vendor/orders/:id/status", apidocs.Operation{
+		Summary: "Update a sub-order's status", Tags: []string{"vendors"}, Auth: bearer,
+		Description: "Vendor accounts only. The status of the order as a whole is managed by admins.",
+		Request:     handlers.UpdateSubOrderStatusRequest{}, Response: handlers.SubOrderResponse{},
+	})
+
+	// Shipping
+	v1("POST", "/admin/shipping-methods", apidocs.Operation{
+		Summary: "Create a shipping method", Tags: []string{"shipping"}, Auth: bearer, AdminOnly: true,
+		Description: "flat methods cost rate; free_over methods cost rate below a free_over subtotal and nothing from it; " +
+			"weight methods cost rate plus per_kg for each kilogram of the cart.",
+		Request: handlers.CreateShippingMethodRequest{}, Response: handlers.ShippingMethodResponse{}, Status: http.StatusCreated,
+	})
+	v1("GET", "/admin/shipping-methods", apidocs.Operation{
+		Summary: "List shipping methods", Tags: []string{"shipping"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.ShippingMethodsResponse{},
+	})
+	v1("DELETE", "/admin/shipping-methods/:id", apidocs.Operation{
+		Summary: "Delete a shipping method", Tags: []string{"shipping"}, Auth: bearer, AdminOnly: true,
+		Status: http.StatusNoContent,
+	})
+	v1("POST", "/admin/delivery-slots", apidocs.Operation{
+		Summary: "Create a delivery slot", Tags: []string{"delivery"}, Auth: bearer, AdminOnly: true,
+		Description: "start_time and end_time are times of day such as 09:00, in the configured delivery time zone; " +
+			"capacity is how many orders the slot takes on each day.",
+		Request: handlers.CreateDeliverySlotRequest{}, Response: handlers.DeliverySlotResponse{}, Status: http.StatusCreated,
+	})
+	v1("GET", "/admin/delivery-slots", apidocs.Operation{
+		Summary: "List delivery slots", Tags: []string{"delivery"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.DeliverySlotsResponse{},
+	})
+	v1("DELETE", "/admin/delivery-slots/:id", apidocs.Operation{
+		Summary: "Delete a delivery slot", Tags: []string{"delivery"}, Auth: bearer, AdminOnly: true,
+		Description: "Orders booked into the slot keep their delivery date and window.",
+		Status:      http.StatusNoContent,
+	})
+
+	// Gift cards
+	v1("GET", "/gift-cards/user", apidocs.Operation{
+		Summary: "List the gift cards the current user bought or was sent", Tags: []string{"gift cards"}, Auth: bearer,
+		Response: handlers.GiftCardsResponse{},
+	})
+	v1("GET", "/gift-cards/:code", apidocs.Operation{
+		Summary: "Check a gift card's balance", Tags: []string{"gift cards"}, Auth: bearer,
+		Response: handlers.GiftCardResponse{},
+	})
+	v1("POST", "/admin/gift-cards", apidocs.Operation{
+		Summary: "Issue a gift card", Tags: []string{"gift cards"}, Auth: bearer, AdminOnly: true,
+		Request: handlers.IssueGiftCardRequest{}, Response: handlers.GiftCardResponse{}, Status: http.StatusCreated,
+	})
+	v1("GET", "/admin/gift-cards", apidocs.Operation{
+		Summary: "List gift cards", Tags: []string{"gift cards"}, Auth: bearer, AdminOnly: true,
+		Query: pageParams, Response: handlers.GiftCardsResponse{},
+	})
+	v1("GET", "/admin/gift-cards/:id", apidocs.Operation{
+		Summary: "Get a gift card with its ledger", Tags: []string{"gift cards"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.GiftCardResponse{},
+	})
+	v1("POST", "/admin/gift-cards/:id/void", apidocs.Operation{
+		Summary: "Void a gift card", Tags: []string{"gift cards"}, Auth: bearer, AdminOnly: true,
+		Description: "Empties the card's balance, recorded in its ledger; voided cards cannot be redeemed.",
+		Response:    handlers.GiftCardResponse{},
+	})
+
+	// Promotions
+	v1("POST", "/admin/promotions", apidocs.Operation{
+		Summary: "Create a promotion", Tags: []string{"promotions"}, Auth: bearer, AdminOnly: true,
+		Description: "buy_x_get_y promotions make get_quantity of every buy_quantity plus get_quantity units of an item free; " +
+			"tier promotions take percent off lines of at least min_quantity units of an item; " +
+			"percent_off promotions take percent off an item or every item in a category. starts_at and ends_at time-box any promotion.",
+		Request: handlers.CreatePromotionRequest{}, Response: handlers.PromotionResponse{}, Status: http.StatusCreated,
+	})
+	v1("GET", "/admin/promotions", apidocs.Operation{
+		Summary: "List promotions", Tags: []string{"promotions"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.PromotionsResponse{},
+	})
+	v1("DELETE", "/admin/promotions/:id", apidocs.Operation{
+		Summary: "Delete a promotion", Tags: []string{"promotions"}, Auth: bearer, AdminOnly: true,
+		Status: http.StatusNoContent,
+	})
+
+	// Flash sales
+	v1("POST", "/admin/sales", apidocs.Operation{
+		Summary: "Create a flash sale", Tags: []string{"sales"}, Auth: bearer, AdminOnly: true,
+		Description: "Sales give an item a sale price, or take percent off every item in a category, between starts_at and ends_at. " +
+			"per_user_limit caps the units each customer buys at the sale price.",
+		Request: handlers.CreateSaleRequest{}, Response: handlers.SaleResponse{}, Status: http.StatusCreated,
+	})
+	v1("GET", "/admin/sales", apidocs.Operation{
+		Summary: "List flash sales", Tags: []string{"sales"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.SalesResponse{},
+	})
+	v1("DELETE", "/admin/sales/:id", apidocs.Operation{
+		Summary: "Delete a flash sale", Tags: []string{"sales"}, Auth: bearer, AdminOnly: true,
+		Status: http.StatusNoContent,
+	})
+
+	// Warehouses
+	v1("POST", "/admin/warehouses", apidocs.Operation{
+		Summary: "Create a warehouse", Tags: []string{"warehouses"}, Auth: bearer, AdminOnly: true,
+		Description: "Checkout allocates orders to warehouses by priority, lowest first.",
+		Request:     handlers.CreateWarehouseRequest{}, Response: handlers.WarehouseResponse{}, Status: http.StatusCreated,
+	})
+	v1("GET", "/admin/warehouses", apidocs.Operation{
+		Summary: "List warehouses in allocation order", Tags: []string{"warehouses"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.WarehousesResponse{},
+	})
+	v1("GET", "/admin/warehouses/:id/stock", apidocs.Operation{
+		Summary: "List the stock held at a warehouse", Tags: []string{"warehouses"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.WarehouseStockResponse{},
+	})
+	v1("PUT", "/admin/warehouses/:id/stock/:item_id", apidocs.Operation{
+		Summary: "Set the stock of an item at a warehouse", Tags: []string{"warehouses"}, Auth: bearer, AdminOnly: true,
+		Description: "The item's stock becomes its total across warehouses.",
+		Request:     handlers.SetWarehouseStockRequest{}, Response: handlers.ItemResponse{},
+	})
+	v1("POST", "/admin/stock-transfers", apidocs.Operation{
+		Summary: "Move stock between warehouses", Tags: []string{"warehouses"}, Auth: bearer, AdminOnly: true,
+		Request: handlers.CreateStockTransferRequest{}, Response: handlers.StockTransferResponse{}, Status: http.StatusCreated,
+	})
+	v1("GET", "/admin/stock-transfers", apidocs.Operation{
+		Summary: "List stock transfers, newest first", Tags: []string{"warehouses"}, Auth: bearer, AdminOnly: true,
+		Query: pageParams, Response: handlers.StockTransfersResponse{},
+	})
+
+	// Integrations
+	v1("POST", "/api-keys", apidocs.Operation{
+		Summary: "Issue an API key", Tags: []string{"integrations"}, Auth: bearer,
+		Description: "Keys are sandbox keys unless sandbox is false, which only admins may request. webhook_url must be https " +
+			"and its host must resolve only to public addresses; private, loopback and link-local ones are refused, " +
+			"also when webhooks are delivered.",
+		Request: handlers.CreateAPIKeyRequest{}, Response: handlers.CreateAPIKeyResponse{}, Status: http.StatusCreated,
+	})
+	v1("POST", "/api-keys/:id/webhook-secret", apidocs.Operation{
+		Summary: "Rotate the webhook secret of an API key", Tags: []string{"integrations"}, Auth: bearer,
+		Description: "Webhooks of the key are signed with the new secret from then on; the old one stops working at once.",
+		Response:    handlers.WebhookSecretResponse{},
+	})
+	quotaNote := "Each UTC day and month the key may make up to its daily_quota and monthly_quota requests, set by admins " +
+		"or API_KEY_DAILY_QUOTA and API_KEY_MONTHLY_QUOTA by default; limit and remaining are null without a limit."
+	v1("GET", "/api-keys/:id/usage", apidocs.Operation{
+		Summary: "Get the quota usage of one of the current user's API keys", Tags: []string{"integrations"}, Auth: bearer,
+		Description: quotaNote + " days lists the requests made, and rejected for exceeding a quota, on each day of the month.",
+		Response:    handlers.APIKeyUsageResponse{},
+	})
+	v1("PUT", "/admin/api-keys/:id/quotas", apidocs.Operation{
+		Summary: "Set the request quotas of an API key", Tags: []string{"integrations"}, Auth: bearer, AdminOnly: true,
+		Description: "null restores the configured default and 0 removes the limit. The request is audited.",
+		Request:     handlers.SetAPIKeyQuotasRequest{}, Response: handlers.APIKeyQuotasResponse{},
+	})
+	apidocs.Document("GET", "/sandbox/usage", apidocs.Operation{
+		Summary: "Get the quota usage of the API key making the request", Tags: []string{"integrations"}, Auth: apidocs.AuthAPIKey,
+		Description: quotaNote + " Checking usage does not count towards the quotas.",
+		Response:    handlers.APIKeyUsageResponse{},
+	})
+	apidocs.Document("POST", "/sandbox/simulate-order", apidocs.Operation{
+		Summary: "Simulate an order lifecycle", Tags: []string{"integrations"}, Auth: apidocs.AuthAPIKey,
+		Description: "Fires order.created, order.paid, order.shipped and order.delivered webhooks at the given interval to the " +
+			"key's webhook_url, signed with the key's webhook secret in the X-Webhook-Signature header. Requires a sandbox key " +
+			"with a webhook_url. Inactive items are never used. Counts towards the " +
+			"key's quotas, failing with 429 QUOTA_EXCEEDED and a Retry-After header once one is used up.",
+		Request: handlers.SimulateOrderRequest{}, Response: handlers.SimulateOrderResponse{}, Status: http.StatusAccepted,
+	})
+
+	syncNote := "Requires a live key owned by an admin and counts towards the key's quotas."
+	apidocs.Document("PUT", "/sync/inventory", apidocs.Operation{
+		Summary: "Push the prices and stock of items by SKU", Tags: []string{"integrations"}, Auth: apidocs.AuthAPIKey,
+		Description: "Each row sets the price and stock of the item with the SKU, creating it if there is none, which needs a " +
+			"name and a price. Rows are applied on their own and reported as created, updated, unchanged, conflict or failed " +
+			"with the item as it is after the row. A row sending the updated_at of the item it last synced conflicts with " +
+			"SYNC_CONFLICT if the item has changed since. " + syncNote,
+		Request: handlers.SyncInventoryRequest{}, Response: handlers.SyncInventoryResponse{},
+	})
+	apidocs.Document("GET", "/sync/inventory", apidocs.Operation{
+		Summary: "Pull the items changed since the last pull", Tags: []string{"integrations"}, Auth: apidocs.AuthAPIKey,
+		Description: "Lists the items with a SKU, active or not, oldest change first. The cursor returned, even when nothing " +
+			"changed, is sent on the next pull; has_more means there are more changes to pull now. " + syncNote,
+		Query: []apidocs.Param{
+			{Name: "since", Description: "RFC 3339 time to list the changes from, on the first pull"},
+			{Name: "cursor", Description: "Cursor returned by the previous pull"},
+			{Name: "limit", Type: "integer", Description: "Maximum items (default 100, max 1000)"},
+		},
+		Response: handlers.InventoryChangesResponse{},
+	})
+
+	// Admin
+	v1("GET", "/audit-logs", apidocs.Operation{
+		Summary: "Query the audit log", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Query: []apidocs.Param{
+			{Name: "route", Description: "Route pattern, e.g. /api/v1/users/login"},
+			{Name: "user_id", Type: "integer"},
+			{Name: "impersonator_id", Type: "integer", Description: "Admin who made the requests with an impersonation token"},
+			{Name: "from", Description: "RFC 3339 start time"},
+			{Name: "to", Description: "RFC 3339 end time"},
+			{Name: "limit", Type: "integer", Description: "Maximum records (default 100, max 500)"},
+		},
+		Response: handlers.AuditLogsResponse{},
+	})
+	v1("GET", "/admin/purge-runs", apidocs.Operation{
+		Summary: "List purge runs", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Query:    []apidocs.Param{{Name: "limit", Type: "integer", Description: "Maximum runs (default 20, max 100)"}},
+		Response: handlers.PurgeRunsResponse{},
+	})
+	v1("POST", "/admin/purge-runs", apidocs.Operation{
+		Summary: "Trigger a purge run", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Description: "Applies the retention policies now, purging expired sessions, carts left open for RETENTION_CART_DAYS " +
+			"and audit logs older than AUDIT_RETENTION_DAYS. The run goes on in the background; fails with 409 " +
+			"PURGE_IN_PROGRESS while this instance is already running one.",
+		Response: handlers.PurgeRunResponse{}, Status: http.StatusAccepted,
+	})
+	v1("GET", "/admin/purge-runs/:id", apidocs.Operation{
+		Summary: "Get a purge run", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.PurgeRunResponse{},
+	})
+	v1("GET", "/admin/maintenance", apidocs.Operation{
+		Summary: "Get the maintenance mode", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.MaintenanceResponse{},
+	})
+	v1("PUT", "/admin/maintenance", apidocs.Operation{
+		Summary: "Switch maintenance mode on or off", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Description: "While the mode is on, writes by anyone but admins fail with 503 MAINTENANCE and a Retry-After header; " +
+			"reads and logins keep working. It applies to every instance sharing the cache, ends by itself after the " +
+			"optional duration, and cannot be switched off while MAINTENANCE_MODE is set. The request is always audited.",
+		Request: handlers.SetMaintenanceRequest{}, Response: handlers.MaintenanceResponse{},
+	})
+	v1("GET", "/admin/jwt-keys", apidocs.Operation{
+		Summary: "List the JWT signing keys", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Description: "Lists the keys that verify tokens, without their secrets, and which one signs new tokens. " +
+			"Keys that have expired are left out.",
+		Response: handlers.JWTKeysResponse{},
+	})
+	v1("POST", "/admin/jwt-keys/rotate", apidocs.Operation{
+		Summary: "Rotate the JWT signing key", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Description: "Makes a new key, named by the kid header of the tokens it signs, which every instance signs " +
+			"with within a minute. Tokens signed with the previous keys stay valid until they expire, after which " +
+			"those keys are retired. Returns 201 with the keys. The request is always audited.",
+		Response: handlers.JWTKeysResponse{},
+	})
+	v1("GET", "/admin/email-templates", apidocs.Operation{
+		Summary: "List the email templates", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.EmailTemplatesResponse{},
+	})
+	v1("GET", "/admin/email-templates/:name/preview", apidocs.Operation{
+		Summary: "Preview an email", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Description: "Renders the email with sample data using the loaded templates, including those replaced from " +
+			"EMAIL_TEMPLATES_DIR. Unknown names fail with EMAIL_TEMPLATE_NOT_FOUND.",
+		Query:    []apidocs.Param{{Name: "format", Description: "html for the HTML body alone, to view in a browser"}},
+		Response: handlers.EmailPreviewResponse{},
+	})
+	v1("GET", "/flags", apidocs.Operation{
+		Summary: "Get the feature flags of the current user", Tags: []string{"admin"},
+		Description: "Tells, for every feature flag, whether it is on for the user sending the bearer token, or for anonymous visitors.",
+		Response:    handlers.EnabledFlagsResponse{},
+	})
+	v1("GET", "/admin/flags", apidocs.Operation{
+		Summary: "List feature flags", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.FeatureFlagsResponse{},
+	})
+	v1("PUT", "/admin/flags/:name", apidocs.Operation{
+		Summary: "Create or change a feature flag", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Description: "Omitted fields keep their value; new flags start disabled, at 0 percent. Enabled flags are on for " +
+			"user_ids and for percentage percent of the other signed-in users, and for everyone at 100. Returns 201 when " +
+			"the flag is created.",
+		Request: handlers.SetFlagRequest{}, Response: handlers.FeatureFlagResponse{},
+	})
+	v1("DELETE", "/admin/flags/:name", apidocs.Operation{
+		Summary: "Delete a feature flag", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Response: handlers.MessageResponse{},
+	})
+
+	// Analytics
+	rangeParams := []apidocs.Param{
+		{Name: "from", Description: "RFC 3339 time or YYYY-MM-DD date (default 30 days ago)"},
+		{Name: "to", Description: "RFC 3339 time, exclusive, or YYYY-MM-DD date, inclusive (default now)"},
+		{Name: "format", Description: "csv for a CSV attachment instead of JSON"},
+	}
+	v1("GET", "/admin/analytics/revenue", apidocs.Operation{
+		Summary: "Revenue and order counts per period", Tags: []string{"analytics"}, Auth: bearer, AdminOnly: true,
+		Description: "Counts completed, shipped and delivered orders.",
+		Query:       append([]apidocs.Param{{Name: "period", Description: "day (default), week or month"}}, rangeParams...),
+		Response:    handlers.RevenueResponse{},
+	})
+	v1("GET", "/admin/analytics/order-status", apidocs.Operation{
+		Summary: "Order counts by status", Tags: []string{"analytics"}, Auth: bearer, AdminOnly: true,
+		Query: rangeParams, Response: handlers.OrderStatusCountsResponse{},
+	})
+	v1("GET", "/admin/analytics/summary", apidocs.Operation{
+		Summary: "Order count, revenue and average order value", Tags: []string{"analytics"}, Auth: bearer, AdminOnly: true,
+		Description: "Counts completed, shipped and delivered orders.",
+		Query:       rangeParams, Response: handlers.SalesSummaryResponse{},
+	})
+
+	v1("GET", "/admin/analytics/funnel", apidocs.Operation{
+		Summary: "Conversion funnel", Tags: []string{"analytics"}, Auth: bearer, AdminOnly: true,
+		Description: "Follows the carts created in the range: how many still hold items, how many their owners tried to check out, " +
+			"and how many became completed, shipped or delivered orders. Each stage's rate is its share of the previous stage.",
+		Query: rangeParams, Response: handlers.ConversionFunnelResponse{},
+	})
+
+	v1("GET", "/admin/analytics/top-items", apidocs.Operation{
+		Summary: "Best-selling items", Tags: []string{"analytics"}, Auth: bearer, AdminOnly: true,
+		Description: "Ranks items by units sold in completed, shipped and delivered orders. Revenue is at current prices.",
+		Query: []apidocs.Param{
+			{Name: "period", Description: "day, week (default) or month, counted back from now"},
+			{Name: "limit", Type: "integer", Description: "Number of items (default 10, max 100)"},
+			{Name: "format", Description: "csv for a CSV attachment instead of JSON"},
+		},
+		Response: handlers.TopItemsResponse{},
+	})
+
+	v1("GET", "/admin/events", apidocs.Operation{
+		Summary: "Admin event stream (server-sent events)", Tags: []string{"admin"}, Auth: bearer, AdminOnly: true,
+		Description: "Streams order.created, payment.failed and item.stock_low events as text/event-stream. " +
+			"Send Last-Event-ID when reconnecting to receive missed events; a resync event means they were no longer retained.",
+		Query: []apidocs.Param{
+			{Name: "types", Description: "Comma-separated event types to receive (default all)"},
+			{Name: "access_token", Description: "JWT, for clients that cannot set the Authorization header"},
+		},
+	})
+
+	// Operations
+	// GraphQL
+	for _, method := range []string{"GET", "POST"} {
+		apidocs.Document(method, "/graphql", apidocs.Operation{
+			Summary: "GraphQL API", Tags: []string{"graphql"},
+			Description: "Queries items, item, cart and orders; mutations addToCart and checkout. The schema is in graph/schema.graphqls " +
+				"and available through introspection. A bearer token is optional, but cart, orders and mutations require one.",
+		})
+	}
+
+	apidocs.Document("GET", "/healthz", apidocs.Operation{
+		Summary: "Liveness probe", Tags: []string{"operations"}, Response: handlers.HealthResponse{},
+	})
+	apidocs.Document("GET", "/readyz", apidocs.Operation{
+		Summary: "Readiness probe", Tags: []string{"operations"}, Response: handlers.ReadinessResponse{},
+	})
+	apidocs.Document("GET", "/version", apidocs.Operation{
+		Summary: "Build information", Tags: []string{"operations"}, Response: version.Info{},
+	})
+	if base := config.Get().Storage.BaseURL; strings.HasPrefix(base, "/") {
+		uploads := apidocs.Operation{Summary: "Uploaded files, such as avatars", Tags: []string{"operations"}}
+		apidocs.Document("GET", strings.TrimSuffix(base, "/")+"/*filepath", uploads)
+		apidocs.Document("HEAD", strings.TrimSuffix(base, "/")+"/*filepath", uploads)
+	}
+	apidocs.Document("GET", "/debug/vars", apidocs.Operation{
+		Summary: "Runtime metrics (expvar)", Tags: []string{"operations"}, Auth: bearer, AdminOnly: true,
+	})
+	apidocs.Document("GET", "/docs", apidocs.Operation{
+		Summary: "Swagger UI", Tags: []string{"operations"},
+	})
+	apidocs.Document("GET", "/docs/openapi.json", apidocs.Operation{
+		Summary: "OpenAPI 3 document", Tags: []string{"operations"},
+	})
+}
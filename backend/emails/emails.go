@@ -0,0 +1,279 @@
+// Package emails renders the transactional emails from templates. Each
+// email has a name.txt, executed with text/template for its subject and
+// plain-text body, and a name.html, executed with html/template inside
+// layouts/base.html and with the partials of partials/ for its HTML body.
+// The built-in templates are embedded; files of the same path in the
+// configured templates directory replace them, and partials added there
+// can be used by the others.
+package emails
+
+import (
+	"ecommerce-backend/config"
+	"ecommerce-backend/notifications"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var files embed.FS
+
+// Names of the emails
+const (
+	CartReminder = "cart_reminder"
+	BackInStock  = "back_in_stock"
+	LowStock     = "low_stock"
+)
+
+// ErrUnknownTemplate is returned for emails without a template
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+// Line is an item line of an email
+type Line struct {
+	Name     string
+	Quantity int
+	Amount   float64
+}
+
+// Coupon is a gift card sent with an email
+type Coupon struct {
+	Code   string
+	Amount float64
+}
+
+// CartReminderData is what the abandoned cart reminder is rendered with
+type CartReminderData struct {
+	Username string
+	Lines    []Line
+	// Total is the cart's total, Discount off
+	Total    float64
+	Discount float64
+	// Coupon is the gift card sent with the reminder, if any
+	Coupon *Coupon
+}
+
+// BackInStockData is what back-in-stock alerts are rendered with
+type BackInStockData struct {
+	Username string
+	ItemName string
+	Price    float64
+}
+
+// LowStockItem is an item of the low-stock digest
+type LowStockItem struct {
+	ID        uint
+	StoreID   uint
+	Name      string
+	Stock     int
+	Threshold int
+}
+
+// LowStockData is what the low-stock digest to admins is rendered with
+type LowStockData struct {
+	Items []LowStockItem
+}
+
+// samples are the data each email is previewed with, by name
+var samples = map[string]interface{}{
+	CartReminder: CartReminderData{
+		Username: "jane",
+		Lines: []Line{
+			{Name: "Wireless Mouse", Quantity: 1, Amount: 24.99},
+			{Name: "USB-C Cable", Quantity: 2, Amount: 19.98},
+		},
+		Total:    40.47,
+		Discount: 4.50,
+		Coupon:   &Coupon{Code: "GC-SAMPLE-1234", Amount: 5},
+	},
+	BackInStock: BackInStockData{Username: "jane", ItemName: "Wireless Mouse", Price: 24.99},
+	LowStock: LowStockData{Items: []LowStockItem{
+		{ID: 12, StoreID: 1, Name: "Wireless Mouse", Stock: 3, Threshold: 5},
+		{ID: 31, StoreID: 1, Name: "USB-C Cable", Stock: 0, Threshold: 10},
+	}},
+}
+
+// set is the parsed templates of an email
+type set struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var (
+	mu   sync.RWMutex
+	sets = mustLoad()
+)
+
+func mustLoad() map[string]set {
+	loaded, err := load("", "")
+	if err != nil {
+		panic(err)
+	}
+	return loaded
+}
+
+// Init loads the templates, those in the configured directory in place of
+// the built-in ones, signed with the configured brand
+func Init(cfg config.EmailConfig) error {
+	loaded, err := load(cfg.TemplatesDir, cfg.BrandName)
+	if err != nil {
+		return fmt.Errorf("email templates: %w", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	sets = loaded
+	return nil
+}
+
+// load parses the templates of every email, reading each file from dir if
+// it is there
+func load(dir, brand string) (map[string]set, error) {
+	funcs := map[string]interface{}{
+		"brand": func() string { return brand },
+		"money": func(amount float64) string { return fmt.Sprintf("%.2f", amount) },
+	}
+	read := func(name string) (string, error) {
+		if dir != "" {
+			data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+			if err == nil {
+				return string(data), nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return "", err
+			}
+		}
+		data, err := files.ReadFile(path.Join("templates", name))
+		return string(data), err
+	}
+
+	partials, err := partialNames(dir)
+	if err != nil {
+		return nil, err
+	}
+	html := htmltemplate.New("").Funcs(funcs)
+	for _, name := range append([]string{"layouts/base.html"}, partials...) {
+		src, err := read(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := html.New(name).Parse(src); err != nil {
+			return nil, err
+		}
+	}
+	src, err := read("layouts/base.txt")
+	if err != nil {
+		return nil, err
+	}
+	text, err := texttemplate.New("layouts/base.txt").Funcs(funcs).Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[string]set, len(samples))
+	for name := range samples {
+		var s set
+		if s.html, err = html.Clone(); err != nil {
+			return nil, err
+		}
+		if src, err = read(name + ".html"); err != nil {
+			return nil, err
+		}
+		if _, err := s.html.New(name + ".html").Parse(src); err != nil {
+			return nil, err
+		}
+		if s.text, err = text.Clone(); err != nil {
+			return nil, err
+		}
+		if src, err = read(name + ".txt"); err != nil {
+			return nil, err
+		}
+		if _, err := s.text.New(name + ".txt").Parse(src); err != nil {
+			return nil, err
+		}
+		loaded[name] = s
+	}
+	return loaded, nil
+}
+
+// partialNames returns the paths of the built-in partials and of those
+// added in dir
+func partialNames(dir string) ([]string, error) {
+	embedded, err := fs.Glob(files, "templates/partials/*.html")
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, name := range embedded {
+		name = strings.TrimPrefix(name, "templates/")
+		seen[name], names = true, append(names, name)
+	}
+	if dir != "" {
+		added, err := filepath.Glob(filepath.Join(dir, "partials", "*.html"))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range added {
+			if name = "partials/" + filepath.Base(name); !seen[name] {
+				seen[name], names = true, append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// Render renders the named email with data into a message without
+// recipients. The templates are rendered to buffers, so a failing one
+// sends nothing.
+func Render(name string, data interface{}) (notifications.Message, error) {
+	mu.RLock()
+	s, ok := sets[name]
+	mu.RUnlock()
+	if !ok {
+		return notifications.Message{}, ErrUnknownTemplate
+	}
+
+	var subject, text, html strings.Builder
+	if err := s.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return notifications.Message{}, fmt.Errorf("email %s: %w", name, err)
+	}
+	if err := s.text.ExecuteTemplate(&text, "layout", data); err != nil {
+		return notifications.Message{}, fmt.Errorf("email %s: %w", name, err)
+	}
+	if err := s.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return notifications.Message{}, fmt.Errorf("email %s: %w", name, err)
+	}
+	return notifications.Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// Names returns the names of the emails, sorted
+func Names() []string {
+	names := make([]string, 0, len(samples))
+	for name := range samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Preview renders the named email with sample data
+func Preview(name string) (notifications.Message, error) {
+	data, ok := samples[name]
+	if !ok {
+		return notifications.Message{}, ErrUnknownTemplate
+	}
+	return Render(name, data)
+}
@@ -0,0 +1,158 @@
+// Package encryption encrypts personal data at rest with AES-256-GCM.
+// Values are encrypted with the first configured key and tagged with its
+// ID, so older keys can stay configured to decrypt rows written before a
+// rotation until those rows are re-encrypted. Without keys, values are
+// stored as given.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// prefix marks encrypted values; values without it are plaintext written
+// before encryption was enabled
+const prefix = "enc:v1:"
+
+// KeySize is the length of decoded keys, selecting AES-256
+const KeySize = 32
+
+var (
+	ErrUnknownKey = errors.New("encryption: value was encrypted with a key that is not configured")
+	ErrMalformed  = errors.New("encryption: malformed encrypted value")
+)
+
+type key struct {
+	id   string
+	aead cipher.AEAD
+}
+
+var (
+	mu      sync.RWMutex
+	current *key
+	keys    = map[string]*key{}
+)
+
+// ParseKey splits a configured key of the form "id:base64-key" into its
+// ID and the decoded key
+func ParseKey(s string) (string, []byte, error) {
+	id, encoded, ok := strings.Cut(s, ":")
+	if !ok || id == "" || strings.Contains(id, ":") {
+		return "", nil, errors.New("key must have the form id:base64-key")
+	}
+	secret, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, fmt.Errorf("key %q is not valid base64", id)
+	}
+	if len(secret) != KeySize {
+		return "", nil, fmt.Errorf("key %q must decode to %d bytes", id, KeySize)
+	}
+	return id, secret, nil
+}
+
+// Init sets the keys, the first of which encrypts new values. With no keys
+// encryption is disabled.
+func Init(configured []string) error {
+	parsed := map[string]*key{}
+	var first *key
+	for _, s := range configured {
+		id, secret, err := ParseKey(s)
+		if err != nil {
+			return err
+		}
+		if _, dup := parsed[id]; dup {
+			return fmt.Errorf("key %q is configured twice", id)
+		}
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return err
+		}
+		k := &key{id: id, aead: aead}
+		parsed[id] = k
+		if first == nil {
+			first = k
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	current, keys = first, parsed
+	return nil
+}
+
+// Enabled reports whether new values are encrypted
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current != nil
+}
+
+// CurrentKeyID returns the ID of the key encrypting new values, or "" if
+// encryption is disabled
+func CurrentKeyID() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return ""
+	}
+	return current.id
+}
+
+// Encrypt encrypts plaintext with the current key. Empty strings are kept
+// empty, so that unset values can still be told apart in queries.
+func Encrypt(plaintext string) (string, error) {
+	mu.RLock()
+	k := current
+	mu.RUnlock()
+	if k == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := k.aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.id))
+	return prefix + k.id + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext of a value returned by Encrypt. Values
+// that were never encrypted are returned as they are.
+func Decrypt(value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return value, nil
+	}
+	id, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", ErrMalformed
+	}
+
+	mu.RLock()
+	k := keys[id]
+	mu.RUnlock()
+	if k == nil {
+		return "", ErrUnknownKey
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < k.aead.NonceSize() {
+		return "", ErrMalformed
+	}
+	nonce, ciphertext := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	plaintext, err := k.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", ErrMalformed
+	}
+	return string(plaintext), nil
+}
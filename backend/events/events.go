@@ -0,0 +1,224 @@
+// Package events is an in-process publish/subscribe bus for domain events.
+// Services write events to the outbox in the transaction of the change they
+// describe, and the outbox relay dispatches them once committed;
+// subscribers such as WebSocket connections receive them asynchronously.
+package events
+
+import (
+	"ecommerce-backend/models"
+	"ecommerce-backend/utils"
+	"encoding/json"
+	"expvar"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	OrderCreated       = "order.created"
+	OrderStatusChanged = "order.status_changed"
+	PaymentFailed      = "payment.failed"
+	StockLow           = "item.stock_low"
+	BackInStock        = "item.back_in_stock"
+	ShipmentUpdated    = "shipment.updated"
+)
+
+const (
+	// subscriberBuffer is how many events a subscriber may fall behind
+	// before further events are dropped for it
+	subscriberBuffer = 64
+	// historySize is how many recent events are kept for Resume
+	historySize = 256
+)
+
+// Event is a domain event
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+	// UserID is the user the event concerns, used to route it to their
+	// subscriptions; 0 if none
+	UserID uint `json:"-"`
+	// StoreID is the store the event happened in
+	StoreID uint `json:"-"`
+}
+
+// OrderStatus is the data of order events
+type OrderStatus struct {
+	OrderID        uint    `json:"order_id"`
+	OrderNumber    string  `json:"order_number"`
+	UserID         uint    `json:"user_id"`
+	Status         string  `json:"status"`
+	PreviousStatus string  `json:"previous_status,omitempty"`
+	Total          float64 `json:"total"`
+}
+
+// Shipment is the data of shipment events
+type Shipment struct {
+	OrderID        uint   `json:"order_id"`
+	ShipmentID     uint   `json:"shipment_id"`
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status,omitempty"`
+}
+
+// Payment is the data of payment events
+type Payment struct {
+	OrderID uint    `json:"order_id"`
+	UserID  uint    `json:"user_id"`
+	Amount  float64 `json:"amount"`
+	Reason  string  `json:"reason"`
+}
+
+// Restock is the data of back-in-stock events, sent to each user
+// subscribed to the item
+type Restock struct {
+	ItemID uint   `json:"item_id"`
+	Name   string `json:"name"`
+	UserID uint   `json:"user_id"`
+}
+
+// Stock is the data of stock events
+type Stock struct {
+	ItemID    uint   `json:"item_id"`
+	Name      string `json:"name"`
+	Stock     int    `json:"stock"`
+	Threshold int    `json:"threshold"`
+}
+
+// Event counters, exposed via /debug/vars
+var (
+	published = expvar.NewMap("events_published")
+	dropped   = expvar.NewInt("events_dropped")
+)
+
+var (
+	mu            sync.Mutex
+	subscriptions = map[*Subscription]struct{}{}
+	// history holds the most recent events, oldest first
+	history []Event
+)
+
+// Subscription receives the published events accepted by its filter
+type Subscription struct {
+	ch     chan Event
+	filter func(Event) bool
+	once   sync.Once
+}
+
+// Subscribe starts receiving events for which filter returns true (all
+// events if filter is nil). The subscription must be closed when done.
+func Subscribe(filter func(Event) bool) *Subscription {
+	sub := &Subscription{ch: make(chan Event, subscriberBuffer), filter: filter}
+
+	mu.Lock()
+	subscriptions[sub] = struct{}{}
+	mu.Unlock()
+	return sub
+}
+
+// Resume subscribes like Subscribe and also returns the retained events
+// published after the one with ID lastID that match filter, so a client
+// that reconnects misses nothing. ok is false if lastID is no longer
+// retained, in which case the client may have missed events.
+func Resume(lastID string, filter func(Event) bool) (sub *Subscription, missed []Event, ok bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	sub = &Subscription{ch: make(chan Event, subscriberBuffer), filter: filter}
+	subscriptions[sub] = struct{}{}
+
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].ID != lastID {
+			continue
+		}
+		for _, event := range history[i+1:] {
+			if filter == nil || filter(event) {
+				missed = append(missed, event)
+			}
+		}
+		return sub, missed, true
+	}
+	return sub, nil, false
+}
+
+// Events returns the channel delivering events; it is closed by Close
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close stops the subscription and closes its channel
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		mu.Lock()
+		delete(subscriptions, s)
+		mu.Unlock()
+		close(s.ch)
+	})
+}
+
+// New returns an event with a new ID, occurring now
+func New(eventType string, storeID, userID uint, data interface{}) Event {
+	id, err := utils.GenerateRandomString(16)
+	if err != nil {
+		id = ""
+	}
+	return Event{ID: id, Type: eventType, OccurredAt: time.Now(), Data: data, UserID: userID, StoreID: storeID}
+}
+
+// Message returns the outbox message relaying the event to the bus
+func (e Event) Message() (models.OutboxMessage, error) {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return models.OutboxMessage{}, err
+	}
+	return models.OutboxMessage{
+		StoreID:       e.StoreID,
+		EventID:       e.ID,
+		Type:          e.Type,
+		UserID:        e.UserID,
+		Data:          string(data),
+		OccurredAt:    e.OccurredAt,
+		NextAttemptAt: e.OccurredAt,
+	}, nil
+}
+
+// FromMessage returns the event relayed by an outbox message, with its
+// data as raw JSON
+func FromMessage(m models.OutboxMessage) Event {
+	return Event{
+		ID:         m.EventID,
+		Type:       m.Type,
+		OccurredAt: m.OccurredAt,
+		Data:       json.RawMessage(m.Data),
+		UserID:     m.UserID,
+		StoreID:    m.StoreID,
+	}
+}
+
+// Dispatch delivers an event to every matching subscription without
+// blocking; subscribers that have fallen behind miss it
+func Dispatch(event Event) {
+	published.Add(event.Type, 1)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(history) == historySize {
+		history = append(history[:0], history[1:]...)
+	}
+	history = append(history, event)
+
+	for sub := range subscriptions {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			dropped.Add(1)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Package flags evaluates feature flags, so that features can be rolled out
+// to some users, a percentage of them or everyone, and switched off again,
+// without redeploying. Flags are stored per store and cached; Invalidate
+// must be called after changing them.
+package flags
+
+import (
+	"context"
+	"ecommerce-backend/cache"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/tenant"
+	"encoding/binary"
+	"encoding/json"
+	"hash/fnv"
+	"slices"
+	"strconv"
+)
+
+var (
+	store repository.Store
+	// flagCache holds the flags of each store
+	flagCache = cache.NewNamespace("flags")
+)
+
+// Init sets the store flags are loaded from. Until it is called, every
+// flag is off.
+func Init(s repository.Store) {
+	store = s
+}
+
+// Enabled reports whether the flag is on for the user; userID is 0 for
+// anonymous visitors. Unknown flags are off, and so is every flag while
+// the flags cannot be loaded.
+func Enabled(ctx context.Context, name string, userID uint) bool {
+	flags, err := load(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to load feature flags", "error", err)
+		return false
+	}
+	flag, ok := flags[name]
+	return ok && On(flag, userID)
+}
+
+// All returns whether each flag of the context's store is on for the user
+func All(ctx context.Context, userID uint) (map[string]bool, error) {
+	flags, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	on := make(map[string]bool, len(flags))
+	for name, flag := range flags {
+		on[name] = On(flag, userID)
+	}
+	return on, nil
+}
+
+// On reports whether the flag is on for the user. Each user falls in the
+// rollout percentage of some flags and not others, so the same users are
+// not always the first to get new features.
+func On(flag models.FeatureFlag, userID uint) bool {
+	switch {
+	case !flag.Enabled:
+		return false
+	case flag.Percentage >= 100:
+		return true
+	case userID == 0:
+		return false
+	case slices.Contains(flag.UserIDs, userID):
+		return true
+	}
+	return bucket(flag.Name, userID) < flag.Percentage
+}
+
+// Invalidate drops the cached flags, once they are changed
+func Invalidate(ctx context.Context) {
+	if err := flagCache.Invalidate(ctx); err != nil {
+		logging.FromContext(ctx).Error("failed to invalidate feature flag cache", "error", err)
+	}
+}
+
+// bucket places the user in one of 100 buckets of the flag
+func bucket(name string, userID uint) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(userID)))
+	return int(h.Sum32() % 100)
+}
+
+// load returns the flags of the context's store by name, from the cache
+// when it holds them
+func load(ctx context.Context) (map[string]models.FeatureFlag, error) {
+	if store == nil {
+		return nil, nil
+	}
+
+	var flags []models.FeatureFlag
+	key := strconv.FormatUint(uint64(tenant.StoreOrDefault(ctx)), 10)
+	body, found, err := flagCache.Get(ctx, key)
+	if err != nil || !found || json.Unmarshal(body, &flags) != nil {
+		if flags, err = store.Flags().List(ctx); err != nil {
+			return nil, err
+		}
+		if body, err := json.Marshal(flags); err == nil {
+			if err := flagCache.Set(ctx, key, body); err != nil {
+				logging.FromContext(ctx).Warn("failed to cache feature flags", "error", err)
+			}
+		}
+	}
+
+	byName := make(map[string]models.FeatureFlag, len(flags))
+	for _, flag := range flags {
+		byName[flag.Name] = flag
+	}
+	return byName, nil
+}
@@ -0,0 +1,70 @@
+package graph
+
+import (
+	"ecommerce-backend/graph/model"
+	"ecommerce-backend/models"
+	"ecommerce-backend/services"
+	"strconv"
+)
+
+func formatID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func toItem(item models.Item) *model.Item {
+	return &model.Item{
+		ID:          formatID(item.ID),
+		Name:        item.Name,
+		Description: item.Description,
+		Price:       item.Price,
+	}
+}
+
+func toCartItems(cartItems []models.CartItem) []*model.CartItem {
+	lines := make([]*model.CartItem, len(cartItems))
+	for i, ci := range cartItems {
+		lines[i] = &model.CartItem{Item: toItem(ci.Item), Quantity: ci.Quantity}
+	}
+	return lines
+}
+
+func toCart(cart models.Cart, pricing services.Pricing) *model.Cart {
+	result := &model.Cart{
+		ID:         formatID(cart.ID),
+		Items:      toCartItems(cart.CartItems),
+		Subtotal:   pricing.Subtotal,
+		Discount:   pricing.Discount,
+		Total:      pricing.Total,
+		Promotions: make([]*model.AppliedPromotion, len(pricing.Promotions)),
+	}
+	for i, p := range pricing.Promotions {
+		result.Promotions[i] = &model.AppliedPromotion{PromotionID: formatID(p.Promotion.ID), Name: p.Promotion.Name, Amount: p.Amount}
+	}
+	return result
+}
+
+func toOrder(order models.Order) *model.Order {
+	result := &model.Order{
+		ID:             formatID(order.ID),
+		Total:          order.Total,
+		ShippingCost:   order.ShippingCost,
+		Discount:       order.Discount,
+		GiftCardAmount: order.GiftCardAmount,
+		Status:         order.Status,
+		CreatedAt:      order.CreatedAt,
+		Items:          toCartItems(order.Cart.CartItems),
+		Promotions:     make([]*model.AppliedPromotion, len(order.Promotions)),
+	}
+	for i, p := range order.Promotions {
+		result.Promotions[i] = &model.AppliedPromotion{PromotionID: formatID(p.PromotionID), Name: p.Name, Amount: p.Amount}
+	}
+	return result
+}
+
+// cursorPtr returns nil for the empty cursor of the last page
+func cursorPtr(cursor string) *string {
+	if cursor == "" {
+		return nil
+	}
+	return &cursor
+}
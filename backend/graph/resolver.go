@@ -0,0 +1,8 @@
+package graph
+
+import "ecommerce-backend/services"
+
+// Resolver resolves GraphQL operations through the service layer
+type Resolver struct {
+	Services *services.Services
+}
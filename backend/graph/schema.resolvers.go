@@ -0,0 +1,168 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver
+// implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/graph/model"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services"
+	"errors"
+	"strconv"
+)
+
+// AddToCart is the resolver for the addToCart field.
+func (r *mutationResolver) AddToCart(ctx context.Context, itemID string, quantity int) (*model.Cart, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.ParseUint(itemID, 10, 64)
+	if err != nil {
+		return nil, apperrors.ErrItemNotFound
+	}
+	if quantity < 1 {
+		return nil, apperrors.Validation("quantity must be at least 1")
+	}
+
+	if _, err := r.Services.Carts.AddItem(ctx, user.ID, uint(id), quantity); err != nil {
+		return nil, err
+	}
+
+	cart, err := r.Services.Carts.OpenCart(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	pricing, err := r.Services.Promotions.Price(ctx, cart)
+	if err != nil {
+		return nil, err
+	}
+	return toCart(cart, pricing), nil
+}
+
+// Checkout is the resolver for the checkout field.
+func (r *mutationResolver) Checkout(ctx context.Context, shippingMethodID *string, giftCardCode *string) (*model.Order, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var methodID uint64
+	if shippingMethodID != nil {
+		if methodID, err = strconv.ParseUint(*shippingMethodID, 10, 64); err != nil {
+			return nil, apperrors.ErrShippingMethodNotFound
+		}
+	}
+
+	opts := services.CheckoutOptions{ShippingMethodID: uint(methodID)}
+	if giftCardCode != nil {
+		opts.GiftCardCode = *giftCardCode
+	}
+
+	order, err := r.Services.Orders.Checkout(ctx, user.ID, opts)
+	if err != nil {
+		return nil, err
+	}
+	return toOrder(order), nil
+}
+
+// Items is the resolver for the items field.
+func (r *queryResolver) Items(ctx context.Context, limit *int, cursor *string) (*model.ItemPage, error) {
+	page, err := pagination.FromArgs(limit, cursor, false)
+	if err != nil {
+		return nil, err
+	}
+
+	items, next, err := r.Services.Items.List(ctx, repository.ItemFilter{}, page)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.ItemPage{Items: make([]*model.Item, len(items)), NextCursor: cursorPtr(next)}
+	for i, item := range items {
+		result.Items[i] = toItem(item)
+	}
+	return result, nil
+}
+
+// Item is the resolver for the item field.
+func (r *queryResolver) Item(ctx context.Context, id string) (*model.Item, error) {
+	itemID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, nil
+	}
+
+	item, err := r.Services.Items.Get(ctx, uint(itemID))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrItemNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !item.IsActive {
+		return nil, nil
+	}
+	return toItem(item), nil
+}
+
+// Cart is the resolver for the cart field.
+func (r *queryResolver) Cart(ctx context.Context) (*model.Cart, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cart, err := r.Services.Carts.OpenCart(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrCartNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	pricing, err := r.Services.Promotions.Price(ctx, cart)
+	if err != nil {
+		return nil, err
+	}
+	return toCart(cart, pricing), nil
+}
+
+// Orders is the resolver for the orders field.
+func (r *queryResolver) Orders(ctx context.Context, limit *int, cursor *string) (*model.OrderPage, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := pagination.KeysetFromArgs(limit, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, cursors, err := r.Services.Orders.ListByUser(ctx, user.ID, repository.OrderFilter{}, page)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.OrderPage{Orders: make([]*model.Order, len(orders)), NextCursor: cursorPtr(cursors.Next)}
+	for i, order := range orders {
+		result.Orders[i] = toOrder(order)
+	}
+	return result, nil
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type (
+	mutationResolver struct{ *Resolver }
+	queryResolver    struct{ *Resolver }
+)
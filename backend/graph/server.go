@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/services"
+	"errors"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// complexityLimit bounds the cost of a single query, roughly the number of
+// fields it resolves
+const complexityLimit = 500
+
+type userKey struct{}
+
+// WithUser returns a context carrying the authenticated user
+func WithUser(ctx context.Context, user models.User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// currentUser returns the authenticated user, or ErrUnauthorized
+func currentUser(ctx context.Context) (models.User, error) {
+	user, ok := ctx.Value(userKey{}).(models.User)
+	if !ok {
+		return models.User{}, apperrors.ErrUnauthorized
+	}
+	return user, nil
+}
+
+// NewServer returns an HTTP handler serving GraphQL over GET and POST
+func NewServer(svc *services.Services) http.Handler {
+	srv := handler.New(NewExecutableSchema(Config{Resolvers: &Resolver{Services: svc}}))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.FixedComplexityLimit(complexityLimit))
+	srv.SetErrorPresenter(presentError)
+	return srv
+}
+
+// presentError reports application errors with their code in the
+// extensions, matching the REST error envelope. Causes of internal errors
+// are logged and never shown to clients.
+func presentError(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) {
+		var parseErr *gqlerror.Error
+		if errors.As(err, &parseErr) && parseErr.Err == nil {
+			// Validation and parse errors from gqlgen itself
+			return gqlErr
+		}
+		appErr = apperrors.From(err)
+	}
+
+	if appErr.Status >= http.StatusInternalServerError {
+		logging.FromContext(ctx).Error("graphql resolver failed", "path", gqlErr.Path.String(), "error", err)
+	}
+	gqlErr.Message = appErr.Message
+	gqlErr.Extensions = map[string]interface{}{"code": appErr.Code}
+	if appErr.Details != nil {
+		gqlErr.Extensions["details"] = appErr.Details
+	}
+	return gqlErr
+}
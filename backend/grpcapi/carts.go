@@ -0,0 +1,41 @@
+package grpcapi
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	pb "ecommerce-backend/proto/ecommerce/v1"
+	"ecommerce-backend/services"
+)
+
+type cartServer struct {
+	pb.UnimplementedCartServiceServer
+	svc *services.Services
+}
+
+func (s *cartServer) GetCart(ctx context.Context, req *pb.GetCartRequest) (*pb.Cart, error) {
+	cart, err := s.svc.Carts.OpenCart(ctx, uint(req.GetUserId()))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toCart(cart), nil
+}
+
+func (s *cartServer) AddToCart(ctx context.Context, req *pb.AddToCartRequest) (*pb.Cart, error) {
+	if req.GetUserId() == 0 {
+		return nil, toStatus(ctx, apperrors.Validation("user_id is required"))
+	}
+	if req.GetQuantity() < 1 {
+		return nil, toStatus(ctx, apperrors.Validation("quantity must be at least 1"))
+	}
+
+	userID := uint(req.GetUserId())
+	if _, err := s.svc.Carts.AddItem(ctx, userID, uint(req.GetItemId()), int(req.GetQuantity())); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	cart, err := s.svc.Carts.OpenCart(ctx, userID)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toCart(cart), nil
+}
@@ -0,0 +1,39 @@
+package grpcapi
+
+import (
+	"context"
+	pb "ecommerce-backend/proto/ecommerce/v1"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services"
+)
+
+type catalogServer struct {
+	pb.UnimplementedCatalogServiceServer
+	svc *services.Services
+}
+
+func (s *catalogServer) ListItems(ctx context.Context, req *pb.ListItemsRequest) (*pb.ListItemsResponse, error) {
+	p, err := page(req.GetPageSize(), req.GetPageToken(), false)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	items, next, err := s.svc.Items.List(ctx, repository.ItemFilter{}, p)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	resp := &pb.ListItemsResponse{Items: make([]*pb.Item, len(items)), NextPageToken: next}
+	for i, item := range items {
+		resp.Items[i] = toItem(item)
+	}
+	return resp, nil
+}
+
+func (s *catalogServer) GetItem(ctx context.Context, req *pb.GetItemRequest) (*pb.Item, error) {
+	item, err := s.svc.Items.Get(ctx, uint(req.GetId()))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toItem(item), nil
+}
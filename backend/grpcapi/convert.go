@@ -0,0 +1,67 @@
+package grpcapi
+
+import (
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	pb "ecommerce-backend/proto/ecommerce/v1"
+	"ecommerce-backend/services"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// page builds a page from the page_size and page_token request fields
+func page(size int32, token string, desc bool) (pagination.Page, error) {
+	var limit *int
+	if size > 0 {
+		n := int(size)
+		limit = &n
+	}
+	return pagination.FromArgs(limit, &token, desc)
+}
+
+// keyset is page for lists paged newest first by creation time
+func keyset(size int32, token string) (pagination.Keyset, error) {
+	var limit *int
+	if size > 0 {
+		n := int(size)
+		limit = &n
+	}
+	return pagination.KeysetFromArgs(limit, &token)
+}
+
+func toItem(item models.Item) *pb.Item {
+	return &pb.Item{
+		Id:          uint64(item.ID),
+		Name:        item.Name,
+		Description: item.Description,
+		Price:       item.Price,
+	}
+}
+
+func toCartItems(cartItems []models.CartItem) []*pb.CartItem {
+	lines := make([]*pb.CartItem, len(cartItems))
+	for i, ci := range cartItems {
+		lines[i] = &pb.CartItem{Item: toItem(ci.Item), Quantity: int32(ci.Quantity)}
+	}
+	return lines
+}
+
+func toCart(cart models.Cart) *pb.Cart {
+	return &pb.Cart{
+		Id:     uint64(cart.ID),
+		UserId: uint64(cart.UserID),
+		Items:  toCartItems(cart.CartItems),
+		Total:  services.Total(cart),
+	}
+}
+
+func toOrder(order models.Order) *pb.Order {
+	return &pb.Order{
+		Id:        uint64(order.ID),
+		UserId:    uint64(order.UserID),
+		Total:     order.Total,
+		Status:    order.Status,
+		CreatedAt: timestamppb.New(order.CreatedAt),
+		Items:     toCartItems(order.Cart.CartItems),
+	}
+}
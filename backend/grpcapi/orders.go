@@ -0,0 +1,48 @@
+package grpcapi
+
+import (
+	"context"
+	"ecommerce-backend/models"
+	pb "ecommerce-backend/proto/ecommerce/v1"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services"
+)
+
+type orderServer struct {
+	pb.UnimplementedOrderServiceServer
+	svc *services.Services
+}
+
+func (s *orderServer) ListOrders(ctx context.Context, req *pb.ListOrdersRequest) (*pb.ListOrdersResponse, error) {
+	resp := &pb.ListOrdersResponse{}
+	if userID := uint(req.GetUserId()); userID != 0 {
+		k, err := keyset(req.GetPageSize(), req.GetPageToken())
+		if err != nil {
+			return nil, toStatus(ctx, err)
+		}
+		orders, cursors, err := s.svc.Orders.ListByUser(ctx, userID, repository.OrderFilter{}, k)
+		if err != nil {
+			return nil, toStatus(ctx, err)
+		}
+		for _, order := range orders {
+			resp.Orders = append(resp.Orders, toOrder(order))
+		}
+		resp.NextPageToken = cursors.Next
+		return resp, nil
+	}
+
+	p, err := page(req.GetPageSize(), req.GetPageToken(), false)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	next, err := s.svc.Orders.Each(ctx, repository.OrderFilter{}, p, func(order *models.Order) error {
+		resp.Orders = append(resp.Orders, toOrder(*order))
+		return nil
+	})
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	resp.NextPageToken = next
+	return resp, nil
+}
@@ -0,0 +1,134 @@
+// Package grpcapi serves the internal gRPC API defined in
+// proto/ecommerce/v1 on top of the same services as the HTTP handlers.
+package grpcapi
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/database"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	pb "ecommerce-backend/proto/ecommerce/v1"
+	"ecommerce-backend/services"
+	"ecommerce-backend/tenant"
+	"ecommerce-backend/utils"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// apiKeyMetadata is the metadata key carrying the caller's API key
+const apiKeyMetadata = "x-api-key"
+
+// NewServer returns a gRPC server with the catalog, cart and order services
+// registered. Every call is authenticated, logged and recovered from panics.
+func NewServer(svc *services.Services) *grpc.Server {
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(logCalls, recoverPanics, authenticate))
+	pb.RegisterCatalogServiceServer(srv, &catalogServer{svc: svc})
+	pb.RegisterCartServiceServer(srv, &cartServer{svc: svc})
+	pb.RegisterOrderServiceServer(srv, &orderServer{svc: svc})
+	return srv
+}
+
+// authenticate requires a live (non-sandbox) API key owned by an admin,
+// since internal services act on behalf of any user of the key's store
+func authenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(apiKeyMetadata)
+	if len(keys) == 0 || keys[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "x-api-key metadata is required")
+	}
+
+	var apiKey models.APIKey
+	err := database.WithContext(ctx).Where("key_hash = ?", utils.HashAPIKey(keys[0])).First(&apiKey).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, status.Error(codes.Unauthenticated, apperrors.ErrInvalidAPIKey.Message)
+		}
+		return nil, toStatus(ctx, apperrors.Internal("failed to verify api key", err))
+	}
+
+	var owner models.User
+	if err := database.WithContext(ctx).Select("id, role").First(&owner, apiKey.UserID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, toStatus(ctx, apperrors.Internal("failed to verify api key", err))
+	}
+	if apiKey.Sandbox || owner.Role != models.RoleAdmin {
+		return nil, status.Error(codes.PermissionDenied, "a live API key issued by an admin is required")
+	}
+
+	if _, _, err := utils.ConsumeAPIKeyRequest(ctx, apiKey, time.Now()); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	database.WithContext(ctx).Model(&apiKey).Update("last_used_at", time.Now())
+
+	// The call acts in the store that issued the key
+	return handler(tenant.WithStore(ctx, apiKey.StoreID), req)
+}
+
+// logCalls logs every call with its outcome, like RequestLogger does for HTTP
+func logCalls(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch {
+	case code == codes.Internal || code == codes.Unknown:
+		level = slog.LevelError
+	case code != codes.OK:
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "grpc call", "method", info.FullMethod, "code", code.String(),
+		"latency_ms", time.Since(start).Milliseconds())
+	return resp, err
+}
+
+// recoverPanics turns a panicking handler into an INTERNAL error
+func recoverPanics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "panic recovered", "method", info.FullMethod, "panic", r, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, apperrors.ErrInternal.Message)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// toStatus converts a service error to a gRPC status. Internal causes are
+// logged and never sent to the caller.
+func toStatus(ctx context.Context, err error) error {
+	appErr := apperrors.From(err)
+
+	code := codes.Internal
+	switch {
+	case errors.Is(appErr, apperrors.ErrCartNotFound):
+		code = codes.NotFound
+	case errors.Is(appErr, apperrors.ErrCartEmpty):
+		code = codes.FailedPrecondition
+	case appErr.Status == http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case appErr.Status == http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case appErr.Status == http.StatusForbidden:
+		code = codes.PermissionDenied
+	case appErr.Status == http.StatusNotFound:
+		code = codes.NotFound
+	case appErr.Status == http.StatusConflict:
+		code = codes.Aborted
+	case appErr.Status == http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	}
+
+	if code == codes.Internal {
+		logging.FromContext(ctx).Error("grpc call failed", "error", err)
+	}
+	return status.Error(code, appErr.Message)
+}
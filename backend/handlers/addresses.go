@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAddressRequest struct {
+	Name       string `json:"name" binding:"required,max=255"`
+	Line1      string `json:"line1" binding:"required,max=255"`
+	Line2      string `json:"line2" binding:"max=255"`
+	City       string `json:"city" binding:"required,max=100"`
+	Region     string `json:"region" binding:"max=100"`
+	PostalCode string `json:"postal_code" binding:"required,max=20"`
+	// Country is an ISO 3166-1 alpha-2 code, such as US
+	Country string `json:"country" binding:"required,len=2,alpha"`
+}
+
+// CreateAddress saves a shipping address for the current user, validated
+// and normalized by the address provider
+func CreateAddress(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var req CreateAddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	address, err := svc.Addresses.Create(c.Request.Context(), currentUser.ID, models.PostalAddress{
+		Name:       req.Name,
+		Line1:      req.Line1,
+		Line2:      req.Line2,
+		City:       req.City,
+		Region:     req.Region,
+		PostalCode: req.PostalCode,
+		Country:    req.Country,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, addressResponse(address))
+}
+
+// GetAddresses lists the current user's saved addresses
+func GetAddresses(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	addresses, err := svc.Addresses.List(c.Request.Context(), currentUser.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := AddressesResponse{Addresses: []AddressResponse{}}
+	for _, address := range addresses {
+		response.Addresses = append(response.Addresses, addressResponse(address))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteAddress removes one of the current user's saved addresses
+func DeleteAddress(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrAddressNotFound)
+		return
+	}
+
+	if err := svc.Addresses.Delete(c.Request.Context(), currentUser.ID, uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func addressResponse(address models.Address) AddressResponse {
+	return AddressResponse{
+		ID:            address.ID,
+		PostalAddress: address.PostalAddress,
+		Status:        address.Status,
+		StatusReason:  address.StatusReason,
+		CreatedAt:     address.CreatedAt,
+	}
+}
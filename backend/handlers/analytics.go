@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"ecommerce-backend/analytics"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/database"
+	"encoding/csv"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAnalyticsDays = 30
+	// maxAnalyticsRange bounds the date range of a report
+	maxAnalyticsRange = 2 * 366 * 24 * time.Hour
+
+	defaultTopItems = 10
+	maxTopItems     = 100
+)
+
+// lookbacks are the periods accepted by the top and trending item reports
+var lookbacks = map[string]time.Duration{
+	analytics.Day:   24 * time.Hour,
+	analytics.Week:  7 * 24 * time.Hour,
+	analytics.Month: 30 * 24 * time.Hour,
+}
+
+// GetRevenue reports revenue and order counts per day, week or month
+// (admin only)
+func GetRevenue(c *gin.Context) {
+	r, ok := analyticsRange(c)
+	if !ok {
+		return
+	}
+
+	period := c.DefaultQuery("period", analytics.Day)
+	if period != analytics.Day && period != analytics.Week && period != analytics.Month {
+		c.Error(apperrors.Validation("period must be day, week or month"))
+		return
+	}
+
+	buckets, err := analytics.Revenue(database.WithContext(c.Request.Context()), period, r)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to compute revenue", err))
+		return
+	}
+
+	if wantsCSV(c) {
+		rows := [][]string{{"start", "orders", "revenue"}}
+		for _, b := range buckets {
+			rows = append(rows, []string{b.Start, strconv.FormatInt(b.Orders, 10), formatAmount(b.Revenue)})
+		}
+		writeCSV(c, "revenue.csv", rows)
+		return
+	}
+
+	if buckets == nil {
+		buckets = []analytics.RevenueBucket{}
+	}
+	c.JSON(http.StatusOK, RevenueResponse{Period: period, From: r.From, To: r.To, Buckets: buckets})
+}
+
+// GetOrderStatusCounts reports the number of orders by status (admin only)
+func GetOrderStatusCounts(c *gin.Context) {
+	r, ok := analyticsRange(c)
+	if !ok {
+		return
+	}
+
+	counts, err := analytics.StatusCounts(database.WithContext(c.Request.Context()), r)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to count orders", err))
+		return
+	}
+
+	if wantsCSV(c) {
+		rows := [][]string{{"status", "orders"}}
+		for _, sc := range counts {
+			rows = append(rows, []string{sc.Status, strconv.FormatInt(sc.Orders, 10)})
+		}
+		writeCSV(c, "order-status.csv", rows)
+		return
+	}
+
+	if counts == nil {
+		counts = []analytics.StatusCount{}
+	}
+	c.JSON(http.StatusOK, OrderStatusCountsResponse{From: r.From, To: r.To, Statuses: counts})
+}
+
+// GetSalesSummary reports the order count, revenue and average order value
+// (admin only)
+func GetSalesSummary(c *gin.Context) {
+	r, ok := analyticsRange(c)
+	if !ok {
+		return
+	}
+
+	summary, err := analytics.Summarize(database.WithContext(c.Request.Context()), r)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to compute sales summary", err))
+		return
+	}
+
+	if wantsCSV(c) {
+		writeCSV(c, "sales-summary.csv", [][]string{
+			{"from", "to", "orders", "revenue", "average_order_value"},
+			{r.From.Format(time.RFC3339), r.To.Format(time.RFC3339), strconv.FormatInt(summary.Orders, 10),
+				formatAmount(summary.Revenue), formatAmount(summary.AverageOrderValue)},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SalesSummaryResponse{
+		From:              r.From,
+		To:                r.To,
+		Orders:            summary.Orders,
+		Revenue:           summary.Revenue,
+		AverageOrderValue: summary.AverageOrderValue,
+	})
+}
+
+// GetConversionFunnel reports how many of the carts created in the range
+// went on to hold items, start a checkout and become orders (admin only)
+func GetConversionFunnel(c *gin.Context) {
+	r, ok := analyticsRange(c)
+	if !ok {
+		return
+	}
+
+	funnel, err := analytics.ConversionFunnel(database.WithContext(c.Request.Context()), r)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to compute conversion funnel", err))
+		return
+	}
+
+	response := ConversionFunnelResponse{
+		From:           r.From,
+		To:             r.To,
+		ConversionRate: conversionRate(funnel.OrdersCompleted, funnel.CartsCreated),
+	}
+	previous := funnel.CartsCreated
+	for _, stage := range []struct {
+		name  string
+		carts int64
+	}{
+		{"carts_created", funnel.CartsCreated},
+		{"carts_with_items", funnel.CartsWithItems},
+		{"checkouts_started", funnel.CheckoutsStarted},
+		{"orders_completed", funnel.OrdersCompleted},
+	} {
+		response.Stages = append(response.Stages, FunnelStageResponse{
+			Stage: stage.name,
+			Carts: stage.carts,
+			Rate:  conversionRate(stage.carts, previous),
+		})
+		previous = stage.carts
+	}
+
+	if wantsCSV(c) {
+		rows := [][]string{{"stage", "carts", "rate"}}
+		for _, stage := range response.Stages {
+			rows = append(rows, []string{stage.Stage, strconv.FormatInt(stage.Carts, 10), strconv.FormatFloat(stage.Rate, 'f', 4, 64)})
+		}
+		writeCSV(c, "conversion-funnel.csv", rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// conversionRate returns the share of of total, to four decimals, or 0
+// if total is 0
+func conversionRate(of, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(of)/float64(total)*10000) / 10000
+}
+
+// GetTopItems reports the best-selling items over the last day, week or
+// month (admin only)
+func GetTopItems(c *gin.Context) {
+	period := c.DefaultQuery("period", analytics.Week)
+	window, ok := lookbacks[period]
+	if !ok {
+		c.Error(apperrors.Validation("period must be day, week or month"))
+		return
+	}
+
+	limit := defaultTopItems
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = min(v, maxTopItems)
+	}
+
+	now := time.Now().UTC()
+	r := analytics.Range{From: now.Add(-window), To: now}
+	top, err := analytics.TopItems(database.WithContext(c.Request.Context()), r, limit)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to compute top items", err))
+		return
+	}
+
+	if wantsCSV(c) {
+		rows := [][]string{{"item_id", "name", "quantity", "orders", "revenue"}}
+		for _, t := range top {
+			rows = append(rows, []string{strconv.FormatUint(uint64(t.ItemID), 10), t.Name,
+				strconv.FormatInt(t.Quantity, 10), strconv.FormatInt(t.Orders, 10), formatAmount(t.Revenue)})
+		}
+		writeCSV(c, "top-items.csv", rows)
+		return
+	}
+
+	if top == nil {
+		top = []analytics.TopItem{}
+	}
+	c.JSON(http.StatusOK, TopItemsResponse{Period: period, From: r.From, To: r.To, Items: top})
+}
+
+// analyticsRange parses the from and to query parameters, as RFC 3339 times
+// or YYYY-MM-DD dates in UTC. A date for to includes that whole day. The
+// range defaults to the last 30 days. It writes the error and returns false
+// if the range is invalid.
+func analyticsRange(c *gin.Context) (analytics.Range, bool) {
+	now := time.Now().UTC()
+	r := analytics.Range{From: now.AddDate(0, 0, -defaultAnalyticsDays), To: now}
+
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &r.From}, {"to", &r.To}} {
+		value := c.Query(p.name)
+		if value == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			*p.dst = t
+			continue
+		}
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.Error(apperrors.Validation("invalid " + p.name + ", expected an RFC 3339 time or YYYY-MM-DD date"))
+			return r, false
+		}
+		if p.name == "to" {
+			t = t.AddDate(0, 0, 1)
+		}
+		*p.dst = t
+	}
+
+	if !r.From.Before(r.To) {
+		c.Error(apperrors.Validation("from must be before to"))
+		return r, false
+	}
+	if r.To.Sub(r.From) > maxAnalyticsRange {
+		c.Error(apperrors.Validation("date range must not exceed two years"))
+		return r, false
+	}
+	return r, true
+}
+
+// wantsCSV reports whether the client asked for CSV with format=csv
+func wantsCSV(c *gin.Context) bool {
+	return c.Query("format") == "csv"
+}
+
+// writeCSV sends rows as a CSV attachment
+func writeCSV(c *gin.Context, filename string, rows [][]string) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.WriteAll(rows)
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/database"
+	"ecommerce-backend/models"
+	"ecommerce-backend/utils"
+	"ecommerce-backend/webhooks"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateAPIKeyRequest struct {
+	Name       string `json:"name" binding:"required"`
+	Sandbox    *bool  `json:"sandbox"`
+	WebhookURL string `json:"webhook_url" binding:"omitempty,url"`
+}
+
+// CreateAPIKey issues a new API key for the current user. The raw key is
+// only returned once; only its hash is stored. Only admins may issue live
+// keys, and webhooks may only go to public https endpoints.
+func CreateAPIKey(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	// Keys are sandbox keys unless explicitly requested otherwise
+	sandbox := req.Sandbox == nil || *req.Sandbox
+	if !sandbox && currentUser.Role != models.RoleAdmin {
+		c.Error(apperrors.ErrForbidden)
+		return
+	}
+	if req.WebhookURL != "" {
+		if err := webhooks.ValidateURL(c.Request.Context(), req.WebhookURL); err != nil {
+			c.Error(apperrors.Validation(err.Error()))
+			return
+		}
+	}
+
+	key, prefix, hash, err := utils.GenerateAPIKey(sandbox)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to generate api key", err))
+		return
+	}
+	secret, err := utils.GenerateWebhookSecret()
+	if err != nil {
+		c.Error(apperrors.Internal("failed to generate api key", err))
+		return
+	}
+
+	apiKey := models.APIKey{
+		Name:          req.Name,
+		Prefix:        prefix,
+		KeyHash:       hash,
+		UserID:        currentUser.ID,
+		Sandbox:       sandbox,
+		WebhookURL:    req.WebhookURL,
+		WebhookSecret: secret,
+	}
+
+	err = database.WithTx(c.Request.Context(), func(tx *gorm.DB) error {
+		if err := tx.Create(&apiKey).Error; err != nil {
+			return err
+		}
+		// GORM inserts the column default (true) in place of false, so live
+		// keys need their flag cleared explicitly
+		if !sandbox {
+			return tx.Model(&apiKey).Update("sandbox", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.Error(apperrors.Internal("failed to create api key", err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{
+		Message:       "api key created successfully",
+		ID:            apiKey.ID,
+		Key:           key,
+		Prefix:        prefix,
+		Sandbox:       sandbox,
+		WebhookSecret: secret,
+	})
+}
+
+// RotateWebhookSecret replaces the webhook secret of one of the current
+// user's API keys and returns the new one. Webhooks are signed with the new
+// secret from then on.
+func RotateWebhookSecret(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrNotFound)
+		return
+	}
+
+	secret, err := utils.GenerateWebhookSecret()
+	if err != nil {
+		c.Error(apperrors.Internal("failed to rotate webhook secret", err))
+		return
+	}
+
+	result := database.WithContext(c.Request.Context()).Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ?", id, currentUser.ID).
+		Update("webhook_secret", secret)
+	if result.Error != nil {
+		c.Error(apperrors.Internal("failed to rotate webhook secret", result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(apperrors.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebhookSecretResponse{
+		Message:       "webhook secret rotated",
+		WebhookSecret: secret,
+	})
+}
+
+type SetAPIKeyQuotasRequest struct {
+	// DailyQuota and MonthlyQuota are null for the configured defaults and
+	// 0 for no limit
+	DailyQuota   *int `json:"daily_quota" binding:"omitempty,min=0"`
+	MonthlyQuota *int `json:"monthly_quota" binding:"omitempty,min=0"`
+}
+
+// GetAPIKeyUsage returns how much of its quotas one of the current user's
+// API keys has used, with its requests on each day of the month
+func GetAPIKeyUsage(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrNotFound)
+		return
+	}
+
+	var apiKey models.APIKey
+	err = database.WithContext(c.Request.Context()).Where("id = ? AND user_id = ?", id, currentUser.ID).First(&apiKey).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.Error(apperrors.ErrNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(apperrors.Internal("failed to fetch api key", err))
+		return
+	}
+
+	writeAPIKeyUsage(c, apiKey)
+}
+
+// GetSandboxUsage returns the usage of the API key making the request,
+// without counting the request towards its quotas
+func GetSandboxUsage(c *gin.Context) {
+	writeAPIKeyUsage(c, c.MustGet("api_key").(models.APIKey))
+}
+
+func writeAPIKeyUsage(c *gin.Context, apiKey models.APIKey) {
+	now := time.Now()
+	daily, monthly, err := utils.APIKeyUsage(c.Request.Context(), apiKey, now)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to fetch api key usage", err))
+		return
+	}
+	days, err := utils.APIKeyUsageDays(c.Request.Context(), apiKey.ID, now)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to fetch api key usage", err))
+		return
+	}
+
+	response := APIKeyUsageResponse{
+		APIKeyID: apiKey.ID,
+		Daily:    quotaResponse(daily),
+		Monthly:  quotaResponse(monthly),
+		Days:     make([]APIKeyUsageDayResponse, len(days)),
+	}
+	for i, day := range days {
+		response.Days[i] = APIKeyUsageDayResponse{Date: day.Day, Requests: day.Requests, Rejected: day.Rejected}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func quotaResponse(usage utils.QuotaUsage) QuotaResponse {
+	response := QuotaResponse{Used: usage.Used, ResetsAt: usage.ResetsAt}
+	if usage.Limit > 0 {
+		limit, remaining := usage.Limit, usage.Remaining()
+		response.Limit, response.Remaining = &limit, &remaining
+	}
+	return response
+}
+
+// SetAPIKeyQuotas sets the daily and monthly request quotas of an API key
+// (admin only)
+func SetAPIKeyQuotas(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrNotFound)
+		return
+	}
+
+	var req SetAPIKeyQuotasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	result := database.WithContext(c.Request.Context()).Model(&models.APIKey{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"daily_quota": req.DailyQuota, "monthly_quota": req.MonthlyQuota})
+	if result.Error != nil {
+		c.Error(apperrors.Internal("failed to set api key quotas", result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.Error(apperrors.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, APIKeyQuotasResponse{ID: uint(id), DailyQuota: req.DailyQuota, MonthlyQuota: req.MonthlyQuota})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/database"
+	"ecommerce-backend/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLogLimit = 100
+	maxAuditLogLimit     = 500
+)
+
+// GetAuditLogs returns audit records, newest first (admin only). Supports
+// filtering by route, user_id, impersonator_id and an RFC 3339 from/to time
+// range.
+func GetAuditLogs(c *gin.Context) {
+	query := database.WithContext(c.Request.Context()).Model(&models.AuditLog{})
+
+	if route := c.Query("route"); route != "" {
+		query = query.Where("route = ?", route)
+	}
+
+	for _, param := range []string{"user_id", "impersonator_id"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.Error(apperrors.Validation("invalid " + param))
+			return
+		}
+		query = query.Where(param+" = ?", id)
+	}
+
+	for param, op := range map[string]string{"from": ">=", "to": "<="} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.Error(apperrors.Validation("invalid " + param + " time, expected RFC 3339"))
+			return
+		}
+		query = query.Where("created_at "+op+" ?", t)
+	}
+
+	limit := defaultAuditLogLimit
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	if limit > maxAuditLogLimit {
+		limit = maxAuditLogLimit
+	}
+
+	var logs []models.AuditLog
+	if err := query.Order("created_at DESC").Limit(limit).Find(&logs).Error; err != nil {
+		c.Error(apperrors.Internal("failed to fetch audit logs", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, AuditLogsResponse{AuditLogs: logs})
+}
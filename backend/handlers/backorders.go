@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SetBackorderRequest struct {
+	// Backorder is backorder or preorder to sell the item beyond its
+	// stock, or empty to stop
+	Backorder  string     `json:"backorder" binding:"omitempty,oneof=backorder preorder"`
+	ExpectedAt *time.Time `json:"expected_at"`
+}
+
+// SetBackorder lets an item sell beyond its stock, or stops it (admin, or
+// the vendor selling the item)
+func SetBackorder(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrItemNotFound)
+		return
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var req SetBackorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	item, err := svc.Items.SetBackorder(c.Request.Context(), currentUser, uint(id), version, req.Backorder, req.ExpectedAt)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	renderItem(c, item)
+}
+
+// GetBackorders lists the backorders not yet fulfilled, oldest first,
+// optionally of one item_id (admin only)
+func GetBackorders(c *gin.Context) {
+	var itemID uint64
+	if raw := c.Query("item_id"); raw != "" {
+		var err error
+		if itemID, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			c.Error(apperrors.Validation("item_id must be a positive integer"))
+			return
+		}
+	}
+
+	backorders, err := svc.Orders.Backorders(c.Request.Context(), uint(itemID))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := BackordersResponse{Backorders: []BackorderResponse{}}
+	for _, b := range backorders {
+		response.Backorders = append(response.Backorders, backorderResponse(b))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// FulfillBackorder takes a backorder's units from stock once the item is
+// in, so the rest of the order can ship (admin only)
+func FulfillBackorder(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrBackorderNotFound)
+		return
+	}
+
+	backorder, err := svc.Orders.FulfillBackorder(c.Request.Context(), currentUser, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	c.JSON(http.StatusOK, backorderResponse(backorder))
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateBundleRequest struct {
+	Name        string  `json:"name" binding:"required,max=255"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price" binding:"required,gt=0"`
+	// Items are the items in one unit of the bundle; each item may only
+	// be listed once
+	Items []BundleItemRequest `json:"items" binding:"required,min=2,dive"`
+}
+
+type BundleItemRequest struct {
+	ItemID   uint `json:"item_id" binding:"required"`
+	Quantity int  `json:"quantity" binding:"required,min=1"`
+}
+
+// CreateBundle adds a bundle of items to the store (admin only)
+func CreateBundle(c *gin.Context) {
+	var req CreateBundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	bundle := models.Bundle{Name: req.Name, Description: req.Description, Price: req.Price}
+	seen := map[uint]bool{}
+	for _, bi := range req.Items {
+		if seen[bi.ItemID] {
+			c.Error(apperrors.Validation("each item may only be listed once in a bundle"))
+			return
+		}
+		seen[bi.ItemID] = true
+		bundle.Items = append(bundle.Items, models.BundleItem{ItemID: bi.ItemID, Quantity: bi.Quantity})
+	}
+
+	if err := svc.Bundles.Create(c.Request.Context(), &bundle); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, BundleResponse{Bundle: bundleResponse(bundle)})
+}
+
+// GetBundles lists the store's bundles
+func GetBundles(c *gin.Context) {
+	bundles, err := svc.Bundles.List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := BundlesResponse{Bundles: []BundleDetailResponse{}}
+	for _, bundle := range bundles {
+		response.Bundles = append(response.Bundles, bundleResponse(bundle))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetBundle returns a bundle with its items
+func GetBundle(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrBundleNotFound)
+		return
+	}
+
+	bundle, err := svc.Bundles.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, BundleResponse{Bundle: bundleResponse(bundle)})
+}
+
+// DeleteBundle removes a bundle (admin only)
+func DeleteBundle(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrBundleNotFound)
+		return
+	}
+
+	if err := svc.Bundles.Delete(c.Request.Context(), uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,109 +1,129 @@
 package handlers
 
 import (
-	"ecommerce-backend/database"
+	"ecommerce-backend/apperrors"
 	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"errors"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 type AddToCartRequest struct {
-	ItemID   uint `json:"item_id" binding:"required"`
-	Quantity int  `json:"quantity" binding:"required,min=1"`
+	// ItemID or BundleID is the item or bundle to add
+	ItemID   uint  `json:"item_id"`
+	BundleID *uint `json:"bundle_id"`
+	Quantity int   `json:"quantity" binding:"required,min=1"`
+	// Expand adds a bundle's items as separate items at their own prices,
+	// rather than as a unit at the bundle price
+	Expand bool `json:"expand"`
 }
 
-// AddToCart adds an item to the user's cart or updates the quantity if already exists
+// AddToCart adds an item, or units of a bundle, to the user's cart or
+// updates the quantity if already exists
 func AddToCart(c *gin.Context) {
 	user, _ := c.Get("user")
 	currentUser := user.(models.User)
 
 	var req AddToCartRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperrors.Binding(err))
 		return
 	}
 
-	// Start transaction
-	tx := database.GetDB().Begin()
+	if (req.ItemID == 0) == (req.BundleID == nil) {
+		c.Error(apperrors.Validation("exactly one of item_id and bundle_id is required"))
+		return
+	}
 
-	// Get or create user's active cart
 	var cart models.Cart
-	result := tx.Where("user_id = ? AND is_checked_out = ?", currentUser.ID, false).FirstOrCreate(&cart, models.Cart{
-		UserID:       currentUser.ID,
-		IsCheckedOut: false,
+	var err error
+	if req.BundleID != nil {
+		cart, err = svc.Carts.AddBundle(c.Request.Context(), currentUser.ID, *req.BundleID, req.Quantity, req.Expand)
+	} else {
+		cart, err = svc.Carts.AddItem(c.Request.Context(), currentUser.ID, req.ItemID, req.Quantity)
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, AddToCartResponse{
+		Message: "item added to cart successfully",
+		CartID:  cart.ID,
 	})
+}
 
-	if result.Error != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get or create cart"})
+// ShareCart returns a signed link through which other users can import the
+// current user's cart
+func ShareCart(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	link, err := svc.Carts.Share(c.Request.Context(), currentUser.ID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Check if item exists
-	var item models.Item
-	if err := tx.First(&item, req.ItemID).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "item not found"})
+	c.JSON(http.StatusOK, CartShareResponse{URL: link.URL, ExpiresAt: link.ExpiresAt})
+}
+
+// ImportCart adds the items of a cart shared through a signed link to the
+// current user's cart
+func ImportCart(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrCartShareInvalid)
 		return
 	}
-
-	// Add item to cart or update quantity
-	var cartItem models.CartItem
-	if err := tx.Where("cart_id = ? AND item_id = ?", cart.ID, req.ItemID).First(&cartItem).Error; err == nil {
-		// Item already in cart, update quantity
-		cartItem.Quantity += req.Quantity
-		if err := tx.Save(&cartItem).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update cart"})
-			return
-		}
-	} else if err == gorm.ErrRecordNotFound {
-		// Item not in cart, add new item
-		cartItem = models.CartItem{
-			CartID:   cart.ID,
-			ItemID:   req.ItemID,
-			Quantity: req.Quantity,
-		}
-		if err := tx.Create(&cartItem).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add item to cart"})
-			return
-		}
-	} else {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process cart"})
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrCartShareInvalid)
 		return
 	}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update cart"})
+	cart, err := svc.Carts.Import(c.Request.Context(), currentUser.ID, uint(id), expires, c.Query("signature"))
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "item added to cart successfully",
-		"cart_id": cart.ID,
+	c.JSON(http.StatusOK, AddToCartResponse{
+		Message: "cart imported successfully",
+		CartID:  cart.ID,
 	})
 }
 
-// GetCarts returns all carts (admin only)
+// GetCarts streams a page of carts (admin only)
 func GetCarts(c *gin.Context) {
-	var carts []models.Cart
-	result := database.GetDB().Preload("User", func(db *gorm.DB) *gorm.DB {
-		return db.Select("id, username") // Only select necessary user fields
-	}).Preload("CartItems.Item").Find(&carts)
+	page, err := pagination.FromRequestUpTo(c, false, pagination.MaxStreamLimit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch carts"})
+	pos, err := svc.Carts.Locate(c.Request.Context(), page)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"carts": carts})
+	stream := newJSONStream(c, "carts")
+	stream.Locate(pos)
+	next, err := svc.Carts.Each(c.Request.Context(), page,
+		func(cart *models.Cart) error { return stream.Write(cart) })
+	if err != nil {
+		stream.Fail("failed to fetch carts", err)
+		return
+	}
+
+	stream.End(next)
 }
 
 // GetUserCart returns the current user's active cart
@@ -111,38 +131,37 @@ func GetUserCart(c *gin.Context) {
 	user, _ := c.Get("user")
 	currentUser := user.(models.User)
 
-	var cart models.Cart
-	result := database.GetDB().Preload("CartItems.Item").
-		Where("user_id = ? AND is_checked_out = ?", currentUser.ID, false).
-		First(&cart)
-
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
+	cart, err := svc.Carts.OpenCart(c.Request.Context(), currentUser.ID)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrCartNotFound) {
 			// Return empty cart if not found
 			c.JSON(http.StatusOK, gin.H{"cart": nil, "items": []interface{}{}})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch cart"})
+		c.Error(err)
+		return
+	}
+
+	pricing, err := svc.Promotions.Price(c.Request.Context(), cart)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Calculate total
-	var total float64
-	var items []map[string]interface{}
+	var items []CartItemResponse
 	for _, ci := range cart.CartItems {
-		total += ci.Item.Price * float64(ci.Quantity)
-		items = append(items, map[string]interface{}{
-			"id":          ci.ItemID,
-			"name":        ci.Item.Name,
-			"description": ci.Item.Description,
-			"price":       ci.Item.Price,
-			"quantity":    ci.Quantity,
-		})
+		items = append(items, cartItemResponse(ci))
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"cart_id": cart.ID,
-		"items":   items,
-		"total":   total,
+	c.JSON(http.StatusOK, CartResponse{
+		CartID:      cart.ID,
+		Items:       items,
+		Subtotal:    pricing.Subtotal,
+		Discount:    pricing.Discount,
+		Total:       pricing.Total,
+		Promotions:  cartPromotions(pricing),
+		Sales:       cartSales(pricing),
+		Bundles:     cartBundles(pricing),
+		Adjustments: cartAdjustments(pricing),
 	})
 }
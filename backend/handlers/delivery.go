@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateDeliverySlotRequest struct {
+	// StartTime and EndTime are times of day such as 09:00, in the
+	// configured delivery time zone
+	StartTime string `json:"start_time" binding:"required,max=5"`
+	EndTime   string `json:"end_time" binding:"required,max=5"`
+	// Capacity is how many orders the slot takes on each day
+	Capacity int `json:"capacity" binding:"required,min=1"`
+}
+
+// CreateDeliverySlot adds a delivery slot to the store (admin only)
+func CreateDeliverySlot(c *gin.Context) {
+	var req CreateDeliverySlotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	slot := models.DeliverySlot{StartTime: req.StartTime, EndTime: req.EndTime, Capacity: req.Capacity}
+	if err := svc.Delivery.CreateSlot(c.Request.Context(), &slot); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, deliverySlotResponse(slot))
+}
+
+// GetDeliverySlots lists the store's delivery slots (admin only)
+func GetDeliverySlots(c *gin.Context) {
+	slots, err := svc.Delivery.Slots(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := DeliverySlotsResponse{Slots: []DeliverySlotResponse{}}
+	for _, slot := range slots {
+		response.Slots = append(response.Slots, deliverySlotResponse(slot))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteDeliverySlot removes a delivery slot (admin only)
+func DeleteDeliverySlot(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrDeliverySlotNotFound)
+		return
+	}
+
+	if err := svc.Delivery.DeleteSlot(c.Request.Context(), uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetCheckoutSlots lists the delivery slots that can be booked at
+// checkout, date by date, with how many orders each still takes
+func GetCheckoutSlots(c *gin.Context) {
+	available, err := svc.Delivery.Available(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := CheckoutSlotsResponse{Timezone: svc.Delivery.Timezone(), Slots: []CheckoutSlotResponse{}}
+	for _, a := range available {
+		response.Slots = append(response.Slots, CheckoutSlotResponse{
+			SlotID:    a.Slot.ID,
+			Date:      a.Date,
+			StartTime: a.Slot.StartTime,
+			EndTime:   a.Slot.EndTime,
+			Remaining: a.Remaining,
+			Available: a.Remaining > 0,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func deliverySlotResponse(slot models.DeliverySlot) DeliverySlotResponse {
+	return DeliverySlotResponse{
+		ID:        slot.ID,
+		StartTime: slot.StartTime,
+		EndTime:   slot.EndTime,
+		Capacity:  slot.Capacity,
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RegisterDeviceRequest struct {
+	// Platform is android, for tokens issued by Firebase Cloud Messaging,
+	// or ios, for APNs device tokens
+	Platform string `json:"platform" binding:"required,oneof=android ios"`
+	Token    string `json:"token" binding:"required,max=255"`
+}
+
+// RegisterDevice saves the token the current user's mobile app receives
+// push notifications with
+func RegisterDevice(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var req RegisterDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	device, err := svc.Devices.Register(c.Request.Context(), currentUser.ID, req.Platform, req.Token)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deviceResponse(device))
+}
+
+// GetDevices lists the current user's registered devices
+func GetDevices(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	devices, err := svc.Devices.List(c.Request.Context(), currentUser.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := DevicesResponse{Devices: []DeviceResponse{}}
+	for _, device := range devices {
+		response.Devices = append(response.Devices, deviceResponse(device))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteDevice stops push notifications to one of the current user's
+// devices
+func DeleteDevice(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrDeviceNotFound)
+		return
+	}
+
+	if err := svc.Devices.Delete(c.Request.Context(), currentUser.ID, uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func deviceResponse(device models.DeviceToken) DeviceResponse {
+	return DeviceResponse{
+		ID:         device.ID,
+		Platform:   device.Platform,
+		Token:      device.Token,
+		LastSeenAt: device.LastSeenAt,
+		CreatedAt:  device.CreatedAt,
+	}
+}
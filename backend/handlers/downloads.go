@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"errors"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxItemFileUpload bounds the size of the files of digital items, in bytes
+const maxItemFileUpload = 100 << 20
+
+// UploadItemFile makes the file uploaded as the file form field the file
+// buyers of the item download, turning it into a digital item (admin, or
+// the vendor selling the item)
+func UploadItemFile(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrItemNotFound)
+		return
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxItemFileUpload)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.Error(apperrors.Validation("file must be at most 100 MB"))
+			return
+		}
+		c.Error(apperrors.Validation("a file must be uploaded as the file form field"))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.Error(apperrors.Internal("failed to read file", err))
+		return
+	}
+	defer file.Close()
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	item, err := svc.Items.SetFile(c.Request.Context(), currentUser, uint(id), version, filepath.Base(header.Filename), contentType, file)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	renderItem(c, item)
+}
+
+// DeleteItemFile removes the file of a digital item, so it is shipped
+// again (admin, or the vendor selling the item)
+func DeleteItemFile(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrItemNotFound)
+		return
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	item, err := svc.Items.RemoveFile(c.Request.Context(), currentUser, uint(id), version)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	renderItem(c, item)
+}
+
+// Download serves the file of a digital item through a signed link from
+// the order (public; the link is the credential)
+func Download(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrDownloadNotFound)
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrDownloadLinkInvalid)
+		return
+	}
+
+	file, content, err := svc.Downloads.Open(c.Request.Context(), uint(id), expires, c.Query("signature"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, file.Size, file.ContentType, content, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}),
+		"Cache-Control":       "private, no-store",
+	})
+}
+
+// ReissueDownloads resets the download counts of an order's digital items
+// and returns fresh links, invalidating those issued before (admin only)
+func ReissueDownloads(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrOrderNotFound)
+		return
+	}
+
+	order, err := svc.Downloads.Reissue(c.Request.Context(), uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, DownloadsResponse{OrderID: order.ID, Downloads: orderDownloads(order)})
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SetDuplicateOrderSettingsRequest struct {
+	// Policy is off, block or flag; empty uses CHECKOUT_DUPLICATE_POLICY
+	Policy string `json:"policy" binding:"omitempty,oneof=off block flag"`
+	// WindowMinutes is how long after an order the same items are taken
+	// for a duplicate; 0 uses CHECKOUT_DUPLICATE_WINDOW
+	WindowMinutes int `json:"window_minutes" binding:"min=0,max=10080"`
+}
+
+// GetDuplicateOrderSettings returns how the store treats likely duplicate
+// orders (admin only)
+func GetDuplicateOrderSettings(c *gin.Context) {
+	settings, err := svc.Orders.DuplicateSettings(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, duplicateOrderSettingsResponse(settings))
+}
+
+// SetDuplicateOrderSettings sets how the store treats likely duplicate
+// orders (admin only)
+func SetDuplicateOrderSettings(c *gin.Context) {
+	var req SetDuplicateOrderSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	settings, err := svc.Orders.SetDuplicateSettings(c.Request.Context(), req.Policy, req.WindowMinutes)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, duplicateOrderSettingsResponse(settings))
+}
+
+func duplicateOrderSettingsResponse(settings services.DuplicateSettings) DuplicateOrderSettingsResponse {
+	return DuplicateOrderSettingsResponse{
+		Policy:                 settings.Policy,
+		WindowMinutes:          settings.Minutes,
+		EffectivePolicy:        settings.EffectivePolicy,
+		EffectiveWindowMinutes: int(settings.Window / time.Minute),
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/emails"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEmailTemplates lists the transactional emails that can be previewed
+// (admin only)
+func GetEmailTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, EmailTemplatesResponse{Templates: emails.Names()})
+}
+
+// PreviewEmailTemplate renders an email with sample data, as sent with the
+// loaded templates; format=html returns the HTML body alone, to view in a
+// browser (admin only)
+func PreviewEmailTemplate(c *gin.Context) {
+	name := c.Param("name")
+	msg, err := emails.Preview(name)
+	if errors.Is(err, emails.ErrUnknownTemplate) {
+		c.Error(apperrors.ErrEmailTemplateNotFound)
+		return
+	}
+	if err != nil {
+		c.Error(apperrors.Internal("failed to render email template", err))
+		return
+	}
+
+	if c.Query("format") == "html" {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+		return
+	}
+	c.JSON(http.StatusOK, EmailPreviewResponse{Name: name, Subject: msg.Subject, Text: msg.Body, HTML: msg.HTML})
+}
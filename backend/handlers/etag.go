@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeJSONWithETag writes a 200 JSON response tagged with a weak ETag
+// derived from the body, or 304 Not Modified if the client already holds it
+func writeJSONWithETag(c *gin.Context, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// etagMatches applies the weak comparison used for If-None-Match
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// itemETag is the strong ETag of an item's version, which updates of the
+// item must send back in If-Match
+func itemETag(item models.Item) string {
+	return strconv.Quote(strconv.Itoa(item.Version))
+}
+
+// ifMatchVersion returns the item version an update was based on, read
+// from its If-Match header, or nil for *, which matches any version. A
+// missing header is refused with PRECONDITION_REQUIRED, and anything but
+// a single item ETag with PRECONDITION_FAILED, as weak ETags never match.
+func ifMatchVersion(c *gin.Context) (*int, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return nil, apperrors.ErrPreconditionRequired
+	}
+	if header == "*" {
+		return nil, nil
+	}
+	unquoted, err := strconv.Unquote(header)
+	if err != nil {
+		return nil, apperrors.ErrPreconditionFailed
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil {
+		return nil, apperrors.ErrPreconditionFailed
+	}
+	return &version, nil
+}
+
+// renderItem writes an updated item tagged with the ETag of its version
+func renderItem(c *gin.Context, item models.Item) {
+	c.Header("ETag", itemETag(item))
+	render(c, http.StatusOK, ItemResponse{Item: item})
+}
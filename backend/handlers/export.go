@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/pagination"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportColumn is a column of an export, named in the CSV header and as
+// the key of JSON Lines objects
+type exportColumn[T any] struct {
+	name  string
+	value func(row *T) interface{}
+}
+
+// exportEach calls fn for every row on the page and returns the next
+// cursor, as the services' Each methods do
+type exportEach[T any] func(ctx context.Context, page pagination.Page, fn func(*T) error) (string, error)
+
+// export streams the rows of each as a CSV attachment named name.csv or,
+// with format=jsonl, as JSON Lines in name.jsonl. Rows are written as they
+// are loaded in batches, so memory use does not grow with the export, and
+// a client reading slowly holds up the loading of further batches. It
+// takes the usual limit and cursor, but exports every row after the cursor
+// without a limit; the cursor of the following page is sent as the
+// Next-Cursor trailer.
+func export[T any](c *gin.Context, name string, columns []exportColumn[T], each exportEach[T]) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "jsonl" {
+		c.Error(apperrors.Validation("format must be csv or jsonl"))
+		return
+	}
+	page, err := pagination.FromRequestUpTo(c, false, math.MaxInt32)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if c.Query("limit") == "" {
+		page.Limit = math.MaxInt32
+	}
+
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.name
+	}
+	values := make([]interface{}, len(columns))
+
+	var (
+		write  func() error
+		end    func(string)
+		failed func(string, error)
+	)
+	if format == "jsonl" {
+		stream := newJSONLStream(c, name+".jsonl")
+		write = func() error { return stream.Write(exportRecord{names, values}) }
+		end, failed = stream.End, stream.Fail
+	} else {
+		stream := newCSVStream(c, name+".csv", names)
+		cells := make([]string, len(columns))
+		write = func() error {
+			for i, v := range values {
+				cells[i] = exportCell(v)
+			}
+			return stream.Write(cells)
+		}
+		end, failed = stream.End, stream.Fail
+	}
+
+	next, err := each(c.Request.Context(), page, func(row *T) error {
+		for i, column := range columns {
+			values[i] = column.value(row)
+		}
+		return write()
+	})
+	if err != nil {
+		failed("failed to export "+name, err)
+		return
+	}
+	end(next)
+}
+
+// exportRecord renders a row as a JSON object with its keys in column
+// order
+type exportRecord struct {
+	keys   []string
+	values []interface{}
+}
+
+func (r exportRecord) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.Quote(key))
+		buf.WriteByte(':')
+		data, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// exportCell formats a value for CSV: amounts with two decimals, times in
+// RFC 3339 UTC and nil pointers as empty cells
+func exportCell(v interface{}) string {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return ""
+		}
+		v = rv.Elem().Interface()
+	}
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return formatAmount(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
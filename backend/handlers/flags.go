@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/flags"
+	"ecommerce-backend/models"
+	"ecommerce-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetFlagRequest changes a feature flag; omitted fields keep their value
+type SetFlagRequest struct {
+	Description *string `json:"description" binding:"omitempty,max=255"`
+	Enabled     *bool   `json:"enabled"`
+	// Percentage of signed-in users the flag is on for, from 0 to 100
+	Percentage *int `json:"percentage"`
+	// UserIDs are users the flag is on for whatever the percentage
+	UserIDs *[]uint `json:"user_ids"`
+}
+
+// flagEnabled reports whether the feature flag is on for the user making
+// the request, who may be anonymous, so handlers can gate features on it
+func flagEnabled(c *gin.Context, name string) bool {
+	var userID uint
+	if user, ok := c.Get("user"); ok {
+		userID = user.(models.User).ID
+	}
+	return flags.Enabled(c.Request.Context(), name, userID)
+}
+
+// GetEnabledFlags returns whether each feature flag is on for the user
+// making the request, who may be anonymous, so clients can gate features too
+func GetEnabledFlags(c *gin.Context) {
+	var userID uint
+	if user, ok := c.Get("user"); ok {
+		userID = user.(models.User).ID
+	}
+
+	on, err := flags.All(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to fetch feature flags", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, EnabledFlagsResponse{Flags: on})
+}
+
+// GetFlags lists the store's feature flags (admin only)
+func GetFlags(c *gin.Context) {
+	list, err := svc.Flags.List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, FeatureFlagsResponse{Flags: list})
+}
+
+// SetFlag creates or changes a feature flag, taking effect without a
+// redeploy (admin only)
+func SetFlag(c *gin.Context) {
+	var req SetFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	flag, created, err := svc.Flags.Set(c.Request.Context(), c.Param("name"), services.FlagChange{
+		Description: req.Description,
+		Enabled:     req.Enabled,
+		Percentage:  req.Percentage,
+		UserIDs:     req.UserIDs,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, FeatureFlagResponse{Flag: flag})
+}
+
+// DeleteFlag deletes a feature flag, turning it off (admin only)
+func DeleteFlag(c *gin.Context) {
+	if err := svc.Flags.Delete(c.Request.Context(), c.Param("name")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "feature flag deleted"})
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IssueGiftCardRequest struct {
+	Amount float64 `json:"amount" binding:"required,gt=0"`
+}
+
+// IssueGiftCard creates a gift card worth the given amount (admin only)
+func IssueGiftCard(c *gin.Context) {
+	var req IssueGiftCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	card, err := svc.GiftCards.Issue(c.Request.Context(), req.Amount)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, giftCardResponse(card))
+}
+
+// GetGiftCards lists gift cards (admin only)
+func GetGiftCards(c *gin.Context) {
+	page, err := pagination.FromRequest(c, true)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	cards, next, err := svc.GiftCards.List(c.Request.Context(), page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := GiftCardsResponse{GiftCards: []GiftCardResponse{}, NextCursor: next}
+	for _, card := range cards {
+		response.GiftCards = append(response.GiftCards, giftCardResponse(card))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetGiftCard returns a gift card with its ledger (admin only)
+func GetGiftCard(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrGiftCardNotFound)
+		return
+	}
+
+	card, err := svc.GiftCards.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, giftCardResponse(card))
+}
+
+// VoidGiftCard cancels a gift card's remaining balance (admin only)
+func VoidGiftCard(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrGiftCardNotFound)
+		return
+	}
+
+	card, err := svc.GiftCards.Void(c.Request.Context(), uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, giftCardResponse(card))
+}
+
+// GetUserGiftCards lists the gift cards the current user bought or was
+// sent, newest first
+func GetUserGiftCards(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	cards, err := svc.GiftCards.ListByUser(c.Request.Context(), currentUser.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := GiftCardsResponse{GiftCards: []GiftCardResponse{}}
+	for _, card := range cards {
+		response.GiftCards = append(response.GiftCards, giftCardResponse(card))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetGiftCardBalance returns the balance of the gift card with the code
+func GetGiftCardBalance(c *gin.Context) {
+	card, err := svc.GiftCards.Lookup(c.Request.Context(), c.Param("code"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := giftCardResponse(card)
+	response.OrderID = nil
+	c.JSON(http.StatusOK, response)
+}
+
+func giftCardResponse(card models.GiftCard) GiftCardResponse {
+	response := GiftCardResponse{
+		ID:             card.ID,
+		Code:           card.Code,
+		InitialBalance: card.InitialBalance,
+		Balance:        card.Balance,
+		OrderID:        card.OrderID,
+		VoidedAt:       card.VoidedAt,
+		CreatedAt:      card.CreatedAt,
+	}
+	for _, entry := range card.Entries {
+		response.Entries = append(response.Entries, GiftCardEntryResponse{
+			Kind:      entry.Kind,
+			Amount:    entry.Amount,
+			OrderID:   entry.OrderID,
+			CreatedAt: entry.CreatedAt,
+		})
+	}
+	return response
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"ecommerce-backend/graph"
+	"ecommerce-backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GraphQL serves the GraphQL API. It must be used after OptionalAuth; the
+// authenticated user, if any, is passed to the resolvers.
+func GraphQL(c *gin.Context) {
+	ctx := c.Request.Context()
+	if user, ok := c.Get("user"); ok {
+		ctx = graph.WithUser(ctx, user.(models.User))
+	}
+	graphqlServer.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+}
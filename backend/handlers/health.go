@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"ecommerce-backend/database"
+	"ecommerce-backend/version"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const readinessCheckTimeout = 2 * time.Second
+
+// ReadinessCheck reports whether a dependency is ready to serve traffic
+type ReadinessCheck func(ctx context.Context) error
+
+var (
+	readinessMu     sync.RWMutex
+	readinessChecks = map[string]ReadinessCheck{
+		"database":   pingDatabase,
+		"migrations": func(ctx context.Context) error { return database.CheckSchema() },
+	}
+	shuttingDown atomic.Bool
+
+	// shutdown is closed by MarkShuttingDown so long-lived connections
+	// can close themselves
+	shutdown     = make(chan struct{})
+	shutdownOnce sync.Once
+)
+
+// RegisterReadinessCheck adds a dependency check to /readyz (e.g. a cache)
+func RegisterReadinessCheck(name string, check ReadinessCheck) {
+	readinessMu.Lock()
+	defer readinessMu.Unlock()
+	readinessChecks[name] = check
+}
+
+// MarkShuttingDown makes /readyz fail so load balancers stop routing new
+// traffic while in-flight requests drain
+func MarkShuttingDown() {
+	shuttingDown.Store(true)
+	shutdownOnce.Do(func() { close(shutdown) })
+}
+
+func pingDatabase(ctx context.Context) error {
+	sqlDB, err := database.GetDB().DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Healthz reports that the process is up (liveness)
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// Readyz reports whether the service can handle traffic (readiness)
+func Readyz(c *gin.Context) {
+	if shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, ReadinessResponse{Status: "shutting down"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessCheckTimeout)
+	defer cancel()
+
+	readinessMu.RLock()
+	defer readinessMu.RUnlock()
+
+	status := http.StatusOK
+	checks := map[string]string{}
+	for name, check := range readinessChecks {
+		if err := check(ctx); err != nil {
+			status = http.StatusServiceUnavailable
+			checks[name] = err.Error()
+			continue
+		}
+		checks[name] = "ok"
+	}
+
+	result := "ok"
+	if status != http.StatusOK {
+		result = "unavailable"
+	}
+
+	c.JSON(status, ReadinessResponse{Status: result, Checks: checks})
+}
+
+// GetVersion returns build information for the running binary
+func GetVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, version.Get())
+}
@@ -1,54 +1,804 @@
 package handlers
 
 import (
+	"context"
+	"ecommerce-backend/analytics"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/cache"
+	"ecommerce-backend/currency"
 	"ecommerce-backend/database"
+	"ecommerce-backend/logging"
 	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/search"
+	"ecommerce-backend/services"
+	"ecommerce-backend/tenant"
+	"ecommerce-backend/visibility"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// itemCache holds rendered catalog responses. It is invalidated whenever an
+// item is created, updated or deleted.
+var itemCache = cache.NewNamespace("items")
+
+const (
+	defaultTrendingItems = 10
+	maxTrendingItems     = 50
+	// maxSearchOffset keeps search pages within the result window search
+	// engines allow by default
+	maxSearchOffset = 10000
+)
+
 type CreateItemRequest struct {
 	Name        string  `json:"name" binding:"required"`
 	Description string  `json:"description"`
 	Price       float64 `json:"price" binding:"required,gt=0"`
+	// SKU identifies the item to the inventory sync; unique in the store
+	SKU *string `json:"sku" binding:"omitempty,min=1,max=64"`
+	// CompareAtPrice is shown struck through next to the price and must
+	// be greater than it
+	CompareAtPrice *float64 `json:"compare_at_price" binding:"omitempty,gt=0"`
+	// Stock is omitted for items whose stock is not tracked
+	Stock             *int `json:"stock" binding:"omitempty,min=0"`
+	LowStockThreshold int  `json:"low_stock_threshold" binding:"min=0"`
+	// WeightKg is used by weight-based shipping methods
+	WeightKg float64 `json:"weight_kg" binding:"min=0"`
+	// GiftCard makes each unit ordered issue a gift card worth the price
+	GiftCard bool `json:"gift_card"`
+	// Category groups the item for category-wide promotions
+	Category string `json:"category" binding:"max=64"`
+	// Backorder lets the item sell beyond its stock, expected at
+	// ExpectedAt
+	Backorder  string     `json:"backorder" binding:"omitempty,oneof=backorder preorder"`
+	ExpectedAt *time.Time `json:"expected_at"`
+	// VendorID assigns the item to a vendor; only admins may set it, as
+	// vendor accounts always create items for their own vendor
+	VendorID *uint `json:"vendor_id"`
+	// IsActive false creates the item hidden from the catalog
+	IsActive *bool `json:"is_active"`
+	// MinQuantity and MaxQuantity limit the units per order and per
+	// customer; 0 for no limit
+	MinQuantity int `json:"min_quantity" binding:"min=0"`
+	MaxQuantity int `json:"max_quantity" binding:"min=0"`
+	// CostPrice and InternalNotes are only seen by admins, and ignored
+	// when vendors create items
+	CostPrice     *float64 `json:"cost_price" binding:"omitempty,min=0"`
+	InternalNotes string   `json:"internal_notes" binding:"max=2000"`
+}
+
+type SetItemPriceRequest struct {
+	Price float64 `json:"price" binding:"required,gt=0"`
+	// CompareAtPrice is omitted to remove the item's compare-at price
+	CompareAtPrice *float64 `json:"compare_at_price" binding:"omitempty,gt=0"`
+}
+
+// SetItemQuantityLimitsRequest sets the fewest units of an item an order
+// may hold and the most each customer may buy; 0 for no limit
+type SetItemQuantityLimitsRequest struct {
+	MinQuantity int `json:"min_quantity" binding:"min=0"`
+	MaxQuantity int `json:"max_quantity" binding:"min=0"`
+}
+
+// SetItemInternalRequest sets the fields of an item only admins see
+type SetItemInternalRequest struct {
+	// CostPrice is omitted to remove the item's cost price
+	CostPrice     *float64 `json:"cost_price" binding:"omitempty,min=0"`
+	InternalNotes string   `json:"internal_notes" binding:"max=2000"`
+}
+
+type SetItemActiveRequest struct {
+	IsActive *bool `json:"is_active" binding:"required"`
+}
+
+type BulkUpdateItemsRequest struct {
+	Items []BulkItemChange `json:"items" binding:"required,min=1,max=1000,dive"`
+}
+
+// BulkItemChange sets an item's price, or adjusts it by percent, and sets
+// its stock; omitted fields are left as they are
+type BulkItemChange struct {
+	ItemID uint     `json:"item_id" binding:"required"`
+	Price  *float64 `json:"price" binding:"omitempty,gt=0"`
+	// Percent adjusts the price, e.g. -20 for 20% off; rounded to cents
+	Percent *float64 `json:"percent" binding:"omitempty,gt=-100"`
+	Stock   *int     `json:"stock" binding:"omitempty,min=0"`
 }
 
-// CreateItem handles creating a new item (admin only)
+type UpdateInventoryRequest struct {
+	// Stock is null to stop tracking the item's stock
+	Stock             *int `json:"stock" binding:"omitempty,min=0"`
+	LowStockThreshold int  `json:"low_stock_threshold" binding:"min=0"`
+}
+
+// CreateItem handles creating a new item (admin or vendor)
 func CreateItem(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
 	var req CreateItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperrors.Binding(err))
 		return
 	}
 
 	// Create item
 	item := models.Item{
-		Name:        req.Name,
-		Description: req.Description,
-		Price:       req.Price,
+		SKU:               req.SKU,
+		Name:              req.Name,
+		Description:       req.Description,
+		Price:             req.Price,
+		CompareAtPrice:    req.CompareAtPrice,
+		Stock:             req.Stock,
+		LowStockThreshold: req.LowStockThreshold,
+		WeightKg:          req.WeightKg,
+		GiftCard:          req.GiftCard,
+		Category:          req.Category,
+		Backorder:         req.Backorder,
+		VendorID:          req.VendorID,
+		IsActive:          req.IsActive == nil || *req.IsActive,
+		MinQuantity:       req.MinQuantity,
+		MaxQuantity:       req.MaxQuantity,
+		CostPrice:         req.CostPrice,
+		InternalNotes:     req.InternalNotes,
+	}
+	if req.Backorder != "" {
+		item.ExpectedAt = req.ExpectedAt
 	}
 
-	result := database.GetDB().Create(&item)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create item"})
+	if err := svc.Items.Create(c.Request.Context(), currentUser, &item); err != nil {
+		c.Error(err)
 		return
 	}
+	invalidateItems(c.Request.Context())
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "item created successfully",
-		"item":    item,
+	render(c, http.StatusCreated, CreateItemResponse{
+		Message: "item created successfully",
+		Item:    item,
 	})
 }
 
-// GetItems returns a list of all items
+// priceRange parses the min_price and max_price query parameters
+func priceRange(c *gin.Context) (minPrice, maxPrice *float64, err error) {
+	for _, p := range []struct {
+		name string
+		dst  **float64
+	}{{"min_price", &minPrice}, {"max_price", &maxPrice}} {
+		value := c.Query(p.name)
+		if value == "" {
+			continue
+		}
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil || price < 0 {
+			return nil, nil, apperrors.Validation("invalid " + p.name)
+		}
+		*p.dst = &price
+	}
+	if minPrice != nil && maxPrice != nil && *minPrice > *maxPrice {
+		return nil, nil, apperrors.Validation("min_price must not exceed max_price")
+	}
+	return minPrice, maxPrice, nil
+}
+
+// itemFilter parses the catalog filters of GetItems, returning them with
+// the query parameters they were parsed from for use in cache keys
+func itemFilter(c *gin.Context) (repository.ItemFilter, url.Values, error) {
+	filter := repository.ItemFilter{Inactive: seesInactive(c), Category: c.Query("category")}
+	var err error
+	if filter.MinPrice, filter.MaxPrice, err = priceRange(c); err != nil {
+		return filter, nil, err
+	}
+
+	params := url.Values{}
+	for _, name := range []string{"min_price", "max_price", "in_stock", "category", "vendor_id", "digital"} {
+		if value := c.Query(name); value != "" {
+			params.Set(name, value)
+		}
+	}
+	if value := params.Get("in_stock"); value != "" {
+		if filter.InStock, err = strconv.ParseBool(value); err != nil {
+			return filter, nil, apperrors.Validation("invalid in_stock")
+		}
+	}
+	if value := params.Get("vendor_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return filter, nil, apperrors.Validation("invalid vendor_id")
+		}
+		vendorID := uint(id)
+		filter.VendorID = &vendorID
+	}
+	if value := params.Get("digital"); value != "" {
+		digital, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, nil, apperrors.Validation("invalid digital")
+		}
+		filter.Digital = &digital
+	}
+	return filter, params, nil
+}
+
+// seesInactive reports whether the request is by an admin, who sees the
+// items hidden from the catalog
+func seesInactive(c *gin.Context) bool {
+	return viewerRole(c) == models.RoleAdmin
+}
+
+// GetItems returns a page of items, optionally filtered by min_price,
+// max_price, in_stock, category, vendor_id and digital; admins also get the
+// inactive ones. With currency, prices are shown converted from the base
+// currency, which the price filters stay in.
 func GetItems(c *gin.Context) {
-	var items []models.Item
-	result := database.GetDB().Find(&items)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch items"})
+	page, err := pagination.FromRequest(c, false)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	filter, params, err := itemFilter(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	var display ItemsResponse
+	if code := c.Query("currency"); code != "" {
+		if display.Currency, display.ExchangeRate, err = services.ExchangeRate(c.Request.Context(), code); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	key := "list:" + page.Key()
+	if len(params) > 0 {
+		key += "&" + params.Encode()
+	}
+	if display.Currency != "" {
+		key += "&currency=" + display.Currency
+	}
+	if filter.Inactive {
+		key += "&inactive"
+	}
+	if serveCachedPage(c, itemCache, key) {
+		return
+	}
+
+	items, next, err := svc.Items.List(c.Request.Context(), filter, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	pos, err := svc.Items.Locate(c.Request.Context(), filter, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if display.Currency != "" {
+		for i := range items {
+			convertPrices(&items[i], display.ExchangeRate)
+		}
+	}
+	display.Items, display.NextCursor, display.Meta = items, next, setPageLinks(c, pos)
+	renderAndCachePage(c, itemCache, key, pos, display)
+}
+
+// convertPrices shows the item's prices, and its sale price, in another
+// currency at rate
+func convertPrices(item *models.Item, rate float64) {
+	item.Price = currency.Convert(item.Price, rate)
+	if item.CompareAtPrice != nil {
+		compareAt := currency.Convert(*item.CompareAtPrice, rate)
+		item.CompareAtPrice = &compareAt
+	}
+	if item.Sale != nil {
+		sale := *item.Sale
+		sale.Price = currency.Convert(sale.Price, rate)
+		item.Sale = &sale
+	}
+}
+
+// GetItem returns a single item with its stock in each warehouse. Inactive
+// items are only shown to admins.
+func GetItem(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrItemNotFound)
+		return
+	}
+
+	inactive := seesInactive(c)
+	key := "item:" + strconv.FormatUint(id, 10)
+	if inactive {
+		key += "&inactive"
+	}
+	editor := editsItems(c)
+	if !editor && serveCached(c, itemCache, key) {
+		return
+	}
+
+	item, err := svc.Items.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !item.IsActive && !inactive {
+		c.Error(apperrors.ErrItemNotFound)
+		return
+	}
+	stock, err := svc.Warehouses.Availability(c.Request.Context(), item.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := ItemResponse{Item: item}
+	for _, s := range stock {
+		response.Availability = append(response.Availability, AvailabilityResponse{
+			WarehouseID: s.WarehouseID,
+			Warehouse:   s.Warehouse.Name,
+			Quantity:    s.Quantity,
+		})
+	}
+	if editor {
+		// Editors get the ETag of the item's version, to send back in
+		// If-Match, rather than the cached body's
+		c.Header("ETag", itemETag(item))
+		c.Header("Cache-Control", "no-cache")
+		render(c, http.StatusOK, response)
+		return
+	}
+	renderAndCache(c, itemCache, key, response)
+}
+
+// editsItems reports whether the user making the request may update items
+func editsItems(c *gin.Context) bool {
+	role := viewerRole(c)
+	return role == models.RoleAdmin || role == models.RoleVendor
+}
+
+// SearchItems returns a page of the items matching the q query parameter,
+// most relevant first, optionally filtered by category and by min_price and
+// max_price, with category and price range facets. Pages are selected with
+// limit and offset.
+func SearchItems(c *gin.Context) {
+	q := search.Query{
+		Text:     strings.TrimSpace(c.Query("q")),
+		Category: c.Query("category"),
+		Limit:    pagination.DefaultLimit,
+	}
+
+	var err error
+	if q.MinPrice, q.MaxPrice, err = priceRange(c); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			c.Error(apperrors.Validation("invalid limit"))
+			return
+		}
+		q.Limit = min(limit, pagination.MaxLimit)
+	}
+	if value := c.Query("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 || offset > maxSearchOffset {
+			c.Error(apperrors.Validation("offset must be between 0 and " + strconv.Itoa(maxSearchOffset)))
+			return
+		}
+		q.Offset = offset
+	}
+
+	key := fmt.Sprintf("search:q=%s&category=%s&min=%v&max=%v&limit=%d&offset=%d",
+		url.QueryEscape(q.Text), url.QueryEscape(q.Category), priceKey(q.MinPrice), priceKey(q.MaxPrice), q.Limit, q.Offset)
+	if serveCached(c, itemCache, key) {
+		return
+	}
+
+	result, err := svc.Items.Search(c.Request.Context(), q)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if result.Items == nil {
+		result.Items = []models.Item{}
+	}
+	if result.Facets.Categories == nil {
+		result.Facets.Categories = []search.CategoryCount{}
+	}
+
+	renderAndCache(c, itemCache, key, SearchItemsResponse{Items: result.Items, Total: result.Total, Facets: result.Facets})
+}
+
+// priceKey renders an optional price for a cache key
+func priceKey(price *float64) string {
+	if price == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*price, 'f', -1, 64)
+}
+
+// UpdateInventory sets an item's stock and low-stock threshold (admin, or
+// the vendor selling the item)
+func UpdateInventory(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrItemNotFound)
+		return
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var req UpdateInventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	item, err := svc.Items.UpdateInventory(c.Request.Context(), currentUser, uint(id), version, req.Stock, req.LowStockThreshold)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	renderItem(c, item)
+}
+
+// SetItemActive lists an item in the catalog or hides it (admin, or the
+// vendor selling the item)
+func SetItemActive(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrItemNotFound)
+		return
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var req SetItemActiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	item, err := svc.Items.SetActive(c.Request.Context(), currentUser, uint(id), version, *req.IsActive)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	renderItem(c, item)
+}
+
+// SetItemPrice sets an item's price and the compare-at price shown struck
+// through next to it (admin or the item's vendor)
+func SetItemPrice(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrItemNotFound)
+		return
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"items": items})
+	var req SetItemPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	item, err := svc.Items.SetPrice(c.Request.Context(), currentUser, uint(id), version, req.Price, req.CompareAtPrice)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	renderItem(c, item)
+}
+
+// SetItemQuantityLimits sets how many units of an item customers may buy
+// (admin, or the vendor selling the item)
+func SetItemQuantityLimits(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrItemNotFound)
+		return
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var req SetItemQuantityLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	item, err := svc.Items.SetQuantityLimits(c.Request.Context(), currentUser, uint(id), version, req.MinQuantity, req.MaxQuantity)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	renderItem(c, item)
+}
+
+// SetItemInternal sets an item's cost price and internal notes (admin only)
+func SetItemInternal(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrItemNotFound)
+		return
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var req SetItemInternalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	item, err := svc.Items.SetInternal(c.Request.Context(), currentUser, uint(id), version, req.CostPrice, req.InternalNotes)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	renderItem(c, item)
+}
+
+// BulkUpdateItems sets the prices and stock of many items in one
+// transaction, with a result per item; if any item fails, none is changed
+// and the results are the error's details (admin only)
+func BulkUpdateItems(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var req BulkUpdateItemsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	changes := make([]services.ItemChange, len(req.Items))
+	for i, row := range req.Items {
+		changes[i] = services.ItemChange{ItemID: row.ItemID, Price: row.Price, Percent: row.Percent, Stock: row.Stock}
+	}
+	results, err := svc.Items.BulkUpdate(c.Request.Context(), currentUser, changes)
+	response := BulkItemsResponse{Results: make([]BulkItemResult, len(results))}
+	for i, result := range results {
+		response.Results[i] = BulkItemResult{ItemID: result.ItemID, Price: result.Price, Stock: result.Stock, Error: result.Err}
+	}
+	if err != nil {
+		if results != nil {
+			err = apperrors.From(err).WithDetails(response.Results)
+		}
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetLowStockItems lists the items at or below their low-stock threshold,
+// lowest stock first (admin only)
+func GetLowStockItems(c *gin.Context) {
+	items, err := svc.Items.LowStock(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if items == nil {
+		items = []models.Item{}
+	}
+
+	render(c, http.StatusOK, ItemsResponse{Items: items})
+}
+
+// ExportInventory streams every item, listed or not, with its stock as CSV
+// or JSON Lines (admin only)
+func ExportInventory(c *gin.Context) {
+	filter := repository.ItemFilter{Inactive: true}
+	export(c, "inventory", inventoryExportColumns,
+		func(ctx context.Context, page pagination.Page, fn func(*models.Item) error) (string, error) {
+			return svc.Items.Each(ctx, filter, page, fn)
+		})
+}
+
+// inventoryExportColumns are the columns of the inventory export; stock is
+// empty for items whose stock is not tracked
+var inventoryExportColumns = []exportColumn[models.Item]{
+	{"id", func(i *models.Item) interface{} { return i.ID }},
+	{"name", func(i *models.Item) interface{} { return i.Name }},
+	{"category", func(i *models.Item) interface{} { return i.Category }},
+	{"vendor_id", func(i *models.Item) interface{} { return i.VendorID }},
+	{"price", func(i *models.Item) interface{} { return i.Price }},
+	{"cost_price", func(i *models.Item) interface{} { return i.CostPrice }},
+	{"stock", func(i *models.Item) interface{} { return i.Stock }},
+	{"low_stock_threshold", func(i *models.Item) interface{} { return i.LowStockThreshold }},
+	{"backorder", func(i *models.Item) interface{} { return i.Backorder }},
+	{"expected_at", func(i *models.Item) interface{} { return i.ExpectedAt }},
+	{"is_active", func(i *models.Item) interface{} { return i.IsActive }},
+	{"version", func(i *models.Item) interface{} { return i.Version }},
+}
+
+// GetItemMovements lists the changes to an item's stock, newest first,
+// optionally of one reason (admin only)
+func GetItemMovements(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrItemNotFound)
+		return
+	}
+	page, err := pagination.FromRequest(c, true)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	movements, next, err := svc.Items.Movements(c.Request.Context(), uint(id), c.Query("reason"), page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if movements == nil {
+		movements = []models.InventoryMovement{}
+	}
+
+	c.JSON(http.StatusOK, InventoryMovementsResponse{Movements: movements, NextCursor: next})
+}
+
+// GetTrendingItems returns the best-selling items of the last week. The
+// list is cached, so it lags new orders by up to the cache TTL.
+func GetTrendingItems(c *gin.Context) {
+	limit := defaultTrendingItems
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = min(v, maxTrendingItems)
+	}
+
+	key := "trending:" + strconv.Itoa(limit)
+	if serveCached(c, itemCache, key) {
+		return
+	}
+
+	now := time.Now().UTC()
+	r := analytics.Range{From: now.Add(-lookbacks[analytics.Week]), To: now}
+	items, err := analytics.Trending(database.WithContext(c.Request.Context()), r, limit)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to fetch trending items", err))
+		return
+	}
+	if items == nil {
+		items = []models.Item{}
+	}
+
+	renderAndCache(c, itemCache, key, ItemsResponse{Items: items})
+}
+
+// invalidateItems discards cached catalog responses after an item changes
+func invalidateItems(ctx context.Context) {
+	if err := itemCache.Invalidate(ctx); err != nil {
+		logging.FromContext(ctx).Error("failed to invalidate item cache", "error", err)
+	}
+}
+
+// serveCached writes the cached response stored under key for the
+// request's store, if any. Cache failures are logged and treated as misses.
+func serveCached(c *gin.Context, ns cache.Namespace, key string) bool {
+	key = storeKey(c, key)
+	body, found, err := ns.Get(c.Request.Context(), key)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Warn("cache lookup failed", "key", key, "error", err)
+		return false
+	}
+	if !found {
+		c.Header("X-Cache", "MISS")
+		return false
+	}
+
+	c.Header("X-Cache", "HIT")
+	writeJSONWithETag(c, body)
+	return true
+}
+
+// renderAndCache writes obj as a JSON response and caches the body under
+// key for the request's store
+func renderAndCache(c *gin.Context, ns cache.Namespace, key string, obj interface{}) {
+	key = storeKey(c, key)
+	body, err := json.Marshal(visibility.Redact(obj, viewerRole(c)))
+	if err != nil {
+		c.Error(apperrors.Internal("failed to encode response", err))
+		return
+	}
+	if err := ns.Set(c.Request.Context(), key, body); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("cache store failed", "key", key, "error", err)
+	}
+
+	writeJSONWithETag(c, body)
+}
+
+// serveCachedPage is serveCached for a page of a list, which also sets the
+// page's Link header from its cached position
+func serveCachedPage(c *gin.Context, ns cache.Namespace, key string) bool {
+	data, found, err := ns.Get(c.Request.Context(), storeKey(c, key+":position"))
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Warn("cache lookup failed", "key", key, "error", err)
+		return false
+	}
+	var pos pagination.Position
+	if !found || json.Unmarshal(data, &pos) != nil {
+		c.Header("X-Cache", "MISS")
+		return false
+	}
+
+	setPageLinks(c, pos)
+	return serveCached(c, ns, key)
+}
+
+// renderAndCachePage is renderAndCache for a page of a list, which also
+// caches the page's position for serveCachedPage
+func renderAndCachePage(c *gin.Context, ns cache.Namespace, key string, pos pagination.Position, obj interface{}) {
+	if data, err := json.Marshal(pos); err == nil {
+		if err := ns.Set(c.Request.Context(), storeKey(c, key+":position"), data); err != nil {
+			logging.FromContext(c.Request.Context()).Warn("cache store failed", "key", key, "error", err)
+		}
+	}
+	renderAndCache(c, ns, key, obj)
+}
+
+// storeKey prefixes a cache key with the request's store, since every
+// store has its own catalog, and with the role of the user, since roles
+// see different fields
+func storeKey(c *gin.Context, key string) string {
+	return "store:" + strconv.FormatUint(uint64(tenant.StoreOrDefault(c.Request.Context())), 10) +
+		":role:" + viewerRole(c) + ":" + key
 }
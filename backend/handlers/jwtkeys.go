@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJWTKeys lists the keys signing and verifying tokens, without their
+// secrets (admin only)
+func GetJWTKeys(c *gin.Context) {
+	keys, err := utils.JWTKeys(c.Request.Context())
+	if err != nil {
+		c.Error(apperrors.Internal("failed to fetch jwt keys", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, jwtKeysResponse(keys))
+}
+
+// RotateJWTKey makes a new key sign every token issued from now on, on
+// every instance within a minute, while tokens signed before stay valid
+// until they expire (admin only)
+func RotateJWTKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	key, err := utils.RotateJWTKey(ctx)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to rotate jwt key", err))
+		return
+	}
+	logging.FromContext(ctx).Info("jwt key rotated", "kid", key.KID)
+
+	keys, err := utils.JWTKeys(ctx)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to fetch jwt keys", err))
+		return
+	}
+	c.JSON(http.StatusCreated, jwtKeysResponse(keys))
+}
+
+func jwtKeysResponse(keys []utils.JWTKeyStatus) JWTKeysResponse {
+	response := JWTKeysResponse{Keys: make([]JWTKeyResponse, len(keys))}
+	for i, key := range keys {
+		source := "config"
+		if key.Rotated {
+			source = "rotated"
+		}
+		response.Keys[i] = JWTKeyResponse{KID: key.KID, Source: source, Signing: key.Signing, ExpiresAt: key.ExpiresAt}
+	}
+	return response
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"ecommerce-backend/pagination"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setPageLinks sets the Link header of a list response to its first,
+// previous, next and last pages, and returns the list's metadata
+func setPageLinks(c *gin.Context, pos pagination.Position) *pagination.Meta {
+	link := func(rel, cursor string) string {
+		u := *c.Request.URL
+		query := u.Query()
+		query.Del("cursor")
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		u.RawQuery = query.Encode()
+		return "<" + u.String() + `>; rel="` + rel + `"`
+	}
+
+	links := []string{link("first", "")}
+	if pos.HasPrev {
+		links = append(links, link("prev", pos.Prev))
+	}
+	if pos.Next != "" {
+		links = append(links, link("next", pos.Next))
+	}
+	links = append(links, link("last", pos.Last))
+	c.Header("Link", strings.Join(links, ", "))
+
+	meta := pos.Meta()
+	return &meta
+}
+
+// setKeysetLinks sets the Link header of a keyset-paged list response to
+// its first page and the pages before and after it
+func setKeysetLinks(c *gin.Context, cursors pagination.Cursors) {
+	link := func(rel, param, cursor string) string {
+		u := *c.Request.URL
+		query := u.Query()
+		query.Del("cursor")
+		query.Del("after")
+		query.Del("before")
+		if cursor != "" {
+			query.Set(param, cursor)
+		}
+		u.RawQuery = query.Encode()
+		return "<" + u.String() + `>; rel="` + rel + `"`
+	}
+
+	links := []string{link("first", "", "")}
+	if cursors.Prev != "" {
+		links = append(links, link("prev", "before", cursors.Prev))
+	}
+	if cursors.Next != "" {
+		links = append(links, link("next", "after", cursors.Next))
+	}
+	c.Header("Link", strings.Join(links, ", "))
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/maintenance"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SetMaintenanceRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+	// Duration, such as 30m, switches maintenance mode off by itself
+	// after it has passed; without it the mode stays on until switched off
+	Duration string `json:"duration"`
+}
+
+// GetMaintenance returns whether maintenance mode is on (admin only)
+func GetMaintenance(c *gin.Context) {
+	status, err := maintenance.Get(c.Request.Context())
+	if err != nil {
+		c.Error(apperrors.Internal("failed to check maintenance mode", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, maintenanceResponse(status))
+}
+
+// SetMaintenance switches maintenance mode on or off for every instance
+// (admin only)
+func SetMaintenance(c *gin.Context) {
+	var req SetMaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	var duration time.Duration
+	if req.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(req.Duration); err != nil || duration <= 0 {
+			c.Error(apperrors.Validation("duration must be a positive duration such as 30m"))
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	var err error
+	if *req.Enabled {
+		err = maintenance.Enable(ctx, duration)
+	} else {
+		err = maintenance.Disable(ctx)
+	}
+	if err != nil {
+		c.Error(apperrors.Internal("failed to switch maintenance mode", err))
+		return
+	}
+	logging.FromContext(ctx).Info("maintenance mode switched", "enabled", *req.Enabled, "duration", duration)
+
+	status, err := maintenance.Get(ctx)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to check maintenance mode", err))
+		return
+	}
+	c.JSON(http.StatusOK, maintenanceResponse(status))
+}
+
+func maintenanceResponse(status maintenance.Status) MaintenanceResponse {
+	response := MaintenanceResponse{Enabled: status.Enabled, Configured: status.Configured, Until: status.Until}
+	if status.Enabled {
+		response.RetryAfter = int(status.RetryAfter().Round(time.Second).Seconds())
+	}
+	return response
+}
@@ -1,171 +1,420 @@
 package handlers
 
 import (
-	"ecommerce-backend/database"
+	"context"
+	"ecommerce-backend/apperrors"
 	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
-// CreateOrder creates a new order from the user's cart
+type CreateOrderRequest struct {
+	// ShippingMethodID is required once the store has shipping methods
+	ShippingMethodID uint `json:"shipping_method_id"`
+	// GiftCardCode pays as much of the order as the card's balance covers
+	GiftCardCode string `json:"gift_card_code" binding:"max=32"`
+	// AddressID is the saved address to ship to
+	AddressID uint `json:"address_id"`
+	// DeliverySlotID and DeliveryDate book one of GET /checkout/slots;
+	// they are required once the store has delivery slots
+	DeliverySlotID uint   `json:"delivery_slot_id"`
+	DeliveryDate   string `json:"delivery_date"`
+	// Metadata are custom fields kept on the order, such as gift_message,
+	// po_number or delivery_instructions
+	Metadata map[string]string `json:"metadata"`
+	// ConfirmDuplicate places the order even if it has the same items as
+	// one just placed, after a DUPLICATE_ORDER error
+	ConfirmDuplicate bool `json:"confirm_duplicate"`
+	// Currency is the ISO 4217 code of the currency to settle the order
+	// in; the base currency if omitted
+	Currency string `json:"currency" binding:"omitempty,len=3"`
+}
+
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=pending completed shipped delivered cancelled"`
+}
+
+type BulkUpdateOrderStatusRequest struct {
+	OrderIDs []uint `json:"order_ids" binding:"required,min=1,max=1000,unique,dive,required"`
+	Status   string `json:"status" binding:"required,oneof=pending completed shipped delivered cancelled"`
+}
+
+// CreateOrder creates a new order from the user's cart, shipped with the
+// selected method. The body may be omitted while the store has no
+// shipping methods.
 func CreateOrder(c *gin.Context) {
 	user, _ := c.Get("user")
 	currentUser := user.(models.User)
 
-	// Start transaction
-	tx := database.GetDB().Begin()
-
-	// Get user's active cart
-	var cart models.Cart
-	result := tx.Preload("CartItems.Item").
-		Where("user_id = ? AND is_checked_out = ?", currentUser.ID, false).
-		First(&cart)
-
-	if result.Error != nil {
-		tx.Rollback()
-		if result.Error == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "no active cart found"})
+	var req CreateOrderRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(apperrors.Binding(err))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process order"})
-		return
 	}
 
-	// Check if cart is empty
-	if len(cart.CartItems) == 0 {
-		tx.Rollback()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "cart is empty"})
+	order, err := svc.Orders.Checkout(c.Request.Context(), currentUser.ID, services.CheckoutOptions{
+		ShippingMethodID: req.ShippingMethodID,
+		GiftCardCode:     req.GiftCardCode,
+		AddressID:        req.AddressID,
+		DeliverySlotID:   req.DeliverySlotID,
+		DeliveryDate:     req.DeliveryDate,
+		Metadata:         req.Metadata,
+		ConfirmDuplicate: req.ConfirmDuplicate,
+		Currency:         req.Currency,
+	})
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Calculate total
-	var total float64
-	for _, item := range cart.CartItems {
-		total += item.Item.Price * float64(item.Quantity)
+	// Cached catalog responses show the stock of tracked items
+	for _, ci := range order.Cart.CartItems {
+		if ci.Item.Stock != nil {
+			invalidateItems(c.Request.Context())
+			break
+		}
 	}
 
-	// Create order
-	now := time.Now()
-	order := models.Order{
-		UserID:    currentUser.ID,
-		CartID:    cart.ID,
-		Total:     total,
-		Status:    "completed",
+	response := CreateOrderResponse{
+		Message:        "order created successfully",
+		OrderID:        order.ID,
+		Number:         order.Number,
+		Total:          order.Total,
+		ShippingCost:   order.ShippingCost,
+		Discount:       order.Discount,
+		GiftCardAmount: order.GiftCardAmount,
+		Promotions:     orderPromotions(order),
+		Sales:          orderSales(order),
+		Bundles:        orderBundles(order),
+		Downloads:      orderDownloads(order),
+		Backorders:     orderBackorders(order),
+		Metadata:       order.Metadata,
+		Delivery:       orderDelivery(order),
+		Settlement:     orderSettlement(order),
+	}
+	if order.ShippingAddress != (models.PostalAddress{}) {
+		response.ShippingAddress = &order.ShippingAddress
 	}
+	for _, card := range order.GiftCards {
+		card.Entries = nil
+		response.GiftCards = append(response.GiftCards, giftCardResponse(card))
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ExportOrders streams orders as CSV or JSON Lines (admin only), optionally
+// those with the metadata values given as metadata[key]=value, or those
+// awaiting review with needs_review=true
+func ExportOrders(c *gin.Context) {
+	filter := repository.OrderFilter{
+		Number:      c.Query("number"),
+		Metadata:    c.QueryMap("metadata"),
+		NeedsReview: c.Query("needs_review") == "true",
+	}
+	if err := services.CheckMetadata(filter.Metadata); err != nil {
+		c.Error(err)
+		return
+	}
+	export(c, "orders", orderExportColumns,
+		func(ctx context.Context, page pagination.Page, fn func(*models.Order) error) (string, error) {
+			return svc.Orders.Each(ctx, filter, page, fn)
+		})
+}
+
+// orderExportColumns are the columns of the order export
+var orderExportColumns = []exportColumn[models.Order]{
+	{"id", func(o *models.Order) interface{} { return o.ID }},
+	{"number", func(o *models.Order) interface{} { return o.Number }},
+	{"user_id", func(o *models.Order) interface{} { return o.UserID }},
+	{"username", func(o *models.Order) interface{} { return o.User.Username }},
+	{"status", func(o *models.Order) interface{} { return o.Status }},
+	{"units", func(o *models.Order) interface{} {
+		units := 0
+		for _, item := range o.Cart.CartItems {
+			units += item.Quantity
+		}
+		return units
+	}},
+	{"shipping_cost", func(o *models.Order) interface{} { return o.ShippingCost }},
+	{"discount", func(o *models.Order) interface{} { return o.Discount }},
+	{"gift_card_amount", func(o *models.Order) interface{} { return o.GiftCardAmount }},
+	{"total", func(o *models.Order) interface{} { return o.Total }},
+	{"created_at", func(o *models.Order) interface{} { return o.CreatedAt.UTC() }},
+	{"delivery_date", func(o *models.Order) interface{} { return o.DeliveryDate }},
+	{"delivery_window", func(o *models.Order) interface{} { return o.DeliveryWindow }},
+}
 
-	if err := tx.Create(&order).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create order"})
+// GetOrders streams a page of orders (admin only), optionally those with
+// the metadata values given as metadata[key]=value, or those awaiting
+// review with needs_review=true. Pages may be large, so orders are loaded
+// in batches and written as they are formatted.
+func GetOrders(c *gin.Context) {
+	page, err := pagination.FromRequestUpTo(c, false, pagination.MaxStreamLimit)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Mark cart as checked out
-	cart.IsCheckedOut = true
-	cart.CheckedOutAt = &now
-	if err := tx.Save(&cart).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update cart status"})
+	filter := repository.OrderFilter{
+		Number:      c.Query("number"),
+		Metadata:    c.QueryMap("metadata"),
+		NeedsReview: c.Query("needs_review") == "true",
+	}
+	if err := services.CheckMetadata(filter.Metadata); err != nil {
+		c.Error(err)
+		return
+	}
+	pos, err := svc.Orders.Locate(c.Request.Context(), 0, filter, page)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to process order"})
+	stream := newJSONStream(c, "orders")
+	stream.Locate(pos)
+	next, err := svc.Orders.Each(c.Request.Context(), filter, page,
+		func(order *models.Order) error {
+			return stream.Write(orderResponse(*order))
+		})
+	if err != nil {
+		stream.Fail("failed to fetch orders", err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "order created successfully",
-		"order_id": order.ID,
-	})
+	stream.End(next)
 }
 
-// GetOrders returns all orders (admin only)
-func GetOrders(c *gin.Context) {
-	var orders []models.Order
-	result := database.GetDB().Preload("User", func(db *gorm.DB) *gorm.DB {
-		return db.Select("id, username") // Only select necessary user fields
-	}).Preload("Cart.CartItems.Item").Find(&orders)
+// orderStatuses are the statuses an order may have
+var orderStatuses = []string{
+	models.OrderPending, models.OrderCompleted, models.OrderShipped, models.OrderDelivered, models.OrderCancelled,
+}
+
+// GetUserOrders returns a page of the current user's orders, newest first,
+// optionally filtered by status and by the from and to dates they were
+// placed. Pages are walked with after and before cursors on the orders'
+// creation time and ID. With summary=true the orders are listed without
+// their items.
+func GetUserOrders(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
 
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch orders"})
+	page, err := pagination.KeysetFromRequest(c)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Format response
-	var response []map[string]interface{}
-	for _, order := range orders {
-		orderData := map[string]interface{}{
-			"id":         order.ID,
-			"user_id":    order.UserID,
-			"username":   order.User.Username,
-			"total":      order.Total,
-			"status":     order.Status,
-			"created_at": order.CreatedAt,
-			"items":      []map[string]interface{}{},
-		}
+	filter, err := orderHistoryFilter(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	summary, err := strconv.ParseBool(c.DefaultQuery("summary", "false"))
+	if err != nil {
+		c.Error(apperrors.Validation("invalid summary"))
+		return
+	}
 
-		// Add cart items
-		for _, item := range order.Cart.CartItems {
-			orderData["items"] = append(orderData["items"].([]map[string]interface{}), map[string]interface{}{
-				"id":          item.ItemID,
-				"name":        item.Item.Name,
-				"description": item.Item.Description,
-				"price":       item.Item.Price,
-				"quantity":    item.Quantity,
-			})
+	if summary {
+		orders, cursors, err := svc.Orders.Summaries(c.Request.Context(), currentUser.ID, filter, page)
+		if err != nil {
+			c.Error(err)
+			return
 		}
+		response := make([]OrderSummaryResponse, len(orders))
+		for i, order := range orders {
+			response[i] = OrderSummaryResponse{
+				ID:        order.ID,
+				Number:    order.Number,
+				Total:     order.Total,
+				Status:    order.Status,
+				CreatedAt: order.CreatedAt,
+			}
+		}
+		setKeysetLinks(c, cursors)
+		c.JSON(http.StatusOK, OrderSummariesResponse{Orders: response, NextCursor: cursors.Next, PrevCursor: cursors.Prev})
+		return
+	}
 
-		response = append(response, orderData)
+	orders, cursors, err := svc.Orders.ListByUser(c.Request.Context(), currentUser.ID, filter, page)
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"orders": response})
+	response := []OrderResponse{}
+	for _, order := range orders {
+		response = append(response, orderResponse(order))
+	}
+
+	setKeysetLinks(c, cursors)
+	render(c, http.StatusOK, OrdersResponse{Orders: response, NextCursor: cursors.Next, PrevCursor: cursors.Prev})
 }
 
-// GetUserOrders returns the current user's orders
-func GetUserOrders(c *gin.Context) {
+// orderHistoryFilter parses the number, status, from and to query
+// parameters of GetUserOrders. Statuses may be repeated or comma-separated;
+// from and to are dates (YYYY-MM-DD), both included, or RFC 3339 times.
+func orderHistoryFilter(c *gin.Context) (repository.OrderFilter, error) {
+	filter := repository.OrderFilter{Number: c.Query("number")}
+	for _, value := range c.QueryArray("status") {
+		for _, status := range strings.Split(value, ",") {
+			if !slices.Contains(orderStatuses, status) {
+				return filter, apperrors.Validation("status must be one of " + strings.Join(orderStatuses, ", "))
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &filter.Since}, {"to", &filter.Until}} {
+		value := c.Query(p.name)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			day, dayErr := time.Parse(time.DateOnly, value)
+			if dayErr != nil {
+				return filter, apperrors.Validation("invalid " + p.name + ", expected YYYY-MM-DD or an RFC 3339 time")
+			}
+			at = day
+			// A to date includes the whole day
+			if p.name == "to" {
+				at = day.AddDate(0, 0, 1)
+			}
+		}
+		*p.dst = &at
+	}
+	if filter.Since != nil && filter.Until != nil && !filter.Since.Before(*filter.Until) {
+		return filter, apperrors.Validation("from must be before to")
+	}
+	return filter, nil
+}
+
+// GetOrder returns one of the current user's orders with its shipments and
+// their tracking events, and the warehouses it ships from
+func GetOrder(c *gin.Context) {
 	user, _ := c.Get("user")
 	currentUser := user.(models.User)
 
-	var orders []models.Order
-	result := database.GetDB().Preload("Cart.CartItems.Item").
-		Where("user_id = ?", currentUser.ID).
-		Order("created_at DESC").
-		Find(&orders)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrOrderNotFound)
+		return
+	}
 
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch orders"})
+	order, err := svc.Orders.Get(c.Request.Context(), currentUser.ID, uint(id))
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Format response
-	var response []map[string]interface{}
-	for _, order := range orders {
-		orderData := map[string]interface{}{
-			"id":         order.ID,
-			"total":      order.Total,
-			"status":     order.Status,
-			"created_at": order.CreatedAt,
-			"items":      []map[string]interface{}{},
-		}
+	response := orderResponse(order)
+	response.Sales = orderSales(order)
+	response.Bundles = orderBundles(order)
+	response.Downloads = orderDownloads(order)
+	response.Backorders = orderBackorders(order)
+	response.Shipments = []ShipmentResponse{}
+	for _, shipment := range order.Shipments {
+		response.Shipments = append(response.Shipments, shipmentResponse(shipment))
+	}
+	for _, a := range order.Allocations {
+		response.Allocations = append(response.Allocations, AllocationResponse{
+			ItemID:      a.ItemID,
+			WarehouseID: a.WarehouseID,
+			Quantity:    a.Quantity,
+		})
+	}
+	if order.ShippingAddress != (models.PostalAddress{}) {
+		response.ShippingAddress = &order.ShippingAddress
+	}
+
+	render(c, http.StatusOK, response)
+}
 
-		// Add cart items
-		for _, item := range order.Cart.CartItems {
-			orderData["items"] = append(orderData["items"].([]map[string]interface{}), map[string]interface{}{
-				"id":          item.ItemID,
-				"name":        item.Item.Name,
-				"description": item.Item.Description,
-				"price":       item.Item.Price,
-				"quantity":    item.Quantity,
-			})
+// BulkUpdateOrderStatus moves many orders to a new status at once, all or
+// none of them (admin only). Each owner is notified as by
+// UpdateOrderStatus.
+func BulkUpdateOrderStatus(c *gin.Context) {
+	var req BulkUpdateOrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	results, err := svc.Orders.BulkUpdateStatus(c.Request.Context(), req.OrderIDs, req.Status)
+	response := BulkOrderStatusResponse{Results: make([]BulkOrderStatusResult, len(results))}
+	for i, result := range results {
+		response.Results[i] = BulkOrderStatusResult{
+			OrderID: result.OrderID,
+			Number:  result.Number,
+			Status:  result.Status,
+			Error:   result.Err,
+		}
+	}
+	if err != nil {
+		if results != nil {
+			err = apperrors.From(err).WithDetails(response.Results)
 		}
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateOrderStatus moves an order to a new status (admin only). The owner
+// is notified over /ws/orders.
+func UpdateOrderStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrOrderNotFound)
+		return
+	}
+
+	var req UpdateOrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	order, err := svc.Orders.UpdateStatus(c.Request.Context(), uint(id), req.Status)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-		response = append(response, orderData)
+	render(c, http.StatusOK, orderResponse(order))
+}
+
+// ReviewOrder records that an admin reviewed an order flagged as a likely
+// duplicate, taking it off the orders awaiting review (admin only). It
+// does not change the order's status; cancel it to undo the duplicate.
+func ReviewOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrOrderNotFound)
+		return
+	}
+
+	order, err := svc.Orders.MarkReviewed(c.Request.Context(), uint(id))
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"orders": response})
+	render(c, http.StatusOK, orderResponse(order))
 }
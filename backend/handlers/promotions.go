@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreatePromotionRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+	Kind string `json:"kind" binding:"required,oneof=buy_x_get_y tier percent_off"`
+	// ItemID is the item the promotion applies to; percent_off promotions
+	// may apply to a Category instead
+	ItemID   *uint  `json:"item_id"`
+	Category string `json:"category" binding:"max=64"`
+	// BuyQuantity and GetQuantity make GetQuantity of every BuyQuantity
+	// plus GetQuantity units free, for buy_x_get_y promotions
+	BuyQuantity int `json:"buy_quantity" binding:"min=0"`
+	GetQuantity int `json:"get_quantity" binding:"min=0"`
+	// MinQuantity is the quantity from which tier promotions apply
+	MinQuantity int `json:"min_quantity" binding:"min=0"`
+	// Percent is taken off by tier and percent_off promotions
+	Percent float64 `json:"percent" binding:"min=0,max=100"`
+	// StartsAt and EndsAt bound when the promotion runs; either may be
+	// omitted
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+// CreatePromotion adds a promotion to the store (admin only)
+func CreatePromotion(c *gin.Context) {
+	var req CreatePromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+	if err := validatePromotion(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	promotion := models.Promotion{
+		Name:        req.Name,
+		Kind:        req.Kind,
+		ItemID:      req.ItemID,
+		Category:    req.Category,
+		BuyQuantity: req.BuyQuantity,
+		GetQuantity: req.GetQuantity,
+		MinQuantity: req.MinQuantity,
+		Percent:     req.Percent,
+		StartsAt:    req.StartsAt,
+		EndsAt:      req.EndsAt,
+	}
+	if err := svc.Promotions.Create(c.Request.Context(), &promotion); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, PromotionResponse{Promotion: promotion})
+}
+
+// validatePromotion checks the fields each kind of promotion needs
+func validatePromotion(req CreatePromotionRequest) error {
+	if (req.ItemID == nil) == (req.Category == "") {
+		return apperrors.Validation("exactly one of item_id and category is required")
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return apperrors.Validation("ends_at must be after starts_at")
+	}
+
+	switch req.Kind {
+	case models.PromotionBuyXGetY:
+		if req.ItemID == nil {
+			return apperrors.Validation("buy_x_get_y promotions apply to an item_id")
+		}
+		if req.BuyQuantity < 1 || req.GetQuantity < 1 {
+			return apperrors.Validation("buy_quantity and get_quantity must be positive for buy_x_get_y promotions")
+		}
+	case models.PromotionTier:
+		if req.ItemID == nil {
+			return apperrors.Validation("tier promotions apply to an item_id")
+		}
+		if req.MinQuantity < 2 {
+			return apperrors.Validation("min_quantity must be at least 2 for tier promotions")
+		}
+	}
+	if req.Kind != models.PromotionBuyXGetY && req.Percent <= 0 {
+		return apperrors.Validation("percent must be positive for " + req.Kind + " promotions")
+	}
+	return nil
+}
+
+// GetPromotions lists the store's promotions, including those not running
+// (admin only)
+func GetPromotions(c *gin.Context) {
+	promotions, err := svc.Promotions.List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if promotions == nil {
+		promotions = []models.Promotion{}
+	}
+
+	c.JSON(http.StatusOK, PromotionsResponse{Promotions: promotions})
+}
+
+// DeletePromotion removes a promotion (admin only)
+func DeletePromotion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrPromotionNotFound)
+		return
+	}
+
+	if err := svc.Promotions.Delete(c.Request.Context(), uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bytes"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/i18n"
+	"ecommerce-backend/models"
+	"ecommerce-backend/receipts"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOrderReceipt serves a printer-friendly receipt of one of the user's
+// orders in the language of their Accept-Language header, as HTML, or as a
+// PDF with format=pdf or when PDFs are accepted rather than HTML
+func GetOrderReceipt(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrOrderNotFound)
+		return
+	}
+
+	order, err := svc.Orders.Get(c.Request.Context(), currentUser.ID, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	pdf, err := wantsPDF(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	lang := i18n.Negotiate(c.GetHeader("Accept-Language"))
+	receipt := receipts.New(order, lang)
+
+	var body bytes.Buffer
+	contentType := "text/html; charset=utf-8"
+	if pdf {
+		contentType = "application/pdf"
+		err = receipts.PDF(&body, receipt)
+		c.Header("Content-Disposition", mime.FormatMediaType("inline",
+			map[string]string{"filename": "receipt-" + order.Number + ".pdf"}))
+	} else {
+		err = receipts.HTML(&body, receipt)
+	}
+	if err != nil {
+		c.Error(apperrors.Internal("failed to render receipt", err))
+		return
+	}
+
+	c.Header("Content-Language", lang)
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, contentType, body.Bytes())
+}
+
+// wantsPDF reports whether a receipt is asked for as a PDF
+func wantsPDF(c *gin.Context) (bool, error) {
+	switch c.Query("format") {
+	case "pdf":
+		return true, nil
+	case "html":
+		return false, nil
+	case "":
+		accept := c.GetHeader("Accept")
+		return strings.Contains(accept, "application/pdf") && !strings.Contains(accept, "text/html"), nil
+	}
+	return false, apperrors.Validation("format must be html or pdf")
+}
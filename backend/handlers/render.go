@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"ecommerce-backend/models"
+	"ecommerce-backend/visibility"
+
+	"github.com/gin-gonic/gin"
+)
+
+// viewerRole returns the role of the user making the request, or "" for
+// anonymous requests
+func viewerRole(c *gin.Context) string {
+	user, ok := c.Get("user")
+	if !ok {
+		return ""
+	}
+	return user.(models.User).Role
+}
+
+// render writes obj as JSON without the fields the role of the user making
+// the request may not see
+func render(c *gin.Context, status int, obj interface{}) {
+	c.JSON(status, visibility.Redact(obj, viewerRole(c)))
+}
@@ -0,0 +1,1030 @@
+package handlers
+
+import (
+	"ecommerce-backend/analytics"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/search"
+	"ecommerce-backend/services"
+	"time"
+)
+
+// Response bodies rendered by the handlers. They also drive the generated
+// OpenAPI schemas, so handlers should render these rather than ad-hoc maps.
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type TokenResponse struct {
+	Message string `json:"message"`
+	Token   string `json:"token"`
+}
+
+type ImpersonationResponse struct {
+	Message string `json:"message"`
+	Token   string `json:"token"`
+	UserID  uint   `json:"user_id"`
+	// ExpiresAt is when the token stops working; it cannot be renewed
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type DeactivationResponse struct {
+	Message string `json:"message"`
+	// ReactivateBefore is when the account is anonymized unless reactivated
+	ReactivateBefore time.Time `json:"reactivate_before"`
+}
+
+type UserResponse struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Email    string `json:"email,omitempty"`
+	VendorID *uint  `json:"vendor_id,omitempty"`
+	// AvatarURL is the user's avatar image, AvatarSize pixels square
+	AvatarURL string `json:"avatar_url,omitempty"`
+	Phone     string `json:"phone,omitempty"`
+	// PhoneVerified is set once the phone is verified with the code texted
+	// to it
+	PhoneVerified bool `json:"phone_verified"`
+	SMSOptIn      bool `json:"sms_opt_in"`
+	// ImpersonatedBy is the admin acting as the user, when the profile is
+	// requested with an impersonation token
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
+}
+
+// AdminUserResponse is a user as admins see them
+type AdminUserResponse struct {
+	User          UserResponse `json:"user"`
+	RegisteredAt  time.Time    `json:"registered_at"`
+	DeactivatedAt *time.Time   `json:"deactivated_at,omitempty"`
+	// TermsAcceptances are the versions of the terms of service and privacy
+	// policy the user accepted, newest first
+	TermsAcceptances []TermsAcceptanceResponse `json:"terms_acceptances"`
+}
+
+type TermsAcceptanceResponse struct {
+	// Document is terms, for the terms of service, or privacy
+	Document   string    `json:"document"`
+	Version    string    `json:"version"`
+	AcceptedAt time.Time `json:"accepted_at"`
+	// IP is erased once the user is anonymized
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+type UsersResponse struct {
+	Users      []UserResponse   `json:"users"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	Meta       *pagination.Meta `json:"meta,omitempty"`
+}
+
+type ItemResponse struct {
+	Item models.Item `json:"item"`
+	// Availability breaks the item's stock down by warehouse; it is only
+	// included in item detail responses of items held in warehouses
+	Availability []AvailabilityResponse `json:"availability,omitempty"`
+}
+
+type BulkItemsResponse struct {
+	Results []BulkItemResult `json:"results"`
+}
+
+// BulkItemResult is an item's price and stock after a bulk update, or the
+// error its change failed with
+type BulkItemResult struct {
+	ItemID uint             `json:"item_id"`
+	Price  float64          `json:"price"`
+	Stock  *int             `json:"stock"`
+	Error  *apperrors.Error `json:"error,omitempty"`
+}
+
+type BulkOrderStatusResponse struct {
+	Results []BulkOrderStatusResult `json:"results"`
+}
+
+// BulkOrderStatusResult is an order's status after a bulk status update,
+// or the error moving it failed with
+type BulkOrderStatusResult struct {
+	OrderID uint             `json:"order_id"`
+	Number  string           `json:"number,omitempty"`
+	Status  string           `json:"status,omitempty"`
+	Error   *apperrors.Error `json:"error,omitempty"`
+}
+
+type SyncInventoryResponse struct {
+	Results []SyncInventoryResult `json:"results"`
+}
+
+// SyncInventoryResult is the outcome of a row of an inventory sync:
+// created, updated, unchanged, conflict or failed, with the item as it is
+// after the row and the error the row failed or conflicted with
+type SyncInventoryResult struct {
+	SKU    string              `json:"sku"`
+	Status string              `json:"status"`
+	Item   *SyncedItemResponse `json:"item,omitempty"`
+	Error  *apperrors.Error    `json:"error,omitempty"`
+}
+
+type InventoryChangesResponse struct {
+	Items []SyncedItemResponse `json:"items"`
+	// Cursor is sent on the next pull to get the changes since this one
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+// SyncedItemResponse is an item as the inventory sync knows it. UpdatedAt
+// is sent back with the next change to detect conflicting changes.
+type SyncedItemResponse struct {
+	SKU       string    `json:"sku"`
+	ItemID    uint      `json:"item_id"`
+	Name      string    `json:"name"`
+	Price     float64   `json:"price"`
+	Stock     *int      `json:"stock"`
+	IsActive  bool      `json:"is_active"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type AvailabilityResponse struct {
+	WarehouseID uint   `json:"warehouse_id"`
+	Warehouse   string `json:"warehouse"`
+	Quantity    int    `json:"quantity"`
+}
+
+type ItemsResponse struct {
+	Items      []models.Item    `json:"items"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	Meta       *pagination.Meta `json:"meta,omitempty"`
+	// Currency is the currency the prices were converted to, at
+	// ExchangeRate, when one was asked for
+	Currency     string  `json:"currency,omitempty"`
+	ExchangeRate float64 `json:"exchange_rate,omitempty"`
+}
+
+type SearchItemsResponse struct {
+	Items []models.Item `json:"items"`
+	// Total is how many items match, across every page
+	Total  int64         `json:"total"`
+	Facets search.Facets `json:"facets"`
+}
+
+type CreateItemResponse struct {
+	Message string      `json:"message"`
+	Item    models.Item `json:"item"`
+}
+
+type CartItemResponse struct {
+	ID          uint    `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	Quantity    int     `json:"quantity"`
+	// BundleID is set on the lines of a bundle bought as a unit
+	BundleID *uint `json:"bundle_id,omitempty"`
+	// CompareAtPrice is the item's price to show struck through, and
+	// DiscountPercent how far below it the price is
+	CompareAtPrice  *float64 `json:"compare_at_price,omitempty"`
+	DiscountPercent int      `json:"discount_percent,omitempty"`
+}
+
+type CartResponse struct {
+	CartID   uint               `json:"cart_id"`
+	Items    []CartItemResponse `json:"items"`
+	Subtotal float64            `json:"subtotal"`
+	Discount float64            `json:"discount"`
+	// Total is the subtotal less the discount of the promotions and sales
+	Total      float64                    `json:"total"`
+	Promotions []AppliedPromotionResponse `json:"promotions"`
+	Sales      []AppliedSaleResponse      `json:"sales"`
+	Bundles    []AppliedBundleResponse    `json:"bundles"`
+	// Adjustments are the amounts taken off by price adjusters added to
+	// the store; they are part of the discount
+	Adjustments []AppliedAdjustmentResponse `json:"adjustments"`
+}
+
+type AppliedAdjustmentResponse struct {
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+}
+
+type AppliedPromotionResponse struct {
+	PromotionID uint    `json:"promotion_id"`
+	Name        string  `json:"name"`
+	Amount      float64 `json:"amount"`
+}
+
+type AppliedSaleResponse struct {
+	SaleID uint   `json:"sale_id"`
+	Name   string `json:"name"`
+	// ItemID is only included for orders, whose sales are listed per item
+	ItemID   uint    `json:"item_id,omitempty"`
+	Quantity int     `json:"quantity"`
+	Amount   float64 `json:"amount"`
+}
+
+type AppliedBundleResponse struct {
+	BundleID uint    `json:"bundle_id"`
+	Name     string  `json:"name"`
+	Quantity int     `json:"quantity"`
+	Amount   float64 `json:"amount"`
+}
+
+type BundleDetailResponse struct {
+	ID          uint    `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	// Value is what the items cost separately, and Saving what the bundle
+	// price takes off it
+	Value  float64              `json:"value"`
+	Saving float64              `json:"saving"`
+	Items  []BundleItemResponse `json:"items"`
+}
+
+type BundleItemResponse struct {
+	ItemID   uint    `json:"item_id"`
+	Name     string  `json:"name"`
+	Price    float64 `json:"price"`
+	Quantity int     `json:"quantity"`
+}
+
+type BundleResponse struct {
+	Bundle BundleDetailResponse `json:"bundle"`
+}
+
+type BundlesResponse struct {
+	Bundles []BundleDetailResponse `json:"bundles"`
+}
+
+type SaleResponse struct {
+	Sale models.Sale `json:"sale"`
+}
+
+type SalesResponse struct {
+	Sales []models.Sale `json:"sales"`
+}
+
+type PromotionResponse struct {
+	Promotion models.Promotion `json:"promotion"`
+}
+
+type PromotionsResponse struct {
+	Promotions []models.Promotion `json:"promotions"`
+}
+
+type AddToCartResponse struct {
+	Message string `json:"message"`
+	CartID  uint   `json:"cart_id"`
+}
+
+type CartShareResponse struct {
+	// URL is a signed link working until ExpiresAt while the cart is
+	// unchanged
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type CartsResponse struct {
+	Carts      []models.Cart    `json:"carts"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	Meta       *pagination.Meta `json:"meta,omitempty"`
+}
+
+type OrderResponse struct {
+	ID             uint               `json:"id"`
+	Number         string             `json:"number"`
+	UserID         uint               `json:"user_id,omitempty" visible:"admin"`
+	Username       string             `json:"username,omitempty" visible:"admin"`
+	Total          float64            `json:"total"`
+	ShippingCost   float64            `json:"shipping_cost"`
+	Discount       float64            `json:"discount"`
+	GiftCardAmount float64            `json:"gift_card_amount"`
+	Status         string             `json:"status"`
+	CreatedAt      time.Time          `json:"created_at"`
+	Items          []CartItemResponse `json:"items"`
+	// Promotions, Sales and Bundles are those that made up Discount;
+	// Sales and Bundles are only included in order detail responses
+	Promotions []AppliedPromotionResponse `json:"promotions"`
+	Sales      []AppliedSaleResponse      `json:"sales,omitempty"`
+	Bundles    []AppliedBundleResponse    `json:"bundles,omitempty"`
+	// Downloads are the digital items bought, with signed links, and
+	// Backorders the units bought beyond stock; they are only included in
+	// order detail responses
+	Downloads  []DownloadResponse  `json:"downloads,omitempty"`
+	Backorders []BackorderResponse `json:"backorders,omitempty"`
+	// Metadata are the custom fields given at checkout
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Shipments is only included in order detail responses
+	Shipments []ShipmentResponse `json:"shipments,omitempty"`
+	// Allocations are the warehouses the items ship from; they are only
+	// included in order detail responses
+	Allocations []AllocationResponse `json:"allocations,omitempty"`
+	// ShippingAddress is only included in order detail responses, for
+	// orders shipped to an address
+	ShippingAddress *models.PostalAddress `json:"shipping_address,omitempty"`
+	// Delivery is the delivery slot booked at checkout, if any
+	Delivery *OrderDeliveryResponse `json:"delivery,omitempty"`
+	// Settlement is the currency the order was settled in, for orders
+	// that recorded it
+	Settlement *OrderSettlementResponse `json:"settlement,omitempty"`
+	// Review is set for orders flagged as likely duplicates
+	Review *OrderReviewResponse `json:"review,omitempty" visible:"admin"`
+}
+
+// OrderReviewResponse tells which order an order likely duplicates, and
+// when an admin reviewed it
+type OrderReviewResponse struct {
+	DuplicateOf uint       `json:"duplicate_of"`
+	ReviewedAt  *time.Time `json:"reviewed_at"`
+}
+
+// OrderDeliveryResponse is the delivery slot an order is booked into
+type OrderDeliveryResponse struct {
+	SlotID uint   `json:"slot_id"`
+	Date   string `json:"date"`
+	Window string `json:"window"`
+}
+
+// OrderSettlementResponse is the currency an order was settled in, the
+// rate from the base currency and the total in that currency
+type OrderSettlementResponse struct {
+	Currency     string  `json:"currency"`
+	ExchangeRate float64 `json:"exchange_rate"`
+	Total        float64 `json:"total"`
+}
+
+type AllocationResponse struct {
+	ItemID      uint `json:"item_id"`
+	WarehouseID uint `json:"warehouse_id"`
+	Quantity    int  `json:"quantity"`
+}
+
+type TrackingEventResponse struct {
+	Status      string    `json:"status"`
+	Description string    `json:"description"`
+	Location    string    `json:"location"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+type ShipmentResponse struct {
+	ID             uint                    `json:"id"`
+	OrderID        uint                    `json:"order_id"`
+	Carrier        string                  `json:"carrier"`
+	TrackingNumber string                  `json:"tracking_number"`
+	Status         string                  `json:"status"`
+	DeliveredAt    *time.Time              `json:"delivered_at,omitempty"`
+	Events         []TrackingEventResponse `json:"events"`
+}
+
+// OrderSummaryResponse is an order listed without its items
+type OrderSummaryResponse struct {
+	ID        uint      `json:"id"`
+	Number    string    `json:"number"`
+	Total     float64   `json:"total"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OrderSummariesResponse struct {
+	Orders     []OrderSummaryResponse `json:"orders"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+	PrevCursor string                 `json:"prev_cursor,omitempty"`
+}
+
+type OrdersResponse struct {
+	Orders     []OrderResponse `json:"orders"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	// PrevCursor is only set on the current user's order history
+	PrevCursor string           `json:"prev_cursor,omitempty"`
+	Meta       *pagination.Meta `json:"meta,omitempty"`
+}
+
+type SubOrderResponse struct {
+	ID        uint               `json:"id"`
+	OrderID   uint               `json:"order_id"`
+	Total     float64            `json:"total"`
+	Status    string             `json:"status"`
+	CreatedAt time.Time          `json:"created_at"`
+	Items     []CartItemResponse `json:"items"`
+}
+
+type SubOrdersResponse struct {
+	Orders     []SubOrderResponse `json:"orders"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+type ShippingMethodResponse struct {
+	ShippingMethod models.ShippingMethod `json:"shipping_method"`
+}
+
+type ShippingMethodsResponse struct {
+	ShippingMethods []models.ShippingMethod `json:"shipping_methods"`
+}
+
+type DeliverySlotResponse struct {
+	ID        uint   `json:"id"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Capacity  int    `json:"capacity"`
+}
+
+type DeliverySlotsResponse struct {
+	Slots []DeliverySlotResponse `json:"slots"`
+}
+
+// CheckoutSlotResponse is a delivery slot on a date customers can book
+type CheckoutSlotResponse struct {
+	SlotID    uint   `json:"slot_id"`
+	Date      string `json:"date"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	// Remaining is how many more orders the slot takes on the date
+	Remaining int  `json:"remaining"`
+	Available bool `json:"available"`
+}
+
+type CheckoutSlotsResponse struct {
+	// Timezone is the time zone of the slots' times of day
+	Timezone string                 `json:"timezone"`
+	Slots    []CheckoutSlotResponse `json:"slots"`
+}
+
+type AddressResponse struct {
+	ID uint `json:"id"`
+	models.PostalAddress
+	// Status is deliverable, undeliverable or unverified
+	Status       string    `json:"status"`
+	StatusReason string    `json:"status_reason,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type MaintenanceResponse struct {
+	Enabled bool `json:"enabled"`
+	// Configured is set while MAINTENANCE_MODE keeps the mode on; it
+	// cannot be switched off through the API then
+	Configured bool `json:"configured"`
+	// Until is when the mode ends by itself, if it was switched on for a
+	// while
+	Until *time.Time `json:"until,omitempty"`
+	// RetryAfter is the Retry-After, in seconds, sent with refused writes
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+type DuplicateOrderSettingsResponse struct {
+	// Policy and WindowMinutes are the store's own settings, empty and 0
+	// when it uses the configured ones
+	Policy        string `json:"policy"`
+	WindowMinutes int    `json:"window_minutes"`
+	// EffectivePolicy and EffectiveWindowMinutes are those applied
+	EffectivePolicy        string `json:"effective_policy"`
+	EffectiveWindowMinutes int    `json:"effective_window_minutes"`
+}
+
+type JWTKeysResponse struct {
+	Keys []JWTKeyResponse `json:"keys"`
+}
+
+type JWTKeyResponse struct {
+	// KID is the kid header of the tokens the key signs; it is empty for
+	// JWT_SECRET_KEY, which signs tokens without one
+	KID string `json:"kid"`
+	// Source is config for keys of JWT_SECRET_KEY and JWT_KEYS, rotated
+	// for keys made by rotating
+	Source  string `json:"source"`
+	Signing bool   `json:"signing"`
+	// ExpiresAt is when the key stops verifying tokens, once a newer key
+	// has signed for the lifetime of a token
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type FeatureFlagResponse struct {
+	Flag models.FeatureFlag `json:"flag"`
+}
+
+type FeatureFlagsResponse struct {
+	Flags []models.FeatureFlag `json:"flags"`
+}
+
+// EnabledFlagsResponse tells, for each feature flag, whether it is on for
+// the user
+type EnabledFlagsResponse struct {
+	Flags map[string]bool `json:"flags"`
+}
+
+type AddressesResponse struct {
+	Addresses []AddressResponse `json:"addresses"`
+}
+
+type DeviceResponse struct {
+	ID       uint   `json:"id"`
+	Platform string `json:"platform"`
+	Token    string `json:"token"`
+	// LastSeenAt is when the app last registered the token
+	LastSeenAt time.Time `json:"last_seen_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type DevicesResponse struct {
+	Devices []DeviceResponse `json:"devices"`
+}
+
+type StockSubscriptionResponse struct {
+	ID     uint   `json:"id"`
+	ItemID uint   `json:"item_id"`
+	Item   string `json:"item"`
+	// CreatedAt is when the user subscribed; subscriptions are deleted
+	// once the user is told the item is back in stock
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type StockSubscriptionsResponse struct {
+	Subscriptions []StockSubscriptionResponse `json:"subscriptions"`
+}
+
+type WarehouseResponse struct {
+	Warehouse models.Warehouse `json:"warehouse"`
+}
+
+type WarehousesResponse struct {
+	Warehouses []models.Warehouse `json:"warehouses"`
+}
+
+type StockLevelResponse struct {
+	ItemID   uint   `json:"item_id"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+}
+
+type WarehouseStockResponse struct {
+	WarehouseID uint                 `json:"warehouse_id"`
+	Stock       []StockLevelResponse `json:"stock"`
+}
+
+type InventoryMovementsResponse struct {
+	Movements  []models.InventoryMovement `json:"movements"`
+	NextCursor string                     `json:"next_cursor,omitempty"`
+}
+
+type StockTransferResponse struct {
+	Transfer models.StockTransfer `json:"transfer"`
+}
+
+type StockTransfersResponse struct {
+	Transfers  []models.StockTransfer `json:"transfers"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+type ShippingQuoteLine struct {
+	ShippingMethodID uint    `json:"shipping_method_id"`
+	Name             string  `json:"name"`
+	Kind             string  `json:"kind"`
+	Cost             float64 `json:"cost"`
+	Total            float64 `json:"total"`
+}
+
+type ShippingQuoteResponse struct {
+	CartID   uint                `json:"cart_id"`
+	Subtotal float64             `json:"subtotal"`
+	Discount float64             `json:"discount"`
+	WeightKg float64             `json:"weight_kg"`
+	Quotes   []ShippingQuoteLine `json:"quotes"`
+}
+
+type VendorResponse struct {
+	Vendor models.Vendor `json:"vendor"`
+}
+
+type VendorsResponse struct {
+	Vendors []models.Vendor `json:"vendors"`
+}
+
+type CreateOrderResponse struct {
+	Message        string  `json:"message"`
+	OrderID        uint    `json:"order_id"`
+	Number         string  `json:"number"`
+	Total          float64 `json:"total"`
+	ShippingCost   float64 `json:"shipping_cost"`
+	Discount       float64 `json:"discount"`
+	GiftCardAmount float64 `json:"gift_card_amount"`
+	// Promotions, Sales and Bundles are those that made up Discount
+	Promotions []AppliedPromotionResponse `json:"promotions"`
+	Sales      []AppliedSaleResponse      `json:"sales,omitempty"`
+	Bundles    []AppliedBundleResponse    `json:"bundles,omitempty"`
+	// GiftCards are the cards bought with the order
+	GiftCards []GiftCardResponse `json:"gift_cards,omitempty"`
+	// Downloads are the digital items bought, with signed links
+	Downloads []DownloadResponse `json:"downloads,omitempty"`
+	// Backorders are the units bought beyond the items' stock, shipped
+	// once they are fulfilled
+	Backorders []BackorderResponse `json:"backorders,omitempty"`
+	// Metadata are the custom fields given at checkout
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// ShippingAddress is where the order ships, if an address was given
+	ShippingAddress *models.PostalAddress `json:"shipping_address,omitempty"`
+	// Delivery is the delivery slot booked, if one was chosen
+	Delivery *OrderDeliveryResponse `json:"delivery,omitempty"`
+	// Settlement is the currency the order is settled in
+	Settlement *OrderSettlementResponse `json:"settlement,omitempty"`
+}
+
+type BackorderResponse struct {
+	ID      uint   `json:"id"`
+	OrderID uint   `json:"order_id"`
+	ItemID  uint   `json:"item_id"`
+	Name    string `json:"name"`
+	// Kind is backorder or preorder
+	Kind        string     `json:"kind"`
+	Quantity    int        `json:"quantity"`
+	ExpectedAt  *time.Time `json:"expected_at,omitempty"`
+	FulfilledAt *time.Time `json:"fulfilled_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type BackordersResponse struct {
+	Backorders []BackorderResponse `json:"backorders"`
+}
+
+type DownloadResponse struct {
+	ID           uint   `json:"id"`
+	ItemID       uint   `json:"item_id"`
+	Name         string `json:"name"`
+	Downloaded   int    `json:"downloaded"`
+	MaxDownloads int    `json:"max_downloads"`
+	// URL is a signed link working until ExpiresAt; cancelled orders
+	// have none
+	URL       string     `json:"url,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type DownloadsResponse struct {
+	OrderID   uint               `json:"order_id"`
+	Downloads []DownloadResponse `json:"downloads"`
+}
+
+type GiftCardEntryResponse struct {
+	Kind      string    `json:"kind"`
+	Amount    float64   `json:"amount"`
+	OrderID   *uint     `json:"order_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type GiftCardResponse struct {
+	ID             uint       `json:"id"`
+	Code           string     `json:"code"`
+	InitialBalance float64    `json:"initial_balance"`
+	Balance        float64    `json:"balance"`
+	OrderID        *uint      `json:"order_id,omitempty"`
+	VoidedAt       *time.Time `json:"voided_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	// Entries is the card's ledger, only included for admins
+	Entries []GiftCardEntryResponse `json:"entries,omitempty"`
+}
+
+type GiftCardsResponse struct {
+	GiftCards  []GiftCardResponse `json:"gift_cards"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+type CreateAPIKeyResponse struct {
+	Message string `json:"message"`
+	ID      uint   `json:"id"`
+	Key     string `json:"key"`
+	Prefix  string `json:"prefix"`
+	Sandbox bool   `json:"sandbox"`
+	// WebhookSecret verifies the signatures of the key's webhooks; like
+	// the key, it is only shown once
+	WebhookSecret string `json:"webhook_secret"`
+}
+
+type WebhookSecretResponse struct {
+	Message       string `json:"message"`
+	WebhookSecret string `json:"webhook_secret"`
+}
+
+// QuotaResponse is how much of one of an API key's quotas has been used.
+// Limit and Remaining are null when the quota has no limit.
+type QuotaResponse struct {
+	Limit     *int      `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining *int64    `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+type APIKeyUsageDayResponse struct {
+	Date     string `json:"date"`
+	Requests int64  `json:"requests"`
+	Rejected int64  `json:"rejected"`
+}
+
+type APIKeyUsageResponse struct {
+	APIKeyID uint          `json:"api_key_id"`
+	Daily    QuotaResponse `json:"daily"`
+	Monthly  QuotaResponse `json:"monthly"`
+	// Days are the days of the current UTC month the key was used on
+	Days []APIKeyUsageDayResponse `json:"days"`
+}
+
+type APIKeyQuotasResponse struct {
+	ID           uint `json:"id"`
+	DailyQuota   *int `json:"daily_quota"`
+	MonthlyQuota *int `json:"monthly_quota"`
+}
+
+type AuditLogsResponse struct {
+	AuditLogs []models.AuditLog `json:"audit_logs"`
+}
+
+type PurgeRunResponse struct {
+	Run models.PurgeRun `json:"run"`
+}
+
+type PurgeRunsResponse struct {
+	Runs []models.PurgeRun `json:"purge_runs"`
+}
+
+type SimulationEvent struct {
+	Event       string    `json:"event"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+}
+
+type SimulateOrderResponse struct {
+	Message    string                 `json:"message"`
+	Order      map[string]interface{} `json:"order"`
+	WebhookURL string                 `json:"webhook_url"`
+	Timeline   []SimulationEvent      `json:"timeline"`
+}
+
+type RevenueResponse struct {
+	Period  string                    `json:"period"`
+	From    time.Time                 `json:"from"`
+	To      time.Time                 `json:"to"`
+	Buckets []analytics.RevenueBucket `json:"buckets"`
+}
+
+type OrderStatusCountsResponse struct {
+	From     time.Time               `json:"from"`
+	To       time.Time               `json:"to"`
+	Statuses []analytics.StatusCount `json:"statuses"`
+}
+
+type TopItemsResponse struct {
+	Period string              `json:"period"`
+	From   time.Time           `json:"from"`
+	To     time.Time           `json:"to"`
+	Items  []analytics.TopItem `json:"items"`
+}
+
+type SalesSummaryResponse struct {
+	From              time.Time `json:"from"`
+	To                time.Time `json:"to"`
+	Orders            int64     `json:"orders"`
+	Revenue           float64   `json:"revenue"`
+	AverageOrderValue float64   `json:"average_order_value"`
+}
+
+type ConversionFunnelResponse struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Stages are carts_created, carts_with_items, checkouts_started and
+	// orders_completed, each counting carts created in the range
+	Stages []FunnelStageResponse `json:"stages"`
+	// ConversionRate is the share of the carts created that became orders
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+type FunnelStageResponse struct {
+	Stage string `json:"stage"`
+	Carts int64  `json:"carts"`
+	// Rate is the share of the carts of the previous stage that reached
+	// this one; 1 for the first stage unless no cart was created
+	Rate float64 `json:"rate"`
+}
+
+type EmailTemplatesResponse struct {
+	Templates []string `json:"templates"`
+}
+
+// EmailPreviewResponse is an email rendered with sample data: its subject
+// and its plain-text and HTML bodies
+type EmailPreviewResponse struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+type ReadinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// cartItemResponse renders a cart line
+func cartItemResponse(ci models.CartItem) CartItemResponse {
+	return CartItemResponse{
+		ID:          ci.ItemID,
+		Name:        ci.Item.Name,
+		Description: ci.Item.Description,
+		Price:       ci.Item.Price,
+		Quantity:    ci.Quantity,
+		BundleID:    ci.BundleID,
+
+		CompareAtPrice:  ci.Item.CompareAtPrice,
+		DiscountPercent: ci.Item.DiscountPercent(),
+	}
+}
+
+// orderPromotions renders the promotions applied to an order
+func orderPromotions(order models.Order) []AppliedPromotionResponse {
+	promotions := []AppliedPromotionResponse{}
+	for _, p := range order.Promotions {
+		promotions = append(promotions, AppliedPromotionResponse{PromotionID: p.PromotionID, Name: p.Name, Amount: p.Amount})
+	}
+	return promotions
+}
+
+// orderSales renders the units an order bought at sale prices
+func orderSales(order models.Order) []AppliedSaleResponse {
+	var sales []AppliedSaleResponse
+	for _, p := range order.Sales {
+		sales = append(sales, AppliedSaleResponse{SaleID: p.SaleID, Name: p.Name, ItemID: p.ItemID, Quantity: p.Quantity, Amount: p.Amount})
+	}
+	return sales
+}
+
+// orderBundles renders the bundles bought with an order
+func orderBundles(order models.Order) []AppliedBundleResponse {
+	var bundles []AppliedBundleResponse
+	for _, b := range order.Bundles {
+		bundles = append(bundles, AppliedBundleResponse{BundleID: b.BundleID, Name: b.Name, Quantity: b.Quantity, Amount: b.Amount})
+	}
+	return bundles
+}
+
+// backorderResponse renders a backorder
+func backorderResponse(b models.OrderBackorder) BackorderResponse {
+	return BackorderResponse{
+		ID:          b.ID,
+		OrderID:     b.OrderID,
+		ItemID:      b.ItemID,
+		Name:        b.Name,
+		Kind:        b.Kind,
+		Quantity:    b.Quantity,
+		ExpectedAt:  b.ExpectedAt,
+		FulfilledAt: b.FulfilledAt,
+		CreatedAt:   b.CreatedAt,
+	}
+}
+
+// orderDelivery renders the delivery slot an order is booked into, or nil
+// if it has none
+func orderDelivery(order models.Order) *OrderDeliveryResponse {
+	if order.DeliverySlotID == nil {
+		return nil
+	}
+	return &OrderDeliveryResponse{
+		SlotID: *order.DeliverySlotID,
+		Date:   order.DeliveryDate,
+		Window: order.DeliveryWindow,
+	}
+}
+
+// orderSettlement renders the currency an order was settled in, or nil for
+// orders placed before currencies were recorded
+func orderSettlement(order models.Order) *OrderSettlementResponse {
+	if order.Currency == "" {
+		return nil
+	}
+	return &OrderSettlementResponse{
+		Currency:     order.Currency,
+		ExchangeRate: order.ExchangeRate,
+		Total:        order.SettlementTotal,
+	}
+}
+
+// orderResponse renders an order with its items; the responses of the
+// order endpoints add the parts they include to it
+func orderResponse(order models.Order) OrderResponse {
+	response := OrderResponse{
+		ID:             order.ID,
+		Number:         order.Number,
+		UserID:         order.UserID,
+		Username:       order.User.Username,
+		Total:          order.Total,
+		ShippingCost:   order.ShippingCost,
+		Discount:       order.Discount,
+		GiftCardAmount: order.GiftCardAmount,
+		Status:         order.Status,
+		CreatedAt:      order.CreatedAt,
+		Items:          []CartItemResponse{},
+		Promotions:     orderPromotions(order),
+		Metadata:       order.Metadata,
+		Delivery:       orderDelivery(order),
+		Settlement:     orderSettlement(order),
+		Review:         orderReview(order),
+	}
+	for _, item := range order.Cart.CartItems {
+		response.Items = append(response.Items, cartItemResponse(item))
+	}
+	return response
+}
+
+// orderReview renders whether an order was flagged as a likely duplicate
+// and reviewed, or nil if it was not flagged
+func orderReview(order models.Order) *OrderReviewResponse {
+	if order.DuplicateOfID == nil {
+		return nil
+	}
+	return &OrderReviewResponse{DuplicateOf: *order.DuplicateOfID, ReviewedAt: order.ReviewedAt}
+}
+
+// orderBackorders renders the backorders of an order
+func orderBackorders(order models.Order) []BackorderResponse {
+	var backorders []BackorderResponse
+	for _, b := range order.Backorders {
+		backorders = append(backorders, backorderResponse(b))
+	}
+	return backorders
+}
+
+// orderDownloads renders the downloads of an order with signed links
+func orderDownloads(order models.Order) []DownloadResponse {
+	links := svc.Downloads.Links(order)
+	var downloads []DownloadResponse
+	for _, d := range order.Downloads {
+		response := DownloadResponse{
+			ID:           d.ID,
+			ItemID:       d.ItemID,
+			Name:         d.Name,
+			Downloaded:   d.Downloaded,
+			MaxDownloads: d.MaxDownloads,
+		}
+		if link, ok := links[d.ID]; ok {
+			response.URL, response.ExpiresAt = link.URL, &link.ExpiresAt
+		}
+		downloads = append(downloads, response)
+	}
+	return downloads
+}
+
+// cartBundles renders the bundles in a cart
+func cartBundles(pricing services.Pricing) []AppliedBundleResponse {
+	bundles := []AppliedBundleResponse{}
+	for _, b := range pricing.Bundles {
+		bundles = append(bundles, AppliedBundleResponse{BundleID: b.Bundle.ID, Name: b.Bundle.Name, Quantity: b.Quantity, Amount: b.Amount})
+	}
+	return bundles
+}
+
+// bundleResponse renders a bundle with its items
+func bundleResponse(bundle models.Bundle) BundleDetailResponse {
+	response := BundleDetailResponse{
+		ID:          bundle.ID,
+		Name:        bundle.Name,
+		Description: bundle.Description,
+		Price:       bundle.Price,
+		Value:       bundle.Value(),
+		Saving:      bundle.Saving(),
+		Items:       []BundleItemResponse{},
+	}
+	for _, bi := range bundle.Items {
+		response.Items = append(response.Items, BundleItemResponse{
+			ItemID:   bi.ItemID,
+			Name:     bi.Item.Name,
+			Price:    bi.Item.Price,
+			Quantity: bi.Quantity,
+		})
+	}
+	return response
+}
+
+// cartSales renders the sales applied to a cart
+func cartSales(pricing services.Pricing) []AppliedSaleResponse {
+	sales := []AppliedSaleResponse{}
+	for _, s := range pricing.Sales {
+		sales = append(sales, AppliedSaleResponse{SaleID: s.Sale.ID, Name: s.Sale.Name, Quantity: s.Quantity, Amount: s.Amount})
+	}
+	return sales
+}
+
+// cartAdjustments renders the adjustments made to a cart's price
+func cartAdjustments(pricing services.Pricing) []AppliedAdjustmentResponse {
+	adjustments := []AppliedAdjustmentResponse{}
+	for _, a := range pricing.Adjustments {
+		adjustments = append(adjustments, AppliedAdjustmentResponse{Name: a.Name, Amount: a.Amount})
+	}
+	return adjustments
+}
+
+// cartPromotions renders the promotions applied to a cart
+func cartPromotions(pricing services.Pricing) []AppliedPromotionResponse {
+	promotions := []AppliedPromotionResponse{}
+	for _, p := range pricing.Promotions {
+		promotions = append(promotions, AppliedPromotionResponse{PromotionID: p.Promotion.ID, Name: p.Promotion.Name, Amount: p.Amount})
+	}
+	return promotions
+}
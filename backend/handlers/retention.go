@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/database"
+	"ecommerce-backend/jobs"
+	"ecommerce-backend/models"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPurgeRunLimit = 20
+	maxPurgeRunLimit     = 100
+)
+
+// TriggerPurge starts a run of the retention policies, which purge expired
+// sessions, stale carts and old audit logs, without waiting for the next
+// scheduled run (admin only). The run goes on in the background; poll it
+// to see its outcome.
+func TriggerPurge(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	run, err := jobs.StartPurge(c.Request.Context(), currentUser.ID)
+	if err != nil {
+		if errors.Is(err, jobs.ErrPurgeRunning) {
+			c.Error(apperrors.ErrPurgeInProgress)
+			return
+		}
+		c.Error(apperrors.Internal("failed to start purge run", err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, PurgeRunResponse{Run: run})
+}
+
+// GetPurgeRuns returns the latest purge runs, newest first (admin only)
+func GetPurgeRuns(c *gin.Context) {
+	limit := defaultPurgeRunLimit
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	if limit > maxPurgeRunLimit {
+		limit = maxPurgeRunLimit
+	}
+
+	var runs []models.PurgeRun
+	if err := database.WithContext(c.Request.Context()).Order("id DESC").Limit(limit).Find(&runs).Error; err != nil {
+		c.Error(apperrors.Internal("failed to fetch purge runs", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, PurgeRunsResponse{Runs: runs})
+}
+
+// GetPurgeRun returns a purge run with the outcome of each policy once it
+// has finished (admin only)
+func GetPurgeRun(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrPurgeRunNotFound)
+		return
+	}
+
+	var run models.PurgeRun
+	if err := database.WithContext(c.Request.Context()).First(&run, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.Error(apperrors.ErrPurgeRunNotFound)
+			return
+		}
+		c.Error(apperrors.Internal("failed to fetch purge run", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, PurgeRunResponse{Run: run})
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateSaleRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+	// ItemID is the item on sale; sales may cover a Category instead
+	ItemID   *uint  `json:"item_id"`
+	Category string `json:"category" binding:"max=64"`
+	// Price is the item's sale price; sales of a category take Percent
+	// off instead
+	Price   *float64 `json:"price" binding:"omitempty,gt=0"`
+	Percent float64  `json:"percent" binding:"min=0,max=100"`
+	// PerUserLimit caps the units each customer buys at the sale price;
+	// 0 for no limit
+	PerUserLimit int       `json:"per_user_limit" binding:"min=0"`
+	StartsAt     time.Time `json:"starts_at" binding:"required"`
+	EndsAt       time.Time `json:"ends_at" binding:"required"`
+}
+
+// CreateSale adds a flash sale to the store (admin only)
+func CreateSale(c *gin.Context) {
+	var req CreateSaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+	if err := validateSale(req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	sale := models.Sale{
+		Name:         req.Name,
+		ItemID:       req.ItemID,
+		Category:     req.Category,
+		Price:        req.Price,
+		Percent:      req.Percent,
+		PerUserLimit: req.PerUserLimit,
+		StartsAt:     req.StartsAt,
+		EndsAt:       req.EndsAt,
+	}
+	if err := svc.Sales.Create(c.Request.Context(), &sale); err != nil {
+		c.Error(err)
+		return
+	}
+	if sale.Active {
+		invalidateItems(c.Request.Context())
+	}
+
+	c.JSON(http.StatusCreated, SaleResponse{Sale: sale})
+}
+
+// validateSale checks that the sale covers an item or a category and
+// discounts it
+func validateSale(req CreateSaleRequest) error {
+	if (req.ItemID == nil) == (req.Category == "") {
+		return apperrors.Validation("exactly one of item_id and category is required")
+	}
+	if !req.EndsAt.After(req.StartsAt) {
+		return apperrors.Validation("ends_at must be after starts_at")
+	}
+	if (req.Price == nil) == (req.Percent == 0) {
+		return apperrors.Validation("exactly one of price and percent is required")
+	}
+	if req.Price != nil && req.ItemID == nil {
+		return apperrors.Validation("sales of a category take a percent off")
+	}
+	return nil
+}
+
+// GetSales lists the store's sales, including those not running (admin
+// only)
+func GetSales(c *gin.Context) {
+	sales, err := svc.Sales.List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if sales == nil {
+		sales = []models.Sale{}
+	}
+
+	c.JSON(http.StatusOK, SalesResponse{Sales: sales})
+}
+
+// DeleteSale removes a sale, ending it if it is running (admin only)
+func DeleteSale(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrSaleNotFound)
+		return
+	}
+
+	if err := svc.Sales.Delete(c.Request.Context(), uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	c.Status(http.StatusNoContent)
+}
+
+// ScheduleSales starts and ends sales as their windows open and close,
+// discarding cached catalog responses when any did. It runs as the
+// flash-sales background job.
+func ScheduleSales(ctx context.Context) error {
+	changed, err := svc.Sales.Schedule(ctx)
+	if err != nil {
+		return err
+	}
+	if changed > 0 {
+		invalidateItems(ctx)
+	}
+	return nil
+}
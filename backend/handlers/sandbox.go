@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/database"
+	"ecommerce-backend/jobs"
+	"ecommerce-backend/models"
+	"ecommerce-backend/utils"
+	"ecommerce-backend/webhooks"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSimulationInterval = 5
+	maxSimulatedItems         = 3
+)
+
+// simulatedLifecycle is the sequence of statuses a simulated order moves through
+var simulatedLifecycle = []string{"created", "paid", "shipped", "delivered"}
+
+type SimulatedItem struct {
+	ItemID   uint `json:"item_id" binding:"required"`
+	Quantity int  `json:"quantity" binding:"required,min=1"`
+}
+
+type SimulateOrderRequest struct {
+	Items           []SimulatedItem `json:"items" binding:"omitempty,dive"`
+	IntervalSeconds int             `json:"interval_seconds" binding:"omitempty,min=1,max=300"`
+}
+
+// SimulateOrder generates a fake order lifecycle for sandbox integrations.
+// Nothing is persisted; webhooks are fired at the API key's webhook URL for
+// each status transition at the requested interval so partners can build
+// against our events. The URL cannot be given per request, so callers
+// cannot point the server at addresses of their choosing.
+func SimulateOrder(c *gin.Context) {
+	key, _ := c.Get("api_key")
+	apiKey := key.(models.APIKey)
+
+	var req SimulateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	webhookURL := apiKey.WebhookURL
+	if webhookURL == "" {
+		c.Error(apperrors.Validation("the api key has no webhook_url configured"))
+		return
+	}
+
+	interval := req.IntervalSeconds
+	if interval == 0 {
+		interval = defaultSimulationInterval
+	}
+
+	items, err := simulatedOrderItems(c.Request.Context(), req.Items)
+	if err != nil {
+		c.Error(apperrors.Validation(err.Error()))
+		return
+	}
+	if len(items) == 0 {
+		c.Error(apperrors.Validation("no items available to simulate an order"))
+		return
+	}
+
+	// Calculate total
+	var total float64
+	for _, item := range items {
+		total += item["price"].(float64) * float64(item["quantity"].(int))
+	}
+
+	suffix, err := utils.GenerateRandomString(16)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to simulate order", err))
+		return
+	}
+
+	order := map[string]interface{}{
+		"id":         "sim_" + suffix,
+		"total":      total,
+		"status":     simulatedLifecycle[0],
+		"created_at": time.Now(),
+		"items":      items,
+	}
+
+	jobs.Go("sandbox-simulation", func(ctx context.Context) {
+		runOrderSimulation(ctx, webhookURL, apiKey.WebhookSecret, order, time.Duration(interval)*time.Second)
+	})
+
+	// Build the expected timeline for the caller
+	start := time.Now()
+	var timeline []SimulationEvent
+	for i, status := range simulatedLifecycle {
+		timeline = append(timeline, SimulationEvent{
+			Event:       "order." + status,
+			ScheduledAt: start.Add(time.Duration(i*interval) * time.Second),
+		})
+	}
+
+	c.JSON(http.StatusAccepted, SimulateOrderResponse{
+		Message:    "order simulation started",
+		Order:      order,
+		WebhookURL: webhookURL,
+		Timeline:   timeline,
+	})
+}
+
+// simulatedOrderItems resolves the requested items, or samples a few items
+// from the catalog when none are given. Inactive items are left out, as
+// they are from the catalog.
+func simulatedOrderItems(ctx context.Context, requested []SimulatedItem) ([]map[string]interface{}, error) {
+	var items []map[string]interface{}
+
+	if len(requested) == 0 {
+		var catalog []models.Item
+		if err := database.WithContext(ctx).Where("is_active = ?", true).Limit(maxSimulatedItems).Find(&catalog).Error; err != nil {
+			return nil, err
+		}
+		for _, item := range catalog {
+			items = append(items, simulatedItem(item, 1))
+		}
+		return items, nil
+	}
+
+	for _, r := range requested {
+		var item models.Item
+		if err := database.WithContext(ctx).Where("is_active = ?", true).First(&item, r.ItemID).Error; err != nil {
+			return nil, fmt.Errorf("item %d not found", r.ItemID)
+		}
+		items = append(items, simulatedItem(item, r.Quantity))
+	}
+
+	return items, nil
+}
+
+func simulatedItem(item models.Item, quantity int) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          item.ID,
+		"name":        item.Name,
+		"description": item.Description,
+		"price":       item.Price,
+		"quantity":    quantity,
+	}
+}
+
+// runOrderSimulation walks the order through its lifecycle, firing a webhook
+// signed with secret for each status and waiting interval between
+// transitions. It stops early when ctx is cancelled.
+func runOrderSimulation(ctx context.Context, webhookURL, secret string, order map[string]interface{}, interval time.Duration) {
+	for i, status := range simulatedLifecycle {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+
+		// Copy the order so each event carries its own status snapshot
+		data := make(map[string]interface{}, len(order))
+		for k, v := range order {
+			data[k] = v
+		}
+		data["status"] = status
+
+		suffix, _ := utils.GenerateRandomString(16)
+		event := webhooks.Event{
+			ID:        "evt_" + suffix,
+			Type:      "order." + status,
+			Sandbox:   true,
+			CreatedAt: time.Now(),
+			Data:      data,
+		}
+
+		if err := webhooks.Deliver(ctx, webhookURL, secret, event); err != nil {
+			log.Printf("sandbox: failed to deliver %s for %s: %v", event.Type, order["id"], err)
+		}
+	}
+}
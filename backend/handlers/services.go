@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"ecommerce-backend/graph"
+	"ecommerce-backend/services"
+	"net/http"
+)
+
+var (
+	// svc holds the business logic the handlers delegate to
+	svc *services.Services
+	// graphqlServer executes GraphQL requests against svc
+	graphqlServer http.Handler
+)
+
+// SetServices sets the services used by the handlers. It must be called
+// before the router serves requests; tests can pass services built on
+// repository.NewMemory.
+func SetServices(s *services.Services) {
+	svc = s
+	graphqlServer = graph.NewServer(s)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/services"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateShippingMethodRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+	Kind string `json:"kind" binding:"required,oneof=flat free_over weight"`
+	// Rate is the cost per order, or the base cost of weight methods
+	Rate float64 `json:"rate" binding:"min=0"`
+	// FreeOver is the subtotal from which free_over methods are free
+	FreeOver float64 `json:"free_over" binding:"min=0"`
+	// PerKg is the cost per kilogram of weight methods
+	PerKg float64 `json:"per_kg" binding:"min=0"`
+}
+
+type ShippingQuoteRequest struct {
+	// ShippingMethodID limits the quote to one method
+	ShippingMethodID uint `json:"shipping_method_id"`
+}
+
+// CreateShippingMethod adds a shipping method to the store (admin only)
+func CreateShippingMethod(c *gin.Context) {
+	var req CreateShippingMethodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+	if req.Kind == models.ShippingFreeOver && req.FreeOver <= 0 {
+		c.Error(apperrors.Validation("free_over must be positive for free_over methods"))
+		return
+	}
+	if req.Kind == models.ShippingWeight && req.PerKg <= 0 {
+		c.Error(apperrors.Validation("per_kg must be positive for weight methods"))
+		return
+	}
+
+	method := models.ShippingMethod{
+		Name:     req.Name,
+		Kind:     req.Kind,
+		Rate:     req.Rate,
+		FreeOver: req.FreeOver,
+		PerKg:    req.PerKg,
+	}
+	if err := svc.Shipping.Create(c.Request.Context(), &method); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ShippingMethodResponse{ShippingMethod: method})
+}
+
+// GetShippingMethods lists the store's shipping methods (admin only)
+func GetShippingMethods(c *gin.Context) {
+	methods, err := svc.Shipping.List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if methods == nil {
+		methods = []models.ShippingMethod{}
+	}
+
+	c.JSON(http.StatusOK, ShippingMethodsResponse{ShippingMethods: methods})
+}
+
+// DeleteShippingMethod removes a shipping method (admin only)
+func DeleteShippingMethod(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrShippingMethodNotFound)
+		return
+	}
+
+	if err := svc.Shipping.Delete(c.Request.Context(), uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetShippingQuote prices shipping the current user's cart with each of the
+// store's methods, or with the one given
+func GetShippingQuote(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var req ShippingQuoteRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(apperrors.Binding(err))
+			return
+		}
+	}
+
+	cart, quotes, err := svc.Shipping.Quote(c.Request.Context(), currentUser.ID, req.ShippingMethodID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	pricing, err := svc.Promotions.Price(c.Request.Context(), cart)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := ShippingQuoteResponse{
+		CartID:   cart.ID,
+		Subtotal: pricing.Subtotal,
+		Discount: pricing.Discount,
+		WeightKg: math.Round(services.Weight(services.Shippable(cart))*1000) / 1000,
+		Quotes:   []ShippingQuoteLine{},
+	}
+	for _, q := range quotes {
+		response.Quotes = append(response.Quotes, ShippingQuoteLine{
+			ShippingMethodID: q.Method.ID,
+			Name:             q.Method.Name,
+			Kind:             q.Method.Kind,
+			Cost:             q.Cost,
+			Total:            math.Round((pricing.Total+q.Cost)*100) / 100,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/events"
+	"ecommerce-backend/tenant"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// sseHeartbeat keeps idle streams open through proxies
+	sseHeartbeat = 25 * time.Second
+	// sseRetry is the reconnection delay suggested to clients
+	sseRetry = 5 * time.Second
+)
+
+// adminEventTypes are the events streamed to the admin dashboard
+var adminEventTypes = []string{events.OrderCreated, events.PaymentFailed, events.StockLow}
+
+// AdminEvents streams the store's new orders, payment failures and
+// low-stock alerts as server-sent events (admin only). The types query
+// parameter limits the stream to a comma-separated subset. Clients that
+// reconnect with Last-Event-ID first receive the events they missed; if
+// those are no longer retained, a resync event tells them to reload their
+// data.
+func AdminEvents(c *gin.Context) {
+	types, err := parseEventTypes(c.Query("types"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	storeID := tenant.StoreOrDefault(c.Request.Context())
+	filter := func(e events.Event) bool { return e.StoreID == storeID && types[e.Type] }
+
+	sub, missed, ok := events.Resume(c.GetHeader("Last-Event-ID"), filter)
+	defer sub.Close()
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", sseRetry.Milliseconds())
+	if c.GetHeader("Last-Event-ID") != "" && !ok {
+		fmt.Fprint(c.Writer, "event: resync\ndata: {}\n\n")
+	}
+	for _, event := range missed {
+		if writeSSE(c, event) != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event := <-sub.Events():
+			if err := writeSSE(c, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-shutdown:
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// parseEventTypes returns the admin event types named in a comma-separated
+// list, or all of them if the list is empty
+func parseEventTypes(list string) (map[string]bool, error) {
+	types := map[string]bool{}
+	if list == "" {
+		for _, t := range adminEventTypes {
+			types[t] = true
+		}
+		return types, nil
+	}
+
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimSpace(t)
+		known := false
+		for _, a := range adminEventTypes {
+			known = known || a == t
+		}
+		if !known {
+			return nil, apperrors.Validation("types must be a comma-separated list of " + strings.Join(adminEventTypes, ", "))
+		}
+		types[t] = true
+	}
+	return types, nil
+}
+
+// writeSSE writes an event in the text/event-stream format
+func writeSSE(c *gin.Context, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
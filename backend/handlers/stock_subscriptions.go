@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubscribeToStock has the current user told when the out-of-stock item is
+// back in stock
+func SubscribeToStock(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrItemNotFound)
+		return
+	}
+
+	sub, err := svc.Items.Subscribe(c.Request.Context(), currentUser.ID, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stockSubscriptionResponse(sub))
+}
+
+// UnsubscribeFromStock cancels the current user's back-in-stock alert for
+// the item
+func UnsubscribeFromStock(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrNotSubscribed)
+		return
+	}
+
+	if err := svc.Items.Unsubscribe(c.Request.Context(), currentUser.ID, uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetStockSubscriptions lists the items the current user is waiting on
+func GetStockSubscriptions(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	subs, err := svc.Items.Subscriptions(c.Request.Context(), currentUser.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := StockSubscriptionsResponse{Subscriptions: []StockSubscriptionResponse{}}
+	for _, sub := range subs {
+		response.Subscriptions = append(response.Subscriptions, stockSubscriptionResponse(sub))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func stockSubscriptionResponse(sub models.StockSubscription) StockSubscriptionResponse {
+	return StockSubscriptionResponse{
+		ID:        sub.ID,
+		ItemID:    sub.ItemID,
+		Item:      sub.Item.Name,
+		CreatedAt: sub.CreatedAt,
+	}
+}
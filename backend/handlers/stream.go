@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/visibility"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamFlushEvery is how many array elements are written between flushes
+const streamFlushEvery = 100
+
+// jsonStream writes a response of the form
+// {"<field>":[...],"next_cursor":...,"meta":...} one element at a time.
+// Nothing is written until the first element (or End), so errors before that
+// are still rendered as a normal error response.
+type jsonStream struct {
+	c       *gin.Context
+	field   string
+	meta    *pagination.Meta
+	count   int
+	started bool
+}
+
+func newJSONStream(c *gin.Context, field string) *jsonStream {
+	return &jsonStream{c: c, field: field}
+}
+
+func (s *jsonStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.c.Header("Content-Type", "application/json; charset=utf-8")
+	s.c.Status(http.StatusOK)
+	s.c.Writer.WriteString(`{"` + s.field + `":[`)
+}
+
+// Write appends one element to the array, without the fields the role of
+// the user making the request may not see
+func (s *jsonStream) Write(v interface{}) error {
+	data, err := json.Marshal(visibility.Redact(v, viewerRole(s.c)))
+	if err != nil {
+		return err
+	}
+
+	s.start()
+	if s.count > 0 {
+		s.c.Writer.WriteString(",")
+	}
+	s.count++
+	s.c.Writer.Write(data)
+
+	if s.count%streamFlushEvery == 0 {
+		s.c.Writer.Flush()
+	}
+	return nil
+}
+
+// Locate sets the Link header from the page's position and adds its meta
+// to the response. It must be called before the first element is written.
+func (s *jsonStream) Locate(pos pagination.Position) {
+	s.meta = setPageLinks(s.c, pos)
+}
+
+// End closes the array and the object, adding next_cursor and meta if set
+func (s *jsonStream) End(nextCursor string) {
+	s.start()
+	s.c.Writer.WriteString("]")
+	if nextCursor != "" {
+		s.c.Writer.WriteString(`,"next_cursor":` + strconv.Quote(nextCursor))
+	}
+	if s.meta != nil {
+		data, _ := json.Marshal(s.meta)
+		s.c.Writer.WriteString(`,"meta":`)
+		s.c.Writer.Write(data)
+	}
+	s.c.Writer.WriteString("}")
+}
+
+// Fail reports an error. Once output has started the status can no longer
+// change, so the response is left truncated (and therefore invalid JSON)
+// for the client to detect.
+func (s *jsonStream) Fail(message string, err error) {
+	if !s.started {
+		s.c.Error(apperrors.Internal(message, err))
+		return
+	}
+	logging.FromContext(s.c.Request.Context()).Error(message+" while streaming", "error", err)
+	s.c.Error(err)
+}
+
+// csvStream writes a CSV attachment one row at a time, after a header row.
+// Like jsonStream, nothing is written until the first row (or End). The
+// cursor of the following page is only known at the end, so it is sent as
+// the Next-Cursor trailer.
+type csvStream struct {
+	c        *gin.Context
+	filename string
+	header   []string
+	w        *csv.Writer
+	count    int
+}
+
+func newCSVStream(c *gin.Context, filename string, header []string) *csvStream {
+	return &csvStream{c: c, filename: filename, header: header}
+}
+
+func (s *csvStream) start() {
+	if s.w != nil {
+		return
+	}
+	s.c.Header("Content-Type", "text/csv; charset=utf-8")
+	s.c.Header("Content-Disposition", `attachment; filename="`+s.filename+`"`)
+	s.c.Header("Trailer", "Next-Cursor")
+	s.c.Status(http.StatusOK)
+	s.w = csv.NewWriter(s.c.Writer)
+	s.w.Write(s.header)
+}
+
+// Write appends one row
+func (s *csvStream) Write(row []string) error {
+	s.start()
+	if err := s.w.Write(row); err != nil {
+		return err
+	}
+	s.count++
+
+	if s.count%streamFlushEvery == 0 {
+		s.w.Flush()
+		s.c.Writer.Flush()
+	}
+	return s.w.Error()
+}
+
+// End flushes the rows and sets the Next-Cursor trailer if there is a
+// following page
+func (s *csvStream) End(nextCursor string) {
+	s.start()
+	s.w.Flush()
+	if nextCursor != "" {
+		s.c.Writer.Header().Set("Next-Cursor", nextCursor)
+	}
+}
+
+// Fail reports an error. Once output has started the response is left
+// truncated, without the Next-Cursor trailer.
+func (s *csvStream) Fail(message string, err error) {
+	if s.w == nil {
+		s.c.Error(apperrors.Internal(message, err))
+		return
+	}
+	s.w.Flush()
+	logging.FromContext(s.c.Request.Context()).Error(message+" while streaming", "error", err)
+	s.c.Error(err)
+}
+
+// jsonlStream writes a JSON Lines attachment, one JSON value per line. Like
+// csvStream, nothing is written until the first line (or End) and the
+// cursor of the following page is sent as the Next-Cursor trailer.
+type jsonlStream struct {
+	c        *gin.Context
+	filename string
+	count    int
+	started  bool
+}
+
+func newJSONLStream(c *gin.Context, filename string) *jsonlStream {
+	return &jsonlStream{c: c, filename: filename}
+}
+
+func (s *jsonlStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.c.Header("Content-Type", "application/x-ndjson")
+	s.c.Header("Content-Disposition", `attachment; filename="`+s.filename+`"`)
+	s.c.Header("Trailer", "Next-Cursor")
+	s.c.Status(http.StatusOK)
+}
+
+// Write appends one line
+func (s *jsonlStream) Write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.start()
+	if _, err := s.c.Writer.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	s.count++
+
+	if s.count%streamFlushEvery == 0 {
+		s.c.Writer.Flush()
+	}
+	return nil
+}
+
+// End sets the Next-Cursor trailer if there is a following page
+func (s *jsonlStream) End(nextCursor string) {
+	s.start()
+	if nextCursor != "" {
+		s.c.Writer.Header().Set("Next-Cursor", nextCursor)
+	}
+}
+
+// Fail reports an error. Once output has started the response is left
+// truncated, without the Next-Cursor trailer.
+func (s *jsonlStream) Fail(message string, err error) {
+	if !s.started {
+		s.c.Error(apperrors.Internal(message, err))
+		return
+	}
+	logging.FromContext(s.c.Request.Context()).Error(message+" while streaming", "error", err)
+	s.c.Error(err)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultSyncLimit and maxSyncLimit bound the changes pulled at once
+	defaultSyncLimit = 100
+	maxSyncLimit     = 1000
+)
+
+type SyncInventoryRequest struct {
+	Items []SyncInventoryRow `json:"items" binding:"required,min=1,max=1000,unique=SKU,dive"`
+}
+
+// SyncInventoryRow sets the price and stock of the item with the SKU,
+// creating it if there is none; omitted fields are left as they are
+type SyncInventoryRow struct {
+	SKU string `json:"sku" binding:"required,max=64"`
+	// Name is only used to create the item, which also needs a price
+	Name  string   `json:"name"`
+	Price *float64 `json:"price" binding:"omitempty,gt=0"`
+	Stock *int     `json:"stock" binding:"omitempty,min=0"`
+	// UpdatedAt, if set, is the item's updated_at as last synced; the row
+	// conflicts if the item has changed since
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+// SyncInventory applies the price and stock of items pushed by an external
+// system, such as an ERP, keyed by SKU. Each row is applied on its own and
+// reported with its outcome.
+func SyncInventory(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var req SyncInventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	changes := make([]services.SyncChange, len(req.Items))
+	for i, row := range req.Items {
+		changes[i] = services.SyncChange{SKU: row.SKU, Name: row.Name, Price: row.Price, Stock: row.Stock, SeenAt: row.UpdatedAt}
+	}
+	results, err := svc.Items.Sync(c.Request.Context(), currentUser, changes)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	response := SyncInventoryResponse{Results: make([]SyncInventoryResult, len(results))}
+	for i, result := range results {
+		row := SyncInventoryResult{SKU: result.SKU, Status: result.Status, Error: result.Err}
+		if result.Item.ID != 0 {
+			item := syncedItem(result.Item)
+			row.Item = &item
+		}
+		response.Results[i] = row
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetInventoryChanges returns the items with a SKU changed since a time,
+// given as since=RFC 3339 time, or after the cursor of the previous pull,
+// oldest change first. The cursor returned is to be sent on the next pull,
+// even when no items changed.
+func GetInventoryChanges(c *gin.Context) {
+	since, cursor := c.Query("since"), c.Query("cursor")
+	var after pagination.ChangeCursor
+	switch {
+	case since != "" && cursor != "":
+		c.Error(apperrors.Validation("give either since or cursor, not both"))
+		return
+	case since != "":
+		at, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			c.Error(apperrors.Validation("since must be an RFC 3339 time such as 2024-05-31T12:00:00Z"))
+			return
+		}
+		// With no ID the items changed at since are included
+		after = pagination.ChangeCursor{At: at}
+	case cursor != "":
+		var err error
+		if after, err = pagination.ParseChangeCursor(cursor); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	limit := defaultSyncLimit
+	if value := c.Query("limit"); value != "" {
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 1 {
+			c.Error(apperrors.Validation("invalid limit"))
+			return
+		}
+		limit = min(v, maxSyncLimit)
+	}
+
+	items, next, more, err := svc.Items.Changes(c.Request.Context(), after, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	response := InventoryChangesResponse{
+		Items:   make([]SyncedItemResponse, len(items)),
+		Cursor:  next.String(),
+		HasMore: more,
+	}
+	for i, item := range items {
+		response.Items[i] = syncedItem(item)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func syncedItem(item models.Item) SyncedItemResponse {
+	response := SyncedItemResponse{
+		ItemID:    item.ID,
+		Name:      item.Name,
+		Price:     item.Price,
+		Stock:     item.Stock,
+		IsActive:  item.IsActive,
+		UpdatedAt: item.UpdatedAt,
+	}
+	if item.SKU != nil {
+		response.SKU = *item.SKU
+	}
+	return response
+}
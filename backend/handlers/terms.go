@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"ecommerce-backend/models"
+	"ecommerce-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AcceptTerms records that the current user accepted the current terms of
+// service and privacy policy
+func AcceptTerms(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	if err := svc.Users.AcceptTerms(c.Request.Context(), currentUser.ID, termsSource(c)); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "terms accepted"})
+}
+
+// termsSource returns the request terms are accepted with
+func termsSource(c *gin.Context) services.TermsSource {
+	return services.TermsSource{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
+
+func termsAcceptanceResponse(acceptance models.TermsAcceptance) TermsAcceptanceResponse {
+	return TermsAcceptanceResponse{
+		Document:   acceptance.Document,
+		Version:    acceptance.Version,
+		AcceptedAt: acceptance.AcceptedAt,
+		IP:         acceptance.IP,
+		UserAgent:  acceptance.UserAgent,
+	}
+}
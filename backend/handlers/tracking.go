@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/carriers"
+	"ecommerce-backend/models"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateShipmentRequest struct {
+	Carrier        string `json:"carrier" binding:"required,max=50"`
+	TrackingNumber string `json:"tracking_number" binding:"required,max=100"`
+}
+
+// CreateShipment records that an order was handed to a carrier (admin only).
+// Its tracking is then polled until it is delivered.
+func CreateShipment(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrOrderNotFound)
+		return
+	}
+
+	var req CreateShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	shipment, err := svc.Tracking.AddShipment(c.Request.Context(), uint(id), req.Carrier, req.TrackingNumber)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, shipmentResponse(shipment))
+}
+
+// CarrierWebhook receives the tracking updates a carrier pushes for its
+// shipments. The carrier's provider verifies the request.
+func CarrierWebhook(c *gin.Context) {
+	provider, ok := carriers.Get(c.Param("carrier"))
+	if !ok {
+		c.Error(apperrors.ErrNotFound.WithMessage("unknown carrier"))
+		return
+	}
+
+	trackingNumber, reported, err := provider.ParseWebhook(c.Request)
+	if err != nil {
+		switch {
+		case errors.Is(err, carriers.ErrWebhooksUnsupported):
+			c.Error(apperrors.ErrNotFound.WithMessage(err.Error()))
+		case errors.Is(err, carriers.ErrInvalidSignature):
+			c.Error(apperrors.ErrUnauthorized.WithMessage(err.Error()))
+		default:
+			c.Error(apperrors.Validation("invalid tracking update: " + err.Error()))
+		}
+		return
+	}
+
+	if err := svc.Tracking.Receive(c.Request.Context(), c.Param("carrier"), trackingNumber, reported); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func shipmentResponse(shipment models.Shipment) ShipmentResponse {
+	response := ShipmentResponse{
+		ID:             shipment.ID,
+		OrderID:        shipment.OrderID,
+		Carrier:        shipment.Carrier,
+		TrackingNumber: shipment.TrackingNumber,
+		Status:         shipment.Status,
+		DeliveredAt:    shipment.DeliveredAt,
+		Events:         []TrackingEventResponse{},
+	}
+	for _, e := range shipment.Events {
+		response.Events = append(response.Events, TrackingEventResponse{
+			Status:      e.Status,
+			Description: e.Description,
+			Location:    e.Location,
+			OccurredAt:  e.OccurredAt,
+		})
+	}
+	return response
+}
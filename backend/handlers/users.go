@@ -1,129 +1,464 @@
 package handlers
 
 import (
-	"ecommerce-backend/database"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/captcha"
 	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/services"
 	"ecommerce-backend/utils"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxAvatarUpload bounds the size of avatar uploads, in bytes
+const maxAvatarUpload = 5 << 20
+
 type CreateUserRequest struct {
 	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
+	// Email receives reminders such as abandoned cart emails
+	Email string `json:"email" binding:"omitempty,email,max=255"`
+	// CaptchaToken is the token of a solved CAPTCHA, when one is asked for
+	CaptchaToken string `json:"captcha_token"`
+	// AcceptTerms accepts the current terms of service and privacy policy,
+	// which registering requires while either is tracked
+	AcceptTerms bool `json:"accept_terms"`
+}
+
+type UpdateEmailRequest struct {
+	// Email is empty to remove the user's email
+	Email string `json:"email" binding:"omitempty,email,max=255"`
+}
+
+type UpdatePhoneRequest struct {
+	// Phone is in E.164 format, such as +14155550123, or empty to remove
+	// the user's phone
+	Phone string `json:"phone" binding:"omitempty,e164"`
+}
+
+type VerifyPhoneRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type UpdateNotificationsRequest struct {
+	// SMSOptIn sends SMS alerts when the user's orders ship and are
+	// delivered; it requires a verified phone
+	SMSOptIn bool `json:"sms_opt_in"`
 }
 
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// CaptchaToken is the token of a solved CAPTCHA, when one is asked for
+	CaptchaToken string `json:"captcha_token"`
+	// AcceptTerms accepts the current terms of service and privacy policy,
+	// when logging in is refused with TERMS_NOT_ACCEPTED
+	AcceptTerms bool `json:"accept_terms"`
+}
+
+type DeactivateRequest struct {
+	// Password confirms the deactivation
+	Password string `json:"password" binding:"required"`
 }
 
 // CreateUser handles user registration
 func CreateUser(c *gin.Context) {
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperrors.Binding(err))
 		return
 	}
-
-	// Check if user already exists
-	exists := false
-	database.GetDB().Model(&models.User{}).Select("count(*) > 0").Where("username = ?", req.Username).Find(&exists)
-	if exists {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "username already exists"})
+	if err := captcha.Check(c.Request.Context(), c.ClientIP(), req.CaptchaToken); err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Hash password
-	hashedPassword, err := utils.HashPassword(req.Password)
+	var terms *services.TermsSource
+	if req.AcceptTerms {
+		source := termsSource(c)
+		terms = &source
+	}
+	user, err := svc.Users.Register(c.Request.Context(), req.Username, req.Password, req.Email, terms)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
+		c.Error(err)
 		return
 	}
 
 	// Generate token
-	token, err := utils.GenerateToken(req.Username)
+	token, err := utils.GenerateToken(user.ID, user.Username, user.Role, user.VendorIDOrZero(), user.StoreID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		c.Error(apperrors.Internal("failed to generate token", err))
 		return
 	}
 
-	// Create user
-	user := models.User{
-		Username:     req.Username,
-		PasswordHash: hashedPassword,
-		Token:        token,
+	c.JSON(http.StatusCreated, TokenResponse{
+		Message: "user created successfully",
+		Token:   token,
+	})
+}
+
+// Login handles user login
+func Login(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+	if err := captcha.Check(c.Request.Context(), c.ClientIP(), req.CaptchaToken); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := svc.Users.Authenticate(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := svc.Users.CheckTerms(c.Request.Context(), user.ID, req.AcceptTerms, termsSource(c)); err != nil {
+		c.Error(err)
+		return
 	}
 
-	result := database.GetDB().Create(&user)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
+	// Generate new token
+	token, err := utils.GenerateToken(user.ID, user.Username, user.Role, user.VendorIDOrZero(), user.StoreID)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to generate token", err))
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "user created successfully",
-		"token":   token,
+	c.JSON(http.StatusOK, TokenResponse{
+		Message: "login successful",
+		Token:   token,
 	})
 }
 
-// Login handles user login
-func Login(c *gin.Context) {
+// Logout revokes the token used for the current request
+func Logout(c *gin.Context) {
+	value, _ := c.Get("claims")
+	claims := value.(*utils.Claims)
+
+	if err := utils.RevokeToken(c.Request.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+		c.Error(apperrors.Internal("failed to revoke token", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "logout successful"})
+}
+
+// DeactivateAccount deactivates the current user's account and logs them
+// out everywhere
+func DeactivateAccount(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var req DeactivateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	reactivateBefore, err := svc.Users.Deactivate(c.Request.Context(), currentUser.ID, req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, DeactivationResponse{
+		Message:          "account deactivated",
+		ReactivateBefore: reactivateBefore,
+	})
+}
+
+// ReactivateAccount reactivates a deactivated account and logs its user in
+func ReactivateAccount(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(apperrors.Binding(err))
+		return
+	}
+	if err := captcha.Check(c.Request.Context(), c.ClientIP(), req.CaptchaToken); err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Find user by username
-	var user models.User
-	result := database.GetDB().Where("username = ?", req.Username).First(&user)
-	if result.Error != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+	user, err := svc.Users.Reactivate(c.Request.Context(), req.Username, req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := svc.Users.CheckTerms(c.Request.Context(), user.ID, req.AcceptTerms, termsSource(c)); err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Check password
-	if err := utils.CheckPassword(req.Password, user.PasswordHash); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+	token, err := utils.GenerateToken(user.ID, user.Username, user.Role, user.VendorIDOrZero(), user.StoreID)
+	if err != nil {
+		c.Error(apperrors.Internal("failed to generate token", err))
 		return
 	}
 
-	// Generate new token
-	token, err := utils.GenerateToken(user.Username)
+	c.JSON(http.StatusOK, TokenResponse{
+		Message: "account reactivated",
+		Token:   token,
+	})
+}
+
+// GetProfile returns the current user's profile
+func GetProfile(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	profile, err := svc.Users.Get(c.Request.Context(), currentUser.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := userResponse(profile)
+	value, _ := c.Get("claims")
+	if actor := value.(*utils.Claims).Actor; actor != nil {
+		response.ImpersonatedBy = actor.Username
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetUser returns a user with the history of their terms acceptances
+// (admin only)
+func GetUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrUserNotFound)
+		return
+	}
+
+	user, err := svc.Users.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	acceptances, err := svc.Users.TermsHistory(c.Request.Context(), user.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := AdminUserResponse{
+		User:             userResponse(user),
+		RegisteredAt:     user.CreatedAt,
+		DeactivatedAt:    user.DeactivatedAt,
+		TermsAcceptances: []TermsAcceptanceResponse{},
+	}
+	for _, acceptance := range acceptances {
+		response.TermsAcceptances = append(response.TermsAcceptances, termsAcceptanceResponse(acceptance))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ImpersonateUser issues a short-lived token acting as a user, so support
+// can reproduce what the user sees (admin only). The token names the admin
+// in its act claim, and audit records of requests made with it carry the
+// admin's ID.
+func ImpersonateUser(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		c.Error(apperrors.ErrUserNotFound)
 		return
 	}
 
-	// Update user token in database
-	user.Token = token
-	database.GetDB().Save(&user)
+	token, expiresAt, err := svc.Users.Impersonate(c.Request.Context(), currentUser, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "login successful",
-		"token":   token,
+	c.JSON(http.StatusOK, ImpersonationResponse{
+		Message:   "impersonation token issued",
+		Token:     token,
+		UserID:    uint(id),
+		ExpiresAt: expiresAt,
 	})
 }
 
-// GetUsers returns a list of all users (admin only)
+// UpdateEmail sets or removes the current user's email
+func UpdateEmail(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var req UpdateEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	updated, err := svc.Users.SetEmail(c.Request.Context(), currentUser.ID, req.Email)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, userResponse(updated))
+}
+
+// UpdatePhone sets or removes the current user's phone, texting a new
+// number a code to verify it with
+func UpdatePhone(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var req UpdatePhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	updated, err := svc.Users.SetPhone(c.Request.Context(), currentUser.ID, req.Phone)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, userResponse(updated))
+}
+
+// VerifyPhone verifies the current user's phone with the code texted to it
+func VerifyPhone(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var req VerifyPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	updated, err := svc.Users.VerifyPhone(c.Request.Context(), currentUser.ID, req.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, userResponse(updated))
+}
+
+// UpdateNotifications sets the current user's notification preferences
+func UpdateNotifications(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var req UpdateNotificationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	updated, err := svc.Users.SetSMSOptIn(c.Request.Context(), currentUser.ID, req.SMSOptIn)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, userResponse(updated))
+}
+
+// UploadAvatar sets the current user's avatar from the image uploaded as
+// the avatar form field
+func UploadAvatar(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAvatarUpload)
+	header, err := c.FormFile("avatar")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.Error(apperrors.Validation("avatar must be at most 5 MB"))
+			return
+		}
+		c.Error(apperrors.Validation("an avatar image must be uploaded as the avatar form field"))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.Error(apperrors.Internal("failed to read avatar", err))
+		return
+	}
+	defer file.Close()
+
+	updated, err := svc.Users.SetAvatar(c.Request.Context(), currentUser.ID, file)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, userResponse(updated))
+}
+
+// GetUsers streams a page of active users (admin only)
 func GetUsers(c *gin.Context) {
-	var users []models.User
-	result := database.GetDB().Find(&users)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch users"})
+	page, err := pagination.FromRequestUpTo(c, false, pagination.MaxStreamLimit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	pos, err := svc.Users.Locate(c.Request.Context(), page)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Remove sensitive data
-	var response []gin.H
-	for _, user := range users {
-		response = append(response, gin.H{
-			"id":       user.ID,
-			"username": user.Username,
+	stream := newJSONStream(c, "users")
+	stream.Locate(pos)
+	next, err := svc.Users.Each(c.Request.Context(), page,
+		func(user *models.User) error {
+			return stream.Write(userResponse(*user))
 		})
+	if err != nil {
+		stream.Fail("failed to fetch users", err)
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"users": response})
+	stream.End(next)
+}
+
+// ExportUsers streams active users as CSV or JSON Lines, with their
+// registration date, order count and lifetime value (admin only)
+func ExportUsers(c *gin.Context) {
+	export(c, "users", userExportColumns, svc.Users.EachSummary)
+}
+
+// userExportColumns are the columns of the user export; password hashes
+// are never exported
+var userExportColumns = []exportColumn[repository.UserSummary]{
+	{"id", func(u *repository.UserSummary) interface{} { return u.ID }},
+	{"username", func(u *repository.UserSummary) interface{} { return u.Username }},
+	{"email", func(u *repository.UserSummary) interface{} { return u.Email }},
+	{"role", func(u *repository.UserSummary) interface{} { return u.Role }},
+	{"vendor_id", func(u *repository.UserSummary) interface{} { return u.VendorID }},
+	{"registered_at", func(u *repository.UserSummary) interface{} { return u.CreatedAt.UTC() }},
+	{"order_count", func(u *repository.UserSummary) interface{} { return u.OrderCount }},
+	{"lifetime_value", func(u *repository.UserSummary) interface{} { return u.LifetimeValue }},
+}
+
+// userResponse renders the user without sensitive data
+func userResponse(user models.User) UserResponse {
+	return UserResponse{
+		ID:            user.ID,
+		Username:      user.Username,
+		Role:          user.Role,
+		Email:         user.Email,
+		VendorID:      user.VendorID,
+		AvatarURL:     user.AvatarURL,
+		Phone:         user.Phone,
+		PhoneVerified: user.PhoneVerifiedAt != nil,
+		SMSOptIn:      user.SMSOptIn,
+	}
 }
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateVendorRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+}
+
+type AddVendorAccountRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+type UpdateSubOrderStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=completed shipped delivered cancelled"`
+}
+
+// CreateVendor adds a marketplace vendor (admin only)
+func CreateVendor(c *gin.Context) {
+	var req CreateVendorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	vendor, err := svc.Vendors.Create(c.Request.Context(), req.Name)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, VendorResponse{Vendor: vendor})
+}
+
+// GetVendors lists all vendors by name (admin only)
+func GetVendors(c *gin.Context) {
+	vendors, err := svc.Vendors.List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if vendors == nil {
+		vendors = []models.Vendor{}
+	}
+
+	c.JSON(http.StatusOK, VendorsResponse{Vendors: vendors})
+}
+
+// AddVendorAccount makes an existing user an account of the vendor (admin
+// only). The user must log in again for the vendor role to take effect.
+func AddVendorAccount(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrVendorNotFound)
+		return
+	}
+
+	var req AddVendorAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	user, err := svc.Vendors.AddAccount(c.Request.Context(), uint(id), req.Username)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, userResponse(user))
+}
+
+// GetVendorOrders returns a page of the current vendor's sub-orders,
+// newest first, each with only the vendor's own lines (vendor only)
+func GetVendorOrders(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	page, err := pagination.FromRequest(c, true)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	subs, next, err := svc.Orders.VendorOrders(c.Request.Context(), currentUser.VendorIDOrZero(), page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := []SubOrderResponse{}
+	for _, sub := range subs {
+		subData := subOrderResponse(sub)
+		for _, ci := range sub.Order.Cart.CartItems {
+			if ci.Item.VendorID != nil && *ci.Item.VendorID == sub.VendorID {
+				subData.Items = append(subData.Items, cartItemResponse(ci))
+			}
+		}
+		response = append(response, subData)
+	}
+
+	c.JSON(http.StatusOK, SubOrdersResponse{Orders: response, NextCursor: next})
+}
+
+// UpdateVendorOrderStatus moves one of the current vendor's sub-orders to a
+// new status (vendor only)
+func UpdateVendorOrderStatus(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrOrderNotFound)
+		return
+	}
+
+	var req UpdateSubOrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	sub, err := svc.Orders.UpdateSubOrderStatus(c.Request.Context(), currentUser.VendorIDOrZero(), uint(id), req.Status)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, subOrderResponse(sub))
+}
+
+// subOrderResponse renders a sub-order without its lines
+func subOrderResponse(sub models.SubOrder) SubOrderResponse {
+	return SubOrderResponse{
+		ID:        sub.ID,
+		OrderID:   sub.OrderID,
+		Total:     sub.Total,
+		Status:    sub.Status,
+		CreatedAt: sub.CreatedAt,
+		Items:     []CartItemResponse{},
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateWarehouseRequest struct {
+	Name string `json:"name" binding:"required,max=255"`
+	// Priority orders warehouses for allocation at checkout, lowest first
+	Priority int `json:"priority"`
+}
+
+type SetWarehouseStockRequest struct {
+	Quantity *int `json:"quantity" binding:"required,min=0"`
+}
+
+type CreateStockTransferRequest struct {
+	ItemID          uint `json:"item_id" binding:"required"`
+	FromWarehouseID uint `json:"from_warehouse_id" binding:"required"`
+	ToWarehouseID   uint `json:"to_warehouse_id" binding:"required"`
+	Quantity        int  `json:"quantity" binding:"required,min=1"`
+}
+
+// CreateWarehouse adds a warehouse to the store (admin only)
+func CreateWarehouse(c *gin.Context) {
+	var req CreateWarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	warehouse := models.Warehouse{Name: req.Name, Priority: req.Priority}
+	if err := svc.Warehouses.Create(c.Request.Context(), &warehouse); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, WarehouseResponse{Warehouse: warehouse})
+}
+
+// GetWarehouses lists the store's warehouses in allocation order (admin
+// only)
+func GetWarehouses(c *gin.Context) {
+	warehouses, err := svc.Warehouses.List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if warehouses == nil {
+		warehouses = []models.Warehouse{}
+	}
+
+	c.JSON(http.StatusOK, WarehousesResponse{Warehouses: warehouses})
+}
+
+// GetWarehouseStock lists the items held at a warehouse (admin only)
+func GetWarehouseStock(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrWarehouseNotFound)
+		return
+	}
+
+	stock, err := svc.Warehouses.Stock(c.Request.Context(), uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	response := WarehouseStockResponse{WarehouseID: uint(id), Stock: []StockLevelResponse{}}
+	for _, s := range stock {
+		response.Stock = append(response.Stock, StockLevelResponse{ItemID: s.ItemID, Name: s.Item.Name, Quantity: s.Quantity})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// SetWarehouseStock sets the units of an item held at a warehouse and
+// returns the item with its new total stock (admin only)
+func SetWarehouseStock(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrWarehouseNotFound)
+		return
+	}
+	itemID, err := strconv.ParseUint(c.Param("item_id"), 10, 64)
+	if err != nil {
+		c.Error(apperrors.ErrItemNotFound)
+		return
+	}
+
+	var req SetWarehouseStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	item, err := svc.Warehouses.SetStock(c.Request.Context(), currentUser, uint(id), uint(itemID), *req.Quantity)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	render(c, http.StatusOK, ItemResponse{Item: item})
+}
+
+// CreateStockTransfer moves units of an item between warehouses (admin
+// only)
+func CreateStockTransfer(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var req CreateStockTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperrors.Binding(err))
+		return
+	}
+
+	transfer := models.StockTransfer{
+		ItemID:          req.ItemID,
+		FromWarehouseID: req.FromWarehouseID,
+		ToWarehouseID:   req.ToWarehouseID,
+		Quantity:        req.Quantity,
+	}
+	if err := svc.Warehouses.Transfer(c.Request.Context(), currentUser, &transfer); err != nil {
+		c.Error(err)
+		return
+	}
+	invalidateItems(c.Request.Context())
+
+	c.JSON(http.StatusCreated, StockTransferResponse{Transfer: transfer})
+}
+
+// GetStockTransfers returns a page of stock transfers, newest first (admin
+// only)
+func GetStockTransfers(c *gin.Context) {
+	page, err := pagination.FromRequest(c, true)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	transfers, next, err := svc.Warehouses.Transfers(c.Request.Context(), page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if transfers == nil {
+		transfers = []models.StockTransfer{}
+	}
+
+	c.JSON(http.StatusOK, StockTransfersResponse{Transfers: transfers, NextCursor: next})
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"ecommerce-backend/events"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/middleware"
+	"ecommerce-backend/models"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	// wsPongTimeout is how long a connection may stay silent; pings are
+	// sent often enough for a live client to answer in time
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = wsPongTimeout * 9 / 10
+)
+
+// wsConns tracks open WebSocket connections, which http.Server.Shutdown
+// does not wait for
+var wsConns sync.WaitGroup
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Browsers send an Origin on every handshake; other clients may not
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || middleware.OriginAllowed(origin)
+	},
+}
+
+// OrderUpdates upgrades to a WebSocket and pushes an event whenever one of
+// the current user's orders is created or changes status. Events are only
+// sent while connected, so clients should refetch their orders after
+// (re)connecting. Messages from the client are ignored.
+func OrderUpdates(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+	logger := logging.FromContext(c.Request.Context())
+
+	// Subscribe before upgrading so no event is missed in between
+	sub := events.Subscribe(func(e events.Event) bool {
+		return e.UserID == currentUser.ID &&
+			(e.Type == events.OrderCreated || e.Type == events.OrderStatusChanged || e.Type == events.ShipmentUpdated)
+	})
+	defer sub.Close()
+
+	// The upgrader writes its own error response on failure
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	wsConns.Add(1)
+	defer wsConns.Done()
+
+	// The connection outlives the request deadline, so it is not tied to
+	// the request context; closed is signalled when the client goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case event := <-sub.Events():
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(event); err != nil {
+				logger.Debug("order updates: write failed", "user_id", currentUser.ID, "error", err)
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		case <-shutdown:
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+			conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout))
+			return
+		case <-closed:
+			return
+		}
+	}
+}
+
+// WaitWebSockets waits until the WebSocket connections have closed after
+// MarkShuttingDown, and reports false if ctx expires first
+func WaitWebSockets(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		wsConns.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
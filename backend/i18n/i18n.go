@@ -0,0 +1,119 @@
+// Package i18n holds the translations of client-facing messages and picks
+// the language of a response from the Accept-Language header. English is
+// the language messages are written in, so it needs no translation file;
+// any message missing from a translation is left in English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is the language of untranslated messages
+const Default = "en"
+
+//go:embed translations/*.json
+var files embed.FS
+
+// catalogs maps languages to their messages, keyed by "section.key",
+// e.g. "errors.CART_EMPTY" or "validation.min.string"
+var catalogs = map[string]map[string]string{}
+
+func init() {
+	entries, err := files.ReadDir("translations")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		data, err := files.ReadFile(path.Join("translations", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var sections map[string]map[string]string
+		if err := json.Unmarshal(data, &sections); err != nil {
+			panic("i18n: " + entry.Name() + ": " + err.Error())
+		}
+		messages := map[string]string{}
+		for section, entries := range sections {
+			for key, message := range entries {
+				messages[section+"."+key] = message
+			}
+		}
+		catalogs[strings.TrimSuffix(entry.Name(), ".json")] = messages
+	}
+}
+
+// Languages returns the supported languages, English first
+func Languages() []string {
+	langs := []string{Default}
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs[1:])
+	return langs
+}
+
+// Message returns the translation of key into lang, and false if there is
+// none, in which case the English message should be used
+func Message(lang, key string) (string, bool) {
+	message, ok := catalogs[lang][key]
+	return message, ok
+}
+
+// Negotiate returns the supported language the client prefers most in an
+// Accept-Language header such as "de-CH, de;q=0.9, en;q=0.5". Regional
+// variants fall back to their base language; English is returned if no
+// listed language is supported.
+func Negotiate(header string) string {
+	type choice struct {
+		tag string
+		q   float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(name) == "q" {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			choices = append(choices, choice{tag, q})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+
+	for _, c := range choices {
+		if lang, ok := supported(c.tag); ok {
+			return lang
+		}
+	}
+	return Default
+}
+
+// supported returns the supported language matching a language tag
+func supported(tag string) (string, bool) {
+	if tag == "*" {
+		return Default, true
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	if base == Default {
+		return Default, true
+	}
+	if _, ok := catalogs[base]; ok {
+		return base, true
+	}
+	return "", false
+}
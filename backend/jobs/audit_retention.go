@@ -0,0 +1,22 @@
+package jobs
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/database"
+	"time"
+)
+
+// AuditRetention returns how long audit logs are kept
+func AuditRetention() time.Duration {
+	return time.Duration(config.Get().Audit.RetentionDays) * 24 * time.Hour
+}
+
+// PurgeAuditLogs deletes audit logs older than the retention period. Audit
+// logs refuse ordinary deletes, so this is the only way records are removed.
+func PurgeAuditLogs(ctx context.Context) (int64, error) {
+	cutoff := time.Now().Add(-AuditRetention())
+
+	result := database.GetDB().WithContext(ctx).Exec("DELETE FROM audit_logs WHERE created_at < ?", cutoff)
+	return result.RowsAffected, result.Error
+}
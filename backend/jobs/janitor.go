@@ -0,0 +1,64 @@
+package jobs
+
+import (
+	"context"
+	"ecommerce-backend/database"
+	"ecommerce-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// PurgeOrphanCarts deletes, with their items and reminders, the open carts
+// no one can check out any more: those of accounts that were anonymized or
+// no longer exist, which stand in for guest carts as the store has no
+// guest checkout, and those deleted when merged into their owner's cart.
+func PurgeOrphanCarts(ctx context.Context) (int64, error) {
+	db := database.GetDB().WithContext(ctx)
+
+	var purged int64
+	for {
+		var ids []uint
+		err := db.Unscoped().Model(&models.Cart{}).
+			Where("is_checked_out = ?", false).
+			Where("(carts.deleted_at IS NOT NULL OR NOT EXISTS (?))",
+				db.Model(&models.User{}).Select("1").Where("users.id = carts.user_id AND users.anonymized_at IS NULL")).
+			Limit(purgeBatchSize).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return purged, err
+		}
+
+		if err := db.Transaction(func(tx *gorm.DB) error { return deleteCarts(tx, ids) }); err != nil {
+			return purged, err
+		}
+		purged += int64(len(ids))
+	}
+}
+
+// PurgeOrphanCartItems deletes the cart items left behind: those removed
+// from their cart, which are only soft-deleted, those whose cart no longer
+// exists, and those of open carts whose item was deleted. Items of
+// checked-out carts are kept as the lines of their order.
+func PurgeOrphanCartItems(ctx context.Context) (int64, error) {
+	db := database.GetDB().WithContext(ctx)
+
+	var purged int64
+	for {
+		var ids []uint
+		err := db.Unscoped().Model(&models.CartItem{}).
+			Where("cart_items.deleted_at IS NOT NULL").
+			Or("NOT EXISTS (?)",
+				db.Model(&models.Cart{}).Select("1").Where("carts.id = cart_items.cart_id")).
+			Or("NOT EXISTS (?) AND EXISTS (?)",
+				db.Model(&models.Item{}).Select("1").Where("items.id = cart_items.item_id"),
+				db.Model(&models.Cart{}).Select("1").Where("carts.id = cart_items.cart_id AND carts.is_checked_out = ?", false)).
+			Limit(purgeBatchSize).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return purged, err
+		}
+
+		if err := db.Unscoped().Delete(&models.CartItem{}, ids).Error; err != nil {
+			return purged, err
+		}
+		purged += int64(len(ids))
+	}
+}
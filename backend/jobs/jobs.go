@@ -0,0 +1,83 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Background work is tied to a single context that is cancelled on shutdown,
+// and tracked so shutdown can wait for it to finish.
+var (
+	baseCtx = context.Background()
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+)
+
+// Init sets the parent context for all background work. It must be called
+// once at startup before any jobs are started.
+func Init(ctx context.Context) {
+	baseCtx, cancel = context.WithCancel(ctx)
+}
+
+// Go runs fn in a tracked goroutine. fn should return promptly once ctx is done.
+func Go(name string, fn func(ctx context.Context)) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("job %s panicked: %v", name, r)
+			}
+		}()
+		fn(baseCtx)
+	}()
+}
+
+// Schedule runs fn in the background via Every
+func Schedule(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	Go(name, func(ctx context.Context) {
+		Every(ctx, name, interval, fn)
+	})
+}
+
+// Every runs fn immediately and then at each interval until ctx is cancelled.
+// Errors are logged and do not stop the schedule.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil {
+			log.Printf("job %s failed: %v", name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop cancels all background work and waits for it to finish, giving up
+// when ctx is done. It reports whether every job stopped in time.
+func Stop(ctx context.Context) bool {
+	if cancel != nil {
+		cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
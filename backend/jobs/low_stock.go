@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"context"
+	"ecommerce-backend/database"
+	"ecommerce-backend/emails"
+	"ecommerce-backend/events"
+	"ecommerce-backend/models"
+	"ecommerce-backend/notifications"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CheckLowStock alerts admins to items whose stock has fallen to their
+// low-stock threshold, across all stores. Each item is reported once when it crosses the
+// threshold, and again only after it has been restocked above it.
+func CheckLowStock(ctx context.Context) error {
+	db := database.GetDB().WithContext(ctx)
+
+	// Re-arm alerts for restocked items
+	err := db.Model(&models.Item{}).
+		Where("low_stock_alerted_at IS NOT NULL").
+		Where("stock IS NULL OR low_stock_threshold = 0 OR stock > low_stock_threshold").
+		Update("low_stock_alerted_at", nil).Error
+	if err != nil {
+		return err
+	}
+
+	var items []models.Item
+	err = db.Where("stock IS NOT NULL AND low_stock_threshold > 0 AND stock <= low_stock_threshold").
+		Where("low_stock_alerted_at IS NULL").
+		Order("id").
+		Find(&items).Error
+	if err != nil || len(items) == 0 {
+		return err
+	}
+
+	data := emails.LowStockData{}
+	ids := make([]uint, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+		data.Items = append(data.Items, emails.LowStockItem{
+			ID:        item.ID,
+			StoreID:   item.StoreID,
+			Name:      item.Name,
+			Stock:     *item.Stock,
+			Threshold: item.LowStockThreshold,
+		})
+	}
+	msg, err := emails.Render(emails.LowStock, data)
+	if err != nil {
+		return err
+	}
+	if err := notifications.NotifyAdmins(ctx, msg); err != nil {
+		// Not marked as alerted, so the next run tries again
+		return fmt.Errorf("failed to notify admins: %w", err)
+	}
+
+	// The alerts are recorded with their events, so the events are relayed
+	// once and only for alerts that were recorded
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Item{}).Where("id IN ?", ids).Update("low_stock_alerted_at", time.Now()).Error; err != nil {
+			return err
+		}
+		for _, item := range items {
+			msg, err := events.New(events.StockLow, item.StoreID, 0, events.Stock{
+				ItemID:    item.ID,
+				Name:      item.Name,
+				Stock:     *item.Stock,
+				Threshold: item.LowStockThreshold,
+			}).Message()
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(&msg).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("low stock: alerted admins to %d item(s)", len(items))
+	return nil
+}
@@ -0,0 +1,184 @@
+package jobs
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/database"
+	"ecommerce-backend/models"
+	"errors"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// purgeBatchSize is how many carts are deleted per transaction
+const purgeBatchSize = 500
+
+// Policy is a retention policy: it deletes one kind of expired data and
+// returns how many records it removed
+type Policy struct {
+	Name  string
+	Purge func(ctx context.Context) (int64, error)
+}
+
+// Policies are applied in order by every purge run
+var Policies = []Policy{
+	{Name: "sessions", Purge: PurgeRevokedTokens},
+	{Name: "carts", Purge: PurgeStaleCarts},
+	{Name: "orphan_carts", Purge: PurgeOrphanCarts},
+	{Name: "orphan_cart_items", Purge: PurgeOrphanCartItems},
+	{Name: "audit_logs", Purge: PurgeAuditLogs},
+	{Name: "outbox", Purge: PurgeOutbox},
+	{Name: "device_tokens", Purge: PurgeStaleDevices},
+}
+
+// Records purged by each policy since the process started, exposed via
+// /debug/vars
+var purgedRecords = expvar.NewMap("retention_purged")
+
+// ErrPurgeRunning is returned when a purge run is started on an instance
+// already running one
+var ErrPurgeRunning = errors.New("a purge run is already in progress")
+
+// purging is held while this instance runs the retention policies
+var purging sync.Mutex
+
+// Purge applies the retention policies and records the run. It is
+// scheduled every RETENTION_INTERVAL and skipped while a run triggered by
+// an admin is in progress.
+func Purge(ctx context.Context) error {
+	if !purging.TryLock() {
+		return nil
+	}
+	defer purging.Unlock()
+
+	run, err := startRun(ctx, models.PurgeTriggerSchedule, nil)
+	if err != nil {
+		return err
+	}
+	return finishRun(ctx, run)
+}
+
+// StartPurge records a purge run triggered by an admin and applies the
+// retention policies in the background. The run is returned as started;
+// its outcome is recorded once it finishes.
+func StartPurge(ctx context.Context, adminID uint) (models.PurgeRun, error) {
+	if !purging.TryLock() {
+		return models.PurgeRun{}, ErrPurgeRunning
+	}
+
+	run, err := startRun(ctx, models.PurgeTriggerAdmin, &adminID)
+	if err != nil {
+		purging.Unlock()
+		return models.PurgeRun{}, err
+	}
+	Go("purge-run", func(ctx context.Context) {
+		defer purging.Unlock()
+		if err := finishRun(ctx, run); err != nil {
+			log.Printf("job purge-run failed: %v", err)
+		}
+	})
+	return run, nil
+}
+
+func startRun(ctx context.Context, trigger string, triggeredBy *uint) (models.PurgeRun, error) {
+	run := models.PurgeRun{
+		Trigger:     trigger,
+		TriggeredBy: triggeredBy,
+		Status:      models.PurgeRunning,
+		StartedAt:   time.Now(),
+	}
+	err := database.GetDB().WithContext(ctx).Create(&run).Error
+	return run, err
+}
+
+// finishRun applies every policy, even after one fails, and records the
+// outcome of the run
+func finishRun(ctx context.Context, run models.PurgeRun) error {
+	var errs []error
+	results := make([]models.PurgeResult, 0, len(Policies))
+	for _, policy := range Policies {
+		n, err := policy.Purge(ctx)
+		result := models.PurgeResult{Policy: policy.Name, Purged: n}
+		if err != nil {
+			result.Error = err.Error()
+			errs = append(errs, errors.New(policy.Name+": "+err.Error()))
+		} else if n > 0 {
+			log.Printf("retention: purged %d %s", n, policy.Name)
+		}
+		purgedRecords.Add(policy.Name, n)
+		results = append(results, result)
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Results = results
+	run.Status = models.PurgeSucceeded
+	if len(errs) > 0 {
+		run.Status = models.PurgeFailed
+	}
+	// Recorded even when the run was cancelled by shutdown
+	err := database.GetDB().WithContext(context.WithoutCancel(ctx)).
+		Select("status", "finished_at", "results").Updates(&run).Error
+	return errors.Join(append(errs, err)...)
+}
+
+// PurgeStaleCarts deletes open carts that have not changed for
+// RETENTION_CART_DAYS, with their items and reminders. Users get a new cart
+// when they next add an item.
+func PurgeStaleCarts(ctx context.Context) (int64, error) {
+	cutoff := time.Now().AddDate(0, 0, -config.Get().Retention.CartDays)
+	db := database.GetDB().WithContext(ctx)
+
+	var purged int64
+	for {
+		var ids []uint
+		err := db.Unscoped().Model(&models.Cart{}).
+			Where("is_checked_out = ? AND updated_at < ?", false, cutoff).
+			Limit(purgeBatchSize).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return purged, err
+		}
+
+		if err := db.Transaction(func(tx *gorm.DB) error { return deleteCarts(tx, ids) }); err != nil {
+			return purged, err
+		}
+		purged += int64(len(ids))
+	}
+}
+
+// deleteCarts deletes the carts with their items and reminders
+func deleteCarts(tx *gorm.DB, ids []uint) error {
+	if err := tx.Unscoped().Where("cart_id IN ?", ids).Delete(&models.CartItem{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("cart_id IN ?", ids).Delete(&models.CartReminder{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Delete(&models.Cart{}, ids).Error
+}
+
+// PurgeOutbox deletes outbox messages relayed or given up on more than
+// RETENTION_OUTBOX_DAYS ago
+func PurgeOutbox(ctx context.Context) (int64, error) {
+	cutoff := time.Now().AddDate(0, 0, -config.Get().Retention.OutboxDays)
+
+	result := database.GetDB().WithContext(ctx).
+		Where("delivered_at < ? OR failed_at < ?", cutoff, cutoff).
+		Delete(&models.OutboxMessage{})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeStaleDevices deletes device tokens their app has not registered for
+// RETENTION_DEVICE_TOKEN_DAYS, which push providers are likely to refuse
+func PurgeStaleDevices(ctx context.Context) (int64, error) {
+	cutoff := time.Now().AddDate(0, 0, -config.Get().Retention.DeviceTokenDays)
+
+	result := database.GetDB().WithContext(ctx).
+		Where("last_seen_at < ?", cutoff).
+		Delete(&models.DeviceToken{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,12 @@
+package jobs
+
+import (
+	"context"
+	"ecommerce-backend/utils"
+)
+
+// PurgeRevokedTokens deletes revocations of tokens that have since expired;
+// expired tokens are rejected regardless, so the entries are no longer needed
+func PurgeRevokedTokens(ctx context.Context) (int64, error) {
+	return utils.PurgeExpiredRevocations(ctx)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"ecommerce-backend/addresses"
+	"ecommerce-backend/cache"
+	"ecommerce-backend/captcha"
+	"ecommerce-backend/carriers"
+	"ecommerce-backend/config"
+	"ecommerce-backend/currency"
+	"ecommerce-backend/database"
+	"ecommerce-backend/emails"
+	"ecommerce-backend/encryption"
+	"ecommerce-backend/flags"
+	"ecommerce-backend/grpcapi"
+	"ecommerce-backend/handlers"
+	"ecommerce-backend/jobs"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/maintenance"
+	"ecommerce-backend/migrations"
+	"ecommerce-backend/notifications"
+	"ecommerce-backend/passwords"
+	"ecommerce-backend/receipts"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/resilience"
+	"ecommerce-backend/search"
+	"ecommerce-backend/services"
+	"ecommerce-backend/storage"
+	"ecommerce-backend/telemetry"
+	"errors"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// newRootCmd builds the command tree. Running the binary without a
+// subcommand starts the server.
+func newRootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:               "ecommerce",
+		Short:             "E-commerce backend server and operational tools",
+		SilenceUsage:      true,
+		Args:              cobra.NoArgs,
+		RunE:              func(cmd *cobra.Command, args []string) error { return runServer() },
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			// Structured logging; the standard log package is routed through it too
+			slog.SetDefault(logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format))
+
+			// Personal data is encrypted by the models' serializer, whatever
+			// command touches the database
+			return encryption.Init(cfg.Encryption.Keys)
+		},
+	}
+
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "serve",
+			Short: "Start the HTTP server (default)",
+			Args:  cobra.NoArgs,
+			RunE:  func(cmd *cobra.Command, args []string) error { return runServer() },
+		},
+		newMigrateCmd(),
+		newSeedCmd(),
+		newAdminCmd(),
+	)
+	return root
+}
+
+// withDB opens the database for a command that needs it and closes it when
+// the command returns
+func withDB(run func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if _, err := database.InitDB(); err != nil {
+			return errors.New("failed to initialize database: " + err.Error())
+		}
+		defer database.Close()
+		return run(cmd, args)
+	}
+}
+
+// runServer serves the API until SIGINT or SIGTERM, then shuts down gracefully
+func runServer() error {
+	cfg := config.Get()
+
+	shutdownTracing, err := telemetry.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatal("Failed to initialize tracing:", err)
+	}
+
+	if _, err := database.InitDB(); err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+	defer database.Close()
+
+	if cfg.DB.AutoMigrate {
+		n, err := migrations.Up(database.GetDB())
+		if err != nil {
+			log.Fatal("Failed to apply migrations:", err)
+		}
+		log.Printf("Applied %d migration(s)", n)
+	}
+
+	// Refuse to serve against an outdated schema
+	if err := database.CheckSchema(); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := cache.Init(cfg.Cache); err != nil {
+		log.Fatal("Failed to initialize cache:", err)
+	}
+	defer cache.Close()
+	if cfg.Cache.RedisURL != "" {
+		handlers.RegisterReadinessCheck("cache", cache.Get().Ping)
+	}
+
+	resilience.Init(cfg.Resilience)
+	search.Init(cfg.Search)
+	storage.Init(cfg.Storage)
+	addresses.Init(cfg.Addresses)
+	currency.Init(cfg.Currency)
+	captcha.Init(cfg.Captcha)
+	passwords.Init(cfg.Passwords)
+	if engine := search.Get(); engine != nil {
+		handlers.RegisterReadinessCheck("search", engine.Ping)
+		// Search falls back to SQL until the engine is reachable
+		if err := engine.EnsureIndex(context.Background()); err != nil {
+			log.Printf("Failed to create search index: %v", err)
+		}
+	}
+
+	notifications.Init(cfg.SMTP, cfg.SMS, cfg.Notifications)
+	if err := notifications.InitPush(cfg.Push); err != nil {
+		log.Fatal("Failed to initialize push notifications:", err)
+	}
+	if err := receipts.Init(cfg.Receipts); err != nil {
+		log.Fatal("Failed to initialize receipts:", err)
+	}
+	if err := emails.Init(cfg.Emails); err != nil {
+		log.Fatal("Failed to initialize emails:", err)
+	}
+	carriers.Init(cfg.Tracking)
+	maintenance.Init(cfg.Maintenance)
+
+	store := repository.NewGorm(database.GetDB())
+	flags.Init(store)
+	svc := services.New(store, cfg)
+	handlers.SetServices(svc)
+
+	// Cancelled on SIGINT/SIGTERM to begin shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Background workers
+	jobs.Init(ctx)
+	jobs.Schedule("retention", cfg.Retention.Interval, jobs.Purge)
+	jobs.Schedule("low-stock-check", cfg.Inventory.LowStockCheckInterval, jobs.CheckLowStock)
+	jobs.Schedule("back-in-stock", cfg.Inventory.BackInStockCheckInterval, svc.Items.NotifyRestocked)
+	jobs.Schedule("tracking-poll", cfg.Tracking.PollInterval, svc.Tracking.Poll)
+	jobs.Schedule("account-anonymization", cfg.Accounts.AnonymizeInterval, svc.Users.AnonymizeExpired)
+	jobs.Schedule("flash-sales", cfg.Sales.ScheduleInterval, handlers.ScheduleSales)
+	jobs.Schedule("outbox-relay", cfg.Outbox.RelayInterval, svc.Outbox.Relay)
+	jobs.Schedule("outbox-webhooks", cfg.Outbox.RelayInterval, svc.Outbox.RelayWebhooks)
+	jobs.Schedule("outbox-sms", cfg.Outbox.RelayInterval, svc.Outbox.RelaySMS)
+	jobs.Schedule("outbox-push", cfg.Outbox.RelayInterval, svc.Outbox.RelayPush)
+	if database.HasReplicas() {
+		jobs.Schedule("replica-health", cfg.DB.ReplicaCheckInterval, database.CheckReplicas)
+	}
+	if cfg.Carts.AbandonedAfter > 0 {
+		jobs.Schedule("abandoned-cart-reminders", cfg.Carts.ReminderInterval, svc.Carts.RemindAbandoned)
+	}
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           setupRouter(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverErr := make(chan error, 2)
+	go func() {
+		log.Printf("Server listening on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	// Internal gRPC API, when enabled
+	var grpcServer *grpc.Server
+	if cfg.GRPC.Port != "" {
+		lis, err := net.Listen("tcp", ":"+cfg.GRPC.Port)
+		if err != nil {
+			log.Fatal("Failed to listen for gRPC:", err)
+		}
+		grpcServer = grpcapi.NewServer(svc)
+		go func() {
+			log.Printf("gRPC server listening on %s", lis.Addr())
+			if err := grpcServer.Serve(lis); err != nil {
+				serverErr <- err
+			}
+		}()
+	}
+
+	select {
+	case err := <-serverErr:
+		log.Printf("Server failed: %v", err)
+	case <-ctx.Done():
+		log.Println("Shutdown signal received")
+	}
+	stop()
+	handlers.MarkShuttingDown()
+
+	// Let in-flight requests (e.g. checkout transactions) finish, then stop
+	// background workers; the database is closed by the deferred Close
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown did not complete: %v", err)
+	}
+	if !handlers.WaitWebSockets(shutdownCtx) {
+		log.Println("WebSocket connections did not close before the shutdown timeout")
+	}
+	if grpcServer != nil {
+		stopGRPC(shutdownCtx, grpcServer)
+	}
+	if !jobs.Stop(shutdownCtx) {
+		log.Println("Background workers did not stop before the shutdown timeout")
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
+
+	log.Println("Server stopped")
+	return nil
+}
+
+// stopGRPC waits for in-flight calls to finish, cancelling them once ctx
+// expires
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Println("gRPC shutdown did not complete; cancelling remaining calls")
+		srv.Stop()
+	}
+}
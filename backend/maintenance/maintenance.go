@@ -0,0 +1,75 @@
+// Package maintenance switches the API into maintenance mode, in which
+// writes are refused so that schema migrations can run safely. The mode is
+// on while MAINTENANCE_MODE is set or while it is switched on through the
+// admin API; the latter is kept in the cache, so that it reaches every
+// instance sharing it.
+package maintenance
+
+import (
+	"context"
+	"ecommerce-backend/cache"
+	"ecommerce-backend/config"
+	"time"
+)
+
+// key is the cache key holding the mode switched on through the admin API
+const key = "maintenance"
+
+var cfg = config.Default().Maintenance
+
+// Init sets the configured mode and retry delay
+func Init(c config.MaintenanceConfig) {
+	cfg = c
+}
+
+// Status is whether maintenance mode is on
+type Status struct {
+	Enabled bool
+	// Configured is set while MAINTENANCE_MODE keeps the mode on
+	Configured bool
+	// Until is when the mode switched on through the admin API ends by
+	// itself, if it was switched on for a while
+	Until *time.Time
+}
+
+// Get returns the maintenance status. If the cache fails, the configured
+// mode is returned along with the error.
+func Get(ctx context.Context) (Status, error) {
+	status := Status{Enabled: cfg.Enabled, Configured: cfg.Enabled}
+	value, found, err := cache.Get().Get(ctx, key)
+	if err != nil || !found {
+		return status, err
+	}
+
+	status.Enabled = true
+	if until, err := time.Parse(time.RFC3339, string(value)); err == nil {
+		status.Until = &until
+	}
+	return status, nil
+}
+
+// Enable switches maintenance mode on, for the duration if it is positive
+// and until Disable is called otherwise
+func Enable(ctx context.Context, duration time.Duration) error {
+	var value string
+	if duration > 0 {
+		value = time.Now().Add(duration).UTC().Format(time.RFC3339)
+	}
+	return cache.Get().Set(ctx, key, []byte(value), duration)
+}
+
+// Disable switches maintenance mode off, unless MAINTENANCE_MODE keeps it on
+func Disable(ctx context.Context) error {
+	return cache.Get().Delete(ctx, key)
+}
+
+// RetryAfter is how long clients should wait before retrying a refused
+// write: until the mode ends, when that is known, or MAINTENANCE_RETRY_AFTER
+func (s Status) RetryAfter() time.Duration {
+	if s.Until != nil && !s.Configured {
+		if wait := time.Until(*s.Until); wait > 0 {
+			return wait
+		}
+	}
+	return cfg.RetryAfter
+}
@@ -1,49 +1,125 @@
 package middleware
 
 import (
-	"ecommerce-backend/database"
+	"ecommerce-backend/apperrors"
 	"ecommerce-backend/models"
+	"ecommerce-backend/tenant"
 	"ecommerce-backend/utils"
-	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+		if c.GetHeader("Authorization") == "" {
+			abortWithError(c, apperrors.ErrUnauthorized.WithMessage("Authorization header is required"))
 			return
 		}
+		if authenticate(c) {
+			c.Next()
+		}
+	}
+}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader { // No Bearer prefix found
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token not found in Authorization header"})
-			c.Abort()
-			return
+// OptionalAuth authenticates requests that carry a bearer token, like
+// AuthMiddleware, and lets requests without one through anonymously
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" || authenticate(c) {
+			c.Next()
 		}
+	}
+}
 
-		username, err := utils.ValidateToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
-			return
+// QueryToken accepts the bearer token from the access_token query parameter
+// when there is no Authorization header. Browsers cannot set headers on
+// WebSocket handshakes, so it is only meant for those routes.
+func QueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.Query("access_token"); token != "" && c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
 		}
+		c.Next()
+	}
+}
+
+// authenticate validates the bearer token and stores the user and claims in
+// the context. It aborts the request and returns false if the token is
+// missing or invalid.
+func authenticate(c *gin.Context) bool {
+	authHeader := c.GetHeader("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader { // No Bearer prefix found
+		abortWithError(c, apperrors.ErrUnauthorized.WithMessage("Bearer token not found in Authorization header"))
+		return false
+	}
+
+	claims, err := utils.ValidateToken(c.Request.Context(), tokenString)
+	if err != nil {
+		abortWithError(c, apperrors.ErrInvalidToken.Wrap(err))
+		return false
+	}
 
-		// Get user from database
-		var user models.User
-		result := database.GetDB().Where("username = ? AND token = ?", username, tokenString).First(&user)
-		if result.Error != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user session"})
-			c.Abort()
+	// Tokens are only valid in the store that issued them
+	storeID := claims.StoreID
+	if storeID == 0 {
+		storeID = tenant.DefaultStoreID
+	}
+	if storeID != tenant.StoreOrDefault(c.Request.Context()) {
+		abortWithError(c, apperrors.ErrInvalidToken.WithMessage("token was issued by another store"))
+		return false
+	}
+
+	// Build user from the token claims; no database lookup is needed
+	user := models.User{
+		Model:    gorm.Model{ID: claims.UserID},
+		StoreID:  storeID,
+		Username: claims.Username,
+		Role:     claims.Role,
+	}
+	if claims.VendorID != 0 {
+		user.VendorID = &claims.VendorID
+	}
+
+	// Add user and claims to context
+	c.Set("user", user)
+	c.Set("claims", claims)
+	return true
+}
+
+// RequireRole restricts a route to users holding one of the given roles.
+// It must be used after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			abortWithError(c, apperrors.ErrUnauthorized)
 			return
 		}
 
-		// Add user to context
-		c.Set("user", user)
+		role := user.(models.User).Role
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+
+		abortWithError(c, apperrors.ErrForbidden)
+	}
+}
+
+// NoImpersonation refuses requests made with impersonation tokens, keeping
+// admins acting as a user away from the user's credentials. It must be used
+// after AuthMiddleware.
+func NoImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if claims, exists := c.Get("claims"); exists && claims.(*utils.Claims).Actor != nil {
+			abortWithError(c, apperrors.ErrImpersonationNotAllowed)
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/config"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLimit rejects request bodies larger than the configured size with
+// PAYLOAD_TOO_LARGE. Bodies without a Content-Length are cut off at the
+// limit, which fails their binding the same way. Routes using Upload are
+// bounded by their own handlers instead.
+func BodyLimit() gin.HandlerFunc {
+	limit := int64(config.Get().API.MaxBodySize)
+
+	// marked caches, per method and route, whether the route uses Upload
+	var marked sync.Map
+	uploadName := handlerName(uploadMarker)
+
+	return func(c *gin.Context) {
+		if limit <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		key := c.Request.Method + " " + c.FullPath()
+		upload, known := marked.Load(key)
+		if !known {
+			upload = slices.Contains(c.HandlerNames(), uploadName)
+			marked.Store(key, upload)
+		}
+		if upload.(bool) {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limit {
+			abortWithError(c, apperrors.ErrPayloadTooLarge)
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// Upload marks the route it is used on as taking file uploads, which
+// BodyLimit leaves to the route's handler to bound with its own
+// http.MaxBytesReader. It does nothing itself.
+func Upload() gin.HandlerFunc {
+	return uploadMarker
+}
+
+func uploadMarker(c *gin.Context) {}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"ecommerce-backend/apperrors"
+	"expvar"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CartBlocklist decides whether a client may create or modify carts.
+// Implementations can consult IP reputation, user flags, rate counters, etc.
+type CartBlocklist interface {
+	IsBlocked(c *gin.Context) bool
+}
+
+// CartBlocklistFunc adapts an ordinary function to a CartBlocklist
+type CartBlocklistFunc func(c *gin.Context) bool
+
+// IsBlocked calls f(c)
+func (f CartBlocklistFunc) IsBlocked(c *gin.Context) bool {
+	return f(c)
+}
+
+var cartRequestsBlocked = expvar.NewInt("cart_requests_blocked")
+
+// cartBlocklist is consulted by CartAbuseGuard; nothing is blocked by default
+var cartBlocklist CartBlocklist = CartBlocklistFunc(func(*gin.Context) bool { return false })
+
+// SetCartBlocklist installs the blocklist used by CartAbuseGuard.
+// It should be called during startup, before the server accepts requests.
+func SetCartBlocklist(b CartBlocklist) {
+	cartBlocklist = b
+}
+
+// CartAbuseGuard rejects cart writes from clients flagged by the blocklist
+func CartAbuseGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cartBlocklist.IsBlocked(c) {
+			cartRequestsBlocked.Add(1)
+			abortWithError(c, apperrors.ErrRateLimited.WithMessage("too many cart requests"))
+			return
+		}
+
+		c.Next()
+	}
+}
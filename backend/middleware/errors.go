@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/i18n"
+	"ecommerce-backend/logging"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorHandler renders the last error attached to the context with c.Error
+// as the standard error envelope. Handlers and middleware report failures
+// with c.Error(err) and return (or Abort) without writing a response.
+// Messages are translated into the language negotiated from Accept-Language.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		appErr := localize(c, apperrors.From(c.Errors.Last().Err))
+		c.JSON(appErr.Status, apperrors.Response{Error: appErr})
+	}
+}
+
+// Recovery turns panics into INTERNAL error responses
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		logging.FromContext(c.Request.Context()).Error("panic recovered", "panic", fmt.Sprint(recovered))
+		c.AbortWithStatusJSON(apperrors.ErrInternal.Status, apperrors.Response{Error: localize(c, apperrors.ErrInternal)})
+	})
+}
+
+// localize translates err into the client's preferred language and
+// declares the language of the response
+func localize(c *gin.Context, err *apperrors.Error) *apperrors.Error {
+	lang := i18n.Negotiate(c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", lang)
+	c.Writer.Header().Add("Vary", "Accept-Language")
+	return err.Localize(lang)
+}
+
+// abortWithError attaches err to the context for ErrorHandler and stops the
+// handler chain
+func abortWithError(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/maintenance"
+	"ecommerce-backend/models"
+	"ecommerce-backend/utils"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Maintenance refuses writes with 503 MAINTENANCE and a Retry-After header
+// while maintenance mode is on. Reads, including health checks, keep
+// working, as do logins and every request by an admin, so that admins can
+// manage the store and switch the mode off again.
+func Maintenance() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if strings.HasSuffix(c.FullPath(), "/users/login") {
+			c.Next()
+			return
+		}
+
+		status, err := maintenance.Get(c.Request.Context())
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Warn("failed to check maintenance mode", "error", err)
+		}
+		if !status.Enabled || byAdmin(c) {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(status.RetryAfter().Seconds()))))
+		abortWithError(c, apperrors.ErrMaintenance)
+	}
+}
+
+// byAdmin reports whether the request carries a valid admin token. The
+// token is still checked in full by AuthMiddleware on the route.
+func byAdmin(c *gin.Context) bool {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	claims, err := utils.ValidateToken(c.Request.Context(), token)
+	return err == nil && claims.Role == models.RoleAdmin
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"ecommerce-backend/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReadReplica lets the route's queries read from a database replica, when
+// DB_REPLICA_DSNS configures one and it is up. Only use it on read-only
+// routes that can show data a moment behind the primary.
+func ReadReplica() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(database.WithReplica(c.Request.Context()))
+		c.Next()
+	}
+}
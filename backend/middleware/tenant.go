@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/config"
+	"ecommerce-backend/database"
+	"ecommerce-backend/models"
+	"ecommerce-backend/tenant"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// StoreHeader names the store of a request by its slug
+const StoreHeader = "X-Store"
+
+// storeIDs caches store IDs by slug. Stores are never renamed or deleted
+// while the server runs, so entries do not expire.
+var storeIDs sync.Map
+
+// Tenant scopes the request to the store named by the X-Store header or,
+// when TENANT_BASE_DOMAIN is set, by the subdomain of the host. Requests
+// naming neither belong to the default store; unknown stores are 404.
+func Tenant() gin.HandlerFunc {
+	baseDomain := strings.ToLower(config.Get().Tenancy.BaseDomain)
+
+	return func(c *gin.Context) {
+		slug := c.GetHeader(StoreHeader)
+		if slug == "" {
+			slug = subdomain(c.Request.Host, baseDomain)
+		}
+
+		storeID := tenant.DefaultStoreID
+		if slug != "" {
+			id, err := lookupStore(c, strings.ToLower(slug))
+			if err != nil {
+				abortWithError(c, err)
+				return
+			}
+			storeID = id
+		}
+
+		c.Request = c.Request.WithContext(tenant.WithStore(c.Request.Context(), storeID))
+		c.Next()
+	}
+}
+
+// subdomain returns the single label host has in front of baseDomain, or
+// "" if host is not a subdomain of it
+func subdomain(host, baseDomain string) string {
+	if baseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	label, found := strings.CutSuffix(strings.ToLower(host), "."+baseDomain)
+	if !found || label == "" || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// lookupStore returns the ID of the store with the slug
+func lookupStore(c *gin.Context, slug string) (uint, error) {
+	if id, ok := storeIDs.Load(slug); ok {
+		return id.(uint), nil
+	}
+
+	var store models.Store
+	err := database.WithContext(c.Request.Context()).Select("id").Where("slug = ?", slug).First(&store).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, apperrors.ErrStoreNotFound
+		}
+		return 0, apperrors.Internal("failed to resolve store", err)
+	}
+
+	storeIDs.Store(slug, store.ID)
+	return store.ID, nil
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueryTimeout bounds the request context by the configured query timeout.
+// Handlers pass the request context to GORM, so queries are cancelled when
+// the deadline passes or the client disconnects. Event streams and
+// WebSocket handshakes are long-lived and left unbounded.
+func QueryTimeout() gin.HandlerFunc {
+	timeout := config.Get().DB.QueryTimeout
+
+	return func(c *gin.Context) {
+		if timeout <= 0 || longLived(c) {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// longLived reports whether the request opens an event stream or a WebSocket
+func longLived(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), "text/event-stream") ||
+		strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"ecommerce-backend/database"
+	"ecommerce-backend/migrations"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// newMigrateCmd implements `migrate up`, `migrate down` and `migrate status`
+func newMigrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply, roll back or list database migrations",
+	}
+
+	up := &cobra.Command{
+		Use:   "up",
+		Short: "Apply all pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: withDB(func(cmd *cobra.Command, args []string) error {
+			n, err := migrations.Up(database.GetDB())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("applied %d migration(s)\n", n)
+			return nil
+		}),
+	}
+
+	var steps int
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "Roll back the most recent migrations",
+		Args:  cobra.NoArgs,
+		RunE: withDB(func(cmd *cobra.Command, args []string) error {
+			n, err := migrations.Down(database.GetDB(), steps)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("rolled back %d migration(s)\n", n)
+			return nil
+		}),
+	}
+	down.Flags().IntVar(&steps, "steps", 1, "number of migrations to roll back")
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "List migrations and when they were applied",
+		Args:  cobra.NoArgs,
+		RunE: withDB(func(cmd *cobra.Command, args []string) error {
+			statuses, err := migrations.StatusOf(database.GetDB())
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+			for _, s := range statuses {
+				appliedAt := "pending"
+				if s.Applied {
+					appliedAt = s.AppliedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+			}
+			return w.Flush()
+		}),
+	}
+
+	cmd.AddCommand(up, down, status)
+	return cmd
+}
@@ -0,0 +1,94 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The structs below are snapshots of the schema at this version. They must
+// not be changed once released; later schema changes get their own migration.
+
+type User struct {
+	gorm.Model
+	Username     string `gorm:"size:255;uniqueIndex;not null"`
+	PasswordHash string `gorm:"not null"`
+	Role         string `gorm:"size:32;not null;default:'customer'"`
+}
+
+type Item struct {
+	gorm.Model
+	Name        string `gorm:"not null"`
+	Description string
+	Price       float64 `gorm:"not null"`
+}
+
+type Cart struct {
+	gorm.Model
+	UserID       uint `gorm:"not null"`
+	IsCheckedOut bool `gorm:"default:false"`
+	CheckedOutAt *time.Time
+}
+
+type CartItem struct {
+	gorm.Model
+	CartID   uint `gorm:"not null"`
+	ItemID   uint `gorm:"not null"`
+	Quantity int  `gorm:"default:1"`
+}
+
+type Order struct {
+	gorm.Model
+	UserID uint    `gorm:"not null"`
+	CartID uint    `gorm:"not null"`
+	Total  float64 `gorm:"not null"`
+	Status string  `gorm:"default:'pending'"`
+}
+
+type APIKey struct {
+	gorm.Model
+	Name       string `gorm:"not null"`
+	Prefix     string `gorm:"not null"`
+	KeyHash    string `gorm:"size:64;uniqueIndex;not null"`
+	UserID     uint   `gorm:"not null"`
+	Sandbox    bool   `gorm:"default:true"`
+	WebhookURL string
+	LastUsedAt *time.Time
+}
+
+type AuditLog struct {
+	ID           uint      `gorm:"primarykey"`
+	CreatedAt    time.Time `gorm:"index"`
+	Method       string    `gorm:"not null"`
+	Route        string    `gorm:"size:255;index;not null"`
+	Path         string    `gorm:"not null"`
+	Status       int
+	UserID       *uint `gorm:"index"`
+	ClientIP     string
+	LatencyMs    int64
+	RequestBody  string `gorm:"type:text"`
+	ResponseBody string `gorm:"type:text"`
+}
+
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "initial_schema",
+		// AutoMigrate makes this safe to apply to databases created before
+		// versioned migrations were introduced
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&User{}, &Item{}, &Cart{}, &CartItem{}, &Order{}, &APIKey{}, &AuditLog{}); err != nil {
+				return err
+			}
+
+			// Session tokens used to be stored on the user row
+			if tx.Migrator().HasColumn(&User{}, "token") {
+				return tx.Migrator().DropColumn(&User{}, "token")
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&AuditLog{}, &APIKey{}, &Order{}, &CartItem{}, &Cart{}, &Item{}, &User{})
+		},
+	})
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RevokedToken is the schema of revoked_tokens at this version
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "revoked_tokens",
+		// Token revocations used to be held in memory, so were lost on
+		// restart and not shared between instances
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&RevokedToken{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&RevokedToken{})
+		},
+	})
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ItemInventory is the schema of the inventory columns of items at this
+// version
+type ItemInventory struct {
+	Stock             *int
+	LowStockThreshold int `gorm:"not null;default:0"`
+	LowStockAlertedAt *time.Time
+}
+
+func (ItemInventory) TableName() string { return "items" }
+
+var itemInventoryColumns = []string{"Stock", "LowStockThreshold", "LowStockAlertedAt"}
+
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "inventory",
+		// Stock is nullable so existing items stay purchasable until their
+		// stock is counted
+		Up: func(tx *gorm.DB) error {
+			for _, column := range itemInventoryColumns {
+				if err := tx.Migrator().AddColumn(&ItemInventory{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range itemInventoryColumns {
+				if err := tx.Migrator().DropColumn(&ItemInventory{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Vendor is the schema of vendors at this version
+type Vendor struct {
+	gorm.Model
+	Name string `gorm:"size:255;uniqueIndex;not null"`
+}
+
+// SubOrder is the schema of sub_orders at this version
+type SubOrder struct {
+	gorm.Model
+	OrderID  uint    `gorm:"index;not null"`
+	VendorID uint    `gorm:"index;not null"`
+	Total    float64 `gorm:"not null"`
+	Status   string  `gorm:"size:32;not null"`
+}
+
+// UserVendor is the schema of the vendor column of users at this version
+type UserVendor struct {
+	VendorID *uint `gorm:"index"`
+}
+
+func (UserVendor) TableName() string { return "users" }
+
+// ItemVendor is the schema of the vendor column of items at this version
+type ItemVendor struct {
+	VendorID *uint `gorm:"index"`
+}
+
+func (ItemVendor) TableName() string { return "items" }
+
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "vendors",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.CreateTable(&Vendor{}, &SubOrder{}); err != nil {
+				return err
+			}
+			for _, model := range []interface{}{&UserVendor{}, &ItemVendor{}} {
+				if err := m.AddColumn(model, "VendorID"); err != nil {
+					return err
+				}
+				if err := m.CreateIndex(model, "VendorID"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, model := range []interface{}{&UserVendor{}, &ItemVendor{}} {
+				// SQLite loses the index if a later rollback dropped a column
+				if m.HasIndex(model, "VendorID") {
+					if err := m.DropIndex(model, "VendorID"); err != nil {
+						return err
+					}
+				}
+				if err := m.DropColumn(model, "VendorID"); err != nil {
+					return err
+				}
+			}
+			return m.DropTable(&SubOrder{}, &Vendor{})
+		},
+	})
+}
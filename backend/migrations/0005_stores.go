@@ -0,0 +1,138 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Store is the schema of stores at this version
+type Store struct {
+	gorm.Model
+	Slug string `gorm:"size:63;uniqueIndex;not null"`
+	Name string `gorm:"size:255;not null"`
+}
+
+// UserStore is the schema of the store column of users at this version,
+// which makes usernames unique per store
+type UserStore struct {
+	StoreID  uint   `gorm:"not null;default:1;index;uniqueIndex:idx_users_store_username,priority:1"`
+	Username string `gorm:"size:255;uniqueIndex:idx_users_store_username,priority:2;not null"`
+}
+
+func (UserStore) TableName() string { return "users" }
+
+// VendorStore is the schema of the store column of vendors at this
+// version, which makes vendor names unique per store
+type VendorStore struct {
+	StoreID uint   `gorm:"not null;default:1;index;uniqueIndex:idx_vendors_store_name,priority:1"`
+	Name    string `gorm:"size:255;uniqueIndex:idx_vendors_store_name,priority:2;not null"`
+}
+
+func (VendorStore) TableName() string { return "vendors" }
+
+// TableStore is the schema of the store column of the other store-owned
+// tables at this version
+type TableStore struct {
+	StoreID uint `gorm:"not null;default:1;index"`
+}
+
+// storeScopedTables are the tables given a store column besides users and
+// vendors
+var storeScopedTables = []string{"items", "carts", "orders", "api_keys", "audit_logs"}
+
+// storeUniqueIndexes are the global unique indexes replaced by ones scoped
+// to the store
+var storeUniqueIndexes = []struct {
+	model       interface{}
+	old, scoped string
+	column      string
+}{
+	{&UserStore{}, "idx_users_username", "idx_users_store_username", "username"},
+	{&VendorStore{}, "idx_vendors_name", "idx_vendors_store_name", "name"},
+}
+
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "stores",
+		// Existing data belongs to the default store, which is created
+		// first and so gets ID 1, the column default
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.CreateTable(&Store{}); err != nil {
+				return err
+			}
+			if err := tx.Create(&Store{Slug: "default", Name: "Default store"}).Error; err != nil {
+				return err
+			}
+
+			for _, sm := range storeMigrators(tx) {
+				if err := sm.migrator.AddColumn(sm.model, "StoreID"); err != nil {
+					return err
+				}
+				if err := sm.migrator.CreateIndex(sm.model, "StoreID"); err != nil {
+					return err
+				}
+			}
+			for _, idx := range storeUniqueIndexes {
+				if m.HasIndex(idx.model, idx.old) {
+					if err := m.DropIndex(idx.model, idx.old); err != nil {
+						return err
+					}
+				}
+				if err := m.CreateIndex(idx.model, idx.scoped); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		// Rolling back fails if a username or vendor name is used in more
+		// than one store. SQLite drops a table's indexes along with any of
+		// its columns, so here and in Up indexes are only dropped if still
+		// present.
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, idx := range storeUniqueIndexes {
+				if m.HasIndex(idx.model, idx.scoped) {
+					if err := m.DropIndex(idx.model, idx.scoped); err != nil {
+						return err
+					}
+				}
+			}
+			for _, sm := range storeMigrators(tx) {
+				if sm.migrator.HasIndex(sm.model, "StoreID") {
+					if err := sm.migrator.DropIndex(sm.model, "StoreID"); err != nil {
+						return err
+					}
+				}
+				if err := sm.migrator.DropColumn(sm.model, "StoreID"); err != nil {
+					return err
+				}
+			}
+			for _, idx := range storeUniqueIndexes {
+				table := idx.model.(interface{ TableName() string }).TableName()
+				if err := tx.Exec("CREATE UNIQUE INDEX " + idx.old + " ON " + table + " (" + idx.column + ")").Error; err != nil {
+					return err
+				}
+			}
+			return m.DropTable(&Store{})
+		},
+	})
+}
+
+type storeMigrator struct {
+	migrator gorm.Migrator
+	model    interface{}
+}
+
+// storeMigrators returns a migrator and snapshot for every table with a
+// store column
+func storeMigrators(tx *gorm.DB) []storeMigrator {
+	sms := []storeMigrator{
+		{tx.Migrator(), &UserStore{}},
+		{tx.Migrator(), &VendorStore{}},
+	}
+	for _, table := range storeScopedTables {
+		sms = append(sms, storeMigrator{tx.Table(table).Migrator(), &TableStore{}})
+	}
+	return sms
+}
@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// ShippingMethod is the schema of shipping_methods at this version
+type ShippingMethod struct {
+	gorm.Model
+	StoreID  uint    `gorm:"not null;default:1;index"`
+	Name     string  `gorm:"size:255;not null"`
+	Kind     string  `gorm:"size:32;not null"`
+	Rate     float64 `gorm:"not null;default:0"`
+	FreeOver float64 `gorm:"not null;default:0"`
+	PerKg    float64 `gorm:"not null;default:0"`
+}
+
+// ItemWeight is the schema of the weight column of items at this version
+type ItemWeight struct {
+	WeightKg float64 `gorm:"not null;default:0"`
+}
+
+func (ItemWeight) TableName() string { return "items" }
+
+// OrderShipping is the schema of the shipping columns of orders at this
+// version
+type OrderShipping struct {
+	ShippingMethodID *uint
+	ShippingCost     float64 `gorm:"not null;default:0"`
+}
+
+func (OrderShipping) TableName() string { return "orders" }
+
+var orderShippingColumns = []string{"ShippingMethodID", "ShippingCost"}
+
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "shipping",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.CreateTable(&ShippingMethod{}); err != nil {
+				return err
+			}
+			if err := m.AddColumn(&ItemWeight{}, "WeightKg"); err != nil {
+				return err
+			}
+			for _, column := range orderShippingColumns {
+				if err := m.AddColumn(&OrderShipping{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range orderShippingColumns {
+				if err := m.DropColumn(&OrderShipping{}, column); err != nil {
+					return err
+				}
+			}
+			if err := m.DropColumn(&ItemWeight{}, "WeightKg"); err != nil {
+				return err
+			}
+			return m.DropTable(&ShippingMethod{})
+		},
+	})
+}
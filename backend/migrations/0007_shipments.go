@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Shipment is the schema of shipments at this version
+type Shipment struct {
+	gorm.Model
+	StoreID        uint   `gorm:"not null;default:1;index"`
+	OrderID        uint   `gorm:"index;not null"`
+	Carrier        string `gorm:"size:50;not null;uniqueIndex:idx_shipments_tracking,priority:1"`
+	TrackingNumber string `gorm:"size:100;not null;uniqueIndex:idx_shipments_tracking,priority:2"`
+	Status         string `gorm:"size:32;not null;default:'pending'"`
+	DeliveredAt    *time.Time
+	CheckedAt      *time.Time
+}
+
+// TrackingEvent is the schema of tracking_events at this version
+type TrackingEvent struct {
+	gorm.Model
+	ShipmentID  uint      `gorm:"index;not null"`
+	Status      string    `gorm:"size:32;not null"`
+	Description string    `gorm:"size:255"`
+	Location    string    `gorm:"size:255"`
+	OccurredAt  time.Time `gorm:"not null"`
+}
+
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "shipments",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&Shipment{}, &TrackingEvent{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&TrackingEvent{}, &Shipment{})
+		},
+	})
+}
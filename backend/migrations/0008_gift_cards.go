@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GiftCard is the schema of gift_cards at this version
+type GiftCard struct {
+	gorm.Model
+	StoreID        uint    `gorm:"not null;default:1;index"`
+	Code           string  `gorm:"size:32;uniqueIndex;not null"`
+	InitialBalance float64 `gorm:"not null"`
+	Balance        float64 `gorm:"not null"`
+	OrderID        *uint   `gorm:"index"`
+	UserID         *uint   `gorm:"index"`
+	VoidedAt       *time.Time
+}
+
+// GiftCardEntry is the schema of gift_card_entries at this version
+type GiftCardEntry struct {
+	gorm.Model
+	GiftCardID uint    `gorm:"index;not null"`
+	Kind       string  `gorm:"size:16;not null"`
+	Amount     float64 `gorm:"not null"`
+	OrderID    *uint
+}
+
+// ItemGiftCard is the schema of the gift card flag of items at this
+// version
+type ItemGiftCard struct {
+	GiftCard bool `gorm:"not null;default:false"`
+}
+
+func (ItemGiftCard) TableName() string { return "items" }
+
+// OrderGiftCard is the schema of the gift card column of orders at this
+// version
+type OrderGiftCard struct {
+	GiftCardAmount float64 `gorm:"not null;default:0"`
+}
+
+func (OrderGiftCard) TableName() string { return "orders" }
+
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "gift_cards",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.CreateTable(&GiftCard{}, &GiftCardEntry{}); err != nil {
+				return err
+			}
+			if err := m.AddColumn(&ItemGiftCard{}, "GiftCard"); err != nil {
+				return err
+			}
+			return m.AddColumn(&OrderGiftCard{}, "GiftCardAmount")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropColumn(&OrderGiftCard{}, "GiftCardAmount"); err != nil {
+				return err
+			}
+			if err := m.DropColumn(&ItemGiftCard{}, "GiftCard"); err != nil {
+				return err
+			}
+			return m.DropTable(&GiftCardEntry{}, &GiftCard{})
+		},
+	})
+}
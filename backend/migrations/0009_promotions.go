@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Promotion is the schema of promotions at this version
+type Promotion struct {
+	gorm.Model
+	StoreID     uint    `gorm:"not null;default:1;index"`
+	Name        string  `gorm:"size:255;not null"`
+	Kind        string  `gorm:"size:32;not null"`
+	ItemID      *uint   `gorm:"index"`
+	Category    string  `gorm:"size:64;not null;default:''"`
+	BuyQuantity int     `gorm:"not null;default:0"`
+	GetQuantity int     `gorm:"not null;default:0"`
+	MinQuantity int     `gorm:"not null;default:0"`
+	Percent     float64 `gorm:"not null;default:0"`
+	StartsAt    *time.Time
+	EndsAt      *time.Time
+}
+
+// OrderPromotion is the schema of order_promotions at this version
+type OrderPromotion struct {
+	gorm.Model
+	OrderID     uint    `gorm:"index;not null"`
+	PromotionID uint    `gorm:"not null"`
+	Name        string  `gorm:"size:255;not null"`
+	Amount      float64 `gorm:"not null"`
+}
+
+// ItemCategory is the schema of the category of items at this version
+type ItemCategory struct {
+	Category string `gorm:"size:64;not null;default:'';index"`
+}
+
+func (ItemCategory) TableName() string { return "items" }
+
+// OrderDiscount is the schema of the discount of orders at this version
+type OrderDiscount struct {
+	Discount float64 `gorm:"not null;default:0"`
+}
+
+func (OrderDiscount) TableName() string { return "orders" }
+
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "promotions",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.CreateTable(&Promotion{}, &OrderPromotion{}); err != nil {
+				return err
+			}
+			if err := m.AddColumn(&ItemCategory{}, "Category"); err != nil {
+				return err
+			}
+			if err := m.CreateIndex(&ItemCategory{}, "Category"); err != nil {
+				return err
+			}
+			return m.AddColumn(&OrderDiscount{}, "Discount")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropColumn(&OrderDiscount{}, "Discount"); err != nil {
+				return err
+			}
+			if m.HasIndex(&ItemCategory{}, "Category") {
+				if err := m.DropIndex(&ItemCategory{}, "Category"); err != nil {
+					return err
+				}
+			}
+			if err := m.DropColumn(&ItemCategory{}, "Category"); err != nil {
+				return err
+			}
+			return m.DropTable(&OrderPromotion{}, &Promotion{})
+		},
+	})
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// CartReminder is the schema of cart_reminders at this version
+type CartReminder struct {
+	gorm.Model
+	StoreID    uint   `gorm:"not null;default:1;index"`
+	CartID     uint   `gorm:"uniqueIndex;not null"`
+	UserID     uint   `gorm:"index;not null"`
+	Email      string `gorm:"size:255;not null"`
+	GiftCardID *uint
+}
+
+// UserEmail is the schema of the email column of users at this version
+type UserEmail struct {
+	Email string `gorm:"size:255;not null;default:''"`
+}
+
+func (UserEmail) TableName() string { return "users" }
+
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "cart_reminders",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.CreateTable(&CartReminder{}); err != nil {
+				return err
+			}
+			return m.AddColumn(&UserEmail{}, "Email")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropColumn(&UserEmail{}, "Email"); err != nil {
+				return err
+			}
+			return m.DropTable(&CartReminder{})
+		},
+	})
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Warehouse is the schema of warehouses at this version
+type Warehouse struct {
+	gorm.Model
+	StoreID  uint   `gorm:"not null;default:1;index"`
+	Name     string `gorm:"size:255;not null"`
+	Priority int    `gorm:"not null;default:0"`
+}
+
+// WarehouseStock is the schema of warehouse_stocks at this version
+type WarehouseStock struct {
+	gorm.Model
+	WarehouseID uint `gorm:"not null;uniqueIndex:idx_warehouse_stocks_item,priority:1"`
+	ItemID      uint `gorm:"not null;index;uniqueIndex:idx_warehouse_stocks_item,priority:2"`
+	Quantity    int  `gorm:"not null;default:0"`
+}
+
+// StockTransfer is the schema of stock_transfers at this version
+type StockTransfer struct {
+	gorm.Model
+	StoreID         uint `gorm:"not null;default:1;index"`
+	ItemID          uint `gorm:"not null;index"`
+	FromWarehouseID uint `gorm:"not null"`
+	ToWarehouseID   uint `gorm:"not null"`
+	Quantity        int  `gorm:"not null"`
+	UserID          uint `gorm:"not null"`
+}
+
+// OrderAllocation is the schema of order_allocations at this version
+type OrderAllocation struct {
+	gorm.Model
+	OrderID     uint `gorm:"index;not null"`
+	ItemID      uint `gorm:"not null"`
+	WarehouseID uint `gorm:"not null"`
+	Quantity    int  `gorm:"not null"`
+}
+
+func init() {
+	register(Migration{
+		Version: 11,
+		Name:    "warehouses",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&Warehouse{}, &WarehouseStock{}, &StockTransfer{}, &OrderAllocation{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&OrderAllocation{}, &StockTransfer{}, &WarehouseStock{}, &Warehouse{})
+		},
+	})
+}
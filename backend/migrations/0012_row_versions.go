@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// ItemVersion is the schema of the version column of items at this version
+type ItemVersion struct {
+	Version int `gorm:"not null;default:0"`
+}
+
+func (ItemVersion) TableName() string { return "items" }
+
+// CartVersion is the schema of the version column of carts at this version
+type CartVersion struct {
+	Version int `gorm:"not null;default:0"`
+}
+
+func (CartVersion) TableName() string { return "carts" }
+
+// CartItemVersion is the schema of the version column of cart_items at
+// this version
+type CartItemVersion struct {
+	Version int `gorm:"not null;default:0"`
+}
+
+func (CartItemVersion) TableName() string { return "cart_items" }
+
+// versioned are the tables given a version column for optimistic locking
+var versioned = []interface{}{&ItemVersion{}, &CartVersion{}, &CartItemVersion{}}
+
+func init() {
+	register(Migration{
+		Version: 12,
+		Name:    "row_versions",
+		Up: func(tx *gorm.DB) error {
+			for _, model := range versioned {
+				if err := tx.Migrator().AddColumn(model, "Version"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, model := range versioned {
+				if err := tx.Migrator().DropColumn(model, "Version"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrderNumber is the schema of the number column of orders at this version
+type OrderNumber struct {
+	ID        uint
+	CreatedAt time.Time
+	Number    *string `gorm:"size:32;uniqueIndex"`
+}
+
+func (OrderNumber) TableName() string { return "orders" }
+
+// numberOrders gives every existing order a random number in the year it
+// was placed, as checkout does for new ones
+func numberOrders(tx *gorm.DB) error {
+	var orders []OrderNumber
+	if err := tx.Select("id", "created_at").Find(&orders).Error; err != nil {
+		return err
+	}
+
+	used := map[string]bool{}
+	for _, order := range orders {
+		var number string
+		for number == "" || used[number] {
+			n, err := rand.Int(rand.Reader, big.NewInt(100000000))
+			if err != nil {
+				return err
+			}
+			number = fmt.Sprintf("ORD-%d-%08d", order.CreatedAt.Year(), n.Int64())
+		}
+		used[number] = true
+		if err := tx.Model(&OrderNumber{}).Where("id = ?", order.ID).Update("number", number).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	register(Migration{
+		Version: 13,
+		Name:    "order_numbers",
+		// The index is created once the existing orders are numbered
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.AddColumn(&OrderNumber{}, "Number"); err != nil {
+				return err
+			}
+			if err := numberOrders(tx); err != nil {
+				return err
+			}
+			return m.CreateIndex(&OrderNumber{}, "Number")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&OrderNumber{}, "Number"); err != nil {
+				return err
+			}
+			return m.DropColumn(&OrderNumber{}, "Number")
+		},
+	})
+}
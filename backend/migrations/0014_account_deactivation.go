@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserDeactivation is the schema of the deactivation columns of users at
+// this version
+type UserDeactivation struct {
+	DeactivatedAt *time.Time `gorm:"index"`
+	AnonymizedAt  *time.Time
+}
+
+func (UserDeactivation) TableName() string { return "users" }
+
+func init() {
+	register(Migration{
+		Version: 14,
+		Name:    "account_deactivation",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range []string{"DeactivatedAt", "AnonymizedAt"} {
+				if err := m.AddColumn(&UserDeactivation{}, column); err != nil {
+					return err
+				}
+			}
+			return m.CreateIndex(&UserDeactivation{}, "DeactivatedAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&UserDeactivation{}, "DeactivatedAt"); err != nil {
+				return err
+			}
+			for _, column := range []string{"DeactivatedAt", "AnonymizedAt"} {
+				if err := m.DropColumn(&UserDeactivation{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
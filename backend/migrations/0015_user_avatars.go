@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// UserAvatar is the schema of the avatar column of users at this version
+type UserAvatar struct {
+	AvatarURL string `gorm:"size:512;not null;default:''"`
+}
+
+func (UserAvatar) TableName() string { return "users" }
+
+func init() {
+	register(Migration{
+		Version: 15,
+		Name:    "user_avatars",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&UserAvatar{}, "AvatarURL")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&UserAvatar{}, "AvatarURL")
+		},
+	})
+}
@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// PostalAddress is the schema of a postal address at this version
+type PostalAddress struct {
+	Name       string `gorm:"size:255;not null;default:''"`
+	Line1      string `gorm:"size:255;not null;default:''"`
+	Line2      string `gorm:"size:255;not null;default:''"`
+	City       string `gorm:"size:100;not null;default:''"`
+	Region     string `gorm:"size:100;not null;default:''"`
+	PostalCode string `gorm:"size:20;not null;default:''"`
+	Country    string `gorm:"size:2;not null;default:''"`
+}
+
+// Address is the schema of addresses at this version
+type Address struct {
+	gorm.Model
+	StoreID uint `gorm:"not null;default:1;index"`
+	UserID  uint `gorm:"index;not null"`
+	PostalAddress
+	Status       string `gorm:"size:32;not null"`
+	StatusReason string `gorm:"size:255;not null;default:''"`
+}
+
+// OrderShippingAddress is the schema of the shipping address columns of
+// orders at this version
+type OrderShippingAddress struct {
+	ShippingAddress PostalAddress `gorm:"embedded;embeddedPrefix:shipping_"`
+}
+
+func (OrderShippingAddress) TableName() string { return "orders" }
+
+var orderShippingAddressColumns = []string{
+	"shipping_name", "shipping_line1", "shipping_line2", "shipping_city",
+	"shipping_region", "shipping_postal_code", "shipping_country",
+}
+
+func init() {
+	register(Migration{
+		Version: 16,
+		Name:    "addresses",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.CreateTable(&Address{}); err != nil {
+				return err
+			}
+			for _, column := range orderShippingAddressColumns {
+				if err := m.AddColumn(&OrderShippingAddress{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range orderShippingAddressColumns {
+				if err := m.DropColumn(&OrderShippingAddress{}, column); err != nil {
+					return err
+				}
+			}
+			return m.DropTable(&Address{})
+		},
+	})
+}
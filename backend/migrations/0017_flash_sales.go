@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Sale is the schema of sales at this version
+type Sale struct {
+	gorm.Model
+	StoreID      uint   `gorm:"not null;default:1;index"`
+	Name         string `gorm:"size:255;not null"`
+	ItemID       *uint  `gorm:"index"`
+	Category     string `gorm:"size:64;not null;default:''"`
+	Price        *float64
+	Percent      float64   `gorm:"not null;default:0"`
+	PerUserLimit int       `gorm:"not null;default:0"`
+	StartsAt     time.Time `gorm:"not null"`
+	EndsAt       time.Time `gorm:"not null"`
+	Active       bool      `gorm:"not null;default:false;index"`
+}
+
+// SalePurchase is the schema of sale_purchases at this version
+type SalePurchase struct {
+	gorm.Model
+	SaleID   uint    `gorm:"index;not null"`
+	OrderID  uint    `gorm:"index;not null"`
+	UserID   uint    `gorm:"index;not null"`
+	ItemID   uint    `gorm:"not null"`
+	Name     string  `gorm:"size:255;not null"`
+	Quantity int     `gorm:"not null"`
+	Amount   float64 `gorm:"not null"`
+}
+
+func init() {
+	register(Migration{
+		Version: 17,
+		Name:    "flash_sales",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&Sale{}, &SalePurchase{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&SalePurchase{}, &Sale{})
+		},
+	})
+}
@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// Bundle is the schema of bundles at this version
+type Bundle struct {
+	gorm.Model
+	StoreID     uint   `gorm:"not null;default:1;index"`
+	Name        string `gorm:"size:255;not null"`
+	Description string
+	Price       float64 `gorm:"not null"`
+}
+
+// BundleItem is the schema of bundle_items at this version
+type BundleItem struct {
+	gorm.Model
+	BundleID uint `gorm:"index;not null"`
+	ItemID   uint `gorm:"not null"`
+	Quantity int  `gorm:"not null;default:1"`
+}
+
+// OrderBundle is the schema of order_bundles at this version
+type OrderBundle struct {
+	gorm.Model
+	OrderID  uint    `gorm:"index;not null"`
+	BundleID uint    `gorm:"not null"`
+	Name     string  `gorm:"size:255;not null"`
+	Quantity int     `gorm:"not null"`
+	Amount   float64 `gorm:"not null"`
+}
+
+// CartItemBundle is the schema of the bundle column of cart_items at this
+// version
+type CartItemBundle struct {
+	BundleID *uint `gorm:"index"`
+}
+
+func (CartItemBundle) TableName() string { return "cart_items" }
+
+func init() {
+	register(Migration{
+		Version: 18,
+		Name:    "bundles",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.CreateTable(&Bundle{}, &BundleItem{}, &OrderBundle{}); err != nil {
+				return err
+			}
+			if err := m.AddColumn(&CartItemBundle{}, "BundleID"); err != nil {
+				return err
+			}
+			return m.CreateIndex(&CartItemBundle{}, "BundleID")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if m.HasIndex(&CartItemBundle{}, "BundleID") {
+				if err := m.DropIndex(&CartItemBundle{}, "BundleID"); err != nil {
+					return err
+				}
+			}
+			if err := m.DropColumn(&CartItemBundle{}, "BundleID"); err != nil {
+				return err
+			}
+			return m.DropTable(&OrderBundle{}, &BundleItem{}, &Bundle{})
+		},
+	})
+}
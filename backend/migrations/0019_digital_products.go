@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// ItemDigital is the schema of the digital column of items at this version
+type ItemDigital struct {
+	Digital bool `gorm:"not null;default:false"`
+}
+
+func (ItemDigital) TableName() string { return "items" }
+
+// DigitalFile is the schema of digital_files at this version
+type DigitalFile struct {
+	gorm.Model
+	ItemID      uint   `gorm:"not null;uniqueIndex"`
+	Key         string `gorm:"size:255;not null"`
+	Name        string `gorm:"size:255;not null"`
+	ContentType string `gorm:"size:255;not null"`
+	Size        int64  `gorm:"not null"`
+}
+
+// Download is the schema of downloads at this version
+type Download struct {
+	gorm.Model
+	OrderID      uint   `gorm:"index;not null"`
+	UserID       uint   `gorm:"index;not null"`
+	ItemID       uint   `gorm:"not null"`
+	Name         string `gorm:"size:255;not null"`
+	Downloaded   int    `gorm:"not null;default:0"`
+	MaxDownloads int    `gorm:"not null;default:0"`
+	Issue        int    `gorm:"not null;default:0"`
+}
+
+func init() {
+	register(Migration{
+		Version: 19,
+		Name:    "digital_products",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.AddColumn(&ItemDigital{}, "Digital"); err != nil {
+				return err
+			}
+			return m.CreateTable(&DigitalFile{}, &Download{})
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropTable(&Download{}, &DigitalFile{}); err != nil {
+				return err
+			}
+			return m.DropColumn(&ItemDigital{}, "Digital")
+		},
+	})
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ItemBackorder is the schema of the backorder columns of items at this
+// version
+type ItemBackorder struct {
+	Backorder  string `gorm:"size:16;not null;default:''"`
+	ExpectedAt *time.Time
+}
+
+func (ItemBackorder) TableName() string { return "items" }
+
+// OrderBackorder is the schema of order_backorders at this version
+type OrderBackorder struct {
+	gorm.Model
+	StoreID     uint   `gorm:"not null;default:1;index"`
+	OrderID     uint   `gorm:"index;not null"`
+	ItemID      uint   `gorm:"index;not null"`
+	Name        string `gorm:"size:255;not null"`
+	Kind        string `gorm:"size:16;not null"`
+	Quantity    int    `gorm:"not null"`
+	ExpectedAt  *time.Time
+	FulfilledAt *time.Time `gorm:"index"`
+}
+
+func init() {
+	register(Migration{
+		Version: 20,
+		Name:    "backorders",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range []string{"Backorder", "ExpectedAt"} {
+				if err := m.AddColumn(&ItemBackorder{}, column); err != nil {
+					return err
+				}
+			}
+			return m.CreateTable(&OrderBackorder{})
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropTable(&OrderBackorder{}); err != nil {
+				return err
+			}
+			for _, column := range []string{"ExpectedAt", "Backorder"} {
+				if err := m.DropColumn(&ItemBackorder{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// OrderMetadata is the schema of the metadata column of orders at this
+// version
+type OrderMetadata struct {
+	Metadata string `gorm:"type:text"`
+}
+
+func (OrderMetadata) TableName() string { return "orders" }
+
+func init() {
+	register(Migration{
+		Version: 21,
+		Name:    "order_metadata",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&OrderMetadata{}, "Metadata")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&OrderMetadata{}, "Metadata")
+		},
+	})
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// ItemActive is the schema of the active flag of items at this version;
+// existing items stay listed
+type ItemActive struct {
+	IsActive bool `gorm:"not null;default:true;index"`
+}
+
+func (ItemActive) TableName() string { return "items" }
+
+func init() {
+	register(Migration{
+		Version: 22,
+		Name:    "item_active",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.AddColumn(&ItemActive{}, "IsActive"); err != nil {
+				return err
+			}
+			return m.CreateIndex(&ItemActive{}, "IsActive")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&ItemActive{}, "IsActive"); err != nil {
+				return err
+			}
+			return m.DropColumn(&ItemActive{}, "IsActive")
+		},
+	})
+}
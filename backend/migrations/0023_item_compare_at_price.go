@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// ItemCompareAtPrice is the schema of the compare-at price of items at this
+// version
+type ItemCompareAtPrice struct {
+	CompareAtPrice *float64
+}
+
+func (ItemCompareAtPrice) TableName() string { return "items" }
+
+func init() {
+	register(Migration{
+		Version: 23,
+		Name:    "item_compare_at_price",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&ItemCompareAtPrice{}, "CompareAtPrice")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&ItemCompareAtPrice{}, "CompareAtPrice")
+		},
+	})
+}
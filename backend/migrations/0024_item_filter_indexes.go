@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// ItemFilterIndexes is the schema of the indexes backing the catalog's price
+// and stock filters at this version
+type ItemFilterIndexes struct {
+	Price float64 `gorm:"not null;index"`
+	Stock *int    `gorm:"index"`
+}
+
+func (ItemFilterIndexes) TableName() string { return "items" }
+
+func init() {
+	register(Migration{
+		Version: 24,
+		Name:    "item_filter_indexes",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.CreateIndex(&ItemFilterIndexes{}, "Price"); err != nil {
+				return err
+			}
+			return m.CreateIndex(&ItemFilterIndexes{}, "Stock")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&ItemFilterIndexes{}, "Stock"); err != nil {
+				return err
+			}
+			return m.DropIndex(&ItemFilterIndexes{}, "Price")
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// ItemQuantityLimits is the schema of the purchase quantity limits of items
+// at this version
+type ItemQuantityLimits struct {
+	MinQuantity int `gorm:"not null;default:0"`
+	MaxQuantity int `gorm:"not null;default:0"`
+}
+
+func (ItemQuantityLimits) TableName() string { return "items" }
+
+var itemQuantityLimitColumns = []string{"MinQuantity", "MaxQuantity"}
+
+func init() {
+	register(Migration{
+		Version: 25,
+		Name:    "item_quantity_limits",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range itemQuantityLimitColumns {
+				if err := tx.Migrator().AddColumn(&ItemQuantityLimits{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range itemQuantityLimitColumns {
+				if err := tx.Migrator().DropColumn(&ItemQuantityLimits{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// InventoryMovement is the schema of inventory_movements at this version
+type InventoryMovement struct {
+	gorm.Model
+	StoreID     uint   `gorm:"not null;default:1;index"`
+	ItemID      uint   `gorm:"not null;index"`
+	Delta       int    `gorm:"not null"`
+	Reason      string `gorm:"size:32;not null"`
+	ActorID     *uint
+	OrderID     *uint
+	WarehouseID *uint
+}
+
+func init() {
+	register(Migration{
+		Version: 26,
+		Name:    "inventory_movements",
+		// Items already tracking their stock open the ledger with it, so
+		// that their movements add up to their stock
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&InventoryMovement{}); err != nil {
+				return err
+			}
+			return tx.Exec(`INSERT INTO inventory_movements (created_at, updated_at, store_id, item_id, delta, reason)
+				SELECT CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, store_id, id, stock, 'opening'
+				FROM items WHERE stock IS NOT NULL AND deleted_at IS NULL`).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&InventoryMovement{})
+		},
+	})
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// AuditImpersonation is the schema of the impersonator column of audit logs
+// at this version
+type AuditImpersonation struct {
+	ImpersonatorID *uint `gorm:"index"`
+}
+
+func (AuditImpersonation) TableName() string { return "audit_logs" }
+
+func init() {
+	register(Migration{
+		Version: 27,
+		Name:    "audit_impersonation",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.AddColumn(&AuditImpersonation{}, "ImpersonatorID"); err != nil {
+				return err
+			}
+			return m.CreateIndex(&AuditImpersonation{}, "ImpersonatorID")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&AuditImpersonation{}, "ImpersonatorID"); err != nil {
+				return err
+			}
+			return m.DropColumn(&AuditImpersonation{}, "ImpersonatorID")
+		},
+	})
+}
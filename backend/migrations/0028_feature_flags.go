@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// FeatureFlag is the schema of feature_flags at this version
+type FeatureFlag struct {
+	gorm.Model
+	StoreID     uint   `gorm:"not null;default:1;uniqueIndex:idx_feature_flags_store_name,priority:1"`
+	Name        string `gorm:"size:64;not null;uniqueIndex:idx_feature_flags_store_name,priority:2"`
+	Description string `gorm:"size:255;not null;default:''"`
+	Enabled     bool   `gorm:"not null;default:false"`
+	Percentage  int    `gorm:"not null;default:0"`
+	UserIDs     string `gorm:"type:text"`
+}
+
+func init() {
+	register(Migration{
+		Version: 28,
+		Name:    "feature_flags",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&FeatureFlag{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&FeatureFlag{})
+		},
+	})
+}
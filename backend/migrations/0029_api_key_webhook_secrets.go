@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyWebhookSecret is the schema of the webhook secret column of API
+// keys at this version
+type APIKeyWebhookSecret struct {
+	WebhookSecret string `gorm:"size:64;not null;default:''"`
+}
+
+func (APIKeyWebhookSecret) TableName() string { return "api_keys" }
+
+func init() {
+	register(Migration{
+		Version: 29,
+		Name:    "api_key_webhook_secrets",
+		// Existing keys get a secret of their own, which their owners
+		// obtain by rotating it
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&APIKeyWebhookSecret{}, "WebhookSecret"); err != nil {
+				return err
+			}
+			var ids []uint
+			if err := tx.Table("api_keys").Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			for _, id := range ids {
+				random := make([]byte, 20)
+				if _, err := rand.Read(random); err != nil {
+					return err
+				}
+				secret := "whsec_" + hex.EncodeToString(random)
+				if err := tx.Table("api_keys").Where("id = ?", id).Update("webhook_secret", secret).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&APIKeyWebhookSecret{}, "WebhookSecret")
+		},
+	})
+}
@@ -0,0 +1,87 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// EncryptedPostalAddress is the schema of a postal address at this
+// version, with room for the encrypted fields
+type EncryptedPostalAddress struct {
+	Name       string `gorm:"size:512;not null;default:''"`
+	Line1      string `gorm:"size:512;not null;default:''"`
+	Line2      string `gorm:"size:512;not null;default:''"`
+	City       string `gorm:"size:255;not null;default:''"`
+	Region     string `gorm:"size:100;not null;default:''"`
+	PostalCode string `gorm:"size:128;not null;default:''"`
+	Country    string `gorm:"size:2;not null;default:''"`
+}
+
+// EncryptedAddress is the schema of the encrypted columns of addresses
+// at this version
+type EncryptedAddress struct {
+	EncryptedPostalAddress
+}
+
+func (EncryptedAddress) TableName() string { return "addresses" }
+
+// EncryptedOrderShippingAddress is the schema of the shipping address
+// columns of orders at this version
+type EncryptedOrderShippingAddress struct {
+	ShippingAddress EncryptedPostalAddress `gorm:"embedded;embeddedPrefix:shipping_"`
+}
+
+func (EncryptedOrderShippingAddress) TableName() string { return "orders" }
+
+// EncryptedUserEmail is the schema of the email column of users at this
+// version
+type EncryptedUserEmail struct {
+	Email string `gorm:"size:512;not null;default:''"`
+}
+
+func (EncryptedUserEmail) TableName() string { return "users" }
+
+// EncryptedCartReminderEmail is the schema of the email column of cart
+// reminders at this version
+type EncryptedCartReminderEmail struct {
+	Email string `gorm:"size:512;not null"`
+}
+
+func (EncryptedCartReminderEmail) TableName() string { return "cart_reminders" }
+
+var encryptedAddressColumns = []string{"name", "line1", "line2", "city", "postal_code"}
+
+func init() {
+	register(Migration{
+		Version: 30,
+		Name:    "pii_encryption",
+		// Encrypted values are longer than the plaintext they replace, so
+		// the columns holding them are widened. Existing rows stay in the
+		// clear until `admin rotate-pii-key` encrypts them.
+		Up: func(tx *gorm.DB) error {
+			// SQLite ignores column sizes, and altering a column there
+			// rebuilds the table without its indexes
+			if tx.Dialector.Name() == "sqlite" {
+				return nil
+			}
+			m := tx.Migrator()
+			if err := m.AlterColumn(&EncryptedUserEmail{}, "email"); err != nil {
+				return err
+			}
+			if err := m.AlterColumn(&EncryptedCartReminderEmail{}, "email"); err != nil {
+				return err
+			}
+			for _, column := range encryptedAddressColumns {
+				if err := m.AlterColumn(&EncryptedAddress{}, column); err != nil {
+					return err
+				}
+				if err := m.AlterColumn(&EncryptedOrderShippingAddress{}, "shipping_"+column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		// The columns are left wide, as encrypted values would not fit
+		// the narrower ones
+		Down: func(tx *gorm.DB) error { return nil },
+	})
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PurgeRun is the schema of purge_runs at this version
+type PurgeRun struct {
+	gorm.Model
+	Trigger     string `gorm:"size:32;not null"`
+	TriggeredBy *uint
+	Status      string    `gorm:"size:32;not null;index"`
+	StartedAt   time.Time `gorm:"not null;index"`
+	FinishedAt  *time.Time
+	Results     string `gorm:"type:text"`
+}
+
+func init() {
+	register(Migration{
+		Version: 31,
+		Name:    "purge_runs",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&PurgeRun{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&PurgeRun{})
+		},
+	})
+}
@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CartLookupIndex is the schema of the index finding a user's open cart at
+// this version
+type CartLookupIndex struct {
+	UserID       uint `gorm:"not null;index:idx_carts_user_checked_out,priority:1"`
+	IsCheckedOut bool `gorm:"default:false;index:idx_carts_user_checked_out,priority:2"`
+}
+
+func (CartLookupIndex) TableName() string { return "carts" }
+
+// CartItemLookupIndex is the schema of the index finding an item in a cart
+// at this version
+type CartItemLookupIndex struct {
+	CartID uint `gorm:"not null;index:idx_cart_items_cart_item,priority:1"`
+	ItemID uint `gorm:"not null;index:idx_cart_items_cart_item,priority:2"`
+}
+
+func (CartItemLookupIndex) TableName() string { return "cart_items" }
+
+// OrderListIndexes is the schema of the indexes backing order histories
+// and the status filters of order listings and analytics at this version
+type OrderListIndexes struct {
+	UserID    uint      `gorm:"not null;index:idx_orders_user_created,priority:1"`
+	CreatedAt time.Time `gorm:"index:idx_orders_user_created,priority:2"`
+	Status    string    `gorm:"index:idx_orders_status"`
+}
+
+func (OrderListIndexes) TableName() string { return "orders" }
+
+// hotPathIndexes are the indexes created by this version, by model
+var hotPathIndexes = []struct {
+	model interface{}
+	name  string
+}{
+	{&CartLookupIndex{}, "idx_carts_user_checked_out"},
+	{&CartItemLookupIndex{}, "idx_cart_items_cart_item"},
+	{&OrderListIndexes{}, "idx_orders_user_created"},
+	{&OrderListIndexes{}, "idx_orders_status"},
+}
+
+func init() {
+	register(Migration{
+		Version: 32,
+		Name:    "hot_path_indexes",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, idx := range hotPathIndexes {
+				if err := m.CreateIndex(idx.model, idx.name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for i := len(hotPathIndexes) - 1; i >= 0; i-- {
+				if err := m.DropIndex(hotPathIndexes[i].model, hotPathIndexes[i].name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
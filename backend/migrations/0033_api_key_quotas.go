@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// APIKeyQuota is the schema of the quota columns of API keys at this
+// version
+type APIKeyQuota struct {
+	DailyQuota   *int
+	MonthlyQuota *int
+}
+
+func (APIKeyQuota) TableName() string { return "api_keys" }
+
+// APIKeyUsage is the schema of api_key_usages at this version
+type APIKeyUsage struct {
+	ID       uint   `gorm:"primarykey"`
+	APIKeyID uint   `gorm:"not null;uniqueIndex:idx_api_key_usages_day,priority:1"`
+	Day      string `gorm:"size:10;not null;uniqueIndex:idx_api_key_usages_day,priority:2"`
+	Requests int64  `gorm:"not null;default:0"`
+	Rejected int64  `gorm:"not null;default:0"`
+}
+
+func init() {
+	register(Migration{
+		Version: 33,
+		Name:    "api_key_quotas",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.AddColumn(&APIKeyQuota{}, "DailyQuota"); err != nil {
+				return err
+			}
+			if err := m.AddColumn(&APIKeyQuota{}, "MonthlyQuota"); err != nil {
+				return err
+			}
+			return m.CreateTable(&APIKeyUsage{})
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropTable(&APIKeyUsage{}); err != nil {
+				return err
+			}
+			if err := m.DropColumn(&APIKeyQuota{}, "MonthlyQuota"); err != nil {
+				return err
+			}
+			return m.DropColumn(&APIKeyQuota{}, "DailyQuota")
+		},
+	})
+}
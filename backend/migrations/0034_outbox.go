@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OutboxMessage is the schema of outbox_messages at this version
+type OutboxMessage struct {
+	ID            uint   `gorm:"primarykey"`
+	StoreID       uint   `gorm:"not null;default:1"`
+	EventID       string `gorm:"size:32;not null;index"`
+	Type          string `gorm:"size:64;not null"`
+	UserID        uint
+	Data          string    `gorm:"type:text;not null"`
+	OccurredAt    time.Time `gorm:"not null"`
+	APIKeyID      *uint
+	Attempts      int       `gorm:"not null;default:0"`
+	NextAttemptAt time.Time `gorm:"not null;index:idx_outbox_messages_due,priority:2"`
+	LastError     string
+	DeliveredAt   *time.Time `gorm:"index:idx_outbox_messages_due,priority:1"`
+	FailedAt      *time.Time
+	CreatedAt     time.Time
+}
+
+func init() {
+	register(Migration{
+		Version: 34,
+		Name:    "outbox",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&OutboxMessage{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&OutboxMessage{})
+		},
+	})
+}
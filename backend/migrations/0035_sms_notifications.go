@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserPhone is the schema of the phone and SMS columns of users at this
+// version
+type UserPhone struct {
+	Phone             string `gorm:"size:512;not null;default:''"`
+	PhoneVerifiedAt   *time.Time
+	PhoneCodeHash     string `gorm:"size:64;not null;default:''"`
+	PhoneCodeSentAt   *time.Time
+	PhoneCodeAttempts int  `gorm:"not null;default:0"`
+	SMSOptIn          bool `gorm:"column:sms_opt_in;not null;default:false"`
+}
+
+func (UserPhone) TableName() string { return "users" }
+
+// OutboxChannel is the schema of the channel column of outbox_messages at
+// this version
+type OutboxChannel struct {
+	Channel string `gorm:"size:16;not null;default:'bus'"`
+}
+
+func (OutboxChannel) TableName() string { return "outbox_messages" }
+
+var userPhoneColumns = []string{"Phone", "PhoneVerifiedAt", "PhoneCodeHash", "PhoneCodeSentAt", "PhoneCodeAttempts", "SMSOptIn"}
+
+func init() {
+	register(Migration{
+		Version: 35,
+		Name:    "sms_notifications",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range userPhoneColumns {
+				if err := m.AddColumn(&UserPhone{}, column); err != nil {
+					return err
+				}
+			}
+			if err := m.AddColumn(&OutboxChannel{}, "Channel"); err != nil {
+				return err
+			}
+			// Messages for webhooks were told apart by their API key
+			return tx.Exec("UPDATE outbox_messages SET channel = 'webhook' WHERE api_key_id IS NOT NULL").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropColumn(&OutboxChannel{}, "Channel"); err != nil {
+				return err
+			}
+			for i := len(userPhoneColumns) - 1; i >= 0; i-- {
+				if err := m.DropColumn(&UserPhone{}, userPhoneColumns[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DeviceToken is the schema of device_tokens at this version
+type DeviceToken struct {
+	ID         uint      `gorm:"primarykey"`
+	StoreID    uint      `gorm:"not null;default:1;uniqueIndex:idx_device_tokens_token,priority:1"`
+	UserID     uint      `gorm:"not null;index"`
+	Platform   string    `gorm:"size:16;not null"`
+	Token      string    `gorm:"size:255;not null;uniqueIndex:idx_device_tokens_token,priority:2"`
+	LastSeenAt time.Time `gorm:"not null;index"`
+	CreatedAt  time.Time
+}
+
+// OutboxDevice is the schema of the device_token_id column of
+// outbox_messages at this version
+type OutboxDevice struct {
+	DeviceTokenID *uint
+}
+
+func (OutboxDevice) TableName() string { return "outbox_messages" }
+
+func init() {
+	register(Migration{
+		Version: 36,
+		Name:    "device_tokens",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&DeviceToken{}); err != nil {
+				return err
+			}
+			return tx.Migrator().AddColumn(&OutboxDevice{}, "DeviceTokenID")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropColumn(&OutboxDevice{}, "DeviceTokenID"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&DeviceToken{})
+		},
+	})
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StockSubscription is the schema of stock_subscriptions at this version
+type StockSubscription struct {
+	ID        uint `gorm:"primarykey"`
+	StoreID   uint `gorm:"not null;default:1"`
+	UserID    uint `gorm:"not null;uniqueIndex:idx_stock_subscriptions_user_item,priority:1"`
+	ItemID    uint `gorm:"not null;uniqueIndex:idx_stock_subscriptions_user_item,priority:2;index"`
+	CreatedAt time.Time
+}
+
+func init() {
+	register(Migration{
+		Version: 37,
+		Name:    "stock_subscriptions",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&StockSubscription{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&StockSubscription{})
+		},
+	})
+}
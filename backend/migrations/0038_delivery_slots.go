@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// DeliverySlot is the schema of delivery_slots at this version
+type DeliverySlot struct {
+	gorm.Model
+	StoreID   uint   `gorm:"not null;default:1;index"`
+	StartTime string `gorm:"size:5;not null"`
+	EndTime   string `gorm:"size:5;not null"`
+	Capacity  int    `gorm:"not null"`
+}
+
+// DeliveryBooking is the schema of delivery_bookings at this version
+type DeliveryBooking struct {
+	ID      uint   `gorm:"primarykey"`
+	StoreID uint   `gorm:"not null;default:1"`
+	SlotID  uint   `gorm:"not null;uniqueIndex:idx_delivery_bookings_slot_date,priority:1"`
+	Date    string `gorm:"size:10;not null;uniqueIndex:idx_delivery_bookings_slot_date,priority:2"`
+	Booked  int    `gorm:"not null;default:0"`
+}
+
+// OrderDelivery is the schema of the delivery slot columns of orders at
+// this version
+type OrderDelivery struct {
+	DeliverySlotID *uint
+	DeliveryDate   string `gorm:"size:10;not null;default:'';index:idx_orders_delivery_date"`
+	DeliveryWindow string `gorm:"size:11;not null;default:''"`
+}
+
+func (OrderDelivery) TableName() string { return "orders" }
+
+var orderDeliveryColumns = []string{"DeliverySlotID", "DeliveryDate", "DeliveryWindow"}
+
+func init() {
+	register(Migration{
+		Version: 38,
+		Name:    "delivery_slots",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.CreateTable(&DeliverySlot{}, &DeliveryBooking{}); err != nil {
+				return err
+			}
+			for _, column := range orderDeliveryColumns {
+				if err := m.AddColumn(&OrderDelivery{}, column); err != nil {
+					return err
+				}
+			}
+			return m.CreateIndex(&OrderDelivery{}, "idx_orders_delivery_date")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&OrderDelivery{}, "idx_orders_delivery_date"); err != nil {
+				return err
+			}
+			for _, column := range orderDeliveryColumns {
+				if err := m.DropColumn(&OrderDelivery{}, column); err != nil {
+					return err
+				}
+			}
+			return m.DropTable(&DeliveryBooking{}, &DeliverySlot{})
+		},
+	})
+}
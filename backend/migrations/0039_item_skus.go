@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// ItemSKU is the schema of the sku column of items at this version
+type ItemSKU struct {
+	StoreID uint    `gorm:"not null;default:1;uniqueIndex:idx_items_store_sku,priority:1"`
+	SKU     *string `gorm:"size:64;uniqueIndex:idx_items_store_sku,priority:2"`
+}
+
+func (ItemSKU) TableName() string { return "items" }
+
+func init() {
+	register(Migration{
+		Version: 39,
+		Name:    "item_skus",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&ItemSKU{}, "SKU"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&ItemSKU{}, "idx_items_store_sku")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex(&ItemSKU{}, "idx_items_store_sku"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&ItemSKU{}, "SKU")
+		},
+	})
+}
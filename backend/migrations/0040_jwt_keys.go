@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JWTKey is the schema of jwt_keys at this version
+type JWTKey struct {
+	ID        uint      `gorm:"primarykey"`
+	KID       string    `gorm:"column:kid;size:64;uniqueIndex;not null"`
+	Secret    string    `gorm:"size:512;not null"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func init() {
+	register(Migration{
+		Version: 40,
+		Name:    "jwt_keys",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&JWTKey{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&JWTKey{})
+		},
+	})
+}
@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StoreDuplicateOrders is the schema of the duplicate order columns of
+// stores at this version
+type StoreDuplicateOrders struct {
+	DuplicateOrderPolicy  string `gorm:"size:16;not null;default:''"`
+	DuplicateOrderMinutes int    `gorm:"not null;default:0"`
+}
+
+func (StoreDuplicateOrders) TableName() string { return "stores" }
+
+// OrderReview is the schema of the duplicate review columns of orders at
+// this version
+type OrderReview struct {
+	DuplicateOfID *uint `gorm:"index:idx_orders_duplicate_of_id"`
+	ReviewedAt    *time.Time
+}
+
+func (OrderReview) TableName() string { return "orders" }
+
+var (
+	storeDuplicateOrderColumns = []string{"DuplicateOrderPolicy", "DuplicateOrderMinutes"}
+	orderReviewColumns         = []string{"DuplicateOfID", "ReviewedAt"}
+)
+
+func init() {
+	register(Migration{
+		Version: 41,
+		Name:    "duplicate_orders",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range storeDuplicateOrderColumns {
+				if err := m.AddColumn(&StoreDuplicateOrders{}, column); err != nil {
+					return err
+				}
+			}
+			for _, column := range orderReviewColumns {
+				if err := m.AddColumn(&OrderReview{}, column); err != nil {
+					return err
+				}
+			}
+			return m.CreateIndex(&OrderReview{}, "idx_orders_duplicate_of_id")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&OrderReview{}, "idx_orders_duplicate_of_id"); err != nil {
+				return err
+			}
+			for _, column := range orderReviewColumns {
+				if err := m.DropColumn(&OrderReview{}, column); err != nil {
+					return err
+				}
+			}
+			for _, column := range storeDuplicateOrderColumns {
+				if err := m.DropColumn(&StoreDuplicateOrders{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,33 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CartCheckoutStarted is the schema of the checkout_started_at column of
+// carts at this version
+type CartCheckoutStarted struct {
+	CheckoutStartedAt *time.Time
+}
+
+func (CartCheckoutStarted) TableName() string { return "carts" }
+
+func init() {
+	register(Migration{
+		Version: 42,
+		Name:    "checkout_started",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&CartCheckoutStarted{}, "CheckoutStartedAt"); err != nil {
+				return err
+			}
+			// Carts checked out before the column existed were started
+			// when they were checked out
+			return tx.Exec("UPDATE carts SET checkout_started_at = checked_out_at WHERE is_checked_out = ?", true).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&CartCheckoutStarted{}, "CheckoutStartedAt")
+		},
+	})
+}
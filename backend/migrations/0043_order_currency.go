@@ -0,0 +1,40 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// OrderCurrency is the schema of the settlement currency columns of orders
+// at this version
+type OrderCurrency struct {
+	Currency        string  `gorm:"size:3;not null;default:''"`
+	ExchangeRate    float64 `gorm:"not null;default:1"`
+	SettlementTotal float64 `gorm:"not null;default:0"`
+}
+
+func (OrderCurrency) TableName() string { return "orders" }
+
+var orderCurrencyColumns = []string{"Currency", "ExchangeRate", "SettlementTotal"}
+
+func init() {
+	register(Migration{
+		Version: 43,
+		Name:    "order_currency",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range orderCurrencyColumns {
+				if err := m.AddColumn(&OrderCurrency{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range orderCurrencyColumns {
+				if err := m.DropColumn(&OrderCurrency{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TermsAcceptance is the schema of terms_acceptances at this version
+type TermsAcceptance struct {
+	ID         uint      `gorm:"primarykey"`
+	StoreID    uint      `gorm:"not null;default:1;index"`
+	UserID     uint      `gorm:"not null;index"`
+	Document   string    `gorm:"size:16;not null"`
+	Version    string    `gorm:"size:64;not null"`
+	AcceptedAt time.Time `gorm:"not null"`
+	IP         string    `gorm:"size:45;not null;default:''"`
+	UserAgent  string    `gorm:"size:255;not null;default:''"`
+}
+
+func init() {
+	register(Migration{
+		Version: 44,
+		Name:    "terms_acceptances",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&TermsAcceptance{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&TermsAcceptance{})
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// ItemInternalFields is the schema of the admin-only columns of items at
+// this version
+type ItemInternalFields struct {
+	CostPrice     *float64
+	InternalNotes string `gorm:"not null;default:''"`
+}
+
+func (ItemInternalFields) TableName() string { return "items" }
+
+var itemInternalColumns = []string{"CostPrice", "InternalNotes"}
+
+func init() {
+	register(Migration{
+		Version: 45,
+		Name:    "item_internal_fields",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range itemInternalColumns {
+				if err := m.AddColumn(&ItemInternalFields{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range itemInternalColumns {
+				if err := m.DropColumn(&ItemInternalFields{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserTokenRevocation is the schema of user_token_revocations at this
+// version
+type UserTokenRevocation struct {
+	UserID        uint      `gorm:"primaryKey;autoIncrement:false"`
+	RevokedBefore time.Time `gorm:"not null"`
+	ExpiresAt     time.Time `gorm:"index;not null"`
+}
+
+// userTokensPrefix starts the revoked_tokens entries that stood for all of
+// a user's tokens
+const userTokensPrefix = "user:"
+
+func init() {
+	register(Migration{
+		Version: 46,
+		Name:    "user_token_revocations",
+		// Revoking all of a user's tokens used to add a "user:ID" entry to
+		// revoked_tokens, deleted again on reactivation, which made the
+		// tokens issued before valid again
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&UserTokenRevocation{}); err != nil {
+				return err
+			}
+			var entries []RevokedToken
+			if err := tx.Where("jti LIKE ?", userTokensPrefix+"%").Find(&entries).Error; err != nil {
+				return err
+			}
+			// The entries are of deactivated accounts, whose tokens were all
+			// issued before now
+			revokedBefore := time.Now().Truncate(time.Second).Add(time.Second)
+			for _, entry := range entries {
+				userID, err := strconv.ParseUint(strings.TrimPrefix(entry.JTI, userTokensPrefix), 10, 64)
+				if err != nil {
+					return fmt.Errorf("revoked token %q: %v", entry.JTI, err)
+				}
+				revocation := UserTokenRevocation{UserID: uint(userID), RevokedBefore: revokedBefore, ExpiresAt: entry.ExpiresAt}
+				if err := tx.Create(&revocation).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Where("jti LIKE ?", userTokensPrefix+"%").Delete(&RevokedToken{}).Error
+		},
+		// Going back revokes the tokens of reactivated accounts too, until
+		// they expire
+		Down: func(tx *gorm.DB) error {
+			var revocations []UserTokenRevocation
+			if err := tx.Find(&revocations).Error; err != nil {
+				return err
+			}
+			for _, revocation := range revocations {
+				entry := RevokedToken{JTI: fmt.Sprintf("%s%d", userTokensPrefix, revocation.UserID), ExpiresAt: revocation.ExpiresAt}
+				if err := tx.Create(&entry).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&UserTokenRevocation{})
+		},
+	})
+}
@@ -0,0 +1,46 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// CartItemSnapshot is the schema of the checkout snapshot columns of cart
+// items at this version
+type CartItemSnapshot struct {
+	Name      string  `gorm:"size:255;not null;default:''"`
+	UnitPrice float64 `gorm:"not null;default:0"`
+}
+
+func (CartItemSnapshot) TableName() string { return "cart_items" }
+
+var cartItemSnapshotColumns = []string{"Name", "UnitPrice"}
+
+func init() {
+	register(Migration{
+		Version: 47,
+		Name:    "cart_item_snapshots",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range cartItemSnapshotColumns {
+				if err := m.AddColumn(&CartItemSnapshot{}, column); err != nil {
+					return err
+				}
+			}
+			// Lines checked out before the columns existed take the item's
+			// name and price now, deleted items included, which is the
+			// closest record left of them
+			return tx.Exec(`UPDATE cart_items SET
+				name = (SELECT name FROM items WHERE items.id = cart_items.item_id),
+				unit_price = (SELECT price FROM items WHERE items.id = cart_items.item_id)
+				WHERE cart_id IN (SELECT id FROM carts WHERE is_checked_out = ?)
+				AND EXISTS (SELECT 1 FROM items WHERE items.id = cart_items.item_id)`, true).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range cartItemSnapshotColumns {
+				if err := m.DropColumn(&CartItemSnapshot{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
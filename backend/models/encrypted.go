@@ -0,0 +1,44 @@
+package models
+
+import (
+	"context"
+	"ecommerce-backend/encryption"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("encrypted", encryptedSerializer{})
+}
+
+// encryptedSerializer stores string fields tagged serializer:encrypted
+// encrypted with the configured PII key. Encrypted values are randomized,
+// so these columns can only be compared against the empty string.
+type encryptedSerializer struct{}
+
+func (encryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("encrypted column %s holds a %T", field.DBName, dbValue)
+	}
+
+	plaintext, err := encryption.Decrypt(value)
+	if err != nil {
+		return fmt.Errorf("column %s: %w", field.DBName, err)
+	}
+	field.ReflectValueOf(ctx, dst).SetString(plaintext)
+	return nil
+}
+
+func (encryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, _ := fieldValue.(string)
+	return encryption.Encrypt(plaintext)
+}
@@ -1,50 +1,1007 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
+	"math"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// User roles embedded in JWT claims
+const (
+	RoleCustomer = "customer"
+	RoleAdmin    = "admin"
+	// RoleVendor accounts manage their vendor's catalog and sub-orders
+	RoleVendor = "vendor"
+)
+
+// Address statuses, set by validating the address with the address
+// provider when it is saved and again at checkout
+const (
+	AddressDeliverable   = "deliverable"
+	AddressUndeliverable = "undeliverable"
+	// AddressUnverified addresses could not be checked because the
+	// provider failed
+	AddressUnverified = "unverified"
+)
+
+// Order statuses. Checkout creates completed orders; fulfilment moves them
+// on to shipped and delivered.
+const (
+	OrderPending   = "pending"
+	OrderCompleted = "completed"
+	OrderShipped   = "shipped"
+	OrderDelivered = "delivered"
+	OrderCancelled = "cancelled"
+)
+
+// Backorder modes, letting an item sell beyond its stock
+const (
+	// BackorderAllowed items are restocked later
+	BackorderAllowed = "backorder"
+	// BackorderPreorder items are not released yet
+	BackorderPreorder = "preorder"
+)
+
+// Store is a shop (tenant) hosted by the deployment, addressed by its slug
+// as a subdomain or in the X-Store header
+type Store struct {
+	gorm.Model
+	Slug string `gorm:"size:63;uniqueIndex;not null"`
+	Name string `gorm:"size:255;not null"`
+	// DuplicateOrderPolicy is what checkout does with likely duplicate
+	// orders, one of the config.DuplicateOrders constants, and
+	// DuplicateOrderMinutes how long after an order the same items are
+	// taken for a duplicate; empty and 0 use the configured ones
+	DuplicateOrderPolicy  string `gorm:"size:16;not null;default:''"`
+	DuplicateOrderMinutes int    `gorm:"not null;default:0"`
+}
+
+// Shipping rate kinds
+const (
+	// ShippingFlat charges Rate per order
+	ShippingFlat = "flat"
+	// ShippingFreeOver charges Rate per order, or nothing once the
+	// subtotal reaches FreeOver
+	ShippingFreeOver = "free_over"
+	// ShippingWeight charges Rate plus PerKg for each kilogram
+	ShippingWeight = "weight"
+)
+
 type User struct {
 	gorm.Model
-	Username     string `gorm:"uniqueIndex;not null"`
-	PasswordHash string `gorm:"not null"`
-	Token        string `gorm:"index"`
+	// StoreID is the store the user belongs to; usernames are unique
+	// within a store
+	StoreID      uint   `gorm:"not null;default:1;index;uniqueIndex:idx_users_store_username,priority:1"`
+	Username     string `gorm:"size:255;uniqueIndex:idx_users_store_username,priority:2;not null"`
+	PasswordHash string `gorm:"not null" json:"-"`
+	Role         string `gorm:"size:32;not null;default:'customer'"`
+	// Email receives reminders such as abandoned cart emails; optional.
+	// It is stored encrypted.
+	Email        string `gorm:"size:512;not null;default:'';serializer:encrypted"`
+	// VendorID is set for vendor accounts
+	VendorID     *uint  `gorm:"index"`
+	// AvatarURL is the public URL of the user's avatar image, if any
+	AvatarURL string `gorm:"size:512;not null;default:''"`
+	// DeactivatedAt is set while the user has deactivated their account.
+	// They can reactivate it within the reactivation window, after which
+	// it is anonymized and AnonymizedAt set.
+	DeactivatedAt *time.Time `gorm:"index"`
+	AnonymizedAt  *time.Time
+	// Phone is the user's mobile number in E.164 format, stored encrypted.
+	// Once verified it receives SMS alerts if the user opted in to them.
+	Phone           string `gorm:"size:512;not null;default:'';serializer:encrypted"`
+	PhoneVerifiedAt *time.Time
+	// PhoneCodeHash is the hash of the code last sent to verify Phone, at
+	// PhoneCodeSentAt; PhoneCodeAttempts counts the wrong codes entered
+	PhoneCodeHash     string     `gorm:"size:64;not null;default:''" json:"-"`
+	PhoneCodeSentAt   *time.Time `json:"-"`
+	PhoneCodeAttempts int        `gorm:"not null;default:0" json:"-"`
+	SMSOptIn          bool       `gorm:"column:sms_opt_in;not null;default:false"`
 	Carts        []Cart `gorm:"foreignKey:UserID"`
 	Orders       []Order `gorm:"foreignKey:UserID"`
 }
 
+// VendorIDOrZero returns the user's vendor ID, or 0 if they have none
+func (u User) VendorIDOrZero() uint {
+	if u.VendorID == nil {
+		return 0
+	}
+	return *u.VendorID
+}
+
 type Item struct {
 	gorm.Model
+	StoreID uint `gorm:"not null;default:1;index;uniqueIndex:idx_items_store_sku,priority:1"`
+	// SKU is the stock keeping unit external systems, such as an ERP,
+	// know the item by; unique within the store, or nil
+	SKU         *string `gorm:"size:64;uniqueIndex:idx_items_store_sku,priority:2"`
 	Name        string  `gorm:"not null"`
 	Description string
-	Price       float64 `gorm:"not null"`
+	Price       float64 `gorm:"not null;index"`
+	// CompareAtPrice is the reference price, such as the list price, shown
+	// struck through next to Price; it is greater than Price, or nil
+	CompareAtPrice *float64
+	// Stock is the number of units available, or nil if not tracked.
+	// Items held in warehouses have the sum of their WarehouseStock.
+	Stock *int `gorm:"index"`
+	// LowStockThreshold triggers an alert to admins when Stock falls to
+	// it; 0 disables alerts
+	LowStockThreshold int        `gorm:"not null;default:0" json:",omitempty" visible:"admin,vendor"`
+	LowStockAlertedAt *time.Time `json:"-"`
+	// VendorID is the marketplace vendor selling the item, or nil for
+	// items sold by the store itself
+	VendorID *uint `gorm:"index"`
+	// CostPrice is what a unit costs the store, and InternalNotes are for
+	// staff; only admins see them
+	CostPrice     *float64 `json:",omitempty" visible:"admin"`
+	InternalNotes string   `gorm:"not null;default:''" json:",omitempty" visible:"admin"`
+	// WeightKg is the shipping weight of one unit
+	WeightKg  float64    `gorm:"not null;default:0"`
+	// GiftCard items issue a gift card worth their price for each unit
+	// ordered
+	GiftCard bool `gorm:"not null;default:false"`
+	// Digital items are delivered as their DigitalFile through download
+	// links instead of being shipped
+	Digital bool `gorm:"not null;default:false"`
+	// Backorder, if set, lets the item sell beyond its stock; units
+	// beyond it are recorded on the order as backordered
+	Backorder string `gorm:"size:16;not null;default:''"`
+	// ExpectedAt is when backordered or pre-ordered units are expected
+	ExpectedAt *time.Time
+	// Category groups items for category-wide promotions
+	Category string `gorm:"size:64;not null;default:'';index"`
+	// MinQuantity is the fewest units of the item an order may hold, and
+	// MaxQuantity the most each customer may buy over all their orders
+	// that are not cancelled; 0 for no limit
+	MinQuantity int `gorm:"not null;default:0"`
+	MaxQuantity int `gorm:"not null;default:0"`
+	// IsActive lists the item in the catalog; inactive items are hidden
+	// from customers without being deleted
+	IsActive bool `gorm:"not null;default:true;index"`
+	// Version is bumped on every stock change and every update of the
+	// item, so concurrent updates can detect each other; it is the item's
+	// ETag on the update endpoints
+	Version   int        `gorm:"not null;default:0"`
 	CartItems   []CartItem `gorm:"foreignKey:ItemID"`
+	// Sale is the running sale the item is on, if any; it is filled in
+	// when items are listed rather than stored
+	Sale *ItemSale `gorm:"-" json:",omitempty"`
+}
+
+// DiscountPercent is how far Price is below CompareAtPrice, as a whole
+// percentage; 0 without a compare-at price
+func (i Item) DiscountPercent() int {
+	if i.CompareAtPrice == nil || *i.CompareAtPrice <= i.Price {
+		return 0
+	}
+	return int(math.Round((*i.CompareAtPrice - i.Price) / *i.CompareAtPrice * 100))
+}
+
+// MarshalJSON renders the item with its DiscountPercent
+func (i Item) MarshalJSON() ([]byte, error) {
+	type item Item
+	return json.Marshal(struct {
+		item
+		DiscountPercent int `json:",omitempty"`
+	}{item(i), i.DiscountPercent()})
 }
 
 type Cart struct {
 	gorm.Model
-	UserID     uint       `gorm:"not null"`
-	IsCheckedOut bool      `gorm:"default:false"`
+	StoreID    uint       `gorm:"not null;default:1;index"`
+	UserID     uint       `gorm:"not null;index:idx_carts_user_checked_out,priority:1"`
+	User       User       `gorm:"foreignKey:UserID"`
+	IsCheckedOut bool      `gorm:"default:false;index:idx_carts_user_checked_out,priority:2"`
 	CheckedOutAt *time.Time
+	// CheckoutStartedAt is when the owner first tried to check the cart
+	// out, whether or not the checkout went through
+	CheckoutStartedAt *time.Time
+	// Version is bumped whenever the cart or its items change
+	Version    int        `gorm:"not null;default:0"`
 	CartItems  []CartItem `gorm:"foreignKey:CartID"`
 	Order      *Order     `gorm:"foreignKey:CartID"`
 }
 
+// CartReminder records an abandoned cart reminder sent to the cart's
+// owner, so each cart is reminded about once
+type CartReminder struct {
+	gorm.Model
+	StoreID uint   `gorm:"not null;default:1;index"`
+	CartID  uint   `gorm:"uniqueIndex;not null"`
+	UserID  uint   `gorm:"index;not null"`
+	Email   string `gorm:"size:512;not null;serializer:encrypted"`
+	// GiftCardID is the coupon sent with the reminder, if any
+	GiftCardID *uint
+}
+
 type CartItem struct {
 	gorm.Model
-	CartID     uint   `gorm:"not null"`
-	ItemID     uint   `gorm:"not null"`
+	CartID     uint   `gorm:"not null;index:idx_cart_items_cart_item,priority:1"`
+	ItemID     uint   `gorm:"not null;index:idx_cart_items_cart_item,priority:2"`
 	Item       Item   `gorm:"foreignKey:ItemID"`
 	Quantity   int    `gorm:"default:1"`
+	Version    int    `gorm:"not null;default:0"`
+	// BundleID is set on the lines of a bundle added as a unit, whose
+	// quantities are the bundle's multiplied by the units added
+	BundleID *uint `gorm:"index"`
+	// Name and UnitPrice are the item's when the cart was checked out, as
+	// the item may be renamed, repriced or deleted since; empty while the
+	// cart is open
+	Name      string  `gorm:"size:255;not null;default:''"`
+	UnitPrice float64 `gorm:"not null;default:0"`
 }
 
 type Order struct {
 	gorm.Model
+	StoreID   uint      `gorm:"not null;default:1;index"`
+	// Number identifies the order to customers, such as ORD-2024-48213907,
+	// so the sequential ID is never shown to them; unique across stores
+	Number    string    `gorm:"size:32;uniqueIndex"`
+	// UserID is indexed with CreatedAt by migration 32; the index is not
+	// tagged here since CreatedAt comes from gorm.Model
 	UserID    uint      `gorm:"not null"`
+	User      User      `gorm:"foreignKey:UserID"`
 	CartID    uint      `gorm:"not null"`
 	Cart      Cart      `gorm:"foreignKey:CartID"`
+	// Total is the amount charged: the items less Discount, plus
+	// ShippingCost, less GiftCardAmount
 	Total     float64   `gorm:"not null"`
-	Status    string    `gorm:"default:'pending'"`
+	Status    string    `gorm:"default:'pending';index:idx_orders_status"`
+	// ShippingMethodID is the method chosen at checkout, if the store
+	// offered any
+	ShippingMethodID *uint
+	ShippingCost     float64 `gorm:"not null;default:0"`
+	// DeliverySlotID is the delivery slot chosen at checkout, if the store
+	// offered any, on DeliveryDate (YYYY-MM-DD); DeliveryWindow keeps its
+	// times of day, such as 09:00-12:00, as the slot may change later
+	DeliverySlotID *uint
+	DeliveryDate   string `gorm:"size:10;not null;default:'';index:idx_orders_delivery_date"`
+	DeliveryWindow string `gorm:"size:11;not null;default:''"`
+	// Discount is the amount taken off the items by Promotions and Sales,
+	// and by any price adjusters registered with the services
+	Discount   float64          `gorm:"not null;default:0"`
+	Promotions []OrderPromotion `gorm:"foreignKey:OrderID"`
+	// GiftCardAmount is the part of the order paid with a gift card
+	GiftCardAmount float64 `gorm:"not null;default:0"`
+	// Currency is the currency the customer settled the order in, and
+	// ExchangeRate how much of it one unit of the base currency bought at
+	// checkout; SettlementTotal is Total in Currency. Orders placed before
+	// currencies were recorded have none.
+	Currency        string  `gorm:"size:3;not null;default:''"`
+	ExchangeRate    float64 `gorm:"not null;default:1"`
+	SettlementTotal float64 `gorm:"not null;default:0"`
+	// GiftCards are the cards bought with the order
+	GiftCards []GiftCard `gorm:"foreignKey:OrderID"`
+	SubOrders []SubOrder `gorm:"foreignKey:OrderID"`
+	Shipments []Shipment `gorm:"foreignKey:OrderID"`
+	// Allocations are the warehouses the items are shipped from
+	Allocations []OrderAllocation `gorm:"foreignKey:OrderID"`
+	// ShippingAddress is a copy of the address chosen at checkout, if any
+	ShippingAddress PostalAddress `gorm:"embedded;embeddedPrefix:shipping_"`
+	// Sales are the units bought at a sale price, and Bundles the bundles
+	// bought as a unit; both are part of Discount
+	Sales   []SalePurchase `gorm:"foreignKey:OrderID"`
+	Bundles []OrderBundle  `gorm:"foreignKey:OrderID"`
+	// Downloads are the digital items bought with the order
+	Downloads []Download `gorm:"foreignKey:OrderID"`
+	// Backorders are the units bought beyond the items' stock
+	Backorders []OrderBackorder `gorm:"foreignKey:OrderID"`
+	// Metadata are the custom fields given at checkout, such as a gift
+	// message or a purchase order number, stored as a JSON object
+	Metadata map[string]string `gorm:"serializer:json;type:text"`
+	// DuplicateOfID is set on orders the store flagged as likely
+	// duplicates, naming the earlier order with the same items; they await
+	// an admin's review until ReviewedAt is set
+	DuplicateOfID *uint `gorm:"index:idx_orders_duplicate_of_id"`
+	ReviewedAt    *time.Time
+}
+
+// PostalAddress is where an order is shipped. The fields identifying the
+// recipient are stored encrypted; region and country are kept in the clear
+// for reporting.
+type PostalAddress struct {
+	Name       string `gorm:"size:512;not null;default:'';serializer:encrypted" json:"name"`
+	Line1      string `gorm:"size:512;not null;default:'';serializer:encrypted" json:"line1"`
+	Line2      string `gorm:"size:512;not null;default:'';serializer:encrypted" json:"line2,omitempty"`
+	City       string `gorm:"size:255;not null;default:'';serializer:encrypted" json:"city"`
+	Region     string `gorm:"size:100;not null;default:''" json:"region,omitempty"`
+	PostalCode string `gorm:"size:128;not null;default:'';serializer:encrypted" json:"postal_code"`
+	// Country is an ISO 3166-1 alpha-2 code
+	Country string `gorm:"size:2;not null;default:''" json:"country"`
+}
+
+// Address is a shipping address saved by a user, with the outcome of
+// validating it
+type Address struct {
+	gorm.Model
+	StoreID uint `gorm:"not null;default:1;index"`
+	UserID  uint `gorm:"index;not null"`
+	PostalAddress
+	Status string `gorm:"size:32;not null"`
+	// StatusReason explains why the address is undeliverable
+	StatusReason string `gorm:"size:255;not null;default:''"`
+}
+
+// Promotion kinds
+const (
+	// PromotionBuyXGetY makes GetQuantity of every BuyQuantity plus
+	// GetQuantity units of the item free
+	PromotionBuyXGetY = "buy_x_get_y"
+	// PromotionTier takes Percent off lines of at least MinQuantity units
+	// of the item
+	PromotionTier = "tier"
+	// PromotionPercentOff takes Percent off the item, or off every item in
+	// the category
+	PromotionPercentOff = "percent_off"
+)
+
+// Promotion is a discount rule applied automatically to carts while it
+// runs. Each cart line gets the single promotion, or Sale, taking the most
+// off it.
+type Promotion struct {
+	gorm.Model
+	StoreID uint   `gorm:"not null;default:1;index"`
+	Name    string `gorm:"size:255;not null"`
+	Kind    string `gorm:"size:32;not null"`
+	// ItemID is the item the promotion applies to, or nil for promotions
+	// applying to Category
+	ItemID      *uint   `gorm:"index"`
+	Category    string  `gorm:"size:64;not null;default:''"`
+	BuyQuantity int     `gorm:"not null;default:0"`
+	GetQuantity int     `gorm:"not null;default:0"`
+	MinQuantity int     `gorm:"not null;default:0"`
+	Percent     float64 `gorm:"not null;default:0"`
+	// StartsAt and EndsAt bound when the promotion runs; nil leaves that
+	// side open
+	StartsAt *time.Time
+	EndsAt   *time.Time
+}
+
+// Applies reports whether the promotion covers the item
+func (p Promotion) Applies(item Item) bool {
+	// Gift cards are worth their price whatever they were bought for
+	if item.GiftCard {
+		return false
+	}
+	if p.ItemID != nil {
+		return *p.ItemID == item.ID
+	}
+	return p.Category != "" && p.Category == item.Category
+}
+
+// Discount returns the amount the promotion takes off quantity units of
+// the item, rounded to cents
+func (p Promotion) Discount(item Item, quantity int) float64 {
+	if !p.Applies(item) {
+		return 0
+	}
+	var discount float64
+	switch p.Kind {
+	case PromotionBuyXGetY:
+		if group := p.BuyQuantity + p.GetQuantity; group > 0 {
+			discount = item.Price * float64(quantity/group*p.GetQuantity)
+		}
+	case PromotionTier:
+		if quantity >= p.MinQuantity {
+			discount = item.Price * float64(quantity) * p.Percent / 100
+		}
+	case PromotionPercentOff:
+		discount = item.Price * float64(quantity) * p.Percent / 100
+	}
+	return math.Round(discount*100) / 100
+}
+
+// OrderPromotion is a promotion applied to an order and the amount it took
+// off. Its name is copied so it outlives the promotion.
+type OrderPromotion struct {
+	gorm.Model
+	OrderID     uint    `gorm:"index;not null"`
+	PromotionID uint    `gorm:"not null"`
+	Name        string  `gorm:"size:255;not null"`
+	Amount      float64 `gorm:"not null"`
+}
+
+// Sale is a flash sale: a sale price for an item, or a percentage off
+// every item in a category, between StartsAt and EndsAt. The flash-sales
+// job sets Active when the window opens and clears it when it closes; only
+// active sales are applied.
+type Sale struct {
+	gorm.Model
+	StoreID uint   `gorm:"not null;default:1;index"`
+	Name    string `gorm:"size:255;not null"`
+	// ItemID is the item on sale, or nil for sales of Category
+	ItemID   *uint  `gorm:"index"`
+	Category string `gorm:"size:64;not null;default:''"`
+	// Price is the sale price of the item; sales of a category take
+	// Percent off instead
+	Price   *float64
+	Percent float64 `gorm:"not null;default:0"`
+	// PerUserLimit is how many units each customer may buy at the sale
+	// price, or 0 for no limit; further units cost the regular price
+	PerUserLimit int       `gorm:"not null;default:0"`
+	StartsAt     time.Time `gorm:"not null"`
+	EndsAt       time.Time `gorm:"not null"`
+	Active       bool      `gorm:"not null;default:false;index"`
+}
+
+// Applies reports whether the sale covers the item
+func (s Sale) Applies(item Item) bool {
+	// Gift cards are worth their price whatever they were bought for
+	if item.GiftCard {
+		return false
+	}
+	if s.ItemID != nil {
+		return *s.ItemID == item.ID
+	}
+	return s.Category != "" && s.Category == item.Category
+}
+
+// UnitPrice returns the item's price during the sale, rounded to cents and
+// never above its regular price
+func (s Sale) UnitPrice(item Item) float64 {
+	price := item.Price * (100 - s.Percent) / 100
+	if s.Price != nil {
+		price = *s.Price
+	}
+	return math.Min(math.Round(price*100)/100, item.Price)
+}
+
+// Running reports whether the sale's window contains the given time
+func (s Sale) Running(at time.Time) bool {
+	return !s.StartsAt.After(at) && s.EndsAt.After(at)
+}
+
+// ItemSale is the sale an item is on, as shown with the item
+type ItemSale struct {
+	SaleID       uint      `json:"sale_id"`
+	Name         string    `json:"name"`
+	Price        float64   `json:"price"`
+	EndsAt       time.Time `json:"ends_at"`
+	PerUserLimit int       `json:"per_user_limit,omitempty"`
+}
+
+// SalePurchase records units of an item an order bought at a sale price
+// and the amount taken off them, counting towards the sale's per-user
+// limit
+type SalePurchase struct {
+	gorm.Model
+	SaleID   uint    `gorm:"index;not null"`
+	OrderID  uint    `gorm:"index;not null"`
+	UserID   uint    `gorm:"index;not null"`
+	ItemID   uint    `gorm:"not null"`
+	Name     string  `gorm:"size:255;not null"`
+	Quantity int     `gorm:"not null"`
+	Amount   float64 `gorm:"not null"`
+}
+
+// Bundle is a set of items sold together for Price
+type Bundle struct {
+	gorm.Model
+	StoreID     uint   `gorm:"not null;default:1;index"`
+	Name        string `gorm:"size:255;not null"`
+	Description string
+	Price       float64      `gorm:"not null"`
+	Items       []BundleItem `gorm:"foreignKey:BundleID"`
+}
+
+// BundleItem is the quantity of an item in one unit of a bundle
+type BundleItem struct {
+	gorm.Model
+	BundleID uint `gorm:"index;not null"`
+	ItemID   uint `gorm:"not null"`
+	Item     Item `gorm:"foreignKey:ItemID"`
+	Quantity int  `gorm:"not null;default:1"`
+}
+
+// Value returns what one unit of the bundle's items costs separately
+func (b Bundle) Value() float64 {
+	var value float64
+	for _, bi := range b.Items {
+		value += bi.Item.Price * float64(bi.Quantity)
+	}
+	return math.Round(value*100) / 100
+}
+
+// Saving returns the amount a unit of the bundle takes off its items'
+// prices; bundles priced above their items save nothing
+func (b Bundle) Saving() float64 {
+	return math.Max(math.Round((b.Value()-b.Price)*100)/100, 0)
+}
+
+// OrderBundle is a bundle bought with an order, the units bought and the
+// amount its price took off them. Its name is copied so it outlives the
+// bundle.
+type OrderBundle struct {
+	gorm.Model
+	OrderID  uint    `gorm:"index;not null"`
+	BundleID uint    `gorm:"not null"`
+	Name     string  `gorm:"size:255;not null"`
+	Quantity int     `gorm:"not null"`
+	Amount   float64 `gorm:"not null"`
+}
+
+// DigitalFile is the file buyers of a digital item download, kept in
+// private storage under Key
+type DigitalFile struct {
+	gorm.Model
+	ItemID      uint   `gorm:"not null;uniqueIndex"`
+	Key         string `gorm:"size:255;not null"`
+	Name        string `gorm:"size:255;not null"`
+	ContentType string `gorm:"size:255;not null"`
+	Size        int64  `gorm:"not null"`
+}
+
+// Download lets the buyer of a digital item download its file. Downloads
+// are found by their signed link alone, so they are not scoped to a store.
+type Download struct {
+	gorm.Model
+	OrderID uint   `gorm:"index;not null"`
+	UserID  uint   `gorm:"index;not null"`
+	ItemID  uint   `gorm:"not null"`
+	Name    string `gorm:"size:255;not null"`
+	// Downloaded counts the downloads so far, of at most MaxDownloads; 0
+	// is unlimited
+	Downloaded   int `gorm:"not null;default:0"`
+	MaxDownloads int `gorm:"not null;default:0"`
+	// Issue is bumped when an admin re-issues the download, so links
+	// signed before stop working
+	Issue int `gorm:"not null;default:0"`
+}
+
+// Gift card ledger entry kinds
+const (
+	GiftCardIssue  = "issue"
+	GiftCardRedeem = "redeem"
+	GiftCardVoid   = "void"
+)
+
+// GiftCard is a stored-value card redeemable at checkout. Balance is the
+// sum of its ledger entries.
+type GiftCard struct {
+	gorm.Model
+	StoreID        uint    `gorm:"not null;default:1;index"`
+	Code           string  `gorm:"size:32;uniqueIndex;not null"`
+	InitialBalance float64 `gorm:"not null"`
+	Balance        float64 `gorm:"not null"`
+	// OrderID and UserID are the order that bought the card and its
+	// buyer, or nil for cards issued by admins. Coupons sent with cart
+	// reminders have only a UserID.
+	OrderID  *uint `gorm:"index"`
+	UserID   *uint `gorm:"index"`
+	VoidedAt *time.Time
+	Entries  []GiftCardEntry `gorm:"foreignKey:GiftCardID"`
+}
+
+// GiftCardEntry is a change to a gift card's balance
+type GiftCardEntry struct {
+	gorm.Model
+	GiftCardID uint   `gorm:"index;not null"`
+	Kind       string `gorm:"size:16;not null"`
+	// Amount is positive for credits and negative for debits
+	Amount float64 `gorm:"not null"`
+	// OrderID is the order that bought or redeemed the card
+	OrderID *uint
+}
+
+// Shipment tracking statuses, as reported by carrier providers
+const (
+	// TrackingPending is the status of shipments with no tracking events
+	TrackingPending        = "pending"
+	TrackingInfoReceived   = "info_received"
+	TrackingInTransit      = "in_transit"
+	TrackingOutForDelivery = "out_for_delivery"
+	TrackingDelivered      = "delivered"
+	TrackingException      = "exception"
+)
+
+// Shipment is a parcel of an order handed to a carrier. Its status is that
+// of its latest tracking event.
+type Shipment struct {
+	gorm.Model
+	StoreID        uint   `gorm:"not null;default:1;index"`
+	OrderID        uint   `gorm:"index;not null"`
+	Carrier        string `gorm:"size:50;not null;uniqueIndex:idx_shipments_tracking,priority:1"`
+	TrackingNumber string `gorm:"size:100;not null;uniqueIndex:idx_shipments_tracking,priority:2"`
+	Status         string `gorm:"size:32;not null;default:'pending'"`
+	DeliveredAt    *time.Time
+	// CheckedAt is when tracking was last fetched or received
+	CheckedAt *time.Time
+	Events    []TrackingEvent `gorm:"foreignKey:ShipmentID"`
+}
+
+// TrackingEvent is a status update of a shipment reported by its carrier
+type TrackingEvent struct {
+	gorm.Model
+	ShipmentID  uint      `gorm:"index;not null"`
+	Status      string    `gorm:"size:32;not null"`
+	Description string    `gorm:"size:255"`
+	Location    string    `gorm:"size:255"`
+	OccurredAt  time.Time `gorm:"not null"`
+}
+
+// ShippingMethod is a way a store ships orders, with its rate
+type ShippingMethod struct {
+	gorm.Model
+	StoreID uint   `gorm:"not null;default:1;index"`
+	Name    string `gorm:"size:255;not null"`
+	Kind    string `gorm:"size:32;not null"`
+	// Rate is the cost per order, or the base cost of weight-based methods
+	Rate     float64 `gorm:"not null;default:0"`
+	FreeOver float64 `gorm:"not null;default:0"`
+	PerKg    float64 `gorm:"not null;default:0"`
+}
+
+// Cost returns the cost of shipping an order with the given subtotal and
+// weight, rounded to cents
+func (m ShippingMethod) Cost(subtotal, weightKg float64) float64 {
+	cost := m.Rate
+	switch m.Kind {
+	case ShippingFreeOver:
+		if subtotal >= m.FreeOver {
+			cost = 0
+		}
+	case ShippingWeight:
+		cost += m.PerKg * weightKg
+	}
+	return math.Round(cost*100) / 100
+}
+
+// DeliverySlot is a window of the day customers can have their order
+// delivered in, taking up to Capacity orders on each day
+type DeliverySlot struct {
+	gorm.Model
+	StoreID uint `gorm:"not null;default:1;index"`
+	// StartTime and EndTime are times of day such as 09:00, in the
+	// configured delivery time zone
+	StartTime string `gorm:"size:5;not null"`
+	EndTime   string `gorm:"size:5;not null"`
+	Capacity  int    `gorm:"not null"`
+}
+
+// Window returns the slot's times of day, such as 09:00-12:00
+func (s DeliverySlot) Window() string {
+	return s.StartTime + "-" + s.EndTime
+}
+
+// DeliveryBooking counts the orders booked into a delivery slot on a date
+type DeliveryBooking struct {
+	ID      uint `gorm:"primarykey"`
+	StoreID uint `gorm:"not null;default:1"`
+	SlotID  uint `gorm:"not null;uniqueIndex:idx_delivery_bookings_slot_date,priority:1"`
+	// Date is the day of the delivery as YYYY-MM-DD
+	Date   string `gorm:"size:10;not null;uniqueIndex:idx_delivery_bookings_slot_date,priority:2"`
+	Booked int    `gorm:"not null;default:0"`
+}
+
+// Warehouse is a fulfillment center holding stock of the store's items
+type Warehouse struct {
+	gorm.Model
+	StoreID uint   `gorm:"not null;default:1;index"`
+	Name    string `gorm:"size:255;not null"`
+	// Priority orders warehouses for allocation at checkout, lowest first
+	Priority int `gorm:"not null;default:0"`
+}
+
+// WarehouseStock is the number of units of an item held at a warehouse
+type WarehouseStock struct {
+	gorm.Model
+	WarehouseID uint      `gorm:"not null;uniqueIndex:idx_warehouse_stocks_item,priority:1"`
+	Warehouse   Warehouse `gorm:"foreignKey:WarehouseID"`
+	ItemID      uint      `gorm:"not null;index;uniqueIndex:idx_warehouse_stocks_item,priority:2"`
+	Item        Item      `gorm:"foreignKey:ItemID"`
+	Quantity    int       `gorm:"not null;default:0"`
+}
+
+// StockTransfer records units of an item moved between warehouses
+type StockTransfer struct {
+	gorm.Model
+	StoreID         uint `gorm:"not null;default:1;index"`
+	ItemID          uint `gorm:"not null;index"`
+	FromWarehouseID uint `gorm:"not null"`
+	ToWarehouseID   uint `gorm:"not null"`
+	Quantity        int  `gorm:"not null"`
+	// UserID is the admin who moved the stock
+	UserID uint `gorm:"not null"`
+}
+
+// Reasons of inventory movements
+const (
+	// MovementOpening is the stock items held when the ledger was started
+	MovementOpening    = "opening"
+	MovementCheckout   = "checkout"
+	MovementBackorder  = "backorder_fulfilled"
+	MovementAdjustment = "adjustment"
+	MovementTransfer   = "transfer"
+)
+
+// InventoryMovement records a change of Delta units (negative when units
+// are taken) to an item's stock and why, so that stock can be audited for
+// shrinkage. The movements of a tracked item add up to its stock.
+type InventoryMovement struct {
+	gorm.Model
+	StoreID uint   `gorm:"not null;default:1;index"`
+	ItemID  uint   `gorm:"not null;index"`
+	Delta   int    `gorm:"not null"`
+	Reason  string `gorm:"size:32;not null"`
+	// ActorID is the user who changed the stock: the customer checking
+	// out or the admin or vendor adjusting it
+	ActorID *uint
+	// OrderID and WarehouseID are the order and warehouse the movement
+	// concerns, if any; transfers record a movement out of one warehouse
+	// and one into the other
+	OrderID     *uint
+	WarehouseID *uint
+}
+
+// FeatureFlag switches a feature on and off without redeploying. Enabled
+// flags are on for the users listed in UserIDs and for Percentage percent
+// of the other signed-in users; at 100 they are on for everyone, including
+// anonymous visitors.
+type FeatureFlag struct {
+	gorm.Model
+	StoreID     uint   `gorm:"not null;default:1;uniqueIndex:idx_feature_flags_store_name,priority:1"`
+	Name        string `gorm:"size:64;not null;uniqueIndex:idx_feature_flags_store_name,priority:2"`
+	Description string `gorm:"size:255;not null;default:''"`
+	Enabled     bool   `gorm:"not null;default:false"`
+	Percentage  int    `gorm:"not null;default:0"`
+	UserIDs     []uint `gorm:"serializer:json;type:text"`
+}
+
+// Purge run statuses and triggers
+const (
+	PurgeRunning   = "running"
+	PurgeSucceeded = "succeeded"
+	PurgeFailed    = "failed"
+
+	PurgeTriggerSchedule = "schedule"
+	PurgeTriggerAdmin    = "admin"
+)
+
+// PurgeRun records a run of the retention policies deleting expired data,
+// whether scheduled or triggered by an admin
+type PurgeRun struct {
+	gorm.Model
+	Trigger string `gorm:"size:32;not null"`
+	// TriggeredBy is the admin who triggered the run, if any
+	TriggeredBy *uint
+	Status      string    `gorm:"size:32;not null;index"`
+	StartedAt   time.Time `gorm:"not null;index"`
+	FinishedAt  *time.Time
+	Results     []PurgeResult `gorm:"serializer:json;type:text"`
+}
+
+// PurgeResult is the outcome of one retention policy in a purge run
+type PurgeResult struct {
+	Policy string
+	Purged int64
+	Error  string
+}
+
+// OrderAllocation is the number of units of an order's item taken from a
+// warehouse at checkout
+type OrderAllocation struct {
+	gorm.Model
+	OrderID     uint `gorm:"index;not null"`
+	ItemID      uint `gorm:"not null"`
+	WarehouseID uint `gorm:"not null"`
+	Quantity    int  `gorm:"not null"`
+}
+
+// OrderBackorder is the part of an order's item bought beyond its stock,
+// fulfilled once the item is restocked or released
+type OrderBackorder struct {
+	gorm.Model
+	StoreID uint   `gorm:"not null;default:1;index"`
+	OrderID uint   `gorm:"index;not null"`
+	ItemID  uint   `gorm:"index;not null"`
+	Name    string `gorm:"size:255;not null"`
+	// Kind is the item's backorder mode at checkout
+	Kind       string `gorm:"size:16;not null"`
+	Quantity   int    `gorm:"not null"`
+	ExpectedAt *time.Time
+	// FulfilledAt is when the units were taken from stock, or nil while
+	// they are outstanding
+	FulfilledAt *time.Time `gorm:"index"`
+}
+
+// Vendor is a marketplace seller
+type Vendor struct {
+	gorm.Model
+	StoreID uint   `gorm:"not null;default:1;index;uniqueIndex:idx_vendors_store_name,priority:1"`
+	Name    string `gorm:"size:255;uniqueIndex:idx_vendors_store_name,priority:2;not null"`
+}
+
+// SubOrder is the part of an order sold by one vendor, fulfilled by that
+// vendor. Its lines are the order's cart items whose item has the vendor.
+type SubOrder struct {
+	gorm.Model
+	OrderID  uint    `gorm:"index;not null"`
+	Order    Order   `gorm:"foreignKey:OrderID"`
+	VendorID uint    `gorm:"index;not null"`
+	Total    float64 `gorm:"not null"`
+	Status   string  `gorm:"size:32;not null"`
+}
+
+type APIKey struct {
+	gorm.Model
+	StoreID    uint   `gorm:"not null;default:1;index"`
+	Name       string `gorm:"not null"`
+	Prefix     string `gorm:"not null"`
+	KeyHash    string `gorm:"size:64;uniqueIndex;not null"`
+	UserID     uint   `gorm:"not null"`
+	Sandbox    bool   `gorm:"default:true"`
+	WebhookURL string
+	// WebhookSecret signs the webhooks delivered to WebhookURL
+	WebhookSecret string `gorm:"size:64;not null;default:''" json:"-"`
+	LastUsedAt    *time.Time
+	// DailyQuota and MonthlyQuota bound the requests made with the key per
+	// UTC day and month; nil for the configured defaults, 0 for no limit
+	DailyQuota   *int
+	MonthlyQuota *int
+}
+
+// APIKeyUsage counts the requests made with an API key on a UTC day,
+// Rejected being those refused for exceeding a quota
+type APIKeyUsage struct {
+	ID       uint   `gorm:"primarykey"`
+	APIKeyID uint   `gorm:"not null;uniqueIndex:idx_api_key_usages_day,priority:1"`
+	Day      string `gorm:"size:10;not null;uniqueIndex:idx_api_key_usages_day,priority:2"`
+	Requests int64  `gorm:"not null;default:0"`
+	Rejected int64  `gorm:"not null;default:0"`
+}
+
+// Outbox channels
+const (
+	OutboxBus     = "bus"
+	OutboxWebhook = "webhook"
+	OutboxSMS     = "sms"
+	OutboxPush    = "push"
+)
+
+// OutboxMessage is a domain event waiting to be relayed over its channel:
+// to the event bus, to the webhook endpoint of the API key APIKeyID, by
+// SMS to the user UserID, or as a push notification to the device
+// DeviceTokenID. Events are written in the transaction of the
+// change they describe, so they are neither lost when the process stops
+// after a commit nor sent for changes that were rolled back.
+type OutboxMessage struct {
+	ID         uint   `gorm:"primarykey"`
+	StoreID    uint   `gorm:"not null;default:1"`
+	EventID    string `gorm:"size:32;not null;index"`
+	Type       string `gorm:"size:64;not null"`
+	UserID     uint
+	Data       string    `gorm:"type:text;not null"`
+	OccurredAt time.Time `gorm:"not null"`
+	// Channel is OutboxBus for events as written; relaying them adds a
+	// message for each webhook, SMS alert and push notification the event
+	// is sent as
+	Channel       string `gorm:"size:16;not null;default:'bus'"`
+	APIKeyID      *uint
+	DeviceTokenID *uint
+	Attempts      int `gorm:"not null;default:0"`
+	// NextAttemptAt is when the message is next due; it is pushed back
+	// while a relay holds it and after failed attempts
+	NextAttemptAt time.Time `gorm:"not null;index:idx_outbox_messages_due,priority:2"`
+	LastError     string
+	// DeliveredAt is set once the message is relayed and FailedAt once it
+	// is given up on; the relay skips messages with either
+	DeliveredAt *time.Time `gorm:"index:idx_outbox_messages_due,priority:1"`
+	FailedAt    *time.Time
+	CreatedAt   time.Time
+}
+
+// StockSubscription asks for the user to be told when the out-of-stock
+// item is back in stock. It is deleted once they are, or once the item is.
+type StockSubscription struct {
+	ID        uint      `gorm:"primarykey"`
+	StoreID   uint      `gorm:"not null;default:1"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_stock_subscriptions_user_item,priority:1"`
+	ItemID    uint      `gorm:"not null;uniqueIndex:idx_stock_subscriptions_user_item,priority:2;index"`
+	CreatedAt time.Time
+	Item      Item `gorm:"foreignKey:ItemID"`
+	User      User `gorm:"foreignKey:UserID"`
+}
+
+// Device platforms, which the push provider depends on
+const (
+	// DeviceAndroid devices are reached through Firebase Cloud Messaging
+	DeviceAndroid = "android"
+	// DeviceIOS devices are reached through the Apple Push Notification
+	// service
+	DeviceIOS = "ios"
+)
+
+// DeviceToken is the token a mobile app registered to receive push
+// notifications for its user. Apps register it again whenever they start,
+// so tokens not seen for long belong to uninstalled apps.
+type DeviceToken struct {
+	ID         uint      `gorm:"primarykey"`
+	StoreID    uint      `gorm:"not null;default:1;uniqueIndex:idx_device_tokens_token,priority:1"`
+	UserID     uint      `gorm:"not null;index"`
+	Platform   string    `gorm:"size:16;not null"`
+	Token      string    `gorm:"size:255;not null;uniqueIndex:idx_device_tokens_token,priority:2"`
+	LastSeenAt time.Time `gorm:"not null;index"`
+	CreatedAt  time.Time
+}
+
+// Documents users accept, by TermsAcceptance.Document
+const (
+	DocumentTerms   = "terms"
+	DocumentPrivacy = "privacy"
+)
+
+// TermsAcceptance records that a user accepted a version of the terms of
+// service or privacy policy. Earlier acceptances are kept when the user
+// accepts a newer version, for compliance.
+type TermsAcceptance struct {
+	ID         uint      `gorm:"primarykey"`
+	StoreID    uint      `gorm:"not null;default:1;index"`
+	UserID     uint      `gorm:"not null;index"`
+	Document   string    `gorm:"size:16;not null"`
+	Version    string    `gorm:"size:64;not null"`
+	AcceptedAt time.Time `gorm:"not null"`
+	// IP and UserAgent are of the request accepting; the IP is erased when
+	// the user is anonymized
+	IP        string `gorm:"size:45;not null;default:''"`
+	UserAgent string `gorm:"size:255;not null;default:''"`
+}
+
+// RevokedToken records a logged-out JWT (by its jti) until it would have
+// expired anyway
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+// UserTokenRevocation revokes every token issued to a user before
+// RevokedBefore, such as when they deactivate their account. It is kept
+// until those tokens have all expired, at ExpiresAt, even if the account is
+// reactivated, so tokens leaked before are never valid again.
+type UserTokenRevocation struct {
+	UserID        uint      `gorm:"primaryKey;autoIncrement:false"`
+	RevokedBefore time.Time `gorm:"not null"`
+	ExpiresAt     time.Time `gorm:"index;not null"`
+}
+
+// JWTKey is a key signing tokens, made by rotating the keys at runtime.
+// The newest signs new tokens, while older ones verify the tokens they
+// signed until those expire. The secret is stored encrypted.
+type JWTKey struct {
+	ID        uint      `gorm:"primarykey"`
+	KID       string    `gorm:"column:kid;size:64;uniqueIndex;not null"`
+	Secret    string    `gorm:"size:512;not null;serializer:encrypted" json:"-"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// AuditLog is an append-only record of a request to a sensitive route.
+// Bodies are stored with secrets and card data redacted.
+type AuditLog struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	StoreID   uint      `gorm:"not null;default:1;index"`
+	Method    string    `gorm:"not null"`
+	Route     string    `gorm:"size:255;index;not null"`
+	Path      string    `gorm:"not null"`
+	Status    int
+	UserID    *uint `gorm:"index"`
+	// ImpersonatorID is the admin who made the request with a token
+	// acting as the user
+	ImpersonatorID *uint `gorm:"index"`
+	ClientIP       string
+	LatencyMs      int64
+	RequestBody    string `gorm:"type:text"`
+	ResponseBody   string `gorm:"type:text"`
+}
+
+// BeforeUpdate prevents audit records from being modified
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return errors.New("audit logs are append-only")
+}
+
+// BeforeDelete prevents audit records from being deleted outside retention purges
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return errors.New("audit logs are append-only")
 }
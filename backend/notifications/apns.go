@@ -0,0 +1,128 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"ecommerce-backend/config"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// apnsTokenTTL is how long a provider token is used for; APNs refuses
+// tokens older than an hour and ones renewed more often than every 20
+// minutes
+const apnsTokenTTL = 40 * time.Minute
+
+// apnsSender sends notifications to iOS devices through the Apple Push
+// Notification service, authenticated with a token signed by the team's key
+type apnsSender struct {
+	cfg      config.PushConfig
+	key      *ecdsa.PrivateKey
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+// newAPNsSender loads the signing key of APNS_KEY_FILE
+func newAPNsSender(cfg config.PushConfig) (*apnsSender, error) {
+	data, err := os.ReadFile(cfg.APNsKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %v", err)
+	}
+	return &apnsSender{cfg: cfg, key: key}, nil
+}
+
+func (s *apnsSender) SendPush(ctx context.Context, msg Push) error {
+	token, err := s.providerToken()
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{"title": msg.Title, "body": msg.Body},
+			"sound": "default",
+		},
+	}
+	for k, v := range msg.Data {
+		if k != "aps" {
+			payload[k] = v
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	endpoint := strings.TrimRight(s.cfg.APNsAPIURL, "/") + "/3/device/" + url.PathEscape(msg.Token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating apns request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("apns-topic", s.cfg.APNsTopic)
+	req.Header.Set("apns-push-type", "alert")
+
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending apns notification: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var failure struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure)
+	switch failure.Reason {
+	case "Unregistered", "BadDeviceToken", "DeviceTokenNotForTopic":
+		return ErrUnregistered
+	case "ExpiredProviderToken":
+		s.mu.Lock()
+		s.token = ""
+		s.mu.Unlock()
+	}
+	if resp.StatusCode == http.StatusGone {
+		return ErrUnregistered
+	}
+	return fmt.Errorf("apns responded with status %d: %s", resp.StatusCode, failure.Reason)
+}
+
+// providerToken returns the signed token authenticating requests, renewing
+// it every apnsTokenTTL
+func (s *apnsSender) providerToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Since(s.issuedAt) < apnsTokenTTL {
+		return s.token, nil
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": s.cfg.APNsTeamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = s.cfg.APNsKeyID
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		return "", fmt.Errorf("error signing apns token: %v", err)
+	}
+	s.token, s.issuedAt = signed, now
+	return s.token, nil
+}
@@ -0,0 +1,175 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"ecommerce-backend/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fcmScope is the OAuth scope of access tokens allowed to send messages
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// fcmSender sends notifications to Android devices through the Firebase
+// Cloud Messaging HTTP v1 API, authenticated as a service account
+type fcmSender struct {
+	cfg        config.PushConfig
+	email      string
+	key        *rsa.PrivateKey
+	tokenURL   string
+	mu         sync.Mutex
+	token      string
+	tokenUntil time.Time
+}
+
+// newFCMSender loads the service account key of FCM_CREDENTIALS_FILE
+func newFCMSender(cfg config.PushConfig) (*fcmSender, error) {
+	data, err := os.ReadFile(cfg.FCMCredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	var creds struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("invalid credentials file: %v", err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(creds.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &fcmSender{cfg: cfg, email: creds.ClientEmail, key: key, tokenURL: creds.TokenURI}, nil
+}
+
+func (s *fcmSender) SendPush(ctx context.Context, msg Push) error {
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"message": map[string]interface{}{
+		"token":        msg.Token,
+		"notification": map[string]string{"title": msg.Title, "body": msg.Body},
+		"data":         msg.Data,
+	}})
+	if err != nil {
+		return err
+	}
+	endpoint := strings.TrimRight(s.cfg.FCMAPIURL, "/") + "/v1/projects/" + url.PathEscape(s.cfg.FCMProjectID) + "/messages:send"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating fcm request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending fcm message: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	var failure struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&failure)
+	for _, detail := range failure.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" || detail.ErrorCode == "SENDER_ID_MISMATCH" {
+			return ErrUnregistered
+		}
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return ErrUnregistered
+	case http.StatusUnauthorized:
+		s.mu.Lock()
+		s.token = ""
+		s.mu.Unlock()
+	case http.StatusBadRequest:
+		// Malformed tokens are the only invalid part of the messages sent
+		if failure.Error.Status == "INVALID_ARGUMENT" {
+			return ErrUnregistered
+		}
+	}
+	return fmt.Errorf("fcm responded with status %d: %s", resp.StatusCode, failure.Error.Message)
+}
+
+// accessToken returns an OAuth access token of the service account,
+// exchanging a signed assertion for a new one shortly before it expires
+func (s *fcmSender) accessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.tokenUntil) {
+		return s.token, nil
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   s.email,
+		"scope": fcmScope,
+		"aud":   s.tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(s.key)
+	if err != nil {
+		return "", fmt.Errorf("error signing fcm assertion: %v", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("error creating fcm token request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error fetching fcm access token: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fcm token endpoint responded with status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid fcm token response: %v", err)
+	}
+	if result.AccessToken == "" {
+		return "", errors.New("fcm token endpoint returned no access token")
+	}
+	s.token = result.AccessToken
+	s.tokenUntil = now.Add(time.Duration(result.ExpiresIn)*time.Second - time.Minute)
+	return s.token, nil
+}
@@ -0,0 +1,222 @@
+// Package notifications delivers messages to people outside the API, such
+// as alerts to admins. Messages are emailed when SMTP is configured, text
+// messages sent when an SMS provider is and push notifications when FCM or
+// APNs are, and logged otherwise, so development setups need none of them.
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"ecommerce-backend/config"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/resilience"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a notification with a plain-text body and, for emails
+// rendered from templates, an HTML alternative
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+	HTML    string
+}
+
+// Sender delivers messages over one channel
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var (
+	mu          sync.RWMutex
+	sender      Sender = logSender{}
+	adminEmails []string
+)
+
+// Init selects the senders from the SMTP and SMS configuration and records
+// the addresses that receive admin alerts
+func Init(smtpCfg config.SMTPConfig, smsCfg config.SMSConfig, cfg config.NotificationsConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	sender = logSender{}
+	if smtpCfg.Host != "" {
+		sender = smtpSender{cfg: smtpCfg}
+	}
+	smsSender = logSender{}
+	if smsCfg.AccountSID != "" {
+		smsSender = twilioSender{cfg: smsCfg}
+	}
+	adminEmails = cfg.AdminEmails
+}
+
+// SetSender replaces the sender; mainly useful for tests
+func SetSender(s Sender) {
+	mu.Lock()
+	defer mu.Unlock()
+	sender = s
+}
+
+// Send delivers msg with the configured sender
+func Send(ctx context.Context, msg Message) error {
+	mu.RLock()
+	s := sender
+	mu.RUnlock()
+	return s.Send(ctx, msg)
+}
+
+// NotifyAdmins sends msg to the configured admin addresses. With no
+// addresses configured the message is only logged.
+func NotifyAdmins(ctx context.Context, msg Message) error {
+	mu.RLock()
+	msg.To = adminEmails
+	mu.RUnlock()
+
+	if len(msg.To) == 0 {
+		return logSender{}.Send(ctx, msg)
+	}
+	return Send(ctx, msg)
+}
+
+// logSender logs messages instead of delivering them
+type logSender struct{}
+
+func (logSender) Send(ctx context.Context, msg Message) error {
+	logging.FromContext(ctx).Info("notification", "to", strings.Join(msg.To, ","), "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// smtpTimeout bounds connecting to and talking with the mail server
+const smtpTimeout = 30 * time.Second
+
+// smtpSender emails messages through an SMTP server, using STARTTLS when
+// the server offers it
+type smtpSender struct {
+	cfg config.SMTPConfig
+}
+
+// Send emails msg, retrying when the server cannot be reached or turns it
+// away for now (4xx replies); a server failing every attempt opens the
+// smtp circuit breaker
+func (s smtpSender) Send(ctx context.Context, msg Message) error {
+	if len(msg.To) == 0 {
+		return nil
+	}
+	return resilience.Do(ctx, "smtp", smtpTimeout, func(ctx context.Context) error {
+		return s.send(ctx, msg)
+	})
+}
+
+func (s smtpSender) send(ctx context.Context, msg Message) error {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	dialer := net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return smtpError(err)
+	}
+	// Bounded by smtpTimeout through the attempt's context
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return smtpError(err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return smtpError(err)
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return smtpError(err)
+		}
+	}
+
+	if err := client.Mail(s.cfg.From); err != nil {
+		return smtpError(err)
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return smtpError(err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return smtpError(err)
+	}
+	if _, err := w.Write(formatEmail(s.cfg.From, msg)); err != nil {
+		return smtpError(err)
+	}
+	if err := w.Close(); err != nil {
+		return smtpError(err)
+	}
+	// The message is accepted once its data is; a failed QUIT must not have
+	// it sent again
+	if err := client.Quit(); err != nil {
+		return resilience.Permanent(smtpError(err))
+	}
+	return nil
+}
+
+// smtpError wraps an SMTP error, marking permanent failures (5xx replies)
+// so they are not retried
+func smtpError(err error) error {
+	err = fmt.Errorf("smtp: %w", err)
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return resilience.Permanent(err)
+	}
+	return err
+}
+
+// formatEmail renders msg as an RFC 5322 email: plain text, or
+// multipart/alternative with the HTML body when it has one
+func formatEmail(from string, msg Message) []byte {
+	var b bytes.Buffer
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + strings.Join(msg.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if msg.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		b.WriteString("\r\n")
+		b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+		return b.Bytes()
+	}
+
+	parts := multipart.NewWriter(&b)
+	b.WriteString("Content-Type: multipart/alternative; boundary=" + parts.Boundary() + "\r\n")
+	b.WriteString("\r\n")
+	// Clients show the last part they can display, so HTML goes last
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Body},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, _ := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		qp := quotedprintable.NewWriter(w)
+		qp.Write([]byte(part.body))
+		qp.Close()
+	}
+	parts.Close()
+	return b.Bytes()
+}
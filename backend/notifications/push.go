@@ -0,0 +1,108 @@
+package notifications
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/resilience"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// pushTimeout bounds each request to a push provider
+const pushTimeout = 10 * time.Second
+
+// ErrUnregistered is returned for device tokens the push provider no longer
+// accepts, typically because the app was uninstalled; they should be
+// forgotten
+var ErrUnregistered = errors.New("device token is no longer registered")
+
+// Push is a notification to a mobile app on the device holding Token
+type Push struct {
+	// Platform is models.DeviceAndroid or models.DeviceIOS
+	Platform string
+	Token    string
+	Title    string
+	Body     string
+	// Data is passed to the app along with the notification
+	Data map[string]string
+}
+
+// PushSender delivers push notifications
+type PushSender interface {
+	SendPush(ctx context.Context, msg Push) error
+}
+
+var pushSender PushSender = logSender{}
+
+// InitPush selects the push providers from the configuration, loading
+// their keys. Platforms without a provider get their notifications logged.
+func InitPush(cfg config.PushConfig) error {
+	router := pushRouter{android: logSender{}, ios: logSender{}}
+	if cfg.FCMProjectID != "" {
+		fcm, err := newFCMSender(cfg)
+		if err != nil {
+			return fmt.Errorf("fcm: %w", err)
+		}
+		router.android = fcm
+	}
+	if cfg.APNsKeyFile != "" {
+		apns, err := newAPNsSender(cfg)
+		if err != nil {
+			return fmt.Errorf("apns: %w", err)
+		}
+		router.ios = apns
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	pushSender = router
+	return nil
+}
+
+// SetPushSender replaces the push sender; mainly useful for tests
+func SetPushSender(s PushSender) {
+	mu.Lock()
+	defer mu.Unlock()
+	pushSender = s
+}
+
+// SendPush delivers msg with the configured push sender
+func SendPush(ctx context.Context, msg Push) error {
+	mu.RLock()
+	s := pushSender
+	mu.RUnlock()
+	return s.SendPush(ctx, msg)
+}
+
+func (logSender) SendPush(ctx context.Context, msg Push) error {
+	logging.FromContext(ctx).Info("push", "platform", msg.Platform, "title", msg.Title, "body", msg.Body, "data", msg.Data)
+	return nil
+}
+
+// pushRouter hands notifications to the provider of their platform
+type pushRouter struct {
+	android PushSender
+	ios     PushSender
+}
+
+func (r pushRouter) SendPush(ctx context.Context, msg Push) error {
+	switch msg.Platform {
+	case models.DeviceAndroid:
+		return r.android.SendPush(ctx, msg)
+	case models.DeviceIOS:
+		return r.ios.SendPush(ctx, msg)
+	}
+	return fmt.Errorf("unknown device platform %q", msg.Platform)
+}
+
+// pushClient propagates the trace context of the caller to the push
+// providers, retrying failed requests and failing fast while one is down
+var pushClient = &http.Client{
+	Transport: resilience.Transport("push", pushTimeout, otelhttp.NewTransport(http.DefaultTransport)),
+}
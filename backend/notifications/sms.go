@@ -0,0 +1,86 @@
+package notifications
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/resilience"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// smsTimeout bounds each request to the SMS provider
+const smsTimeout = 10 * time.Second
+
+// SMS is a text message to a phone number in E.164 format
+type SMS struct {
+	To   string
+	Body string
+}
+
+// SMSSender delivers text messages
+type SMSSender interface {
+	SendSMS(ctx context.Context, msg SMS) error
+}
+
+var smsSender SMSSender = logSender{}
+
+// SetSMSSender replaces the SMS sender; mainly useful for tests
+func SetSMSSender(s SMSSender) {
+	mu.Lock()
+	defer mu.Unlock()
+	smsSender = s
+}
+
+// SendSMS delivers msg with the configured SMS sender
+func SendSMS(ctx context.Context, msg SMS) error {
+	mu.RLock()
+	s := smsSender
+	mu.RUnlock()
+	return s.SendSMS(ctx, msg)
+}
+
+func (logSender) SendSMS(ctx context.Context, msg SMS) error {
+	logging.FromContext(ctx).Info("sms", "to", msg.To, "body", msg.Body)
+	return nil
+}
+
+// smsClient propagates the trace context of the caller to the SMS
+// provider, retrying failed requests and failing fast while it is down
+var smsClient = &http.Client{
+	Transport: resilience.Transport("sms", smsTimeout, otelhttp.NewTransport(http.DefaultTransport)),
+}
+
+// twilioSender sends text messages through the Twilio Messages API, or a
+// provider offering the same API
+type twilioSender struct {
+	cfg config.SMSConfig
+}
+
+func (s twilioSender) SendSMS(ctx context.Context, msg SMS) error {
+	endpoint := strings.TrimRight(s.cfg.APIURL, "/") + "/2010-04-01/Accounts/" +
+		url.PathEscape(s.cfg.AccountSID) + "/Messages.json"
+	form := url.Values{"To": {msg.To}, "From": {s.cfg.From}, "Body": {msg.Body}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("error creating sms request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.cfg.AccountSID, s.cfg.AuthToken)
+
+	resp, err := smsClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending sms: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sms provider responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package pagination
+
+import (
+	"ecommerce-backend/apperrors"
+	"time"
+)
+
+// ChangeCursor is a place in a list ordered oldest change first by update
+// time and then by ID, such as the changes an external system pulls. Rows
+// after it were changed later, or at the same time with a greater ID, so
+// a cursor with ID 0 starts at the rows changed at At.
+type ChangeCursor struct {
+	At time.Time
+	ID uint
+}
+
+// String encodes the cursor for clients to send back
+func (c ChangeCursor) String() string {
+	return encodeKeysetCursor(keysetKey(c))
+}
+
+// ParseChangeCursor decodes a cursor encoded by String
+func ParseChangeCursor(value string) (ChangeCursor, error) {
+	key, err := decodeKeysetCursor(value)
+	if err != nil {
+		return ChangeCursor{}, apperrors.Validation("invalid cursor")
+	}
+	return ChangeCursor(key), nil
+}
@@ -0,0 +1,192 @@
+package pagination
+
+import (
+	"ecommerce-backend/apperrors"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Keyset is a window over a list ordered newest first by creation time and
+// then by ID. Its cursors hold both values of a row, so pages stay stable
+// while rows are inserted and can be walked either way: after a cursor
+// towards older rows, as for infinite scroll, or before it towards newer
+// ones.
+type Keyset struct {
+	Limit  int
+	key    *keysetKey
+	before bool
+}
+
+type keysetKey struct {
+	At time.Time `json:"at"`
+	ID uint      `json:"id"`
+}
+
+// Cursors are the cursors of the pages around a keyset page. Next pages to
+// older rows and is empty on the oldest page; Prev pages to newer rows and
+// is set whenever the page has rows, since newer rows may be added at any
+// time.
+type Cursors struct {
+	Next string
+	Prev string
+}
+
+// KeysetFromRequest reads the limit, after and before query parameters;
+// cursor is accepted in place of after. Limits are clamped to
+// [1, MaxLimit].
+func KeysetFromRequest(c *gin.Context) (Keyset, error) {
+	limit := DefaultLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return Keyset{}, apperrors.Validation("invalid limit")
+		}
+		limit = n
+	}
+	after := c.Query("after")
+	if after == "" {
+		after = c.Query("cursor")
+	}
+	return newKeyset(limit, after, c.Query("before"))
+}
+
+// KeysetFromArgs builds a keyset page from optional limit and after-cursor
+// arguments, such as those of a GraphQL field
+func KeysetFromArgs(limit *int, cursor *string) (Keyset, error) {
+	n, after := DefaultLimit, ""
+	if limit != nil {
+		n = *limit
+	}
+	if cursor != nil {
+		after = *cursor
+	}
+	return newKeyset(n, after, "")
+}
+
+func newKeyset(limit int, after, before string) (Keyset, error) {
+	k := Keyset{Limit: clamp(limit, MaxLimit)}
+	if after != "" && before != "" {
+		return Keyset{}, apperrors.Validation("after and before cannot be combined")
+	}
+
+	value := after
+	if before != "" {
+		value, k.before = before, true
+	}
+	if value != "" {
+		key, err := decodeKeysetCursor(value)
+		if err != nil {
+			return Keyset{}, apperrors.Validation("invalid cursor")
+		}
+		k.key = &key
+	}
+	return k, nil
+}
+
+// Apply scopes a query with created_at and id columns to the page. One row
+// more than the limit is fetched so Trim can tell whether more follow.
+func (k Keyset) Apply(db *gorm.DB) *gorm.DB {
+	if k.key == nil {
+		return db.Order("created_at DESC, id DESC").Limit(k.Limit + 1)
+	}
+
+	// Times are compared in the zone they were written in, which matters
+	// to SQLite since it compares them as text
+	at := k.key.At.Local()
+	if k.before {
+		return db.Where("(created_at > ? OR (created_at = ? AND id > ?))", at, at, k.key.ID).
+			Order("created_at ASC, id ASC").Limit(k.Limit + 1)
+	}
+	return db.Where("(created_at < ? OR (created_at = ? AND id < ?))", at, at, k.key.ID).
+		Order("created_at DESC, id DESC").Limit(k.Limit + 1)
+}
+
+// Trim takes the rows fetched with Apply, or SliceKeyset, and returns those
+// on the page, newest first, with the cursors around it. key returns the
+// creation time and ID of a row.
+func Trim[T any](k Keyset, rows []T, key func(*T) (time.Time, uint)) ([]T, Cursors) {
+	more := len(rows) > k.Limit
+	if more {
+		rows = rows[:k.Limit]
+	}
+	if k.before {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	var cursors Cursors
+	if len(rows) == 0 {
+		// Nothing newer yet; the same cursor is polled again
+		if k.before {
+			cursors.Prev = encodeKeysetCursor(*k.key)
+		}
+		return rows, cursors
+	}
+
+	at, id := key(&rows[0])
+	cursors.Prev = encodeKeysetCursor(keysetKey{At: at, ID: id})
+	// Rows before a cursor are followed by at least the cursor's own row
+	if more || k.before {
+		at, id := key(&rows[len(rows)-1])
+		cursors.Next = encodeKeysetCursor(keysetKey{At: at, ID: id})
+	}
+	return rows, cursors
+}
+
+// SliceKeyset applies the page to rows held in memory, in any order,
+// returning them as Apply would fetch them
+func SliceKeyset[T any](k Keyset, rows []T, key func(*T) (time.Time, uint)) []T {
+	newer := func(a, b keysetKey) bool {
+		return a.At.After(b.At) || (a.At.Equal(b.At) && a.ID > b.ID)
+	}
+	keyOf := func(row *T) keysetKey {
+		at, id := key(row)
+		return keysetKey{At: at, ID: id}
+	}
+
+	var out []T
+	for i := range rows {
+		row := keyOf(&rows[i])
+		if k.key == nil || (k.before && newer(row, *k.key)) || (!k.before && newer(*k.key, row)) {
+			out = append(out, rows[i])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if k.before {
+			return newer(keyOf(&out[j]), keyOf(&out[i]))
+		}
+		return newer(keyOf(&out[i]), keyOf(&out[j]))
+	})
+	if len(out) > k.Limit+1 {
+		out = out[:k.Limit+1]
+	}
+	return out
+}
+
+func encodeKeysetCursor(key keysetKey) string {
+	data, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeKeysetCursor(value string) (keysetKey, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return keysetKey{}, err
+	}
+
+	var key keysetKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return keysetKey{}, err
+	}
+	if key.At.IsZero() || key.ID == 0 {
+		return keysetKey{}, apperrors.Validation("invalid cursor")
+	}
+	return key, nil
+}
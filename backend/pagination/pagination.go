@@ -0,0 +1,219 @@
+package pagination
+
+import (
+	"ecommerce-backend/apperrors"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+	// MaxStreamLimit bounds pages of endpoints that stream their response
+	MaxStreamLimit = 10000
+)
+
+// Page is a window over a list ordered by primary key. The cursor is an
+// opaque token holding the ID of the last row of the previous page, so
+// pages stay stable while rows are inserted.
+type Page struct {
+	Limit   int
+	afterID uint
+	desc    bool
+}
+
+type cursor struct {
+	ID uint `json:"id"`
+}
+
+// FromRequest reads the limit and cursor query parameters. Limits are
+// clamped to [1, MaxLimit]; desc selects newest-first ordering.
+func FromRequest(c *gin.Context, desc bool) (Page, error) {
+	return FromRequestUpTo(c, desc, MaxLimit)
+}
+
+// FromRequestUpTo is like FromRequest with a different upper bound for the
+// limit, for endpoints that stream their response
+func FromRequestUpTo(c *gin.Context, desc bool, maxLimit int) (Page, error) {
+	page := Page{Limit: DefaultLimit, desc: desc}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return Page{}, apperrors.Validation("invalid limit")
+		}
+		page.Limit = clamp(limit, maxLimit)
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		id, err := decodeCursor(value)
+		if err != nil {
+			return Page{}, apperrors.Validation("invalid cursor")
+		}
+		page.afterID = id
+	}
+
+	return page, nil
+}
+
+// FromArgs builds a page from optional limit and cursor arguments, such as
+// those of a GraphQL field, with the same rules as FromRequest
+func FromArgs(limit *int, cursor *string, desc bool) (Page, error) {
+	page := Page{Limit: DefaultLimit, desc: desc}
+	if limit != nil {
+		page.Limit = clamp(*limit, MaxLimit)
+	}
+	if cursor != nil && *cursor != "" {
+		id, err := decodeCursor(*cursor)
+		if err != nil {
+			return Page{}, apperrors.Validation("invalid cursor")
+		}
+		page.afterID = id
+	}
+	return page, nil
+}
+
+// Apply scopes a query to the page. One row more than the limit is fetched
+// so Next can tell whether another page follows.
+func (p Page) Apply(db *gorm.DB) *gorm.DB {
+	return p.scope(db, p.afterID, p.Limit+1)
+}
+
+// scope restricts db to at most limit rows after the given ID in page order
+func (p Page) scope(db *gorm.DB, afterID uint, limit int) *gorm.DB {
+	if p.desc {
+		if afterID > 0 {
+			db = db.Where("id < ?", afterID)
+		}
+		return db.Order("id DESC").Limit(limit)
+	}
+
+	if afterID > 0 {
+		db = db.Where("id > ?", afterID)
+	}
+	return db.Order("id ASC").Limit(limit)
+}
+
+// Next takes the number of rows fetched with Apply and a function returning
+// the ID of the i-th row. It returns how many rows belong to the page and
+// the cursor for the next page, which is empty on the last page.
+func (p Page) Next(fetched int, idAt func(i int) uint) (int, string) {
+	if fetched <= p.Limit {
+		return fetched, ""
+	}
+	return p.Limit, encodeCursor(idAt(p.Limit - 1))
+}
+
+// Key identifies the page, e.g. for caching
+func (p Page) Key() string {
+	key := "limit=" + strconv.Itoa(p.Limit) + "&after=" + strconv.FormatUint(uint64(p.afterID), 10)
+	if p.desc {
+		key += "&desc"
+	}
+	return key
+}
+
+// Each loads the page in batches of batchSize and calls fn for every row,
+// so large pages can be streamed without holding them in memory. It returns
+// the cursor for the next page, which is empty on the last page.
+func Each[T any](p Page, db *gorm.DB, batchSize int, id func(*T) uint, fn func(*T) error) (string, error) {
+	return EachBatch(p, db, batchSize, id, nil, fn)
+}
+
+// EachBatch is Each with a load function called on every batch before its
+// rows are passed to fn, so that associations can be fetched for the whole
+// batch at once rather than preloaded one query per association
+func EachBatch[T any](p Page, db *gorm.DB, batchSize int, id func(*T) uint, load func([]T) error, fn func(*T) error) (string, error) {
+	base := db.Session(&gorm.Session{})
+	afterID := p.afterID
+	sent := 0
+
+	for {
+		// The final batch fetches one extra row to detect a following page
+		size, final := batchSize, false
+		if remaining := p.Limit - sent; remaining <= batchSize {
+			size, final = remaining+1, true
+		}
+
+		var batch []T
+		if err := p.scope(base, afterID, size).Find(&batch).Error; err != nil {
+			return "", err
+		}
+		if load != nil && len(batch) > 0 {
+			if err := load(batch); err != nil {
+				return "", err
+			}
+		}
+
+		for i := range batch {
+			if sent == p.Limit {
+				return encodeCursor(afterID), nil
+			}
+			if err := fn(&batch[i]); err != nil {
+				return "", err
+			}
+			afterID = id(&batch[i])
+			sent++
+		}
+
+		if final || len(batch) < size {
+			return "", nil
+		}
+	}
+}
+
+// Slice applies the page to rows held in memory, which must be sorted by
+// ascending ID. Like Apply, it returns one row more than the limit when
+// another page follows.
+func Slice[T any](p Page, rows []T, id func(*T) uint) []T {
+	var out []T
+	for i := range rows {
+		row := &rows[i]
+		if p.desc {
+			row = &rows[len(rows)-1-i]
+		}
+
+		if p.afterID > 0 {
+			if rowID := id(row); (p.desc && rowID >= p.afterID) || (!p.desc && rowID <= p.afterID) {
+				continue
+			}
+		}
+		if len(out) > p.Limit {
+			break
+		}
+		out = append(out, *row)
+	}
+	return out
+}
+
+func clamp(limit, maxLimit int) int {
+	switch {
+	case limit < 1:
+		return 1
+	case limit > maxLimit:
+		return maxLimit
+	}
+	return limit
+}
+
+func encodeCursor(id uint) string {
+	data, _ := json.Marshal(cursor{ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (uint, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return 0, err
+	}
+
+	var cur cursor
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return 0, err
+	}
+	return cur.ID, nil
+}
@@ -0,0 +1,123 @@
+package pagination
+
+import (
+	"gorm.io/gorm"
+)
+
+// Position is where a page lies in its list: the list's size, the page's
+// number and the cursors of the pages around it. Page numbers count pages
+// of the page's limit from the start, so they are exact when the cursor
+// was reached by following next links with the same limit.
+type Position struct {
+	Total   int64
+	Page    int
+	PerPage int
+	// HasPrev reports whether a page precedes this one; Prev is its
+	// cursor, empty for the first page
+	HasPrev bool
+	Prev    string
+	// Next is the cursor of the following page, empty on the last page
+	Next string
+	// Last is the cursor of the last page, empty if it is the first
+	Last string
+}
+
+// Meta is the pagination metadata of list responses
+type Meta struct {
+	Total   int64 `json:"total"`
+	Page    int   `json:"page"`
+	PerPage int   `json:"per_page"`
+}
+
+// Meta returns the position as list response metadata
+func (pos Position) Meta() Meta {
+	return Meta{Total: pos.Total, Page: pos.Page, PerPage: pos.PerPage}
+}
+
+// Locate finds the page in the rows of db, which must select a model
+// without paging it
+func Locate(p Page, db *gorm.DB) (Position, error) {
+	base := db.Session(&gorm.Session{})
+
+	var total, before int64
+	if err := base.Count(&total).Error; err != nil {
+		return Position{}, err
+	}
+	if p.afterID > 0 {
+		preceding := base.Where("id <= ?", p.afterID)
+		if p.desc {
+			preceding = base.Where("id >= ?", p.afterID)
+		}
+		if err := preceding.Count(&before).Error; err != nil {
+			return Position{}, err
+		}
+	}
+
+	return p.position(total, before, func(offset int64) (uint, error) {
+		var ids []uint
+		err := p.scope(base, 0, 1).Offset(int(offset)).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return 0, err
+		}
+		return ids[0], nil
+	})
+}
+
+// LocateSlice finds the page in rows held in memory, which must be sorted
+// by ascending ID
+func LocateSlice[T any](p Page, rows []T, id func(*T) uint) Position {
+	var before int64
+	if p.afterID > 0 {
+		for i := range rows {
+			if rowID := id(&rows[i]); (p.desc && rowID >= p.afterID) || (!p.desc && rowID <= p.afterID) {
+				before++
+			}
+		}
+	}
+
+	pos, _ := p.position(int64(len(rows)), before, func(offset int64) (uint, error) {
+		if p.desc {
+			return id(&rows[int64(len(rows))-1-offset]), nil
+		}
+		return id(&rows[offset]), nil
+	})
+	return pos
+}
+
+// position numbers the page from the size of its list and the number of
+// rows before it; idAt returns the ID of the row at an offset in page order
+func (p Page) position(total, before int64, idAt func(offset int64) (uint, error)) (Position, error) {
+	limit := int64(p.Limit)
+	pos := Position{Total: total, Page: int(before/limit) + 1, PerPage: p.Limit}
+
+	// cursorAt returns the cursor of the page starting at offset
+	cursorAt := func(offset int64) (string, error) {
+		if offset <= 0 {
+			return "", nil
+		}
+		id, err := idAt(offset - 1)
+		if err != nil {
+			return "", err
+		}
+		return encodeCursor(id), nil
+	}
+
+	var err error
+	if before > 0 {
+		pos.HasPrev = true
+		if pos.Prev, err = cursorAt(before - limit); err != nil {
+			return Position{}, err
+		}
+	}
+	if before+limit < total {
+		if pos.Next, err = cursorAt(before + limit); err != nil {
+			return Position{}, err
+		}
+	}
+	if total > 0 {
+		if pos.Last, err = cursorAt((total - 1) / limit * limit); err != nil {
+			return Position{}, err
+		}
+	}
+	return pos, nil
+}
@@ -0,0 +1,68 @@
+package passwords
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"ecommerce-backend/resilience"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const requestTimeout = 5 * time.Second
+
+// client propagates the trace context of the caller to the breach API,
+// retrying failed requests and failing fast while it is down
+var client = &http.Client{
+	Transport: resilience.Transport("pwned-passwords", requestTimeout, otelhttp.NewTransport(http.DefaultTransport)),
+}
+
+// breachCount returns how often the password appears in the breaches known
+// to the Pwned Passwords API. Only the first five characters of its SHA-1
+// hash are sent; the API answers with the suffixes of every hash starting
+// with them, padded with decoys, and the match is made here.
+func breachCount(ctx context.Context, apiURL, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	endpoint := strings.TrimRight(apiURL, "/") + "/range/" + prefix
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error creating breach request: %v", err)
+	}
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error querying breaches: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("breach api responded with status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		candidate, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || !strings.EqualFold(candidate, suffix) {
+			continue
+		}
+		// Padding entries have a count of 0
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, fmt.Errorf("invalid breach count %q", count)
+		}
+		return n, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("error reading breaches: %v", err)
+	}
+	return 0, nil
+}
@@ -0,0 +1,166 @@
+// Package passwords checks new passwords against the configured policy:
+// their length, the character classes they mix, their estimated strength
+// and whether they are known from data breaches.
+package passwords
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/config"
+	"ecommerce-backend/logging"
+	"fmt"
+	"sync"
+	"unicode"
+)
+
+// maxLength is the most bytes bcrypt hashes; longer passwords are refused
+// rather than silently truncated
+const maxLength = 72
+
+var (
+	mu     sync.RWMutex
+	policy = config.Default().Passwords
+)
+
+// Init sets the policy passwords are checked against
+func Init(cfg config.PasswordConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	policy = cfg
+}
+
+// rule checks a password, returning the violation if it breaks the rule
+type rule func(ctx context.Context, password string, userInputs []string) *apperrors.Violation
+
+// Check runs every rule of the policy on the password, so that users learn
+// of all the violations at once, and returns them as an ErrWeakPassword.
+// userInputs, such as the username and email, make passwords built from
+// them score as weak.
+func Check(ctx context.Context, password string, userInputs ...string) error {
+	violations := Violations(ctx, password, userInputs...)
+	if len(violations) == 0 {
+		return nil
+	}
+	return apperrors.ErrWeakPassword.WithDetails(map[string][]apperrors.Violation{"violations": violations})
+}
+
+// Violations returns the rules of the policy the password breaks
+func Violations(ctx context.Context, password string, userInputs ...string) []apperrors.Violation {
+	mu.RLock()
+	cfg := policy
+	mu.RUnlock()
+
+	var violations []apperrors.Violation
+	for _, rule := range rules(cfg) {
+		if v := rule(ctx, password, userInputs); v != nil {
+			violations = append(violations, *v)
+		}
+	}
+	return violations
+}
+
+// rules builds the pipeline of rules of the policy
+func rules(cfg config.PasswordConfig) []rule {
+	rules := []rule{length(cfg.MinLength)}
+	if cfg.MinClasses > 0 {
+		rules = append(rules, characterClasses(cfg.MinClasses))
+	}
+	if cfg.MinScore > 0 {
+		rules = append(rules, strength(cfg.MinScore))
+	}
+	if cfg.BreachCheck {
+		rules = append(rules, notBreached(cfg.BreachAPIURL))
+	}
+	return rules
+}
+
+// length requires at least minLength characters, and at most maxLength
+// bytes
+func length(minLength int) rule {
+	return func(ctx context.Context, password string, userInputs []string) *apperrors.Violation {
+		if n := len([]rune(password)); n < minLength {
+			return &apperrors.Violation{
+				Rule:    "min_length",
+				Message: fmt.Sprintf("passwords must be at least %d characters long", minLength),
+				Params:  map[string]interface{}{"min_length": minLength, "length": n},
+			}
+		}
+		if len(password) > maxLength {
+			return &apperrors.Violation{
+				Rule:    "max_length",
+				Message: fmt.Sprintf("passwords must be at most %d bytes long", maxLength),
+				Params:  map[string]interface{}{"max_length": maxLength, "length": len(password)},
+			}
+		}
+		return nil
+	}
+}
+
+// characterClasses requires passwords to mix minClasses of lowercase
+// letters, uppercase letters, digits and symbols
+func characterClasses(minClasses int) rule {
+	return func(ctx context.Context, password string, userInputs []string) *apperrors.Violation {
+		if n := classes(password); n < minClasses {
+			return &apperrors.Violation{
+				Rule: "character_classes",
+				Message: fmt.Sprintf("passwords must mix at least %d of lowercase letters, uppercase letters, digits and symbols",
+					minClasses),
+				Params: map[string]interface{}{"min_classes": minClasses, "classes": n},
+			}
+		}
+		return nil
+	}
+}
+
+// strength requires passwords to score at least minScore
+func strength(minScore int) rule {
+	return func(ctx context.Context, password string, userInputs []string) *apperrors.Violation {
+		if score := Score(password, userInputs...); score < minScore {
+			return &apperrors.Violation{
+				Rule:    "strength",
+				Message: "password is too easy to guess; avoid common words, names, sequences and repeated characters",
+				Params:  map[string]interface{}{"min_score": minScore, "score": score},
+			}
+		}
+		return nil
+	}
+}
+
+// notBreached refuses passwords known from data breaches. Passwords are
+// let through when the API cannot be reached, so that it being down does
+// not stop registrations.
+func notBreached(apiURL string) rule {
+	return func(ctx context.Context, password string, userInputs []string) *apperrors.Violation {
+		count, err := breachCount(ctx, apiURL, password)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to check password against breaches", "error", err)
+			return nil
+		}
+		if count > 0 {
+			return &apperrors.Violation{
+				Rule:    "breached",
+				Message: "password has appeared in a data breach; choose another one",
+				Params:  map[string]interface{}{"occurrences": count},
+			}
+		}
+		return nil
+	}
+}
+
+// classes counts the character classes the password mixes
+func classes(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
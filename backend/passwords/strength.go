@@ -0,0 +1,245 @@
+package passwords
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Score estimates the strength of the password on the zxcvbn scale, from 0
+// (too guessable) to 4 (very unguessable). Like zxcvbn it looks for the
+// patterns attackers try first, which are common passwords, the user's own
+// details, years, repeated characters, sequences and keyboard rows,
+// possibly capitalized or with l33t substitutions, and estimates the
+// guesses needed to find the password: below 10^3, 10^6, 10^8 and 10^10
+// guesses it scores 0 to 3.
+func Score(password string, userInputs ...string) int {
+	guesses := estimateGuesses(password, userInputs)
+	switch {
+	case guesses < 1e3:
+		return 0
+	case guesses < 1e6:
+		return 1
+	case guesses < 1e8:
+		return 2
+	case guesses < 1e10:
+		return 3
+	}
+	return 4
+}
+
+const (
+	// minPatternLength is the fewest characters a pattern spans
+	minPatternLength = 3
+	// minDictionaryGuesses is the fewest guesses of a dictionary word, so
+	// that the very first common passwords still cost something
+	minDictionaryGuesses = 10
+	// keyboardGuesses is the guesses per character of a keyboard run,
+	// given the rows and directions it might start in
+	keyboardGuesses = 40
+	// yearGuesses is the guesses of a year, the recent ones being tried
+	// first
+	yearGuesses = 100
+)
+
+// commonPasswords are among the most used passwords, most common first;
+// their rank is how many guesses they take
+var commonPasswords = []string{
+	"123456", "password", "12345678", "qwerty", "123456789", "12345", "1234", "111111", "1234567", "dragon",
+	"123123", "baseball", "abc123", "football", "monkey", "letmein", "696969", "shadow", "master", "666666",
+	"qwertyuiop", "123321", "mustang", "1234567890", "michael", "654321", "superman", "1qaz2wsx", "7777777", "121212",
+	"000000", "qazwsx", "123qwe", "killer", "trustno1", "jordan", "jennifer", "zxcvbnm", "asdfgh", "hunter",
+	"buster", "soccer", "harley", "batman", "andrew", "tigger", "sunshine", "iloveyou", "2000", "charlie",
+	"robert", "thomas", "hockey", "ranger", "daniel", "starwars", "klaster", "112233", "george", "computer",
+	"michelle", "jessica", "pepper", "1111", "zxcvbn", "555555", "11111111", "131313", "freedom", "777777",
+	"pass", "maggie", "159753", "aaaaaa", "ginger", "princess", "joshua", "cheese", "amanda", "summer",
+	"love", "ashley", "nicole", "chelsea", "biteme", "matthew", "access", "yankees", "987654321", "dallas",
+	"austin", "thunder", "taylor", "matrix", "admin", "welcome", "secret", "login", "changeme", "hello",
+	"shopping", "store", "shop", "guest", "test", "default", "root", "money", "flower", "orange",
+	"banana", "apple", "chocolate", "hello123", "qwerty123", "password1", "welcome1", "admin123", "letmein1", "iloveu",
+}
+
+var commonRanks = func() map[string]int {
+	ranks := make(map[string]int, len(commonPasswords))
+	for i, p := range commonPasswords {
+		ranks[p] = i + 1
+	}
+	return ranks
+}()
+
+// keyboardRows are the rows of a QWERTY keyboard, runs along which are
+// keyboard patterns
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// leet undoes common l33t substitutions
+var leet = map[rune]rune{
+	'4': 'a', '@': 'a', '3': 'e', '1': 'i', '!': 'i', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't',
+}
+
+// estimateGuesses finds the patterns in the password and returns the
+// fewest guesses over the ways of covering it with them, characters
+// matching none being brute-forced, the guesses of consecutive parts
+// multiplying
+func estimateGuesses(password string, userInputs []string) float64 {
+	chars := []rune(password)
+	lower := make([]rune, len(chars))
+	unleet := make([]rune, len(chars))
+	for i, r := range chars {
+		lower[i] = unicode.ToLower(r)
+		unleet[i] = lower[i]
+		if plain, ok := leet[r]; ok {
+			unleet[i] = plain
+		}
+	}
+
+	dictionary := map[string]int{}
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		local, _, _ := strings.Cut(input, "@")
+		for _, word := range []string{input, local} {
+			if len([]rune(word)) >= minPatternLength {
+				dictionary[word] = 1
+			}
+		}
+	}
+
+	// best[j] is the fewest guesses of the first j characters
+	cardinality := bruteCardinality(chars)
+	best := make([]float64, len(chars)+1)
+	for j := 1; j <= len(chars); j++ {
+		best[j] = math.Inf(1)
+	}
+	best[0] = 1
+	for i := 0; i < len(chars); i++ {
+		best[i+1] = math.Min(best[i+1], best[i]*cardinality)
+		for _, m := range patternsAt(chars, lower, unleet, i, dictionary) {
+			best[m.end] = math.Min(best[m.end], best[i]*m.guesses)
+		}
+	}
+	return best[len(chars)]
+}
+
+// match is a pattern ending before end that takes guesses to find
+type match struct {
+	end     int
+	guesses float64
+}
+
+// patternsAt returns the patterns starting at i
+func patternsAt(chars, lower, unleet []rune, i int, dictionary map[string]int) []match {
+	var matches []match
+	for j := i + minPatternLength; j <= len(chars); j++ {
+		for _, form := range [][]rune{lower, unleet} {
+			word := string(form[i:j])
+			rank, ok := commonRanks[word]
+			if !ok {
+				rank, ok = dictionary[word]
+			}
+			if !ok {
+				continue
+			}
+			guesses := math.Max(float64(rank), minDictionaryGuesses) * caseVariations(chars[i:j])
+			if word != string(lower[i:j]) {
+				guesses *= 2
+			}
+			matches = append(matches, match{j, guesses})
+		}
+		if j-i == 4 && isYear(lower[i:j]) {
+			matches = append(matches, match{j, yearGuesses})
+		}
+	}
+
+	runs := func(n int, perChar float64) {
+		for k := minPatternLength; k <= n; k++ {
+			matches = append(matches, match{i + k, perChar * float64(k)})
+		}
+	}
+	runs(runLength(lower, i, func(a, b rune) bool { return a == b }), bruteCardinality(chars[i:i+1]))
+	for _, delta := range []rune{1, -1} {
+		base := 26.0
+		if unicode.IsDigit(lower[i]) {
+			base = 10
+		}
+		if delta < 0 {
+			base *= 2
+		}
+		runs(runLength(lower, i, func(a, b rune) bool { return b-a == delta && sameClass(a, b) }), base)
+	}
+	runs(runLength(lower, i, adjacentKeys), keyboardGuesses)
+	return matches
+}
+
+// isYear reports whether the digits are a recent year, 1900 to 2099
+func isYear(digits []rune) bool {
+	s := string(digits)
+	return (strings.HasPrefix(s, "19") || strings.HasPrefix(s, "20")) &&
+		unicode.IsDigit(digits[2]) && unicode.IsDigit(digits[3])
+}
+
+// runLength returns how many characters from i on each follow the previous
+// one as next requires
+func runLength(chars []rune, i int, next func(a, b rune) bool) int {
+	n := 1
+	for i+n < len(chars) && next(chars[i+n-1], chars[i+n]) {
+		n++
+	}
+	return n
+}
+
+// adjacentKeys reports whether b is next to a on a keyboard row
+func adjacentKeys(a, b rune) bool {
+	for _, row := range keyboardRows {
+		i, j := strings.IndexRune(row, a), strings.IndexRune(row, b)
+		if i >= 0 && j >= 0 && (j-i == 1 || i-j == 1) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameClass(a, b rune) bool {
+	return unicode.IsDigit(a) == unicode.IsDigit(b) && unicode.IsLetter(a) == unicode.IsLetter(b)
+}
+
+// caseVariations is how many capitalizations of a word are guessed before
+// the one used: none for lowercase, a couple for capitalized or uppercase
+// words, and more the more letters of mixed case are uppercase
+func caseVariations(word []rune) float64 {
+	upper, letters := 0, 0
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	switch {
+	case upper == 0:
+		return 1
+	case upper == letters || (upper == 1 && unicode.IsUpper(word[0])):
+		return 2
+	}
+	return 2 * math.Pow(2, float64(min(upper, letters-upper)))
+}
+
+// bruteCardinality is the size of the alphabet of the character classes
+// the characters use
+func bruteCardinality(chars []rune) float64 {
+	var lower, upper, digit, symbol float64
+	for _, r := range chars {
+		switch {
+		case unicode.IsLower(r):
+			lower = 26
+		case unicode.IsUpper(r):
+			upper = 26
+		case unicode.IsDigit(r):
+			digit = 10
+		case r < unicode.MaxASCII:
+			symbol = 33
+		default:
+			symbol = 100
+		}
+	}
+	return lower + upper + digit + symbol
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/currency"
+	"ecommerce-backend/models"
+	"ecommerce-backend/testutil"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// fixedRates is an exchange-rate provider with constant rates
+type fixedRates map[string]float64
+
+func (r fixedRates) Rates(ctx context.Context, base string) (map[string]float64, error) {
+	return r, nil
+}
+
+// useRates converts the base currency, USD, at the given rates until the
+// test ends
+func useRates(t *testing.T, rates fixedRates) {
+	previous := currency.Get()
+	currency.Set(currency.NewConverter(config.CurrencyConfig{Base: "USD", RefreshInterval: time.Hour}, rates))
+	t.Cleanup(func() { currency.Set(previous) })
+}
+
+func createPromotion(t *testing.T, db *gorm.DB, item models.Item, percent float64) {
+	t.Helper()
+	promotion := models.Promotion{Name: "Promotion", Kind: models.PromotionPercentOff, ItemID: &item.ID, Percent: percent}
+	if err := db.Create(&promotion).Error; err != nil {
+		t.Fatalf("failed to create promotion: %v", err)
+	}
+}
+
+func createSale(t *testing.T, db *gorm.DB, item models.Item, price float64, perUserLimit int) {
+	t.Helper()
+	now := time.Now()
+	sale := models.Sale{
+		Name:         "Sale",
+		ItemID:       &item.ID,
+		Price:        &price,
+		PerUserLimit: perUserLimit,
+		StartsAt:     now.Add(-time.Hour),
+		EndsAt:       now.Add(time.Hour),
+		Active:       true,
+	}
+	if err := db.Create(&sale).Error; err != nil {
+		t.Fatalf("failed to create sale: %v", err)
+	}
+}
+
+func createGiftCard(t *testing.T, db *gorm.DB, code string, balance float64) models.GiftCard {
+	t.Helper()
+	card := models.GiftCard{Code: code, InitialBalance: balance, Balance: balance}
+	if err := db.Create(&card).Error; err != nil {
+		t.Fatalf("failed to create gift card: %v", err)
+	}
+	return card
+}
+
+// TestCheckoutPricing checks how promotions, sales, gift cards and the
+// settlement currency make up the total of an order of three widgets at
+// 10.00
+func TestCheckoutPricing(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, db *gorm.DB, widget models.Item)
+		// giftCard is the balance of the gift card paying for the order,
+		// 0 for none
+		giftCard float64
+		currency string
+		rates    fixedRates
+
+		wantDiscount   float64
+		wantGiftCard   float64
+		wantTotal      float64
+		wantSettlement float64
+	}{
+		{
+			name:      "regular price",
+			wantTotal: 30, wantSettlement: 30,
+		},
+		{
+			name: "promotion",
+			setup: func(t *testing.T, db *gorm.DB, widget models.Item) {
+				createPromotion(t, db, widget, 10)
+			},
+			wantDiscount: 3, wantTotal: 27, wantSettlement: 27,
+		},
+		{
+			name: "sale taking more off than the promotion",
+			setup: func(t *testing.T, db *gorm.DB, widget models.Item) {
+				createPromotion(t, db, widget, 10)
+				createSale(t, db, widget, 8, 0)
+			},
+			wantDiscount: 6, wantTotal: 24, wantSettlement: 24,
+		},
+		{
+			name: "promotion taking more off than the sale",
+			setup: func(t *testing.T, db *gorm.DB, widget models.Item) {
+				createPromotion(t, db, widget, 50)
+				createSale(t, db, widget, 8, 0)
+			},
+			wantDiscount: 15, wantTotal: 15, wantSettlement: 15,
+		},
+		{
+			name: "sale limited to one unit per customer",
+			setup: func(t *testing.T, db *gorm.DB, widget models.Item) {
+				createSale(t, db, widget, 8, 1)
+			},
+			wantDiscount: 2, wantTotal: 28, wantSettlement: 28,
+		},
+		{
+			name:         "gift card paying part of the order",
+			giftCard:     12,
+			wantGiftCard: 12, wantTotal: 18, wantSettlement: 18,
+		},
+		{
+			name:         "gift card paying the whole order",
+			giftCard:     50,
+			wantGiftCard: 30, wantTotal: 0, wantSettlement: 0,
+		},
+		{
+			name:      "settled in another currency",
+			currency:  "EUR",
+			rates:     fixedRates{"EUR": 0.5},
+			wantTotal: 30, wantSettlement: 15,
+		},
+		{
+			name: "promotion and gift card settled in another currency",
+			setup: func(t *testing.T, db *gorm.DB, widget models.Item) {
+				createPromotion(t, db, widget, 10)
+			},
+			giftCard:     7,
+			currency:     "EUR",
+			rates:        fixedRates{"EUR": 2},
+			wantDiscount: 3, wantGiftCard: 7, wantTotal: 20, wantSettlement: 40,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.Setup(t)
+			client := testutil.NewClient(t, setupRouter())
+			if tt.rates != nil {
+				useRates(t, tt.rates)
+			}
+
+			widget := testutil.CreateItem(t, db, "Widget", 10)
+			if tt.setup != nil {
+				tt.setup(t, db, widget)
+			}
+			body := map[string]interface{}{}
+			var card models.GiftCard
+			if tt.giftCard > 0 {
+				card = createGiftCard(t, db, "ABCD-EFGH-JKLM-NPQR", tt.giftCard)
+				body["gift_card_code"] = card.Code
+			}
+			if tt.currency != "" {
+				body["currency"] = tt.currency
+			}
+
+			alice := client.As(testutil.CreateUser(t, db, "alice", models.RoleCustomer))
+			alice.POST("/api/v1/carts", map[string]interface{}{"item_id": widget.ID, "quantity": 3}).AssertStatus(http.StatusOK)
+			order := alice.POST("/api/v1/orders", body).
+				AssertStatus(http.StatusCreated).
+				AssertJSON("discount", tt.wantDiscount).
+				AssertJSON("gift_card_amount", tt.wantGiftCard).
+				AssertJSON("total", tt.wantTotal).
+				AssertJSON("settlement.total", tt.wantSettlement)
+			if tt.currency != "" {
+				order.AssertJSON("settlement.currency", tt.currency)
+			}
+
+			if tt.giftCard > 0 {
+				if err := db.First(&card, card.ID).Error; err != nil {
+					t.Fatalf("failed to reload gift card: %v", err)
+				}
+				if want := tt.giftCard - tt.wantGiftCard; card.Balance != want {
+					t.Fatalf("gift card balance = %v, want %v", card.Balance, want)
+				}
+			}
+		})
+	}
+}
+
+// TestReceiptKeepsCheckoutPrices checks that receipts show the prices paid
+// after the items are repriced in bulk or deleted
+func TestReceiptKeepsCheckoutPrices(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, db *gorm.DB, admin *testutil.Client, widget models.Item)
+	}{
+		{
+			name: "repriced in bulk",
+			change: func(t *testing.T, db *gorm.DB, admin *testutil.Client, widget models.Item) {
+				admin.Do(http.MethodPatch, "/api/v1/admin/items/bulk", map[string]interface{}{
+					"items": []map[string]interface{}{{"item_id": widget.ID, "percent": 50}},
+				}).AssertStatus(http.StatusOK)
+			},
+		},
+		{
+			name: "deleted",
+			change: func(t *testing.T, db *gorm.DB, admin *testutil.Client, widget models.Item) {
+				if err := db.Delete(&widget).Error; err != nil {
+					t.Fatalf("failed to delete widget: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testutil.Setup(t)
+			client := testutil.NewClient(t, setupRouter())
+
+			widget := testutil.CreateItem(t, db, "Widget", 10)
+			alice := client.As(testutil.CreateUser(t, db, "alice", models.RoleCustomer))
+			alice.POST("/api/v1/carts", map[string]interface{}{"item_id": widget.ID, "quantity": 2}).AssertStatus(http.StatusOK)
+			orderID := alice.POST("/api/v1/orders", nil).
+				AssertStatus(http.StatusCreated).
+				AssertJSON("total", 20).
+				JSON("order_id")
+
+			tt.change(t, db, client.As(testutil.CreateUser(t, db, "root", models.RoleAdmin)), widget)
+
+			receipt := alice.GET(fmt.Sprintf("/api/v1/orders/%v/receipt", orderID)).
+				AssertStatus(http.StatusOK).
+				Body.String()
+			line := `<td>Widget</td><td class="num">2</td><td class="num">10.00</td><td class="num">20.00</td>`
+			if !strings.Contains(receipt, line) {
+				t.Fatalf("receipt lacks the line %s:\n%s", line, receipt)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PDF page geometry, in points on A4 paper
+const (
+	pageWidth   = 595.0
+	pageHeight  = 842.0
+	margin      = 50.0
+	fontSize    = 10.0
+	lineSpacing = 14.0
+)
+
+// PDF writes the receipt as a PDF of plain text laid out like the built-in
+// HTML template. Custom templates do not apply to it, and characters that
+// the standard Helvetica font cannot show are replaced by "?".
+func PDF(w io.Writer, r Receipt) error {
+	d := &pdfDoc{}
+	d.newPage()
+
+	title := r.Labels["title"]
+	if r.Brand != "" {
+		title = r.Brand + " - " + title
+	}
+	d.text(margin, 16, true, title)
+	d.advance(lineSpacing * 2)
+
+	d.text(margin, fontSize, false, r.Labels["order"]+": "+r.Number)
+	d.advance(lineSpacing)
+	d.text(margin, fontSize, false, r.Labels["date"]+": "+r.Date.Format("2006-01-02"))
+	d.advance(lineSpacing)
+	d.text(margin, fontSize, false, r.Labels["status"]+": "+r.Status)
+	d.advance(lineSpacing * 2)
+
+	if a := r.Address; a.Line1 != "" {
+		d.text(margin, fontSize, true, r.Labels["ship_to"])
+		d.advance(lineSpacing)
+		city := strings.TrimSpace(a.PostalCode + " " + a.City)
+		if a.Region != "" {
+			city += ", " + a.Region
+		}
+		for _, line := range []string{a.Name, a.Line1, a.Line2, city, a.Country} {
+			if line != "" {
+				d.text(margin, fontSize, false, line)
+				d.advance(lineSpacing)
+			}
+		}
+		d.advance(lineSpacing)
+	}
+
+	const quantityX, unitPriceX, amountX = 370.0, 460.0, pageWidth - margin
+	d.text(margin, fontSize, true, r.Labels["item"])
+	d.textRight(quantityX, true, r.Labels["quantity"])
+	d.textRight(unitPriceX, true, r.Labels["unit_price"])
+	d.textRight(amountX, true, r.Labels["amount"])
+	d.advance(lineSpacing * 1.5)
+	for _, line := range r.Lines {
+		d.text(margin, fontSize, false, line.Name)
+		d.textRight(quantityX, false, fmt.Sprint(line.Quantity))
+		d.textRight(unitPriceX, false, fmt.Sprintf("%.2f", line.UnitPrice))
+		d.textRight(amountX, false, fmt.Sprintf("%.2f", line.Amount))
+		d.advance(lineSpacing)
+	}
+	d.advance(lineSpacing / 2)
+
+	total := func(label string, amount float64, bold bool) {
+		d.text(unitPriceX-120, fontSize, bold, label)
+		d.textRight(amountX, bold, fmt.Sprintf("%.2f", amount))
+		d.advance(lineSpacing)
+	}
+	total(r.Labels["subtotal"], r.Subtotal, false)
+	if r.Discount != 0 {
+		total(r.Labels["discount"], -r.Discount, false)
+	}
+	if r.ShippingCost != 0 {
+		total(r.Labels["shipping"], r.ShippingCost, false)
+	}
+	if r.GiftCardAmount != 0 {
+		total(r.Labels["gift_card"], -r.GiftCardAmount, false)
+	}
+	total(r.Labels["total"], r.Total, true)
+	if r.Currency != "" {
+		total(r.Labels["settlement"]+" "+r.Currency, r.SettlementTotal, false)
+	}
+	d.advance(lineSpacing * 2)
+
+	d.text(margin, fontSize, false, r.Labels["thank_you"])
+	d.advance(lineSpacing)
+	d.text(margin, fontSize, false, r.Labels["not_invoice"])
+
+	_, err := d.WriteTo(w)
+	return err
+}
+
+// pdfDoc lays out text on the pages of a PDF, using the standard Helvetica
+// fonts so that nothing needs to be embedded
+type pdfDoc struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+// newPage starts a page, writing at its top
+func (d *pdfDoc) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pageHeight - margin
+}
+
+// advance moves down by height, starting a page when the bottom is reached
+func (d *pdfDoc) advance(height float64) {
+	d.y -= height
+	if d.y < margin {
+		d.newPage()
+	}
+}
+
+// text writes s on the current line starting at x
+func (d *pdfDoc) text(x, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+		font, size, x, d.y, pdfString(s))
+}
+
+// textRight writes s on the current line ending at x
+func (d *pdfDoc) textRight(x float64, bold bool, s string) {
+	d.text(x-textWidth(s, fontSize), fontSize, bold, s)
+}
+
+// WriteTo writes the document's catalog, fonts, pages and cross-reference
+// table
+func (d *pdfDoc) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1 to 4 are the catalog, page tree and fonts; each page is
+	// followed by its content stream
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	buf.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.WriteTo(w)
+}
+
+// pdfString encodes s as the contents of a PDF string in WinAnsiEncoding
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case r == '€':
+			b.WriteString("\\200")
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// textWidth estimates the width of s in Helvetica, exactly for amounts,
+// which are right-aligned
+func textWidth(s string, size float64) float64 {
+	var width float64
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			width += 556
+		case r == '.' || r == ',' || r == ' ':
+			width += 278
+		case r == '-':
+			width += 333
+		default:
+			width += 600
+		}
+	}
+	return width * size / 1000
+}
@@ -0,0 +1,162 @@
+// Package receipts renders printer-friendly receipts of orders for their
+// customers, as HTML from a template that stores may replace to brand them,
+// or as a plain PDF. Receipts are localized with the i18n translations.
+package receipts
+
+import (
+	"bytes"
+	"ecommerce-backend/config"
+	"ecommerce-backend/i18n"
+	"ecommerce-backend/models"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"sync"
+	"time"
+)
+
+//go:embed templates/receipt.html
+var files embed.FS
+
+var (
+	mu       sync.RWMutex
+	cfg      config.ReceiptConfig
+	htmlTmpl = template.Must(template.ParseFS(files, "templates/receipt.html"))
+)
+
+// Init sets the brand shown on receipts and loads the configured template,
+// if any, in place of the built-in one
+func Init(receiptCfg config.ReceiptConfig) error {
+	tmpl := template.Must(template.ParseFS(files, "templates/receipt.html"))
+	if receiptCfg.Template != "" {
+		var err error
+		if tmpl, err = template.ParseFiles(receiptCfg.Template); err != nil {
+			return fmt.Errorf("receipt template: %w", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	cfg, htmlTmpl = receiptCfg, tmpl
+	return nil
+}
+
+// Receipt is what receipt templates are executed with
+type Receipt struct {
+	Lang    string
+	Brand   string
+	LogoURL string
+	// Labels are the receipt's headings in its language, keyed like the
+	// "receipt" section of the translations
+	Labels map[string]string
+
+	Number  string
+	Date    time.Time
+	Status  string
+	Address models.PostalAddress
+	Lines   []Line
+	// Subtotal is the items before Discount; with ShippingCost and less
+	// GiftCardAmount it makes Total, the amount charged
+	Subtotal       float64
+	Discount       float64
+	ShippingCost   float64
+	GiftCardAmount float64
+	Total          float64
+	// Currency is the currency the order was settled in, and
+	// SettlementTotal Total in it; "" for orders placed before currencies
+	// were recorded
+	Currency        string
+	SettlementTotal float64
+}
+
+// Line is a line of a receipt
+type Line struct {
+	Name      string
+	Quantity  int
+	UnitPrice float64
+	Amount    float64
+}
+
+// labels are the English receipt headings, translated by key
+var labels = map[string]string{
+	"title":       "Receipt",
+	"order":       "Order",
+	"date":        "Date",
+	"status":      "Status",
+	"ship_to":     "Ship to",
+	"item":        "Item",
+	"quantity":    "Qty",
+	"unit_price":  "Unit price",
+	"amount":      "Amount",
+	"subtotal":    "Subtotal",
+	"discount":    "Discount",
+	"shipping":    "Shipping",
+	"gift_card":   "Gift card",
+	"total":       "Total",
+	"settlement":  "Charged in",
+	"thank_you":   "Thank you for your order.",
+	"not_invoice": "This receipt is not a tax invoice.",
+}
+
+// New builds the receipt of an order, with its cart items, in lang. Lines
+// show the names and prices the items had at checkout.
+func New(order models.Order, lang string) Receipt {
+	mu.RLock()
+	brand, logo := cfg.BrandName, cfg.LogoURL
+	mu.RUnlock()
+
+	r := Receipt{
+		Lang:           lang,
+		Brand:          brand,
+		LogoURL:        logo,
+		Labels:         make(map[string]string, len(labels)),
+		Number:         order.Number,
+		Date:           order.CreatedAt,
+		Status:         order.Status,
+		Address:        order.ShippingAddress,
+		Discount:       order.Discount,
+		ShippingCost:   order.ShippingCost,
+		GiftCardAmount: order.GiftCardAmount,
+		Total:          order.Total,
+		Subtotal:       round(order.Total + order.GiftCardAmount - order.ShippingCost + order.Discount),
+	}
+	if order.Currency != "" {
+		r.Currency, r.SettlementTotal = order.Currency, order.SettlementTotal
+	}
+	for key, label := range labels {
+		if translated, ok := i18n.Message(lang, "receipt."+key); ok {
+			label = translated
+		}
+		r.Labels[key] = label
+	}
+	for _, ci := range order.Cart.CartItems {
+		r.Lines = append(r.Lines, Line{
+			Name:      ci.Name,
+			Quantity:  ci.Quantity,
+			UnitPrice: ci.UnitPrice,
+			Amount:    round(ci.UnitPrice * float64(ci.Quantity)),
+		})
+	}
+	return r
+}
+
+// HTML writes the receipt with the receipt template
+func HTML(w io.Writer, r Receipt) error {
+	mu.RLock()
+	tmpl := htmlTmpl
+	mu.RUnlock()
+
+	// Rendered to a buffer first, so a failing template writes nothing
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, r); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// round rounds an amount to cents
+func round(amount float64) float64 {
+	return float64(int64(amount*100+0.5)) / 100
+}
@@ -0,0 +1,1617 @@
+package repository
+
+import (
+	"context"
+	"ecommerce-backend/database"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/search"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// eachBatchSize is how many rows are loaded per query by Each
+	eachBatchSize = 50
+	// orderBatchSize is how many orders Orders.Each loads per query. Their
+	// lines are fetched with one more query per batch, so batches can be
+	// larger than those of other rows.
+	orderBatchSize = 500
+	// maxCategoryFacets bounds the categories counted by item search
+	maxCategoryFacets = 50
+)
+
+type gormStore struct {
+	db *gorm.DB
+	// inTx is set for the store of a transaction
+	inTx bool
+}
+
+// NewGorm returns a Store backed by db
+func NewGorm(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) Users() UserRepository           { return gormUsers{s.db} }
+func (s *gormStore) Items() ItemRepository           { return gormItems{s.db} }
+func (s *gormStore) Carts() CartRepository           { return gormCarts{s.db} }
+func (s *gormStore) Orders() OrderRepository         { return gormOrders{s.db} }
+func (s *gormStore) Vendors() VendorRepository       { return gormVendors{s.db} }
+func (s *gormStore) Shipping() ShippingRepository    { return gormShipping{s.db} }
+func (s *gormStore) Delivery() DeliveryRepository    { return gormDelivery{s.db} }
+func (s *gormStore) Shipments() ShipmentRepository   { return gormShipments{s.db} }
+func (s *gormStore) GiftCards() GiftCardRepository   { return gormGiftCards{s.db} }
+func (s *gormStore) Promotions() PromotionRepository { return gormPromotions{s.db} }
+func (s *gormStore) Sales() SaleRepository           { return gormSales{s.db} }
+func (s *gormStore) Bundles() BundleRepository       { return gormBundles{s.db} }
+func (s *gormStore) Downloads() DownloadRepository   { return gormDownloads{s.db} }
+func (s *gormStore) Backorders() BackorderRepository { return gormBackorders{s.db} }
+func (s *gormStore) Warehouses() WarehouseRepository { return gormWarehouses{s.db} }
+func (s *gormStore) Addresses() AddressRepository    { return gormAddresses{s.db} }
+func (s *gormStore) Devices() DeviceRepository       { return gormDevices{s.db} }
+func (s *gormStore) Terms() TermsRepository          { return gormTerms{s.db} }
+func (s *gormStore) Flags() FlagRepository           { return gormFlags{s.db} }
+func (s *gormStore) Stores() StoreRepository         { return gormStores{s.db} }
+func (s *gormStore) Outbox() OutboxRepository        { return gormOutbox{s.db} }
+func (s *gormStore) StockSubscriptions() StockSubscriptionRepository {
+	return gormStockSubscriptions{s.db}
+}
+
+// Transaction runs through database.RunTx, which retries transactions the
+// database aborts to serialize them. Nested transactions are savepoints of
+// the enclosing one and are not retried on their own, as the failure
+// aborts the whole transaction.
+func (s *gormStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	run := func(tx *gorm.DB) error {
+		return fn(&gormStore{db: tx, inTx: true})
+	}
+	if s.inTx {
+		return s.db.WithContext(ctx).Transaction(run)
+	}
+	return database.RunTx(ctx, s.db, run)
+}
+
+// notFound maps GORM's missing record error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// conflict returns ErrConflict if a version-checked update matched no row
+func conflict(result *gorm.DB) error {
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return result.Error
+}
+
+// inOrder arranges items in the order of ids, dropping IDs without an item
+func inOrder(ids []uint, items []models.Item) []models.Item {
+	byID := make(map[uint]models.Item, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+	ordered := make([]models.Item, 0, len(items))
+	for _, id := range ids {
+		if item, ok := byID[id]; ok {
+			ordered = append(ordered, item)
+		}
+	}
+	return ordered
+}
+
+// inAllocationOrder sorts warehouse stock by the priority of its
+// warehouse, then by warehouse and item ID
+func inAllocationOrder(stock []models.WarehouseStock) {
+	sort.SliceStable(stock, func(i, j int) bool {
+		a, b := stock[i], stock[j]
+		if a.Warehouse.Priority != b.Warehouse.Priority {
+			return a.Warehouse.Priority < b.Warehouse.Priority
+		}
+		if a.WarehouseID != b.WarehouseID {
+			return a.WarehouseID < b.WarehouseID
+		}
+		return a.ItemID < b.ItemID
+	})
+}
+
+// byID orders preloaded records by ID
+func byID(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
+
+// byOccurrence orders preloaded tracking events oldest first
+func byOccurrence(db *gorm.DB) *gorm.DB {
+	return db.Order("occurred_at, id")
+}
+
+// usernameOnly limits a preloaded user to the fields safe to expose
+func usernameOnly(db *gorm.DB) *gorm.DB {
+	return db.Select("id, username")
+}
+
+type gormUsers struct{ db *gorm.DB }
+
+func (r gormUsers) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r gormUsers) Get(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).First(&user, id).Error
+	return user, notFound(err)
+}
+
+func (r gormUsers) FindByUsername(ctx context.Context, username string) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error
+	return user, notFound(err)
+}
+
+func (r gormUsers) UsernameExists(ctx context.Context, username string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).Where("username = ?", username).Count(&count).Error
+	return count > 0, err
+}
+
+func (r gormUsers) SetVendor(ctx context.Context, userID uint, vendorID *uint, role string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"vendor_id": vendorID, "role": role}).Error
+}
+
+// SetEmail updates from a struct, as the email is encrypted by its
+// serializer, which single-column updates skip
+func (r gormUsers) SetEmail(ctx context.Context, userID uint, email string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).
+		Select("email").Updates(&models.User{Email: email}).Error
+}
+
+func (r gormUsers) SetAvatar(ctx context.Context, userID uint, url string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("avatar_url", url).Error
+}
+
+func (r gormUsers) SetPhone(ctx context.Context, userID uint, phone, codeHash string, sentAt *time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).
+		Select("phone", "phone_verified_at", "phone_code_hash", "phone_code_sent_at", "phone_code_attempts", "sms_opt_in").
+		Updates(&models.User{Phone: phone, PhoneCodeHash: codeHash, PhoneCodeSentAt: sentAt}).Error
+}
+
+func (r gormUsers) FailPhoneCode(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).
+		Update("phone_code_attempts", gorm.Expr("phone_code_attempts + 1")).Error
+}
+
+func (r gormUsers) VerifyPhone(ctx context.Context, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"phone_verified_at":   at,
+		"phone_code_hash":     "",
+		"phone_code_sent_at":  nil,
+		"phone_code_attempts": 0,
+	}).Error
+}
+
+func (r gormUsers) SetSMSOptIn(ctx context.Context, userID uint, optIn bool) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("sms_opt_in", optIn).Error
+}
+
+func (r gormUsers) SetDeactivated(ctx context.Context, userID uint, at *time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("deactivated_at", at).Error
+}
+
+func (r gormUsers) Deactivated(ctx context.Context, before time.Time, limit int) ([]models.User, error) {
+	var users []models.User
+	err := r.db.WithContext(ctx).
+		Where("deactivated_at < ? AND anonymized_at IS NULL", before).
+		Order("deactivated_at ASC, id ASC").
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
+
+func (r gormUsers) Anonymize(ctx context.Context, userID uint, username string, at time.Time) error {
+	db := r.db.WithContext(ctx)
+	err := db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"username":      username,
+		"email":         "",
+		"password_hash": "",
+		"avatar_url":    "",
+		"anonymized_at": at,
+	}).Error
+	if err != nil {
+		return err
+	}
+	// The phone is erased with a struct update, as map updates skip its
+	// encrypting serializer
+	err = db.Model(&models.User{}).Where("id = ?", userID).
+		Select("phone", "phone_verified_at", "phone_code_hash", "phone_code_sent_at", "phone_code_attempts", "sms_opt_in").
+		Updates(&models.User{}).Error
+	if err != nil {
+		return err
+	}
+	if err := db.Model(&models.CartReminder{}).Where("user_id = ?", userID).Update("email", "").Error; err != nil {
+		return err
+	}
+	if err := db.Model(&models.TermsAcceptance{}).Where("user_id = ?", userID).Update("ip", "").Error; err != nil {
+		return err
+	}
+	if err := db.Where("user_id = ?", userID).Delete(&models.DeviceToken{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("user_id = ?", userID).Delete(&models.StockSubscription{}).Error; err != nil {
+		return err
+	}
+	return db.Unscoped().Where("user_id = ?", userID).Delete(&models.Address{}).Error
+}
+
+func (r gormUsers) Each(ctx context.Context, page pagination.Page, fn func(*models.User) error) (string, error) {
+	return pagination.Each(page, r.db.WithContext(ctx).Where("deactivated_at IS NULL"), eachBatchSize,
+		func(user *models.User) uint { return user.ID }, fn)
+}
+
+func (r gormUsers) Locate(ctx context.Context, page pagination.Page) (pagination.Position, error) {
+	return pagination.Locate(page, r.db.WithContext(ctx).Model(&models.User{}).Where("deactivated_at IS NULL"))
+}
+
+// soldStatuses are the order statuses counted in lifetime values
+var soldStatuses = []string{models.OrderCompleted, models.OrderShipped, models.OrderDelivered}
+
+func (r gormUsers) EachSummary(ctx context.Context, page pagination.Page, fn func(*UserSummary) error) (string, error) {
+	query := r.db.WithContext(ctx).Table("users").Where("deactivated_at IS NULL").Select(
+		"users.*, "+
+			"(SELECT COUNT(*) FROM orders WHERE orders.user_id = users.id AND orders.deleted_at IS NULL) AS order_count, "+
+			"(SELECT COALESCE(SUM(orders.total), 0) FROM orders WHERE orders.user_id = users.id "+
+			"AND orders.deleted_at IS NULL AND orders.status IN ?) AS lifetime_value",
+		soldStatuses)
+	return pagination.Each(page, query, eachBatchSize,
+		func(summary *UserSummary) uint { return summary.ID }, fn)
+}
+
+type gormItems struct{ db *gorm.DB }
+
+func (r gormItems) Create(ctx context.Context, item *models.Item) error {
+	return r.db.WithContext(ctx).Create(item).Error
+}
+
+func (r gormItems) Get(ctx context.Context, id uint) (models.Item, error) {
+	var item models.Item
+	err := r.db.WithContext(ctx).First(&item, id).Error
+	return item, notFound(err)
+}
+
+func (r gormItems) GetMany(ctx context.Context, ids []uint) ([]models.Item, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var found []models.Item
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	return inOrder(ids, found), nil
+}
+
+// listed narrows the items queried to those matching the filter
+func (r gormItems) GetBySKU(ctx context.Context, sku string) (models.Item, error) {
+	var item models.Item
+	err := r.db.WithContext(ctx).Where("sku = ?", sku).First(&item).Error
+	return item, notFound(err)
+}
+
+func (r gormItems) Changed(ctx context.Context, after pagination.ChangeCursor, limit int) ([]models.Item, error) {
+	// Times are compared in the zone they were written in, which matters
+	// to SQLite since it compares them as text
+	at := after.At.Local()
+	var items []models.Item
+	err := r.db.WithContext(ctx).
+		Where("sku IS NOT NULL AND (updated_at > ? OR (updated_at = ? AND id > ?))", at, at, after.ID).
+		Order("updated_at, id").Limit(limit).Find(&items).Error
+	return items, err
+}
+
+func (r gormItems) listed(ctx context.Context, filter ItemFilter) *gorm.DB {
+	return r.db.WithContext(ctx).Model(&models.Item{}).Scopes(filter.scopes()...)
+}
+
+// scopes returns the conditions of the filter as GORM scopes
+func (f ItemFilter) scopes() []func(*gorm.DB) *gorm.DB {
+	var scopes []func(*gorm.DB) *gorm.DB
+	where := func(query string, args ...interface{}) {
+		scopes = append(scopes, func(db *gorm.DB) *gorm.DB { return db.Where(query, args...) })
+	}
+	if !f.Inactive {
+		where("is_active = ?", true)
+	}
+	if f.MinPrice != nil {
+		where("price >= ?", *f.MinPrice)
+	}
+	if f.MaxPrice != nil {
+		where("price <= ?", *f.MaxPrice)
+	}
+	if f.InStock {
+		where("stock IS NULL OR stock > 0")
+	}
+	if f.Category != "" {
+		where("category = ?", f.Category)
+	}
+	if f.VendorID != nil {
+		where("vendor_id = ?", *f.VendorID)
+	}
+	if f.Digital != nil {
+		where("digital = ?", *f.Digital)
+	}
+	return scopes
+}
+
+func (r gormItems) List(ctx context.Context, filter ItemFilter, page pagination.Page) ([]models.Item, string, error) {
+	var items []models.Item
+	if err := page.Apply(r.listed(ctx, filter)).Find(&items).Error; err != nil {
+		return nil, "", err
+	}
+	n, next := page.Next(len(items), func(i int) uint { return items[i].ID })
+	return items[:n], next, nil
+}
+
+func (r gormItems) Locate(ctx context.Context, filter ItemFilter, page pagination.Page) (pagination.Position, error) {
+	return pagination.Locate(page, r.listed(ctx, filter))
+}
+
+func (r gormItems) Each(ctx context.Context, filter ItemFilter, page pagination.Page, fn func(*models.Item) error) (string, error) {
+	return pagination.Each(page, r.listed(ctx, filter), eachBatchSize,
+		func(item *models.Item) uint { return item.ID }, fn)
+}
+
+func (r gormItems) Search(ctx context.Context, q search.Query) (search.Result, error) {
+	db := r.db.WithContext(ctx)
+	pattern := "%" + strings.ToLower(q.Text) + "%"
+	matching := func() *gorm.DB {
+		query := db.Model(&models.Item{}).Where("is_active = ?", true)
+		if q.Text != "" {
+			query = query.Where("LOWER(name) LIKE ? OR LOWER(category) LIKE ? OR LOWER(description) LIKE ?", pattern, pattern, pattern)
+		}
+		return query
+	}
+
+	var result search.Result
+	err := matching().Select("category, COUNT(*) AS count").Where("category <> ''").
+		Group("category").Order("count DESC, category").Limit(maxCategoryFacets).
+		Scan(&result.Facets.Categories).Error
+	if err != nil {
+		return search.Result{}, err
+	}
+
+	// Each price falls in the first range it is below the end of
+	bucket := "CASE"
+	for i, pr := range search.PriceRanges {
+		if pr.To != 0 {
+			bucket += fmt.Sprintf(" WHEN price < %g THEN %d", pr.To, i)
+		}
+	}
+	bucket += fmt.Sprintf(" ELSE %d END", len(search.PriceRanges)-1)
+	var buckets []struct {
+		Bucket int
+		Count  int64
+	}
+	if err := matching().Select(bucket + " AS bucket, COUNT(*) AS count").Group("bucket").Scan(&buckets).Error; err != nil {
+		return search.Result{}, err
+	}
+	result.Facets.Prices = make([]search.PriceCount, len(search.PriceRanges))
+	for i, pr := range search.PriceRanges {
+		result.Facets.Prices[i].PriceRange = pr
+	}
+	for _, b := range buckets {
+		result.Facets.Prices[b.Bucket].Count = b.Count
+	}
+
+	filtered := matching()
+	if q.Category != "" {
+		filtered = filtered.Where("category = ?", q.Category)
+	}
+	if q.MinPrice != nil {
+		filtered = filtered.Where("price >= ?", *q.MinPrice)
+	}
+	if q.MaxPrice != nil {
+		filtered = filtered.Where("price <= ?", *q.MaxPrice)
+	}
+	if err := filtered.Session(&gorm.Session{}).Count(&result.Total).Error; err != nil {
+		return search.Result{}, err
+	}
+
+	if q.Text != "" {
+		filtered = filtered.Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "CASE WHEN LOWER(name) LIKE ? THEN 0 ELSE 1 END",
+			Vars:               []interface{}{pattern},
+			WithoutParentheses: true,
+		}})
+	}
+	err = filtered.Order("id ASC").Offset(q.Offset).Limit(q.Limit).Pluck("id", &result.IDs).Error
+	return result, err
+}
+
+func (r gormItems) ReserveStock(ctx context.Context, id uint, quantity int) (bool, error) {
+	// Untracked stock stays NULL; updated_at changes either way, so the
+	// row counts as affected on every database
+	result := r.db.WithContext(ctx).Model(&models.Item{}).
+		Where("id = ? AND (stock IS NULL OR stock >= ?)", id, quantity).
+		Updates(map[string]interface{}{"stock": gorm.Expr("stock - ?", quantity), "version": gorm.Expr("version + 1")})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r gormItems) UpdateInventory(ctx context.Context, id uint, version int, stock *int, threshold int) error {
+	result := r.db.WithContext(ctx).Model(&models.Item{}).Where("id = ? AND version = ?", id, version).
+		Updates(map[string]interface{}{"stock": stock, "low_stock_threshold": threshold, "version": gorm.Expr("version + 1")})
+	return conflict(result)
+}
+
+func (r gormItems) LowStock(ctx context.Context) ([]models.Item, error) {
+	var items []models.Item
+	err := r.db.WithContext(ctx).
+		Where("stock IS NOT NULL AND low_stock_threshold > 0 AND stock <= low_stock_threshold").
+		Order("stock ASC, id ASC").
+		Find(&items).Error
+	return items, err
+}
+
+func (r gormItems) SetBackorder(ctx context.Context, id uint, version int, mode string, expectedAt *time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.Item{}).Where("id = ? AND version = ?", id, version).
+		Updates(map[string]interface{}{"backorder": mode, "expected_at": expectedAt, "version": gorm.Expr("version + 1")})
+	return conflict(result)
+}
+
+func (r gormItems) SetPrice(ctx context.Context, id uint, version int, price float64, compareAt *float64) error {
+	result := r.db.WithContext(ctx).Model(&models.Item{}).Where("id = ? AND version = ?", id, version).
+		Updates(map[string]interface{}{"price": price, "compare_at_price": compareAt, "version": gorm.Expr("version + 1")})
+	return conflict(result)
+}
+
+func (r gormItems) AddMovements(ctx context.Context, movements []models.InventoryMovement) error {
+	if len(movements) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(&movements).Error
+}
+
+func (r gormItems) Movements(ctx context.Context, itemID uint, reason string, page pagination.Page) ([]models.InventoryMovement, string, error) {
+	query := r.db.WithContext(ctx).Where("item_id = ?", itemID)
+	if reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+	var movements []models.InventoryMovement
+	if err := page.Apply(query).Find(&movements).Error; err != nil {
+		return nil, "", err
+	}
+	n, next := page.Next(len(movements), func(i int) uint { return movements[i].ID })
+	return movements[:n], next, nil
+}
+
+func (r gormItems) SetQuantityLimits(ctx context.Context, id uint, version int, minQuantity, maxQuantity int) error {
+	result := r.db.WithContext(ctx).Model(&models.Item{}).Where("id = ? AND version = ?", id, version).
+		Updates(map[string]interface{}{"min_quantity": minQuantity, "max_quantity": maxQuantity, "version": gorm.Expr("version + 1")})
+	return conflict(result)
+}
+
+func (r gormItems) SetInternal(ctx context.Context, id uint, version int, costPrice *float64, notes string) error {
+	result := r.db.WithContext(ctx).Model(&models.Item{}).Where("id = ? AND version = ?", id, version).
+		Updates(map[string]interface{}{"cost_price": costPrice, "internal_notes": notes, "version": gorm.Expr("version + 1")})
+	return conflict(result)
+}
+
+func (r gormItems) SetActive(ctx context.Context, id uint, version int, active bool) error {
+	result := r.db.WithContext(ctx).Model(&models.Item{}).Where("id = ? AND version = ?", id, version).
+		Updates(map[string]interface{}{"is_active": active, "version": gorm.Expr("version + 1")})
+	return conflict(result)
+}
+
+type gormCarts struct{ db *gorm.DB }
+
+func (r gormCarts) Create(ctx context.Context, cart *models.Cart) error {
+	return r.db.WithContext(ctx).Create(cart).Error
+}
+
+func (r gormCarts) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.Cart{}, id).Error
+}
+
+func (r gormCarts) Get(ctx context.Context, id uint) (models.Cart, error) {
+	var cart models.Cart
+	err := r.db.WithContext(ctx).Preload("CartItems.Item").First(&cart, id).Error
+	return cart, notFound(err)
+}
+
+func (r gormCarts) OpenCarts(ctx context.Context, userID uint) ([]models.Cart, error) {
+	var carts []models.Cart
+	err := r.db.WithContext(ctx).Preload("CartItems").
+		Where("user_id = ? AND is_checked_out = ?", userID, false).
+		Order("id ASC").
+		Find(&carts).Error
+	return carts, err
+}
+
+func (r gormCarts) OpenCart(ctx context.Context, userID uint) (models.Cart, error) {
+	var cart models.Cart
+	err := r.db.WithContext(ctx).Preload("CartItems.Item").
+		Where("user_id = ? AND is_checked_out = ?", userID, false).
+		First(&cart).Error
+	return cart, notFound(err)
+}
+
+func (r gormCarts) LockOpenCart(ctx context.Context, userID uint) (models.Cart, error) {
+	var cart models.Cart
+	err := r.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND is_checked_out = ?", userID, false).
+		First(&cart).Error
+	if err != nil {
+		return cart, notFound(err)
+	}
+	err = r.db.WithContext(ctx).Preload("Item").Where("cart_id = ?", cart.ID).Find(&cart.CartItems).Error
+	return cart, err
+}
+
+func (r gormCarts) MarkCheckedOut(ctx context.Context, cart *models.Cart, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.Cart{}).Where("id = ? AND version = ?", cart.ID, cart.Version).
+		Updates(map[string]interface{}{"is_checked_out": true, "checked_out_at": at, "version": gorm.Expr("version + 1")})
+	if err := conflict(result); err != nil {
+		return err
+	}
+	for i := range cart.CartItems {
+		ci := &cart.CartItems[i]
+		ci.Name, ci.UnitPrice = ci.Item.Name, ci.Item.Price
+		err := r.db.WithContext(ctx).Model(&models.CartItem{}).Where("id = ?", ci.ID).
+			Updates(map[string]interface{}{"name": ci.Name, "unit_price": ci.UnitPrice}).Error
+		if err != nil {
+			return err
+		}
+	}
+	cart.IsCheckedOut = true
+	cart.CheckedOutAt = &at
+	cart.Version++
+	return nil
+}
+
+func (r gormCarts) MarkCheckoutStarted(ctx context.Context, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Cart{}).
+		Where("user_id = ? AND is_checked_out = ? AND checkout_started_at IS NULL", userID, false).
+		UpdateColumn("checkout_started_at", at).Error
+}
+
+func (r gormCarts) Touch(ctx context.Context, cart *models.Cart, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.Cart{}).Where("id = ? AND version = ?", cart.ID, cart.Version).
+		Updates(map[string]interface{}{"updated_at": at, "version": gorm.Expr("version + 1")})
+	if err := conflict(result); err != nil {
+		return err
+	}
+	cart.UpdatedAt = at
+	cart.Version++
+	return nil
+}
+
+func (r gormCarts) Abandoned(ctx context.Context, idleSince, remindedSince time.Time, limit int) ([]models.Cart, error) {
+	var carts []models.Cart
+	err := r.db.WithContext(ctx).Preload("User").Preload("CartItems.Item").
+		Where("is_checked_out = ? AND updated_at < ?", false, idleSince).
+		Where("EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.id AND cart_items.deleted_at IS NULL)").
+		Where("EXISTS (SELECT 1 FROM users WHERE users.id = carts.user_id AND users.email <> '' AND users.deactivated_at IS NULL)").
+		Where("NOT EXISTS (SELECT 1 FROM cart_reminders WHERE cart_reminders.cart_id = carts.id)").
+		Where("NOT EXISTS (SELECT 1 FROM cart_reminders WHERE cart_reminders.user_id = carts.user_id AND cart_reminders.created_at > ?)", remindedSince).
+		Order("updated_at ASC, id ASC").
+		Limit(limit).
+		Find(&carts).Error
+	return carts, err
+}
+
+func (r gormCarts) RecordReminder(ctx context.Context, reminder *models.CartReminder) error {
+	return r.db.WithContext(ctx).Create(reminder).Error
+}
+
+func (r gormCarts) FindItem(ctx context.Context, cartID, itemID uint, bundleID *uint) (models.CartItem, error) {
+	var cartItem models.CartItem
+	query := r.db.WithContext(ctx).Where("cart_id = ? AND item_id = ?", cartID, itemID)
+	if bundleID != nil {
+		query = query.Where("bundle_id = ?", *bundleID)
+	} else {
+		query = query.Where("bundle_id IS NULL")
+	}
+	err := query.First(&cartItem).Error
+	return cartItem, notFound(err)
+}
+
+func (r gormCarts) CreateItem(ctx context.Context, cartItem *models.CartItem) error {
+	return r.db.WithContext(ctx).Create(cartItem).Error
+}
+
+func (r gormCarts) UpdateQuantity(ctx context.Context, cartItem *models.CartItem) error {
+	result := r.db.WithContext(ctx).Model(&models.CartItem{}).Where("id = ? AND version = ?", cartItem.ID, cartItem.Version).
+		Updates(map[string]interface{}{"quantity": cartItem.Quantity, "version": gorm.Expr("version + 1")})
+	if err := conflict(result); err != nil {
+		return err
+	}
+	cartItem.Version++
+	return nil
+}
+
+func (r gormCarts) MoveItem(ctx context.Context, cartItemID, cartID uint) error {
+	return r.db.WithContext(ctx).Model(&models.CartItem{}).Where("id = ?", cartItemID).Update("cart_id", cartID).Error
+}
+
+func (r gormCarts) DeleteItem(ctx context.Context, cartItemID uint) error {
+	return r.db.WithContext(ctx).Delete(&models.CartItem{}, cartItemID).Error
+}
+
+func (r gormCarts) Each(ctx context.Context, page pagination.Page, fn func(*models.Cart) error) (string, error) {
+	query := r.db.WithContext(ctx).Preload("User", usernameOnly).Preload("CartItems.Item")
+	return pagination.Each(page, query, eachBatchSize,
+		func(cart *models.Cart) uint { return cart.ID }, fn)
+}
+
+func (r gormCarts) Locate(ctx context.Context, page pagination.Page) (pagination.Position, error) {
+	return pagination.Locate(page, r.db.WithContext(ctx).Model(&models.Cart{}))
+}
+
+type gormOrders struct{ db *gorm.DB }
+
+func (r gormOrders) Create(ctx context.Context, order *models.Order) error {
+	return r.db.WithContext(ctx).Create(order).Error
+}
+
+func (r gormOrders) Get(ctx context.Context, id uint) (models.Order, error) {
+	var order models.Order
+	err := r.db.WithContext(ctx).First(&order, id).Error
+	return order, notFound(err)
+}
+
+func (r gormOrders) GetDetail(ctx context.Context, id uint) (models.Order, error) {
+	var order models.Order
+	err := r.db.WithContext(ctx).Preload("Cart.CartItems.Item").Preload("Promotions", byID).
+		Preload("Sales", byID).Preload("Bundles", byID).Preload("Downloads", byID).Preload("Backorders", byID).
+		Preload("Allocations", byID).Preload("Shipments", byID).Preload("Shipments.Events", byOccurrence).
+		First(&order, id).Error
+	return order, notFound(err)
+}
+
+func (r gormOrders) UpdateStatus(ctx context.Context, id uint, status string) error {
+	return r.db.WithContext(ctx).Model(&models.Order{}).Where("id = ?", id).Update("status", status).Error
+}
+
+func (r gormOrders) NumberExists(ctx context.Context, number string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Order{}).Where("number = ?", number).Count(&count).Error
+	return count > 0, err
+}
+
+// filtered narrows the orders queried to those matching the filter
+func (r gormOrders) filtered(ctx context.Context, filter OrderFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&models.Order{})
+	if filter.Number != "" {
+		query = query.Where("number = ?", filter.Number)
+	}
+	for key, value := range filter.Metadata {
+		expr, path := metadataExpr(r.db.Dialector.Name(), key)
+		query = query.Where(expr+" = ?", path, value)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+	if filter.NeedsReview {
+		query = query.Where("duplicate_of_id IS NOT NULL AND reviewed_at IS NULL")
+	}
+	return query
+}
+
+// metadataExpr returns the expression reading a key of the orders'
+// metadata in the dialect, and its path argument
+func metadataExpr(dialect, key string) (string, string) {
+	switch dialect {
+	case "postgres":
+		return "CAST(metadata AS jsonb) ->> ?", key
+	case "mysql":
+		return "JSON_UNQUOTE(JSON_EXTRACT(metadata, ?))", `$."` + key + `"`
+	default:
+		return "json_extract(metadata, ?)", `$."` + key + `"`
+	}
+}
+
+func (r gormOrders) ListByUser(ctx context.Context, userID uint, filter OrderFilter, page pagination.Keyset) ([]models.Order, pagination.Cursors, error) {
+	var orders []models.Order
+	err := page.Apply(r.filtered(ctx, filter)).Preload("Cart.CartItems.Item").Preload("Promotions", byID).
+		Where("user_id = ?", userID).
+		Find(&orders).Error
+	if err != nil {
+		return nil, pagination.Cursors{}, err
+	}
+	orders, cursors := pagination.Trim(page, orders, orderKey)
+	return orders, cursors, nil
+}
+
+func (r gormOrders) Summaries(ctx context.Context, userID uint, filter OrderFilter, page pagination.Keyset) ([]models.Order, pagination.Cursors, error) {
+	var orders []models.Order
+	if err := page.Apply(r.filtered(ctx, filter)).Where("user_id = ?", userID).Find(&orders).Error; err != nil {
+		return nil, pagination.Cursors{}, err
+	}
+	orders, cursors := pagination.Trim(page, orders, orderKey)
+	return orders, cursors, nil
+}
+
+// orderKey returns the keys orders are paged by in order histories
+func orderKey(order *models.Order) (time.Time, uint) {
+	return order.CreatedAt, order.ID
+}
+
+func (r gormOrders) Each(ctx context.Context, filter OrderFilter, page pagination.Page, fn func(*models.Order) error) (string, error) {
+	query := r.filtered(ctx, filter).Preload("User", usernameOnly).Preload("Promotions", byID)
+	return pagination.EachBatch(page, query, orderBatchSize,
+		func(order *models.Order) uint { return order.ID },
+		func(orders []models.Order) error { return r.loadLines(ctx, orders) }, fn)
+}
+
+// orderLine is a cart item of an order joined with the item fields shown in
+// order listings. ItemRef is nil once the item has been deleted.
+type orderLine struct {
+	ID             uint
+	CartID         uint
+	ItemID         uint
+	Quantity       int
+	BundleID       *uint
+	ItemRef        *uint
+	Name           string
+	Description    string
+	Price          float64
+	CompareAtPrice *float64
+}
+
+// loadLines sets the cart items of a batch of orders, with their items,
+// from a single query rather than preloading carts, cart items and items
+// one after the other
+func (r gormOrders) loadLines(ctx context.Context, orders []models.Order) error {
+	cartIDs := make([]uint, len(orders))
+	for i := range orders {
+		cartIDs[i] = orders[i].CartID
+	}
+
+	var lines []orderLine
+	err := r.db.WithContext(ctx).Table("cart_items").
+		Select("cart_items.id, cart_items.cart_id, cart_items.item_id, cart_items.quantity, cart_items.bundle_id, "+
+			"items.id AS item_ref, COALESCE(items.name, '') AS name, COALESCE(items.description, '') AS description, "+
+			"COALESCE(items.price, 0) AS price, items.compare_at_price").
+		Joins("LEFT JOIN items ON items.id = cart_items.item_id AND items.deleted_at IS NULL").
+		Where("cart_items.cart_id IN ? AND cart_items.deleted_at IS NULL", cartIDs).
+		Order("cart_items.id").
+		Scan(&lines).Error
+	if err != nil {
+		return err
+	}
+
+	byCart := make(map[uint][]models.CartItem, len(orders))
+	for _, line := range lines {
+		item := models.CartItem{CartID: line.CartID, ItemID: line.ItemID, Quantity: line.Quantity, BundleID: line.BundleID}
+		item.ID = line.ID
+		if line.ItemRef != nil {
+			item.Item.ID = *line.ItemRef
+			item.Item.Name = line.Name
+			item.Item.Description = line.Description
+			item.Item.Price = line.Price
+			item.Item.CompareAtPrice = line.CompareAtPrice
+		}
+		byCart[line.CartID] = append(byCart[line.CartID], item)
+	}
+	for i := range orders {
+		orders[i].Cart.ID = orders[i].CartID
+		orders[i].Cart.UserID = orders[i].UserID
+		orders[i].Cart.CartItems = byCart[orders[i].CartID]
+	}
+	return nil
+}
+
+func (r gormOrders) Locate(ctx context.Context, userID uint, filter OrderFilter, page pagination.Page) (pagination.Position, error) {
+	query := r.filtered(ctx, filter)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	return pagination.Locate(page, query)
+}
+
+func (r gormOrders) Purchased(ctx context.Context, userID uint, itemIDs []uint) (map[uint]int, error) {
+	var rows []struct {
+		ItemID   uint
+		Quantity int
+	}
+	err := r.db.WithContext(ctx).Model(&models.Order{}).
+		Select("cart_items.item_id, SUM(cart_items.quantity) AS quantity").
+		Joins("JOIN cart_items ON cart_items.cart_id = orders.cart_id AND cart_items.deleted_at IS NULL").
+		Where("orders.user_id = ? AND orders.status <> ? AND cart_items.item_id IN ?", userID, models.OrderCancelled, itemIDs).
+		Group("cart_items.item_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	purchased := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		purchased[row.ItemID] = row.Quantity
+	}
+	return purchased, nil
+}
+
+func (r gormOrders) Recent(ctx context.Context, userID uint, since time.Time) ([]models.Order, error) {
+	var orders []models.Order
+	err := r.db.WithContext(ctx).Preload("Cart.CartItems").
+		Where("user_id = ? AND status <> ? AND created_at >= ?", userID, models.OrderCancelled, since).
+		Order("created_at DESC, id DESC").Find(&orders).Error
+	return orders, err
+}
+
+func (r gormOrders) MarkReviewed(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.Order{}).Where("id = ?", id).Update("reviewed_at", at).Error
+}
+
+func (r gormOrders) CreateSubOrder(ctx context.Context, sub *models.SubOrder) error {
+	return r.db.WithContext(ctx).Create(sub).Error
+}
+
+func (r gormOrders) GetSubOrder(ctx context.Context, id uint) (models.SubOrder, error) {
+	var sub models.SubOrder
+	err := r.db.WithContext(ctx).First(&sub, id).Error
+	return sub, notFound(err)
+}
+
+func (r gormOrders) UpdateSubOrderStatus(ctx context.Context, id uint, status string) error {
+	return r.db.WithContext(ctx).Model(&models.SubOrder{}).Where("id = ?", id).Update("status", status).Error
+}
+
+func (r gormOrders) ListSubOrders(ctx context.Context, vendorID uint, page pagination.Page) ([]models.SubOrder, string, error) {
+	var subs []models.SubOrder
+	err := page.Apply(r.db.WithContext(ctx)).Preload("Order.Cart.CartItems.Item").
+		Where("vendor_id = ?", vendorID).
+		Find(&subs).Error
+	if err != nil {
+		return nil, "", err
+	}
+	n, next := page.Next(len(subs), func(i int) uint { return subs[i].ID })
+	return subs[:n], next, nil
+}
+
+type gormVendors struct{ db *gorm.DB }
+
+func (r gormVendors) Create(ctx context.Context, vendor *models.Vendor) error {
+	return r.db.WithContext(ctx).Create(vendor).Error
+}
+
+func (r gormVendors) Get(ctx context.Context, id uint) (models.Vendor, error) {
+	var vendor models.Vendor
+	err := r.db.WithContext(ctx).First(&vendor, id).Error
+	return vendor, notFound(err)
+}
+
+func (r gormVendors) NameExists(ctx context.Context, name string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Vendor{}).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+func (r gormVendors) List(ctx context.Context) ([]models.Vendor, error) {
+	var vendors []models.Vendor
+	err := r.db.WithContext(ctx).Order("name").Find(&vendors).Error
+	return vendors, err
+}
+
+type gormShipping struct{ db *gorm.DB }
+
+func (r gormShipping) Create(ctx context.Context, method *models.ShippingMethod) error {
+	return r.db.WithContext(ctx).Create(method).Error
+}
+
+func (r gormShipping) Get(ctx context.Context, id uint) (models.ShippingMethod, error) {
+	var method models.ShippingMethod
+	err := r.db.WithContext(ctx).First(&method, id).Error
+	return method, notFound(err)
+}
+
+func (r gormShipping) List(ctx context.Context) ([]models.ShippingMethod, error) {
+	var methods []models.ShippingMethod
+	err := r.db.WithContext(ctx).Order("id").Find(&methods).Error
+	return methods, err
+}
+
+func (r gormShipping) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.ShippingMethod{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+type gormDelivery struct{ db *gorm.DB }
+
+func (r gormDelivery) CreateSlot(ctx context.Context, slot *models.DeliverySlot) error {
+	return r.db.WithContext(ctx).Create(slot).Error
+}
+
+func (r gormDelivery) GetSlot(ctx context.Context, id uint) (models.DeliverySlot, error) {
+	var slot models.DeliverySlot
+	err := r.db.WithContext(ctx).First(&slot, id).Error
+	return slot, notFound(err)
+}
+
+func (r gormDelivery) ListSlots(ctx context.Context) ([]models.DeliverySlot, error) {
+	var slots []models.DeliverySlot
+	err := r.db.WithContext(ctx).Order("start_time, end_time, id").Find(&slots).Error
+	return slots, err
+}
+
+func (r gormDelivery) DeleteSlot(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.DeliverySlot{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+func (r gormDelivery) Bookings(ctx context.Context, from, to string) ([]models.DeliveryBooking, error) {
+	var bookings []models.DeliveryBooking
+	err := r.db.WithContext(ctx).Where("date >= ? AND date <= ?", from, to).Order("date, slot_id").Find(&bookings).Error
+	return bookings, err
+}
+
+// Book counts the order with a conditional update, so concurrent checkouts
+// cannot overbook the slot
+func (r gormDelivery) Book(ctx context.Context, slot models.DeliverySlot, date string) (bool, error) {
+	db := r.db.WithContext(ctx)
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "slot_id"}, {Name: "date"}},
+		DoNothing: true,
+	}).Create(&models.DeliveryBooking{SlotID: slot.ID, Date: date}).Error
+	if err != nil {
+		return false, err
+	}
+	result := db.Model(&models.DeliveryBooking{}).
+		Where("slot_id = ? AND date = ? AND booked < ?", slot.ID, date, slot.Capacity).
+		Update("booked", gorm.Expr("booked + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r gormDelivery) Release(ctx context.Context, slotID uint, date string) error {
+	return r.db.WithContext(ctx).Model(&models.DeliveryBooking{}).
+		Where("slot_id = ? AND date = ? AND booked > 0", slotID, date).
+		Update("booked", gorm.Expr("booked - 1")).Error
+}
+
+type gormStores struct{ db *gorm.DB }
+
+func (r gormStores) Get(ctx context.Context, id uint) (models.Store, error) {
+	var store models.Store
+	err := r.db.WithContext(ctx).First(&store, id).Error
+	return store, notFound(err)
+}
+
+func (r gormStores) UpdateDuplicateOrders(ctx context.Context, id uint, policy string, minutes int) error {
+	result := r.db.WithContext(ctx).Model(&models.Store{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"duplicate_order_policy":  policy,
+		"duplicate_order_minutes": minutes,
+	})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+type gormFlags struct{ db *gorm.DB }
+
+func (r gormFlags) Create(ctx context.Context, flag *models.FeatureFlag) error {
+	return r.db.WithContext(ctx).Create(flag).Error
+}
+
+func (r gormFlags) Get(ctx context.Context, name string) (models.FeatureFlag, error) {
+	var flag models.FeatureFlag
+	err := r.db.WithContext(ctx).Where("name = ?", name).First(&flag).Error
+	return flag, notFound(err)
+}
+
+func (r gormFlags) List(ctx context.Context) ([]models.FeatureFlag, error) {
+	var flags []models.FeatureFlag
+	err := r.db.WithContext(ctx).Order("name").Find(&flags).Error
+	return flags, err
+}
+
+func (r gormFlags) Update(ctx context.Context, flag *models.FeatureFlag) error {
+	return r.db.WithContext(ctx).Save(flag).Error
+}
+
+func (r gormFlags) Delete(ctx context.Context, name string) error {
+	result := r.db.WithContext(ctx).Unscoped().Where("name = ?", name).Delete(&models.FeatureFlag{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+type gormOutbox struct{ db *gorm.DB }
+
+func (r gormOutbox) Add(ctx context.Context, msg *models.OutboxMessage) error {
+	return r.db.WithContext(ctx).Create(msg).Error
+}
+
+// Claim holds each message with an update conditioned on its attempts, so
+// of relays claiming the same message only one succeeds
+func (r gormOutbox) Claim(ctx context.Context, channel string, now time.Time, lease time.Duration, limit int) ([]models.OutboxMessage, error) {
+	db := r.db.WithContext(ctx)
+	query := db.Where("delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ? AND channel = ?", now, channel)
+	var due []models.OutboxMessage
+	if err := query.Order("next_attempt_at, id").Limit(limit).Find(&due).Error; err != nil {
+		return nil, err
+	}
+
+	claimed := due[:0]
+	for _, msg := range due {
+		result := db.Model(&models.OutboxMessage{}).
+			Where("id = ? AND attempts = ?", msg.ID, msg.Attempts).
+			Updates(map[string]interface{}{
+				"attempts":        gorm.Expr("attempts + 1"),
+				"next_attempt_at": now.Add(lease),
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			msg.Attempts++
+			claimed = append(claimed, msg)
+		}
+	}
+	return claimed, nil
+}
+
+func (r gormOutbox) Delivered(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"delivered_at": at, "last_error": ""}).Error
+}
+
+func (r gormOutbox) Retry(ctx context.Context, id uint, reason string, retryAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"next_attempt_at": retryAt, "last_error": reason}).Error
+}
+
+func (r gormOutbox) GiveUp(ctx context.Context, id uint, reason string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.OutboxMessage{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"failed_at": at, "last_error": reason}).Error
+}
+
+func (r gormOutbox) WebhookKeys(ctx context.Context, storeID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.WithContext(ctx).
+		Joins("JOIN users ON users.id = api_keys.user_id AND users.role = ?", models.RoleAdmin).
+		Where("api_keys.store_id = ? AND api_keys.sandbox = ? AND api_keys.webhook_url <> ''", storeID, false).
+		Order("api_keys.id").
+		Find(&keys).Error
+	return keys, err
+}
+
+func (r gormOutbox) WebhookKey(ctx context.Context, id uint) (models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.WithContext(ctx).First(&key, id).Error
+	return key, notFound(err)
+}
+
+type gormAddresses struct{ db *gorm.DB }
+
+func (r gormAddresses) Create(ctx context.Context, address *models.Address) error {
+	return r.db.WithContext(ctx).Create(address).Error
+}
+
+func (r gormAddresses) Get(ctx context.Context, id uint) (models.Address, error) {
+	var address models.Address
+	err := r.db.WithContext(ctx).First(&address, id).Error
+	return address, notFound(err)
+}
+
+func (r gormAddresses) ListByUser(ctx context.Context, userID uint) ([]models.Address, error) {
+	var addresses []models.Address
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&addresses).Error
+	return addresses, err
+}
+
+func (r gormAddresses) SetStatus(ctx context.Context, id uint, status, reason string) error {
+	return r.db.WithContext(ctx).Model(&models.Address{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"status": status, "status_reason": reason}).Error
+}
+
+// Delete removes the address for good, as it is personal data; orders keep
+// their own copy
+func (r gormAddresses) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Unscoped().Delete(&models.Address{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+type gormDevices struct{ db *gorm.DB }
+
+func (r gormDevices) Register(ctx context.Context, device *models.DeviceToken) error {
+	db := r.db.WithContext(ctx)
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "store_id"}, {Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "platform", "last_seen_at"}),
+	}).Create(device).Error
+	if err != nil {
+		return err
+	}
+	// The ID is not reported for rows updated on conflict by every database
+	return db.Where("token = ?", device.Token).First(device).Error
+}
+
+func (r gormDevices) Get(ctx context.Context, id uint) (models.DeviceToken, error) {
+	var device models.DeviceToken
+	err := r.db.WithContext(ctx).First(&device, id).Error
+	return device, notFound(err)
+}
+
+func (r gormDevices) ListByUser(ctx context.Context, userID uint) ([]models.DeviceToken, error) {
+	var devices []models.DeviceToken
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&devices).Error
+	return devices, err
+}
+
+func (r gormDevices) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.DeviceToken{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+type gormTerms struct{ db *gorm.DB }
+
+func (r gormTerms) Accept(ctx context.Context, acceptances []models.TermsAcceptance) error {
+	return r.db.WithContext(ctx).Create(&acceptances).Error
+}
+
+func (r gormTerms) ListByUser(ctx context.Context, userID uint) ([]models.TermsAcceptance, error) {
+	var acceptances []models.TermsAcceptance
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id DESC").Find(&acceptances).Error
+	return acceptances, err
+}
+
+type gormStockSubscriptions struct{ db *gorm.DB }
+
+func (r gormStockSubscriptions) Subscribe(ctx context.Context, sub *models.StockSubscription) error {
+	db := r.db.WithContext(ctx)
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "item_id"}},
+		DoNothing: true,
+	}).Create(sub).Error
+	if err != nil {
+		return err
+	}
+	return db.Where("user_id = ? AND item_id = ?", sub.UserID, sub.ItemID).First(sub).Error
+}
+
+func (r gormStockSubscriptions) Unsubscribe(ctx context.Context, userID, itemID uint) error {
+	result := r.db.WithContext(ctx).Where("user_id = ? AND item_id = ?", userID, itemID).Delete(&models.StockSubscription{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+func (r gormStockSubscriptions) ListByUser(ctx context.Context, userID uint) ([]models.StockSubscription, error) {
+	var subs []models.StockSubscription
+	err := r.db.WithContext(ctx).Preload("Item").Where("user_id = ?", userID).Order("id").Find(&subs).Error
+	return subs, err
+}
+
+func (r gormStockSubscriptions) Restocked(ctx context.Context, limit int) ([]models.StockSubscription, error) {
+	var subs []models.StockSubscription
+	err := r.db.WithContext(ctx).Preload("Item").Preload("User").
+		Where("EXISTS (SELECT 1 FROM items WHERE items.id = stock_subscriptions.item_id AND items.deleted_at IS NULL "+
+			"AND items.is_active = ? AND (items.stock IS NULL OR items.stock > 0 OR items.backorder <> ''))", true).
+		Order("id").
+		Limit(limit).
+		Find(&subs).Error
+	return subs, err
+}
+
+func (r gormStockSubscriptions) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.StockSubscription{}, id).Error
+}
+
+func (r gormStockSubscriptions) DeleteOrphaned(ctx context.Context) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("NOT EXISTS (SELECT 1 FROM items WHERE items.id = stock_subscriptions.item_id AND items.deleted_at IS NULL)").
+		Delete(&models.StockSubscription{})
+	return result.RowsAffected, result.Error
+}
+
+type gormPromotions struct{ db *gorm.DB }
+
+func (r gormPromotions) Create(ctx context.Context, promotion *models.Promotion) error {
+	return r.db.WithContext(ctx).Create(promotion).Error
+}
+
+func (r gormPromotions) List(ctx context.Context) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	err := r.db.WithContext(ctx).Order("id").Find(&promotions).Error
+	return promotions, err
+}
+
+func (r gormPromotions) Active(ctx context.Context, at time.Time) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	err := r.db.WithContext(ctx).
+		Where("(starts_at IS NULL OR starts_at <= ?) AND (ends_at IS NULL OR ends_at > ?)", at, at).
+		Order("id").Find(&promotions).Error
+	return promotions, err
+}
+
+func (r gormPromotions) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.Promotion{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+type gormSales struct{ db *gorm.DB }
+
+func (r gormSales) Create(ctx context.Context, sale *models.Sale) error {
+	return r.db.WithContext(ctx).Create(sale).Error
+}
+
+func (r gormSales) List(ctx context.Context) ([]models.Sale, error) {
+	var sales []models.Sale
+	err := r.db.WithContext(ctx).Order("id").Find(&sales).Error
+	return sales, err
+}
+
+func (r gormSales) Active(ctx context.Context) ([]models.Sale, error) {
+	var sales []models.Sale
+	err := r.db.WithContext(ctx).Where("active = ?", true).Order("id").Find(&sales).Error
+	return sales, err
+}
+
+func (r gormSales) Schedule(ctx context.Context, at time.Time) (int64, error) {
+	var changed int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		started := tx.Model(&models.Sale{}).
+			Where("active = ? AND starts_at <= ? AND ends_at > ?", false, at, at).
+			Update("active", true)
+		if started.Error != nil {
+			return started.Error
+		}
+		ended := tx.Model(&models.Sale{}).
+			Where("active = ? AND (starts_at > ? OR ends_at <= ?)", true, at, at).
+			Update("active", false)
+		changed = started.RowsAffected + ended.RowsAffected
+		return ended.Error
+	})
+	return changed, err
+}
+
+func (r gormSales) Purchased(ctx context.Context, userID uint, saleIDs []uint) (map[uint]int, error) {
+	var rows []struct {
+		SaleID   uint
+		Quantity int
+	}
+	err := r.db.WithContext(ctx).Model(&models.SalePurchase{}).
+		Select("sale_id, SUM(quantity) AS quantity").
+		Where("user_id = ? AND sale_id IN ?", userID, saleIDs).
+		Group("sale_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	purchased := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		purchased[row.SaleID] = row.Quantity
+	}
+	return purchased, nil
+}
+
+func (r gormSales) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.Sale{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+type gormBundles struct{ db *gorm.DB }
+
+func (r gormBundles) Create(ctx context.Context, bundle *models.Bundle) error {
+	return r.db.WithContext(ctx).Omit("Items.Item").Create(bundle).Error
+}
+
+// withItems preloads the items of bundles and their details
+func (r gormBundles) withItems(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Preload("Items", byID).Preload("Items.Item")
+}
+
+func (r gormBundles) Get(ctx context.Context, id uint) (models.Bundle, error) {
+	var bundle models.Bundle
+	err := r.withItems(ctx).First(&bundle, id).Error
+	return bundle, notFound(err)
+}
+
+func (r gormBundles) GetMany(ctx context.Context, ids []uint) ([]models.Bundle, error) {
+	var bundles []models.Bundle
+	err := r.withItems(ctx).Where("id IN ?", ids).Order("id").Find(&bundles).Error
+	return bundles, err
+}
+
+func (r gormBundles) List(ctx context.Context) ([]models.Bundle, error) {
+	var bundles []models.Bundle
+	err := r.withItems(ctx).Order("id").Find(&bundles).Error
+	return bundles, err
+}
+
+func (r gormBundles) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.Bundle{}, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+type gormDownloads struct{ db *gorm.DB }
+
+func (r gormDownloads) SetFile(ctx context.Context, file *models.DigitalFile, version int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		marked := tx.Model(&models.Item{}).Where("id = ? AND version = ?", file.ItemID, version).
+			Updates(map[string]interface{}{"digital": true, "version": gorm.Expr("version + 1")})
+		if err := conflict(marked); err != nil {
+			return err
+		}
+		// The unique index on the item covers deleted files too
+		if err := tx.Unscoped().Where("item_id = ?", file.ItemID).Delete(&models.DigitalFile{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(file).Error
+	})
+}
+
+func (r gormDownloads) File(ctx context.Context, itemID uint) (models.DigitalFile, error) {
+	var file models.DigitalFile
+	err := r.db.WithContext(ctx).Where("item_id = ?", itemID).First(&file).Error
+	return file, notFound(err)
+}
+
+func (r gormDownloads) RemoveFile(ctx context.Context, itemID uint, version int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		removed := tx.Unscoped().Where("item_id = ?", itemID).Delete(&models.DigitalFile{})
+		if removed.Error != nil {
+			return removed.Error
+		}
+		if removed.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		marked := tx.Model(&models.Item{}).Where("id = ? AND version = ?", itemID, version).
+			Updates(map[string]interface{}{"digital": false, "version": gorm.Expr("version + 1")})
+		return conflict(marked)
+	})
+}
+
+func (r gormDownloads) Get(ctx context.Context, id uint) (models.Download, error) {
+	var download models.Download
+	err := r.db.WithContext(ctx).First(&download, id).Error
+	return download, notFound(err)
+}
+
+func (r gormDownloads) ListByOrder(ctx context.Context, orderID uint) ([]models.Download, error) {
+	var downloads []models.Download
+	err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("id").Find(&downloads).Error
+	return downloads, err
+}
+
+func (r gormDownloads) Use(ctx context.Context, id uint, issue int) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Download{}).
+		Where("id = ? AND issue = ? AND (max_downloads = 0 OR downloaded < max_downloads)", id, issue).
+		Update("downloaded", gorm.Expr("downloaded + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r gormDownloads) Reissue(ctx context.Context, orderID uint) error {
+	return r.db.WithContext(ctx).Model(&models.Download{}).Where("order_id = ?", orderID).
+		Updates(map[string]interface{}{"downloaded": 0, "issue": gorm.Expr("issue + 1")}).Error
+}
+
+type gormBackorders struct{ db *gorm.DB }
+
+func (r gormBackorders) Get(ctx context.Context, id uint) (models.OrderBackorder, error) {
+	var backorder models.OrderBackorder
+	err := r.db.WithContext(ctx).First(&backorder, id).Error
+	return backorder, notFound(err)
+}
+
+func (r gormBackorders) Outstanding(ctx context.Context, itemID uint) ([]models.OrderBackorder, error) {
+	query := r.db.WithContext(ctx).Where("fulfilled_at IS NULL")
+	if itemID != 0 {
+		query = query.Where("item_id = ?", itemID)
+	}
+	var backorders []models.OrderBackorder
+	err := query.Order("id").Find(&backorders).Error
+	return backorders, err
+}
+
+func (r gormBackorders) Fulfill(ctx context.Context, backorder *models.OrderBackorder, allocations []models.OrderAllocation, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OrderBackorder{}).Where("id = ? AND fulfilled_at IS NULL", backorder.ID).
+			Update("fulfilled_at", at)
+		if err := conflict(result); err != nil {
+			return err
+		}
+		backorder.FulfilledAt = &at
+		if len(allocations) == 0 {
+			return nil
+		}
+		return tx.Create(&allocations).Error
+	})
+}
+
+type gormWarehouses struct{ db *gorm.DB }
+
+func (r gormWarehouses) Create(ctx context.Context, warehouse *models.Warehouse) error {
+	return r.db.WithContext(ctx).Create(warehouse).Error
+}
+
+func (r gormWarehouses) Get(ctx context.Context, id uint) (models.Warehouse, error) {
+	var warehouse models.Warehouse
+	err := r.db.WithContext(ctx).First(&warehouse, id).Error
+	return warehouse, notFound(err)
+}
+
+func (r gormWarehouses) List(ctx context.Context) ([]models.Warehouse, error) {
+	var warehouses []models.Warehouse
+	err := r.db.WithContext(ctx).Order("priority, id").Find(&warehouses).Error
+	return warehouses, err
+}
+
+func (r gormWarehouses) Stock(ctx context.Context, warehouseID uint) ([]models.WarehouseStock, error) {
+	var stock []models.WarehouseStock
+	err := r.db.WithContext(ctx).Preload("Item").Where("warehouse_id = ?", warehouseID).
+		Order("item_id").Find(&stock).Error
+	return stock, err
+}
+
+func (r gormWarehouses) StockOf(ctx context.Context, itemIDs []uint) ([]models.WarehouseStock, error) {
+	if len(itemIDs) == 0 {
+		return nil, nil
+	}
+	var stock []models.WarehouseStock
+	err := r.db.WithContext(ctx).Preload("Warehouse").Where("item_id IN ?", itemIDs).Find(&stock).Error
+	inAllocationOrder(stock)
+	return stock, err
+}
+
+func (r gormWarehouses) SetStock(ctx context.Context, warehouseID, itemID uint, quantity int) error {
+	stock := models.WarehouseStock{WarehouseID: warehouseID, ItemID: itemID, Quantity: quantity}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "warehouse_id"}, {Name: "item_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"quantity": quantity, "updated_at": time.Now()}),
+	}).Create(&stock).Error
+}
+
+func (r gormWarehouses) AddStock(ctx context.Context, warehouseID, itemID uint, quantity int) error {
+	stock := models.WarehouseStock{WarehouseID: warehouseID, ItemID: itemID, Quantity: quantity}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "warehouse_id"}, {Name: "item_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"quantity":   gorm.Expr("warehouse_stocks.quantity + ?", quantity),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&stock).Error
+}
+
+func (r gormWarehouses) TakeStock(ctx context.Context, warehouseID, itemID uint, quantity int) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.WarehouseStock{}).
+		Where("warehouse_id = ? AND item_id = ? AND quantity >= ?", warehouseID, itemID, quantity).
+		Update("quantity", gorm.Expr("quantity - ?", quantity))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r gormWarehouses) CreateTransfer(ctx context.Context, transfer *models.StockTransfer) error {
+	return r.db.WithContext(ctx).Create(transfer).Error
+}
+
+func (r gormWarehouses) ListTransfers(ctx context.Context, page pagination.Page) ([]models.StockTransfer, string, error) {
+	var transfers []models.StockTransfer
+	if err := page.Apply(r.db.WithContext(ctx)).Find(&transfers).Error; err != nil {
+		return nil, "", err
+	}
+	n, next := page.Next(len(transfers), func(i int) uint { return transfers[i].ID })
+	return transfers[:n], next, nil
+}
+
+type gormShipments struct{ db *gorm.DB }
+
+func (r gormShipments) Create(ctx context.Context, shipment *models.Shipment) error {
+	return r.db.WithContext(ctx).Create(shipment).Error
+}
+
+func (r gormShipments) FindByTracking(ctx context.Context, carrier, trackingNumber string) (models.Shipment, error) {
+	var shipment models.Shipment
+	err := r.db.WithContext(ctx).Preload("Events", byOccurrence).
+		Where("carrier = ? AND tracking_number = ?", carrier, trackingNumber).
+		First(&shipment).Error
+	return shipment, notFound(err)
+}
+
+func (r gormShipments) Undelivered(ctx context.Context, limit int) ([]models.Shipment, error) {
+	var shipments []models.Shipment
+	err := r.db.WithContext(ctx).Preload("Events", byOccurrence).
+		Where("status <> ?", models.TrackingDelivered).
+		Order("checked_at IS NOT NULL, checked_at, id").
+		Limit(limit).
+		Find(&shipments).Error
+	return shipments, err
+}
+
+func (r gormShipments) Record(ctx context.Context, shipment *models.Shipment, events []models.TrackingEvent) error {
+	db := r.db.WithContext(ctx)
+	if len(events) > 0 {
+		for i := range events {
+			events[i].ShipmentID = shipment.ID
+		}
+		if err := db.Create(&events).Error; err != nil {
+			return err
+		}
+	}
+	return db.Model(&models.Shipment{}).Where("id = ?", shipment.ID).Updates(map[string]interface{}{
+		"status":       shipment.Status,
+		"delivered_at": shipment.DeliveredAt,
+		"checked_at":   shipment.CheckedAt,
+	}).Error
+}
+
+type gormGiftCards struct{ db *gorm.DB }
+
+func (r gormGiftCards) Create(ctx context.Context, card *models.GiftCard) error {
+	return r.db.WithContext(ctx).Create(card).Error
+}
+
+func (r gormGiftCards) Get(ctx context.Context, id uint) (models.GiftCard, error) {
+	var card models.GiftCard
+	err := r.db.WithContext(ctx).Preload("Entries", byID).First(&card, id).Error
+	return card, notFound(err)
+}
+
+func (r gormGiftCards) FindByCode(ctx context.Context, code string) (models.GiftCard, error) {
+	var card models.GiftCard
+	err := r.db.WithContext(ctx).Where("code = ?", code).First(&card).Error
+	return card, notFound(err)
+}
+
+func (r gormGiftCards) List(ctx context.Context, page pagination.Page) ([]models.GiftCard, string, error) {
+	var cards []models.GiftCard
+	if err := page.Apply(r.db.WithContext(ctx)).Find(&cards).Error; err != nil {
+		return nil, "", err
+	}
+	n, next := page.Next(len(cards), func(i int) uint { return cards[i].ID })
+	return cards[:n], next, nil
+}
+
+func (r gormGiftCards) ListByUser(ctx context.Context, userID uint) ([]models.GiftCard, error) {
+	var cards []models.GiftCard
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id DESC").Find(&cards).Error
+	return cards, err
+}
+
+func (r gormGiftCards) Debit(ctx context.Context, id uint, amount float64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.GiftCard{}).
+		Where("id = ? AND voided_at IS NULL AND balance >= ?", id, amount).
+		Update("balance", gorm.Expr("balance - ?", amount))
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r gormGiftCards) Void(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.GiftCard{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"balance": 0, "voided_at": at}).Error
+}
+
+func (r gormGiftCards) AddEntry(ctx context.Context, entry *models.GiftCardEntry) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
@@ -0,0 +1,2876 @@
+package repository
+
+import (
+	"context"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/search"
+	"ecommerce-backend/tenant"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Memory is an in-memory Store for unit tests. Records are kept without
+// their associations, which are filled in on reads the way the GORM store
+// preloads them, and scoped to the store in the context the way the tenant
+// plugin scopes queries. Transactions are serialized and restore a snapshot
+// when rolled back; reads outside a transaction may see its uncommitted
+// writes.
+type Memory struct {
+	state *memoryState
+	inTx  bool
+}
+
+type memoryState struct {
+	txMu sync.Mutex
+	mu   sync.Mutex
+	data memoryData
+}
+
+type memoryData struct {
+	nextID      uint
+	users       map[uint]models.User
+	items       map[uint]models.Item
+	carts       map[uint]models.Cart
+	cartItems   map[uint]models.CartItem
+	reminders   map[uint]models.CartReminder
+	orders      map[uint]models.Order
+	subOrders   map[uint]models.SubOrder
+	vendors     map[uint]models.Vendor
+	shipping    map[uint]models.ShippingMethod
+	slots       map[uint]models.DeliverySlot
+	bookings    map[uint]models.DeliveryBooking
+	shipments   map[uint]models.Shipment
+	tracking    map[uint]models.TrackingEvent
+	giftCards   map[uint]models.GiftCard
+	giftEntries map[uint]models.GiftCardEntry
+	promotions  map[uint]models.Promotion
+	orderPromos map[uint]models.OrderPromotion
+	sales       map[uint]models.Sale
+	purchases   map[uint]models.SalePurchase
+	bundles     map[uint]models.Bundle
+	bundleItems map[uint]models.BundleItem
+	soldBundles map[uint]models.OrderBundle
+	files       map[uint]models.DigitalFile
+	downloads   map[uint]models.Download
+	backorders  map[uint]models.OrderBackorder
+	warehouses  map[uint]models.Warehouse
+	stock       map[uint]models.WarehouseStock
+	transfers   map[uint]models.StockTransfer
+	movements   map[uint]models.InventoryMovement
+	allocations map[uint]models.OrderAllocation
+	addresses   map[uint]models.Address
+	devices     map[uint]models.DeviceToken
+	terms       map[uint]models.TermsAcceptance
+	stockSubs   map[uint]models.StockSubscription
+	flags       map[uint]models.FeatureFlag
+	outbox      map[uint]models.OutboxMessage
+	stores      map[uint]models.Store
+}
+
+var _ Store = (*Memory)(nil)
+
+// NewMemory returns an empty in-memory Store
+func NewMemory() *Memory {
+	return &Memory{state: &memoryState{data: memoryData{
+		users:       map[uint]models.User{},
+		items:       map[uint]models.Item{},
+		carts:       map[uint]models.Cart{},
+		cartItems:   map[uint]models.CartItem{},
+		reminders:   map[uint]models.CartReminder{},
+		orders:      map[uint]models.Order{},
+		subOrders:   map[uint]models.SubOrder{},
+		vendors:     map[uint]models.Vendor{},
+		shipping:    map[uint]models.ShippingMethod{},
+		slots:       map[uint]models.DeliverySlot{},
+		bookings:    map[uint]models.DeliveryBooking{},
+		shipments:   map[uint]models.Shipment{},
+		tracking:    map[uint]models.TrackingEvent{},
+		giftCards:   map[uint]models.GiftCard{},
+		giftEntries: map[uint]models.GiftCardEntry{},
+		promotions:  map[uint]models.Promotion{},
+		orderPromos: map[uint]models.OrderPromotion{},
+		sales:       map[uint]models.Sale{},
+		purchases:   map[uint]models.SalePurchase{},
+		bundles:     map[uint]models.Bundle{},
+		bundleItems: map[uint]models.BundleItem{},
+		soldBundles: map[uint]models.OrderBundle{},
+		files:       map[uint]models.DigitalFile{},
+		downloads:   map[uint]models.Download{},
+		backorders:  map[uint]models.OrderBackorder{},
+		warehouses:  map[uint]models.Warehouse{},
+		stock:       map[uint]models.WarehouseStock{},
+		transfers:   map[uint]models.StockTransfer{},
+		movements:   map[uint]models.InventoryMovement{},
+		allocations: map[uint]models.OrderAllocation{},
+		addresses:   map[uint]models.Address{},
+		devices:     map[uint]models.DeviceToken{},
+		terms:       map[uint]models.TermsAcceptance{},
+		stockSubs:   map[uint]models.StockSubscription{},
+		flags:       map[uint]models.FeatureFlag{},
+		outbox:      map[uint]models.OutboxMessage{},
+		stores:      map[uint]models.Store{},
+	}}}
+}
+
+func (m *Memory) Users() UserRepository           { return memoryUsers{m.state} }
+func (m *Memory) Items() ItemRepository           { return memoryItems{m.state} }
+func (m *Memory) Carts() CartRepository           { return memoryCarts{m.state} }
+func (m *Memory) Orders() OrderRepository         { return memoryOrders{m.state} }
+func (m *Memory) Vendors() VendorRepository       { return memoryVendors{m.state} }
+func (m *Memory) Shipping() ShippingRepository    { return memoryShipping{m.state} }
+func (m *Memory) Delivery() DeliveryRepository    { return memoryDelivery{m.state} }
+func (m *Memory) Shipments() ShipmentRepository   { return memoryShipments{m.state} }
+func (m *Memory) GiftCards() GiftCardRepository   { return memoryGiftCards{m.state} }
+func (m *Memory) Promotions() PromotionRepository { return memoryPromotions{m.state} }
+func (m *Memory) Sales() SaleRepository           { return memorySales{m.state} }
+func (m *Memory) Bundles() BundleRepository       { return memoryBundles{m.state} }
+func (m *Memory) Downloads() DownloadRepository   { return memoryDownloads{m.state} }
+func (m *Memory) Backorders() BackorderRepository { return memoryBackorders{m.state} }
+func (m *Memory) Warehouses() WarehouseRepository { return memoryWarehouses{m.state} }
+func (m *Memory) Addresses() AddressRepository    { return memoryAddresses{m.state} }
+func (m *Memory) Devices() DeviceRepository       { return memoryDevices{m.state} }
+func (m *Memory) Terms() TermsRepository          { return memoryTerms{m.state} }
+func (m *Memory) Flags() FlagRepository           { return memoryFlags{m.state} }
+func (m *Memory) Outbox() OutboxRepository        { return memoryOutbox{m.state} }
+func (m *Memory) Stores() StoreRepository         { return memoryStores{m.state} }
+func (m *Memory) StockSubscriptions() StockSubscriptionRepository {
+	return memoryStockSubscriptions{m.state}
+}
+
+func (m *Memory) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	// Nested transactions run in the enclosing one
+	if m.inTx {
+		return fn(m)
+	}
+
+	m.state.txMu.Lock()
+	defer m.state.txMu.Unlock()
+
+	m.state.mu.Lock()
+	snapshot := m.state.data.clone()
+	m.state.mu.Unlock()
+
+	if err := fn(&Memory{state: m.state, inTx: true}); err != nil {
+		m.state.mu.Lock()
+		m.state.data = snapshot
+		m.state.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (d memoryData) clone() memoryData {
+	c := d
+	c.users = cloneMap(d.users)
+	c.items = cloneMap(d.items)
+	c.carts = cloneMap(d.carts)
+	c.cartItems = cloneMap(d.cartItems)
+	c.reminders = cloneMap(d.reminders)
+	c.orders = cloneMap(d.orders)
+	c.subOrders = cloneMap(d.subOrders)
+	c.vendors = cloneMap(d.vendors)
+	c.shipping = cloneMap(d.shipping)
+	c.slots = cloneMap(d.slots)
+	c.bookings = cloneMap(d.bookings)
+	c.shipments = cloneMap(d.shipments)
+	c.tracking = cloneMap(d.tracking)
+	c.giftCards = cloneMap(d.giftCards)
+	c.giftEntries = cloneMap(d.giftEntries)
+	c.promotions = cloneMap(d.promotions)
+	c.orderPromos = cloneMap(d.orderPromos)
+	c.sales = cloneMap(d.sales)
+	c.purchases = cloneMap(d.purchases)
+	c.bundles = cloneMap(d.bundles)
+	c.bundleItems = cloneMap(d.bundleItems)
+	c.soldBundles = cloneMap(d.soldBundles)
+	c.files = cloneMap(d.files)
+	c.downloads = cloneMap(d.downloads)
+	c.backorders = cloneMap(d.backorders)
+	c.warehouses = cloneMap(d.warehouses)
+	c.stock = cloneMap(d.stock)
+	c.transfers = cloneMap(d.transfers)
+	c.movements = cloneMap(d.movements)
+	c.allocations = cloneMap(d.allocations)
+	c.addresses = cloneMap(d.addresses)
+	c.devices = cloneMap(d.devices)
+	c.terms = cloneMap(d.terms)
+	c.stockSubs = cloneMap(d.stockSubs)
+	c.flags = cloneMap(d.flags)
+	c.outbox = cloneMap(d.outbox)
+	return c
+}
+
+func cloneMap[T any](m map[uint]T) map[uint]T {
+	c := make(map[uint]T, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// stamp assigns the next ID and the timestamps, like an insert would
+func (d *memoryData) stamp(model *gorm.Model) {
+	d.nextID++
+	now := time.Now()
+	model.ID = d.nextID
+	if model.CreatedAt.IsZero() {
+		model.CreatedAt = now
+	}
+	model.UpdatedAt = now
+}
+
+// sorted returns the values of m ordered by ID
+func sorted[T any](m map[uint]T) []T {
+	ids := make([]uint, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	values := make([]T, len(ids))
+	for i, id := range ids {
+		values[i] = m[id]
+	}
+	return values
+}
+
+// eachInMemory calls fn for the rows of the page and returns the next cursor
+func eachInMemory[T any](page pagination.Page, rows []T, id func(*T) uint, fn func(*T) error) (string, error) {
+	rows = pagination.Slice(page, rows, id)
+	n, next := page.Next(len(rows), func(i int) uint { return id(&rows[i]) })
+	for i := range rows[:n] {
+		if err := fn(&rows[i]); err != nil {
+			return "", err
+		}
+	}
+	return next, nil
+}
+
+// cartItemsOf returns the items of a cart, with item details if withItems
+func (d *memoryData) cartItemsOf(cartID uint, withItems bool) []models.CartItem {
+	var cartItems []models.CartItem
+	for _, ci := range sorted(d.cartItems) {
+		if ci.CartID != cartID {
+			continue
+		}
+		if withItems {
+			ci.Item = d.items[ci.ItemID]
+		}
+		cartItems = append(cartItems, ci)
+	}
+	return cartItems
+}
+
+// assignStore sets the store of a new record from ctx, as the tenant
+// plugin does for the GORM store
+func assignStore(ctx context.Context, storeID *uint) {
+	if *storeID == 0 {
+		*storeID = tenant.StoreOrDefault(ctx)
+	}
+}
+
+// inStore reports whether a record of the store is visible in ctx
+func inStore(ctx context.Context, storeID uint) bool {
+	id, ok := tenant.FromContext(ctx)
+	return !ok || id == storeID
+}
+
+// owner returns the ID and username of a user, as the GORM store preloads it
+func (d *memoryData) owner(userID uint) models.User {
+	user := models.User{Username: d.users[userID].Username}
+	user.ID = userID
+	return user
+}
+
+type memoryUsers struct{ s *memoryState }
+
+func (r memoryUsers) Create(ctx context.Context, user *models.User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &user.StoreID)
+	for _, existing := range r.s.data.users {
+		if existing.StoreID == user.StoreID && existing.Username == user.Username {
+			return fmt.Errorf("duplicate username %q", user.Username)
+		}
+	}
+	r.s.data.stamp(&user.Model)
+
+	record := *user
+	record.Carts, record.Orders = nil, nil
+	r.s.data.users[user.ID] = record
+	return nil
+}
+
+func (r memoryUsers) Get(ctx context.Context, id uint) (models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.data.users[id]
+	if !ok || !inStore(ctx, user.StoreID) {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (r memoryUsers) FindByUsername(ctx context.Context, username string) (models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, user := range r.s.data.users {
+		if inStore(ctx, user.StoreID) && user.Username == username {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (r memoryUsers) UsernameExists(ctx context.Context, username string) (bool, error) {
+	_, err := r.FindByUsername(ctx, username)
+	return err == nil, nil
+}
+
+func (r memoryUsers) SetVendor(ctx context.Context, userID uint, vendorID *uint, role string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.data.users[userID]
+	if !ok || !inStore(ctx, user.StoreID) {
+		return nil
+	}
+	if vendorID != nil {
+		id := *vendorID
+		vendorID = &id
+	}
+	user.VendorID, user.Role = vendorID, role
+	user.UpdatedAt = time.Now()
+	r.s.data.users[userID] = user
+	return nil
+}
+
+func (r memoryUsers) SetEmail(ctx context.Context, userID uint, email string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.data.users[userID]
+	if !ok || !inStore(ctx, user.StoreID) {
+		return nil
+	}
+	user.Email = email
+	user.UpdatedAt = time.Now()
+	r.s.data.users[userID] = user
+	return nil
+}
+
+func (r memoryUsers) SetAvatar(ctx context.Context, userID uint, url string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.data.users[userID]
+	if !ok || !inStore(ctx, user.StoreID) {
+		return nil
+	}
+	user.AvatarURL = url
+	user.UpdatedAt = time.Now()
+	r.s.data.users[userID] = user
+	return nil
+}
+
+func (r memoryUsers) SetPhone(ctx context.Context, userID uint, phone, codeHash string, sentAt *time.Time) error {
+	return r.modify(ctx, userID, func(user *models.User) {
+		if sentAt != nil {
+			t := *sentAt
+			sentAt = &t
+		}
+		user.Phone, user.PhoneVerifiedAt, user.SMSOptIn = phone, nil, false
+		user.PhoneCodeHash, user.PhoneCodeSentAt, user.PhoneCodeAttempts = codeHash, sentAt, 0
+	})
+}
+
+func (r memoryUsers) FailPhoneCode(ctx context.Context, userID uint) error {
+	return r.modify(ctx, userID, func(user *models.User) {
+		user.PhoneCodeAttempts++
+	})
+}
+
+func (r memoryUsers) VerifyPhone(ctx context.Context, userID uint, at time.Time) error {
+	return r.modify(ctx, userID, func(user *models.User) {
+		user.PhoneVerifiedAt = &at
+		user.PhoneCodeHash, user.PhoneCodeSentAt, user.PhoneCodeAttempts = "", nil, 0
+	})
+}
+
+func (r memoryUsers) SetSMSOptIn(ctx context.Context, userID uint, optIn bool) error {
+	return r.modify(ctx, userID, func(user *models.User) {
+		user.SMSOptIn = optIn
+	})
+}
+
+// modify applies fn to the user, if it exists in the store of ctx
+func (r memoryUsers) modify(ctx context.Context, userID uint, fn func(user *models.User)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.data.users[userID]
+	if !ok || !inStore(ctx, user.StoreID) {
+		return nil
+	}
+	fn(&user)
+	user.UpdatedAt = time.Now()
+	r.s.data.users[userID] = user
+	return nil
+}
+
+func (r memoryUsers) SetDeactivated(ctx context.Context, userID uint, at *time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.data.users[userID]
+	if !ok || !inStore(ctx, user.StoreID) {
+		return nil
+	}
+	if at != nil {
+		t := *at
+		at = &t
+	}
+	user.DeactivatedAt = at
+	user.UpdatedAt = time.Now()
+	r.s.data.users[userID] = user
+	return nil
+}
+
+func (r memoryUsers) Deactivated(ctx context.Context, before time.Time, limit int) ([]models.User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var users []models.User
+	for _, user := range sorted(r.s.data.users) {
+		if inStore(ctx, user.StoreID) && user.DeactivatedAt != nil && user.DeactivatedAt.Before(before) && user.AnonymizedAt == nil {
+			users = append(users, user)
+		}
+	}
+
+	sort.SliceStable(users, func(i, j int) bool { return users[i].DeactivatedAt.Before(*users[j].DeactivatedAt) })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r memoryUsers) Anonymize(ctx context.Context, userID uint, username string, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	user, ok := r.s.data.users[userID]
+	if !ok || !inStore(ctx, user.StoreID) {
+		return nil
+	}
+	user.Username, user.Email, user.PasswordHash, user.AvatarURL = username, "", "", ""
+	user.Phone, user.PhoneVerifiedAt, user.SMSOptIn = "", nil, false
+	user.PhoneCodeHash, user.PhoneCodeSentAt, user.PhoneCodeAttempts = "", nil, 0
+	user.AnonymizedAt = &at
+	user.UpdatedAt = time.Now()
+	r.s.data.users[userID] = user
+
+	for id, reminder := range r.s.data.reminders {
+		if reminder.UserID == userID {
+			reminder.Email = ""
+			r.s.data.reminders[id] = reminder
+		}
+	}
+	for id, address := range r.s.data.addresses {
+		if address.UserID == userID {
+			delete(r.s.data.addresses, id)
+		}
+	}
+	for id, acceptance := range r.s.data.terms {
+		if acceptance.UserID == userID {
+			acceptance.IP = ""
+			r.s.data.terms[id] = acceptance
+		}
+	}
+	for id, device := range r.s.data.devices {
+		if device.UserID == userID {
+			delete(r.s.data.devices, id)
+		}
+	}
+	for id, sub := range r.s.data.stockSubs {
+		if sub.UserID == userID {
+			delete(r.s.data.stockSubs, id)
+		}
+	}
+	return nil
+}
+
+func (r memoryUsers) Each(ctx context.Context, page pagination.Page, fn func(*models.User) error) (string, error) {
+	r.s.mu.Lock()
+	var users []models.User
+	for _, user := range sorted(r.s.data.users) {
+		if inStore(ctx, user.StoreID) && user.DeactivatedAt == nil {
+			users = append(users, user)
+		}
+	}
+	r.s.mu.Unlock()
+
+	return eachInMemory(page, users, func(user *models.User) uint { return user.ID }, fn)
+}
+
+func (r memoryUsers) Locate(ctx context.Context, page pagination.Page) (pagination.Position, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var users []models.User
+	for _, user := range sorted(r.s.data.users) {
+		if inStore(ctx, user.StoreID) && user.DeactivatedAt == nil {
+			users = append(users, user)
+		}
+	}
+	return pagination.LocateSlice(page, users, func(user *models.User) uint { return user.ID }), nil
+}
+
+func (r memoryUsers) EachSummary(ctx context.Context, page pagination.Page, fn func(*UserSummary) error) (string, error) {
+	r.s.mu.Lock()
+	var summaries []UserSummary
+	for _, user := range sorted(r.s.data.users) {
+		if !inStore(ctx, user.StoreID) || user.DeactivatedAt != nil {
+			continue
+		}
+		summary := UserSummary{User: user}
+		for _, order := range r.s.data.orders {
+			if order.UserID != user.ID {
+				continue
+			}
+			summary.OrderCount++
+			switch order.Status {
+			case models.OrderCompleted, models.OrderShipped, models.OrderDelivered:
+				summary.LifetimeValue += order.Total
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	r.s.mu.Unlock()
+
+	return eachInMemory(page, summaries, func(summary *UserSummary) uint { return summary.ID }, fn)
+}
+
+type memoryItems struct{ s *memoryState }
+
+func (r memoryItems) Create(ctx context.Context, item *models.Item) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &item.StoreID)
+	r.s.data.stamp(&item.Model)
+	// Items are created active, as the column default is applied in
+	// place of false
+	item.IsActive = true
+	record := *item
+	record.CartItems = nil
+	r.s.data.items[item.ID] = record
+	return nil
+}
+
+func (r memoryItems) Get(ctx context.Context, id uint) (models.Item, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, ok := r.s.data.items[id]
+	if !ok || !inStore(ctx, item.StoreID) {
+		return models.Item{}, ErrNotFound
+	}
+	return item, nil
+}
+
+func (r memoryItems) GetMany(ctx context.Context, ids []uint) ([]models.Item, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var items []models.Item
+	for _, id := range ids {
+		if item, ok := r.s.data.items[id]; ok && inStore(ctx, item.StoreID) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (r memoryItems) GetBySKU(ctx context.Context, sku string) (models.Item, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, item := range sorted(r.s.data.items) {
+		if inStore(ctx, item.StoreID) && item.SKU != nil && *item.SKU == sku {
+			return item, nil
+		}
+	}
+	return models.Item{}, ErrNotFound
+}
+
+func (r memoryItems) Changed(ctx context.Context, after pagination.ChangeCursor, limit int) ([]models.Item, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var items []models.Item
+	for _, item := range r.s.data.items {
+		if !inStore(ctx, item.StoreID) || item.SKU == nil {
+			continue
+		}
+		if item.UpdatedAt.After(after.At) || (item.UpdatedAt.Equal(after.At) && item.ID > after.ID) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].UpdatedAt.Equal(items[j].UpdatedAt) {
+			return items[i].UpdatedAt.Before(items[j].UpdatedAt)
+		}
+		return items[i].ID < items[j].ID
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+// listed returns the items matching the filter, by ascending ID
+func (r memoryItems) listed(ctx context.Context, filter ItemFilter) []models.Item {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var items []models.Item
+	for _, item := range sorted(r.s.data.items) {
+		if inStore(ctx, item.StoreID) && filter.matches(item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// matches reports whether the item passes the filter, like its scopes
+func (f ItemFilter) matches(item models.Item) bool {
+	return (item.IsActive || f.Inactive) &&
+		(f.MinPrice == nil || item.Price >= *f.MinPrice) &&
+		(f.MaxPrice == nil || item.Price <= *f.MaxPrice) &&
+		(!f.InStock || item.Stock == nil || *item.Stock > 0) &&
+		(f.Category == "" || item.Category == f.Category) &&
+		(f.VendorID == nil || (item.VendorID != nil && *item.VendorID == *f.VendorID)) &&
+		(f.Digital == nil || item.Digital == *f.Digital)
+}
+
+func (r memoryItems) List(ctx context.Context, filter ItemFilter, page pagination.Page) ([]models.Item, string, error) {
+	items := pagination.Slice(page, r.listed(ctx, filter), func(item *models.Item) uint { return item.ID })
+
+	n, next := page.Next(len(items), func(i int) uint { return items[i].ID })
+	return items[:n], next, nil
+}
+
+func (r memoryItems) Locate(ctx context.Context, filter ItemFilter, page pagination.Page) (pagination.Position, error) {
+	return pagination.LocateSlice(page, r.listed(ctx, filter), func(item *models.Item) uint { return item.ID }), nil
+}
+
+func (r memoryItems) Each(ctx context.Context, filter ItemFilter, page pagination.Page, fn func(*models.Item) error) (string, error) {
+	return eachInMemory(page, r.listed(ctx, filter), func(item *models.Item) uint { return item.ID }, fn)
+}
+
+func (r memoryItems) Search(ctx context.Context, q search.Query) (search.Result, error) {
+	r.s.mu.Lock()
+	var matching []models.Item
+	text := strings.ToLower(q.Text)
+	for _, item := range sorted(r.s.data.items) {
+		if inStore(ctx, item.StoreID) && item.IsActive && (strings.Contains(strings.ToLower(item.Name), text) ||
+			strings.Contains(strings.ToLower(item.Category), text) ||
+			strings.Contains(strings.ToLower(item.Description), text)) {
+			matching = append(matching, item)
+		}
+	}
+	r.s.mu.Unlock()
+
+	var result search.Result
+	categories := map[string]int64{}
+	result.Facets.Prices = make([]search.PriceCount, len(search.PriceRanges))
+	for i, pr := range search.PriceRanges {
+		result.Facets.Prices[i].PriceRange = pr
+	}
+	var filtered []models.Item
+	for _, item := range matching {
+		if item.Category != "" {
+			categories[item.Category]++
+		}
+		for i, pr := range search.PriceRanges {
+			if pr.Contains(item.Price) {
+				result.Facets.Prices[i].Count++
+				break
+			}
+		}
+		if (q.Category == "" || item.Category == q.Category) &&
+			(q.MinPrice == nil || item.Price >= *q.MinPrice) && (q.MaxPrice == nil || item.Price <= *q.MaxPrice) {
+			filtered = append(filtered, item)
+		}
+	}
+	for category, count := range categories {
+		result.Facets.Categories = append(result.Facets.Categories, search.CategoryCount{Category: category, Count: count})
+	}
+	sort.Slice(result.Facets.Categories, func(i, j int) bool {
+		a, b := result.Facets.Categories[i], result.Facets.Categories[j]
+		return a.Count > b.Count || a.Count == b.Count && a.Category < b.Category
+	})
+	if len(result.Facets.Categories) > maxCategoryFacets {
+		result.Facets.Categories = result.Facets.Categories[:maxCategoryFacets]
+	}
+
+	if text != "" {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return strings.Contains(strings.ToLower(filtered[i].Name), text) && !strings.Contains(strings.ToLower(filtered[j].Name), text)
+		})
+	}
+	result.Total = int64(len(filtered))
+	for i := q.Offset; i < len(filtered) && i < q.Offset+q.Limit; i++ {
+		result.IDs = append(result.IDs, filtered[i].ID)
+	}
+	return result, nil
+}
+
+func (r memoryItems) ReserveStock(ctx context.Context, id uint, quantity int) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, ok := r.s.data.items[id]
+	if !ok || !inStore(ctx, item.StoreID) {
+		return false, nil
+	}
+	if item.Stock != nil {
+		if *item.Stock < quantity {
+			return false, nil
+		}
+		stock := *item.Stock - quantity
+		item.Stock = &stock
+	}
+	item.Version++
+	item.UpdatedAt = time.Now()
+	r.s.data.items[id] = item
+	return true, nil
+}
+
+func (r memoryItems) UpdateInventory(ctx context.Context, id uint, version int, stock *int, threshold int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, ok := r.s.data.items[id]
+	if !ok || !inStore(ctx, item.StoreID) || item.Version != version {
+		return ErrConflict
+	}
+	if stock != nil {
+		v := *stock
+		stock = &v
+	}
+	item.Stock, item.LowStockThreshold = stock, threshold
+	item.Version++
+	item.UpdatedAt = time.Now()
+	r.s.data.items[id] = item
+	return nil
+}
+
+func (r memoryItems) SetBackorder(ctx context.Context, id uint, version int, mode string, expectedAt *time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, ok := r.s.data.items[id]
+	if !ok || !inStore(ctx, item.StoreID) || item.Version != version {
+		return ErrConflict
+	}
+	item.Backorder, item.ExpectedAt = mode, expectedAt
+	item.Version++
+	item.UpdatedAt = time.Now()
+	r.s.data.items[id] = item
+	return nil
+}
+
+func (r memoryItems) SetActive(ctx context.Context, id uint, version int, active bool) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, ok := r.s.data.items[id]
+	if !ok || !inStore(ctx, item.StoreID) || item.Version != version {
+		return ErrConflict
+	}
+	item.IsActive = active
+	item.Version++
+	item.UpdatedAt = time.Now()
+	r.s.data.items[id] = item
+	return nil
+}
+
+func (r memoryItems) SetPrice(ctx context.Context, id uint, version int, price float64, compareAt *float64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, ok := r.s.data.items[id]
+	if !ok || !inStore(ctx, item.StoreID) || item.Version != version {
+		return ErrConflict
+	}
+	item.Price, item.CompareAtPrice = price, compareAt
+	item.Version++
+	item.UpdatedAt = time.Now()
+	r.s.data.items[id] = item
+	return nil
+}
+
+func (r memoryItems) AddMovements(ctx context.Context, movements []models.InventoryMovement) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for i := range movements {
+		assignStore(ctx, &movements[i].StoreID)
+		r.s.data.stamp(&movements[i].Model)
+		r.s.data.movements[movements[i].ID] = movements[i]
+	}
+	return nil
+}
+
+func (r memoryItems) Movements(ctx context.Context, itemID uint, reason string, page pagination.Page) ([]models.InventoryMovement, string, error) {
+	r.s.mu.Lock()
+	var movements []models.InventoryMovement
+	for _, movement := range sorted(r.s.data.movements) {
+		if inStore(ctx, movement.StoreID) && movement.ItemID == itemID && (reason == "" || movement.Reason == reason) {
+			movements = append(movements, movement)
+		}
+	}
+	r.s.mu.Unlock()
+
+	movements = pagination.Slice(page, movements, func(m *models.InventoryMovement) uint { return m.ID })
+	n, next := page.Next(len(movements), func(i int) uint { return movements[i].ID })
+	return movements[:n], next, nil
+}
+
+func (r memoryItems) SetQuantityLimits(ctx context.Context, id uint, version int, minQuantity, maxQuantity int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, ok := r.s.data.items[id]
+	if !ok || !inStore(ctx, item.StoreID) || item.Version != version {
+		return ErrConflict
+	}
+	item.MinQuantity, item.MaxQuantity = minQuantity, maxQuantity
+	item.Version++
+	item.UpdatedAt = time.Now()
+	r.s.data.items[id] = item
+	return nil
+}
+
+func (r memoryItems) SetInternal(ctx context.Context, id uint, version int, costPrice *float64, notes string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, ok := r.s.data.items[id]
+	if !ok || !inStore(ctx, item.StoreID) || item.Version != version {
+		return ErrConflict
+	}
+	item.CostPrice, item.InternalNotes = costPrice, notes
+	item.Version++
+	item.UpdatedAt = time.Now()
+	r.s.data.items[id] = item
+	return nil
+}
+
+func (r memoryItems) LowStock(ctx context.Context) ([]models.Item, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var items []models.Item
+	for _, item := range sorted(r.s.data.items) {
+		if inStore(ctx, item.StoreID) && item.Stock != nil && item.LowStockThreshold > 0 && *item.Stock <= item.LowStockThreshold {
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return *items[i].Stock < *items[j].Stock })
+	return items, nil
+}
+
+type memoryCarts struct{ s *memoryState }
+
+func (r memoryCarts) Create(ctx context.Context, cart *models.Cart) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &cart.StoreID)
+	r.s.data.stamp(&cart.Model)
+	record := *cart
+	record.User, record.CartItems, record.Order = models.User{}, nil, nil
+	r.s.data.carts[cart.ID] = record
+	return nil
+}
+
+func (r memoryCarts) Delete(ctx context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if cart, ok := r.s.data.carts[id]; ok && inStore(ctx, cart.StoreID) {
+		delete(r.s.data.carts, id)
+	}
+	return nil
+}
+
+func (r memoryCarts) Get(ctx context.Context, id uint) (models.Cart, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	cart, ok := r.s.data.carts[id]
+	if !ok || !inStore(ctx, cart.StoreID) {
+		return models.Cart{}, ErrNotFound
+	}
+	cart.CartItems = r.s.data.cartItemsOf(cart.ID, true)
+	return cart, nil
+}
+
+func (r memoryCarts) OpenCarts(ctx context.Context, userID uint) ([]models.Cart, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var carts []models.Cart
+	for _, cart := range sorted(r.s.data.carts) {
+		if inStore(ctx, cart.StoreID) && cart.UserID == userID && !cart.IsCheckedOut {
+			cart.CartItems = r.s.data.cartItemsOf(cart.ID, false)
+			carts = append(carts, cart)
+		}
+	}
+	return carts, nil
+}
+
+func (r memoryCarts) OpenCart(ctx context.Context, userID uint) (models.Cart, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, cart := range sorted(r.s.data.carts) {
+		if inStore(ctx, cart.StoreID) && cart.UserID == userID && !cart.IsCheckedOut {
+			cart.CartItems = r.s.data.cartItemsOf(cart.ID, true)
+			return cart, nil
+		}
+	}
+	return models.Cart{}, ErrNotFound
+}
+
+// LockOpenCart needs no lock, as memory transactions are serialized
+func (r memoryCarts) LockOpenCart(ctx context.Context, userID uint) (models.Cart, error) {
+	return r.OpenCart(ctx, userID)
+}
+
+func (r memoryCarts) MarkCheckedOut(ctx context.Context, cart *models.Cart, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	record, ok := r.s.data.carts[cart.ID]
+	if !ok || record.Version != cart.Version {
+		return ErrConflict
+	}
+	record.IsCheckedOut = true
+	record.CheckedOutAt = &at
+	record.Version++
+	r.s.data.carts[cart.ID] = record
+	for i := range cart.CartItems {
+		ci := &cart.CartItems[i]
+		ci.Name, ci.UnitPrice = ci.Item.Name, ci.Item.Price
+		if stored, ok := r.s.data.cartItems[ci.ID]; ok {
+			stored.Name, stored.UnitPrice = ci.Name, ci.UnitPrice
+			r.s.data.cartItems[ci.ID] = stored
+		}
+	}
+
+	cart.IsCheckedOut = true
+	cart.CheckedOutAt = &at
+	cart.Version = record.Version
+	return nil
+}
+
+func (r memoryCarts) MarkCheckoutStarted(ctx context.Context, userID uint, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, cart := range r.s.data.carts {
+		if inStore(ctx, cart.StoreID) && cart.UserID == userID && !cart.IsCheckedOut && cart.CheckoutStartedAt == nil {
+			cart.CheckoutStartedAt = &at
+			r.s.data.carts[id] = cart
+		}
+	}
+	return nil
+}
+
+func (r memoryCarts) Touch(ctx context.Context, cart *models.Cart, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	record, ok := r.s.data.carts[cart.ID]
+	if !ok || !inStore(ctx, record.StoreID) || record.Version != cart.Version {
+		return ErrConflict
+	}
+	record.UpdatedAt = at
+	record.Version++
+	r.s.data.carts[cart.ID] = record
+
+	cart.UpdatedAt = at
+	cart.Version = record.Version
+	return nil
+}
+
+func (r memoryCarts) Abandoned(ctx context.Context, idleSince, remindedSince time.Time, limit int) ([]models.Cart, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	reminded := map[uint]bool{}
+	recentlyReminded := map[uint]bool{}
+	for _, reminder := range r.s.data.reminders {
+		reminded[reminder.CartID] = true
+		if reminder.CreatedAt.After(remindedSince) {
+			recentlyReminded[reminder.UserID] = true
+		}
+	}
+
+	var carts []models.Cart
+	for _, cart := range sorted(r.s.data.carts) {
+		if !inStore(ctx, cart.StoreID) || cart.IsCheckedOut || !cart.UpdatedAt.Before(idleSince) ||
+			reminded[cart.ID] || recentlyReminded[cart.UserID] ||
+			r.s.data.users[cart.UserID].Email == "" || r.s.data.users[cart.UserID].DeactivatedAt != nil {
+			continue
+		}
+		cart.CartItems = r.s.data.cartItemsOf(cart.ID, true)
+		if len(cart.CartItems) == 0 {
+			continue
+		}
+		cart.User = r.s.data.users[cart.UserID]
+		carts = append(carts, cart)
+	}
+
+	sort.SliceStable(carts, func(i, j int) bool { return carts[i].UpdatedAt.Before(carts[j].UpdatedAt) })
+	if len(carts) > limit {
+		carts = carts[:limit]
+	}
+	return carts, nil
+}
+
+func (r memoryCarts) RecordReminder(ctx context.Context, reminder *models.CartReminder) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &reminder.StoreID)
+	for _, existing := range r.s.data.reminders {
+		if existing.CartID == reminder.CartID {
+			return fmt.Errorf("cart %d already reminded", reminder.CartID)
+		}
+	}
+	r.s.data.stamp(&reminder.Model)
+	r.s.data.reminders[reminder.ID] = *reminder
+	return nil
+}
+
+func (r memoryCarts) FindItem(ctx context.Context, cartID, itemID uint, bundleID *uint) (models.CartItem, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, ci := range sorted(r.s.data.cartItems) {
+		sameBundle := (ci.BundleID == nil && bundleID == nil) ||
+			(ci.BundleID != nil && bundleID != nil && *ci.BundleID == *bundleID)
+		if ci.CartID == cartID && ci.ItemID == itemID && sameBundle {
+			return ci, nil
+		}
+	}
+	return models.CartItem{}, ErrNotFound
+}
+
+func (r memoryCarts) CreateItem(ctx context.Context, cartItem *models.CartItem) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.data.stamp(&cartItem.Model)
+	record := *cartItem
+	record.Item = models.Item{}
+	r.s.data.cartItems[cartItem.ID] = record
+	return nil
+}
+
+func (r memoryCarts) UpdateQuantity(ctx context.Context, cartItem *models.CartItem) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	ci, ok := r.s.data.cartItems[cartItem.ID]
+	if !ok || ci.Version != cartItem.Version {
+		return ErrConflict
+	}
+	ci.Quantity = cartItem.Quantity
+	ci.Version++
+	ci.UpdatedAt = time.Now()
+	r.s.data.cartItems[ci.ID] = ci
+
+	cartItem.Version = ci.Version
+	return nil
+}
+
+func (r memoryCarts) MoveItem(ctx context.Context, cartItemID, cartID uint) error {
+	return r.updateItem(cartItemID, func(ci *models.CartItem) { ci.CartID = cartID })
+}
+
+func (r memoryCarts) updateItem(id uint, update func(*models.CartItem)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	ci, ok := r.s.data.cartItems[id]
+	if !ok {
+		return ErrNotFound
+	}
+	update(&ci)
+	ci.UpdatedAt = time.Now()
+	r.s.data.cartItems[id] = ci
+	return nil
+}
+
+func (r memoryCarts) DeleteItem(ctx context.Context, cartItemID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	delete(r.s.data.cartItems, cartItemID)
+	return nil
+}
+
+func (r memoryCarts) Each(ctx context.Context, page pagination.Page, fn func(*models.Cart) error) (string, error) {
+	r.s.mu.Lock()
+	var carts []models.Cart
+	for _, cart := range sorted(r.s.data.carts) {
+		if inStore(ctx, cart.StoreID) {
+			cart.User = r.s.data.owner(cart.UserID)
+			cart.CartItems = r.s.data.cartItemsOf(cart.ID, true)
+			carts = append(carts, cart)
+		}
+	}
+	r.s.mu.Unlock()
+
+	return eachInMemory(page, carts, func(cart *models.Cart) uint { return cart.ID }, fn)
+}
+
+func (r memoryCarts) Locate(ctx context.Context, page pagination.Page) (pagination.Position, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var carts []models.Cart
+	for _, cart := range sorted(r.s.data.carts) {
+		if inStore(ctx, cart.StoreID) {
+			carts = append(carts, cart)
+		}
+	}
+	return pagination.LocateSlice(page, carts, func(cart *models.Cart) uint { return cart.ID }), nil
+}
+
+type memoryOrders struct{ s *memoryState }
+
+func (r memoryOrders) Create(ctx context.Context, order *models.Order) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &order.StoreID)
+	r.s.data.stamp(&order.Model)
+	// Promotions, sale purchases, bundles, downloads, backorders and
+	// allocations are saved with the order, as GORM saves associations
+	for i := range order.Promotions {
+		order.Promotions[i].OrderID = order.ID
+		r.s.data.stamp(&order.Promotions[i].Model)
+		r.s.data.orderPromos[order.Promotions[i].ID] = order.Promotions[i]
+	}
+	for i := range order.Sales {
+		order.Sales[i].OrderID = order.ID
+		r.s.data.stamp(&order.Sales[i].Model)
+		r.s.data.purchases[order.Sales[i].ID] = order.Sales[i]
+	}
+	for i := range order.Bundles {
+		order.Bundles[i].OrderID = order.ID
+		r.s.data.stamp(&order.Bundles[i].Model)
+		r.s.data.soldBundles[order.Bundles[i].ID] = order.Bundles[i]
+	}
+	for i := range order.Downloads {
+		order.Downloads[i].OrderID = order.ID
+		r.s.data.stamp(&order.Downloads[i].Model)
+		r.s.data.downloads[order.Downloads[i].ID] = order.Downloads[i]
+	}
+	for i := range order.Backorders {
+		order.Backorders[i].OrderID = order.ID
+		assignStore(ctx, &order.Backorders[i].StoreID)
+		r.s.data.stamp(&order.Backorders[i].Model)
+		r.s.data.backorders[order.Backorders[i].ID] = order.Backorders[i]
+	}
+	for i := range order.Allocations {
+		order.Allocations[i].OrderID = order.ID
+		r.s.data.stamp(&order.Allocations[i].Model)
+		r.s.data.allocations[order.Allocations[i].ID] = order.Allocations[i]
+	}
+	record := *order
+	record.User, record.Cart, record.GiftCards, record.Promotions = models.User{}, models.Cart{}, nil, nil
+	record.Sales, record.Bundles, record.Downloads, record.Backorders, record.Allocations = nil, nil, nil, nil, nil
+	r.s.data.orders[order.ID] = record
+	return nil
+}
+
+func (r memoryOrders) Get(ctx context.Context, id uint) (models.Order, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order, ok := r.s.data.orders[id]
+	if !ok || !inStore(ctx, order.StoreID) {
+		return models.Order{}, ErrNotFound
+	}
+	return order, nil
+}
+
+func (r memoryOrders) GetDetail(ctx context.Context, id uint) (models.Order, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order, ok := r.s.data.orders[id]
+	if !ok || !inStore(ctx, order.StoreID) {
+		return models.Order{}, ErrNotFound
+	}
+	order = r.s.data.withCart(order)
+	order.Promotions = r.s.data.promotionsOf(id)
+	for _, purchase := range sorted(r.s.data.purchases) {
+		if purchase.OrderID == id {
+			order.Sales = append(order.Sales, purchase)
+		}
+	}
+	for _, bundle := range sorted(r.s.data.soldBundles) {
+		if bundle.OrderID == id {
+			order.Bundles = append(order.Bundles, bundle)
+		}
+	}
+	order.Downloads = r.s.data.downloadsOf(id)
+	for _, backorder := range sorted(r.s.data.backorders) {
+		if backorder.OrderID == id {
+			order.Backorders = append(order.Backorders, backorder)
+		}
+	}
+	for _, allocation := range sorted(r.s.data.allocations) {
+		if allocation.OrderID == id {
+			order.Allocations = append(order.Allocations, allocation)
+		}
+	}
+	for _, shipment := range sorted(r.s.data.shipments) {
+		if shipment.OrderID == id && inStore(ctx, shipment.StoreID) {
+			order.Shipments = append(order.Shipments, r.s.data.withEvents(shipment))
+		}
+	}
+	return order, nil
+}
+
+func (r memoryOrders) UpdateStatus(ctx context.Context, id uint, status string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order, ok := r.s.data.orders[id]
+	if !ok || !inStore(ctx, order.StoreID) {
+		return nil
+	}
+	order.Status = status
+	order.UpdatedAt = time.Now()
+	r.s.data.orders[id] = order
+	return nil
+}
+
+func (r memoryOrders) NumberExists(ctx context.Context, number string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, order := range r.s.data.orders {
+		if inStore(ctx, order.StoreID) && order.Number == number {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matches reports whether the order matches the filter
+func (f OrderFilter) matches(order models.Order) bool {
+	if f.Number != "" && order.Number != f.Number {
+		return false
+	}
+	for key, value := range f.Metadata {
+		if order.Metadata[key] != value {
+			return false
+		}
+	}
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, order.Status) {
+		return false
+	}
+	if f.Since != nil && order.CreatedAt.Before(*f.Since) {
+		return false
+	}
+	if f.NeedsReview && (order.DuplicateOfID == nil || order.ReviewedAt != nil) {
+		return false
+	}
+	return f.Until == nil || order.CreatedAt.Before(*f.Until)
+}
+
+func (r memoryOrders) ListByUser(ctx context.Context, userID uint, filter OrderFilter, page pagination.Keyset) ([]models.Order, pagination.Cursors, error) {
+	r.s.mu.Lock()
+	var orders []models.Order
+	for _, order := range sorted(r.s.data.orders) {
+		if inStore(ctx, order.StoreID) && order.UserID == userID && filter.matches(order) {
+			order = r.s.data.withCart(order)
+			order.Promotions = r.s.data.promotionsOf(order.ID)
+			orders = append(orders, order)
+		}
+	}
+	r.s.mu.Unlock()
+
+	orders, cursors := pagination.Trim(page, pagination.SliceKeyset(page, orders, orderKey), orderKey)
+	return orders, cursors, nil
+}
+
+func (r memoryOrders) Summaries(ctx context.Context, userID uint, filter OrderFilter, page pagination.Keyset) ([]models.Order, pagination.Cursors, error) {
+	r.s.mu.Lock()
+	var orders []models.Order
+	for _, order := range sorted(r.s.data.orders) {
+		if inStore(ctx, order.StoreID) && order.UserID == userID && filter.matches(order) {
+			orders = append(orders, order)
+		}
+	}
+	r.s.mu.Unlock()
+
+	orders, cursors := pagination.Trim(page, pagination.SliceKeyset(page, orders, orderKey), orderKey)
+	return orders, cursors, nil
+}
+
+func (r memoryOrders) Each(ctx context.Context, filter OrderFilter, page pagination.Page, fn func(*models.Order) error) (string, error) {
+	r.s.mu.Lock()
+	var orders []models.Order
+	for _, order := range sorted(r.s.data.orders) {
+		if inStore(ctx, order.StoreID) && filter.matches(order) {
+			order = r.s.data.withCart(order)
+			order.Promotions = r.s.data.promotionsOf(order.ID)
+			order.User = r.s.data.owner(order.UserID)
+			orders = append(orders, order)
+		}
+	}
+	r.s.mu.Unlock()
+
+	return eachInMemory(page, orders, func(order *models.Order) uint { return order.ID }, fn)
+}
+
+func (r memoryOrders) Locate(ctx context.Context, userID uint, filter OrderFilter, page pagination.Page) (pagination.Position, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var orders []models.Order
+	for _, order := range sorted(r.s.data.orders) {
+		if inStore(ctx, order.StoreID) && (userID == 0 || order.UserID == userID) && filter.matches(order) {
+			orders = append(orders, order)
+		}
+	}
+	return pagination.LocateSlice(page, orders, func(order *models.Order) uint { return order.ID }), nil
+}
+
+func (r memoryOrders) Purchased(ctx context.Context, userID uint, itemIDs []uint) (map[uint]int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	purchased := map[uint]int{}
+	for _, order := range r.s.data.orders {
+		if !inStore(ctx, order.StoreID) || order.UserID != userID || order.Status == models.OrderCancelled {
+			continue
+		}
+		for _, ci := range r.s.data.cartItemsOf(order.CartID, false) {
+			if slices.Contains(itemIDs, ci.ItemID) {
+				purchased[ci.ItemID] += ci.Quantity
+			}
+		}
+	}
+	return purchased, nil
+}
+
+func (r memoryOrders) Recent(ctx context.Context, userID uint, since time.Time) ([]models.Order, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var orders []models.Order
+	for _, order := range sorted(r.s.data.orders) {
+		if inStore(ctx, order.StoreID) && order.UserID == userID && order.Status != models.OrderCancelled &&
+			!order.CreatedAt.Before(since) {
+			order.Cart = r.s.data.carts[order.CartID]
+			order.Cart.CartItems = r.s.data.cartItemsOf(order.CartID, false)
+			orders = append(orders, order)
+		}
+	}
+	slices.Reverse(orders)
+	return orders, nil
+}
+
+func (r memoryOrders) MarkReviewed(ctx context.Context, id uint, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	order, ok := r.s.data.orders[id]
+	if !ok || !inStore(ctx, order.StoreID) {
+		return nil
+	}
+	order.ReviewedAt = &at
+	r.s.data.orders[id] = order
+	return nil
+}
+
+func (r memoryOrders) CreateSubOrder(ctx context.Context, sub *models.SubOrder) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.data.stamp(&sub.Model)
+	record := *sub
+	record.Order = models.Order{}
+	r.s.data.subOrders[sub.ID] = record
+	return nil
+}
+
+func (r memoryOrders) GetSubOrder(ctx context.Context, id uint) (models.SubOrder, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	sub, ok := r.s.data.subOrders[id]
+	if !ok || !inStore(ctx, r.s.data.orders[sub.OrderID].StoreID) {
+		return models.SubOrder{}, ErrNotFound
+	}
+	return sub, nil
+}
+
+func (r memoryOrders) UpdateSubOrderStatus(ctx context.Context, id uint, status string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	sub, ok := r.s.data.subOrders[id]
+	if !ok {
+		return nil
+	}
+	sub.Status = status
+	sub.UpdatedAt = time.Now()
+	r.s.data.subOrders[id] = sub
+	return nil
+}
+
+func (r memoryOrders) ListSubOrders(ctx context.Context, vendorID uint, page pagination.Page) ([]models.SubOrder, string, error) {
+	r.s.mu.Lock()
+	var subs []models.SubOrder
+	for _, sub := range sorted(r.s.data.subOrders) {
+		if sub.VendorID == vendorID {
+			sub.Order = r.s.data.withCart(r.s.data.orders[sub.OrderID])
+			subs = append(subs, sub)
+		}
+	}
+	r.s.mu.Unlock()
+
+	subs = pagination.Slice(page, subs, func(sub *models.SubOrder) uint { return sub.ID })
+	n, next := page.Next(len(subs), func(i int) uint { return subs[i].ID })
+	return subs[:n], next, nil
+}
+
+// withCart fills in the order's cart, its cart items and their items
+func (d *memoryData) withCart(order models.Order) models.Order {
+	order.Cart = d.carts[order.CartID]
+	order.Cart.CartItems = d.cartItemsOf(order.CartID, true)
+	return order
+}
+
+// promotionsOf returns the promotions applied to an order
+func (d *memoryData) promotionsOf(orderID uint) []models.OrderPromotion {
+	var promotions []models.OrderPromotion
+	for _, p := range sorted(d.orderPromos) {
+		if p.OrderID == orderID {
+			promotions = append(promotions, p)
+		}
+	}
+	return promotions
+}
+
+type memorySales struct{ s *memoryState }
+
+func (r memorySales) Create(ctx context.Context, sale *models.Sale) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &sale.StoreID)
+	r.s.data.stamp(&sale.Model)
+	r.s.data.sales[sale.ID] = *sale
+	return nil
+}
+
+func (r memorySales) List(ctx context.Context) ([]models.Sale, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var sales []models.Sale
+	for _, sale := range sorted(r.s.data.sales) {
+		if inStore(ctx, sale.StoreID) {
+			sales = append(sales, sale)
+		}
+	}
+	return sales, nil
+}
+
+func (r memorySales) Active(ctx context.Context) ([]models.Sale, error) {
+	sales, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var active []models.Sale
+	for _, sale := range sales {
+		if sale.Active {
+			active = append(active, sale)
+		}
+	}
+	return active, nil
+}
+
+func (r memorySales) Schedule(ctx context.Context, at time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var changed int64
+	for id, sale := range r.s.data.sales {
+		if inStore(ctx, sale.StoreID) && sale.Active != sale.Running(at) {
+			sale.Active = !sale.Active
+			sale.UpdatedAt = time.Now()
+			r.s.data.sales[id] = sale
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+func (r memorySales) Purchased(ctx context.Context, userID uint, saleIDs []uint) (map[uint]int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	wanted := map[uint]bool{}
+	for _, id := range saleIDs {
+		wanted[id] = true
+	}
+	purchased := map[uint]int{}
+	for _, purchase := range r.s.data.purchases {
+		if purchase.UserID == userID && wanted[purchase.SaleID] {
+			purchased[purchase.SaleID] += purchase.Quantity
+		}
+	}
+	return purchased, nil
+}
+
+func (r memorySales) Delete(ctx context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	sale, ok := r.s.data.sales[id]
+	if !ok || !inStore(ctx, sale.StoreID) {
+		return ErrNotFound
+	}
+	delete(r.s.data.sales, id)
+	return nil
+}
+
+type memoryBundles struct{ s *memoryState }
+
+func (r memoryBundles) Create(ctx context.Context, bundle *models.Bundle) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &bundle.StoreID)
+	r.s.data.stamp(&bundle.Model)
+	// Items are saved with the bundle, as GORM saves associations
+	for i := range bundle.Items {
+		bundle.Items[i].BundleID = bundle.ID
+		r.s.data.stamp(&bundle.Items[i].Model)
+		item := bundle.Items[i]
+		item.Item = models.Item{}
+		r.s.data.bundleItems[item.ID] = item
+	}
+	record := *bundle
+	record.Items = nil
+	r.s.data.bundles[bundle.ID] = record
+	return nil
+}
+
+func (r memoryBundles) Get(ctx context.Context, id uint) (models.Bundle, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	bundle, ok := r.s.data.bundles[id]
+	if !ok || !inStore(ctx, bundle.StoreID) {
+		return models.Bundle{}, ErrNotFound
+	}
+	return r.s.data.withBundleItems(bundle), nil
+}
+
+func (r memoryBundles) GetMany(ctx context.Context, ids []uint) ([]models.Bundle, error) {
+	wanted := map[uint]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	bundles, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []models.Bundle
+	for _, bundle := range bundles {
+		if wanted[bundle.ID] {
+			found = append(found, bundle)
+		}
+	}
+	return found, nil
+}
+
+func (r memoryBundles) List(ctx context.Context) ([]models.Bundle, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var bundles []models.Bundle
+	for _, bundle := range sorted(r.s.data.bundles) {
+		if inStore(ctx, bundle.StoreID) {
+			bundles = append(bundles, r.s.data.withBundleItems(bundle))
+		}
+	}
+	return bundles, nil
+}
+
+func (r memoryBundles) Delete(ctx context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	bundle, ok := r.s.data.bundles[id]
+	if !ok || !inStore(ctx, bundle.StoreID) {
+		return ErrNotFound
+	}
+	delete(r.s.data.bundles, id)
+	return nil
+}
+
+// withBundleItems fills in the bundle's items and their details
+func (d *memoryData) withBundleItems(bundle models.Bundle) models.Bundle {
+	for _, bi := range sorted(d.bundleItems) {
+		if bi.BundleID == bundle.ID {
+			bi.Item = d.items[bi.ItemID]
+			bundle.Items = append(bundle.Items, bi)
+		}
+	}
+	return bundle
+}
+
+type memoryDownloads struct{ s *memoryState }
+
+func (r memoryDownloads) SetFile(ctx context.Context, file *models.DigitalFile, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	item, ok := r.s.data.items[file.ItemID]
+	if !ok || !inStore(ctx, item.StoreID) || item.Version != version {
+		return ErrConflict
+	}
+	item.Digital = true
+	item.Version++
+	item.UpdatedAt = time.Now()
+	r.s.data.items[item.ID] = item
+	for id, existing := range r.s.data.files {
+		if existing.ItemID == file.ItemID {
+			delete(r.s.data.files, id)
+		}
+	}
+	r.s.data.stamp(&file.Model)
+	r.s.data.files[file.ID] = *file
+	return nil
+}
+
+func (r memoryDownloads) File(ctx context.Context, itemID uint) (models.DigitalFile, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, file := range r.s.data.files {
+		if file.ItemID == itemID {
+			return file, nil
+		}
+	}
+	return models.DigitalFile{}, ErrNotFound
+}
+
+func (r memoryDownloads) RemoveFile(ctx context.Context, itemID uint, version int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var ids []uint
+	for id, file := range r.s.data.files {
+		if file.ItemID == itemID {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return ErrNotFound
+	}
+	item, ok := r.s.data.items[itemID]
+	if !ok || !inStore(ctx, item.StoreID) || item.Version != version {
+		return ErrConflict
+	}
+	for _, id := range ids {
+		delete(r.s.data.files, id)
+	}
+	item.Digital = false
+	item.Version++
+	item.UpdatedAt = time.Now()
+	r.s.data.items[itemID] = item
+	return nil
+}
+
+func (r memoryDownloads) Get(ctx context.Context, id uint) (models.Download, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	download, ok := r.s.data.downloads[id]
+	if !ok {
+		return models.Download{}, ErrNotFound
+	}
+	return download, nil
+}
+
+func (r memoryDownloads) ListByOrder(ctx context.Context, orderID uint) ([]models.Download, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.data.downloadsOf(orderID), nil
+}
+
+func (r memoryDownloads) Use(ctx context.Context, id uint, issue int) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	download, ok := r.s.data.downloads[id]
+	if !ok || download.Issue != issue ||
+		(download.MaxDownloads > 0 && download.Downloaded >= download.MaxDownloads) {
+		return false, nil
+	}
+	download.Downloaded++
+	download.UpdatedAt = time.Now()
+	r.s.data.downloads[id] = download
+	return true, nil
+}
+
+func (r memoryDownloads) Reissue(ctx context.Context, orderID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, download := range r.s.data.downloads {
+		if download.OrderID == orderID {
+			download.Downloaded = 0
+			download.Issue++
+			download.UpdatedAt = time.Now()
+			r.s.data.downloads[id] = download
+		}
+	}
+	return nil
+}
+
+// downloadsOf returns the downloads of an order by ID
+func (d *memoryData) downloadsOf(orderID uint) []models.Download {
+	var downloads []models.Download
+	for _, download := range sorted(d.downloads) {
+		if download.OrderID == orderID {
+			downloads = append(downloads, download)
+		}
+	}
+	return downloads
+}
+
+type memoryBackorders struct{ s *memoryState }
+
+func (r memoryBackorders) Get(ctx context.Context, id uint) (models.OrderBackorder, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	backorder, ok := r.s.data.backorders[id]
+	if !ok || !inStore(ctx, backorder.StoreID) {
+		return models.OrderBackorder{}, ErrNotFound
+	}
+	return backorder, nil
+}
+
+func (r memoryBackorders) Outstanding(ctx context.Context, itemID uint) ([]models.OrderBackorder, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var backorders []models.OrderBackorder
+	for _, backorder := range sorted(r.s.data.backorders) {
+		if backorder.FulfilledAt == nil && inStore(ctx, backorder.StoreID) &&
+			(itemID == 0 || backorder.ItemID == itemID) {
+			backorders = append(backorders, backorder)
+		}
+	}
+	return backorders, nil
+}
+
+func (r memoryBackorders) Fulfill(ctx context.Context, backorder *models.OrderBackorder, allocations []models.OrderAllocation, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	record, ok := r.s.data.backorders[backorder.ID]
+	if !ok || !inStore(ctx, record.StoreID) || record.FulfilledAt != nil {
+		return ErrConflict
+	}
+	record.FulfilledAt = &at
+	record.UpdatedAt = time.Now()
+	r.s.data.backorders[record.ID] = record
+	backorder.FulfilledAt = &at
+	for i := range allocations {
+		r.s.data.stamp(&allocations[i].Model)
+		r.s.data.allocations[allocations[i].ID] = allocations[i]
+	}
+	return nil
+}
+
+type memoryVendors struct{ s *memoryState }
+
+func (r memoryVendors) Create(ctx context.Context, vendor *models.Vendor) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &vendor.StoreID)
+	for _, existing := range r.s.data.vendors {
+		if existing.StoreID == vendor.StoreID && existing.Name == vendor.Name {
+			return fmt.Errorf("duplicate vendor name %q", vendor.Name)
+		}
+	}
+	r.s.data.stamp(&vendor.Model)
+	r.s.data.vendors[vendor.ID] = *vendor
+	return nil
+}
+
+func (r memoryVendors) Get(ctx context.Context, id uint) (models.Vendor, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	vendor, ok := r.s.data.vendors[id]
+	if !ok || !inStore(ctx, vendor.StoreID) {
+		return models.Vendor{}, ErrNotFound
+	}
+	return vendor, nil
+}
+
+func (r memoryVendors) NameExists(ctx context.Context, name string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, vendor := range r.s.data.vendors {
+		if inStore(ctx, vendor.StoreID) && vendor.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryVendors) List(ctx context.Context) ([]models.Vendor, error) {
+	r.s.mu.Lock()
+	var vendors []models.Vendor
+	for _, vendor := range sorted(r.s.data.vendors) {
+		if inStore(ctx, vendor.StoreID) {
+			vendors = append(vendors, vendor)
+		}
+	}
+	r.s.mu.Unlock()
+
+	sort.SliceStable(vendors, func(i, j int) bool { return vendors[i].Name < vendors[j].Name })
+	return vendors, nil
+}
+
+type memoryShipping struct{ s *memoryState }
+
+func (r memoryShipping) Create(ctx context.Context, method *models.ShippingMethod) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &method.StoreID)
+	r.s.data.stamp(&method.Model)
+	r.s.data.shipping[method.ID] = *method
+	return nil
+}
+
+func (r memoryShipping) Get(ctx context.Context, id uint) (models.ShippingMethod, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	method, ok := r.s.data.shipping[id]
+	if !ok || !inStore(ctx, method.StoreID) {
+		return models.ShippingMethod{}, ErrNotFound
+	}
+	return method, nil
+}
+
+func (r memoryShipping) List(ctx context.Context) ([]models.ShippingMethod, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var methods []models.ShippingMethod
+	for _, method := range sorted(r.s.data.shipping) {
+		if inStore(ctx, method.StoreID) {
+			methods = append(methods, method)
+		}
+	}
+	return methods, nil
+}
+
+func (r memoryShipping) Delete(ctx context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	method, ok := r.s.data.shipping[id]
+	if !ok || !inStore(ctx, method.StoreID) {
+		return ErrNotFound
+	}
+	delete(r.s.data.shipping, id)
+	return nil
+}
+
+type memoryDelivery struct{ s *memoryState }
+
+func (r memoryDelivery) CreateSlot(ctx context.Context, slot *models.DeliverySlot) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &slot.StoreID)
+	r.s.data.stamp(&slot.Model)
+	r.s.data.slots[slot.ID] = *slot
+	return nil
+}
+
+func (r memoryDelivery) GetSlot(ctx context.Context, id uint) (models.DeliverySlot, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	slot, ok := r.s.data.slots[id]
+	if !ok || !inStore(ctx, slot.StoreID) {
+		return models.DeliverySlot{}, ErrNotFound
+	}
+	return slot, nil
+}
+
+func (r memoryDelivery) ListSlots(ctx context.Context) ([]models.DeliverySlot, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var slots []models.DeliverySlot
+	for _, slot := range sorted(r.s.data.slots) {
+		if inStore(ctx, slot.StoreID) {
+			slots = append(slots, slot)
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool {
+		if slots[i].StartTime != slots[j].StartTime {
+			return slots[i].StartTime < slots[j].StartTime
+		}
+		return slots[i].EndTime < slots[j].EndTime
+	})
+	return slots, nil
+}
+
+func (r memoryDelivery) DeleteSlot(ctx context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	slot, ok := r.s.data.slots[id]
+	if !ok || !inStore(ctx, slot.StoreID) {
+		return ErrNotFound
+	}
+	delete(r.s.data.slots, id)
+	return nil
+}
+
+func (r memoryDelivery) Bookings(ctx context.Context, from, to string) ([]models.DeliveryBooking, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var bookings []models.DeliveryBooking
+	for _, booking := range sorted(r.s.data.bookings) {
+		if inStore(ctx, booking.StoreID) && booking.Date >= from && booking.Date <= to {
+			bookings = append(bookings, booking)
+		}
+	}
+	sort.SliceStable(bookings, func(i, j int) bool {
+		if bookings[i].Date != bookings[j].Date {
+			return bookings[i].Date < bookings[j].Date
+		}
+		return bookings[i].SlotID < bookings[j].SlotID
+	})
+	return bookings, nil
+}
+
+func (r memoryDelivery) Book(ctx context.Context, slot models.DeliverySlot, date string) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	booking, ok := r.find(slot.ID, date)
+	if !ok {
+		booking = models.DeliveryBooking{SlotID: slot.ID, Date: date}
+		assignStore(ctx, &booking.StoreID)
+		r.s.data.nextID++
+		booking.ID = r.s.data.nextID
+	}
+	if booking.Booked >= slot.Capacity {
+		return false, nil
+	}
+	booking.Booked++
+	r.s.data.bookings[booking.ID] = booking
+	return true, nil
+}
+
+func (r memoryDelivery) Release(ctx context.Context, slotID uint, date string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if booking, ok := r.find(slotID, date); ok && booking.Booked > 0 {
+		booking.Booked--
+		r.s.data.bookings[booking.ID] = booking
+	}
+	return nil
+}
+
+// find returns the booking of the slot on the date; the lock must be held
+func (r memoryDelivery) find(slotID uint, date string) (models.DeliveryBooking, bool) {
+	for _, booking := range r.s.data.bookings {
+		if booking.SlotID == slotID && booking.Date == date {
+			return booking, true
+		}
+	}
+	return models.DeliveryBooking{}, false
+}
+
+type memoryStores struct{ s *memoryState }
+
+func (r memoryStores) Get(ctx context.Context, id uint) (models.Store, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	store, ok := r.s.data.stores[id]
+	if !ok {
+		return models.Store{}, ErrNotFound
+	}
+	return store, nil
+}
+
+func (r memoryStores) UpdateDuplicateOrders(ctx context.Context, id uint, policy string, minutes int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	store, ok := r.s.data.stores[id]
+	if !ok {
+		return ErrNotFound
+	}
+	store.DuplicateOrderPolicy = policy
+	store.DuplicateOrderMinutes = minutes
+	store.UpdatedAt = time.Now()
+	r.s.data.stores[id] = store
+	return nil
+}
+
+type memoryFlags struct{ s *memoryState }
+
+func (r memoryFlags) Create(ctx context.Context, flag *models.FeatureFlag) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if _, ok := r.find(ctx, flag.Name); ok {
+		return fmt.Errorf("duplicate feature flag name %q", flag.Name)
+	}
+	assignStore(ctx, &flag.StoreID)
+	r.s.data.stamp(&flag.Model)
+	r.s.data.flags[flag.ID] = *flag
+	return nil
+}
+
+func (r memoryFlags) Get(ctx context.Context, name string) (models.FeatureFlag, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	flag, ok := r.find(ctx, name)
+	if !ok {
+		return models.FeatureFlag{}, ErrNotFound
+	}
+	return flag, nil
+}
+
+func (r memoryFlags) List(ctx context.Context) ([]models.FeatureFlag, error) {
+	r.s.mu.Lock()
+	var flags []models.FeatureFlag
+	for _, flag := range sorted(r.s.data.flags) {
+		if inStore(ctx, flag.StoreID) {
+			flags = append(flags, flag)
+		}
+	}
+	r.s.mu.Unlock()
+
+	sort.SliceStable(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags, nil
+}
+
+func (r memoryFlags) Update(ctx context.Context, flag *models.FeatureFlag) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	current, ok := r.s.data.flags[flag.ID]
+	if !ok || !inStore(ctx, current.StoreID) {
+		return ErrNotFound
+	}
+	flag.UpdatedAt = time.Now()
+	r.s.data.flags[flag.ID] = *flag
+	return nil
+}
+
+func (r memoryFlags) Delete(ctx context.Context, name string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	flag, ok := r.find(ctx, name)
+	if !ok {
+		return ErrNotFound
+	}
+	delete(r.s.data.flags, flag.ID)
+	return nil
+}
+
+// find returns the store's flag with the name; the lock must be held
+func (r memoryFlags) find(ctx context.Context, name string) (models.FeatureFlag, bool) {
+	for _, flag := range r.s.data.flags {
+		if flag.Name == name && inStore(ctx, flag.StoreID) {
+			return flag, true
+		}
+	}
+	return models.FeatureFlag{}, false
+}
+
+type memoryAddresses struct{ s *memoryState }
+
+func (r memoryAddresses) Create(ctx context.Context, address *models.Address) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &address.StoreID)
+	r.s.data.stamp(&address.Model)
+	r.s.data.addresses[address.ID] = *address
+	return nil
+}
+
+func (r memoryAddresses) Get(ctx context.Context, id uint) (models.Address, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	address, ok := r.s.data.addresses[id]
+	if !ok || !inStore(ctx, address.StoreID) {
+		return models.Address{}, ErrNotFound
+	}
+	return address, nil
+}
+
+func (r memoryAddresses) ListByUser(ctx context.Context, userID uint) ([]models.Address, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var addresses []models.Address
+	for _, address := range sorted(r.s.data.addresses) {
+		if inStore(ctx, address.StoreID) && address.UserID == userID {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses, nil
+}
+
+func (r memoryAddresses) SetStatus(ctx context.Context, id uint, status, reason string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	address, ok := r.s.data.addresses[id]
+	if !ok || !inStore(ctx, address.StoreID) {
+		return nil
+	}
+	address.Status, address.StatusReason = status, reason
+	address.UpdatedAt = time.Now()
+	r.s.data.addresses[id] = address
+	return nil
+}
+
+func (r memoryAddresses) Delete(ctx context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	address, ok := r.s.data.addresses[id]
+	if !ok || !inStore(ctx, address.StoreID) {
+		return ErrNotFound
+	}
+	delete(r.s.data.addresses, id)
+	return nil
+}
+
+type memoryDevices struct{ s *memoryState }
+
+func (r memoryDevices) Register(ctx context.Context, device *models.DeviceToken) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &device.StoreID)
+	for _, existing := range r.s.data.devices {
+		if existing.StoreID == device.StoreID && existing.Token == device.Token {
+			existing.UserID, existing.Platform, existing.LastSeenAt = device.UserID, device.Platform, device.LastSeenAt
+			r.s.data.devices[existing.ID] = existing
+			*device = existing
+			return nil
+		}
+	}
+	r.s.data.nextID++
+	device.ID = r.s.data.nextID
+	if device.CreatedAt.IsZero() {
+		device.CreatedAt = time.Now()
+	}
+	r.s.data.devices[device.ID] = *device
+	return nil
+}
+
+func (r memoryDevices) Get(ctx context.Context, id uint) (models.DeviceToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	device, ok := r.s.data.devices[id]
+	if !ok || !inStore(ctx, device.StoreID) {
+		return models.DeviceToken{}, ErrNotFound
+	}
+	return device, nil
+}
+
+func (r memoryDevices) ListByUser(ctx context.Context, userID uint) ([]models.DeviceToken, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var devices []models.DeviceToken
+	for _, device := range sorted(r.s.data.devices) {
+		if inStore(ctx, device.StoreID) && device.UserID == userID {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+func (r memoryDevices) Delete(ctx context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	device, ok := r.s.data.devices[id]
+	if !ok || !inStore(ctx, device.StoreID) {
+		return ErrNotFound
+	}
+	delete(r.s.data.devices, id)
+	return nil
+}
+
+type memoryTerms struct{ s *memoryState }
+
+func (r memoryTerms) Accept(ctx context.Context, acceptances []models.TermsAcceptance) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, acceptance := range acceptances {
+		assignStore(ctx, &acceptance.StoreID)
+		r.s.data.nextID++
+		acceptance.ID = r.s.data.nextID
+		r.s.data.terms[acceptance.ID] = acceptance
+	}
+	return nil
+}
+
+func (r memoryTerms) ListByUser(ctx context.Context, userID uint) ([]models.TermsAcceptance, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var acceptances []models.TermsAcceptance
+	all := sorted(r.s.data.terms)
+	for i := len(all) - 1; i >= 0; i-- {
+		if inStore(ctx, all[i].StoreID) && all[i].UserID == userID {
+			acceptances = append(acceptances, all[i])
+		}
+	}
+	return acceptances, nil
+}
+
+type memoryStockSubscriptions struct{ s *memoryState }
+
+func (r memoryStockSubscriptions) Subscribe(ctx context.Context, sub *models.StockSubscription) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, existing := range sorted(r.s.data.stockSubs) {
+		if existing.UserID == sub.UserID && existing.ItemID == sub.ItemID {
+			*sub = existing
+			return nil
+		}
+	}
+	assignStore(ctx, &sub.StoreID)
+	r.s.data.nextID++
+	sub.ID = r.s.data.nextID
+	if sub.CreatedAt.IsZero() {
+		sub.CreatedAt = time.Now()
+	}
+	r.s.data.stockSubs[sub.ID] = *sub
+	return nil
+}
+
+func (r memoryStockSubscriptions) Unsubscribe(ctx context.Context, userID, itemID uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, sub := range r.s.data.stockSubs {
+		if inStore(ctx, sub.StoreID) && sub.UserID == userID && sub.ItemID == itemID {
+			delete(r.s.data.stockSubs, id)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r memoryStockSubscriptions) ListByUser(ctx context.Context, userID uint) ([]models.StockSubscription, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var subs []models.StockSubscription
+	for _, sub := range sorted(r.s.data.stockSubs) {
+		if inStore(ctx, sub.StoreID) && sub.UserID == userID {
+			sub.Item = r.s.data.items[sub.ItemID]
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+func (r memoryStockSubscriptions) Restocked(ctx context.Context, limit int) ([]models.StockSubscription, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var subs []models.StockSubscription
+	for _, sub := range sorted(r.s.data.stockSubs) {
+		item, ok := r.s.data.items[sub.ItemID]
+		if !inStore(ctx, sub.StoreID) || !ok || !item.IsActive ||
+			(item.Stock != nil && *item.Stock <= 0 && item.Backorder == "") {
+			continue
+		}
+		sub.Item, sub.User = item, r.s.data.users[sub.UserID]
+		subs = append(subs, sub)
+		if len(subs) == limit {
+			break
+		}
+	}
+	return subs, nil
+}
+
+func (r memoryStockSubscriptions) Delete(ctx context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if sub, ok := r.s.data.stockSubs[id]; ok && inStore(ctx, sub.StoreID) {
+		delete(r.s.data.stockSubs, id)
+	}
+	return nil
+}
+
+func (r memoryStockSubscriptions) DeleteOrphaned(ctx context.Context) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var n int64
+	for id, sub := range r.s.data.stockSubs {
+		if _, ok := r.s.data.items[sub.ItemID]; inStore(ctx, sub.StoreID) && !ok {
+			delete(r.s.data.stockSubs, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+type memoryWarehouses struct{ s *memoryState }
+
+func (r memoryWarehouses) Create(ctx context.Context, warehouse *models.Warehouse) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &warehouse.StoreID)
+	r.s.data.stamp(&warehouse.Model)
+	r.s.data.warehouses[warehouse.ID] = *warehouse
+	return nil
+}
+
+func (r memoryWarehouses) Get(ctx context.Context, id uint) (models.Warehouse, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	warehouse, ok := r.s.data.warehouses[id]
+	if !ok || !inStore(ctx, warehouse.StoreID) {
+		return models.Warehouse{}, ErrNotFound
+	}
+	return warehouse, nil
+}
+
+func (r memoryWarehouses) List(ctx context.Context) ([]models.Warehouse, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var warehouses []models.Warehouse
+	for _, warehouse := range sorted(r.s.data.warehouses) {
+		if inStore(ctx, warehouse.StoreID) {
+			warehouses = append(warehouses, warehouse)
+		}
+	}
+	sort.SliceStable(warehouses, func(i, j int) bool { return warehouses[i].Priority < warehouses[j].Priority })
+	return warehouses, nil
+}
+
+func (r memoryWarehouses) Stock(ctx context.Context, warehouseID uint) ([]models.WarehouseStock, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var stock []models.WarehouseStock
+	for _, s := range sorted(r.s.data.stock) {
+		if s.WarehouseID == warehouseID {
+			s.Item = r.s.data.items[s.ItemID]
+			stock = append(stock, s)
+		}
+	}
+	sort.SliceStable(stock, func(i, j int) bool { return stock[i].ItemID < stock[j].ItemID })
+	return stock, nil
+}
+
+func (r memoryWarehouses) StockOf(ctx context.Context, itemIDs []uint) ([]models.WarehouseStock, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	wanted := map[uint]bool{}
+	for _, id := range itemIDs {
+		wanted[id] = true
+	}
+	var stock []models.WarehouseStock
+	for _, s := range sorted(r.s.data.stock) {
+		if wanted[s.ItemID] {
+			if warehouse := r.s.data.warehouses[s.WarehouseID]; inStore(ctx, warehouse.StoreID) {
+				s.Warehouse = warehouse
+			}
+			stock = append(stock, s)
+		}
+	}
+	inAllocationOrder(stock)
+	return stock, nil
+}
+
+func (r memoryWarehouses) SetStock(ctx context.Context, warehouseID, itemID uint, quantity int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stock := r.s.data.stockAt(warehouseID, itemID)
+	stock.Quantity = quantity
+	r.s.data.stock[stock.ID] = stock
+	return nil
+}
+
+func (r memoryWarehouses) AddStock(ctx context.Context, warehouseID, itemID uint, quantity int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stock := r.s.data.stockAt(warehouseID, itemID)
+	stock.Quantity += quantity
+	r.s.data.stock[stock.ID] = stock
+	return nil
+}
+
+func (r memoryWarehouses) TakeStock(ctx context.Context, warehouseID, itemID uint, quantity int) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for id, stock := range r.s.data.stock {
+		if stock.WarehouseID == warehouseID && stock.ItemID == itemID {
+			if stock.Quantity < quantity {
+				return false, nil
+			}
+			stock.Quantity -= quantity
+			stock.UpdatedAt = time.Now()
+			r.s.data.stock[id] = stock
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryWarehouses) CreateTransfer(ctx context.Context, transfer *models.StockTransfer) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &transfer.StoreID)
+	r.s.data.stamp(&transfer.Model)
+	r.s.data.transfers[transfer.ID] = *transfer
+	return nil
+}
+
+func (r memoryWarehouses) ListTransfers(ctx context.Context, page pagination.Page) ([]models.StockTransfer, string, error) {
+	r.s.mu.Lock()
+	var transfers []models.StockTransfer
+	for _, transfer := range sorted(r.s.data.transfers) {
+		if inStore(ctx, transfer.StoreID) {
+			transfers = append(transfers, transfer)
+		}
+	}
+	r.s.mu.Unlock()
+
+	transfers = pagination.Slice(page, transfers, func(t *models.StockTransfer) uint { return t.ID })
+	n, next := page.Next(len(transfers), func(i int) uint { return transfers[i].ID })
+	return transfers[:n], next, nil
+}
+
+// stockAt returns the stock of the item at the warehouse, stamping a new
+// record if there is none, like an upsert would
+func (d *memoryData) stockAt(warehouseID, itemID uint) models.WarehouseStock {
+	for _, stock := range d.stock {
+		if stock.WarehouseID == warehouseID && stock.ItemID == itemID {
+			stock.UpdatedAt = time.Now()
+			return stock
+		}
+	}
+	stock := models.WarehouseStock{WarehouseID: warehouseID, ItemID: itemID}
+	d.stamp(&stock.Model)
+	return stock
+}
+
+type memoryPromotions struct{ s *memoryState }
+
+func (r memoryPromotions) Create(ctx context.Context, promotion *models.Promotion) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &promotion.StoreID)
+	r.s.data.stamp(&promotion.Model)
+	r.s.data.promotions[promotion.ID] = *promotion
+	return nil
+}
+
+func (r memoryPromotions) List(ctx context.Context) ([]models.Promotion, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var promotions []models.Promotion
+	for _, promotion := range sorted(r.s.data.promotions) {
+		if inStore(ctx, promotion.StoreID) {
+			promotions = append(promotions, promotion)
+		}
+	}
+	return promotions, nil
+}
+
+func (r memoryPromotions) Active(ctx context.Context, at time.Time) ([]models.Promotion, error) {
+	promotions, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var active []models.Promotion
+	for _, p := range promotions {
+		if (p.StartsAt == nil || !p.StartsAt.After(at)) && (p.EndsAt == nil || p.EndsAt.After(at)) {
+			active = append(active, p)
+		}
+	}
+	return active, nil
+}
+
+func (r memoryPromotions) Delete(ctx context.Context, id uint) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	promotion, ok := r.s.data.promotions[id]
+	if !ok || !inStore(ctx, promotion.StoreID) {
+		return ErrNotFound
+	}
+	delete(r.s.data.promotions, id)
+	return nil
+}
+
+type memoryShipments struct{ s *memoryState }
+
+func (r memoryShipments) Create(ctx context.Context, shipment *models.Shipment) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &shipment.StoreID)
+	for _, existing := range r.s.data.shipments {
+		if existing.Carrier == shipment.Carrier && existing.TrackingNumber == shipment.TrackingNumber {
+			return fmt.Errorf("duplicate tracking number %q", shipment.TrackingNumber)
+		}
+	}
+	r.s.data.stamp(&shipment.Model)
+	record := *shipment
+	record.Events = nil
+	r.s.data.shipments[shipment.ID] = record
+	return nil
+}
+
+func (r memoryShipments) FindByTracking(ctx context.Context, carrier, trackingNumber string) (models.Shipment, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, shipment := range sorted(r.s.data.shipments) {
+		if inStore(ctx, shipment.StoreID) && shipment.Carrier == carrier && shipment.TrackingNumber == trackingNumber {
+			return r.s.data.withEvents(shipment), nil
+		}
+	}
+	return models.Shipment{}, ErrNotFound
+}
+
+func (r memoryShipments) Undelivered(ctx context.Context, limit int) ([]models.Shipment, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var shipments []models.Shipment
+	for _, shipment := range sorted(r.s.data.shipments) {
+		if inStore(ctx, shipment.StoreID) && shipment.Status != models.TrackingDelivered {
+			shipments = append(shipments, r.s.data.withEvents(shipment))
+		}
+	}
+	// Never checked first, then least recently checked
+	sort.SliceStable(shipments, func(i, j int) bool {
+		a, b := shipments[i].CheckedAt, shipments[j].CheckedAt
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+		return a.Before(*b)
+	})
+	if len(shipments) > limit {
+		shipments = shipments[:limit]
+	}
+	return shipments, nil
+}
+
+func (r memoryShipments) Record(ctx context.Context, shipment *models.Shipment, events []models.TrackingEvent) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	record, ok := r.s.data.shipments[shipment.ID]
+	if !ok || !inStore(ctx, record.StoreID) {
+		return nil
+	}
+	for i := range events {
+		events[i].ShipmentID = shipment.ID
+		r.s.data.stamp(&events[i].Model)
+		r.s.data.tracking[events[i].ID] = events[i]
+	}
+	record.Status = shipment.Status
+	record.DeliveredAt = shipment.DeliveredAt
+	record.CheckedAt = shipment.CheckedAt
+	record.UpdatedAt = time.Now()
+	r.s.data.shipments[shipment.ID] = record
+	return nil
+}
+
+// withEvents fills in the shipment's tracking events, oldest first
+func (d *memoryData) withEvents(shipment models.Shipment) models.Shipment {
+	shipment.Events = nil
+	for _, event := range sorted(d.tracking) {
+		if event.ShipmentID == shipment.ID {
+			shipment.Events = append(shipment.Events, event)
+		}
+	}
+	sort.SliceStable(shipment.Events, func(i, j int) bool {
+		return shipment.Events[i].OccurredAt.Before(shipment.Events[j].OccurredAt)
+	})
+	return shipment
+}
+
+type memoryGiftCards struct{ s *memoryState }
+
+func (r memoryGiftCards) Create(ctx context.Context, card *models.GiftCard) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &card.StoreID)
+	for _, existing := range r.s.data.giftCards {
+		if existing.Code == card.Code {
+			return fmt.Errorf("duplicate gift card code")
+		}
+	}
+	r.s.data.stamp(&card.Model)
+	record := *card
+	record.Entries = nil
+	r.s.data.giftCards[card.ID] = record
+	return nil
+}
+
+func (r memoryGiftCards) Get(ctx context.Context, id uint) (models.GiftCard, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	card, ok := r.s.data.giftCards[id]
+	if !ok || !inStore(ctx, card.StoreID) {
+		return models.GiftCard{}, ErrNotFound
+	}
+	for _, entry := range sorted(r.s.data.giftEntries) {
+		if entry.GiftCardID == id {
+			card.Entries = append(card.Entries, entry)
+		}
+	}
+	return card, nil
+}
+
+func (r memoryGiftCards) FindByCode(ctx context.Context, code string) (models.GiftCard, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, card := range sorted(r.s.data.giftCards) {
+		if inStore(ctx, card.StoreID) && card.Code == code {
+			return card, nil
+		}
+	}
+	return models.GiftCard{}, ErrNotFound
+}
+
+func (r memoryGiftCards) List(ctx context.Context, page pagination.Page) ([]models.GiftCard, string, error) {
+	r.s.mu.Lock()
+	var cards []models.GiftCard
+	for _, card := range sorted(r.s.data.giftCards) {
+		if inStore(ctx, card.StoreID) {
+			cards = append(cards, card)
+		}
+	}
+	r.s.mu.Unlock()
+
+	cards = pagination.Slice(page, cards, func(card *models.GiftCard) uint { return card.ID })
+	n, next := page.Next(len(cards), func(i int) uint { return cards[i].ID })
+	return cards[:n], next, nil
+}
+
+func (r memoryGiftCards) ListByUser(ctx context.Context, userID uint) ([]models.GiftCard, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var cards []models.GiftCard
+	for _, card := range sorted(r.s.data.giftCards) {
+		if inStore(ctx, card.StoreID) && card.UserID != nil && *card.UserID == userID {
+			cards = append([]models.GiftCard{card}, cards...)
+		}
+	}
+	return cards, nil
+}
+
+func (r memoryGiftCards) Debit(ctx context.Context, id uint, amount float64) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	card, ok := r.s.data.giftCards[id]
+	if !ok || !inStore(ctx, card.StoreID) || card.VoidedAt != nil || card.Balance < amount {
+		return false, nil
+	}
+	card.Balance -= amount
+	card.UpdatedAt = time.Now()
+	r.s.data.giftCards[id] = card
+	return true, nil
+}
+
+func (r memoryGiftCards) Void(ctx context.Context, id uint, at time.Time) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	card, ok := r.s.data.giftCards[id]
+	if !ok || !inStore(ctx, card.StoreID) {
+		return nil
+	}
+	card.Balance = 0
+	card.VoidedAt = &at
+	card.UpdatedAt = time.Now()
+	r.s.data.giftCards[id] = card
+	return nil
+}
+
+func (r memoryGiftCards) AddEntry(ctx context.Context, entry *models.GiftCardEntry) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.data.stamp(&entry.Model)
+	r.s.data.giftEntries[entry.ID] = *entry
+	return nil
+}
+
+// memoryOutbox keeps outbox messages but no API keys, so events are not
+// relayed to webhooks
+type memoryOutbox struct{ s *memoryState }
+
+func (r memoryOutbox) Add(ctx context.Context, msg *models.OutboxMessage) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	assignStore(ctx, &msg.StoreID)
+	r.s.data.nextID++
+	msg.ID = r.s.data.nextID
+	if msg.Channel == "" {
+		msg.Channel = models.OutboxBus
+	}
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	r.s.data.outbox[msg.ID] = *msg
+	return nil
+}
+
+func (r memoryOutbox) Claim(ctx context.Context, channel string, now time.Time, lease time.Duration, limit int) ([]models.OutboxMessage, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var due []models.OutboxMessage
+	for _, msg := range sorted(r.s.data.outbox) {
+		if msg.DeliveredAt == nil && msg.FailedAt == nil && !msg.NextAttemptAt.After(now) &&
+			msg.Channel == channel && inStore(ctx, msg.StoreID) {
+			due = append(due, msg)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for i := range due {
+		due[i].Attempts++
+		due[i].NextAttemptAt = now.Add(lease)
+		r.s.data.outbox[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+func (r memoryOutbox) Delivered(ctx context.Context, id uint, at time.Time) error {
+	return r.update(id, func(msg *models.OutboxMessage) {
+		msg.DeliveredAt, msg.LastError = &at, ""
+	})
+}
+
+func (r memoryOutbox) Retry(ctx context.Context, id uint, reason string, retryAt time.Time) error {
+	return r.update(id, func(msg *models.OutboxMessage) {
+		msg.NextAttemptAt, msg.LastError = retryAt, reason
+	})
+}
+
+func (r memoryOutbox) GiveUp(ctx context.Context, id uint, reason string, at time.Time) error {
+	return r.update(id, func(msg *models.OutboxMessage) {
+		msg.FailedAt, msg.LastError = &at, reason
+	})
+}
+
+func (r memoryOutbox) update(id uint, fn func(msg *models.OutboxMessage)) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if msg, ok := r.s.data.outbox[id]; ok {
+		fn(&msg)
+		r.s.data.outbox[id] = msg
+	}
+	return nil
+}
+
+func (r memoryOutbox) WebhookKeys(ctx context.Context, storeID uint) ([]models.APIKey, error) {
+	return nil, nil
+}
+
+func (r memoryOutbox) WebhookKey(ctx context.Context, id uint) (models.APIKey, error) {
+	return models.APIKey{}, ErrNotFound
+}
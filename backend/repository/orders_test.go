@@ -0,0 +1,130 @@
+package repository_test
+
+import (
+	"context"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/testutil"
+	"fmt"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+const (
+	benchOrders        = 50000
+	benchItemsPerOrder = 3
+)
+
+// seedOrders inserts checked-out carts of benchItemsPerOrder items and an
+// order for each, spread over a hundred users, every tenth with a promotion
+func seedOrders(b *testing.B, db *gorm.DB) {
+	b.Helper()
+
+	users := make([]models.User, 100)
+	for i := range users {
+		users[i] = models.User{Username: fmt.Sprintf("user%d", i), PasswordHash: "-", Role: "customer"}
+	}
+	items := make([]models.Item, 200)
+	for i := range items {
+		items[i] = models.Item{Name: fmt.Sprintf("Item %d", i), Description: "A benchmark item", Price: float64(i%50) + 0.99}
+	}
+	if err := db.CreateInBatches(&users, 500).Error; err != nil {
+		b.Fatalf("failed to create users: %v", err)
+	}
+	if err := db.CreateInBatches(&items, 500).Error; err != nil {
+		b.Fatalf("failed to create items: %v", err)
+	}
+
+	const chunk = 5000
+	for start := 0; start < benchOrders; start += chunk {
+		carts := make([]models.Cart, chunk)
+		for i := range carts {
+			carts[i] = models.Cart{UserID: users[(start+i)%len(users)].ID, IsCheckedOut: true}
+		}
+		if err := db.CreateInBatches(&carts, 500).Error; err != nil {
+			b.Fatalf("failed to create carts: %v", err)
+		}
+
+		lines := make([]models.CartItem, 0, chunk*benchItemsPerOrder)
+		orders := make([]models.Order, chunk)
+		for i, cart := range carts {
+			for j := 0; j < benchItemsPerOrder; j++ {
+				item := items[(start+i+j)%len(items)]
+				lines = append(lines, models.CartItem{CartID: cart.ID, ItemID: item.ID, Quantity: j + 1})
+			}
+			orders[i] = models.Order{
+				Number: fmt.Sprintf("ORD-BENCH-%08d", start+i),
+				UserID: cart.UserID,
+				CartID: cart.ID,
+				Total:  10,
+				Status: "pending",
+			}
+		}
+		if err := db.CreateInBatches(&lines, 500).Error; err != nil {
+			b.Fatalf("failed to create cart items: %v", err)
+		}
+		if err := db.CreateInBatches(&orders, 500).Error; err != nil {
+			b.Fatalf("failed to create orders: %v", err)
+		}
+
+		var promotions []models.OrderPromotion
+		for i := 0; i < len(orders); i += 10 {
+			promotions = append(promotions, models.OrderPromotion{OrderID: orders[i].ID, PromotionID: 1, Name: "Bench", Amount: 1})
+		}
+		if err := db.CreateInBatches(&promotions, 500).Error; err != nil {
+			b.Fatalf("failed to create order promotions: %v", err)
+		}
+	}
+}
+
+// BenchmarkOrdersEach streams every order with its lines, as GetOrders
+// does, comparing the former per-association preloads with the batched
+// query that replaced them
+func BenchmarkOrdersEach(b *testing.B) {
+	db := testutil.Setup(b)
+	seedOrders(b, db)
+	page := pagination.Page{Limit: benchOrders}
+	ctx := context.Background()
+
+	count := func(b *testing.B, n int) {
+		if n != benchOrders*benchItemsPerOrder {
+			b.Fatalf("streamed %d order lines, want %d", n, benchOrders*benchItemsPerOrder)
+		}
+	}
+
+	b.Run("preload", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			lines := 0
+			query := db.WithContext(ctx).Model(&models.Order{}).
+				Preload("User", func(db *gorm.DB) *gorm.DB { return db.Select("id, username") }).
+				Preload("Cart.CartItems.Item").
+				Preload("Promotions", func(db *gorm.DB) *gorm.DB { return db.Order("id") })
+			_, err := pagination.Each(page, query, 50, func(order *models.Order) uint { return order.ID },
+				func(order *models.Order) error {
+					lines += len(order.Cart.CartItems)
+					return nil
+				})
+			if err != nil {
+				b.Fatal(err)
+			}
+			count(b, lines)
+		}
+	})
+
+	b.Run("batched", func(b *testing.B) {
+		orders := repository.NewGorm(db).Orders()
+		for i := 0; i < b.N; i++ {
+			lines := 0
+			_, err := orders.Each(ctx, repository.OrderFilter{}, page, func(order *models.Order) error {
+				lines += len(order.Cart.CartItems)
+				return nil
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			count(b, lines)
+		}
+	})
+}
@@ -0,0 +1,558 @@
+package repository
+
+import (
+	"context"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/search"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrConflict is returned when a versioned record was changed, or deleted,
+// since it was read
+var ErrConflict = errors.New("record changed since it was read")
+
+// Store gives access to the repositories. Implementations are backed by
+// GORM (NewGorm) or held in memory for tests (NewMemory).
+type Store interface {
+	Users() UserRepository
+	Items() ItemRepository
+	Carts() CartRepository
+	Orders() OrderRepository
+	Vendors() VendorRepository
+	Shipping() ShippingRepository
+	Delivery() DeliveryRepository
+	Shipments() ShipmentRepository
+	GiftCards() GiftCardRepository
+	Promotions() PromotionRepository
+	Sales() SaleRepository
+	Bundles() BundleRepository
+	Downloads() DownloadRepository
+	Backorders() BackorderRepository
+	Warehouses() WarehouseRepository
+	Addresses() AddressRepository
+	Devices() DeviceRepository
+	StockSubscriptions() StockSubscriptionRepository
+	Terms() TermsRepository
+	Flags() FlagRepository
+	Outbox() OutboxRepository
+	Stores() StoreRepository
+
+	// Transaction runs fn with a Store whose repositories share a single
+	// transaction, committed if fn returns nil and rolled back otherwise.
+	// fn may be run more than once, so it must not have effects outside
+	// the transaction.
+	Transaction(ctx context.Context, fn func(tx Store) error) error
+}
+
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	// Get returns ErrNotFound if the user does not exist
+	Get(ctx context.Context, id uint) (models.User, error)
+	// FindByUsername returns ErrNotFound if no user has the username
+	FindByUsername(ctx context.Context, username string) (models.User, error)
+	UsernameExists(ctx context.Context, username string) (bool, error)
+	// SetVendor makes the user an account of the vendor with the given role
+	SetVendor(ctx context.Context, userID uint, vendorID *uint, role string) error
+	SetEmail(ctx context.Context, userID uint, email string) error
+	SetAvatar(ctx context.Context, userID uint, url string) error
+	// SetPhone changes the user's phone, unverified and opted out of SMS,
+	// recording the hash of the code sent to verify it, if any
+	SetPhone(ctx context.Context, userID uint, phone, codeHash string, sentAt *time.Time) error
+	// FailPhoneCode counts a wrong verification code entered by the user
+	FailPhoneCode(ctx context.Context, userID uint) error
+	// VerifyPhone marks the user's phone verified and forgets its code
+	VerifyPhone(ctx context.Context, userID uint, at time.Time) error
+	SetSMSOptIn(ctx context.Context, userID uint, optIn bool) error
+	// SetDeactivated deactivates the user's account at the given time, or
+	// reactivates it if at is nil
+	SetDeactivated(ctx context.Context, userID uint, at *time.Time) error
+	// Deactivated returns up to limit accounts deactivated before the given
+	// time and not yet anonymized, longest deactivated first
+	Deactivated(ctx context.Context, before time.Time, limit int) ([]models.User, error)
+	// Anonymize renames the user to username, erases their email, phone,
+	// password, avatar, the email recorded on their cart reminders and the
+	// IP recorded on their terms acceptances, and deletes their saved
+	// addresses, device tokens and stock subscriptions
+	Anonymize(ctx context.Context, userID uint, username string, at time.Time) error
+	// Each calls fn for every active user on the page and returns the next
+	// cursor; deactivated accounts are left out
+	Each(ctx context.Context, page pagination.Page, fn func(*models.User) error) (string, error)
+	// EachSummary is like Each, with each user's order count and lifetime
+	// value
+	EachSummary(ctx context.Context, page pagination.Page, fn func(*UserSummary) error) (string, error)
+	// Locate returns the position of the page among the active users
+	Locate(ctx context.Context, page pagination.Page) (pagination.Position, error)
+}
+
+// UserSummary is a user with the number of orders they placed and their
+// lifetime value, the total of their completed, shipped and delivered
+// orders
+type UserSummary struct {
+	models.User
+	OrderCount    int
+	LifetimeValue float64
+}
+
+type ItemRepository interface {
+	Create(ctx context.Context, item *models.Item) error
+	// Get returns ErrNotFound if the item does not exist
+	Get(ctx context.Context, id uint) (models.Item, error)
+	// GetMany returns the items with the given IDs in that order, skipping
+	// those that do not exist
+	GetMany(ctx context.Context, ids []uint) ([]models.Item, error)
+	// GetBySKU returns ErrNotFound if no item has the SKU
+	GetBySKU(ctx context.Context, sku string) (models.Item, error)
+	// Changed returns up to limit items with a SKU, active or not, changed
+	// after the cursor, oldest change first
+	Changed(ctx context.Context, after pagination.ChangeCursor, limit int) ([]models.Item, error)
+	// List returns the items matching the filter on the page and the next
+	// cursor
+	List(ctx context.Context, filter ItemFilter, page pagination.Page) ([]models.Item, string, error)
+	// Locate returns the position of the page among the items matching
+	// the filter
+	Locate(ctx context.Context, filter ItemFilter, page pagination.Page) (pagination.Position, error)
+	// Each calls fn for every item matching the filter on the page and
+	// returns the next cursor
+	Each(ctx context.Context, filter ItemFilter, page pagination.Page, fn func(*models.Item) error) (string, error)
+	// Search matches the query's text against item names, categories and
+	// descriptions with LIKE, case-insensitively, listing name matches
+	// first; inactive items never match. It backs item search when no
+	// search engine is configured.
+	Search(ctx context.Context, q search.Query) (search.Result, error)
+	// ReserveStock takes quantity units from the item's stock, bumping its
+	// version. It returns false, changing nothing, if fewer are in stock;
+	// items whose stock is not tracked always succeed.
+	ReserveStock(ctx context.Context, id uint, quantity int) (bool, error)
+	// UpdateInventory sets the item's stock (nil to stop tracking it) and
+	// low-stock threshold and bumps its version. It returns ErrConflict if
+	// the item is no longer at the given version.
+	UpdateInventory(ctx context.Context, id uint, version int, stock *int, threshold int) error
+	// LowStock returns the tracked items whose stock is at or below their
+	// threshold, lowest stock first
+	LowStock(ctx context.Context) ([]models.Item, error)
+	// SetBackorder sets the item's backorder mode, empty to stop selling
+	// beyond its stock, and expected availability and bumps its version.
+	// It returns ErrConflict if the item is no longer at the given version.
+	SetBackorder(ctx context.Context, id uint, version int, mode string, expectedAt *time.Time) error
+	// SetPrice sets the item's price and compare-at price, nil to remove
+	// it, and bumps its version. It returns ErrConflict if the item is no
+	// longer at the given version.
+	SetPrice(ctx context.Context, id uint, version int, price float64, compareAt *float64) error
+	// AddMovements records changes to the stock of items
+	AddMovements(ctx context.Context, movements []models.InventoryMovement) error
+	// Movements returns the item's inventory movements on the page, of the
+	// reason given unless it is empty, and the next cursor
+	Movements(ctx context.Context, itemID uint, reason string, page pagination.Page) ([]models.InventoryMovement, string, error)
+	// SetQuantityLimits sets the fewest units of the item an order may
+	// hold and the most each customer may buy, 0 for no limit, and bumps
+	// its version. It returns ErrConflict if the item is no longer at the
+	// given version.
+	SetQuantityLimits(ctx context.Context, id uint, version int, minQuantity, maxQuantity int) error
+	// SetActive lists or hides the item and bumps its version. It returns
+	// ErrConflict if the item is no longer at the given version.
+	SetActive(ctx context.Context, id uint, version int, active bool) error
+	// SetInternal sets the item's cost price, nil to remove it, and
+	// internal notes and bumps its version. It returns ErrConflict if the
+	// item is no longer at the given version.
+	SetInternal(ctx context.Context, id uint, version int, costPrice *float64, notes string) error
+}
+
+// ItemFilter narrows item listings; its zero value matches every active
+// item
+type ItemFilter struct {
+	// Inactive includes the items hidden from the catalog
+	Inactive bool
+	// MinPrice and MaxPrice, if set, bound the price
+	MinPrice *float64
+	MaxPrice *float64
+	// InStock keeps only items with stock left or whose stock is not
+	// tracked
+	InStock bool
+	// Category, VendorID and Digital, if set, must match
+	Category string
+	VendorID *uint
+	Digital  *bool
+}
+
+type CartRepository interface {
+	Create(ctx context.Context, cart *models.Cart) error
+	Delete(ctx context.Context, id uint) error
+	// Get returns the cart with its cart items and their items, or
+	// ErrNotFound
+	Get(ctx context.Context, id uint) (models.Cart, error)
+	// OpenCarts returns the user's open carts, oldest first, with their
+	// cart items but not the items' details
+	OpenCarts(ctx context.Context, userID uint) ([]models.Cart, error)
+	// OpenCart returns the user's oldest open cart with its cart items and
+	// their items, or ErrNotFound
+	OpenCart(ctx context.Context, userID uint) (models.Cart, error)
+	// LockOpenCart is OpenCart, locking the cart's row until the enclosing
+	// transaction ends so concurrent checkouts of the cart run one at a
+	// time
+	LockOpenCart(ctx context.Context, userID uint) (models.Cart, error)
+	// MarkCheckedOut closes the cart and bumps its version, keeping the
+	// name and price of each line's item on the line. It returns
+	// ErrConflict if the cart changed since it was read.
+	MarkCheckedOut(ctx context.Context, cart *models.Cart, at time.Time) error
+	// MarkCheckoutStarted records that the user first tried to check out
+	// their open carts at the given time, leaving carts already tried and
+	// the carts' versions as they are
+	MarkCheckoutStarted(ctx context.Context, userID uint, at time.Time) error
+	// Touch records that the cart was changed at the given time and bumps
+	// its version. It returns ErrConflict if the cart changed since it was
+	// read.
+	Touch(ctx context.Context, cart *models.Cart, at time.Time) error
+	// Abandoned returns up to limit open carts with items last changed
+	// before idleSince, least recently changed first, with their owner and
+	// their cart items and items. Carts already reminded, carts whose
+	// owner has no email or is deactivated and carts whose owner was
+	// reminded after remindedSince are left out.
+	Abandoned(ctx context.Context, idleSince, remindedSince time.Time, limit int) ([]models.Cart, error)
+	RecordReminder(ctx context.Context, reminder *models.CartReminder) error
+
+	// FindItem returns the cart item for itemID in the cart, as part of the
+	// bundle if bundleID is set and on its own otherwise, or ErrNotFound
+	FindItem(ctx context.Context, cartID, itemID uint, bundleID *uint) (models.CartItem, error)
+	CreateItem(ctx context.Context, cartItem *models.CartItem) error
+	// UpdateQuantity saves the cart item's quantity and bumps its version.
+	// It returns ErrConflict if the cart item changed since it was read.
+	UpdateQuantity(ctx context.Context, cartItem *models.CartItem) error
+	MoveItem(ctx context.Context, cartItemID, cartID uint) error
+	DeleteItem(ctx context.Context, cartItemID uint) error
+
+	// Each calls fn for every cart on the page, with its owner's ID and
+	// username and its items, and returns the next cursor
+	Each(ctx context.Context, page pagination.Page, fn func(*models.Cart) error) (string, error)
+	// Locate returns the position of the page among all carts
+	Locate(ctx context.Context, page pagination.Page) (pagination.Position, error)
+}
+
+// OrderFilter narrows order listings; its zero value matches every order
+type OrderFilter struct {
+	// Number, if set, is the order number to match
+	Number string
+	// Metadata, if set, are the metadata values the order must have
+	Metadata map[string]string
+	// Statuses, if set, are the statuses the order may have
+	Statuses []string
+	// Since and Until, if set, bound when the order was placed; Until is
+	// exclusive
+	Since *time.Time
+	Until *time.Time
+	// NeedsReview, if set, keeps the orders flagged as likely duplicates
+	// that no admin has reviewed
+	NeedsReview bool
+}
+
+type OrderRepository interface {
+	Create(ctx context.Context, order *models.Order) error
+	// Get returns ErrNotFound if the order does not exist
+	Get(ctx context.Context, id uint) (models.Order, error)
+	// GetDetail returns the order with its cart items and items, its
+	// promotions, its sale purchases, its bundles, its downloads, its
+	// backorders, its warehouse allocations and its shipments with their
+	// tracking events, or ErrNotFound
+	GetDetail(ctx context.Context, id uint) (models.Order, error)
+	UpdateStatus(ctx context.Context, id uint, status string) error
+	NumberExists(ctx context.Context, number string) (bool, error)
+	// ListByUser returns the user's orders matching the filter on the
+	// page, newest first, with their cart items and items and their
+	// promotions, and the cursors around the page
+	ListByUser(ctx context.Context, userID uint, filter OrderFilter, page pagination.Keyset) ([]models.Order, pagination.Cursors, error)
+	// Summaries is ListByUser without the orders' cart items and
+	// promotions
+	Summaries(ctx context.Context, userID uint, filter OrderFilter, page pagination.Keyset) ([]models.Order, pagination.Cursors, error)
+	// Each calls fn for every order matching the filter on the page, with
+	// its owner's ID and username, its cart items and items and its
+	// promotions, and returns the next cursor
+	Each(ctx context.Context, filter OrderFilter, page pagination.Page, fn func(*models.Order) error) (string, error)
+	// Locate returns the position of the page among the user's orders
+	// matching the filter, or every user's if userID is 0
+	Locate(ctx context.Context, userID uint, filter OrderFilter, page pagination.Page) (pagination.Position, error)
+	// Purchased returns the units of each of the items the user ordered in
+	// orders that are not cancelled, leaving out items never ordered
+	Purchased(ctx context.Context, userID uint, itemIDs []uint) (map[uint]int, error)
+	// Recent returns the user's orders placed since the given time, newest
+	// first, with their cart items, leaving out cancelled ones
+	Recent(ctx context.Context, userID uint, since time.Time) ([]models.Order, error)
+	// MarkReviewed records that an admin reviewed the order at the given
+	// time
+	MarkReviewed(ctx context.Context, id uint, at time.Time) error
+
+	CreateSubOrder(ctx context.Context, sub *models.SubOrder) error
+	// GetSubOrder returns ErrNotFound if the sub-order does not exist
+	GetSubOrder(ctx context.Context, id uint) (models.SubOrder, error)
+	UpdateSubOrderStatus(ctx context.Context, id uint, status string) error
+	// ListSubOrders returns the vendor's sub-orders on the page, with their
+	// order's cart items and items, and the next cursor
+	ListSubOrders(ctx context.Context, vendorID uint, page pagination.Page) ([]models.SubOrder, string, error)
+}
+
+type VendorRepository interface {
+	Create(ctx context.Context, vendor *models.Vendor) error
+	// Get returns ErrNotFound if the vendor does not exist
+	Get(ctx context.Context, id uint) (models.Vendor, error)
+	NameExists(ctx context.Context, name string) (bool, error)
+	// List returns all vendors by name
+	List(ctx context.Context) ([]models.Vendor, error)
+}
+
+type ShippingRepository interface {
+	Create(ctx context.Context, method *models.ShippingMethod) error
+	// Get returns ErrNotFound if the method does not exist
+	Get(ctx context.Context, id uint) (models.ShippingMethod, error)
+	// List returns all methods by ID
+	List(ctx context.Context) ([]models.ShippingMethod, error)
+	// Delete returns ErrNotFound if the method does not exist
+	Delete(ctx context.Context, id uint) error
+}
+
+type DeliveryRepository interface {
+	CreateSlot(ctx context.Context, slot *models.DeliverySlot) error
+	// GetSlot returns ErrNotFound if the slot does not exist
+	GetSlot(ctx context.Context, id uint) (models.DeliverySlot, error)
+	// ListSlots returns all slots by start time
+	ListSlots(ctx context.Context) ([]models.DeliverySlot, error)
+	// DeleteSlot returns ErrNotFound if the slot does not exist
+	DeleteSlot(ctx context.Context, id uint) error
+	// Bookings returns the bookings of dates from one to another, both
+	// included
+	Bookings(ctx context.Context, from, to string) ([]models.DeliveryBooking, error)
+	// Book takes one order of the slot's capacity on the date, returning
+	// false if it is full
+	Book(ctx context.Context, slot models.DeliverySlot, date string) (bool, error)
+	// Release gives back an order booked into the slot on the date
+	Release(ctx context.Context, slotID uint, date string) error
+}
+
+type AddressRepository interface {
+	Create(ctx context.Context, address *models.Address) error
+	// Get returns ErrNotFound if the address does not exist
+	Get(ctx context.Context, id uint) (models.Address, error)
+	// ListByUser returns the user's addresses by ID
+	ListByUser(ctx context.Context, userID uint) ([]models.Address, error)
+	// SetStatus records the outcome of validating the address again
+	SetStatus(ctx context.Context, id uint, status, reason string) error
+	// Delete returns ErrNotFound if the address does not exist
+	Delete(ctx context.Context, id uint) error
+}
+
+type DeviceRepository interface {
+	// Register saves the device token for the user, seen at now, taking it
+	// over from any other user it was registered for
+	Register(ctx context.Context, device *models.DeviceToken) error
+	// Get returns ErrNotFound if the device token does not exist
+	Get(ctx context.Context, id uint) (models.DeviceToken, error)
+	// ListByUser returns the user's device tokens by ID
+	ListByUser(ctx context.Context, userID uint) ([]models.DeviceToken, error)
+	// Delete returns ErrNotFound if the device token does not exist
+	Delete(ctx context.Context, id uint) error
+}
+
+type TermsRepository interface {
+	Accept(ctx context.Context, acceptances []models.TermsAcceptance) error
+	// ListByUser returns the user's acceptances, newest first
+	ListByUser(ctx context.Context, userID uint) ([]models.TermsAcceptance, error)
+}
+
+type StockSubscriptionRepository interface {
+	// Subscribe saves the subscription unless the user already has one to
+	// the item, and sets it to the stored one
+	Subscribe(ctx context.Context, sub *models.StockSubscription) error
+	// Unsubscribe returns ErrNotFound if the user has no subscription to
+	// the item
+	Unsubscribe(ctx context.Context, userID, itemID uint) error
+	// ListByUser returns the user's subscriptions with their items, oldest
+	// first
+	ListByUser(ctx context.Context, userID uint) ([]models.StockSubscription, error)
+	// Restocked returns up to limit subscriptions, oldest first, with their
+	// user and item, to active items that can be bought again: in stock,
+	// not tracking stock or sold on backorder
+	Restocked(ctx context.Context, limit int) ([]models.StockSubscription, error)
+	Delete(ctx context.Context, id uint) error
+	// DeleteOrphaned deletes the subscriptions to items that were deleted
+	DeleteOrphaned(ctx context.Context) (int64, error)
+}
+
+// StoreRepository reads and changes the settings of stores (tenants),
+// which are not scoped to the store of the context
+type StoreRepository interface {
+	// Get returns ErrNotFound if the store does not exist
+	Get(ctx context.Context, id uint) (models.Store, error)
+	// UpdateDuplicateOrders sets the store's policy for likely duplicate
+	// orders and the minutes they are looked for in
+	UpdateDuplicateOrders(ctx context.Context, id uint, policy string, minutes int) error
+}
+
+type FlagRepository interface {
+	Create(ctx context.Context, flag *models.FeatureFlag) error
+	// Get returns ErrNotFound if no flag has the name
+	Get(ctx context.Context, name string) (models.FeatureFlag, error)
+	// List returns all flags by name
+	List(ctx context.Context) ([]models.FeatureFlag, error)
+	// Update saves every field of the flag
+	Update(ctx context.Context, flag *models.FeatureFlag) error
+	// Delete removes the flag for good, so its name can be used again; it
+	// returns ErrNotFound if no flag has the name
+	Delete(ctx context.Context, name string) error
+}
+
+type OutboxRepository interface {
+	// Add writes a message to the outbox
+	Add(ctx context.Context, msg *models.OutboxMessage) error
+	// Claim returns up to limit messages of the channel that are due at
+	// now, counting an attempt for each and holding them until now plus
+	// lease so other relays skip them
+	Claim(ctx context.Context, channel string, now time.Time, lease time.Duration, limit int) ([]models.OutboxMessage, error)
+	// Delivered marks a message relayed
+	Delivered(ctx context.Context, id uint, at time.Time) error
+	// Retry records a failed attempt at a message, due again at retryAt
+	Retry(ctx context.Context, id uint, reason string, retryAt time.Time) error
+	// GiveUp records a failed attempt at a message and stops relaying it
+	GiveUp(ctx context.Context, id uint, reason string, at time.Time) error
+	// WebhookKeys returns the store's live API keys that were issued to
+	// admins and have a webhook URL
+	WebhookKeys(ctx context.Context, storeID uint) ([]models.APIKey, error)
+	// WebhookKey returns an API key, or ErrNotFound if it was revoked
+	WebhookKey(ctx context.Context, id uint) (models.APIKey, error)
+}
+
+type PromotionRepository interface {
+	Create(ctx context.Context, promotion *models.Promotion) error
+	// List returns all promotions by ID
+	List(ctx context.Context) ([]models.Promotion, error)
+	// Active returns the promotions running at the given time, by ID
+	Active(ctx context.Context, at time.Time) ([]models.Promotion, error)
+	// Delete returns ErrNotFound if the promotion does not exist
+	Delete(ctx context.Context, id uint) error
+}
+
+type SaleRepository interface {
+	Create(ctx context.Context, sale *models.Sale) error
+	// List returns all sales by ID
+	List(ctx context.Context) ([]models.Sale, error)
+	// Active returns the active sales by ID
+	Active(ctx context.Context) ([]models.Sale, error)
+	// Schedule activates the inactive sales whose window contains the
+	// given time and deactivates the active sales whose window does not,
+	// returning how many sales changed
+	Schedule(ctx context.Context, at time.Time) (int64, error)
+	// Purchased returns the units the user bought at the price of each of
+	// the given sales, by sale ID
+	Purchased(ctx context.Context, userID uint, saleIDs []uint) (map[uint]int, error)
+	// Delete returns ErrNotFound if the sale does not exist
+	Delete(ctx context.Context, id uint) error
+}
+
+type BundleRepository interface {
+	// Create saves the bundle with its items
+	Create(ctx context.Context, bundle *models.Bundle) error
+	// Get returns the bundle with its items and their details, or
+	// ErrNotFound
+	Get(ctx context.Context, id uint) (models.Bundle, error)
+	// GetMany returns the bundles with the given IDs, with their items and
+	// their details, skipping those that do not exist
+	GetMany(ctx context.Context, ids []uint) ([]models.Bundle, error)
+	// List returns all bundles by ID, with their items and their details
+	List(ctx context.Context) ([]models.Bundle, error)
+	// Delete returns ErrNotFound if the bundle does not exist
+	Delete(ctx context.Context, id uint) error
+}
+
+type DownloadRepository interface {
+	// SetFile makes file the file of its item, replacing any earlier one,
+	// and marks the item digital, bumping its version. It returns
+	// ErrConflict if the item is no longer at version.
+	SetFile(ctx context.Context, file *models.DigitalFile, version int) error
+	// File returns the item's file, or ErrNotFound
+	File(ctx context.Context, itemID uint) (models.DigitalFile, error)
+	// RemoveFile deletes the item's file and marks the item no longer
+	// digital, bumping its version. It returns ErrNotFound if the item has
+	// no file and ErrConflict if the item is no longer at version.
+	RemoveFile(ctx context.Context, itemID uint, version int) error
+	// Get returns ErrNotFound if the download does not exist
+	Get(ctx context.Context, id uint) (models.Download, error)
+	// ListByOrder returns the order's downloads by ID
+	ListByOrder(ctx context.Context, orderID uint) ([]models.Download, error)
+	// Use counts a download of the given issue. It returns false, counting
+	// nothing, if the download was re-issued since or has no downloads
+	// left.
+	Use(ctx context.Context, id uint, issue int) (bool, error)
+	// Reissue resets the download counts of the order's downloads and
+	// bumps their issue
+	Reissue(ctx context.Context, orderID uint) error
+}
+
+type BackorderRepository interface {
+	// Get returns ErrNotFound if the backorder does not exist
+	Get(ctx context.Context, id uint) (models.OrderBackorder, error)
+	// Outstanding returns the backorders not yet fulfilled, only of the
+	// item if itemID is not 0, oldest first
+	Outstanding(ctx context.Context, itemID uint) ([]models.OrderBackorder, error)
+	// Fulfill marks the backorder fulfilled at the given time and saves
+	// the warehouse allocations of its units. It returns ErrConflict if
+	// the backorder was fulfilled already.
+	Fulfill(ctx context.Context, backorder *models.OrderBackorder, allocations []models.OrderAllocation, at time.Time) error
+}
+
+type WarehouseRepository interface {
+	Create(ctx context.Context, warehouse *models.Warehouse) error
+	// Get returns ErrNotFound if the warehouse does not exist
+	Get(ctx context.Context, id uint) (models.Warehouse, error)
+	// List returns all warehouses in allocation order: by priority, then
+	// by ID
+	List(ctx context.Context) ([]models.Warehouse, error)
+	// Stock returns the stock held at the warehouse, with its items, by
+	// item ID
+	Stock(ctx context.Context, warehouseID uint) ([]models.WarehouseStock, error)
+	// StockOf returns the stock of the items in every warehouse holding
+	// any, with the warehouses, in allocation order
+	StockOf(ctx context.Context, itemIDs []uint) ([]models.WarehouseStock, error)
+	// SetStock sets the units of the item held at the warehouse
+	SetStock(ctx context.Context, warehouseID, itemID uint, quantity int) error
+	// AddStock adds quantity units of the item to the warehouse
+	AddStock(ctx context.Context, warehouseID, itemID uint, quantity int) error
+	// TakeStock takes quantity units of the item from the warehouse. It
+	// returns false, changing nothing, if fewer are held there.
+	TakeStock(ctx context.Context, warehouseID, itemID uint, quantity int) (bool, error)
+	CreateTransfer(ctx context.Context, transfer *models.StockTransfer) error
+	// ListTransfers returns the transfers on the page and the next cursor
+	ListTransfers(ctx context.Context, page pagination.Page) ([]models.StockTransfer, string, error)
+}
+
+type GiftCardRepository interface {
+	Create(ctx context.Context, card *models.GiftCard) error
+	// Get returns the card with its ledger entries, or ErrNotFound
+	Get(ctx context.Context, id uint) (models.GiftCard, error)
+	// FindByCode returns the card with the code, or ErrNotFound
+	FindByCode(ctx context.Context, code string) (models.GiftCard, error)
+	// List returns the cards on the page and the next cursor
+	List(ctx context.Context, page pagination.Page) ([]models.GiftCard, string, error)
+	// ListByUser returns the cards bought by or sent to the user, newest
+	// first
+	ListByUser(ctx context.Context, userID uint) ([]models.GiftCard, error)
+	// Debit takes amount from the card's balance. It returns false,
+	// changing nothing, if the card is voided or its balance is lower.
+	Debit(ctx context.Context, id uint, amount float64) (bool, error)
+	// Void marks the card voided and empties its balance
+	Void(ctx context.Context, id uint, at time.Time) error
+	AddEntry(ctx context.Context, entry *models.GiftCardEntry) error
+}
+
+type ShipmentRepository interface {
+	Create(ctx context.Context, shipment *models.Shipment) error
+	// FindByTracking returns the shipment with the carrier and tracking
+	// number, with its tracking events, or ErrNotFound
+	FindByTracking(ctx context.Context, carrier, trackingNumber string) (models.Shipment, error)
+	// Undelivered returns up to limit shipments that are not delivered,
+	// with their tracking events, least recently checked first
+	Undelivered(ctx context.Context, limit int) ([]models.Shipment, error)
+	// Record adds tracking events to the shipment and saves its status,
+	// delivery time and check time
+	Record(ctx context.Context, shipment *models.Shipment, events []models.TrackingEvent) error
+}
@@ -0,0 +1,95 @@
+package resilience
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// breaker is the circuit breaker of a service. It opens once
+// RESILIENCE_BREAKER_FAILURES calls in a row have failed and then refuses
+// calls for RESILIENCE_BREAKER_COOLDOWN, after which a single trial call is
+// let through: the breaker closes if it succeeds and opens again if not.
+type breaker struct {
+	name string
+
+	mu       sync.Mutex
+	failures int
+	// openedAt is zero while the breaker is closed
+	openedAt time.Time
+	// probing is set while the trial call of a half-open breaker runs
+	probing bool
+}
+
+// call runs one attempt of fn if the breaker allows it and records how it
+// went
+func (b *breaker) call(ctx context.Context, cfg config.ResilienceConfig, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if !b.allow(cfg) {
+		return openError(b.name)
+	}
+
+	attemptCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := fn(attemptCtx)
+
+	var permanent *permanentError
+	switch {
+	case err == nil || errors.As(err, &permanent):
+		b.succeeded()
+	case ctx.Err() != nil:
+		// Given up by the caller, which says nothing about the service
+		b.abandoned()
+	default:
+		b.failed(cfg)
+	}
+	return err
+}
+
+func (b *breaker) allow(cfg config.ResilienceConfig) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.openedAt.IsZero() {
+		return true
+	}
+	if b.probing || time.Since(b.openedAt) < cfg.BreakerCooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) succeeded() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.openedAt.IsZero() {
+		slog.Info("circuit breaker closed", "service", b.name)
+	}
+	b.failures, b.openedAt, b.probing = 0, time.Time{}, false
+}
+
+func (b *breaker) failed(cfg config.ResilienceConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.probing || (b.openedAt.IsZero() && b.failures >= cfg.BreakerFailures) {
+		slog.Warn("circuit breaker opened", "service", b.name, "failures", b.failures,
+			"cooldown", cfg.BreakerCooldown.String())
+		b.openedAt, b.probing = time.Now(), false
+	}
+}
+
+func (b *breaker) abandoned() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
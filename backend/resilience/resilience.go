@@ -0,0 +1,123 @@
+// Package resilience guards calls to external services, such as the mail
+// server, carrier tracking and address APIs and webhook endpoints, with
+// per-attempt timeouts, retries after a jittered backoff and circuit
+// breakers. A service that is down or slow then fails calls at once rather
+// than holding requests and their goroutines until each one times out.
+package resilience
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// retryBackoff is the wait before the first retry; it doubles for each
+// further retry and is jittered so callers failing together spread out
+const retryBackoff = 200 * time.Millisecond
+
+// ErrOpen is returned, wrapped with the service name, for calls refused by
+// an open circuit breaker
+var ErrOpen = errors.New("circuit breaker open")
+
+var (
+	mu       sync.RWMutex
+	settings = config.Default().Resilience
+	breakers = map[string]*breaker{}
+)
+
+// Init sets the retries and breaker thresholds from the configuration
+func Init(cfg config.ResilienceConfig) {
+	mu.Lock()
+	defer mu.Unlock()
+	settings = cfg
+}
+
+func current() config.ResilienceConfig {
+	mu.RLock()
+	defer mu.RUnlock()
+	return settings
+}
+
+// breakerFor returns the breaker of the named service, creating it on
+// first use
+func breakerFor(name string) *breaker {
+	mu.RLock()
+	b, ok := breakers[name]
+	mu.RUnlock()
+	if ok {
+		return b
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if b, ok := breakers[name]; ok {
+		return b
+	}
+	b = &breaker{name: name}
+	breakers[name] = b
+	return b
+}
+
+// Do calls fn through the circuit breaker of the named service, bounding
+// each attempt by timeout (0 for none) and retrying failed attempts up to
+// RESILIENCE_RETRIES times after a growing delay. Errors wrapped with
+// Permanent are returned at once, unwrapped, and count as answers from a
+// working service; failures after ctx is done are not held against it.
+// While the breaker is open Do fails with ErrOpen without calling fn.
+func Do(ctx context.Context, name string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	return do(ctx, name, timeout, current().Retries, fn)
+}
+
+func do(ctx context.Context, name string, timeout time.Duration, retries int, fn func(ctx context.Context) error) error {
+	cfg := current()
+	b := breakerFor(name)
+
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := b.call(ctx, cfg, timeout, fn)
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if err == nil || attempt == retries || errors.Is(err, ErrOpen) || ctx.Err() != nil {
+			return err
+		}
+
+		wait := backoff/2 + rand.N(backoff)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// Permanent marks an error that retrying cannot fix, such as a request the
+// service refused as invalid
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// openError is the error of a call refused by the named breaker
+func openError(name string) error {
+	return fmt.Errorf("%s: %w", name, ErrOpen)
+}
@@ -0,0 +1,83 @@
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Transport returns a round tripper sending requests through base with Do,
+// under a breaker for each host named name:host, so one unreachable webhook
+// endpoint does not stop deliveries to the others. Each attempt is bounded
+// by timeout, including the reading of the response body. Requests that
+// fail, time out or are answered with 429 or a 5xx status are retried if
+// their body can be sent again; those answers come back as errors once the
+// retries are used up.
+func Transport(name string, timeout time.Duration, base http.RoundTripper) http.RoundTripper {
+	return &transport{name: name, timeout: timeout, base: base}
+}
+
+type transport struct {
+	name    string
+	timeout time.Duration
+	base    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Bodies without GetBody can only be sent once
+	retries := current().Retries
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		retries = 0
+	}
+	sent := false
+
+	var resp *http.Response
+	err := do(req.Context(), t.name+":"+req.URL.Host, 0, retries, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, t.timeout)
+		attempt := req.Clone(ctx)
+		if sent && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return Permanent(err)
+			}
+			attempt.Body = body
+		}
+		sent = true
+
+		r, err := t.base.RoundTrip(attempt)
+		if err != nil {
+			cancel()
+			return err
+		}
+		if r.StatusCode == http.StatusTooManyRequests || r.StatusCode >= 500 {
+			io.Copy(io.Discard, io.LimitReader(r.Body, 4096))
+			r.Body.Close()
+			cancel()
+			return fmt.Errorf("%s responded with status %d", req.URL.Host, r.StatusCode)
+		}
+
+		r.Body = &cancelOnClose{ReadCloser: r.Body, cancel: cancel}
+		resp = r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// cancelOnClose ends the context of an attempt once its response body is
+// closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"ecommerce-backend/apidocs"
+	"ecommerce-backend/config"
+	"ecommerce-backend/handlers"
+	"ecommerce-backend/middleware"
+	"ecommerce-backend/models"
+	"ecommerce-backend/version"
+	"expvar"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/gzip"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// setupRouter registers middleware and all routes
+func setupRouter() *gin.Engine {
+	r := gin.New()
+	// Multipart uploads past 8 MiB, such as the files of digital items, are
+	// spooled to temporary files rather than held in memory
+	r.MaxMultipartMemory = 8 << 20
+	r.Use(
+		otelgin.Middleware(config.Get().Tracing.ServiceName),
+		middleware.RequestLogger(),
+		middleware.Recovery(),
+		middleware.CORS(),
+		// Compress responses over 1 KiB for clients that accept gzip;
+		// WebSocket handshakes and event streams are left alone
+		gzip.Gzip(gzip.DefaultCompression, gzip.WithMinLength(1024), gzip.WithExcludedPaths([]string{"/ws/", "/api/v1/admin/events", "/api/admin/events"})),
+		middleware.QueryTimeout(),
+		middleware.AuditMiddleware(),
+		middleware.ErrorHandler(),
+		middleware.Tenant(),
+		middleware.Maintenance(),
+		middleware.BodyLimit(),
+	)
+
+	// Health and build info
+	r.GET("/healthz", handlers.Healthz)
+	r.GET("/readyz", handlers.Readyz)
+	r.GET("/version", handlers.GetVersion)
+
+	// Versioned API. Each version registers its own handlers so /api/v2
+	// can coexist with /api/v1 once breaking changes land.
+	registerV1Routes(r.Group("/api/v1"))
+
+	// Unversioned routes predate /api/v1 and serve the same handlers, but
+	// every response is flagged as deprecated
+	legacy := r.Group("/api")
+	legacy.Use(middleware.Deprecated("/api/v1", legacySunset()))
+	registerV1Routes(legacy)
+
+	// GraphQL for the storefront; authentication is checked per field
+	r.Match([]string{http.MethodGet, http.MethodPost}, "/graphql", middleware.OptionalAuth(), handlers.GraphQL)
+
+	// Real-time updates over WebSocket. Browsers cannot set headers on the
+	// handshake, so the token may also be passed as ?access_token=.
+	r.GET("/ws/orders", middleware.QueryToken(), middleware.AuthMiddleware(), handlers.OrderUpdates)
+
+	// Integration partner sandbox
+	sandbox := r.Group("/sandbox")
+	sandbox.Use(middleware.APIKeyMiddleware(true))
+	{
+		sandbox.GET("/usage", handlers.GetSandboxUsage)
+		sandbox.POST("/simulate-order", middleware.APIKeyQuota(), handlers.SimulateOrder)
+	}
+
+	// Inventory sync for external systems such as an ERP, with live keys
+	// owned by admins
+	sync := r.Group("/sync")
+	sync.Use(middleware.APIKeyMiddleware(false), middleware.APIKeyAdmin(), middleware.APIKeyQuota())
+	{
+		sync.PUT("/inventory", handlers.SyncInventory)
+		sync.GET("/inventory", handlers.GetInventoryChanges)
+	}
+
+	// Tracking updates pushed by carriers, verified by each carrier's
+	// provider
+	r.POST("/webhooks/carriers/:carrier", handlers.CarrierWebhook)
+
+	// Uploaded files, when they are published by this server
+	if storageCfg := config.Get().Storage; strings.HasPrefix(storageCfg.BaseURL, "/") {
+		r.Static(strings.TrimSuffix(storageCfg.BaseURL, "/"), storageCfg.Dir)
+	}
+
+	// Runtime metrics (cart creation counters, etc.). They expose the
+	// command line and business counters, so only admins may read them.
+	r.GET("/debug/vars", middleware.AuthMiddleware(), middleware.RequireRole(models.RoleAdmin), gin.WrapH(expvar.Handler()))
+
+	// API documentation, generated from the registered routes
+	documentRoutes()
+	r.GET("/docs", apidocs.UIHandler("/docs/openapi.json"))
+	r.GET("/docs/openapi.json", apidocs.SpecHandler(r, "E-commerce API", version.Get().Version))
+
+	return r
+}
+
+// registerV1Routes registers the v1 API on the given group
+func registerV1Routes(api *gin.RouterGroup) {
+	// Public routes
+	api.POST("/users", handlers.CreateUser)
+	api.POST("/users/login", handlers.Login)
+	api.POST("/users/reactivate", handlers.ReactivateAccount)
+	api.GET("/items", middleware.ReadReplica(), middleware.OptionalAuth(), handlers.GetItems)
+	api.GET("/items/trending", handlers.GetTrendingItems)
+	api.GET("/items/search", handlers.SearchItems)
+	api.GET("/items/:id", middleware.OptionalAuth(), handlers.GetItem)
+	api.GET("/bundles", handlers.GetBundles)
+	api.GET("/bundles/:id", handlers.GetBundle)
+	api.GET("/downloads/:id", handlers.Download)
+	api.GET("/flags", middleware.OptionalAuth(), handlers.GetEnabledFlags)
+
+	// Authenticated routes
+	auth := api.Group("")
+	auth.Use(middleware.AuthMiddleware())
+	{
+		auth.POST("/users/logout", handlers.Logout)
+		auth.GET("/users/me", handlers.GetProfile)
+		auth.PUT("/users/me/email", middleware.NoImpersonation(), handlers.UpdateEmail)
+		auth.POST("/users/me/avatar", middleware.Upload(), handlers.UploadAvatar)
+		auth.PUT("/users/me/phone", middleware.NoImpersonation(), handlers.UpdatePhone)
+		auth.POST("/users/me/phone/verify", middleware.NoImpersonation(), handlers.VerifyPhone)
+		auth.PUT("/users/me/notifications", handlers.UpdateNotifications)
+		auth.GET("/users/me/addresses", handlers.GetAddresses)
+		auth.POST("/users/me/addresses", handlers.CreateAddress)
+		auth.DELETE("/users/me/addresses/:id", handlers.DeleteAddress)
+		auth.GET("/users/me/devices", handlers.GetDevices)
+		auth.POST("/users/me/devices", middleware.NoImpersonation(), handlers.RegisterDevice)
+		auth.DELETE("/users/me/devices/:id", handlers.DeleteDevice)
+		auth.GET("/users/me/stock-subscriptions", handlers.GetStockSubscriptions)
+		auth.POST("/users/me/terms", middleware.NoImpersonation(), handlers.AcceptTerms)
+		auth.POST("/users/me/deactivate", middleware.NoImpersonation(), handlers.DeactivateAccount)
+
+		auth.POST("/items/:id/stock-subscription", handlers.SubscribeToStock)
+		auth.DELETE("/items/:id/stock-subscription", handlers.UnsubscribeFromStock)
+
+		auth.GET("/carts/user", handlers.GetUserCart)
+		auth.POST("/carts", middleware.CartAbuseGuard(), handlers.AddToCart)
+		auth.POST("/cart/shipping-quote", handlers.GetShippingQuote)
+		auth.GET("/checkout/slots", handlers.GetCheckoutSlots)
+		auth.POST("/cart/share", handlers.ShareCart)
+		auth.POST("/cart/shared/:id", middleware.CartAbuseGuard(), handlers.ImportCart)
+
+		auth.GET("/orders/user", handlers.GetUserOrders)
+		auth.GET("/orders/:id", handlers.GetOrder)
+		auth.GET("/orders/:id/receipt", handlers.GetOrderReceipt)
+		auth.POST("/orders", handlers.CreateOrder)
+
+		auth.GET("/gift-cards/user", handlers.GetUserGiftCards)
+		auth.GET("/gift-cards/:code", handlers.GetGiftCardBalance)
+
+		auth.POST("/api-keys", middleware.NoImpersonation(), handlers.CreateAPIKey)
+		auth.POST("/api-keys/:id/webhook-secret", middleware.NoImpersonation(), handlers.RotateWebhookSecret)
+		auth.GET("/api-keys/:id/usage", handlers.GetAPIKeyUsage)
+	}
+
+	// Admin routes
+	admin := api.Group("")
+	admin.Use(middleware.AuthMiddleware(), middleware.RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/users", handlers.GetUsers)
+		admin.GET("/admin/users/export", middleware.ReadReplica(), handlers.ExportUsers)
+		admin.GET("/admin/users/:id", handlers.GetUser)
+		admin.POST("/admin/users/:id/impersonate", middleware.Audit(), handlers.ImpersonateUser)
+		admin.GET("/admin/items/low-stock", handlers.GetLowStockItems)
+		admin.GET("/admin/inventory/export", middleware.ReadReplica(), handlers.ExportInventory)
+		admin.GET("/admin/items/:id/movements", handlers.GetItemMovements)
+		admin.PATCH("/admin/items/bulk", handlers.BulkUpdateItems)
+		admin.PUT("/admin/items/:id/internal", handlers.SetItemInternal)
+		admin.GET("/carts", handlers.GetCarts)
+		admin.GET("/orders", middleware.ReadReplica(), handlers.GetOrders)
+		admin.GET("/admin/orders/export", middleware.ReadReplica(), handlers.ExportOrders)
+		admin.PATCH("/orders/:id/status", handlers.UpdateOrderStatus)
+		admin.PATCH("/admin/orders/status", handlers.BulkUpdateOrderStatus)
+		admin.POST("/admin/orders/:id/review", handlers.ReviewOrder)
+		admin.GET("/admin/duplicate-orders", handlers.GetDuplicateOrderSettings)
+		admin.PUT("/admin/duplicate-orders", middleware.Audit(), handlers.SetDuplicateOrderSettings)
+		admin.POST("/orders/:id/shipments", handlers.CreateShipment)
+		admin.POST("/admin/orders/:id/downloads/reissue", handlers.ReissueDownloads)
+		admin.GET("/admin/backorders", handlers.GetBackorders)
+		admin.POST("/admin/backorders/:id/fulfill", handlers.FulfillBackorder)
+		admin.GET("/audit-logs", handlers.GetAuditLogs)
+		admin.GET("/admin/purge-runs", handlers.GetPurgeRuns)
+		admin.POST("/admin/purge-runs", middleware.Audit(), handlers.TriggerPurge)
+		admin.GET("/admin/purge-runs/:id", handlers.GetPurgeRun)
+
+		admin.PUT("/admin/api-keys/:id/quotas", middleware.Audit(), handlers.SetAPIKeyQuotas)
+
+		admin.GET("/admin/maintenance", handlers.GetMaintenance)
+		admin.PUT("/admin/maintenance", middleware.Audit(), handlers.SetMaintenance)
+
+		admin.GET("/admin/jwt-keys", handlers.GetJWTKeys)
+		admin.POST("/admin/jwt-keys/rotate", middleware.Audit(), handlers.RotateJWTKey)
+		admin.GET("/admin/email-templates", handlers.GetEmailTemplates)
+		admin.GET("/admin/email-templates/:name/preview", handlers.PreviewEmailTemplate)
+
+		admin.GET("/admin/flags", handlers.GetFlags)
+		admin.PUT("/admin/flags/:name", handlers.SetFlag)
+		admin.DELETE("/admin/flags/:name", handlers.DeleteFlag)
+
+		admin.GET("/admin/analytics/revenue", middleware.ReadReplica(), handlers.GetRevenue)
+		admin.GET("/admin/analytics/order-status", middleware.ReadReplica(), handlers.GetOrderStatusCounts)
+		admin.GET("/admin/analytics/summary", middleware.ReadReplica(), handlers.GetSalesSummary)
+		admin.GET("/admin/analytics/top-items", middleware.ReadReplica(), handlers.GetTopItems)
+		admin.GET("/admin/analytics/funnel", middleware.ReadReplica(), handlers.GetConversionFunnel)
+
+		admin.POST("/admin/vendors", handlers.CreateVendor)
+		admin.GET("/admin/vendors", handlers.GetVendors)
+		admin.POST("/admin/vendors/:id/accounts", handlers.AddVendorAccount)
+
+		admin.POST("/admin/shipping-methods", handlers.CreateShippingMethod)
+		admin.GET("/admin/shipping-methods", handlers.GetShippingMethods)
+		admin.DELETE("/admin/shipping-methods/:id", handlers.DeleteShippingMethod)
+
+		admin.POST("/admin/delivery-slots", handlers.CreateDeliverySlot)
+		admin.GET("/admin/delivery-slots", handlers.GetDeliverySlots)
+		admin.DELETE("/admin/delivery-slots/:id", handlers.DeleteDeliverySlot)
+
+		admin.POST("/admin/gift-cards", handlers.IssueGiftCard)
+		admin.GET("/admin/gift-cards", handlers.GetGiftCards)
+		admin.GET("/admin/gift-cards/:id", handlers.GetGiftCard)
+		admin.POST("/admin/gift-cards/:id/void", handlers.VoidGiftCard)
+
+		admin.POST("/admin/promotions", handlers.CreatePromotion)
+		admin.GET("/admin/promotions", handlers.GetPromotions)
+		admin.DELETE("/admin/promotions/:id", handlers.DeletePromotion)
+		admin.POST("/admin/bundles", handlers.CreateBundle)
+		admin.DELETE("/admin/bundles/:id", handlers.DeleteBundle)
+		admin.POST("/admin/sales", handlers.CreateSale)
+		admin.GET("/admin/sales", handlers.GetSales)
+		admin.DELETE("/admin/sales/:id", handlers.DeleteSale)
+
+		admin.POST("/admin/warehouses", handlers.CreateWarehouse)
+		admin.GET("/admin/warehouses", handlers.GetWarehouses)
+		admin.GET("/admin/warehouses/:id/stock", handlers.GetWarehouseStock)
+		admin.PUT("/admin/warehouses/:id/stock/:item_id", handlers.SetWarehouseStock)
+		admin.POST("/admin/stock-transfers", handlers.CreateStockTransfer)
+		admin.GET("/admin/stock-transfers", handlers.GetStockTransfers)
+	}
+
+	// Catalog management; vendor accounts may only manage their own items
+	catalog := api.Group("")
+	catalog.Use(middleware.AuthMiddleware(), middleware.RequireRole(models.RoleAdmin, models.RoleVendor))
+	{
+		catalog.POST("/items", handlers.CreateItem)
+		catalog.PUT("/items/:id/inventory", handlers.UpdateInventory)
+		catalog.PUT("/items/:id/backorder", handlers.SetBackorder)
+		catalog.PUT("/items/:id/price", handlers.SetItemPrice)
+		catalog.PUT("/items/:id/quantity-limits", handlers.SetItemQuantityLimits)
+		catalog.PUT("/items/:id/active", handlers.SetItemActive)
+		catalog.PUT("/items/:id/file", middleware.Upload(), handlers.UploadItemFile)
+		catalog.DELETE("/items/:id/file", handlers.DeleteItemFile)
+	}
+
+	// Vendor routes
+	vendor := api.Group("/vendor")
+	vendor.Use(middleware.AuthMiddleware(), middleware.RequireRole(models.RoleVendor))
+	{
+		vendor.GET("/orders", handlers.GetVendorOrders)
+		vendor.PATCH("/orders/:id/status", handlers.UpdateVendorOrderStatus)
+	}
+
+	// Admin dashboard event stream; EventSource cannot set headers, so the
+	// token may also be passed as ?access_token=
+	api.GET("/admin/events", middleware.QueryToken(), middleware.AuthMiddleware(),
+		middleware.RequireRole(models.RoleAdmin), handlers.AdminEvents)
+}
+
+// legacySunset returns the configured removal date of the unversioned API,
+// or the zero time if none is announced yet
+func legacySunset() time.Time {
+	sunset, err := time.Parse("2006-01-02", config.Get().API.LegacySunset)
+	if err != nil {
+		return time.Time{}
+	}
+	return sunset
+}
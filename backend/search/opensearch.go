@@ -0,0 +1,270 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+	"ecommerce-backend/tenant"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+	// maxCategories bounds the buckets of the category facet
+	maxCategories = 50
+)
+
+// client propagates the trace context of the caller to the search engine
+var client = &http.Client{
+	Timeout:   requestTimeout,
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
+
+// openSearch is an Engine backed by Elasticsearch or OpenSearch, which
+// share the document and query APIs used here
+type openSearch struct {
+	cfg config.SearchConfig
+}
+
+func newOpenSearch(cfg config.SearchConfig) *openSearch {
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	return &openSearch{cfg: cfg}
+}
+
+// document is the indexed form of an item
+type document struct {
+	ID          uint    `json:"id"`
+	StoreID     uint    `json:"store_id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Category    string  `json:"category"`
+	Price       float64 `json:"price"`
+	Active      bool    `json:"active"`
+}
+
+// mappings index categories as keywords for filtering and faceting, with
+// a text subfield for matching
+var mappings = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"id":          map[string]interface{}{"type": "long"},
+			"store_id":    map[string]interface{}{"type": "integer"},
+			"name":        map[string]interface{}{"type": "text"},
+			"description": map[string]interface{}{"type": "text"},
+			"category": map[string]interface{}{
+				"type":   "keyword",
+				"fields": map[string]interface{}{"text": map[string]interface{}{"type": "text"}},
+			},
+			"price":  map[string]interface{}{"type": "double"},
+			"active": map[string]interface{}{"type": "boolean"},
+		},
+	},
+}
+
+func (e *openSearch) Index(ctx context.Context, item models.Item) error {
+	doc := document{
+		ID:          item.ID,
+		StoreID:     item.StoreID,
+		Name:        item.Name,
+		Description: item.Description,
+		Category:    item.Category,
+		Price:       item.Price,
+		Active:      item.IsActive,
+	}
+	return e.do(ctx, http.MethodPut, e.docPath(item.ID), doc, nil)
+}
+
+func (e *openSearch) Delete(ctx context.Context, id uint) error {
+	err := e.do(ctx, http.MethodDelete, e.docPath(id), nil, nil)
+	if status, ok := err.(statusError); ok && status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+func (e *openSearch) EnsureIndex(ctx context.Context) error {
+	err := e.do(ctx, http.MethodHead, "/"+e.cfg.Index, nil, nil)
+	if status, ok := err.(statusError); ok && status == http.StatusNotFound {
+		return e.do(ctx, http.MethodPut, "/"+e.cfg.Index, mappings, nil)
+	}
+	return err
+}
+
+func (e *openSearch) Ping(ctx context.Context) error {
+	return e.do(ctx, http.MethodGet, "/", nil, nil)
+}
+
+// searchResponse is the part of a search response read back
+type searchResponse struct {
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			ID string `json:"_id"`
+		} `json:"hits"`
+	} `json:"hits"`
+	Aggregations struct {
+		Categories struct {
+			Buckets []struct {
+				Key      string `json:"key"`
+				DocCount int64  `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"categories"`
+		Prices struct {
+			Buckets []struct {
+				DocCount int64 `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"prices"`
+	} `json:"aggregations"`
+}
+
+// Search matches the text fuzzily, ranking name matches above category
+// and description ones. The category and price filters are applied after
+// the facets are counted. Inactive items never match; documents indexed
+// before items could be hidden have no active field and do.
+func (e *openSearch) Search(ctx context.Context, q Query) (Result, error) {
+	store := []interface{}{term("store_id", tenant.StoreOrDefault(ctx))}
+	inactive := []interface{}{term("active", false)}
+	query := map[string]interface{}{"bool": map[string]interface{}{"filter": store, "must_not": inactive}}
+	if q.Text != "" {
+		query = map[string]interface{}{"bool": map[string]interface{}{
+			"filter":   store,
+			"must_not": inactive,
+			"must": map[string]interface{}{"multi_match": map[string]interface{}{
+				"query":     q.Text,
+				"fields":    []string{"name^3", "category.text^2", "description"},
+				"fuzziness": "AUTO",
+			}},
+		}}
+	}
+
+	filters := []interface{}{}
+	if q.Category != "" {
+		filters = append(filters, term("category", q.Category))
+	}
+	if q.MinPrice != nil || q.MaxPrice != nil {
+		bounds := map[string]interface{}{}
+		if q.MinPrice != nil {
+			bounds["gte"] = *q.MinPrice
+		}
+		if q.MaxPrice != nil {
+			bounds["lte"] = *q.MaxPrice
+		}
+		filters = append(filters, map[string]interface{}{"range": map[string]interface{}{"price": bounds}})
+	}
+
+	ranges := make([]map[string]interface{}, len(PriceRanges))
+	for i, r := range PriceRanges {
+		ranges[i] = map[string]interface{}{"from": r.From}
+		if r.To != 0 {
+			ranges[i]["to"] = r.To
+		}
+	}
+
+	body := map[string]interface{}{
+		"query":            query,
+		"post_filter":      map[string]interface{}{"bool": map[string]interface{}{"filter": filters}},
+		"from":             q.Offset,
+		"size":             q.Limit,
+		"track_total_hits": true,
+		"_source":          false,
+		// Ties, such as every item when there is no text, go oldest first
+		"sort": []interface{}{"_score", map[string]interface{}{"id": "asc"}},
+		"aggs": map[string]interface{}{
+			"categories": map[string]interface{}{"terms": map[string]interface{}{"field": "category", "size": maxCategories}},
+			"prices":     map[string]interface{}{"range": map[string]interface{}{"field": "price", "ranges": ranges}},
+		},
+	}
+
+	var resp searchResponse
+	if err := e.do(ctx, http.MethodPost, "/"+e.cfg.Index+"/_search", body, &resp); err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Total: resp.Hits.Total.Value}
+	for _, hit := range resp.Hits.Hits {
+		id, err := strconv.ParseUint(hit.ID, 10, 64)
+		if err != nil {
+			return Result{}, fmt.Errorf("unexpected document ID %q", hit.ID)
+		}
+		result.IDs = append(result.IDs, uint(id))
+	}
+	for _, b := range resp.Aggregations.Categories.Buckets {
+		if b.Key != "" {
+			result.Facets.Categories = append(result.Facets.Categories, CategoryCount{Category: b.Key, Count: b.DocCount})
+		}
+	}
+	for i, b := range resp.Aggregations.Prices.Buckets {
+		if i < len(PriceRanges) {
+			result.Facets.Prices = append(result.Facets.Prices, PriceCount{PriceRange: PriceRanges[i], Count: b.DocCount})
+		}
+	}
+	return result, nil
+}
+
+func (e *openSearch) docPath(id uint) string {
+	return "/" + e.cfg.Index + "/_doc/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// statusError is returned for responses outside the 2xx range
+type statusError int
+
+func (s statusError) Error() string {
+	return fmt.Sprintf("search engine responded with status %d", int(s))
+}
+
+// do sends body, if any, as JSON to the path and decodes the response
+// into out, if non-nil
+func (e *openSearch) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, e.cfg.URL+path, reader)
+	if err != nil {
+		return fmt.Errorf("error creating search request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if e.cfg.Username != "" {
+		req.SetBasicAuth(e.cfg.Username, e.cfg.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling search engine: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return statusError(resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding search response: %v", err)
+	}
+	return nil
+}
+
+func term(field string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{"term": map[string]interface{}{field: value}}
+}
@@ -0,0 +1,102 @@
+// Package search indexes the catalog in Elasticsearch or OpenSearch for
+// typo-tolerant, relevance-ranked item search with facets. Items are
+// indexed as they are created and updated. Without a configured engine,
+// item search falls back to SQL LIKE matching in the repository.
+package search
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+)
+
+// Query selects a page of the items of the context's store
+type Query struct {
+	// Text is matched against item names, categories and descriptions;
+	// empty matches every item
+	Text     string
+	Category string
+	MinPrice *float64
+	MaxPrice *float64
+	Limit    int
+	Offset   int
+}
+
+// Result is a page of the items matching a query, most relevant first
+type Result struct {
+	IDs   []uint
+	Total int64
+	// Facets count the items matching the query's text before its
+	// category and price filters, so every other choice can be offered
+	Facets Facets
+}
+
+type Facets struct {
+	Categories []CategoryCount `json:"categories"`
+	Prices     []PriceCount    `json:"prices"`
+}
+
+type CategoryCount struct {
+	Category string `json:"category"`
+	Count    int64  `json:"count"`
+}
+
+// PriceRange holds prices from From up to, but excluding, To; a zero To
+// leaves the range open
+type PriceRange struct {
+	From float64 `json:"from"`
+	To   float64 `json:"to,omitempty"`
+}
+
+type PriceCount struct {
+	PriceRange
+	Count int64 `json:"count"`
+}
+
+// PriceRanges are the buckets of the price facet, in ascending order
+var PriceRanges = []PriceRange{
+	{From: 0, To: 25},
+	{From: 25, To: 50},
+	{From: 50, To: 100},
+	{From: 100, To: 250},
+	{From: 250},
+}
+
+// Contains reports whether price falls in the range
+func (r PriceRange) Contains(price float64) bool {
+	return price >= r.From && (r.To == 0 || price < r.To)
+}
+
+// Engine is a search backend indexing the catalog of every store
+type Engine interface {
+	// Index adds or replaces the item's document
+	Index(ctx context.Context, item models.Item) error
+	// Delete removes the item's document, if any
+	Delete(ctx context.Context, id uint) error
+	// Search runs the query against the context's store
+	Search(ctx context.Context, q Query) (Result, error)
+	// EnsureIndex creates the index with its mappings if it is missing
+	EnsureIndex(ctx context.Context) error
+	Ping(ctx context.Context) error
+}
+
+var current Engine
+
+// Init selects the search engine: the one at the configured URL, or none,
+// leaving item search to the repository
+func Init(cfg config.SearchConfig) {
+	current = nil
+	if cfg.URL != "" {
+		current = newOpenSearch(cfg)
+	}
+}
+
+// Get returns the configured engine, or nil if there is none
+func Get() Engine {
+	return current
+}
+
+// Set replaces the engine; mainly useful for tests
+func Set(engine Engine) {
+	current = engine
+}
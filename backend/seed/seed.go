@@ -0,0 +1,196 @@
+package seed
+
+import (
+	"ecommerce-backend/models"
+	"ecommerce-backend/utils"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Fixed credentials for seeded accounts, for local development only
+const (
+	AdminUsername    = "admin"
+	AdminPassword    = "admin123"
+	CustomerPassword = "password123"
+)
+
+// randomSeed keeps the generated data identical between runs
+const randomSeed = 42
+
+// Options controls how much data Run generates
+type Options struct {
+	// Count is the number of items and of customers to create
+	Count int
+	// Reset deletes existing users, items, carts, orders and API keys first
+	Reset bool
+}
+
+// Summary reports how many records Run created
+type Summary struct {
+	Users  int
+	Items  int
+	Carts  int
+	Orders int
+}
+
+var (
+	adjectives = []string{"Classic", "Deluxe", "Eco", "Compact", "Vintage", "Smart", "Rugged", "Premium", "Travel", "Everyday"}
+	nouns      = []string{"Backpack", "Water Bottle", "Headphones", "Desk Lamp", "Notebook", "Sneakers", "Coffee Mug", "Sunglasses", "Keyboard", "Umbrella"}
+	firstNames = []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace", "heidi", "ivan", "judy"}
+	statuses   = []string{"completed", "completed", "completed", "pending"}
+)
+
+// Run populates the database with deterministic fake data in a single
+// transaction: an admin, Count customers and items, and for each customer
+// a few past orders plus an open cart.
+func Run(db *gorm.DB, opts Options) (Summary, error) {
+	var summary Summary
+	if opts.Count < 1 {
+		return summary, fmt.Errorf("count must be at least 1")
+	}
+
+	// Hash once; every customer shares the same development password
+	adminHash, err := utils.HashPassword(AdminPassword)
+	if err != nil {
+		return summary, err
+	}
+	customerHash, err := utils.HashPassword(CustomerPassword)
+	if err != nil {
+		return summary, err
+	}
+
+	rng := rand.New(rand.NewSource(randomSeed))
+	now := time.Now()
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if opts.Reset {
+			if err := reset(tx); err != nil {
+				return err
+			}
+		}
+
+		var existing int64
+		if err := tx.Model(&models.User{}).Where("username = ?", AdminUsername).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return fmt.Errorf("database already contains seed data; run with --reset to replace it")
+		}
+
+		admin := models.User{Username: AdminUsername, PasswordHash: adminHash, Role: models.RoleAdmin}
+		if err := tx.Create(&admin).Error; err != nil {
+			return err
+		}
+		summary.Users++
+
+		items := make([]models.Item, opts.Count)
+		for i := range items {
+			name := adjectives[rng.Intn(len(adjectives))] + " " + nouns[rng.Intn(len(nouns))]
+			items[i] = models.Item{
+				Name:        fmt.Sprintf("%s #%d", name, i+1),
+				Description: "A " + name + " for everyday use.",
+				Price:       float64(rng.Intn(19900)+100) / 100,
+			}
+		}
+		if err := tx.CreateInBatches(items, 100).Error; err != nil {
+			return err
+		}
+		summary.Items = len(items)
+
+		for i := 0; i < opts.Count; i++ {
+			customer := models.User{
+				Username:     fmt.Sprintf("%s%d", firstNames[i%len(firstNames)], i+1),
+				PasswordHash: customerHash,
+				Role:         models.RoleCustomer,
+			}
+			if err := tx.Create(&customer).Error; err != nil {
+				return err
+			}
+			summary.Users++
+
+			// Past orders, oldest first, followed by the open cart
+			orders := rng.Intn(4)
+			for j := 0; j <= orders; j++ {
+				checkedOut := j < orders
+				placedAt := now.Add(-time.Duration(orders-j) * 72 * time.Hour).Add(-time.Duration(rng.Intn(24)) * time.Hour)
+
+				cart := models.Cart{UserID: customer.ID}
+				cart.CreatedAt = placedAt
+				if checkedOut {
+					cart.IsCheckedOut = true
+					cart.CheckedOutAt = &placedAt
+				}
+				if err := tx.Create(&cart).Error; err != nil {
+					return err
+				}
+				summary.Carts++
+
+				total, err := fillCart(tx, rng, cart.ID, items)
+				if err != nil {
+					return err
+				}
+				if !checkedOut {
+					continue
+				}
+
+				order := models.Order{
+					Number: fmt.Sprintf("ORD-%d-%08d", placedAt.Year(), rng.Intn(100000000)),
+					UserID: customer.ID,
+					CartID: cart.ID,
+					Total:  total,
+					Status: statuses[rng.Intn(len(statuses))],
+				}
+				order.CreatedAt = placedAt
+				if err := tx.Create(&order).Error; err != nil {
+					return err
+				}
+				summary.Orders++
+			}
+		}
+
+		return nil
+	})
+
+	return summary, err
+}
+
+// fillCart adds one to three distinct items to a cart and returns its total
+func fillCart(tx *gorm.DB, rng *rand.Rand, cartID uint, items []models.Item) (float64, error) {
+	maxLines := 3
+	if len(items) < maxLines {
+		maxLines = len(items)
+	}
+
+	var total float64
+	for _, idx := range rng.Perm(len(items))[:1+rng.Intn(maxLines)] {
+		quantity := 1 + rng.Intn(3)
+		cartItem := models.CartItem{CartID: cartID, ItemID: items[idx].ID, Quantity: quantity}
+		if err := tx.Create(&cartItem).Error; err != nil {
+			return 0, err
+		}
+		total += items[idx].Price * float64(quantity)
+	}
+	return total, nil
+}
+
+// reset permanently deletes the data Run generates. Audit logs are
+// append-only and are left untouched.
+func reset(tx *gorm.DB) error {
+	for _, model := range []interface{}{
+		&models.InventoryMovement{}, &models.OrderBackorder{}, &models.OrderAllocation{}, &models.StockTransfer{},
+		&models.WarehouseStock{}, &models.Warehouse{},
+		&models.OrderPromotion{}, &models.Promotion{}, &models.SalePurchase{}, &models.Sale{}, &models.CartReminder{},
+		&models.OrderBundle{}, &models.BundleItem{}, &models.Bundle{}, &models.Download{}, &models.DigitalFile{},
+		&models.GiftCardEntry{}, &models.GiftCard{}, &models.TrackingEvent{}, &models.Shipment{},
+		&models.SubOrder{}, &models.Order{}, &models.CartItem{}, &models.Cart{}, &models.APIKey{}, &models.Item{},
+		&models.Address{}, &models.User{}, &models.Vendor{},
+	} {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"ecommerce-backend/database"
+	"ecommerce-backend/seed"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// newSeedCmd implements the `seed` subcommand
+func newSeedCmd() *cobra.Command {
+	var opts seed.Options
+
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Populate the database with deterministic demo data",
+		Args:  cobra.NoArgs,
+		RunE: withDB(func(cmd *cobra.Command, args []string) error {
+			summary, err := seed.Run(database.GetDB(), opts)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("seeded %d user(s), %d item(s), %d cart(s), %d order(s)\n",
+				summary.Users, summary.Items, summary.Carts, summary.Orders)
+			fmt.Printf("admin login: %s / %s; customers use password %s\n",
+				seed.AdminUsername, seed.AdminPassword, seed.CustomerPassword)
+			return nil
+		}),
+	}
+	cmd.Flags().IntVar(&opts.Count, "count", 20, "number of items and of customers to create")
+	cmd.Flags().BoolVar(&opts.Reset, "reset", false, "delete existing users, items, carts, orders and API keys first")
+	return cmd
+}
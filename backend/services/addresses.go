@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/addresses"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"errors"
+	"strings"
+)
+
+type AddressService struct {
+	store repository.Store
+}
+
+// Create validates the address with the address provider and saves it for
+// the user in its normalized form. Undeliverable addresses are saved too,
+// flagged with the provider's reason, so the user can see what to fix.
+func (s *AddressService) Create(ctx context.Context, userID uint, postal models.PostalAddress) (models.Address, error) {
+	address := models.Address{UserID: userID, PostalAddress: tidyAddress(postal)}
+	validateAddress(ctx, &address)
+	if err := s.store.Addresses().Create(ctx, &address); err != nil {
+		return models.Address{}, apperrors.Internal("failed to save address", err)
+	}
+	return address, nil
+}
+
+// List returns the user's saved addresses
+func (s *AddressService) List(ctx context.Context, userID uint) ([]models.Address, error) {
+	list, err := s.store.Addresses().ListByUser(ctx, userID)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch addresses", err)
+	}
+	return list, nil
+}
+
+// Delete removes one of the user's addresses. Orders shipped to it keep
+// their copy.
+func (s *AddressService) Delete(ctx context.Context, userID, id uint) error {
+	if _, err := userAddress(ctx, s.store, userID, id); err != nil {
+		return err
+	}
+	if err := s.store.Addresses().Delete(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrAddressNotFound
+		}
+		return apperrors.Internal("failed to delete address", err)
+	}
+	return nil
+}
+
+// userAddress returns the user's address with the given ID; addresses of
+// other users are reported as missing
+func userAddress(ctx context.Context, store repository.Store, userID, id uint) (models.Address, error) {
+	address, err := store.Addresses().Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Address{}, apperrors.ErrAddressNotFound
+		}
+		return models.Address{}, apperrors.Internal("failed to fetch address", err)
+	}
+	if address.UserID != userID {
+		return models.Address{}, apperrors.ErrAddressNotFound
+	}
+	return address, nil
+}
+
+// shippingAddress returns the user's address to ship an order to, checked
+// again with the address provider since it was saved. The new outcome is
+// recorded on the address; undeliverable addresses are refused.
+func shippingAddress(ctx context.Context, store repository.Store, userID, id uint) (models.PostalAddress, error) {
+	address, err := userAddress(ctx, store, userID, id)
+	if err != nil {
+		return models.PostalAddress{}, err
+	}
+
+	status, reason := address.Status, address.StatusReason
+	validateAddress(ctx, &address)
+	// A failing provider leaves the address as it was last checked
+	if address.Status == models.AddressUnverified {
+		address.Status, address.StatusReason = status, reason
+	} else if address.Status != status || address.StatusReason != reason {
+		if err := store.Addresses().SetStatus(ctx, address.ID, address.Status, address.StatusReason); err != nil {
+			return models.PostalAddress{}, apperrors.Internal("failed to update address", err)
+		}
+	}
+
+	if address.Status == models.AddressUndeliverable {
+		return models.PostalAddress{}, apperrors.ErrAddressUndeliverable.
+			WithMessage("address is undeliverable: " + address.StatusReason).
+			WithDetails(map[string]uint{"address_id": address.ID})
+	}
+	return address.PostalAddress, nil
+}
+
+// validateAddress sets the address's status from the address provider's
+// verdict and, for deliverable addresses, adopts its normalized form. A
+// failing provider leaves the address as entered and unverified.
+func validateAddress(ctx context.Context, address *models.Address) {
+	result, err := addresses.Get().Validate(ctx, address.PostalAddress)
+	if err != nil {
+		logging.FromContext(ctx).Warn("address validation failed", "error", err)
+		address.Status, address.StatusReason = models.AddressUnverified, ""
+		return
+	}
+	if !result.Deliverable {
+		address.Status, address.StatusReason = models.AddressUndeliverable, result.Reason
+		if address.StatusReason == "" {
+			address.StatusReason = "the address provider cannot deliver there"
+		}
+		return
+	}
+	address.Status, address.StatusReason = models.AddressDeliverable, ""
+	address.PostalAddress = tidyAddress(result.Address)
+}
+
+// tidyAddress trims the address's fields and upper-cases its country code
+func tidyAddress(a models.PostalAddress) models.PostalAddress {
+	for _, field := range []*string{&a.Name, &a.Line1, &a.Line2, &a.City, &a.Region, &a.PostalCode, &a.Country} {
+		*field = strings.TrimSpace(*field)
+	}
+	a.Country = strings.ToUpper(a.Country)
+	return a
+}
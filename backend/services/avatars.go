@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/storage"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"time"
+)
+
+const (
+	// AvatarSize is the width and height, in pixels, avatars are stored at
+	AvatarSize = 256
+	// maxAvatarPixels bounds the dimensions of uploaded images, so small
+	// files cannot decode to huge images
+	maxAvatarPixels = 40000000
+)
+
+// SetAvatar makes the uploaded JPEG, PNG or GIF image the user's avatar and
+// returns the updated user. The image is cropped to a centered square,
+// resized to AvatarSize and stored as a JPEG, replacing any earlier avatar.
+func (s *UserService) SetAvatar(ctx context.Context, userID uint, upload io.Reader) (models.User, error) {
+	data, err := io.ReadAll(upload)
+	if err != nil {
+		return models.User{}, apperrors.Internal("failed to read avatar", err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return models.User{}, apperrors.Validation("avatar must be a JPEG, PNG or GIF image")
+	}
+	if cfg.Width*cfg.Height > maxAvatarPixels {
+		return models.User{}, apperrors.Validation("avatar image is too large")
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return models.User{}, apperrors.Validation("avatar must be a JPEG, PNG or GIF image")
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, squareThumbnail(img, AvatarSize), &jpeg.Options{Quality: 90}); err != nil {
+		return models.User{}, apperrors.Internal("failed to encode avatar", err)
+	}
+	url, err := storage.Get().Put(ctx, avatarKey(userID), &out, "image/jpeg")
+	if err != nil {
+		return models.User{}, apperrors.Internal("failed to store avatar", err)
+	}
+
+	// The file is replaced in place, so the URL changes with each upload
+	// to get past caches
+	url = fmt.Sprintf("%s?v=%d", url, time.Now().Unix())
+	if err := s.store.Users().SetAvatar(ctx, userID, url); err != nil {
+		return models.User{}, apperrors.Internal("failed to update avatar", err)
+	}
+	return s.Get(ctx, userID)
+}
+
+// avatarKey is where the user's avatar is stored
+func avatarKey(userID uint) string {
+	return fmt.Sprintf("avatars/%d.jpg", userID)
+}
+
+// squareThumbnail crops the largest centered square from img and scales it
+// to size by size pixels, averaging the pixels each one covers.
+// Transparent areas become white, as JPEG has no transparency.
+func squareThumbnail(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		sy0 := y * side / size
+		sy1 := max(sy0+1, (y+1)*side/size)
+		for x := 0; x < size; x++ {
+			sx0 := x * side / size
+			sx1 := max(sx0+1, (x+1)*side/size)
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := img.At(x0+sx, y0+sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			// The channels are alpha-premultiplied, so adding the
+			// uncovered share of white composites onto a white background
+			white := 0xffff - a/n
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r/n + white),
+				G: uint16(g/n + white),
+				B: uint16(bl/n + white),
+				A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"errors"
+	"time"
+)
+
+// SetBackorder lets the item sell beyond its stock on behalf of actor,
+// backordered or pre-ordered by mode and expected at expectedAt, or stops
+// it with an empty mode. It returns the updated item. version is the
+// version the update was based on, as for updateItem.
+func (s *ItemService) SetBackorder(ctx context.Context, actor models.User, id uint, version *int, mode string, expectedAt *time.Time) (models.Item, error) {
+	if mode == "" {
+		expectedAt = nil
+	}
+	return s.updateItem(ctx, actor, id, version, func(tx repository.Store, item models.Item) error {
+		if err := tx.Items().SetBackorder(ctx, id, item.Version, mode, expectedAt); err != nil {
+			return updateFailed("failed to update item", err)
+		}
+		return nil
+	})
+}
+
+// Backorders returns the backorders not yet fulfilled, only of the item if
+// itemID is not 0, oldest first
+func (s *OrderService) Backorders(ctx context.Context, itemID uint) ([]models.OrderBackorder, error) {
+	backorders, err := s.store.Backorders().Outstanding(ctx, itemID)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch backorders", err)
+	}
+	return backorders, nil
+}
+
+// FulfillBackorder takes a backorder's units from the item's stock on
+// behalf of actor, now that it is restocked or released, allocating them
+// to the warehouses holding it, and marks the backorder fulfilled
+func (s *OrderService) FulfillBackorder(ctx context.Context, actor models.User, id uint) (models.OrderBackorder, error) {
+	var backorder models.OrderBackorder
+	err := retryOnConflict(func() error {
+		return s.store.Transaction(ctx, func(tx repository.Store) error {
+			var err error
+			if backorder, err = tx.Backorders().Get(ctx, id); err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return apperrors.ErrBackorderNotFound
+				}
+				return apperrors.Internal("failed to fetch backorder", err)
+			}
+			if backorder.FulfilledAt != nil {
+				return apperrors.Validation("backorder was already fulfilled")
+			}
+			order, err := tx.Orders().Get(ctx, backorder.OrderID)
+			if err != nil {
+				return apperrors.Internal("failed to fetch order", err)
+			}
+			if order.Status == models.OrderCancelled {
+				return apperrors.Validation("the order was cancelled")
+			}
+			item, err := tx.Items().Get(ctx, backorder.ItemID)
+			if err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return apperrors.ErrItemNotFound
+				}
+				return apperrors.Internal("failed to fetch item", err)
+			}
+
+			line := models.CartItem{ItemID: item.ID, Item: item, Quantity: backorder.Quantity}
+			ok, err := tx.Items().ReserveStock(ctx, item.ID, backorder.Quantity)
+			if err != nil {
+				return apperrors.Internal("failed to reserve stock", err)
+			}
+			if !ok {
+				return insufficientStock(line)
+			}
+			if item.Stock != nil {
+				err := recordMovements(ctx, tx, models.InventoryMovement{
+					ItemID:  item.ID,
+					Delta:   -backorder.Quantity,
+					Reason:  models.MovementBackorder,
+					ActorID: &actor.ID,
+					OrderID: &backorder.OrderID,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			allocations, err := allocate(ctx, tx, models.Cart{CartItems: []models.CartItem{line}})
+			if err != nil {
+				return err
+			}
+			for i := range allocations {
+				allocations[i].OrderID = backorder.OrderID
+			}
+			return tx.Backorders().Fulfill(ctx, &backorder, allocations, time.Now())
+		})
+	})
+	if err != nil {
+		return models.OrderBackorder{}, orInternal("failed to fulfill backorder", err)
+	}
+	return backorder, nil
+}
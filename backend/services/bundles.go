@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"errors"
+)
+
+type BundleService struct {
+	store repository.Store
+}
+
+// Create adds a bundle to the store. Its items must exist and must not be
+// gift cards, which are worth their own price.
+func (s *BundleService) Create(ctx context.Context, bundle *models.Bundle) error {
+	ids := make([]uint, len(bundle.Items))
+	for i, bi := range bundle.Items {
+		ids[i] = bi.ItemID
+	}
+	items, err := s.store.Items().GetMany(ctx, ids)
+	if err != nil {
+		return apperrors.Internal("failed to fetch items", err)
+	}
+	found := make(map[uint]models.Item, len(items))
+	for _, item := range items {
+		found[item.ID] = item
+	}
+	for i, bi := range bundle.Items {
+		item, ok := found[bi.ItemID]
+		if !ok {
+			return apperrors.ErrItemNotFound.WithDetails(map[string]uint{"item_id": bi.ItemID})
+		}
+		if item.GiftCard {
+			return apperrors.Validation("gift cards cannot be bundled")
+		}
+		bundle.Items[i].Item = item
+	}
+
+	if err := s.store.Bundles().Create(ctx, bundle); err != nil {
+		return apperrors.Internal("failed to create bundle", err)
+	}
+	return nil
+}
+
+// Get returns a bundle with its items, or ErrBundleNotFound
+func (s *BundleService) Get(ctx context.Context, id uint) (models.Bundle, error) {
+	bundle, err := s.store.Bundles().Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Bundle{}, apperrors.ErrBundleNotFound
+		}
+		return models.Bundle{}, apperrors.Internal("failed to fetch bundle", err)
+	}
+	return bundle, nil
+}
+
+// List returns the store's bundles with their items
+func (s *BundleService) List(ctx context.Context) ([]models.Bundle, error) {
+	bundles, err := s.store.Bundles().List(ctx)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch bundles", err)
+	}
+	return bundles, nil
+}
+
+// Delete removes a bundle. Its lines in open carts are then priced as
+// separate items, and orders keep the bundles they were sold.
+func (s *BundleService) Delete(ctx context.Context, id uint) error {
+	if err := s.store.Bundles().Delete(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrBundleNotFound
+		}
+		return apperrors.Internal("failed to delete bundle", err)
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/repository"
+	"errors"
+	"expvar"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Cart creation metrics, exposed via /debug/vars
+var (
+	cartsCreated      = expvar.NewInt("carts_created")
+	cartsConsolidated = expvar.NewInt("carts_consolidated")
+	cartQuotaExceeded = expvar.NewInt("cart_quota_exceeded")
+)
+
+type CartService struct {
+	store repository.Store
+	// cfg holds the soft quota of open carts per user, the abandoned
+	// cart reminder settings and the lifetime of sharing links
+	cfg config.CartConfig
+	// secret signs cart sharing links
+	secret string
+}
+
+// AddItem adds quantity of an item to the user's open cart, creating the
+// cart if needed, and returns the cart. It is retried when another request
+// changes the cart at the same time, so neither change is lost.
+func (s *CartService) AddItem(ctx context.Context, userID, itemID uint, quantity int) (models.Cart, error) {
+	return s.update(ctx, userID, func(tx repository.Store, cart models.Cart) error {
+		item, err := tx.Items().Get(ctx, itemID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return apperrors.ErrItemNotFound
+			}
+			return apperrors.Internal("failed to fetch item", err)
+		}
+		if !item.IsActive {
+			return apperrors.ErrItemInactive.WithDetails(map[string]uint{"item_id": itemID})
+		}
+		if err := addLine(ctx, tx, cart.ID, itemID, nil, quantity); err != nil {
+			return err
+		}
+		return checkCartQuantities(ctx, tx, userID, cart.ID, []uint{itemID})
+	})
+}
+
+// AddBundle adds quantity units of a bundle to the user's open cart,
+// creating the cart if needed, and returns the cart. Kept as a unit, the
+// bundle's items are added as its lines and priced together at the bundle
+// price; expanded, they are added as separate items at their own prices.
+func (s *CartService) AddBundle(ctx context.Context, userID, bundleID uint, quantity int, expand bool) (models.Cart, error) {
+	return s.update(ctx, userID, func(tx repository.Store, cart models.Cart) error {
+		bundle, err := tx.Bundles().Get(ctx, bundleID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return apperrors.ErrBundleNotFound
+			}
+			return apperrors.Internal("failed to fetch bundle", err)
+		}
+		var unit *uint
+		if !expand {
+			unit = &bundle.ID
+		}
+		itemIDs := make([]uint, 0, len(bundle.Items))
+		for _, bi := range bundle.Items {
+			// Items deleted since the bundle was made leave it incomplete
+			if bi.Item.ID == 0 {
+				return apperrors.ErrItemNotFound.
+					WithMessage("an item of the bundle is no longer sold").
+					WithDetails(map[string]uint{"item_id": bi.ItemID, "bundle_id": bundle.ID})
+			}
+			if !bi.Item.IsActive {
+				return apperrors.ErrItemInactive.
+					WithMessage("an item of the bundle is not for sale").
+					WithDetails(map[string]uint{"item_id": bi.ItemID, "bundle_id": bundle.ID})
+			}
+			if err := addLine(ctx, tx, cart.ID, bi.ItemID, unit, bi.Quantity*quantity); err != nil {
+				return err
+			}
+			itemIDs = append(itemIDs, bi.ItemID)
+		}
+		return checkCartQuantities(ctx, tx, userID, cart.ID, itemIDs)
+	})
+}
+
+// update runs fn on the user's open cart in a transaction, creating the
+// cart if needed, records the change and returns the cart. It is retried
+// when another request changes the cart at the same time.
+func (s *CartService) update(ctx context.Context, userID uint, fn func(tx repository.Store, cart models.Cart) error) (models.Cart, error) {
+	var cart models.Cart
+	err := retryOnConflict(func() error {
+		return s.store.Transaction(ctx, func(tx repository.Store) error {
+			var err error
+			if cart, err = s.openCart(ctx, tx, userID); err != nil {
+				return apperrors.Internal("failed to get or create cart", err)
+			}
+			if err := fn(tx, cart); err != nil {
+				return err
+			}
+
+			// Carts are abandoned once they go unchanged for a while
+			if err := tx.Carts().Touch(ctx, &cart, time.Now()); err != nil {
+				return apperrors.Internal("failed to update cart", err)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return models.Cart{}, orInternal("failed to update cart", err)
+	}
+	return cart, nil
+}
+
+// addLine adds quantity of an item to the cart, as part of the bundle if
+// bundleID is set, increasing the quantity of the matching line if there
+// is one
+func addLine(ctx context.Context, tx repository.Store, cartID, itemID uint, bundleID *uint, quantity int) error {
+	cartItem, err := tx.Carts().FindItem(ctx, cartID, itemID, bundleID)
+	switch {
+	case err == nil:
+		// Item already in cart, update quantity
+		cartItem.Quantity += quantity
+		if err := tx.Carts().UpdateQuantity(ctx, &cartItem); err != nil {
+			return apperrors.Internal("failed to update cart", err)
+		}
+	case errors.Is(err, repository.ErrNotFound):
+		cartItem = models.CartItem{
+			CartID:   cartID,
+			ItemID:   itemID,
+			Quantity: quantity,
+			BundleID: bundleID,
+		}
+		if err := tx.Carts().CreateItem(ctx, &cartItem); err != nil {
+			return apperrors.Internal("failed to add item to cart", err)
+		}
+	default:
+		return apperrors.Internal("failed to process cart", err)
+	}
+	return nil
+}
+
+// checkCartQuantities checks the quantities of the given items in the
+// cart, once lines were added to it, against the items' limits
+func checkCartQuantities(ctx context.Context, tx repository.Store, userID, cartID uint, itemIDs []uint) error {
+	cart, err := tx.Carts().Get(ctx, cartID)
+	if err != nil {
+		return apperrors.Internal("failed to fetch cart", err)
+	}
+	return checkQuantities(ctx, tx, userID, cart, itemIDs)
+}
+
+// checkQuantities checks the quantities of the given items in the cart,
+// or of all its items if itemIDs is nil: the cart must hold at least each
+// item's minimum and, with the units the user already ordered, at most its
+// maximum
+func checkQuantities(ctx context.Context, tx repository.Store, userID uint, cart models.Cart, itemIDs []uint) error {
+	quantities := map[uint]int{}
+	items := map[uint]models.Item{}
+	for _, ci := range cart.CartItems {
+		if itemIDs == nil || slices.Contains(itemIDs, ci.ItemID) {
+			quantities[ci.ItemID] += ci.Quantity
+			items[ci.ItemID] = ci.Item
+		}
+	}
+
+	// Items are checked in ID order, so the same cart always fails on the
+	// same item
+	ids := make([]uint, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	var limited []uint
+	for _, id := range ids {
+		item := items[id]
+		if item.MinQuantity > 0 && quantities[id] < item.MinQuantity {
+			return apperrors.ErrQuantityTooLow.
+				WithMessage(fmt.Sprintf("%s must be ordered at least %d at a time", item.Name, item.MinQuantity)).
+				WithDetails(map[string]int{"item_id": int(id), "min_quantity": item.MinQuantity})
+		}
+		if item.MaxQuantity > 0 {
+			limited = append(limited, id)
+		}
+	}
+	if len(limited) == 0 {
+		return nil
+	}
+
+	purchased, err := tx.Orders().Purchased(ctx, userID, limited)
+	if err != nil {
+		return apperrors.Internal("failed to fetch purchases", err)
+	}
+	for _, id := range limited {
+		item := items[id]
+		if purchased[id]+quantities[id] > item.MaxQuantity {
+			return apperrors.ErrQuantityTooHigh.
+				WithMessage(fmt.Sprintf("%s is limited to %d per customer", item.Name, item.MaxQuantity)).
+				WithDetails(map[string]int{
+					"item_id":      int(id),
+					"max_quantity": item.MaxQuantity,
+					"purchased":    purchased[id],
+				})
+		}
+	}
+	return nil
+}
+
+// openCart returns the user's open cart, creating one if none exists.
+// Users holding more open carts than the soft quota allows have the extra
+// carts merged into the oldest one instead of being rejected.
+func (s *CartService) openCart(ctx context.Context, tx repository.Store, userID uint) (models.Cart, error) {
+	carts, err := tx.Carts().OpenCarts(ctx, userID)
+	if err != nil {
+		return models.Cart{}, err
+	}
+
+	if len(carts) == 0 {
+		cart := models.Cart{UserID: userID}
+		if err := tx.Carts().Create(ctx, &cart); err != nil {
+			return models.Cart{}, err
+		}
+		cartsCreated.Add(1)
+		return cart, nil
+	}
+
+	if len(carts) > s.cfg.MaxOpen {
+		cartQuotaExceeded.Add(1)
+		if err := consolidateCarts(ctx, tx, &carts[0], carts[1:]); err != nil {
+			return models.Cart{}, err
+		}
+	}
+
+	return carts[0], nil
+}
+
+// consolidateCarts moves the items of the duplicate carts into primary,
+// summing quantities for items present in both, and deletes the duplicates.
+// Lines of a bundle are only merged with lines of the same bundle.
+func consolidateCarts(ctx context.Context, tx repository.Store, primary *models.Cart, duplicates []models.Cart) error {
+	type line struct{ itemID, bundleID uint }
+	key := func(ci models.CartItem) line {
+		if ci.BundleID == nil {
+			return line{ci.ItemID, 0}
+		}
+		return line{ci.ItemID, *ci.BundleID}
+	}
+	index := make(map[line]int, len(primary.CartItems))
+	for i, ci := range primary.CartItems {
+		index[key(ci)] = i
+	}
+
+	for _, dup := range duplicates {
+		for _, ci := range dup.CartItems {
+			if i, ok := index[key(ci)]; ok {
+				primary.CartItems[i].Quantity += ci.Quantity
+				if err := tx.Carts().UpdateQuantity(ctx, &primary.CartItems[i]); err != nil {
+					return err
+				}
+				if err := tx.Carts().DeleteItem(ctx, ci.ID); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := tx.Carts().MoveItem(ctx, ci.ID, primary.ID); err != nil {
+				return err
+			}
+			ci.CartID = primary.ID
+			index[key(ci)] = len(primary.CartItems)
+			primary.CartItems = append(primary.CartItems, ci)
+		}
+
+		if err := tx.Carts().Delete(ctx, dup.ID); err != nil {
+			return err
+		}
+		cartsConsolidated.Add(1)
+	}
+
+	return nil
+}
+
+// OpenCart returns the user's active cart with its items, or ErrCartNotFound
+func (s *CartService) OpenCart(ctx context.Context, userID uint) (models.Cart, error) {
+	cart, err := s.store.Carts().OpenCart(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Cart{}, apperrors.ErrCartNotFound
+		}
+		return models.Cart{}, apperrors.Internal("failed to fetch cart", err)
+	}
+	return cart, nil
+}
+
+// Each calls fn for every cart on the page and returns the next cursor
+func (s *CartService) Each(ctx context.Context, page pagination.Page, fn func(*models.Cart) error) (string, error) {
+	return s.store.Carts().Each(ctx, page, fn)
+}
+
+// Locate returns the position of the page among all carts
+func (s *CartService) Locate(ctx context.Context, page pagination.Page) (pagination.Position, error) {
+	pos, err := s.store.Carts().Locate(ctx, page)
+	if err != nil {
+		return pagination.Position{}, apperrors.Internal("failed to count carts", err)
+	}
+	return pos, nil
+}
+
+// Total sums the price of the cart's items
+func Total(cart models.Cart) float64 {
+	var total float64
+	for _, ci := range cart.CartItems {
+		total += ci.Item.Price * float64(ci.Quantity)
+	}
+	return total
+}
+
+// Shippable returns the cart with only the lines that are shipped, leaving
+// out digital items
+func Shippable(cart models.Cart) models.Cart {
+	shipped := cart
+	shipped.CartItems = nil
+	for _, ci := range cart.CartItems {
+		if !ci.Item.Digital {
+			shipped.CartItems = append(shipped.CartItems, ci)
+		}
+	}
+	return shipped
+}
+
+// Weight sums the shipping weight of the cart's items in kilograms
+func Weight(cart models.Cart) float64 {
+	var weight float64
+	for _, ci := range cart.CartItems {
+		weight += ci.Item.WeightKg * float64(ci.Quantity)
+	}
+	return weight
+}
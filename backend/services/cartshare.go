@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CartShareLink is a signed link through which other users import a cart
+type CartShareLink struct {
+	URL       string
+	ExpiresAt time.Time
+}
+
+// Share returns a signed link to the user's open cart, working for the
+// configured share TTL while the cart is unchanged
+func (s *CartService) Share(ctx context.Context, userID uint) (CartShareLink, error) {
+	cart, err := s.OpenCart(ctx, userID)
+	if err != nil {
+		return CartShareLink{}, err
+	}
+	if len(cart.CartItems) == 0 {
+		return CartShareLink{}, apperrors.ErrCartEmpty
+	}
+
+	expires := time.Now().Add(s.cfg.ShareTTL).Truncate(time.Second)
+	return CartShareLink{
+		URL: fmt.Sprintf("/api/v1/cart/shared/%d?expires=%d&signature=%s",
+			cart.ID, expires.Unix(), hex.EncodeToString(s.shareMAC(cart, expires.Unix()))),
+		ExpiresAt: expires,
+	}, nil
+}
+
+// Import checks a signed link to a shared cart and adds the cart's lines
+// to the user's open cart, returning the user's cart. Lines of a bundle
+// stay part of it. Nothing is imported if any item is no longer for sale.
+func (s *CartService) Import(ctx context.Context, userID, cartID uint, expires int64, signature string) (models.Cart, error) {
+	if time.Now().Unix() > expires {
+		return models.Cart{}, apperrors.ErrCartShareInvalid
+	}
+	shared, err := s.store.Carts().Get(ctx, cartID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Cart{}, apperrors.ErrCartShareInvalid
+		}
+		return models.Cart{}, apperrors.Internal("failed to fetch cart", err)
+	}
+	given, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(given, s.shareMAC(shared, expires)) {
+		return models.Cart{}, apperrors.ErrCartShareInvalid
+	}
+	if shared.UserID == userID {
+		return models.Cart{}, apperrors.Validation("a cart cannot be imported by its owner")
+	}
+
+	return s.update(ctx, userID, func(tx repository.Store, cart models.Cart) error {
+		itemIDs := make([]uint, 0, len(shared.CartItems))
+		for _, line := range shared.CartItems {
+			if line.Item.ID == 0 {
+				return apperrors.ErrItemNotFound.
+					WithMessage("an item of the shared cart is no longer sold").
+					WithDetails(map[string]uint{"item_id": line.ItemID})
+			}
+			if !line.Item.IsActive {
+				return apperrors.ErrItemInactive.
+					WithMessage("an item of the shared cart is not for sale").
+					WithDetails(map[string]uint{"item_id": line.ItemID})
+			}
+			if err := addLine(ctx, tx, cart.ID, line.ItemID, line.BundleID, line.Quantity); err != nil {
+				return err
+			}
+			itemIDs = append(itemIDs, line.ItemID)
+		}
+		return checkCartQuantities(ctx, tx, userID, cart.ID, itemIDs)
+	})
+}
+
+// shareMAC authenticates the cart, its version and the link's expiry, so
+// links stop working once they expire or the cart changes
+func (s *CartService) shareMAC(cart models.Cart, expires int64) []byte {
+	mac := hmac.New(sha256.New, []byte(s.secret))
+	fmt.Fprintf(mac, "cart:%d:%d:%d", cart.ID, cart.Version, expires)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"fmt"
+	"strings"
+)
+
+// checkoutRule checks the order the cart is about to become, priced as
+// given, returning the violation if it breaks the rule. The configured
+// rules are the built-in checkout validators.
+type checkoutRule func(cart models.Cart, pricing Pricing) *apperrors.Violation
+
+func (r checkoutRule) Validate(ctx context.Context, store repository.Store, cart models.Cart, pricing Pricing) (*apperrors.Violation, error) {
+	return r(cart, pricing), nil
+}
+
+// checkoutRules builds the pipeline of rules orders must meet at checkout
+// from the configuration
+func checkoutRules(cfg config.CheckoutConfig) []CheckoutValidator {
+	var rules []CheckoutValidator
+	if cfg.MinTotal > 0 {
+		rules = append(rules, minOrderTotal(cfg.MinTotal))
+	}
+	if cfg.MaxItems > 0 {
+		rules = append(rules, maxOrderItems(cfg.MaxItems))
+	}
+	for _, combination := range cfg.RestrictedCombinations {
+		rules = append(rules, restrictedCombination(config.SplitCombination(combination)))
+	}
+	return rules
+}
+
+// checkCheckoutRules runs every rule, then the registered checkout
+// validators, so that customers learn of all the violations at once, and
+// returns them as an ErrCheckoutRules
+func checkCheckoutRules(ctx context.Context, tx repository.Store, rules []CheckoutValidator, cart models.Cart, pricing Pricing) error {
+	validators := append([]CheckoutValidator(nil), rules...)
+	for _, h := range registered(&checkoutValidators) {
+		validators = append(validators, h.hook)
+	}
+	var violations []apperrors.Violation
+	for _, validator := range validators {
+		v, err := validator.Validate(ctx, tx, cart, pricing)
+		if err != nil {
+			return apperrors.Internal("failed to check checkout rules", err)
+		}
+		if v != nil {
+			violations = append(violations, *v)
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return apperrors.ErrCheckoutRules.WithDetails(map[string][]apperrors.Violation{"violations": violations})
+}
+
+// minOrderTotal requires the items to come to at least minTotal after
+// discounts
+func minOrderTotal(minTotal float64) checkoutRule {
+	return func(cart models.Cart, pricing Pricing) *apperrors.Violation {
+		if pricing.Total >= minTotal {
+			return nil
+		}
+		return &apperrors.Violation{
+			Rule:    "min_order_total",
+			Message: fmt.Sprintf("orders must come to at least %.2f before shipping", minTotal),
+			Params:  map[string]interface{}{"min_total": minTotal, "total": pricing.Total},
+		}
+	}
+}
+
+// maxOrderItems limits the units of all items in an order
+func maxOrderItems(maxItems int) checkoutRule {
+	return func(cart models.Cart, pricing Pricing) *apperrors.Violation {
+		units := 0
+		for _, ci := range cart.CartItems {
+			units += ci.Quantity
+		}
+		if units <= maxItems {
+			return nil
+		}
+		return &apperrors.Violation{
+			Rule:    "max_order_items",
+			Message: fmt.Sprintf("orders may hold at most %d items", maxItems),
+			Params:  map[string]interface{}{"max_items": maxItems, "items": units},
+		}
+	}
+}
+
+// restrictedCombination refuses orders holding items of every one of the
+// categories
+func restrictedCombination(categories []string) checkoutRule {
+	return func(cart models.Cart, pricing Pricing) *apperrors.Violation {
+		held := map[string][]uint{}
+		for _, ci := range cart.CartItems {
+			held[ci.Item.Category] = append(held[ci.Item.Category], ci.ItemID)
+		}
+		var itemIDs []uint
+		for _, category := range categories {
+			if len(held[category]) == 0 {
+				return nil
+			}
+			itemIDs = append(itemIDs, held[category]...)
+		}
+		return &apperrors.Violation{
+			Rule:    "restricted_combination",
+			Message: "items of the categories " + strings.Join(categories, ", ") + " cannot be ordered together",
+			Params:  map[string]interface{}{"categories": categories, "item_ids": itemIDs},
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/currency"
+	"ecommerce-backend/logging"
+	"errors"
+	"strings"
+)
+
+// ExchangeRate returns the code of the currency, upper-cased, and how much
+// of it one unit of the base currency buys; "" is the base currency
+func ExchangeRate(ctx context.Context, code string) (string, float64, error) {
+	converter := currency.Get()
+	if code == "" {
+		return converter.Base(), 1, nil
+	}
+	rate, err := converter.Rate(ctx, code)
+	if errors.Is(err, currency.ErrUnsupported) {
+		return "", 0, apperrors.ErrUnsupportedCurrency.WithDetails(map[string]string{"currency": code})
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to fetch exchange rates", "error", err)
+		return "", 0, apperrors.ErrExchangeRatesUnavailable
+	}
+	return strings.ToUpper(code), rate, nil
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// dateLayout is the layout of delivery dates
+const dateLayout = "2006-01-02"
+
+type DeliveryService struct {
+	store    repository.Store
+	calendar deliveryCalendar
+}
+
+// SlotAvailability is a delivery slot on a date, with the orders it can
+// still take
+type SlotAvailability struct {
+	Slot      models.DeliverySlot
+	Date      string
+	Remaining int
+}
+
+// CreateSlot adds a delivery slot to the store. Its times are given as
+// HH:MM, or H:MM, and the slot must end after it starts.
+func (s *DeliveryService) CreateSlot(ctx context.Context, slot *models.DeliverySlot) error {
+	start, err := time.Parse("15:04", slot.StartTime)
+	if err != nil {
+		return apperrors.Validation("start_time must be a time of day such as 09:00")
+	}
+	end, err := time.Parse("15:04", slot.EndTime)
+	if err != nil {
+		return apperrors.Validation("end_time must be a time of day such as 12:00")
+	}
+	if !end.After(start) {
+		return apperrors.Validation("end_time must be after start_time")
+	}
+	slot.StartTime, slot.EndTime = start.Format("15:04"), end.Format("15:04")
+
+	if err := s.store.Delivery().CreateSlot(ctx, slot); err != nil {
+		return apperrors.Internal("failed to create delivery slot", err)
+	}
+	return nil
+}
+
+// Slots returns the store's delivery slots by start time
+func (s *DeliveryService) Slots(ctx context.Context) ([]models.DeliverySlot, error) {
+	slots, err := s.store.Delivery().ListSlots(ctx)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch delivery slots", err)
+	}
+	return slots, nil
+}
+
+// DeleteSlot removes a delivery slot. Orders booked into it keep their
+// date and window.
+func (s *DeliveryService) DeleteSlot(ctx context.Context, id uint) error {
+	if err := s.store.Delivery().DeleteSlot(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrDeliverySlotNotFound
+		}
+		return apperrors.Internal("failed to delete delivery slot", err)
+	}
+	return nil
+}
+
+// Available returns the store's slots on each date that can be booked
+// from now on, by date and start time, with the orders each can still
+// take. Slots starting within the lead time are left out; full ones are
+// listed with none remaining.
+func (s *DeliveryService) Available(ctx context.Context) ([]SlotAvailability, error) {
+	slots, err := s.Slots(ctx)
+	if err != nil || len(slots) == 0 {
+		return nil, err
+	}
+
+	now := time.Now()
+	dates := s.calendar.dates(now)
+	bookings, err := s.store.Delivery().Bookings(ctx, dates[0], dates[len(dates)-1])
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch delivery bookings", err)
+	}
+	type slotDate struct {
+		slotID uint
+		date   string
+	}
+	booked := map[slotDate]int{}
+	for _, booking := range bookings {
+		booked[slotDate{booking.SlotID, booking.Date}] = booking.Booked
+	}
+
+	var available []SlotAvailability
+	for _, date := range dates {
+		for _, slot := range slots {
+			if !s.calendar.open(slot, date, now) {
+				continue
+			}
+			available = append(available, SlotAvailability{
+				Slot:      slot,
+				Date:      date,
+				Remaining: max(0, slot.Capacity-booked[slotDate{slot.ID, date}]),
+			})
+		}
+	}
+	return available, nil
+}
+
+// Timezone returns the time zone the slots' times of day are in
+func (s *DeliveryService) Timezone() string {
+	return s.calendar.loc.String()
+}
+
+// deliveryCalendar decides which dates delivery slots can be booked on
+type deliveryCalendar struct {
+	days     int
+	leadTime time.Duration
+	loc      *time.Location
+}
+
+func newDeliveryCalendar(cfg config.DeliveryConfig) deliveryCalendar {
+	// The time zone is checked when the configuration is loaded
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return deliveryCalendar{days: max(1, cfg.Days), leadTime: cfg.LeadTime, loc: loc}
+}
+
+// dates returns the dates that can be booked, from today on
+func (c deliveryCalendar) dates(now time.Time) []string {
+	today := now.In(c.loc)
+	dates := make([]string, c.days)
+	for i := range dates {
+		dates[i] = today.AddDate(0, 0, i).Format(dateLayout)
+	}
+	return dates
+}
+
+// open reports whether the slot can still be booked on the date: one of
+// the calendar's dates, with the slot starting no sooner than the lead
+// time from now
+func (c deliveryCalendar) open(slot models.DeliverySlot, date string, now time.Time) bool {
+	dates := c.dates(now)
+	if date < dates[0] || date > dates[len(dates)-1] {
+		return false
+	}
+	start, err := time.ParseInLocation(dateLayout+" 15:04", date+" "+slot.StartTime, c.loc)
+	return err == nil && start.Sub(now) >= c.leadTime
+}
+
+// bookDelivery books the slot chosen at checkout on the date and returns
+// it. Choosing none is only allowed while the store has no slots, or when
+// the cart holds only digital items, which are never delivered.
+func bookDelivery(ctx context.Context, tx repository.Store, calendar deliveryCalendar, slotID uint, date string, cart models.Cart) (*models.DeliverySlot, error) {
+	if len(Shippable(cart).CartItems) == 0 {
+		return nil, nil
+	}
+	if slotID == 0 {
+		slots, err := tx.Delivery().ListSlots(ctx)
+		if err != nil {
+			return nil, apperrors.Internal("failed to fetch delivery slots", err)
+		}
+		if len(slots) > 0 {
+			return nil, apperrors.ErrDeliverySlotRequired
+		}
+		return nil, nil
+	}
+	if date == "" {
+		return nil, apperrors.ErrDeliverySlotRequired
+	}
+	if _, err := time.Parse(dateLayout, date); err != nil {
+		return nil, apperrors.Validation("delivery_date must be a date such as 2024-05-31")
+	}
+
+	slot, err := tx.Delivery().GetSlot(ctx, slotID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, apperrors.ErrDeliverySlotNotFound
+		}
+		return nil, apperrors.Internal("failed to fetch delivery slot", err)
+	}
+	details := map[string]interface{}{"delivery_slot_id": slot.ID, "delivery_date": date}
+	if !calendar.open(slot, date, time.Now()) {
+		return nil, apperrors.ErrDeliverySlotClosed.WithDetails(details)
+	}
+
+	ok, err := tx.Delivery().Book(ctx, slot, date)
+	if err != nil {
+		return nil, apperrors.Internal("failed to book delivery slot", err)
+	}
+	if !ok {
+		return nil, apperrors.ErrDeliverySlotFull.
+			WithMessage(fmt.Sprintf("the %s delivery slot is fully booked on %s", slot.Window(), date)).
+			WithDetails(details)
+	}
+	return &slot, nil
+}
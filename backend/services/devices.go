@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"errors"
+	"strings"
+	"time"
+)
+
+type DeviceService struct {
+	store repository.Store
+}
+
+// Register saves the token the user's mobile app receives push
+// notifications with. Apps register their token each time they start, which
+// keeps it from being purged as stale; a token registered by another user
+// of the same device moves to this user.
+func (s *DeviceService) Register(ctx context.Context, userID uint, platform, token string) (models.DeviceToken, error) {
+	device := models.DeviceToken{
+		UserID:     userID,
+		Platform:   platform,
+		Token:      strings.TrimSpace(token),
+		LastSeenAt: time.Now(),
+	}
+	if err := s.store.Devices().Register(ctx, &device); err != nil {
+		return models.DeviceToken{}, apperrors.Internal("failed to register device", err)
+	}
+	return device, nil
+}
+
+// List returns the user's registered devices
+func (s *DeviceService) List(ctx context.Context, userID uint) ([]models.DeviceToken, error) {
+	list, err := s.store.Devices().ListByUser(ctx, userID)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch devices", err)
+	}
+	return list, nil
+}
+
+// Delete stops push notifications to one of the user's devices, as when
+// they log out of the app
+func (s *DeviceService) Delete(ctx context.Context, userID, id uint) error {
+	device, err := s.store.Devices().Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && device.UserID != userID) {
+		return apperrors.ErrDeviceNotFound
+	}
+	if err != nil {
+		return apperrors.Internal("failed to fetch device", err)
+	}
+	if err := s.store.Devices().Delete(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrDeviceNotFound
+		}
+		return apperrors.Internal("failed to delete device", err)
+	}
+	return nil
+}
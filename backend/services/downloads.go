@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/config"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/storage"
+	"ecommerce-backend/tenant"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// DownloadService delivers the files of digital items to their buyers
+// through signed, expiring links
+type DownloadService struct {
+	store repository.Store
+	cfg   config.DownloadConfig
+	// secret signs download links
+	secret string
+}
+
+// DownloadLink is a signed link to a download
+type DownloadLink struct {
+	URL       string
+	ExpiresAt time.Time
+}
+
+// SetFile makes the uploaded file the file buyers of the item download on
+// behalf of actor, replacing any earlier one, and returns the item, now
+// digital. Gift cards cannot be digital items. version is the version the
+// upload was based on, as for updateItem. Each upload is stored under a
+// key of its own, so a rejected one never replaces the file in use.
+func (s *ItemService) SetFile(ctx context.Context, actor models.User, itemID uint, version *int, name, contentType string, upload io.Reader) (models.Item, error) {
+	item, err := s.Get(ctx, itemID)
+	if err != nil {
+		return models.Item{}, err
+	}
+	if !CanManageItem(actor, item) {
+		return models.Item{}, apperrors.ErrForbidden.WithMessage("item belongs to another vendor")
+	}
+	if item.GiftCard {
+		return models.Item{}, apperrors.Validation("gift cards cannot be digital items")
+	}
+	// Checked before storing the upload too, so a stale one is not stored
+	// only to be thrown away
+	if version != nil && *version != item.Version {
+		return models.Item{}, apperrors.ErrPreconditionFailed
+	}
+
+	// Streamed to storage rather than read into memory, as files may be
+	// large
+	counted := &countingReader{r: upload}
+	key := digitalKey(itemID, time.Now())
+	if _, err := storage.Private().Put(ctx, key, counted, contentType); err != nil {
+		return models.Item{}, apperrors.Internal("failed to store file", err)
+	}
+
+	var replaced string
+	item, err = s.updateItem(ctx, actor, itemID, version, func(tx repository.Store, item models.Item) error {
+		if item.GiftCard {
+			return apperrors.Validation("gift cards cannot be digital items")
+		}
+		replaced = ""
+		old, err := tx.Downloads().File(ctx, itemID)
+		if err == nil {
+			replaced = old.Key
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return apperrors.Internal("failed to fetch file", err)
+		}
+		file := models.DigitalFile{
+			ItemID:      itemID,
+			Key:         key,
+			Name:        name,
+			ContentType: contentType,
+			Size:        counted.n,
+		}
+		if err := tx.Downloads().SetFile(ctx, &file, item.Version); err != nil {
+			return updateFailed("failed to save file", err)
+		}
+		return nil
+	})
+	if err != nil {
+		removeStoredFile(ctx, key)
+		return models.Item{}, err
+	}
+	if replaced != "" && replaced != key {
+		removeStoredFile(ctx, replaced)
+	}
+	return item, nil
+}
+
+// RemoveFile deletes the item's file on behalf of actor and returns the
+// item, no longer digital. Orders already placed can no longer download it.
+// version is the version the removal was based on, as for updateItem.
+func (s *ItemService) RemoveFile(ctx context.Context, actor models.User, itemID uint, version *int) (models.Item, error) {
+	var removed string
+	item, err := s.updateItem(ctx, actor, itemID, version, func(tx repository.Store, item models.Item) error {
+		file, err := tx.Downloads().File(ctx, itemID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return apperrors.ErrNotFound.WithMessage("item has no file")
+			}
+			return apperrors.Internal("failed to fetch file", err)
+		}
+		if err := tx.Downloads().RemoveFile(ctx, itemID, item.Version); err != nil {
+			return updateFailed("failed to remove file", err)
+		}
+		removed = file.Key
+		return nil
+	})
+	if err != nil {
+		return models.Item{}, err
+	}
+	if err := storage.Private().Delete(ctx, removed); err != nil {
+		return models.Item{}, apperrors.Internal("failed to delete file", err)
+	}
+	return item, nil
+}
+
+// removeStoredFile deletes a file of a digital item that is no longer in
+// use, logging failures as the item is already up to date
+func removeStoredFile(ctx context.Context, key string) {
+	if err := storage.Private().Delete(ctx, key); err != nil {
+		logging.FromContext(ctx).Warn("failed to delete item file", "key", key, "error", err)
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// digitalKey is where a file of a digital item uploaded at the given time
+// is stored
+func digitalKey(itemID uint, at time.Time) string {
+	return fmt.Sprintf("items/%d-%d", itemID, at.UnixNano())
+}
+
+// Links returns signed links to the order's downloads by download ID, each
+// working for the configured link TTL. Cancelled orders get none.
+func (s *DownloadService) Links(order models.Order) map[uint]DownloadLink {
+	if order.Status == models.OrderCancelled {
+		return nil
+	}
+	expires := time.Now().Add(s.cfg.LinkTTL).Truncate(time.Second)
+	links := make(map[uint]DownloadLink, len(order.Downloads))
+	for _, download := range order.Downloads {
+		links[download.ID] = DownloadLink{
+			URL: fmt.Sprintf("/api/v1/downloads/%d?expires=%d&signature=%s",
+				download.ID, expires.Unix(), s.sign(download, expires.Unix())),
+			ExpiresAt: expires,
+		}
+	}
+	return links
+}
+
+// Open checks a signed link to a download, counts the download and returns
+// the file with its contents, which the caller must close. The link is
+// the only credential, so the download is found in any store.
+func (s *DownloadService) Open(ctx context.Context, id uint, expires int64, signature string) (models.DigitalFile, io.ReadCloser, error) {
+	if time.Now().Unix() > expires {
+		return models.DigitalFile{}, nil, apperrors.ErrDownloadLinkInvalid
+	}
+	ctx = tenant.WithoutStore(ctx)
+
+	download, err := s.store.Downloads().Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.DigitalFile{}, nil, apperrors.ErrDownloadLinkInvalid
+		}
+		return models.DigitalFile{}, nil, apperrors.Internal("failed to fetch download", err)
+	}
+	given, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(given, s.mac(download, expires)) {
+		return models.DigitalFile{}, nil, apperrors.ErrDownloadLinkInvalid
+	}
+	order, err := s.store.Orders().Get(ctx, download.OrderID)
+	if err != nil {
+		return models.DigitalFile{}, nil, apperrors.Internal("failed to fetch order", err)
+	}
+	if order.Status == models.OrderCancelled {
+		return models.DigitalFile{}, nil, apperrors.ErrDownloadLinkInvalid.WithMessage("the order was cancelled")
+	}
+
+	file, err := s.store.Downloads().File(ctx, download.ItemID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.DigitalFile{}, nil, apperrors.ErrDownloadNotFound.WithMessage("the item's file was removed")
+		}
+		return models.DigitalFile{}, nil, apperrors.Internal("failed to fetch file", err)
+	}
+	// The file is opened before the download is counted, so a missing
+	// file does not use up a download
+	content, err := storage.Private().Open(ctx, file.Key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return models.DigitalFile{}, nil, apperrors.ErrDownloadNotFound.WithMessage("the item's file was removed")
+		}
+		return models.DigitalFile{}, nil, apperrors.Internal("failed to open file", err)
+	}
+	ok, err := s.store.Downloads().Use(ctx, download.ID, download.Issue)
+	if err != nil || !ok {
+		content.Close()
+		if err != nil {
+			return models.DigitalFile{}, nil, apperrors.Internal("failed to count download", err)
+		}
+		// Links of earlier issues fail their signature check, so the
+		// download was re-issued just now or has no downloads left
+		return models.DigitalFile{}, nil, apperrors.ErrDownloadLimitReached.
+			WithDetails(map[string]int{"max_downloads": download.MaxDownloads})
+	}
+	return file, content, nil
+}
+
+// Reissue resets the download counts of the order's downloads and
+// invalidates the links issued before, returning the order with its
+// downloads
+func (s *DownloadService) Reissue(ctx context.Context, orderID uint) (models.Order, error) {
+	order, err := s.store.Orders().Get(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Order{}, apperrors.ErrOrderNotFound
+		}
+		return models.Order{}, apperrors.Internal("failed to fetch order", err)
+	}
+	downloads, err := s.store.Downloads().ListByOrder(ctx, orderID)
+	if err != nil {
+		return models.Order{}, apperrors.Internal("failed to fetch downloads", err)
+	}
+	if len(downloads) == 0 {
+		return models.Order{}, apperrors.Validation("order has no digital items")
+	}
+	if order.Status == models.OrderCancelled {
+		return models.Order{}, apperrors.Validation("downloads of cancelled orders cannot be re-issued")
+	}
+
+	if err := s.store.Downloads().Reissue(ctx, orderID); err != nil {
+		return models.Order{}, apperrors.Internal("failed to re-issue downloads", err)
+	}
+	if order.Downloads, err = s.store.Downloads().ListByOrder(ctx, orderID); err != nil {
+		return models.Order{}, apperrors.Internal("failed to fetch downloads", err)
+	}
+	return order, nil
+}
+
+// sign returns the hex signature of a link to the download expiring at
+// the given Unix time
+func (s *DownloadService) sign(download models.Download, expires int64) string {
+	return hex.EncodeToString(s.mac(download, expires))
+}
+
+// mac authenticates the download, its issue and the link's expiry, so
+// links stop working once they expire or the download is re-issued
+func (s *DownloadService) mac(download models.Download, expires int64) []byte {
+	mac := hmac.New(sha256.New, []byte(s.secret))
+	fmt.Fprintf(mac, "%d:%d:%d", download.ID, download.Issue, expires)
+	return mac.Sum(nil)
+}
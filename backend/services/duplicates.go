@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/config"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/tenant"
+	"errors"
+	"time"
+)
+
+// DuplicateSettings are how a store treats likely duplicate orders: those
+// a user places with the same items as another of theirs within Window.
+// Policy and Minutes are the store's own, empty and 0 when it uses the
+// configured ones; EffectivePolicy and Window are those applied.
+type DuplicateSettings struct {
+	Policy          string
+	Minutes         int
+	EffectivePolicy string
+	Window          time.Duration
+}
+
+// duplicateSettings returns how the store of the context treats likely
+// duplicate orders. Stores not found in the database use the configured
+// settings.
+func duplicateSettings(ctx context.Context, tx repository.Store, cfg config.CheckoutConfig) (DuplicateSettings, error) {
+	store, err := tx.Stores().Get(ctx, tenant.StoreOrDefault(ctx))
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return DuplicateSettings{}, err
+	}
+	settings := DuplicateSettings{
+		Policy:          store.DuplicateOrderPolicy,
+		Minutes:         store.DuplicateOrderMinutes,
+		EffectivePolicy: cfg.DuplicatePolicy,
+		Window:          cfg.DuplicateWindow,
+	}
+	if settings.Policy != "" {
+		settings.EffectivePolicy = settings.Policy
+	}
+	if settings.Minutes > 0 {
+		settings.Window = time.Duration(settings.Minutes) * time.Minute
+	}
+	return settings, nil
+}
+
+// DuplicateSettings returns how the current store treats likely duplicate
+// orders
+func (s *OrderService) DuplicateSettings(ctx context.Context) (DuplicateSettings, error) {
+	settings, err := duplicateSettings(ctx, s.store, s.checkout)
+	if err != nil {
+		return DuplicateSettings{}, apperrors.Internal("failed to fetch duplicate order settings", err)
+	}
+	return settings, nil
+}
+
+// SetDuplicateSettings sets how the current store treats likely duplicate
+// orders; an empty policy and 0 minutes go back to the configured ones
+func (s *OrderService) SetDuplicateSettings(ctx context.Context, policy string, minutes int) (DuplicateSettings, error) {
+	err := s.store.Stores().UpdateDuplicateOrders(ctx, tenant.StoreOrDefault(ctx), policy, minutes)
+	if errors.Is(err, repository.ErrNotFound) {
+		return DuplicateSettings{}, apperrors.ErrStoreNotFound
+	}
+	if err != nil {
+		return DuplicateSettings{}, apperrors.Internal("failed to update duplicate order settings", err)
+	}
+	return s.DuplicateSettings(ctx)
+}
+
+// findDuplicate returns the user's latest order placed within the window
+// holding the same units of the same items as the cart, or nil
+func findDuplicate(ctx context.Context, tx repository.Store, userID uint, cart models.Cart, window time.Duration) (*models.Order, error) {
+	recent, err := tx.Orders().Recent(ctx, userID, time.Now().Add(-window))
+	if err != nil {
+		return nil, err
+	}
+	want := units(cart.CartItems)
+	for _, order := range recent {
+		if sameUnits(units(order.Cart.CartItems), want) {
+			return &order, nil
+		}
+	}
+	return nil, nil
+}
+
+// units returns the units of each item in the cart lines
+func units(lines []models.CartItem) map[uint]int {
+	counts := make(map[uint]int, len(lines))
+	for _, ci := range lines {
+		counts[ci.ItemID] += ci.Quantity
+	}
+	return counts
+}
+
+func sameUnits(a, b map[uint]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for itemID, n := range a {
+		if b[itemID] != n {
+			return false
+		}
+	}
+	return true
+}
+
+// MarkReviewed records that an admin reviewed an order flagged as a likely
+// duplicate, taking it off the orders to review
+func (s *OrderService) MarkReviewed(ctx context.Context, orderID uint) (models.Order, error) {
+	order, err := s.store.Orders().Get(ctx, orderID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Order{}, apperrors.ErrOrderNotFound
+	}
+	if err != nil {
+		return models.Order{}, apperrors.Internal("failed to fetch order", err)
+	}
+	if order.DuplicateOfID == nil {
+		return models.Order{}, apperrors.ErrOrderNotFlagged
+	}
+	if order.ReviewedAt != nil {
+		return order, nil
+	}
+
+	now := time.Now()
+	if err := s.store.Orders().MarkReviewed(ctx, orderID, now); err != nil {
+		return models.Order{}, apperrors.Internal("failed to review order", err)
+	}
+	order.ReviewedAt = &now
+	return order, nil
+}
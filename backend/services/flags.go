@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/flags"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"errors"
+	"regexp"
+)
+
+// flagNamePattern matches valid feature flag names, e.g. guest_checkout
+var flagNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_.-]{0,63}$`)
+
+type FlagService struct {
+	store repository.Store
+}
+
+// FlagChange holds the fields of a feature flag to set; nil fields keep
+// their value, or take the default of new flags: disabled, at 0 percent and
+// for no particular users
+type FlagChange struct {
+	Description *string
+	Enabled     *bool
+	Percentage  *int
+	UserIDs     *[]uint
+}
+
+// List returns the feature flags by name
+func (s *FlagService) List(ctx context.Context) ([]models.FeatureFlag, error) {
+	flags, err := s.store.Flags().List(ctx)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch feature flags", err)
+	}
+	return flags, nil
+}
+
+// Set changes the feature flag with the name, creating it if there is none,
+// and returns it along with whether it was created
+func (s *FlagService) Set(ctx context.Context, name string, change FlagChange) (models.FeatureFlag, bool, error) {
+	if !flagNamePattern.MatchString(name) {
+		return models.FeatureFlag{}, false, apperrors.Validation("flag names must be lowercase letters, digits, '_', '.' or '-', starting with a letter, and at most 64 characters")
+	}
+	if change.Percentage != nil && (*change.Percentage < 0 || *change.Percentage > 100) {
+		return models.FeatureFlag{}, false, apperrors.Validation("percentage must be between 0 and 100")
+	}
+
+	flag, err := s.store.Flags().Get(ctx, name)
+	created := errors.Is(err, repository.ErrNotFound)
+	if err != nil && !created {
+		return models.FeatureFlag{}, false, apperrors.Internal("failed to fetch feature flag", err)
+	}
+
+	flag.Name = name
+	if change.Description != nil {
+		flag.Description = *change.Description
+	}
+	if change.Enabled != nil {
+		flag.Enabled = *change.Enabled
+	}
+	if change.Percentage != nil {
+		flag.Percentage = *change.Percentage
+	}
+	if change.UserIDs != nil {
+		flag.UserIDs = *change.UserIDs
+	}
+
+	if created {
+		err = s.store.Flags().Create(ctx, &flag)
+	} else {
+		err = s.store.Flags().Update(ctx, &flag)
+	}
+	if err != nil {
+		return models.FeatureFlag{}, false, apperrors.Internal("failed to save feature flag", err)
+	}
+	flags.Invalidate(ctx)
+
+	logging.FromContext(ctx).Info("feature flag set", "flag", name, "enabled", flag.Enabled, "percentage", flag.Percentage)
+	return flag, created, nil
+}
+
+// Delete deletes the feature flag with the name, which turns it off
+func (s *FlagService) Delete(ctx context.Context, name string) error {
+	if err := s.store.Flags().Delete(ctx, name); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrFlagNotFound
+		}
+		return apperrors.Internal("failed to delete feature flag", err)
+	}
+	flags.Invalidate(ctx)
+
+	logging.FromContext(ctx).Info("feature flag deleted", "flag", name)
+	return nil
+}
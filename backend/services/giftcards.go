@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/tenant"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// giftCardAlphabet leaves out characters that are easily confused, such as
+// 0 and O or 1 and I
+const giftCardAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+type GiftCardService struct {
+	store repository.Store
+}
+
+// Issue creates a gift card worth amount, not bought by anyone
+func (s *GiftCardService) Issue(ctx context.Context, amount float64) (models.GiftCard, error) {
+	var card models.GiftCard
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		var err error
+		card, err = issueGiftCard(ctx, tx, amount, nil, nil)
+		return err
+	})
+	if err != nil {
+		return models.GiftCard{}, orInternal("failed to issue gift card", err)
+	}
+
+	logging.FromContext(ctx).Info("gift card issued", "gift_card_id", card.ID, "amount", amount)
+	return card, nil
+}
+
+// Get returns a gift card with its ledger
+func (s *GiftCardService) Get(ctx context.Context, id uint) (models.GiftCard, error) {
+	card, err := s.store.GiftCards().Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.GiftCard{}, apperrors.ErrGiftCardNotFound
+		}
+		return models.GiftCard{}, apperrors.Internal("failed to fetch gift card", err)
+	}
+	return card, nil
+}
+
+// List returns a page of gift cards and the next cursor
+func (s *GiftCardService) List(ctx context.Context, page pagination.Page) ([]models.GiftCard, string, error) {
+	cards, next, err := s.store.GiftCards().List(ctx, page)
+	if err != nil {
+		return nil, "", apperrors.Internal("failed to fetch gift cards", err)
+	}
+	return cards, next, nil
+}
+
+// ListByUser returns the gift cards the user bought or was sent, newest
+// first
+func (s *GiftCardService) ListByUser(ctx context.Context, userID uint) ([]models.GiftCard, error) {
+	cards, err := s.store.GiftCards().ListByUser(ctx, userID)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch gift cards", err)
+	}
+	return cards, nil
+}
+
+// Lookup returns the gift card with the code, for checking its balance
+func (s *GiftCardService) Lookup(ctx context.Context, code string) (models.GiftCard, error) {
+	card, err := s.store.GiftCards().FindByCode(ctx, normalizeGiftCardCode(code))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.GiftCard{}, apperrors.ErrGiftCardNotFound
+		}
+		return models.GiftCard{}, apperrors.Internal("failed to fetch gift card", err)
+	}
+	return card, nil
+}
+
+// Void cancels a gift card, recording its remaining balance as debited
+func (s *GiftCardService) Void(ctx context.Context, id uint) (models.GiftCard, error) {
+	var card models.GiftCard
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		var err error
+		card, err = tx.GiftCards().Get(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return apperrors.ErrGiftCardNotFound
+			}
+			return err
+		}
+		if card.VoidedAt != nil {
+			return apperrors.ErrGiftCardVoided
+		}
+
+		now := time.Now()
+		if err := tx.GiftCards().Void(ctx, id, now); err != nil {
+			return err
+		}
+		entry := models.GiftCardEntry{GiftCardID: id, Kind: models.GiftCardVoid, Amount: -card.Balance}
+		if err := tx.GiftCards().AddEntry(ctx, &entry); err != nil {
+			return err
+		}
+		card.Entries = append(card.Entries, entry)
+		card.Balance, card.VoidedAt = 0, &now
+		return nil
+	})
+	if err != nil {
+		return models.GiftCard{}, orInternal("failed to void gift card", err)
+	}
+
+	logging.FromContext(ctx).Info("gift card voided", "gift_card_id", id)
+	return card, nil
+}
+
+// issueGiftCard creates a gift card with a new code and credits it with
+// amount. orderID and userID are the order that bought it and its buyer.
+func issueGiftCard(ctx context.Context, tx repository.Store, amount float64, orderID, userID *uint) (models.GiftCard, error) {
+	code, err := newGiftCardCode(ctx, tx)
+	if err != nil {
+		return models.GiftCard{}, err
+	}
+
+	card := models.GiftCard{
+		Code:           code,
+		InitialBalance: amount,
+		Balance:        amount,
+		OrderID:        orderID,
+		UserID:         userID,
+	}
+	if err := tx.GiftCards().Create(ctx, &card); err != nil {
+		return models.GiftCard{}, err
+	}
+	entry := models.GiftCardEntry{GiftCardID: card.ID, Kind: models.GiftCardIssue, Amount: amount, OrderID: orderID}
+	if err := tx.GiftCards().AddEntry(ctx, &entry); err != nil {
+		return models.GiftCard{}, err
+	}
+	card.Entries = []models.GiftCardEntry{entry}
+	return card, nil
+}
+
+// redeemGiftCard pays up to total of an order with the gift card with the
+// code, returning the amount taken from it. The caller records the ledger
+// entry once the order exists.
+func redeemGiftCard(ctx context.Context, tx repository.Store, code string, total float64) (models.GiftCard, float64, error) {
+	card, err := tx.GiftCards().FindByCode(ctx, normalizeGiftCardCode(code))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.GiftCard{}, 0, apperrors.ErrGiftCardNotFound
+		}
+		return models.GiftCard{}, 0, apperrors.Internal("failed to fetch gift card", err)
+	}
+	if card.VoidedAt != nil {
+		return models.GiftCard{}, 0, apperrors.ErrGiftCardVoided
+	}
+	if card.Balance <= 0 {
+		return models.GiftCard{}, 0, apperrors.ErrGiftCardEmpty
+	}
+
+	amount := math.Round(math.Min(card.Balance, total)*100) / 100
+	ok, err := tx.GiftCards().Debit(ctx, card.ID, amount)
+	if err != nil {
+		return models.GiftCard{}, 0, apperrors.Internal("failed to redeem gift card", err)
+	}
+	// Spent by a concurrent checkout since it was read
+	if !ok {
+		return models.GiftCard{}, 0, apperrors.ErrGiftCardEmpty
+	}
+	return card, amount, nil
+}
+
+// newGiftCardCode returns an unused code such as ABCD-EFGH-JKLM-NPQR. Codes
+// are unique across stores.
+func newGiftCardCode(ctx context.Context, tx repository.Store) (string, error) {
+	for {
+		var b strings.Builder
+		for i := 0; i < 16; i++ {
+			if i > 0 && i%4 == 0 {
+				b.WriteByte('-')
+			}
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(giftCardAlphabet))))
+			if err != nil {
+				return "", err
+			}
+			b.WriteByte(giftCardAlphabet[n.Int64()])
+		}
+
+		_, err := tx.GiftCards().FindByCode(tenant.WithoutStore(ctx), b.String())
+		if errors.Is(err, repository.ErrNotFound) {
+			return b.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// normalizeGiftCardCode accepts codes typed in lower case or with
+// surrounding spaces
+func normalizeGiftCardCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"sync"
+)
+
+// PriceAdjuster takes amounts off what a cart costs, recording them with
+// Pricing.Adjust. Adjusters run in the order they were registered, after
+// the built-in ones, each seeing the pricing left by those before it. They
+// price carts outside of checkout too, such as to show them, so they must
+// not change anything.
+type PriceAdjuster interface {
+	Adjust(ctx context.Context, store repository.Store, cart models.Cart, pricing *Pricing) error
+}
+
+// PriceAdjusterFunc adapts a function to a PriceAdjuster
+type PriceAdjusterFunc func(ctx context.Context, store repository.Store, cart models.Cart, pricing *Pricing) error
+
+func (f PriceAdjusterFunc) Adjust(ctx context.Context, store repository.Store, cart models.Cart, pricing *Pricing) error {
+	return f(ctx, store, cart, pricing)
+}
+
+// CheckoutValidator checks the order the cart is about to become, priced
+// as given, returning the violation if the order breaks its rule. Every
+// validator runs, after the configured checkout rules, so that customers
+// learn of all the violations at once.
+type CheckoutValidator interface {
+	Validate(ctx context.Context, store repository.Store, cart models.Cart, pricing Pricing) (*apperrors.Violation, error)
+}
+
+// CheckoutValidatorFunc adapts a function to a CheckoutValidator
+type CheckoutValidatorFunc func(ctx context.Context, store repository.Store, cart models.Cart, pricing Pricing) (*apperrors.Violation, error)
+
+func (f CheckoutValidatorFunc) Validate(ctx context.Context, store repository.Store, cart models.Cart, pricing Pricing) (*apperrors.Violation, error) {
+	return f(ctx, store, cart, pricing)
+}
+
+// OrderHook runs on every order placed, in the checkout's transaction once
+// the order is recorded: failing fails the checkout, and what it writes
+// through tx is only kept with the order. Calls to other systems belong in
+// event subscribers instead, as the transaction may still roll back.
+type OrderHook interface {
+	OrderPlaced(ctx context.Context, tx repository.Store, order models.Order) error
+}
+
+// OrderHookFunc adapts a function to an OrderHook
+type OrderHookFunc func(ctx context.Context, tx repository.Store, order models.Order) error
+
+func (f OrderHookFunc) OrderPlaced(ctx context.Context, tx repository.Store, order models.Order) error {
+	return f(ctx, tx, order)
+}
+
+// namedHook is a registered hook and the name it was registered under
+type namedHook[T any] struct {
+	name string
+	hook T
+}
+
+var (
+	hooksMu sync.RWMutex
+	// priceAdjusters start with the built-in discounts, which can be
+	// replaced by registering an adjuster under their name
+	priceAdjusters = []namedHook[PriceAdjuster]{
+		{"promotions", PriceAdjusterFunc(adjustPromotions)},
+		{"bundles", PriceAdjusterFunc(adjustBundles)},
+	}
+	checkoutValidators []namedHook[CheckoutValidator]
+	orderHooks         []namedHook[OrderHook]
+)
+
+// RegisterPriceAdjuster adds an adjuster to the pricing of every cart,
+// replacing the one registered under the same name, if any. The built-in
+// adjusters are "promotions", which applies promotions and sales, and
+// "bundles".
+func RegisterPriceAdjuster(name string, adjuster PriceAdjuster) {
+	register(&priceAdjusters, name, adjuster)
+}
+
+// RegisterCheckoutValidator adds a validator to every checkout, replacing
+// the one registered under the same name, if any
+func RegisterCheckoutValidator(name string, validator CheckoutValidator) {
+	register(&checkoutValidators, name, validator)
+}
+
+// RegisterOrderHook adds a hook to every order placed, replacing the one
+// registered under the same name, if any
+func RegisterOrderHook(name string, hook OrderHook) {
+	register(&orderHooks, name, hook)
+}
+
+func register[T any](hooks *[]namedHook[T], name string, hook T) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	for i := range *hooks {
+		if (*hooks)[i].name == name {
+			(*hooks)[i].hook = hook
+			return
+		}
+	}
+	*hooks = append(*hooks, namedHook[T]{name, hook})
+}
+
+// registered returns a copy of the hooks, to be run without holding the
+// lock
+func registered[T any](hooks *[]namedHook[T]) []namedHook[T] {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	return append([]namedHook[T](nil), *hooks...)
+}
+
+// runOrderHooks runs the registered order hooks on the order just placed
+func runOrderHooks(ctx context.Context, tx repository.Store, order models.Order) error {
+	for _, h := range registered(&orderHooks) {
+		if err := h.hook.OrderPlaced(ctx, tx, order); err != nil {
+			return orInternal("order hook "+h.name+" failed", err)
+		}
+	}
+	return nil
+}
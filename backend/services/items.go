@@ -0,0 +1,502 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/search"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+)
+
+type ItemService struct {
+	store repository.Store
+}
+
+// SearchResult is a page of the items matching a search
+type SearchResult struct {
+	Items  []models.Item
+	Total  int64
+	Facets search.Facets
+}
+
+// Create adds an item to the catalog on behalf of actor. Items created by
+// a vendor account always belong to its vendor; admins may assign them to
+// any vendor.
+func (s *ItemService) Create(ctx context.Context, actor models.User, item *models.Item) error {
+	if actor.Role == models.RoleVendor {
+		// Vendors do not see the internal fields, so cannot set them
+		item.VendorID = actor.VendorID
+		item.CostPrice, item.InternalNotes = nil, ""
+	} else if item.VendorID != nil {
+		if _, err := s.store.Vendors().Get(ctx, *item.VendorID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return apperrors.ErrVendorNotFound
+			}
+			return apperrors.Internal("failed to fetch vendor", err)
+		}
+	}
+	// Gift cards are redeemable store-wide, so only the store sells them
+	if item.GiftCard && item.VendorID != nil {
+		return apperrors.Validation("gift cards are sold by the store, not by vendors")
+	}
+	if err := checkCompareAtPrice(item.Price, item.CompareAtPrice); err != nil {
+		return err
+	}
+	if err := checkQuantityLimits(item.MinQuantity, item.MaxQuantity); err != nil {
+		return err
+	}
+
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		return createItem(ctx, tx, actor, item)
+	})
+	if err != nil {
+		return err
+	}
+	index(ctx, *item)
+	return nil
+}
+
+// createItem adds a checked item to the catalog in tx, recording its
+// opening stock
+func createItem(ctx context.Context, tx repository.Store, actor models.User, item *models.Item) error {
+	if item.SKU != nil {
+		if _, err := tx.Items().GetBySKU(ctx, *item.SKU); err == nil {
+			return apperrors.ErrSKUTaken.WithDetails(map[string]interface{}{"sku": *item.SKU})
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return apperrors.Internal("failed to fetch item", err)
+		}
+	}
+
+	// The database creates items active in place of false, so inactive
+	// items are hidden once created
+	active := item.IsActive
+	if err := tx.Items().Create(ctx, item); err != nil {
+		return apperrors.Internal("failed to create item", err)
+	}
+	if !active {
+		if err := tx.Items().SetActive(ctx, item.ID, item.Version, false); err != nil {
+			return apperrors.Internal("failed to hide item", err)
+		}
+		item.IsActive = false
+		item.Version++
+	}
+	return recordMovements(ctx, tx, models.InventoryMovement{
+		ItemID:  item.ID,
+		Delta:   stockDelta(nil, item.Stock),
+		Reason:  models.MovementAdjustment,
+		ActorID: &actor.ID,
+	})
+}
+
+// Get returns an item with the sale it is on, or ErrItemNotFound
+func (s *ItemService) Get(ctx context.Context, id uint) (models.Item, error) {
+	item, err := s.store.Items().Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Item{}, apperrors.ErrItemNotFound
+		}
+		return models.Item{}, apperrors.Internal("failed to fetch item", err)
+	}
+	items := []models.Item{item}
+	if err := markSales(ctx, s.store, items); err != nil {
+		return models.Item{}, apperrors.Internal("failed to fetch sales", err)
+	}
+	return items[0], nil
+}
+
+// List returns the items matching the filter on the page, with the sales
+// they are on, and the next cursor
+func (s *ItemService) List(ctx context.Context, filter repository.ItemFilter, page pagination.Page) ([]models.Item, string, error) {
+	items, next, err := s.store.Items().List(ctx, filter, page)
+	if err != nil {
+		return nil, "", apperrors.Internal("failed to fetch items", err)
+	}
+	if err := markSales(ctx, s.store, items); err != nil {
+		return nil, "", apperrors.Internal("failed to fetch sales", err)
+	}
+	return items, next, nil
+}
+
+// Locate returns the position of the page among the items matching the
+// filter
+func (s *ItemService) Locate(ctx context.Context, filter repository.ItemFilter, page pagination.Page) (pagination.Position, error) {
+	pos, err := s.store.Items().Locate(ctx, filter, page)
+	if err != nil {
+		return pagination.Position{}, apperrors.Internal("failed to count items", err)
+	}
+	return pos, nil
+}
+
+// Search returns the items on the page of those matching the query, with
+// the search engine when one is configured and SQL LIKE matching otherwise
+// or when the engine fails
+func (s *ItemService) Search(ctx context.Context, q search.Query) (SearchResult, error) {
+	result, err := s.find(ctx, q)
+	if err != nil {
+		return SearchResult{}, apperrors.Internal("failed to search items", err)
+	}
+
+	items, err := s.store.Items().GetMany(ctx, result.IDs)
+	if err != nil {
+		return SearchResult{}, apperrors.Internal("failed to fetch items", err)
+	}
+	if err := markSales(ctx, s.store, items); err != nil {
+		return SearchResult{}, apperrors.Internal("failed to fetch sales", err)
+	}
+	return SearchResult{Items: items, Total: result.Total, Facets: result.Facets}, nil
+}
+
+func (s *ItemService) find(ctx context.Context, q search.Query) (search.Result, error) {
+	if engine := search.Get(); engine != nil {
+		result, err := engine.Search(ctx, q)
+		if err == nil {
+			return result, nil
+		}
+		logging.FromContext(ctx).Warn("search engine failed, falling back to SQL", "error", err)
+	}
+	return s.store.Items().Search(ctx, q)
+}
+
+// updateItem runs update on the item in a transaction on behalf of actor
+// and returns the updated item. update is given the item as read and must
+// change it only while it is still at that version. With a version, the
+// one the caller read the item at, the update is rejected with
+// ErrPreconditionFailed unless the item is still at it; without one, it is
+// retried over concurrent changes.
+func (s *ItemService) updateItem(ctx context.Context, actor models.User, id uint, version *int, update func(tx repository.Store, item models.Item) error) (models.Item, error) {
+	err := retryOnConflict(func() error {
+		return s.store.Transaction(ctx, func(tx repository.Store) error {
+			item, err := tx.Items().Get(ctx, id)
+			if err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return apperrors.ErrItemNotFound
+				}
+				return apperrors.Internal("failed to fetch item", err)
+			}
+			if !CanManageItem(actor, item) {
+				return apperrors.ErrForbidden.WithMessage("item belongs to another vendor")
+			}
+			if version != nil && *version != item.Version {
+				return apperrors.ErrPreconditionFailed
+			}
+			err = update(tx, item)
+			if errors.Is(err, repository.ErrConflict) && version != nil {
+				return apperrors.ErrPreconditionFailed
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return models.Item{}, err
+	}
+	return s.Get(ctx, id)
+}
+
+// updateFailed reports a failed item update, leaving concurrent changes to
+// updateItem
+func updateFailed(message string, err error) error {
+	if errors.Is(err, repository.ErrConflict) {
+		return err
+	}
+	return apperrors.Internal(message, err)
+}
+
+// UpdateInventory sets an item's stock (nil to stop tracking it) and
+// low-stock threshold on behalf of actor, returning the updated item. The
+// stock of items held in warehouses can only be changed per warehouse.
+// version is the version the update was based on, as for updateItem.
+func (s *ItemService) UpdateInventory(ctx context.Context, actor models.User, id uint, version *int, stock *int, threshold int) (models.Item, error) {
+	item, err := s.updateItem(ctx, actor, id, version, func(tx repository.Store, item models.Item) error {
+		held, err := tx.Warehouses().StockOf(ctx, []uint{id})
+		if err != nil {
+			return apperrors.Internal("failed to fetch warehouse stock", err)
+		}
+		if len(held) > 0 && (stock == nil || item.Stock == nil || *stock != *item.Stock) {
+			return apperrors.Validation("the stock of items held in warehouses is set per warehouse")
+		}
+		if err := tx.Items().UpdateInventory(ctx, id, item.Version, stock, threshold); err != nil {
+			return updateFailed("failed to update inventory", err)
+		}
+		return recordMovements(ctx, tx, models.InventoryMovement{
+			ItemID:  id,
+			Delta:   stockDelta(item.Stock, stock),
+			Reason:  models.MovementAdjustment,
+			ActorID: &actor.ID,
+		})
+	})
+	if err != nil {
+		return models.Item{}, err
+	}
+	index(ctx, item)
+	return item, nil
+}
+
+// SetActive lists or hides an item on behalf of actor, returning the
+// updated item. Hidden items stay in carts but cannot be checked out.
+// version is the version the update was based on, as for updateItem.
+func (s *ItemService) SetActive(ctx context.Context, actor models.User, id uint, version *int, active bool) (models.Item, error) {
+	item, err := s.updateItem(ctx, actor, id, version, func(tx repository.Store, item models.Item) error {
+		if err := tx.Items().SetActive(ctx, id, item.Version, active); err != nil {
+			return updateFailed("failed to update item", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Item{}, err
+	}
+	index(ctx, item)
+	return item, nil
+}
+
+// SetPrice sets an item's price and compare-at price, nil to remove it,
+// on behalf of actor, returning the updated item. version is the version
+// the update was based on, as for updateItem.
+func (s *ItemService) SetPrice(ctx context.Context, actor models.User, id uint, version *int, price float64, compareAt *float64) (models.Item, error) {
+	if err := checkCompareAtPrice(price, compareAt); err != nil {
+		return models.Item{}, err
+	}
+	item, err := s.updateItem(ctx, actor, id, version, func(tx repository.Store, item models.Item) error {
+		if err := tx.Items().SetPrice(ctx, id, item.Version, price, compareAt); err != nil {
+			return updateFailed("failed to set price", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Item{}, err
+	}
+	index(ctx, item)
+	return item, nil
+}
+
+// checkCompareAtPrice checks that a compare-at price, if any, is greater
+// than the price it is shown next to
+func checkCompareAtPrice(price float64, compareAt *float64) error {
+	if compareAt != nil && *compareAt <= price {
+		return apperrors.Validation("compare_at_price must be greater than the price")
+	}
+	return nil
+}
+
+// SetQuantityLimits sets the fewest units of an item an order may hold and
+// the most each customer may buy, 0 for no limit, on behalf of actor,
+// returning the updated item. Carts already over a new limit fail at
+// checkout. version is the version the update was based on, as for
+// updateItem.
+func (s *ItemService) SetQuantityLimits(ctx context.Context, actor models.User, id uint, version *int, minQuantity, maxQuantity int) (models.Item, error) {
+	if err := checkQuantityLimits(minQuantity, maxQuantity); err != nil {
+		return models.Item{}, err
+	}
+	return s.updateItem(ctx, actor, id, version, func(tx repository.Store, item models.Item) error {
+		if err := tx.Items().SetQuantityLimits(ctx, id, item.Version, minQuantity, maxQuantity); err != nil {
+			return updateFailed("failed to set quantity limits", err)
+		}
+		return nil
+	})
+}
+
+// SetInternal sets an item's cost price, nil to remove it, and internal
+// notes, which only admins see, on behalf of actor. version is the version
+// the update was based on, as for updateItem.
+func (s *ItemService) SetInternal(ctx context.Context, actor models.User, id uint, version *int, costPrice *float64, notes string) (models.Item, error) {
+	return s.updateItem(ctx, actor, id, version, func(tx repository.Store, item models.Item) error {
+		if err := tx.Items().SetInternal(ctx, id, item.Version, costPrice, notes); err != nil {
+			return updateFailed("failed to set internal fields", err)
+		}
+		return nil
+	})
+}
+
+// checkQuantityLimits checks that an item's minimum, if any, does not
+// exceed its maximum
+func checkQuantityLimits(minQuantity, maxQuantity int) error {
+	if maxQuantity > 0 && minQuantity > maxQuantity {
+		return apperrors.Validation("min_quantity must not exceed max_quantity")
+	}
+	return nil
+}
+
+// ItemChange is one row of a bulk item update: a new Price, or the price
+// adjusted by Percent (-20 takes 20% off), and a new Stock, each optional
+type ItemChange struct {
+	ItemID  uint
+	Price   *float64
+	Percent *float64
+	Stock   *int
+}
+
+// ItemChangeResult is the outcome of one row of a bulk item update: the
+// item's price and stock after it, or the error it failed with
+type ItemChangeResult struct {
+	ItemID uint
+	Price  float64
+	Stock  *int
+	Err    *apperrors.Error
+}
+
+// BulkUpdate applies the changes on behalf of actor in one transaction,
+// returning a result per row. If any row fails nothing is applied: the
+// results are returned with a validation error. The stock of items held in
+// warehouses can only be changed per warehouse.
+func (s *ItemService) BulkUpdate(ctx context.Context, actor models.User, changes []ItemChange) ([]ItemChangeResult, error) {
+	var results []ItemChangeResult
+	failed := 0
+	err := retryOnConflict(func() error {
+		return s.store.Transaction(ctx, func(tx repository.Store) error {
+			results, failed = make([]ItemChangeResult, len(changes)), 0
+			for i, change := range changes {
+				result, err := changeItem(ctx, tx, actor, change)
+				if err != nil {
+					if errors.Is(err, repository.ErrConflict) {
+						return err
+					}
+					var appErr *apperrors.Error
+					if !errors.As(err, &appErr) || appErr.Status >= http.StatusInternalServerError {
+						return err
+					}
+					result.Err = appErr
+					failed++
+				}
+				results[i] = result
+			}
+			if failed > 0 {
+				return errBulkFailed
+			}
+			return nil
+		})
+	})
+	if errors.Is(err, errBulkFailed) {
+		return results, apperrors.Validation(fmt.Sprintf("%d of %d changes failed, so none were applied", failed, len(changes)))
+	}
+	if err != nil {
+		return nil, orInternal("failed to update items", err)
+	}
+
+	for _, result := range results {
+		if item, err := s.store.Items().Get(ctx, result.ItemID); err == nil {
+			index(ctx, item)
+		}
+	}
+	return results, nil
+}
+
+// errBulkFailed rolls back a bulk update with failed rows
+var errBulkFailed = errors.New("bulk update failed")
+
+// changeItem applies one row of a bulk update. Rows that cannot be applied
+// fail with an application error; repository.ErrConflict means the item
+// changed concurrently.
+func changeItem(ctx context.Context, tx repository.Store, actor models.User, change ItemChange) (ItemChangeResult, error) {
+	result := ItemChangeResult{ItemID: change.ItemID}
+	item, err := tx.Items().Get(ctx, change.ItemID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return result, apperrors.ErrItemNotFound
+		}
+		return result, apperrors.Internal("failed to fetch item", err)
+	}
+	result.Price, result.Stock = item.Price, item.Stock
+
+	if change.Price != nil && change.Percent != nil {
+		return result, apperrors.Validation("give either a price or a percent, not both")
+	}
+	if change.Price == nil && change.Percent == nil && change.Stock == nil {
+		return result, apperrors.Validation("give a price, a percent or a stock")
+	}
+
+	price := item.Price
+	switch {
+	case change.Price != nil:
+		price = *change.Price
+	case change.Percent != nil:
+		price = math.Round(item.Price*(100+*change.Percent)) / 100
+	}
+	if price <= 0 {
+		return result, apperrors.Validation("price must be greater than 0")
+	}
+	if price != item.Price {
+		if err := checkCompareAtPrice(price, item.CompareAtPrice); err != nil {
+			return result, err
+		}
+		if err := tx.Items().SetPrice(ctx, item.ID, item.Version, price, item.CompareAtPrice); err != nil {
+			if errors.Is(err, repository.ErrConflict) {
+				return result, err
+			}
+			return result, apperrors.Internal("failed to set price", err)
+		}
+		item.Version++
+		result.Price = price
+	}
+
+	if change.Stock != nil && (item.Stock == nil || *change.Stock != *item.Stock) {
+		held, err := tx.Warehouses().StockOf(ctx, []uint{item.ID})
+		if err != nil {
+			return result, apperrors.Internal("failed to fetch warehouse stock", err)
+		}
+		if len(held) > 0 {
+			return result, apperrors.Validation("the stock of items held in warehouses is set per warehouse")
+		}
+		if err := tx.Items().UpdateInventory(ctx, item.ID, item.Version, change.Stock, item.LowStockThreshold); err != nil {
+			if errors.Is(err, repository.ErrConflict) {
+				return result, err
+			}
+			return result, apperrors.Internal("failed to update inventory", err)
+		}
+		err = recordMovements(ctx, tx, models.InventoryMovement{
+			ItemID:  item.ID,
+			Delta:   stockDelta(item.Stock, change.Stock),
+			Reason:  models.MovementAdjustment,
+			ActorID: &actor.ID,
+		})
+		if err != nil {
+			return result, err
+		}
+		result.Stock = change.Stock
+	}
+	return result, nil
+}
+
+// Each calls fn for every item matching the filter on the page and returns
+// the next cursor
+func (s *ItemService) Each(ctx context.Context, filter repository.ItemFilter, page pagination.Page, fn func(*models.Item) error) (string, error) {
+	return s.store.Items().Each(ctx, filter, page, fn)
+}
+
+// LowStock returns the tracked items at or below their low-stock threshold
+func (s *ItemService) LowStock(ctx context.Context) ([]models.Item, error) {
+	items, err := s.store.Items().LowStock(ctx)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch low-stock items", err)
+	}
+	return items, nil
+}
+
+// index updates the item in the search engine, if one is configured.
+// Failures are logged rather than failing the change; `admin reindex`
+// rebuilds the index.
+func index(ctx context.Context, item models.Item) {
+	engine := search.Get()
+	if engine == nil {
+		return
+	}
+	if err := engine.Index(ctx, item); err != nil {
+		logging.FromContext(ctx).Error("failed to index item", "item_id", item.ID, "error", err)
+	}
+}
+
+// CanManageItem reports whether user may change item: admins may change
+// any item, vendor accounts only their own vendor's items
+func CanManageItem(user models.User, item models.Item) bool {
+	switch user.Role {
+	case models.RoleAdmin:
+		return true
+	case models.RoleVendor:
+		return user.VendorID != nil && item.VendorID != nil && *user.VendorID == *item.VendorID
+	}
+	return false
+}
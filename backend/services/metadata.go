@@ -0,0 +1,36 @@
+package services
+
+import (
+	"ecommerce-backend/apperrors"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Limits of the metadata given with an order
+const (
+	maxMetadataKeys        = 20
+	maxMetadataValueLength = 500
+)
+
+// metadataKeyPattern is what metadata keys look like, such as gift_message
+// or po_number
+var metadataKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,39}$`)
+
+// CheckMetadata returns a validation error unless the metadata has at most
+// 20 keys of lowercase letters, digits and underscores, up to 40 long,
+// with values up to 500 characters
+func CheckMetadata(metadata map[string]string) error {
+	if len(metadata) > maxMetadataKeys {
+		return apperrors.Validation(fmt.Sprintf("metadata may have at most %d keys", maxMetadataKeys))
+	}
+	for key, value := range metadata {
+		if !metadataKeyPattern.MatchString(key) {
+			return apperrors.Validation(fmt.Sprintf("metadata key %q must be up to 40 lowercase letters, digits and underscores, starting with a letter", key))
+		}
+		if utf8.RuneCountInString(value) > maxMetadataValueLength {
+			return apperrors.Validation(fmt.Sprintf("metadata value of %q must be at most %d characters", key, maxMetadataValueLength))
+		}
+	}
+	return nil
+}
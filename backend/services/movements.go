@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/repository"
+	"slices"
+)
+
+// movementReasons are the reasons inventory movements are recorded for
+var movementReasons = []string{
+	models.MovementOpening,
+	models.MovementCheckout,
+	models.MovementBackorder,
+	models.MovementAdjustment,
+	models.MovementTransfer,
+}
+
+// Movements returns an item's inventory movements on the page, of the
+// reason given unless it is empty, and the next cursor
+func (s *ItemService) Movements(ctx context.Context, itemID uint, reason string, page pagination.Page) ([]models.InventoryMovement, string, error) {
+	if reason != "" && !slices.Contains(movementReasons, reason) {
+		return nil, "", apperrors.Validation("unknown movement reason " + reason)
+	}
+	if _, err := s.Get(ctx, itemID); err != nil {
+		return nil, "", err
+	}
+	movements, next, err := s.store.Items().Movements(ctx, itemID, reason, page)
+	if err != nil {
+		return nil, "", apperrors.Internal("failed to fetch inventory movements", err)
+	}
+	return movements, next, nil
+}
+
+// recordMovements records changes to the stock of items, leaving out those
+// that change nothing
+func recordMovements(ctx context.Context, tx repository.Store, movements ...models.InventoryMovement) error {
+	changed := movements[:0:0]
+	for _, movement := range movements {
+		if movement.Delta != 0 {
+			changed = append(changed, movement)
+		}
+	}
+	if err := tx.Items().AddMovements(ctx, changed); err != nil {
+		return apperrors.Internal("failed to record inventory movements", err)
+	}
+	return nil
+}
+
+// stockDelta is the change from one stock to another, untracked stock
+// counting as none
+func stockDelta(from, to *int) int {
+	delta := 0
+	if to != nil {
+		delta += *to
+	}
+	if from != nil {
+		delta -= *from
+	}
+	return delta
+}
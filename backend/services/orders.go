@@ -0,0 +1,688 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/config"
+	"ecommerce-backend/currency"
+	"ecommerce-backend/events"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/tenant"
+	"errors"
+	"expvar"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// orderNumberSpace is how many order numbers there are each year
+const orderNumberSpace = 100000000
+
+// Checkout funnel metrics, exposed via /debug/vars: checkouts started,
+// including those that fail, and orders placed
+var checkoutFunnel = expvar.NewMap("checkout_funnel")
+
+type OrderService struct {
+	store repository.Store
+	// downloads holds the download limit of digital items bought
+	downloads config.DownloadConfig
+	// rules are the configured checkout rules orders must meet
+	rules []CheckoutValidator
+	// delivery decides which delivery slots can be booked
+	delivery deliveryCalendar
+	// checkout holds the configured policy for likely duplicate orders,
+	// which stores may override
+	checkout config.CheckoutConfig
+}
+
+// CheckoutOptions are the customer's choices at checkout
+type CheckoutOptions struct {
+	// ShippingMethodID is 0 if the store has no shipping methods
+	ShippingMethodID uint
+	// GiftCardCode, if set, pays as much of the order as its balance covers
+	GiftCardCode string
+	// AddressID is the user's saved address to ship to; 0 for none
+	AddressID uint
+	// DeliverySlotID and DeliveryDate (YYYY-MM-DD) book a delivery slot;
+	// 0 if the store has no slots
+	DeliverySlotID uint
+	DeliveryDate   string
+	// Metadata are custom fields kept on the order, checked by
+	// CheckMetadata
+	Metadata map[string]string
+	// ConfirmDuplicate places the order even if the store blocks it as a
+	// likely duplicate of one the user just placed
+	ConfirmDuplicate bool
+	// Currency is the currency the order is settled in; "" for the base
+	// currency
+	Currency string
+}
+
+// Checkout turns the user's open cart into a completed order, returned
+// with the cart and its items and the gift cards it bought. A cart changed
+// by another request mid-checkout is read again, so the order holds
+// exactly what the cart does when it closes. The shipping address is
+// checked with the address provider first and refused if undeliverable.
+// An order with the same items as one the user placed within the store's
+// duplicate window is refused with ErrDuplicateOrder or flagged for review,
+// as the store's policy says.
+func (s *OrderService) Checkout(ctx context.Context, userID uint, opts CheckoutOptions) (models.Order, error) {
+	// Checkouts that fail still count as started in the conversion funnel
+	checkoutFunnel.Add("started", 1)
+	if err := s.store.Carts().MarkCheckoutStarted(ctx, userID, time.Now()); err != nil {
+		logging.FromContext(ctx).Warn("failed to record checkout start", "user_id", userID, "error", err)
+	}
+
+	if err := CheckMetadata(opts.Metadata); err != nil {
+		return models.Order{}, err
+	}
+
+	// The rate is fixed before the cart is locked, as it may be fetched
+	// from the exchange-rate provider
+	settlement, rate, err := ExchangeRate(ctx, opts.Currency)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	var shipTo models.PostalAddress
+	if opts.AddressID != 0 {
+		if shipTo, err = shippingAddress(ctx, s.store, userID, opts.AddressID); err != nil {
+			return models.Order{}, err
+		}
+	}
+
+	var order models.Order
+	err = retryOnConflict(func() error {
+		return s.store.Transaction(ctx, func(tx repository.Store) error {
+			// Concurrent checkouts of the cart wait here for this one
+			cart, err := tx.Carts().LockOpenCart(ctx, userID)
+			if err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return apperrors.ErrCartNotFound
+				}
+				return apperrors.Internal("failed to process order", err)
+			}
+
+			if len(cart.CartItems) == 0 {
+				return apperrors.ErrCartEmpty
+			}
+			for _, ci := range cart.CartItems {
+				if !ci.Item.IsActive {
+					return apperrors.ErrItemInactive.
+						WithMessage(ci.Item.Name + " is no longer for sale").
+						WithDetails(map[string]uint{"item_id": ci.ItemID})
+				}
+			}
+
+			// Limits may have been set, or other orders placed, since the
+			// items were added
+			if err := checkQuantities(ctx, tx, userID, cart, nil); err != nil {
+				return err
+			}
+
+			pricing, err := priceCart(ctx, tx, cart)
+			if err != nil {
+				return orInternal("failed to price cart", err)
+			}
+			if err := checkCheckoutRules(ctx, tx, s.rules, cart, pricing); err != nil {
+				return err
+			}
+			duplicate, err := s.checkDuplicate(ctx, tx, userID, cart, opts.ConfirmDuplicate)
+			if err != nil {
+				return err
+			}
+
+			method, shippingCost, err := shippingFor(ctx, tx, opts.ShippingMethodID, cart)
+			if err != nil {
+				return err
+			}
+			slot, err := bookDelivery(ctx, tx, s.delivery, opts.DeliverySlotID, opts.DeliveryDate, cart)
+			if err != nil {
+				return err
+			}
+
+			// Stock is reserved in item order, so checkouts sharing items
+			// lock their rows in the same order and cannot deadlock
+			lines := append([]models.CartItem(nil), cart.CartItems...)
+			sort.Slice(lines, func(i, j int) bool { return lines[i].ItemID < lines[j].ItemID })
+			var backorders []models.OrderBackorder
+			var movements []models.InventoryMovement
+			// inStock is the cart less its backordered units, which are
+			// allocated to warehouses once they are fulfilled
+			inStock := cart
+			inStock.CartItems = nil
+			for _, ci := range lines {
+				backordered, err := reserveStock(ctx, tx, ci)
+				if err != nil {
+					return err
+				}
+				if ci.Item.Stock != nil {
+					movements = append(movements, models.InventoryMovement{
+						ItemID:  ci.ItemID,
+						Delta:   backordered - ci.Quantity,
+						Reason:  models.MovementCheckout,
+						ActorID: &userID,
+					})
+				}
+				if backordered > 0 {
+					backorders = append(backorders, models.OrderBackorder{
+						ItemID:     ci.ItemID,
+						Name:       ci.Item.Name,
+						Kind:       ci.Item.Backorder,
+						Quantity:   backordered,
+						ExpectedAt: ci.Item.ExpectedAt,
+					})
+				}
+				if ci.Quantity -= backordered; ci.Quantity > 0 {
+					inStock.CartItems = append(inStock.CartItems, ci)
+				}
+			}
+			allocations, err := allocate(ctx, tx, inStock)
+			if err != nil {
+				return err
+			}
+
+			number, err := newOrderNumber(ctx, tx, time.Now())
+			if err != nil {
+				return apperrors.Internal("failed to number order", err)
+			}
+			order = models.Order{
+				Number:       number,
+				UserID:       userID,
+				CartID:       cart.ID,
+				Total:        math.Round((pricing.Total+shippingCost)*100) / 100,
+				Status:       models.OrderCompleted,
+				ShippingCost: shippingCost,
+				Discount:     pricing.Discount,
+				Allocations:  allocations,
+				Backorders:   backorders,
+				Metadata:     opts.Metadata,
+				// Kept on the order as the address may change later
+				ShippingAddress: shipTo,
+			}
+			if duplicate != nil {
+				order.DuplicateOfID = &duplicate.ID
+			}
+			if method != nil {
+				order.ShippingMethodID = &method.ID
+			}
+			if slot != nil {
+				order.DeliverySlotID = &slot.ID
+				order.DeliveryDate = opts.DeliveryDate
+				order.DeliveryWindow = slot.Window()
+			}
+			for _, applied := range pricing.Promotions {
+				order.Promotions = append(order.Promotions, models.OrderPromotion{
+					PromotionID: applied.Promotion.ID,
+					Name:        applied.Promotion.Name,
+					Amount:      applied.Amount,
+				})
+			}
+			order.Sales = pricing.purchases
+			for _, applied := range pricing.Bundles {
+				order.Bundles = append(order.Bundles, models.OrderBundle{
+					BundleID: applied.Bundle.ID,
+					Name:     applied.Bundle.Name,
+					Quantity: applied.Quantity,
+					Amount:   applied.Amount,
+				})
+			}
+			// Digital items are delivered by download instead of shipped
+			for _, ci := range cart.CartItems {
+				if ci.Item.Digital {
+					order.Downloads = append(order.Downloads, models.Download{
+						UserID:       userID,
+						ItemID:       ci.ItemID,
+						Name:         ci.Item.Name,
+						MaxDownloads: s.downloads.Limit,
+					})
+				}
+			}
+
+			var giftCard models.GiftCard
+			if opts.GiftCardCode != "" {
+				giftCard, order.GiftCardAmount, err = redeemGiftCard(ctx, tx, opts.GiftCardCode, order.Total)
+				if err != nil {
+					return err
+				}
+				order.Total = math.Round((order.Total-order.GiftCardAmount)*100) / 100
+			}
+			order.Currency, order.ExchangeRate = settlement, rate
+			order.SettlementTotal = currency.Convert(order.Total, rate)
+
+			if err := tx.Orders().Create(ctx, &order); err != nil {
+				logging.FromContext(ctx).Error("failed to create order", "user_id", userID, "error", err)
+				return apperrors.Internal("failed to create order", err)
+			}
+			for i := range movements {
+				movements[i].OrderID = &order.ID
+			}
+			if err := recordMovements(ctx, tx, movements...); err != nil {
+				return err
+			}
+
+			if order.GiftCardAmount > 0 {
+				entry := models.GiftCardEntry{
+					GiftCardID: giftCard.ID,
+					Kind:       models.GiftCardRedeem,
+					Amount:     -order.GiftCardAmount,
+					OrderID:    &order.ID,
+				}
+				if err := tx.GiftCards().AddEntry(ctx, &entry); err != nil {
+					return apperrors.Internal("failed to redeem gift card", err)
+				}
+			}
+
+			// Each unit of a gift card item is a card worth its price
+			for _, ci := range cart.CartItems {
+				if !ci.Item.GiftCard {
+					continue
+				}
+				for i := 0; i < ci.Quantity; i++ {
+					card, err := issueGiftCard(ctx, tx, ci.Item.Price, &order.ID, &userID)
+					if err != nil {
+						return apperrors.Internal("failed to issue gift card", err)
+					}
+					order.GiftCards = append(order.GiftCards, card)
+				}
+			}
+
+			for _, sub := range splitByVendor(cart, pricing) {
+				sub.OrderID = order.ID
+				sub.Status = order.Status
+				if err := tx.Orders().CreateSubOrder(ctx, &sub); err != nil {
+					return apperrors.Internal("failed to create vendor order", err)
+				}
+				order.SubOrders = append(order.SubOrders, sub)
+			}
+
+			if err := tx.Carts().MarkCheckedOut(ctx, &cart, time.Now()); err != nil {
+				return apperrors.Internal("failed to update cart status", err)
+			}
+			order.Cart = cart
+
+			err = publish(ctx, tx, events.OrderCreated, order.StoreID, userID, events.OrderStatus{
+				OrderID:     order.ID,
+				OrderNumber: order.Number,
+				UserID:      userID,
+				Status:      order.Status,
+				Total:       order.Total,
+			})
+			if err != nil {
+				return apperrors.Internal("failed to record order event", err)
+			}
+			return runOrderHooks(ctx, tx, order)
+		})
+	})
+	if err != nil {
+		var appErr *apperrors.Error
+		if !errors.As(err, &appErr) {
+			logging.FromContext(ctx).Error("failed to commit order", "user_id", userID, "error", err)
+		}
+		return models.Order{}, orInternal("failed to process order", err)
+	}
+
+	checkoutFunnel.Add("completed", 1)
+	logging.FromContext(ctx).Info("order created", "order_id", order.ID, "number", order.Number, "user_id", userID, "total", order.Total)
+	if order.DuplicateOfID != nil {
+		logging.FromContext(ctx).Warn("order flagged as a likely duplicate", "order_id", order.ID, "duplicate_of", *order.DuplicateOfID)
+	}
+	return order, nil
+}
+
+// checkDuplicate looks for an order the cart likely duplicates, as the
+// store's policy says. It fails with ErrDuplicateOrder if the store blocks
+// duplicates the user has not confirmed, and returns the order to flag the
+// new one against if the store flags them.
+func (s *OrderService) checkDuplicate(ctx context.Context, tx repository.Store, userID uint, cart models.Cart, confirmed bool) (*models.Order, error) {
+	settings, err := duplicateSettings(ctx, tx, s.checkout)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch duplicate order settings", err)
+	}
+	switch settings.EffectivePolicy {
+	case config.DuplicateOrdersBlock:
+		if confirmed {
+			return nil, nil
+		}
+	case config.DuplicateOrdersFlag:
+	default:
+		return nil, nil
+	}
+
+	duplicate, err := findDuplicate(ctx, tx, userID, cart, settings.Window)
+	if err != nil {
+		return nil, apperrors.Internal("failed to check for duplicate orders", err)
+	}
+	if duplicate == nil || settings.EffectivePolicy == config.DuplicateOrdersFlag {
+		return duplicate, nil
+	}
+	return nil, apperrors.ErrDuplicateOrder.WithDetails(map[string]interface{}{
+		"order_number": duplicate.Number,
+		"placed_at":    duplicate.CreatedAt,
+	})
+}
+
+// reserveStock takes the cart line's units from the item's stock. Items
+// sold beyond their stock take what is left and return the number of
+// units to backorder; other items fail with ErrInsufficientStock if short.
+func reserveStock(ctx context.Context, tx repository.Store, ci models.CartItem) (int, error) {
+	ok, err := tx.Items().ReserveStock(ctx, ci.ItemID, ci.Quantity)
+	if err != nil {
+		return 0, apperrors.Internal("failed to reserve stock", err)
+	}
+	if ok {
+		return 0, nil
+	}
+	if ci.Item.Backorder == "" {
+		return 0, insufficientStock(ci)
+	}
+
+	item, err := tx.Items().Get(ctx, ci.ItemID)
+	if err != nil {
+		return 0, apperrors.Internal("failed to fetch item", err)
+	}
+	available := 0
+	if item.Stock != nil {
+		available = max(0, min(*item.Stock, ci.Quantity))
+	}
+	if available > 0 {
+		if ok, err := tx.Items().ReserveStock(ctx, ci.ItemID, available); err != nil {
+			return 0, apperrors.Internal("failed to reserve stock", err)
+		} else if !ok {
+			// Another checkout took the stock since it was read; the
+			// checkout is run again
+			return 0, repository.ErrConflict
+		}
+	}
+	return ci.Quantity - available, nil
+}
+
+// insufficientStock is the error of a cart line short of stock
+func insufficientStock(ci models.CartItem) error {
+	if ci.BundleID != nil {
+		return apperrors.ErrInsufficientStock.
+			WithMessage("not enough stock of " + ci.Item.Name + " for the bundle").
+			WithDetails(map[string]uint{"item_id": ci.ItemID, "bundle_id": *ci.BundleID})
+	}
+	return apperrors.ErrInsufficientStock.
+		WithMessage("not enough stock of " + ci.Item.Name).
+		WithDetails(map[string]uint{"item_id": ci.ItemID})
+}
+
+// orderTransitions are the statuses each order status can move to.
+// Delivered and cancelled orders are final.
+var orderTransitions = map[string][]string{
+	models.OrderPending:   {models.OrderCompleted, models.OrderShipped, models.OrderCancelled},
+	models.OrderCompleted: {models.OrderShipped, models.OrderDelivered, models.OrderCancelled},
+	models.OrderShipped:   {models.OrderDelivered, models.OrderCancelled},
+}
+
+// UpdateStatus moves an order to a new status and notifies its owner.
+// Setting the current status again is a no-op, and moves orderTransitions
+// does not allow fail with INVALID_STATUS_TRANSITION.
+func (s *OrderService) UpdateStatus(ctx context.Context, orderID uint, status string) (models.Order, error) {
+	var order models.Order
+	var previous string
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		var err error
+		order, previous, err = moveOrder(ctx, tx, orderID, status)
+		return err
+	})
+	if err != nil {
+		return models.Order{}, orInternal("failed to update order status", err)
+	}
+
+	if previous != status {
+		logging.FromContext(ctx).Info("order status changed", "order_id", orderID, "from", previous, "to", status)
+	}
+	return order, nil
+}
+
+// OrderStatusResult is the outcome of one order of a bulk status update:
+// its status after it, or the error it failed with
+type OrderStatusResult struct {
+	OrderID uint
+	Number  string
+	Status  string
+	Err     *apperrors.Error
+}
+
+// BulkUpdateStatus moves the orders to status in one transaction, as
+// UpdateStatus does, returning a result per order. If any order cannot be
+// moved nothing is applied: the results are returned with a validation
+// error.
+func (s *OrderService) BulkUpdateStatus(ctx context.Context, orderIDs []uint, status string) ([]OrderStatusResult, error) {
+	var results []OrderStatusResult
+	var previous []string
+	failed := 0
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		results, previous, failed = make([]OrderStatusResult, len(orderIDs)), make([]string, len(orderIDs)), 0
+		for i, id := range orderIDs {
+			order, from, err := moveOrder(ctx, tx, id, status)
+			results[i] = OrderStatusResult{OrderID: id, Number: order.Number, Status: order.Status}
+			previous[i] = from
+			if err != nil {
+				var appErr *apperrors.Error
+				if !errors.As(err, &appErr) || appErr.Status >= http.StatusInternalServerError {
+					return err
+				}
+				results[i].Err = appErr
+				failed++
+			}
+		}
+		if failed > 0 {
+			return errBulkFailed
+		}
+		return nil
+	})
+	if errors.Is(err, errBulkFailed) {
+		return results, apperrors.Validation(fmt.Sprintf("%d of %d orders cannot be moved, so none were", failed, len(orderIDs)))
+	}
+	if err != nil {
+		return nil, orInternal("failed to update order statuses", err)
+	}
+
+	changed := 0
+	for i, result := range results {
+		if previous[i] != status {
+			logging.FromContext(ctx).Info("order status changed", "order_id", result.OrderID, "from", previous[i], "to", status)
+			changed++
+		}
+	}
+	logging.FromContext(ctx).Info("order statuses updated in bulk", "orders", len(orderIDs), "changed", changed, "status", status)
+	return results, nil
+}
+
+// moveOrder moves an order to status within tx, publishing the change, and
+// returns the order and its previous status. Orders that cannot be moved
+// fail with an application error.
+func moveOrder(ctx context.Context, tx repository.Store, orderID uint, status string) (models.Order, string, error) {
+	order, err := tx.Orders().Get(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Order{}, "", apperrors.ErrOrderNotFound
+		}
+		return models.Order{}, "", err
+	}
+
+	previous := order.Status
+	if previous == status {
+		return order, previous, nil
+	}
+	if !slices.Contains(orderTransitions[previous], status) {
+		return order, previous, apperrors.ErrStatusTransition.
+			WithMessage(fmt.Sprintf("a %s order cannot be %s", previous, status)).
+			WithDetails(map[string]string{"from": previous, "to": status})
+	}
+	if err := tx.Orders().UpdateStatus(ctx, orderID, status); err != nil {
+		return order, previous, err
+	}
+	order.Status = status
+	// Cancelled orders give their delivery slot back
+	if status == models.OrderCancelled && order.DeliverySlotID != nil {
+		if err := tx.Delivery().Release(ctx, *order.DeliverySlotID, order.DeliveryDate); err != nil {
+			return order, previous, err
+		}
+	}
+	err = publish(ctx, tx, events.OrderStatusChanged, order.StoreID, order.UserID, events.OrderStatus{
+		OrderID:        order.ID,
+		OrderNumber:    order.Number,
+		UserID:         order.UserID,
+		Status:         status,
+		PreviousStatus: previous,
+		Total:          order.Total,
+	})
+	return order, previous, err
+}
+
+// VendorOrders returns a page of the vendor's sub-orders, with their
+// order's lines, and the next cursor
+func (s *OrderService) VendorOrders(ctx context.Context, vendorID uint, page pagination.Page) ([]models.SubOrder, string, error) {
+	subs, next, err := s.store.Orders().ListSubOrders(ctx, vendorID, page)
+	if err != nil {
+		return nil, "", apperrors.Internal("failed to fetch orders", err)
+	}
+	return subs, next, nil
+}
+
+// UpdateSubOrderStatus moves one of the vendor's sub-orders to a new
+// status. The status of the order it belongs to is left to admins.
+func (s *OrderService) UpdateSubOrderStatus(ctx context.Context, vendorID, id uint, status string) (models.SubOrder, error) {
+	sub, err := s.store.Orders().GetSubOrder(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.SubOrder{}, apperrors.ErrOrderNotFound
+		}
+		return models.SubOrder{}, apperrors.Internal("failed to fetch order", err)
+	}
+	// Other vendors' sub-orders are reported as missing, not forbidden
+	if sub.VendorID != vendorID {
+		return models.SubOrder{}, apperrors.ErrOrderNotFound
+	}
+
+	if sub.Status != status {
+		if err := s.store.Orders().UpdateSubOrderStatus(ctx, id, status); err != nil {
+			return models.SubOrder{}, apperrors.Internal("failed to update order status", err)
+		}
+		logging.FromContext(ctx).Info("vendor order status changed", "sub_order_id", id, "vendor_id", vendorID, "from", sub.Status, "to", status)
+		sub.Status = status
+	}
+	return sub, nil
+}
+
+// Get returns one of the user's orders with its lines and its shipments'
+// tracking. Other users' orders are reported as missing.
+func (s *OrderService) Get(ctx context.Context, userID, id uint) (models.Order, error) {
+	order, err := s.store.Orders().GetDetail(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Order{}, apperrors.ErrOrderNotFound
+		}
+		return models.Order{}, apperrors.Internal("failed to fetch order", err)
+	}
+	if order.UserID != userID {
+		return models.Order{}, apperrors.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// ListByUser returns a page of the user's orders matching the filter,
+// newest first, and the cursors around it
+func (s *OrderService) ListByUser(ctx context.Context, userID uint, filter repository.OrderFilter, page pagination.Keyset) ([]models.Order, pagination.Cursors, error) {
+	filter.Number = normalizeOrderNumber(filter.Number)
+	orders, cursors, err := s.store.Orders().ListByUser(ctx, userID, filter, page)
+	if err != nil {
+		return nil, pagination.Cursors{}, apperrors.Internal("failed to fetch orders", err)
+	}
+	return orders, cursors, nil
+}
+
+// Summaries is ListByUser without the orders' items and promotions
+func (s *OrderService) Summaries(ctx context.Context, userID uint, filter repository.OrderFilter, page pagination.Keyset) ([]models.Order, pagination.Cursors, error) {
+	filter.Number = normalizeOrderNumber(filter.Number)
+	orders, cursors, err := s.store.Orders().Summaries(ctx, userID, filter, page)
+	if err != nil {
+		return nil, pagination.Cursors{}, apperrors.Internal("failed to fetch orders", err)
+	}
+	return orders, cursors, nil
+}
+
+// Each calls fn for every order on the page matching the filter and
+// returns the next cursor
+func (s *OrderService) Each(ctx context.Context, filter repository.OrderFilter, page pagination.Page, fn func(*models.Order) error) (string, error) {
+	filter.Number = normalizeOrderNumber(filter.Number)
+	return s.store.Orders().Each(ctx, filter, page, fn)
+}
+
+// Locate returns the position of the page among the user's orders matching
+// the filter, or every user's if userID is 0
+func (s *OrderService) Locate(ctx context.Context, userID uint, filter repository.OrderFilter, page pagination.Page) (pagination.Position, error) {
+	filter.Number = normalizeOrderNumber(filter.Number)
+	pos, err := s.store.Orders().Locate(ctx, userID, filter, page)
+	if err != nil {
+		return pagination.Position{}, apperrors.Internal("failed to count orders", err)
+	}
+	return pos, nil
+}
+
+// newOrderNumber returns an unused order number such as ORD-2024-48213907
+// for an order placed at the given time. The digits are random, so numbers
+// reveal nothing about how many orders are placed. Numbers are unique
+// across stores.
+func newOrderNumber(ctx context.Context, tx repository.Store, at time.Time) (string, error) {
+	for {
+		n, err := rand.Int(rand.Reader, big.NewInt(orderNumberSpace))
+		if err != nil {
+			return "", err
+		}
+		number := fmt.Sprintf("ORD-%d-%08d", at.Year(), n.Int64())
+
+		exists, err := tx.Orders().NumberExists(tenant.WithoutStore(ctx), number)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return number, nil
+		}
+	}
+}
+
+// normalizeOrderNumber accepts numbers typed in lower case or with
+// surrounding spaces
+func normalizeOrderNumber(number string) string {
+	return strings.ToUpper(strings.TrimSpace(number))
+}
+
+// splitByVendor returns a sub-order for each vendor selling items in the
+// cart, in order of first appearance, totalling its lines less their
+// discounts. Items sold by the store itself are not part of any sub-order.
+func splitByVendor(cart models.Cart, pricing Pricing) []models.SubOrder {
+	var subs []models.SubOrder
+	index := map[uint]int{}
+	for _, ci := range cart.CartItems {
+		if ci.Item.VendorID == nil {
+			continue
+		}
+		vendorID := *ci.Item.VendorID
+		i, ok := index[vendorID]
+		if !ok {
+			i = len(subs)
+			index[vendorID] = i
+			subs = append(subs, models.SubOrder{VendorID: vendorID})
+		}
+		subs[i].Total += ci.Item.Price*float64(ci.Quantity) - pricing.lines[ci.ID]
+	}
+	for i := range subs {
+		subs[i].Total = math.Round(subs[i].Total*100) / 100
+	}
+	return subs
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/events"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/notifications"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/webhooks"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// outboxBatchSize is how many messages a relay claims at a time
+	outboxBatchSize = 100
+	// outboxLease is how long a relay holds the messages it claimed; a
+	// relay stopped while holding them leaves them to others after it
+	outboxLease = time.Minute
+	// outboxBackoff is the wait before a failed webhook, SMS or push
+	// delivery is retried; it doubles with each further attempt up to outboxMaxBackoff
+	outboxBackoff    = 10 * time.Second
+	outboxMaxBackoff = time.Hour
+)
+
+var (
+	// errNoWebhook fails deliveries to API keys that were revoked or no
+	// longer receive webhooks
+	errNoWebhook = errors.New("the api key no longer receives webhooks")
+	// errNoSMS fails SMS alerts to users who opted out of them or no longer
+	// have a verified phone
+	errNoSMS = errors.New("the user no longer receives sms alerts")
+	// errNoDevice fails push notifications to devices that were
+	// unregistered
+	errNoDevice = errors.New("the device is no longer registered")
+)
+
+// smsStatuses are the order statuses customers who opted in are alerted of
+// by SMS
+var smsStatuses = map[string]bool{models.OrderShipped: true, models.OrderDelivered: true}
+
+// publish writes an event to the outbox of tx, to be relayed once the
+// transaction commits
+func publish(ctx context.Context, tx repository.Store, eventType string, storeID, userID uint, data interface{}) error {
+	msg, err := events.New(eventType, storeID, userID, data).Message()
+	if err != nil {
+		return err
+	}
+	return tx.Outbox().Add(ctx, &msg)
+}
+
+// OutboxService relays the events written to the outbox. Events are
+// relayed at least once: one relayed just before its relay stopped may be
+// relayed again, so consumers should skip event IDs they have seen.
+type OutboxService struct {
+	store repository.Store
+	cfg   config.OutboxConfig
+}
+
+// Relay dispatches the events due in the outbox on the event bus, adding a
+// message for the webhook of each of the store's live API keys issued to
+// admins, one for the SMS alert of orders shipped or delivered to customers
+// who opted in, and one for the push notification of each device of the
+// user the event concerns, as it marks each event relayed
+func (s *OutboxService) Relay(ctx context.Context) error {
+	return s.relayAll(ctx, models.OutboxBus, func(msg models.OutboxMessage) error {
+		events.Dispatch(events.FromMessage(msg))
+
+		return s.store.Transaction(ctx, func(tx repository.Store) error {
+			keys, err := tx.Outbox().WebhookKeys(ctx, msg.StoreID)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			for _, key := range keys {
+				hook := models.OutboxMessage{
+					StoreID:       msg.StoreID,
+					EventID:       msg.EventID,
+					Type:          msg.Type,
+					UserID:        msg.UserID,
+					Data:          msg.Data,
+					OccurredAt:    msg.OccurredAt,
+					Channel:       models.OutboxWebhook,
+					APIKeyID:      &key.ID,
+					NextAttemptAt: now,
+				}
+				if err := tx.Outbox().Add(ctx, &hook); err != nil {
+					return err
+				}
+			}
+
+			alert, err := s.wantsSMS(ctx, tx, msg)
+			if err != nil {
+				return err
+			}
+			if alert {
+				sms := models.OutboxMessage{
+					StoreID:       msg.StoreID,
+					EventID:       msg.EventID,
+					Type:          msg.Type,
+					UserID:        msg.UserID,
+					Data:          msg.Data,
+					OccurredAt:    msg.OccurredAt,
+					Channel:       models.OutboxSMS,
+					NextAttemptAt: now,
+				}
+				if err := tx.Outbox().Add(ctx, &sms); err != nil {
+					return err
+				}
+			}
+
+			devices, err := s.pushDevices(ctx, tx, msg)
+			if err != nil {
+				return err
+			}
+			for _, device := range devices {
+				push := models.OutboxMessage{
+					StoreID:       msg.StoreID,
+					EventID:       msg.EventID,
+					Type:          msg.Type,
+					UserID:        msg.UserID,
+					Data:          msg.Data,
+					OccurredAt:    msg.OccurredAt,
+					Channel:       models.OutboxPush,
+					DeviceTokenID: &device.ID,
+					NextAttemptAt: now,
+				}
+				if err := tx.Outbox().Add(ctx, &push); err != nil {
+					return err
+				}
+			}
+			return tx.Outbox().Delivered(ctx, msg.ID, now)
+		})
+	})
+}
+
+// RelayWebhooks delivers the webhook messages due in the outbox. Failed
+// deliveries are retried after a growing delay and given up on after
+// OUTBOX_MAX_ATTEMPTS attempts.
+func (s *OutboxService) RelayWebhooks(ctx context.Context) error {
+	return s.relayAll(ctx, models.OutboxWebhook, func(msg models.OutboxMessage) error {
+		err := s.deliver(ctx, msg)
+		return s.settle(ctx, msg, err, errors.Is(err, errNoWebhook), "api_key_id", *msg.APIKeyID)
+	})
+}
+
+// RelaySMS sends the SMS alerts due in the outbox. Provider failures are
+// retried like webhook deliveries; alerts to users who have since opted
+// out are dropped.
+func (s *OutboxService) RelaySMS(ctx context.Context) error {
+	return s.relayAll(ctx, models.OutboxSMS, func(msg models.OutboxMessage) error {
+		err := s.sendSMS(ctx, msg)
+		return s.settle(ctx, msg, err, errors.Is(err, errNoSMS), "user_id", msg.UserID)
+	})
+}
+
+// RelayPush sends the push notifications due in the outbox. Failures are
+// retried like webhook deliveries; devices the push provider no longer
+// knows are unregistered and their notifications dropped.
+func (s *OutboxService) RelayPush(ctx context.Context) error {
+	return s.relayAll(ctx, models.OutboxPush, func(msg models.OutboxMessage) error {
+		err := s.sendPush(ctx, msg)
+		final := errors.Is(err, errNoDevice) || errors.Is(err, notifications.ErrUnregistered)
+		return s.settle(ctx, msg, err, final, "device_token_id", *msg.DeviceTokenID)
+	})
+}
+
+// settle records the outcome of delivering msg: delivered if err is nil,
+// given up on if final or out of attempts, and retried after a growing
+// delay otherwise. attrs identify the recipient in the log.
+func (s *OutboxService) settle(ctx context.Context, msg models.OutboxMessage, err error, final bool, attrs ...interface{}) error {
+	now := time.Now()
+	if err == nil {
+		return s.store.Outbox().Delivered(ctx, msg.ID, now)
+	}
+
+	if msg.Attempts >= s.cfg.MaxAttempts || final {
+		args := append([]interface{}{"channel", msg.Channel, "event_id", msg.EventID, "type", msg.Type}, attrs...)
+		args = append(args, "attempts", msg.Attempts, "error", err)
+		logging.FromContext(ctx).Warn("giving up on outbox message", args...)
+		return s.store.Outbox().GiveUp(ctx, msg.ID, err.Error(), now)
+	}
+	backoff := min(outboxBackoff<<(msg.Attempts-1), outboxMaxBackoff)
+	return s.store.Outbox().Retry(ctx, msg.ID, err.Error(), now.Add(backoff))
+}
+
+// relayAll relays the due messages of the channel batch by batch until none
+// are left. Errors recording the outcome stop it; the messages are then
+// relayed again once their lease ends.
+func (s *OutboxService) relayAll(ctx context.Context, channel string, relay func(msg models.OutboxMessage) error) error {
+	for {
+		msgs, err := s.store.Outbox().Claim(ctx, channel, time.Now(), outboxLease, outboxBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			if err := relay(msg); err != nil {
+				return err
+			}
+		}
+		if len(msgs) < outboxBatchSize || ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *OutboxService) deliver(ctx context.Context, msg models.OutboxMessage) error {
+	key, err := s.store.Outbox().WebhookKey(ctx, *msg.APIKeyID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && (key.Sandbox || key.WebhookURL == "")) {
+		return errNoWebhook
+	}
+	if err != nil {
+		return err
+	}
+
+	event := events.FromMessage(msg)
+	return webhooks.Deliver(ctx, key.WebhookURL, key.WebhookSecret, webhooks.Event{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.OccurredAt,
+		Data:      event.Data,
+	})
+}
+
+// wantsSMS reports whether msg is an order shipped or delivered to a
+// customer who opted in to SMS alerts
+func (s *OutboxService) wantsSMS(ctx context.Context, tx repository.Store, msg models.OutboxMessage) (bool, error) {
+	if msg.Type != events.OrderStatusChanged || msg.UserID == 0 {
+		return false, nil
+	}
+	var order events.OrderStatus
+	if err := json.Unmarshal([]byte(msg.Data), &order); err != nil || !smsStatuses[order.Status] {
+		return false, err
+	}
+	user, err := tx.Users().Get(ctx, msg.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.SMSOptIn && user.PhoneVerifiedAt != nil, nil
+}
+
+func (s *OutboxService) sendSMS(ctx context.Context, msg models.OutboxMessage) error {
+	user, err := s.store.Users().Get(ctx, msg.UserID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && (!user.SMSOptIn || user.PhoneVerifiedAt == nil)) {
+		return errNoSMS
+	}
+	if err != nil {
+		return err
+	}
+
+	var order events.OrderStatus
+	if err := json.Unmarshal([]byte(msg.Data), &order); err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Your order %s has been %s.", order.OrderNumber, order.Status)
+	return notifications.SendSMS(ctx, notifications.SMS{To: user.Phone, Body: body})
+}
+
+// pushDevices returns the devices of the user msg concerns when it is an
+// event sent as a push notification
+func (s *OutboxService) pushDevices(ctx context.Context, tx repository.Store, msg models.OutboxMessage) ([]models.DeviceToken, error) {
+	if (msg.Type != events.OrderStatusChanged && msg.Type != events.BackInStock) || msg.UserID == 0 {
+		return nil, nil
+	}
+	return tx.Devices().ListByUser(ctx, msg.UserID)
+}
+
+func (s *OutboxService) sendPush(ctx context.Context, msg models.OutboxMessage) error {
+	device, err := s.store.Devices().Get(ctx, *msg.DeviceTokenID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && device.UserID != msg.UserID) {
+		return errNoDevice
+	}
+	if err != nil {
+		return err
+	}
+
+	push, err := pushMessage(msg)
+	if err != nil {
+		return err
+	}
+	push.Platform, push.Token = device.Platform, device.Token
+	err = notifications.SendPush(ctx, push)
+	if errors.Is(err, notifications.ErrUnregistered) {
+		if err := s.store.Devices().Delete(ctx, device.ID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+	}
+	return err
+}
+
+// pushMessage composes the push notification of msg
+func pushMessage(msg models.OutboxMessage) (notifications.Push, error) {
+	switch msg.Type {
+	case events.BackInStock:
+		var restock events.Restock
+		if err := json.Unmarshal([]byte(msg.Data), &restock); err != nil {
+			return notifications.Push{}, err
+		}
+		return notifications.Push{
+			Title: "Back in stock",
+			Body:  restock.Name + " is back in stock.",
+			Data: map[string]string{
+				"event_id": msg.EventID,
+				"type":     msg.Type,
+				"item_id":  strconv.FormatUint(uint64(restock.ItemID), 10),
+			},
+		}, nil
+	}
+
+	var order events.OrderStatus
+	if err := json.Unmarshal([]byte(msg.Data), &order); err != nil {
+		return notifications.Push{}, err
+	}
+	return notifications.Push{
+		Title: "Order " + order.OrderNumber,
+		Body:  fmt.Sprintf("Your order is now %s.", order.Status),
+		Data: map[string]string{
+			"event_id":     msg.EventID,
+			"type":         msg.Type,
+			"order_id":     strconv.FormatUint(uint64(order.OrderID), 10),
+			"order_number": order.OrderNumber,
+			"status":       order.Status,
+		},
+	}, nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/notifications"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	// phoneCodeTTL is how long a phone verification code can be entered
+	phoneCodeTTL = 10 * time.Minute
+	// phoneCodeResend is the wait before another code can be sent, so the
+	// endpoint cannot be used to flood a number with texts
+	phoneCodeResend = time.Minute
+	// phoneCodeAttempts is how many wrong codes void the code sent
+	phoneCodeAttempts = 5
+)
+
+// SetPhone changes the user's phone, an empty phone removing it, and
+// returns the updated user. A new number is texted a code to verify it
+// with, and the user is opted out of SMS alerts until it is verified.
+func (s *UserService) SetPhone(ctx context.Context, userID uint, phone string) (models.User, error) {
+	user, err := s.Get(ctx, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+	if phone == "" {
+		if err := s.store.Users().SetPhone(ctx, userID, "", "", nil); err != nil {
+			return models.User{}, apperrors.Internal("failed to update phone", err)
+		}
+		return s.Get(ctx, userID)
+	}
+	if phone == user.Phone && user.PhoneVerifiedAt != nil {
+		return user, nil
+	}
+
+	now := time.Now()
+	if user.PhoneCodeSentAt != nil && now.Sub(*user.PhoneCodeSentAt) < phoneCodeResend {
+		return models.User{}, apperrors.ErrRateLimited
+	}
+	code, err := newPhoneCode()
+	if err != nil {
+		return models.User{}, apperrors.Internal("failed to generate verification code", err)
+	}
+	msg := notifications.SMS{To: phone, Body: fmt.Sprintf("Your verification code is %s.", code)}
+	if err := notifications.SendSMS(ctx, msg); err != nil {
+		logging.FromContext(ctx).Error("failed to send phone verification code", "user_id", userID, "error", err)
+		return models.User{}, apperrors.ErrSMSUnavailable
+	}
+
+	if err := s.store.Users().SetPhone(ctx, userID, phone, hashPhoneCode(userID, code), &now); err != nil {
+		return models.User{}, apperrors.Internal("failed to update phone", err)
+	}
+	return s.Get(ctx, userID)
+}
+
+// VerifyPhone verifies the user's phone with the code texted to it and
+// returns the updated user
+func (s *UserService) VerifyPhone(ctx context.Context, userID uint, code string) (models.User, error) {
+	user, err := s.Get(ctx, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+	if user.PhoneCodeHash == "" || user.PhoneCodeSentAt == nil ||
+		time.Since(*user.PhoneCodeSentAt) > phoneCodeTTL || user.PhoneCodeAttempts >= phoneCodeAttempts {
+		return models.User{}, apperrors.ErrPhoneCodeInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(hashPhoneCode(userID, code)), []byte(user.PhoneCodeHash)) != 1 {
+		if err := s.store.Users().FailPhoneCode(ctx, userID); err != nil {
+			return models.User{}, apperrors.Internal("failed to verify phone", err)
+		}
+		return models.User{}, apperrors.ErrPhoneCodeInvalid
+	}
+
+	if err := s.store.Users().VerifyPhone(ctx, userID, time.Now()); err != nil {
+		return models.User{}, apperrors.Internal("failed to verify phone", err)
+	}
+	return s.Get(ctx, userID)
+}
+
+// SetSMSOptIn opts the user in to or out of SMS alerts for their orders and
+// returns the updated user. Opting in requires a verified phone.
+func (s *UserService) SetSMSOptIn(ctx context.Context, userID uint, optIn bool) (models.User, error) {
+	user, err := s.Get(ctx, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+	if optIn && user.PhoneVerifiedAt == nil {
+		return models.User{}, apperrors.ErrPhoneNotVerified
+	}
+	if err := s.store.Users().SetSMSOptIn(ctx, userID, optIn); err != nil {
+		return models.User{}, apperrors.Internal("failed to update notification settings", err)
+	}
+	return s.Get(ctx, userID)
+}
+
+// newPhoneCode returns a random six-digit verification code
+func newPhoneCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashPhoneCode returns the hex-encoded SHA-256 hash the user's code is
+// stored as
+func hashPhoneCode(userID uint, code string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", userID, code)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+type PromotionService struct {
+	store repository.Store
+}
+
+// AppliedPromotion is a promotion applied to a cart and the amount it
+// takes off
+type AppliedPromotion struct {
+	Promotion models.Promotion
+	Amount    float64
+}
+
+// AppliedSale is a sale applied to a cart, the units it covers and the
+// amount it takes off them
+type AppliedSale struct {
+	Sale     models.Sale
+	Quantity int
+	Amount   float64
+}
+
+// AppliedBundle is a bundle in a cart, the units of it and the amount its
+// price takes off its items
+type AppliedBundle struct {
+	Bundle   models.Bundle
+	Quantity int
+	Amount   float64
+}
+
+// AppliedAdjustment is an amount a registered PriceAdjuster took off a
+// cart, under the name the adjuster gave it
+type AppliedAdjustment struct {
+	Name   string
+	Amount float64
+}
+
+// Pricing is what a cart costs once its bundles, promotions and sales, and
+// the registered price adjusters, are applied
+type Pricing struct {
+	Subtotal    float64
+	Discount    float64
+	Total       float64
+	Promotions  []AppliedPromotion
+	Sales       []AppliedSale
+	Bundles     []AppliedBundle
+	Adjustments []AppliedAdjustment
+	// lines maps cart item IDs to the amount taken off them
+	lines map[uint]float64
+	// purchases are the cart lines bought at a sale price, to be recorded
+	// with the order
+	purchases []models.SalePurchase
+	// bundles are the bundles whose lines are in the cart, by ID
+	bundles map[uint]models.Bundle
+}
+
+// Adjust takes amount off the cart as the named adjustment, up to what is
+// left of the total, and returns the amount taken off. Adjustments apply
+// to the order as a whole, so vendors' sub-orders are not given them.
+func (p *Pricing) Adjust(name string, amount float64) float64 {
+	amount = math.Round(min(amount, p.Total)*100) / 100
+	if amount <= 0 {
+		return 0
+	}
+	p.Adjustments = append(p.Adjustments, AppliedAdjustment{Name: name, Amount: amount})
+	p.Discount = math.Round((p.Discount+amount)*100) / 100
+	p.Total = math.Round((p.Subtotal-p.Discount)*100) / 100
+	return amount
+}
+
+// Create adds a promotion to the store. Promotions of an item require the
+// item to exist.
+func (s *PromotionService) Create(ctx context.Context, promotion *models.Promotion) error {
+	if promotion.ItemID != nil {
+		if _, err := s.store.Items().Get(ctx, *promotion.ItemID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return apperrors.ErrItemNotFound
+			}
+			return apperrors.Internal("failed to fetch item", err)
+		}
+	}
+
+	if err := s.store.Promotions().Create(ctx, promotion); err != nil {
+		return apperrors.Internal("failed to create promotion", err)
+	}
+	return nil
+}
+
+// List returns the store's promotions, including those not running
+func (s *PromotionService) List(ctx context.Context) ([]models.Promotion, error) {
+	promotions, err := s.store.Promotions().List(ctx)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch promotions", err)
+	}
+	return promotions, nil
+}
+
+// Delete removes a promotion. Orders keep the discounts they were given.
+func (s *PromotionService) Delete(ctx context.Context, id uint) error {
+	if err := s.store.Promotions().Delete(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrPromotionNotFound
+		}
+		return apperrors.Internal("failed to delete promotion", err)
+	}
+	return nil
+}
+
+// Price applies the cart's bundles, the promotions running now and the
+// active sales to the cart
+func (s *PromotionService) Price(ctx context.Context, cart models.Cart) (Pricing, error) {
+	pricing, err := priceCart(ctx, s.store, cart)
+	if err != nil {
+		return Pricing{}, orInternal("failed to price cart", err)
+	}
+	return pricing, nil
+}
+
+// priceCart runs the price adjusters over the cart: the built-in ones,
+// which apply the cart's bundles, the promotions running now and the
+// active sales, then those registered
+func priceCart(ctx context.Context, store repository.Store, cart models.Cart) (Pricing, error) {
+	bundles, err := bundlesIn(ctx, store, cart)
+	if err != nil {
+		return Pricing{}, err
+	}
+	subtotal := Total(cart)
+	pricing := Pricing{Subtotal: subtotal, Total: subtotal, lines: map[uint]float64{}, bundles: bundles}
+	for _, h := range registered(&priceAdjusters) {
+		if err := h.hook.Adjust(ctx, store, cart, &pricing); err != nil {
+			return Pricing{}, fmt.Errorf("price adjuster %s: %w", h.name, err)
+		}
+	}
+	return pricing, nil
+}
+
+// adjustPromotions is the built-in adjuster applying the promotions
+// running now and the active sales
+func adjustPromotions(ctx context.Context, store repository.Store, cart models.Cart, pricing *Pricing) error {
+	promotions, err := store.Promotions().Active(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	sales, err := store.Sales().Active(ctx)
+	if err != nil {
+		return err
+	}
+	purchased, err := salesPurchased(ctx, store, cart.UserID, sales)
+	if err != nil {
+		return err
+	}
+	applyPromotions(pricing, cart, promotions, sales, purchased)
+	return nil
+}
+
+// adjustBundles is the built-in adjuster applying the cart's bundles
+func adjustBundles(ctx context.Context, store repository.Store, cart models.Cart, pricing *Pricing) error {
+	applyBundles(pricing, cart)
+	return nil
+}
+
+// applyPromotions gives each line of the cart the promotion or sale taking
+// the most off it: the oldest promotion on ties, unless a sale takes as
+// much. Sales with a per-user limit only cover the units the owner has left
+// of it, given those already bought as counted in purchased. Lines of the
+// bundles, which are priced as a unit, are left out. Promotions and sales
+// are listed in the order they were first applied.
+func applyPromotions(pricing *Pricing, cart models.Cart, promotions []models.Promotion, sales []models.Sale, purchased map[uint]int) {
+	index := map[uint]int{}
+	saleIndex := map[uint]int{}
+	for _, ci := range cart.CartItems {
+		if ci.BundleID != nil {
+			if _, ok := pricing.bundles[*ci.BundleID]; ok {
+				continue
+			}
+		}
+		best, discount := -1, 0.0
+		for i, p := range promotions {
+			if d := p.Discount(ci.Item, ci.Quantity); d > discount {
+				best, discount = i, d
+			}
+		}
+
+		if sale, units, d := bestSale(ci, sales, purchased); units > 0 && d >= discount {
+			discount = d
+			purchased[sale.ID] += units
+			pricing.purchases = append(pricing.purchases, models.SalePurchase{
+				SaleID:   sale.ID,
+				UserID:   cart.UserID,
+				ItemID:   ci.ItemID,
+				Name:     sale.Name,
+				Quantity: units,
+				Amount:   d,
+			})
+			i, ok := saleIndex[sale.ID]
+			if !ok {
+				i = len(pricing.Sales)
+				saleIndex[sale.ID] = i
+				pricing.Sales = append(pricing.Sales, AppliedSale{Sale: sale})
+			}
+			pricing.Sales[i].Quantity += units
+			pricing.Sales[i].Amount = math.Round((pricing.Sales[i].Amount+d)*100) / 100
+		} else if best >= 0 {
+			i, ok := index[promotions[best].ID]
+			if !ok {
+				i = len(pricing.Promotions)
+				index[promotions[best].ID] = i
+				pricing.Promotions = append(pricing.Promotions, AppliedPromotion{Promotion: promotions[best]})
+			}
+			pricing.Promotions[i].Amount = math.Round((pricing.Promotions[i].Amount+discount)*100) / 100
+		} else {
+			continue
+		}
+
+		pricing.lines[ci.ID] += discount
+		pricing.Discount += discount
+	}
+	pricing.Discount = math.Round(pricing.Discount*100) / 100
+	pricing.Total = math.Round((pricing.Subtotal-pricing.Discount)*100) / 100
+}
+
+// applyBundles takes what the bundles save off their lines in the cart,
+// spreading each bundle's saving over its lines by their value. Bundles
+// are listed in the order they appear in the cart.
+func applyBundles(pricing *Pricing, cart models.Cart) {
+	bundles := pricing.bundles
+	lines := map[uint][]models.CartItem{}
+	var order []uint
+	for _, ci := range cart.CartItems {
+		if ci.BundleID == nil {
+			continue
+		}
+		if _, ok := bundles[*ci.BundleID]; !ok {
+			continue
+		}
+		if lines[*ci.BundleID] == nil {
+			order = append(order, *ci.BundleID)
+		}
+		lines[*ci.BundleID] = append(lines[*ci.BundleID], ci)
+	}
+
+	for _, id := range order {
+		bundle := bundles[id]
+		units := bundleUnits(bundle, lines[id])
+		saving := math.Round(bundle.Saving()*float64(units)*100) / 100
+		if units == 0 || saving == 0 {
+			continue
+		}
+
+		var value float64
+		for _, ci := range lines[id] {
+			value += ci.Item.Price * float64(ci.Quantity)
+		}
+		left := saving
+		for i, ci := range lines[id] {
+			share := left
+			if i < len(lines[id])-1 {
+				share = math.Round(saving*ci.Item.Price*float64(ci.Quantity)/value*100) / 100
+			}
+			left = math.Round((left-share)*100) / 100
+			pricing.lines[ci.ID] += share
+		}
+		pricing.Discount = math.Round((pricing.Discount+saving)*100) / 100
+		pricing.Bundles = append(pricing.Bundles, AppliedBundle{Bundle: bundle, Quantity: units, Amount: saving})
+	}
+	pricing.Total = math.Round((pricing.Subtotal-pricing.Discount)*100) / 100
+}
+
+// bundleUnits returns how many whole units of the bundle its lines in a
+// cart hold
+func bundleUnits(bundle models.Bundle, lines []models.CartItem) int {
+	held := map[uint]int{}
+	for _, ci := range lines {
+		held[ci.ItemID] += ci.Quantity
+	}
+	units := -1
+	for _, bi := range bundle.Items {
+		if n := held[bi.ItemID] / max(bi.Quantity, 1); units < 0 || n < units {
+			units = n
+		}
+	}
+	return max(units, 0)
+}
+
+// bundlesIn returns the bundles whose lines are in the cart, by ID.
+// Deleted bundles are left out, so their lines are priced as separate
+// items.
+func bundlesIn(ctx context.Context, store repository.Store, cart models.Cart) (map[uint]models.Bundle, error) {
+	var ids []uint
+	for _, ci := range cart.CartItems {
+		if ci.BundleID != nil {
+			ids = append(ids, *ci.BundleID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	found, err := store.Bundles().GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	bundles := make(map[uint]models.Bundle, len(found))
+	for _, bundle := range found {
+		bundles[bundle.ID] = bundle
+	}
+	return bundles, nil
+}
+
+// bestSale returns the sale taking the most off the cart line, the units
+// it covers and the amount it takes off them; units is 0 if no sale covers
+// the line
+func bestSale(ci models.CartItem, sales []models.Sale, purchased map[uint]int) (models.Sale, int, float64) {
+	var best models.Sale
+	units, discount := 0, 0.0
+	for _, sale := range sales {
+		if !sale.Applies(ci.Item) {
+			continue
+		}
+		n := ci.Quantity
+		if sale.PerUserLimit > 0 {
+			n = min(n, sale.PerUserLimit-purchased[sale.ID])
+		}
+		if n <= 0 {
+			continue
+		}
+		d := math.Round((ci.Item.Price-sale.UnitPrice(ci.Item))*float64(n)*100) / 100
+		if d > discount {
+			best, units, discount = sale, n, d
+		}
+	}
+	return best, units, discount
+}
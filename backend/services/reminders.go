@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/emails"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/notifications"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/tenant"
+	"fmt"
+	"time"
+)
+
+// reminderBatchSize is how many abandoned carts are reminded per run
+const reminderBatchSize = 100
+
+// RemindAbandoned emails the owners of carts left unchanged for
+// AbandonedAfter a reminder of what they hold, with a gift card coupon
+// when one is configured. It runs across all stores. Each cart is reminded
+// once, each user at most once per ReminderCooldown, and users without an
+// email are left alone.
+func (s *CartService) RemindAbandoned(ctx context.Context) error {
+	if s.cfg.AbandonedAfter <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	carts, err := s.store.Carts().Abandoned(tenant.WithoutStore(ctx),
+		now.Add(-s.cfg.AbandonedAfter), now.Add(-s.cfg.ReminderCooldown), reminderBatchSize)
+	if err != nil {
+		return err
+	}
+
+	reminded := map[uint]bool{}
+	for _, cart := range carts {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Users holding several abandoned carts hear about the oldest
+		if reminded[cart.UserID] {
+			continue
+		}
+		if err := s.remind(tenant.WithStore(ctx, cart.StoreID), cart); err != nil {
+			// The carts after it are retried on the next run
+			return fmt.Errorf("failed to remind user %d of cart %d: %w", cart.UserID, cart.ID, err)
+		}
+		reminded[cart.UserID] = true
+	}
+
+	if len(reminded) > 0 {
+		logging.FromContext(ctx).Info("abandoned cart reminders sent", "count", len(reminded))
+	}
+	return nil
+}
+
+// remind records a reminder of the cart, with its coupon, and emails it to
+// the cart's owner. The reminder stays recorded if the email fails, so no
+// one is sent the same reminder twice.
+func (s *CartService) remind(ctx context.Context, cart models.Cart) error {
+	pricing, err := priceCart(ctx, s.store, cart)
+	if err != nil {
+		return err
+	}
+
+	reminder := models.CartReminder{CartID: cart.ID, UserID: cart.UserID, Email: cart.User.Email}
+	var coupon *models.GiftCard
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		if s.cfg.ReminderCoupon > 0 {
+			card, err := issueGiftCard(ctx, tx, s.cfg.ReminderCoupon, nil, &cart.UserID)
+			if err != nil {
+				return err
+			}
+			coupon, reminder.GiftCardID = &card, &card.ID
+		}
+		return tx.Carts().RecordReminder(ctx, &reminder)
+	})
+	if err != nil {
+		return err
+	}
+
+	msg, err := emails.Render(emails.CartReminder, reminderData(cart, pricing, coupon))
+	if err != nil {
+		return err
+	}
+	msg.To = []string{cart.User.Email}
+	return notifications.Send(ctx, msg)
+}
+
+// reminderData lists the cart's items and total, and the coupon's code
+func reminderData(cart models.Cart, pricing Pricing, coupon *models.GiftCard) emails.CartReminderData {
+	data := emails.CartReminderData{Username: cart.User.Username, Total: pricing.Total, Discount: pricing.Discount}
+	for _, ci := range cart.CartItems {
+		data.Lines = append(data.Lines, emails.Line{
+			Name:     ci.Item.Name,
+			Quantity: ci.Quantity,
+			Amount:   ci.Item.Price * float64(ci.Quantity),
+		})
+	}
+	if coupon != nil {
+		data.Coupon = &emails.Coupon{Code: coupon.Code, Amount: coupon.Balance}
+	}
+	return data
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/tenant"
+	"errors"
+	"time"
+)
+
+type SaleService struct {
+	store repository.Store
+}
+
+// Create adds a sale to the store. Sales of an item require the item to
+// exist. A sale whose window is already open starts right away.
+func (s *SaleService) Create(ctx context.Context, sale *models.Sale) error {
+	if sale.ItemID != nil {
+		if _, err := s.store.Items().Get(ctx, *sale.ItemID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return apperrors.ErrItemNotFound
+			}
+			return apperrors.Internal("failed to fetch item", err)
+		}
+	}
+
+	sale.Active = sale.Running(time.Now())
+	if err := s.store.Sales().Create(ctx, sale); err != nil {
+		return apperrors.Internal("failed to create sale", err)
+	}
+	return nil
+}
+
+// List returns the store's sales, including those not running
+func (s *SaleService) List(ctx context.Context) ([]models.Sale, error) {
+	sales, err := s.store.Sales().List(ctx)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch sales", err)
+	}
+	return sales, nil
+}
+
+// Delete removes a sale. Orders keep the discounts they were given.
+func (s *SaleService) Delete(ctx context.Context, id uint) error {
+	if err := s.store.Sales().Delete(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrSaleNotFound
+		}
+		return apperrors.Internal("failed to delete sale", err)
+	}
+	return nil
+}
+
+// Schedule starts the sales of every store whose window has opened and
+// ends those whose window has closed, returning how many changed
+func (s *SaleService) Schedule(ctx context.Context) (int64, error) {
+	changed, err := s.store.Sales().Schedule(tenant.WithoutStore(ctx), time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if changed > 0 {
+		logging.FromContext(ctx).Info("sales started or ended", "count", changed)
+	}
+	return changed, nil
+}
+
+// markSales sets the Sale of each item on an active sale to the sale
+// giving it the lowest price
+func markSales(ctx context.Context, store repository.Store, items []models.Item) error {
+	sales, err := store.Sales().Active(ctx)
+	if err != nil || len(sales) == 0 {
+		return err
+	}
+	for i := range items {
+		items[i].Sale = nil
+		for _, sale := range sales {
+			if !sale.Applies(items[i]) {
+				continue
+			}
+			price := sale.UnitPrice(items[i])
+			if items[i].Sale == nil || price < items[i].Sale.Price {
+				items[i].Sale = &models.ItemSale{
+					SaleID:       sale.ID,
+					Name:         sale.Name,
+					Price:        price,
+					EndsAt:       sale.EndsAt,
+					PerUserLimit: sale.PerUserLimit,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// salesPurchased returns the units the user bought at the price of each of
+// the sales with a per-user limit, by sale ID
+func salesPurchased(ctx context.Context, store repository.Store, userID uint, sales []models.Sale) (map[uint]int, error) {
+	var limited []uint
+	for _, sale := range sales {
+		if sale.PerUserLimit > 0 {
+			limited = append(limited, sale.ID)
+		}
+	}
+	if len(limited) == 0 {
+		return map[uint]int{}, nil
+	}
+	return store.Sales().Purchased(ctx, userID, limited)
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/config"
+	"ecommerce-backend/repository"
+	"errors"
+)
+
+// Services holds the business logic used by the HTTP handlers. Each service
+// works through repository interfaces, so it can run against the database
+// or against repository.NewMemory in tests.
+type Services struct {
+	Users      *UserService
+	Items      *ItemService
+	Carts      *CartService
+	Orders     *OrderService
+	Vendors    *VendorService
+	Shipping   *ShippingService
+	Delivery   *DeliveryService
+	Tracking   *TrackingService
+	GiftCards  *GiftCardService
+	Promotions *PromotionService
+	Sales      *SaleService
+	Bundles    *BundleService
+	Downloads  *DownloadService
+	Warehouses *WarehouseService
+	Addresses  *AddressService
+	Devices    *DeviceService
+	Flags      *FlagService
+	Outbox     *OutboxService
+}
+
+// New builds the services on top of store
+func New(store repository.Store, cfg *config.Config) *Services {
+	return &Services{
+		Users:      &UserService{store: store, cfg: cfg.Accounts, impersonationTTL: cfg.JWT.ImpersonationTTL},
+		Items:      &ItemService{store: store},
+		Carts:      &CartService{store: store, cfg: cfg.Carts, secret: cfg.JWT.Secret},
+		Orders:     &OrderService{store: store, downloads: cfg.Downloads, rules: checkoutRules(cfg.Checkout), delivery: newDeliveryCalendar(cfg.Delivery), checkout: cfg.Checkout},
+		Vendors:    &VendorService{store: store},
+		Shipping:   &ShippingService{store: store},
+		Delivery:   &DeliveryService{store: store, calendar: newDeliveryCalendar(cfg.Delivery)},
+		Tracking:   &TrackingService{store: store},
+		GiftCards:  &GiftCardService{store: store},
+		Promotions: &PromotionService{store: store},
+		Sales:      &SaleService{store: store},
+		Bundles:    &BundleService{store: store},
+		Downloads:  &DownloadService{store: store, cfg: cfg.Downloads, secret: downloadSecret(cfg)},
+		Warehouses: &WarehouseService{store: store},
+		Addresses:  &AddressService{store: store},
+		Devices:    &DeviceService{store: store},
+		Flags:      &FlagService{store: store},
+		Outbox:     &OutboxService{store: store, cfg: cfg.Outbox},
+	}
+}
+
+// downloadSecret is the key signing download links: the configured one,
+// or the JWT secret
+func downloadSecret(cfg *config.Config) string {
+	if cfg.Downloads.Secret != "" {
+		return cfg.Downloads.Secret
+	}
+	return cfg.JWT.Secret
+}
+
+// orInternal passes application errors through and turns any other error
+// into an INTERNAL error with the given message
+func orInternal(message string, err error) error {
+	var appErr *apperrors.Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return apperrors.Internal(message, err)
+}
+
+// conflictRetries is how many times an operation is attempted while it
+// loses races on versioned records
+const conflictRetries = 3
+
+// retryOnConflict runs fn, running it again while it fails because a
+// record it updates was changed since it was read. fn must read those
+// records afresh, so it is only safe for operations whose outcome does not
+// depend on what the caller saw. Conflicts outlasting the retries are
+// reported as apperrors.ErrConflict.
+func retryOnConflict(fn func() error) error {
+	var err error
+	for i := 0; i < conflictRetries; i++ {
+		if err = fn(); !errors.Is(err, repository.ErrConflict) {
+			return err
+		}
+	}
+	return apperrors.ErrConflict.Wrap(err)
+}
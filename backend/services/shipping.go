@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"errors"
+)
+
+type ShippingService struct {
+	store repository.Store
+}
+
+// ShippingQuote is the cost of shipping a cart with one method
+type ShippingQuote struct {
+	Method models.ShippingMethod
+	Cost   float64
+}
+
+// Create adds a shipping method to the store
+func (s *ShippingService) Create(ctx context.Context, method *models.ShippingMethod) error {
+	if err := s.store.Shipping().Create(ctx, method); err != nil {
+		return apperrors.Internal("failed to create shipping method", err)
+	}
+	return nil
+}
+
+// List returns the store's shipping methods
+func (s *ShippingService) List(ctx context.Context) ([]models.ShippingMethod, error) {
+	methods, err := s.store.Shipping().List(ctx)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch shipping methods", err)
+	}
+	return methods, nil
+}
+
+// Delete removes a shipping method. Orders keep the cost they were charged.
+func (s *ShippingService) Delete(ctx context.Context, id uint) error {
+	if err := s.store.Shipping().Delete(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrShippingMethodNotFound
+		}
+		return apperrors.Internal("failed to delete shipping method", err)
+	}
+	return nil
+}
+
+// Quote returns the cost of shipping the user's open cart, less its digital
+// items, with each of the store's methods, or only with methodID if it is
+// not 0
+func (s *ShippingService) Quote(ctx context.Context, userID, methodID uint) (models.Cart, []ShippingQuote, error) {
+	cart, err := s.store.Carts().OpenCart(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Cart{}, nil, apperrors.ErrCartNotFound
+		}
+		return models.Cart{}, nil, apperrors.Internal("failed to fetch cart", err)
+	}
+
+	var methods []models.ShippingMethod
+	if methodID != 0 {
+		method, err := getShippingMethod(ctx, s.store, methodID)
+		if err != nil {
+			return models.Cart{}, nil, err
+		}
+		methods = []models.ShippingMethod{method}
+	} else if methods, err = s.List(ctx); err != nil {
+		return models.Cart{}, nil, err
+	}
+
+	shipped := Shippable(cart)
+	subtotal, weight := Total(shipped), Weight(shipped)
+	quotes := make([]ShippingQuote, len(methods))
+	for i, method := range methods {
+		quotes[i] = ShippingQuote{Method: method}
+		// Carts of only digital items have nothing to ship
+		if len(shipped.CartItems) > 0 {
+			quotes[i].Cost = method.Cost(subtotal, weight)
+		}
+	}
+	return cart, quotes, nil
+}
+
+// shippingFor returns the method chosen at checkout and its cost for the
+// cart. Choosing none is only allowed while the store has no methods, or
+// when the cart holds only digital items, which are never shipped.
+func shippingFor(ctx context.Context, store repository.Store, methodID uint, cart models.Cart) (*models.ShippingMethod, float64, error) {
+	shipped := Shippable(cart)
+	if len(shipped.CartItems) == 0 {
+		return nil, 0, nil
+	}
+	if methodID == 0 {
+		methods, err := store.Shipping().List(ctx)
+		if err != nil {
+			return nil, 0, apperrors.Internal("failed to fetch shipping methods", err)
+		}
+		if len(methods) > 0 {
+			return nil, 0, apperrors.ErrShippingMethodRequired
+		}
+		return nil, 0, nil
+	}
+
+	method, err := getShippingMethod(ctx, store, methodID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &method, method.Cost(Total(shipped), Weight(shipped)), nil
+}
+
+func getShippingMethod(ctx context.Context, store repository.Store, id uint) (models.ShippingMethod, error) {
+	method, err := store.Shipping().Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.ShippingMethod{}, apperrors.ErrShippingMethodNotFound
+		}
+		return models.ShippingMethod{}, apperrors.Internal("failed to fetch shipping method", err)
+	}
+	return method, nil
+}
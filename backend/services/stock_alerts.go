@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/emails"
+	"ecommerce-backend/events"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/notifications"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/tenant"
+	"errors"
+	"fmt"
+)
+
+// stockAlertBatchSize is how many back-in-stock subscriptions are served
+// per run
+const stockAlertBatchSize = 100
+
+// Subscribe asks for the user to be told when the out-of-stock item is back
+// in stock. Subscribing again to the same item keeps the first subscription.
+func (s *ItemService) Subscribe(ctx context.Context, userID, itemID uint) (models.StockSubscription, error) {
+	item, err := s.Get(ctx, itemID)
+	if err != nil {
+		return models.StockSubscription{}, err
+	}
+	if !item.IsActive {
+		return models.StockSubscription{}, apperrors.ErrItemNotFound
+	}
+	if item.Stock == nil || *item.Stock > 0 || item.Backorder != "" {
+		return models.StockSubscription{}, apperrors.ErrItemInStock
+	}
+
+	sub := models.StockSubscription{UserID: userID, ItemID: itemID}
+	if err := s.store.StockSubscriptions().Subscribe(ctx, &sub); err != nil {
+		return models.StockSubscription{}, apperrors.Internal("failed to subscribe", err)
+	}
+	sub.Item = item
+	return sub, nil
+}
+
+// Unsubscribe cancels the user's subscription to the item
+func (s *ItemService) Unsubscribe(ctx context.Context, userID, itemID uint) error {
+	if err := s.store.StockSubscriptions().Unsubscribe(ctx, userID, itemID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrNotSubscribed
+		}
+		return apperrors.Internal("failed to unsubscribe", err)
+	}
+	return nil
+}
+
+// Subscriptions returns the user's back-in-stock subscriptions
+func (s *ItemService) Subscriptions(ctx context.Context, userID uint) ([]models.StockSubscription, error) {
+	subs, err := s.store.StockSubscriptions().ListByUser(ctx, userID)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch subscriptions", err)
+	}
+	return subs, nil
+}
+
+// NotifyRestocked tells the users subscribed to items that can be bought
+// again, across all stores, by email when they have one and by push
+// notification to their devices, and deletes their subscriptions, along
+// with those to deleted items. Users whose account is deactivated are not
+// told.
+func (s *ItemService) NotifyRestocked(ctx context.Context) error {
+	if _, err := s.store.StockSubscriptions().DeleteOrphaned(tenant.WithoutStore(ctx)); err != nil {
+		return err
+	}
+	subs, err := s.store.StockSubscriptions().Restocked(tenant.WithoutStore(ctx), stockAlertBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.notifyRestocked(tenant.WithStore(ctx, sub.StoreID), sub); err != nil {
+			// The subscriptions after it are served on the next run
+			return fmt.Errorf("failed to notify user %d of item %d: %w", sub.UserID, sub.ItemID, err)
+		}
+	}
+
+	if len(subs) > 0 {
+		logging.FromContext(ctx).Info("back-in-stock alerts sent", "count", len(subs))
+	}
+	return nil
+}
+
+// notifyRestocked deletes the subscription, with the event its push
+// notifications are relayed from, and emails the user. The subscription
+// stays deleted if the email fails, so no one is told twice.
+func (s *ItemService) notifyRestocked(ctx context.Context, sub models.StockSubscription) error {
+	active := sub.User.DeactivatedAt == nil
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.StockSubscriptions().Delete(ctx, sub.ID); err != nil {
+			return err
+		}
+		if !active {
+			return nil
+		}
+		return publish(ctx, tx, events.BackInStock, sub.StoreID, sub.UserID, events.Restock{
+			ItemID: sub.ItemID,
+			Name:   sub.Item.Name,
+			UserID: sub.UserID,
+		})
+	})
+	if err != nil || !active || sub.User.Email == "" {
+		return err
+	}
+
+	msg, err := emails.Render(emails.BackInStock, emails.BackInStockData{
+		Username: sub.User.Username,
+		ItemName: sub.Item.Name,
+		Price:    sub.Item.Price,
+	})
+	if err != nil {
+		return err
+	}
+	msg.To = []string{sub.User.Email}
+	return notifications.Send(ctx, msg)
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/repository"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Outcomes of the rows of an inventory sync
+const (
+	SyncCreated   = "created"
+	SyncUpdated   = "updated"
+	SyncUnchanged = "unchanged"
+	SyncConflict  = "conflict"
+	SyncFailed    = "failed"
+)
+
+// SyncChange is one row of an inventory sync pushed by an external system,
+// keyed by SKU. Price and Stock are left as they are when nil; Name is
+// only used to create items. SeenAt, if set, is the item's update time the
+// system last synced: the row conflicts if the item changed since.
+type SyncChange struct {
+	SKU    string
+	Name   string
+	Price  *float64
+	Stock  *int
+	SeenAt *time.Time
+}
+
+// SyncResult is the outcome of one row of an inventory sync: the item as
+// it is after the row, and the error the row failed or conflicted with
+type SyncResult struct {
+	SKU    string
+	Status string
+	Item   models.Item
+	Err    *apperrors.Error
+}
+
+// Sync applies the changes on behalf of actor, creating the items of SKUs
+// the store does not know, which needs a name and a price. Unlike
+// BulkUpdate each row is applied on its own, as a system pushing its whole
+// inventory should not be held up by one bad row; the result of each says
+// what happened to it.
+func (s *ItemService) Sync(ctx context.Context, actor models.User, changes []SyncChange) ([]SyncResult, error) {
+	results := make([]SyncResult, len(changes))
+	counts := map[string]int{}
+	for i, change := range changes {
+		result, err := s.syncItem(ctx, actor, change)
+		if err != nil {
+			var appErr *apperrors.Error
+			if !errors.As(err, &appErr) || appErr.Status >= http.StatusInternalServerError {
+				return nil, orInternal("failed to sync item", err)
+			}
+			result.Err = appErr
+		}
+		results[i] = result
+		counts[result.Status]++
+	}
+	logging.FromContext(ctx).Info("inventory synced",
+		"rows", len(changes),
+		"created", counts[SyncCreated],
+		"updated", counts[SyncUpdated],
+		"conflicts", counts[SyncConflict],
+		"failed", counts[SyncFailed],
+	)
+	return results, nil
+}
+
+// syncItem applies one row of an inventory sync in its own transaction
+func (s *ItemService) syncItem(ctx context.Context, actor models.User, change SyncChange) (SyncResult, error) {
+	result := SyncResult{SKU: change.SKU, Status: SyncFailed}
+	if change.Price == nil && change.Stock == nil {
+		return result, apperrors.Validation("give a price or a stock")
+	}
+
+	var id uint
+	err := retryOnConflict(func() error {
+		return s.store.Transaction(ctx, func(tx repository.Store) error {
+			item, err := tx.Items().GetBySKU(ctx, change.SKU)
+			if errors.Is(err, repository.ErrNotFound) {
+				result.Status = SyncCreated
+				item, err = createSyncedItem(ctx, tx, actor, change)
+				id = item.ID
+				return err
+			}
+			if err != nil {
+				return apperrors.Internal("failed to fetch item", err)
+			}
+			id = item.ID
+
+			if change.SeenAt != nil && changedSince(item.UpdatedAt, *change.SeenAt) {
+				result.Status = SyncConflict
+				return apperrors.ErrSyncConflict.WithDetails(map[string]interface{}{
+					"sku":        change.SKU,
+					"updated_at": item.UpdatedAt,
+				})
+			}
+			changed, err := changeItem(ctx, tx, actor, ItemChange{ItemID: item.ID, Price: change.Price, Stock: change.Stock})
+			if err != nil {
+				return err
+			}
+			result.Status = SyncUpdated
+			if changed.Price == item.Price && sameStock(changed.Stock, item.Stock) {
+				result.Status = SyncUnchanged
+			}
+			return nil
+		})
+	})
+	if id != 0 {
+		// The item is read afresh for its update time, and to show a
+		// conflicting row the item as it now is
+		item, getErr := s.store.Items().Get(ctx, id)
+		if getErr != nil && !errors.Is(getErr, repository.ErrNotFound) {
+			return result, apperrors.Internal("failed to fetch item", getErr)
+		}
+		result.Item = item
+		if err == nil && result.Status != SyncUnchanged {
+			index(ctx, item)
+		}
+	}
+	if err != nil && result.Status != SyncConflict {
+		result.Status = SyncFailed
+	}
+	return result, err
+}
+
+// createSyncedItem adds the item of an inventory sync row for a SKU the
+// store does not know
+func createSyncedItem(ctx context.Context, tx repository.Store, actor models.User, change SyncChange) (models.Item, error) {
+	if change.Name == "" || change.Price == nil {
+		return models.Item{}, apperrors.Validation("name and price are required to create an item")
+	}
+	if *change.Price <= 0 {
+		return models.Item{}, apperrors.Validation("price must be greater than 0")
+	}
+	sku := change.SKU
+	item := models.Item{
+		SKU:      &sku,
+		Name:     change.Name,
+		Price:    *change.Price,
+		Stock:    change.Stock,
+		IsActive: true,
+	}
+	if err := createItem(ctx, tx, actor, &item); err != nil {
+		return models.Item{}, err
+	}
+	return item, nil
+}
+
+// Changes returns up to limit items with a SKU changed after the cursor,
+// oldest change first, with the cursor to pull the next changes from and
+// whether there are more to pull now. With no changes the cursor is
+// returned as it is, to poll again later.
+func (s *ItemService) Changes(ctx context.Context, after pagination.ChangeCursor, limit int) ([]models.Item, pagination.ChangeCursor, bool, error) {
+	items, err := s.store.Items().Changed(ctx, after, limit+1)
+	if err != nil {
+		return nil, after, false, apperrors.Internal("failed to fetch changed items", err)
+	}
+	more := len(items) > limit
+	if more {
+		items = items[:limit]
+	}
+	next := after
+	if len(items) > 0 {
+		last := items[len(items)-1]
+		next = pagination.ChangeCursor{At: last.UpdatedAt, ID: last.ID}
+	}
+	return items, next, more, nil
+}
+
+// changedSince reports whether an item updated at updatedAt changed after
+// seen. Times are compared to the microsecond, as some databases keep no
+// more.
+func changedSince(updatedAt, seen time.Time) bool {
+	return updatedAt.Truncate(time.Microsecond).After(seen.Truncate(time.Microsecond))
+}
+
+// sameStock reports whether two stocks are equal, nil being untracked
+func sameStock(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
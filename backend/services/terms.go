@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"time"
+)
+
+// maxUserAgent bounds the user agent recorded with terms acceptances
+const maxUserAgent = 255
+
+// TermsSource is the request a user accepted the terms with
+type TermsSource struct {
+	IP        string
+	UserAgent string
+}
+
+// currentTerms returns the current version of each tracked document, by
+// document
+func (s *UserService) currentTerms() map[string]string {
+	current := map[string]string{}
+	if s.cfg.TermsVersion != "" {
+		current[models.DocumentTerms] = s.cfg.TermsVersion
+	}
+	if s.cfg.PrivacyVersion != "" {
+		current[models.DocumentPrivacy] = s.cfg.PrivacyVersion
+	}
+	return current
+}
+
+// pendingTerms returns the current versions of the documents the user has
+// not accepted, by document
+func (s *UserService) pendingTerms(ctx context.Context, userID uint) (map[string]string, error) {
+	pending := s.currentTerms()
+	if len(pending) == 0 {
+		return pending, nil
+	}
+	accepted, err := s.store.Terms().ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, acceptance := range accepted {
+		if pending[acceptance.Document] == acceptance.Version {
+			delete(pending, acceptance.Document)
+		}
+	}
+	return pending, nil
+}
+
+// acceptTerms records that the user accepted the given versions
+func acceptTerms(ctx context.Context, store repository.Store, userID uint, versions map[string]string, source TermsSource) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	userAgent := source.UserAgent
+	if len(userAgent) > maxUserAgent {
+		userAgent = userAgent[:maxUserAgent]
+	}
+	now := time.Now()
+	acceptances := make([]models.TermsAcceptance, 0, len(versions))
+	for _, document := range []string{models.DocumentTerms, models.DocumentPrivacy} {
+		if version, ok := versions[document]; ok {
+			acceptances = append(acceptances, models.TermsAcceptance{
+				UserID:     userID,
+				Document:   document,
+				Version:    version,
+				AcceptedAt: now,
+				IP:         source.IP,
+				UserAgent:  userAgent,
+			})
+		}
+	}
+	return store.Terms().Accept(ctx, acceptances)
+}
+
+// termsNotAccepted refuses a user who has yet to accept the given versions
+func termsNotAccepted(pending map[string]string) error {
+	return apperrors.ErrTermsNotAccepted.WithDetails(pending)
+}
+
+// AcceptTerms records that the user accepted the current versions of the
+// documents they had not accepted yet
+func (s *UserService) AcceptTerms(ctx context.Context, userID uint, source TermsSource) error {
+	pending, err := s.pendingTerms(ctx, userID)
+	if err != nil {
+		return apperrors.Internal("failed to accept terms", err)
+	}
+	if err := acceptTerms(ctx, s.store, userID, pending, source); err != nil {
+		return apperrors.Internal("failed to accept terms", err)
+	}
+	return nil
+}
+
+// CheckTerms is called when the user logs in. If re-acceptance is required
+// and a version was bumped since they last accepted, it records their
+// acceptance when accept is set and refuses them with TERMS_NOT_ACCEPTED
+// otherwise, whose details give the versions to accept by document.
+func (s *UserService) CheckTerms(ctx context.Context, userID uint, accept bool, source TermsSource) error {
+	if !s.cfg.TermsReacceptance {
+		return nil
+	}
+	pending, err := s.pendingTerms(ctx, userID)
+	if err != nil {
+		return apperrors.Internal("failed to check terms", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	if !accept {
+		return termsNotAccepted(pending)
+	}
+	if err := acceptTerms(ctx, s.store, userID, pending, source); err != nil {
+		return apperrors.Internal("failed to accept terms", err)
+	}
+	return nil
+}
+
+// TermsHistory returns the terms acceptances of the user, newest first
+func (s *UserService) TermsHistory(ctx context.Context, userID uint) ([]models.TermsAcceptance, error) {
+	acceptances, err := s.store.Terms().ListByUser(ctx, userID)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch terms acceptances", err)
+	}
+	return acceptances, nil
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/carriers"
+	"ecommerce-backend/events"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/tenant"
+	"errors"
+	"strings"
+	"time"
+)
+
+// pollBatchSize is how many shipments are tracked per poll
+const pollBatchSize = 100
+
+type TrackingService struct {
+	store repository.Store
+}
+
+// AddShipment records that the order was handed to the carrier under the
+// tracking number. Its tracking is fetched by the next poll.
+func (s *TrackingService) AddShipment(ctx context.Context, orderID uint, carrier, trackingNumber string) (models.Shipment, error) {
+	if _, ok := carriers.Get(carrier); !ok {
+		return models.Shipment{}, apperrors.ErrUnknownCarrier.
+			WithMessage("unknown carrier " + carrier + ", expected one of " + strings.Join(carriers.Names(), ", "))
+	}
+
+	order, err := s.store.Orders().Get(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Shipment{}, apperrors.ErrOrderNotFound
+		}
+		return models.Shipment{}, apperrors.Internal("failed to fetch order", err)
+	}
+
+	// Webhooks find shipments by tracking number alone, so it must be
+	// unique across stores
+	_, err = s.store.Shipments().FindByTracking(tenant.WithoutStore(ctx), carrier, trackingNumber)
+	if err == nil {
+		return models.Shipment{}, apperrors.ErrShipmentExists
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return models.Shipment{}, apperrors.Internal("failed to check tracking number", err)
+	}
+
+	shipment := models.Shipment{
+		OrderID:        order.ID,
+		Carrier:        carrier,
+		TrackingNumber: trackingNumber,
+		Status:         models.TrackingPending,
+	}
+	if err := s.store.Shipments().Create(ctx, &shipment); err != nil {
+		return models.Shipment{}, apperrors.Internal("failed to create shipment", err)
+	}
+
+	logging.FromContext(ctx).Info("shipment created", "order_id", order.ID, "shipment_id", shipment.ID, "carrier", carrier)
+	return shipment, nil
+}
+
+// Poll fetches the tracking of the undelivered shipments of every store,
+// least recently checked first. Shipments whose carrier fails are logged
+// and retried on the next poll.
+func (s *TrackingService) Poll(ctx context.Context) error {
+	shipments, err := s.store.Shipments().Undelivered(ctx, pollBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, shipment := range shipments {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		logger := logging.FromContext(ctx).With("shipment_id", shipment.ID, "carrier", shipment.Carrier)
+		provider, ok := carriers.Get(shipment.Carrier)
+		if !ok {
+			logger.Warn("no provider for carrier, skipping shipment")
+			continue
+		}
+		reported, err := provider.Track(ctx, shipment.TrackingNumber)
+		if err != nil {
+			logger.Warn("failed to fetch tracking", "error", err)
+			continue
+		}
+		if err := s.record(ctx, shipment, reported); err != nil {
+			logger.Error("failed to record tracking", "error", err)
+		}
+	}
+	return nil
+}
+
+// Receive records the tracking events pushed by a carrier for one of its
+// shipments, which may belong to any store
+func (s *TrackingService) Receive(ctx context.Context, carrier, trackingNumber string, reported []carriers.Event) error {
+	shipment, err := s.store.Shipments().FindByTracking(tenant.WithoutStore(ctx), carrier, trackingNumber)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return apperrors.ErrNotFound.WithMessage("shipment not found")
+		}
+		return apperrors.Internal("failed to fetch shipment", err)
+	}
+
+	if err := s.record(ctx, shipment, reported); err != nil {
+		return apperrors.Internal("failed to record tracking", err)
+	}
+	return nil
+}
+
+// record adds the reported events the shipment does not have yet, moves it
+// to the status of its latest event and notifies the order's owner of a
+// status change
+func (s *TrackingService) record(ctx context.Context, shipment models.Shipment, reported []carriers.Event) error {
+	ctx = tenant.WithStore(ctx, shipment.StoreID)
+
+	seen := map[string]bool{}
+	latest := time.Time{}
+	for _, e := range shipment.Events {
+		seen[eventKey(e.Status, e.OccurredAt)] = true
+		if !e.OccurredAt.Before(latest) {
+			latest = e.OccurredAt
+		}
+	}
+
+	var added []models.TrackingEvent
+	previous := shipment.Status
+	for _, e := range reported {
+		if e.Status == "" || e.OccurredAt.IsZero() || seen[eventKey(e.Status, e.OccurredAt)] {
+			continue
+		}
+		seen[eventKey(e.Status, e.OccurredAt)] = true
+		added = append(added, models.TrackingEvent{
+			Status:      e.Status,
+			Description: e.Description,
+			Location:    e.Location,
+			OccurredAt:  e.OccurredAt.UTC(),
+		})
+		if !e.OccurredAt.Before(latest) {
+			latest = e.OccurredAt
+			shipment.Status = e.Status
+		}
+	}
+
+	now := time.Now()
+	shipment.CheckedAt = &now
+	if shipment.Status == models.TrackingDelivered && shipment.DeliveredAt == nil {
+		deliveredAt := latest.UTC()
+		shipment.DeliveredAt = &deliveredAt
+	}
+	changed := shipment.Status != previous
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.Shipments().Record(ctx, &shipment, added); err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+
+		order, err := tx.Orders().Get(ctx, shipment.OrderID)
+		if err != nil {
+			return err
+		}
+		return publish(ctx, tx, events.ShipmentUpdated, shipment.StoreID, order.UserID, events.Shipment{
+			OrderID:        order.ID,
+			ShipmentID:     shipment.ID,
+			Carrier:        shipment.Carrier,
+			TrackingNumber: shipment.TrackingNumber,
+			Status:         shipment.Status,
+			PreviousStatus: previous,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		logging.FromContext(ctx).Info("shipment status changed", "shipment_id", shipment.ID, "from", previous, "to", shipment.Status)
+	}
+	return nil
+}
+
+// eventKey identifies a tracking event, so events reported again by later
+// polls and webhooks are not recorded twice
+func eventKey(status string, occurredAt time.Time) string {
+	return status + "@" + occurredAt.UTC().Format(time.RFC3339Nano)
+}
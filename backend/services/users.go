@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/config"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/passwords"
+	"ecommerce-backend/repository"
+	"ecommerce-backend/storage"
+	"ecommerce-backend/tenant"
+	"ecommerce-backend/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// anonymizedPrefix starts the usernames given to anonymized accounts,
+	// so it is reserved
+	anonymizedPrefix = "deleted-"
+	// anonymizeBatchSize bounds the accounts anonymized per run
+	anonymizeBatchSize = 100
+)
+
+type UserService struct {
+	store repository.Store
+	cfg   config.AccountConfig
+	// impersonationTTL is the lifetime of impersonation tokens
+	impersonationTTL time.Duration
+}
+
+// Register creates a customer account. The email is optional. terms is
+// the request the user accepted the current terms with, or nil if they did
+// not, which is refused while any document is tracked.
+func (s *UserService) Register(ctx context.Context, username, password, email string, terms *TermsSource) (models.User, error) {
+	if strings.HasPrefix(username, anonymizedPrefix) {
+		return models.User{}, apperrors.Validation("usernames starting with " + anonymizedPrefix + " are reserved")
+	}
+	current := s.currentTerms()
+	if len(current) > 0 && terms == nil {
+		return models.User{}, termsNotAccepted(current)
+	}
+
+	exists, err := s.store.Users().UsernameExists(ctx, username)
+	if err != nil {
+		return models.User{}, apperrors.Internal("failed to create user", err)
+	}
+	if exists {
+		return models.User{}, apperrors.ErrUsernameTaken
+	}
+	if err := passwords.Check(ctx, password, username, email); err != nil {
+		return models.User{}, err
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return models.User{}, apperrors.Internal("failed to create user", err)
+	}
+
+	user := models.User{
+		Username:     username,
+		PasswordHash: hashedPassword,
+		Role:         models.RoleCustomer,
+		Email:        email,
+	}
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.Users().Create(ctx, &user); err != nil {
+			return err
+		}
+		if terms == nil {
+			return nil
+		}
+		return acceptTerms(ctx, tx, user.ID, current, *terms)
+	})
+	if err != nil {
+		return models.User{}, apperrors.Internal("failed to create user", err)
+	}
+	return user, nil
+}
+
+// Authenticate returns the user with the given credentials. Unknown users
+// and wrong passwords are reported alike. Deactivated accounts are refused
+// with ACCOUNT_DEACTIVATED while they can be reactivated.
+func (s *UserService) Authenticate(ctx context.Context, username, password string) (models.User, error) {
+	user, err := s.credentials(ctx, username, password)
+	if err != nil {
+		return models.User{}, err
+	}
+	if user.DeactivatedAt != nil {
+		return models.User{}, apperrors.ErrAccountDeactivated.
+			WithMessage("account is deactivated; reactivate it to log in").
+			WithDetails(map[string]time.Time{"reactivate_before": s.reactivateBefore(user)})
+	}
+	return user, nil
+}
+
+// Deactivate deactivates the user's account, once they confirm their
+// password, and revokes all their tokens. The account can be reactivated
+// until the returned time, after which it is anonymized.
+func (s *UserService) Deactivate(ctx context.Context, userID uint, password string) (time.Time, error) {
+	user, err := s.Get(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := utils.CheckPassword(password, user.PasswordHash); err != nil {
+		return time.Time{}, apperrors.ErrInvalidCredentials
+	}
+	if user.Role == models.RoleAdmin {
+		return time.Time{}, apperrors.ErrForbidden.WithMessage("admin accounts cannot be deactivated")
+	}
+
+	// Tokens are revoked first so a failure cannot leave a deactivated
+	// account with live tokens
+	if err := utils.RevokeUserTokens(ctx, userID); err != nil {
+		return time.Time{}, apperrors.Internal("failed to revoke tokens", err)
+	}
+	now := time.Now()
+	if err := s.store.Users().SetDeactivated(ctx, userID, &now); err != nil {
+		return time.Time{}, apperrors.Internal("failed to deactivate account", err)
+	}
+	user.DeactivatedAt = &now
+	return s.reactivateBefore(user), nil
+}
+
+// Reactivate reactivates a deactivated account with the given credentials
+// and returns its user; active accounts are returned as they are. The
+// tokens issued before deactivation stay revoked.
+func (s *UserService) Reactivate(ctx context.Context, username, password string) (models.User, error) {
+	user, err := s.credentials(ctx, username, password)
+	if err != nil {
+		return models.User{}, err
+	}
+	if user.DeactivatedAt != nil {
+		if err := s.store.Users().SetDeactivated(ctx, user.ID, nil); err != nil {
+			return models.User{}, apperrors.Internal("failed to reactivate account", err)
+		}
+		user.DeactivatedAt = nil
+	}
+	return user, nil
+}
+
+// Impersonate issues a token letting the admin act as the user, and
+// returns it with its expiry. Admins and deactivated accounts cannot be
+// impersonated.
+func (s *UserService) Impersonate(ctx context.Context, admin models.User, userID uint) (string, time.Time, error) {
+	user, err := s.Get(ctx, userID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if user.Role == models.RoleAdmin || user.DeactivatedAt != nil {
+		return "", time.Time{}, apperrors.ErrImpersonationRefused
+	}
+
+	actor := utils.Actor{UserID: admin.ID, Username: admin.Username}
+	token, expiresAt, err := utils.GenerateImpersonationToken(user.ID, user.Username, user.Role,
+		user.VendorIDOrZero(), user.StoreID, actor, s.impersonationTTL)
+	if err != nil {
+		return "", time.Time{}, apperrors.Internal("failed to generate token", err)
+	}
+	logging.FromContext(ctx).Info("user impersonated", "user_id", user.ID, "admin_id", admin.ID, "expires_at", expiresAt)
+	return token, expiresAt, nil
+}
+
+// AnonymizeExpired anonymizes accounts, across all stores, deactivated for
+// longer than the reactivation window, deleting their avatars. Their orders
+// are kept, under a username made from the user's ID.
+func (s *UserService) AnonymizeExpired(ctx context.Context) error {
+	now := time.Now()
+	users, err := s.store.Users().Deactivated(tenant.WithoutStore(ctx), now.Add(-s.cfg.ReactivationWindow), anonymizeBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		username := fmt.Sprintf("%s%d", anonymizedPrefix, user.ID)
+		if user.AvatarURL != "" {
+			if err := storage.Get().Delete(ctx, avatarKey(user.ID)); err != nil {
+				return fmt.Errorf("failed to delete avatar of user %d: %w", user.ID, err)
+			}
+		}
+		if err := s.store.Users().Anonymize(tenant.WithStore(ctx, user.StoreID), user.ID, username, now); err != nil {
+			return fmt.Errorf("failed to anonymize user %d: %w", user.ID, err)
+		}
+	}
+
+	if len(users) > 0 {
+		logging.FromContext(ctx).Info("deactivated accounts anonymized", "count", len(users))
+	}
+	return nil
+}
+
+// credentials returns the user with the given credentials, like
+// Authenticate, but also when their account is deactivated. Accounts past
+// the reactivation window are reported as unknown.
+func (s *UserService) credentials(ctx context.Context, username, password string) (models.User, error) {
+	user, err := s.store.Users().FindByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.User{}, apperrors.ErrInvalidCredentials
+		}
+		return models.User{}, apperrors.Internal("failed to log in", err)
+	}
+
+	if err := utils.CheckPassword(password, user.PasswordHash); err != nil {
+		return models.User{}, apperrors.ErrInvalidCredentials
+	}
+	if user.DeactivatedAt != nil && !time.Now().Before(s.reactivateBefore(user)) {
+		return models.User{}, apperrors.ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// reactivateBefore is when the deactivated user's reactivation window ends
+func (s *UserService) reactivateBefore(user models.User) time.Time {
+	return user.DeactivatedAt.Add(s.cfg.ReactivationWindow)
+}
+
+// Get returns the user with the given ID
+func (s *UserService) Get(ctx context.Context, userID uint) (models.User, error) {
+	user, err := s.store.Users().Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.User{}, apperrors.ErrUserNotFound
+		}
+		return models.User{}, apperrors.Internal("failed to fetch user", err)
+	}
+	return user, nil
+}
+
+// SetEmail changes the user's email, an empty email removing it, and
+// returns the updated user
+func (s *UserService) SetEmail(ctx context.Context, userID uint, email string) (models.User, error) {
+	if err := s.store.Users().SetEmail(ctx, userID, email); err != nil {
+		return models.User{}, apperrors.Internal("failed to update email", err)
+	}
+	return s.Get(ctx, userID)
+}
+
+// Each calls fn for every active user on the page and returns the next cursor
+func (s *UserService) Each(ctx context.Context, page pagination.Page, fn func(*models.User) error) (string, error) {
+	return s.store.Users().Each(ctx, page, fn)
+}
+
+// Locate returns the position of the page among the active users
+func (s *UserService) Locate(ctx context.Context, page pagination.Page) (pagination.Position, error) {
+	pos, err := s.store.Users().Locate(ctx, page)
+	if err != nil {
+		return pagination.Position{}, apperrors.Internal("failed to count users", err)
+	}
+	return pos, nil
+}
+
+// EachSummary calls fn for every active user on the page with their order
+// count and lifetime value, and returns the next cursor
+func (s *UserService) EachSummary(ctx context.Context, page pagination.Page, fn func(*repository.UserSummary) error) (string, error) {
+	return s.store.Users().EachSummary(ctx, page, fn)
+}
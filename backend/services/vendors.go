@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/repository"
+	"errors"
+)
+
+type VendorService struct {
+	store repository.Store
+}
+
+// Create adds a marketplace vendor
+func (s *VendorService) Create(ctx context.Context, name string) (models.Vendor, error) {
+	exists, err := s.store.Vendors().NameExists(ctx, name)
+	if err != nil {
+		return models.Vendor{}, apperrors.Internal("failed to create vendor", err)
+	}
+	if exists {
+		return models.Vendor{}, apperrors.ErrVendorNameTaken
+	}
+
+	vendor := models.Vendor{Name: name}
+	if err := s.store.Vendors().Create(ctx, &vendor); err != nil {
+		return models.Vendor{}, apperrors.Internal("failed to create vendor", err)
+	}
+	return vendor, nil
+}
+
+// Get returns a vendor, or ErrVendorNotFound
+func (s *VendorService) Get(ctx context.Context, id uint) (models.Vendor, error) {
+	vendor, err := s.store.Vendors().Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Vendor{}, apperrors.ErrVendorNotFound
+		}
+		return models.Vendor{}, apperrors.Internal("failed to fetch vendor", err)
+	}
+	return vendor, nil
+}
+
+// List returns all vendors by name
+func (s *VendorService) List(ctx context.Context) ([]models.Vendor, error) {
+	vendors, err := s.store.Vendors().List(ctx)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch vendors", err)
+	}
+	return vendors, nil
+}
+
+// AddAccount turns an existing user into an account of the vendor. The
+// user's current tokens keep their old role until they log in again.
+func (s *VendorService) AddAccount(ctx context.Context, vendorID uint, username string) (models.User, error) {
+	if _, err := s.Get(ctx, vendorID); err != nil {
+		return models.User{}, err
+	}
+
+	user, err := s.store.Users().FindByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.User{}, apperrors.ErrUserNotFound
+		}
+		return models.User{}, apperrors.Internal("failed to fetch user", err)
+	}
+	if user.Role == models.RoleAdmin {
+		return models.User{}, apperrors.Validation("admins cannot be vendor accounts")
+	}
+
+	if err := s.store.Users().SetVendor(ctx, user.ID, &vendorID, models.RoleVendor); err != nil {
+		return models.User{}, apperrors.Internal("failed to update user", err)
+	}
+	user.VendorID, user.Role = &vendorID, models.RoleVendor
+	return user, nil
+}
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pagination"
+	"ecommerce-backend/repository"
+	"errors"
+)
+
+type WarehouseService struct {
+	store repository.Store
+}
+
+// Create adds a warehouse to the store
+func (s *WarehouseService) Create(ctx context.Context, warehouse *models.Warehouse) error {
+	if err := s.store.Warehouses().Create(ctx, warehouse); err != nil {
+		return apperrors.Internal("failed to create warehouse", err)
+	}
+	return nil
+}
+
+// List returns the store's warehouses in allocation order
+func (s *WarehouseService) List(ctx context.Context) ([]models.Warehouse, error) {
+	warehouses, err := s.store.Warehouses().List(ctx)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch warehouses", err)
+	}
+	return warehouses, nil
+}
+
+// Stock returns the stock held at a warehouse, with its items
+func (s *WarehouseService) Stock(ctx context.Context, warehouseID uint) ([]models.WarehouseStock, error) {
+	if _, err := s.get(ctx, s.store, warehouseID); err != nil {
+		return nil, err
+	}
+	stock, err := s.store.Warehouses().Stock(ctx, warehouseID)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch warehouse stock", err)
+	}
+	return stock, nil
+}
+
+// Availability returns the stock of an item in each warehouse holding
+// any, in allocation order
+func (s *WarehouseService) Availability(ctx context.Context, itemID uint) ([]models.WarehouseStock, error) {
+	stock, err := s.store.Warehouses().StockOf(ctx, []uint{itemID})
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch warehouse stock", err)
+	}
+	return stock, nil
+}
+
+// SetStock sets the units of an item held at a warehouse on behalf of
+// actor and returns the item, whose stock becomes its total across
+// warehouses
+func (s *WarehouseService) SetStock(ctx context.Context, actor models.User, warehouseID, itemID uint, quantity int) (models.Item, error) {
+	var item models.Item
+	err := retryOnConflict(func() error {
+		return s.store.Transaction(ctx, func(tx repository.Store) error {
+			if _, err := s.get(ctx, tx, warehouseID); err != nil {
+				return err
+			}
+			var err error
+			if item, err = tx.Items().Get(ctx, itemID); err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					return apperrors.ErrItemNotFound
+				}
+				return err
+			}
+			if err := tx.Warehouses().SetStock(ctx, warehouseID, itemID, quantity); err != nil {
+				return err
+			}
+			before := item.Stock
+			if err := syncStock(ctx, tx, &item); err != nil {
+				return err
+			}
+			return recordMovements(ctx, tx, models.InventoryMovement{
+				ItemID:      itemID,
+				Delta:       stockDelta(before, item.Stock),
+				Reason:      models.MovementAdjustment,
+				ActorID:     &actor.ID,
+				WarehouseID: &warehouseID,
+			})
+		})
+	})
+	if err != nil {
+		return models.Item{}, orInternal("failed to set warehouse stock", err)
+	}
+	return item, nil
+}
+
+// Transfer moves units of an item from one warehouse to another on behalf
+// of actor and records the transfer. The item's total stock is unchanged.
+func (s *WarehouseService) Transfer(ctx context.Context, actor models.User, transfer *models.StockTransfer) error {
+	if transfer.FromWarehouseID == transfer.ToWarehouseID {
+		return apperrors.Validation("cannot transfer stock to the warehouse it is in")
+	}
+
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		for _, id := range []uint{transfer.FromWarehouseID, transfer.ToWarehouseID} {
+			if _, err := s.get(ctx, tx, id); err != nil {
+				return err
+			}
+		}
+		item, err := tx.Items().Get(ctx, transfer.ItemID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return apperrors.ErrItemNotFound
+			}
+			return err
+		}
+
+		ok, err := tx.Warehouses().TakeStock(ctx, transfer.FromWarehouseID, transfer.ItemID, transfer.Quantity)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return apperrors.ErrInsufficientStock.
+				WithMessage("not enough stock of " + item.Name + " in the warehouse").
+				WithDetails(map[string]uint{"item_id": item.ID, "warehouse_id": transfer.FromWarehouseID})
+		}
+		if err := tx.Warehouses().AddStock(ctx, transfer.ToWarehouseID, transfer.ItemID, transfer.Quantity); err != nil {
+			return err
+		}
+
+		transfer.UserID = actor.ID
+		if err := tx.Warehouses().CreateTransfer(ctx, transfer); err != nil {
+			return err
+		}
+		return recordMovements(ctx, tx,
+			models.InventoryMovement{
+				ItemID:      transfer.ItemID,
+				Delta:       -transfer.Quantity,
+				Reason:      models.MovementTransfer,
+				ActorID:     &actor.ID,
+				WarehouseID: &transfer.FromWarehouseID,
+			},
+			models.InventoryMovement{
+				ItemID:      transfer.ItemID,
+				Delta:       transfer.Quantity,
+				Reason:      models.MovementTransfer,
+				ActorID:     &actor.ID,
+				WarehouseID: &transfer.ToWarehouseID,
+			})
+	})
+	if err != nil {
+		return orInternal("failed to transfer stock", err)
+	}
+	return nil
+}
+
+// Transfers returns the stock transfers on the page and the next cursor
+func (s *WarehouseService) Transfers(ctx context.Context, page pagination.Page) ([]models.StockTransfer, string, error) {
+	transfers, next, err := s.store.Warehouses().ListTransfers(ctx, page)
+	if err != nil {
+		return nil, "", apperrors.Internal("failed to fetch stock transfers", err)
+	}
+	return transfers, next, nil
+}
+
+func (s *WarehouseService) get(ctx context.Context, store repository.Store, id uint) (models.Warehouse, error) {
+	warehouse, err := store.Warehouses().Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return models.Warehouse{}, apperrors.ErrWarehouseNotFound
+		}
+		return models.Warehouse{}, apperrors.Internal("failed to fetch warehouse", err)
+	}
+	return warehouse, nil
+}
+
+// syncStock sets the item's stock to its total across warehouses. It fails
+// with repository.ErrConflict if the item changed since it was read.
+func syncStock(ctx context.Context, tx repository.Store, item *models.Item) error {
+	stock, err := tx.Warehouses().StockOf(ctx, []uint{item.ID})
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, s := range stock {
+		total += s.Quantity
+	}
+	if err := tx.Items().UpdateInventory(ctx, item.ID, item.Version, &total, item.LowStockThreshold); err != nil {
+		return err
+	}
+	item.Stock = &total
+	item.Version++
+	return nil
+}
+
+// allocate takes the cart's items held in warehouses from the warehouses.
+// The first warehouse, by priority, holding the whole cart ships all of it,
+// so the order leaves in one parcel; otherwise each item is taken from the
+// warehouses in priority order. Items held in no warehouse are left out.
+// The items' total stock must already be reserved.
+func allocate(ctx context.Context, tx repository.Store, cart models.Cart) ([]models.OrderAllocation, error) {
+	ids := make([]uint, len(cart.CartItems))
+	for i, ci := range cart.CartItems {
+		ids[i] = ci.ItemID
+	}
+	stock, err := tx.Warehouses().StockOf(ctx, ids)
+	if err != nil {
+		return nil, apperrors.Internal("failed to fetch warehouse stock", err)
+	}
+	if len(stock) == 0 {
+		return nil, nil
+	}
+
+	// held maps warehouses, in allocation order, to the units of each item
+	// they hold
+	var warehouses []uint
+	held := map[uint]map[uint]int{}
+	stocked := map[uint]bool{}
+	for _, s := range stock {
+		if held[s.WarehouseID] == nil {
+			warehouses = append(warehouses, s.WarehouseID)
+			held[s.WarehouseID] = map[uint]int{}
+		}
+		held[s.WarehouseID][s.ItemID] = s.Quantity
+		stocked[s.ItemID] = true
+	}
+	var lines []models.CartItem
+	for _, ci := range cart.CartItems {
+		if stocked[ci.ItemID] {
+			lines = append(lines, ci)
+		}
+	}
+
+	var allocations []models.OrderAllocation
+	for _, w := range warehouses {
+		whole := true
+		for _, ci := range lines {
+			if held[w][ci.ItemID] < ci.Quantity {
+				whole = false
+				break
+			}
+		}
+		if whole {
+			for _, ci := range lines {
+				allocations = append(allocations, models.OrderAllocation{ItemID: ci.ItemID, WarehouseID: w, Quantity: ci.Quantity})
+			}
+			break
+		}
+	}
+	if allocations == nil {
+		for _, ci := range lines {
+			remaining := ci.Quantity
+			for _, w := range warehouses {
+				if take := min(remaining, held[w][ci.ItemID]); take > 0 {
+					allocations = append(allocations, models.OrderAllocation{ItemID: ci.ItemID, WarehouseID: w, Quantity: take})
+					remaining -= take
+				}
+			}
+			if remaining > 0 {
+				return nil, insufficientStock(ci)
+			}
+		}
+	}
+
+	for _, a := range allocations {
+		ok, err := tx.Warehouses().TakeStock(ctx, a.WarehouseID, a.ItemID, a.Quantity)
+		if err != nil {
+			return nil, apperrors.Internal("failed to allocate stock", err)
+		}
+		if !ok {
+			for _, ci := range lines {
+				if ci.ItemID == a.ItemID {
+					return nil, insufficientStock(ci)
+				}
+			}
+		}
+	}
+	return allocations, nil
+}
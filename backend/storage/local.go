@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local keeps files in a directory published at a base URL
+type Local struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocal returns a store keeping files in the configured directory
+func NewLocal(cfg config.StorageConfig) *Local {
+	return &Local{dir: cfg.Dir, baseURL: strings.TrimSuffix(cfg.BaseURL, "/")}
+}
+
+// NewPrivate returns a store keeping files in the configured private
+// directory. Its files have no public URL.
+func NewPrivate(cfg config.StorageConfig) *Local {
+	return &Local{dir: cfg.PrivateDir}
+}
+
+// Put writes the file to a temporary name first and renames it into place,
+// so readers never see a partly written file
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return "", err
+	}
+	return l.baseURL + "/" + key, nil
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Dir returns the directory files are kept in
+func (l *Local) Dir() string {
+	return l.dir
+}
+
+// path returns the file name of key, refusing keys that would escape the
+// directory
+func (l *Local) path(key string) (string, error) {
+	if key == "" || path.Clean("/"+key) != "/"+key {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
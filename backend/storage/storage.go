@@ -0,0 +1,57 @@
+// Package storage keeps uploaded files, such as avatars, and publishes them
+// at public URLs. Files of digital items are kept in a separate private
+// store that is never published.
+package storage
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned by Open when there is no file under the key
+var ErrNotFound = errors.New("file not found")
+
+// Storage is a file store. Implementations must be safe for concurrent use.
+type Storage interface {
+	// Put stores what is read from r under key, replacing any file there,
+	// and returns the file's public URL
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (string, error)
+	// Open returns the file under key, or ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the file under key, if any
+	Delete(ctx context.Context, key string) error
+}
+
+var (
+	current Storage = NewLocal(config.Default().Storage)
+	private Storage = NewPrivate(config.Default().Storage)
+)
+
+// Init selects the storage backends: the configured local directories
+func Init(cfg config.StorageConfig) {
+	current = NewLocal(cfg)
+	private = NewPrivate(cfg)
+}
+
+// Get returns the active storage backend
+func Get() Storage {
+	return current
+}
+
+// Set replaces the storage backend; mainly useful for tests
+func Set(storage Storage) {
+	current = storage
+}
+
+// Private returns the storage backend for files served only through signed
+// download links
+func Private() Storage {
+	return private
+}
+
+// SetPrivate replaces the private storage backend; mainly useful for tests
+func SetPrivate(storage Storage) {
+	private = storage
+}
@@ -0,0 +1,84 @@
+package tenant
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// storeField is the field marking a model as owned by a store
+const storeField = "StoreID"
+
+// Plugin scopes GORM statements to the store in their context: inserts of
+// store-owned models get the store's ID, and queries, updates and deletes
+// only see that store's rows. Statements without a store are left alone.
+type Plugin struct{}
+
+func (Plugin) Name() string { return "tenant" }
+
+func (Plugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("tenant:assign", assign); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().Before("gorm:query").Register("tenant:scope", scope); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("tenant:scope", scope); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("tenant:scope", scope); err != nil {
+		return err
+	}
+	return db.Callback().Delete().Before("gorm:delete").Register("tenant:scope", scope)
+}
+
+// storeOf returns the store of the statement and the model's store field,
+// or false if either is missing
+func storeOf(db *gorm.DB) (uint, *schema.Field, bool) {
+	if db.Statement.Schema == nil {
+		return 0, nil, false
+	}
+	field := db.Statement.Schema.LookUpField(storeField)
+	if field == nil {
+		return 0, nil, false
+	}
+	id, ok := FromContext(db.Statement.Context)
+	return id, field, ok
+}
+
+// assign sets the store of new records that have none
+func assign(db *gorm.DB) {
+	id, field, ok := storeOf(db)
+	if !ok {
+		return
+	}
+
+	ctx, rv := db.Statement.Context, db.Statement.ReflectValue
+	set := func(v reflect.Value) {
+		if _, zero := field.ValueOf(ctx, v); zero {
+			db.AddError(field.Set(ctx, v, id))
+		}
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			set(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		set(rv)
+	}
+}
+
+// scope limits the statement to the rows of its store. The column is
+// qualified with the statement's table, so joins stay unambiguous.
+func scope(db *gorm.DB) {
+	id, field, ok := storeOf(db)
+	if !ok {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: id},
+	}})
+}
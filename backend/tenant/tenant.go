@@ -0,0 +1,39 @@
+// Package tenant carries the store (tenant) a request belongs to, so one
+// deployment can host several shops. The store ID travels in the request
+// context, and the GORM plugin scopes every query on a store-owned table
+// by it. Work without a store in its context, such as background jobs and
+// admin commands, sees every store.
+package tenant
+
+import "context"
+
+// DefaultStoreID is the store of requests that name no store, and of the
+// data created before stores were introduced
+const DefaultStoreID uint = 1
+
+type contextKey struct{}
+
+// WithStore returns a copy of ctx scoped to the store
+func WithStore(ctx context.Context, storeID uint) context.Context {
+	return context.WithValue(ctx, contextKey{}, storeID)
+}
+
+// WithoutStore returns a copy of ctx that sees every store, for work done
+// on behalf of no particular store, such as handling carrier webhooks
+func WithoutStore(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, nil)
+}
+
+// FromContext returns the store ctx is scoped to, if any
+func FromContext(ctx context.Context) (uint, bool) {
+	id, ok := ctx.Value(contextKey{}).(uint)
+	return id, ok
+}
+
+// StoreOrDefault returns the store ctx is scoped to, or DefaultStoreID
+func StoreOrDefault(ctx context.Context) uint {
+	if id, ok := FromContext(ctx); ok {
+		return id
+	}
+	return DefaultStoreID
+}
@@ -0,0 +1,182 @@
+package testutil
+
+import (
+	"bytes"
+	"ecommerce-backend/models"
+	"ecommerce-backend/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Client sends requests straight to a handler, optionally authenticated
+type Client struct {
+	t       testing.TB
+	handler http.Handler
+	token   string
+}
+
+// NewClient returns an anonymous client for handler, usually the router
+func NewClient(t testing.TB, handler http.Handler) *Client {
+	return &Client{t: t, handler: handler}
+}
+
+// WithToken returns a copy of the client sending token as a bearer token
+func (c *Client) WithToken(token string) *Client {
+	clone := *c
+	clone.token = token
+	return &clone
+}
+
+// As returns a copy of the client authenticated as user
+func (c *Client) As(user models.User) *Client {
+	c.t.Helper()
+	token, err := utils.GenerateToken(user.ID, user.Username, user.Role, user.VendorIDOrZero(), user.StoreID)
+	if err != nil {
+		c.t.Fatalf("failed to generate token for %s: %v", user.Username, err)
+	}
+	return c.WithToken(token)
+}
+
+// GET sends a GET request
+func (c *Client) GET(path string) *Response {
+	c.t.Helper()
+	return c.Do(http.MethodGet, path, nil)
+}
+
+// POST sends body, if not nil, as JSON
+func (c *Client) POST(path string, body interface{}) *Response {
+	c.t.Helper()
+	return c.Do(http.MethodPost, path, body)
+}
+
+// Do sends a request with body, if not nil, encoded as JSON
+func (c *Client) Do(method, path string, body interface{}) *Response {
+	c.t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatalf("failed to encode request body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	rec := httptest.NewRecorder()
+	c.handler.ServeHTTP(rec, req)
+	return &Response{t: c.t, request: method + " " + path, ResponseRecorder: rec}
+}
+
+// Response wraps a recorded response with assertions that fail the test
+type Response struct {
+	*httptest.ResponseRecorder
+	t       testing.TB
+	request string
+}
+
+// AssertStatus fails the test unless the response has the given status
+func (r *Response) AssertStatus(want int) *Response {
+	r.t.Helper()
+	if r.Code != want {
+		r.t.Fatalf("%s: status %d, want %d; body: %s", r.request, r.Code, want, r.Body.String())
+	}
+	return r
+}
+
+// Decode unmarshals the JSON body into v
+func (r *Response) Decode(v interface{}) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		r.t.Fatalf("%s: invalid JSON body: %v; body: %s", r.request, err, r.Body.String())
+	}
+	return r
+}
+
+// JSON returns the value at path in the JSON body. Paths are dot-separated
+// object keys and array indexes, e.g. "orders.0.items.1.name".
+func (r *Response) JSON(path string) interface{} {
+	r.t.Helper()
+
+	var value interface{}
+	r.Decode(&value)
+	if path == "" {
+		return value
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				r.t.Fatalf("%s: no %q in %s; body: %s", r.request, key, path, r.Body.String())
+			}
+			value = v
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				r.t.Fatalf("%s: no index %q in %s; body: %s", r.request, key, path, r.Body.String())
+			}
+			value = node[i]
+		default:
+			r.t.Fatalf("%s: cannot look up %q in %s; body: %s", r.request, key, path, r.Body.String())
+		}
+	}
+	return value
+}
+
+// AssertJSON fails the test unless the value at path equals want. Numbers
+// are compared as float64, so want may be any numeric type.
+func (r *Response) AssertJSON(path string, want interface{}) *Response {
+	r.t.Helper()
+
+	got := r.JSON(path)
+	if f, ok := toFloat(want); ok {
+		want = f
+	}
+	if !reflect.DeepEqual(got, want) {
+		r.t.Fatalf("%s: %s = %#v, want %#v; body: %s", r.request, path, got, want, r.Body.String())
+	}
+	return r
+}
+
+// AssertLen fails the test unless the array at path has n elements
+func (r *Response) AssertLen(path string, n int) *Response {
+	r.t.Helper()
+
+	list, ok := r.JSON(path).([]interface{})
+	if !ok || len(list) != n {
+		r.t.Fatalf("%s: %s has %d element(s), want %d; body: %s", r.request, path, len(list), n, r.Body.String())
+	}
+	return r
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
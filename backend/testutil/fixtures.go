@@ -0,0 +1,38 @@
+package testutil
+
+import (
+	"ecommerce-backend/models"
+	"ecommerce-backend/utils"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// Password is the password of users created by CreateUser
+const Password = "password123"
+
+// CreateUser inserts a user with the given role and the password Password
+func CreateUser(t testing.TB, db *gorm.DB, username, role string) models.User {
+	t.Helper()
+
+	hash, err := utils.HashPassword(Password)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	user := models.User{Username: username, PasswordHash: hash, Role: role}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user %s: %v", username, err)
+	}
+	return user
+}
+
+// CreateItem inserts a catalog item
+func CreateItem(t testing.TB, db *gorm.DB, name string, price float64) models.Item {
+	t.Helper()
+
+	item := models.Item{Name: name, Price: price}
+	if err := db.Create(&item).Error; err != nil {
+		t.Fatalf("failed to create item %s: %v", name, err)
+	}
+	return item
+}
@@ -1,78 +1,147 @@
 package utils
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"ecommerce-backend/config"
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"os"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
-const (
-	tokenExpiration = 24 * time.Hour
-)
+// Claims are the JWT claims identifying an authenticated user
+type Claims struct {
+	UserID   uint   `json:"uid"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	// VendorID is set for vendor accounts
+	VendorID uint `json:"vid,omitempty"`
+	// StoreID is the store the user belongs to; tokens issued before
+	// stores existed have none and belong to the default store
+	StoreID uint `json:"sid,omitempty"`
+	// Actor is set on impersonation tokens and names the admin acting as
+	// the user
+	Actor *Actor `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
 
-// GenerateToken generates a new JWT token for the given username
-func GenerateToken(username string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": username,
-		"exp":      time.Now().Add(tokenExpiration).Unix(),
-	})
+// Actor identifies the admin behind an impersonation token
+type Actor struct {
+	UserID   uint   `json:"uid"`
+	Username string `json:"username"`
+}
+
+// GenerateToken generates a new JWT token carrying the user's ID, username,
+// role, store and, for vendor accounts, vendor ID (0 otherwise)
+func GenerateToken(userID uint, username, role string, vendorID, storeID uint) (string, error) {
+	token, _, err := signToken(Claims{
+		UserID:   userID,
+		Username: username,
+		Role:     role,
+		VendorID: vendorID,
+		StoreID:  storeID,
+	}, config.Get().JWT.Expiration)
+	return token, err
+}
+
+// GenerateImpersonationToken generates a token like GenerateToken that lets
+// the actor act as the user until the returned expiry
+func GenerateImpersonationToken(userID uint, username, role string, vendorID, storeID uint, actor Actor, ttl time.Duration) (string, time.Time, error) {
+	return signToken(Claims{
+		UserID:   userID,
+		Username: username,
+		Role:     role,
+		VendorID: vendorID,
+		StoreID:  storeID,
+		Actor:    &actor,
+	}, ttl)
+}
+
+// signToken signs the claims with a fresh ID, valid for ttl from now, and
+// returns the token with its expiry
+func signToken(claims Claims, ttl time.Duration) (string, time.Time, error) {
+	jti, err := GenerateRandomString(32)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error generating token: %v", err)
+	}
 
-	// Get secret key from environment variable or use a default one
-	secretKey := os.Getenv("JWT_SECRET_KEY")
-	if secretKey == "" {
-		secretKey = "your-secret-key" // In production, always use environment variables
+	now := time.Now()
+	expiresAt := jwt.NewNumericDate(now.Add(ttl))
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti,
+		Subject:   strconv.FormatUint(uint64(claims.UserID), 10),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: expiresAt,
 	}
 
-	// Sign the token with the secret key
-	tokenString, err := token.SignedString([]byte(secretKey))
+	r, err := jwtKeyring(context.Background(), keyringRefresh)
 	if err != nil {
-		return "", fmt.Errorf("error generating token: %v", err)
+		return "", time.Time{}, fmt.Errorf("error generating token: %v", err)
 	}
 
-	return tokenString, nil
+	// Sign the token with the newest key, naming it for validation
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if r.signing.KID != "" {
+		token.Header["kid"] = r.signing.KID
+	}
+	tokenString, err := token.SignedString(r.signing.secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error generating token: %v", err)
+	}
+
+	return tokenString, expiresAt.Time, nil
 }
 
-// ValidateToken validates the JWT token and returns the username if valid
-func ValidateToken(tokenString string) (string, error) {
-	// Get secret key from environment variable or use a default one
-	secretKey := os.Getenv("JWT_SECRET_KEY")
-	if secretKey == "" {
-		secretKey = "your-secret-key" // In production, always use environment variables
-	}
+// ValidateToken validates the JWT token and returns its claims if valid.
+// Apart from the signature, only the revocation list is consulted.
+func ValidateToken(ctx context.Context, tokenString string) (*Claims, error) {
+	claims := &Claims{}
 
 	// Parse the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		// Validate the alg is what you expect:
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(secretKey), nil
+		// Tokens without a kid were signed with JWT_SECRET_KEY
+		kid, _ := token.Header["kid"].(string)
+		return verifyingKey(ctx, kid)
 	})
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		username, ok := claims["username"].(string)
-		if !ok {
-			return "", errors.New("invalid token claims")
-		}
-		return username, nil
+	if !token.Valid || claims.UserID == 0 || claims.Username == "" {
+		return nil, errors.New("invalid token claims")
 	}
 
-	return "", errors.New("invalid token")
+	// Tokens without an issue time predate any revocation of the user's
+	// tokens
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	revoked, err := IsTokenRevoked(ctx, claims.ID, claims.UserID, issuedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error checking token revocation: %v", err)
+	}
+	if revoked {
+		return nil, errors.New("token has been revoked")
+	}
+
+	return claims, nil
 }
 
 // HashPassword hashes a password using bcrypt
 func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), config.Get().BcryptCost)
 	if err != nil {
 		return "", fmt.Errorf("error hashing password: %v", err)
 	}
@@ -92,3 +161,36 @@ func GenerateRandomString(length int) (string, error) {
 	}
 	return hex.EncodeToString(bytes)[:length], nil
 }
+
+// GenerateAPIKey generates a new API key and returns the raw key, which is
+// only shown once, along with its display prefix and storage hash
+func GenerateAPIKey(sandbox bool) (key, prefix, hash string, err error) {
+	random, err := GenerateRandomString(40)
+	if err != nil {
+		return "", "", "", fmt.Errorf("error generating api key: %v", err)
+	}
+
+	prefix = "sk_live_"
+	if sandbox {
+		prefix = "sk_test_"
+	}
+	key = prefix + random
+
+	return key, key[:len(prefix)+6], HashAPIKey(key), nil
+}
+
+// GenerateWebhookSecret generates the secret signing the webhooks of an API
+// key
+func GenerateWebhookSecret() (string, error) {
+	random, err := GenerateRandomString(40)
+	if err != nil {
+		return "", fmt.Errorf("error generating webhook secret: %v", err)
+	}
+	return "whsec_" + random, nil
+}
+
+// HashAPIKey returns the hex-encoded SHA-256 hash used to look up an API key
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/database"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// keyringRefresh is how often the keys rotated at runtime are read
+	// again, so every instance signs with the newest within that time
+	keyringRefresh = time.Minute
+	// keyringMissRefresh bounds how often tokens naming an unknown key
+	// make the keys be read again
+	keyringMissRefresh = 5 * time.Second
+	// jwtKeySecretLength is the length of the secrets of rotated keys
+	jwtKeySecretLength = 64
+)
+
+// JWTKeyStatus describes a key signing or verifying tokens, without its
+// secret
+type JWTKeyStatus struct {
+	KID string
+	// Rotated is set for keys made by RotateJWTKey rather than configured
+	Rotated bool
+	// Signing is set for the key signing new tokens
+	Signing bool
+	// ExpiresAt is when the key stops verifying tokens, once a newer key
+	// has signed for a token lifetime; nil for keys not yet superseded
+	ExpiresAt *time.Time
+}
+
+type jwtKey struct {
+	JWTKeyStatus
+	secret []byte
+}
+
+// keyring holds the keys by kid, "" being JWT_SECRET_KEY, which verifies
+// tokens signed without a kid. A published keyring is never changed.
+type keyring struct {
+	signing  jwtKey
+	keys     map[string]jwtKey
+	cfg      *config.Config
+	loadedAt time.Time
+}
+
+var (
+	// ring is read by every request without locking, while keyringMu is
+	// held by the one caller reading the keys again
+	ring      atomic.Pointer[keyring]
+	keyringMu sync.Mutex
+)
+
+// jwtKeyring returns the keys, read again by one caller while the others
+// wait for it if they are older than maxAge or the configuration has
+// changed. If they cannot be read, the keys read before are used for
+// another maxAge.
+func jwtKeyring(ctx context.Context, maxAge time.Duration) (*keyring, error) {
+	cfg := config.Get()
+	if r := ring.Load(); r != nil && r.cfg == cfg && time.Since(r.loadedAt) < maxAge {
+		return r, nil
+	}
+	// Noted before waiting, so keys read by another caller meanwhile serve
+	// this one too, even when maxAge is 0
+	waited := time.Now()
+
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+
+	current := ring.Load()
+	if current != nil && current.cfg == cfg && current.loadedAt.After(waited) {
+		return current, nil
+	}
+	loaded, err := loadKeyring(ctx, cfg)
+	if err != nil {
+		if current == nil || current.cfg != cfg {
+			return nil, err
+		}
+		logging.FromContext(ctx).Warn("failed to read jwt keys", "error", err)
+		stale := *current
+		stale.loadedAt = time.Now()
+		loaded = &stale
+	}
+	ring.Store(loaded)
+	return loaded, nil
+}
+
+// loadKeyring reads the configured keys and those rotated at runtime. The
+// first of JWT_KEYS signs, or JWT_SECRET_KEY without them, until a key is
+// rotated at runtime: the newest rotated key then signs. Each key stops
+// verifying tokens once the key superseding it has signed for the longest
+// token lifetime, plus the time other instances take to see the rotation.
+func loadKeyring(ctx context.Context, cfg *config.Config) (*keyring, error) {
+	r := &keyring{keys: map[string]jwtKey{}, cfg: cfg, loadedAt: time.Now()}
+	configured := []jwtKey{{secret: []byte(cfg.JWT.Secret)}}
+	for _, s := range cfg.JWT.Keys {
+		kid, secret, err := config.ParseJWTKey(s)
+		if err != nil {
+			return nil, err
+		}
+		configured = append(configured, jwtKey{JWTKeyStatus: JWTKeyStatus{KID: kid}, secret: []byte(secret)})
+	}
+	signing := configured[0].KID
+	if len(configured) > 1 {
+		signing = configured[1].KID
+	}
+
+	var rotated []models.JWTKey
+	if database.GetDB() != nil {
+		if err := database.WithContext(ctx).Order("created_at, id").Find(&rotated).Error; err != nil {
+			return nil, fmt.Errorf("error reading jwt keys: %v", err)
+		}
+	}
+	grace := max(cfg.JWT.Expiration, cfg.JWT.ImpersonationTTL) + keyringRefresh
+	for _, key := range configured {
+		if len(rotated) > 0 {
+			expiresAt := rotated[0].CreatedAt.Add(grace)
+			key.ExpiresAt = &expiresAt
+		}
+		r.keys[key.KID] = key
+	}
+	for i, k := range rotated {
+		key := jwtKey{JWTKeyStatus: JWTKeyStatus{KID: k.KID, Rotated: true}, secret: []byte(k.Secret)}
+		if i < len(rotated)-1 {
+			expiresAt := rotated[i+1].CreatedAt.Add(grace)
+			key.ExpiresAt = &expiresAt
+		}
+		r.keys[k.KID] = key
+		signing = k.KID
+	}
+
+	r.signing = r.keys[signing]
+	r.signing.Signing = true
+	r.keys[signing] = r.signing
+	return r, nil
+}
+
+// verifyingKey returns the secret of the key named by a token's kid, if
+// the key still verifies tokens
+func verifyingKey(ctx context.Context, kid string) ([]byte, error) {
+	r, err := jwtKeyring(ctx, keyringRefresh)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := r.keys[kid]
+	if !ok {
+		// The key may have been rotated in by another instance
+		if r, err = jwtKeyring(ctx, keyringMissRefresh); err != nil {
+			return nil, err
+		}
+		if key, ok = r.keys[kid]; !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+	}
+	if key.ExpiresAt != nil && !time.Now().Before(*key.ExpiresAt) {
+		return nil, fmt.Errorf("signing key %q has expired", kid)
+	}
+	return key.secret, nil
+}
+
+// RotateJWTKey makes a new key that signs every token issued from now on,
+// on every instance within a minute. Tokens signed with the previous keys
+// stay valid until they expire, so no one is logged out.
+func RotateJWTKey(ctx context.Context) (models.JWTKey, error) {
+	secret, err := GenerateRandomString(jwtKeySecretLength)
+	if err != nil {
+		return models.JWTKey{}, fmt.Errorf("error generating jwt key: %v", err)
+	}
+	suffix, err := GenerateRandomString(6)
+	if err != nil {
+		return models.JWTKey{}, fmt.Errorf("error generating jwt key: %v", err)
+	}
+	key := models.JWTKey{
+		KID:    time.Now().UTC().Format("k20060102T150405") + "-" + suffix,
+		Secret: secret,
+	}
+	if err := database.WithContext(ctx).Create(&key).Error; err != nil {
+		return models.JWTKey{}, err
+	}
+	if _, err := jwtKeyring(ctx, 0); err != nil {
+		return models.JWTKey{}, err
+	}
+	return key, nil
+}
+
+// JWTKeys returns the keys verifying tokens, configured ones first, then
+// those rotated at runtime from the oldest. Expired keys are left out.
+func JWTKeys(ctx context.Context) ([]JWTKeyStatus, error) {
+	r, err := jwtKeyring(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []JWTKeyStatus
+	add := func(key jwtKey) {
+		if key.ExpiresAt == nil || time.Now().Before(*key.ExpiresAt) {
+			statuses = append(statuses, key.JWTKeyStatus)
+		}
+	}
+	add(r.keys[""])
+	for _, s := range r.cfg.JWT.Keys {
+		kid, _, _ := config.ParseJWTKey(s)
+		add(r.keys[kid])
+	}
+	var rotated []models.JWTKey
+	if err := database.WithContext(ctx).Order("created_at, id").Find(&rotated).Error; err != nil {
+		return nil, err
+	}
+	for _, k := range rotated {
+		if key, ok := r.keys[k.KID]; ok {
+			add(key)
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"context"
+	"ecommerce-backend/apperrors"
+	"ecommerce-backend/config"
+	"ecommerce-backend/database"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// usageDay is the layout of the days API key usage is counted by
+const usageDay = "2006-01-02"
+
+// QuotaUsage is how much of one of an API key's quotas has been used
+type QuotaUsage struct {
+	// Limit is the number of requests allowed in the period; 0 for no limit
+	Limit int
+	Used  int64
+	// ResetsAt is when the period ends and a new one starts
+	ResetsAt time.Time
+}
+
+// Exceeded reports whether no requests are left in the period
+func (u QuotaUsage) Exceeded() bool {
+	return u.Limit > 0 && u.Used >= int64(u.Limit)
+}
+
+// Remaining returns the requests left in the period, or -1 without a limit
+func (u QuotaUsage) Remaining() int64 {
+	if u.Limit == 0 {
+		return -1
+	}
+	return max(int64(u.Limit)-u.Used, 0)
+}
+
+// QuotaExceeded details a QUOTA_EXCEEDED error
+type QuotaExceeded struct {
+	// Period is the quota used up, daily or monthly
+	Period   string    `json:"period"`
+	Limit    int       `json:"limit"`
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+// ConsumeAPIKeyRequest counts a request made with the key against its
+// quotas and returns their usage before the request. Once a quota is used
+// up, the request is counted as rejected and QUOTA_EXCEEDED is returned,
+// detailed by a QuotaExceeded. Concurrent requests may overshoot a quota by
+// a few.
+func ConsumeAPIKeyRequest(ctx context.Context, key models.APIKey, now time.Time) (daily, monthly QuotaUsage, err error) {
+	daily, monthly, err = APIKeyUsage(ctx, key, now)
+	if err != nil {
+		return daily, monthly, apperrors.Internal("failed to check api key quota", err)
+	}
+
+	for _, quota := range []struct {
+		period string
+		usage  QuotaUsage
+	}{{"daily", daily}, {"monthly", monthly}} {
+		if !quota.usage.Exceeded() {
+			continue
+		}
+		if err := RecordAPIKeyRequest(ctx, key.ID, now, true); err != nil {
+			logging.FromContext(ctx).Warn("failed to record api key usage", "error", err)
+		}
+		return daily, monthly, apperrors.ErrQuotaExceeded.WithDetails(QuotaExceeded{
+			Period:   quota.period,
+			Limit:    quota.usage.Limit,
+			ResetsAt: quota.usage.ResetsAt,
+		})
+	}
+
+	if err := RecordAPIKeyRequest(ctx, key.ID, now, false); err != nil {
+		return daily, monthly, apperrors.Internal("failed to record api key usage", err)
+	}
+	return daily, monthly, nil
+}
+
+// APIKeyQuotas returns the daily and monthly request quotas of the key: its
+// own, or the configured defaults. 0 means no limit.
+func APIKeyQuotas(key models.APIKey) (daily, monthly int) {
+	daily, monthly = config.Get().API.KeyDailyQuota, config.Get().API.KeyMonthlyQuota
+	if key.DailyQuota != nil {
+		daily = *key.DailyQuota
+	}
+	if key.MonthlyQuota != nil {
+		monthly = *key.MonthlyQuota
+	}
+	return daily, monthly
+}
+
+// APIKeyUsage returns how much of its daily and monthly quotas the key has
+// used in the UTC day and month of now. Rejected requests are not counted.
+func APIKeyUsage(ctx context.Context, key models.APIKey, now time.Time) (daily, monthly QuotaUsage, err error) {
+	now = now.UTC()
+	today := now.Format(usageDay)
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var used struct {
+		Today int64
+		Month int64
+	}
+	err = database.WithContext(ctx).Model(&models.APIKeyUsage{}).
+		Select("COALESCE(SUM(CASE WHEN day = ? THEN requests ELSE 0 END), 0) AS today, "+
+			"COALESCE(SUM(requests), 0) AS month", today).
+		Where("api_key_id = ? AND day >= ? AND day <= ?", key.ID, monthStart.Format(usageDay), today).
+		Scan(&used).Error
+	if err != nil {
+		return QuotaUsage{}, QuotaUsage{}, err
+	}
+
+	dailyLimit, monthlyLimit := APIKeyQuotas(key)
+	daily = QuotaUsage{
+		Limit:    dailyLimit,
+		Used:     used.Today,
+		ResetsAt: time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC),
+	}
+	monthly = QuotaUsage{Limit: monthlyLimit, Used: used.Month, ResetsAt: monthStart.AddDate(0, 1, 0)}
+	return daily, monthly, nil
+}
+
+// RecordAPIKeyRequest counts a request made with the key on the UTC day of
+// now, as rejected if it was refused for exceeding a quota
+func RecordAPIKeyRequest(ctx context.Context, keyID uint, now time.Time, rejected bool) error {
+	usage := models.APIKeyUsage{APIKeyID: keyID, Day: now.UTC().Format(usageDay), Requests: 1}
+	column := "requests"
+	if rejected {
+		usage.Requests, usage.Rejected = 0, 1
+		column = "rejected"
+	}
+	return database.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "api_key_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{column: gorm.Expr(column + " + 1")}),
+	}).Create(&usage).Error
+}
+
+// APIKeyUsageDays returns the key's usage on each day of the UTC month of
+// now on which it was used, oldest first
+func APIKeyUsageDays(ctx context.Context, keyID uint, now time.Time) ([]models.APIKeyUsage, error) {
+	now = now.UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var days []models.APIKeyUsage
+	err := database.WithContext(ctx).
+		Where("api_key_id = ? AND day >= ? AND day <= ?", keyID, monthStart.Format(usageDay), now.Format(usageDay)).
+		Order("day").Find(&days).Error
+	return days, err
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+// sensitiveKeys are JSON keys whose values are always redacted (matched
+// case-insensitively after stripping '_' and '-')
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"passwordhash":  true,
+	"token":         true,
+	"accesstoken":   true,
+	"refreshtoken":  true,
+	"secret":        true,
+	"apikey":        true,
+	"webhooksecret": true,
+	"authorization": true,
+	"cardnumber":    true,
+	"card":          true,
+	"pan":           true,
+	"cvv":           true,
+	"cvc":           true,
+	"expiry":        true,
+	"code":          true,
+	"giftcardcode":  true,
+}
+
+// cardNumberPattern matches runs of 13-19 digits, optionally separated by
+// spaces or dashes
+var cardNumberPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+
+// apiKeyPattern matches raw API keys as issued by GenerateAPIKey
+var apiKeyPattern = regexp.MustCompile(`sk_(?:live|test)_[0-9a-f]+`)
+
+// pairPattern matches key=value pairs of form bodies and query strings, and
+// "key": value pairs of JSON too malformed or truncated to parse; quoted
+// values may lack their closing quote where the text was cut off
+var pairPattern = regexp.MustCompile(`("?)([A-Za-z0-9_-]+)("?\s*[:=]\s*)("(?:[^"\\]|\\.)*"?|[^&\s,;}"]*)`)
+
+// RedactJSON returns a copy of a JSON body with secrets and card numbers
+// redacted. Bodies that are not valid JSON, such as forms or JSON cut off
+// at the audit size limit, are scrubbed as plain text.
+func RedactJSON(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return RedactText(string(body))
+	}
+
+	redacted, err := json.Marshal(redactValue(data))
+	if err != nil {
+		return redactedValue
+	}
+	return string(redacted)
+}
+
+// RedactText masks API keys, the values of key=value and "key": value
+// pairs with sensitive keys, and anything in free text that looks like a
+// valid card number
+func RedactText(text string) string {
+	text = apiKeyPattern.ReplaceAllString(text, redactedValue)
+	text = pairPattern.ReplaceAllStringFunc(text, func(match string) string {
+		m := pairPattern.FindStringSubmatch(match)
+		if !isSensitiveKey(m[2]) {
+			return match
+		}
+		value := redactedValue
+		if strings.HasPrefix(m[4], `"`) {
+			value = `"` + value + `"`
+		}
+		return m[1] + m[2] + m[3] + value
+	})
+	return cardNumberPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if luhnValid(match) {
+			return redactedValue
+		}
+		return match
+	})
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, inner := range val {
+			if isSensitiveKey(k) {
+				val[k] = redactedValue
+				continue
+			}
+			val[k] = redactValue(inner)
+		}
+		return val
+	case []interface{}:
+		for i, inner := range val {
+			val[i] = redactValue(inner)
+		}
+		return val
+	case string:
+		return RedactText(val)
+	default:
+		return val
+	}
+}
+
+func isSensitiveKey(key string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	return sensitiveKeys[normalized]
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		ch := s[i]
+		if ch < '0' || ch > '9' {
+			continue
+		}
+		d := int(ch - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
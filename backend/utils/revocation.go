@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"context"
+	"ecommerce-backend/config"
+	"ecommerce-backend/database"
+	"ecommerce-backend/logging"
+	"ecommerce-backend/models"
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// revocationRefresh is how often the revocation list is read again, so a
+// token revoked on one instance is refused by every instance within that
+// time without a query per request
+const revocationRefresh = 10 * time.Second
+
+// revocationList holds the revocations in force: the expiry of each revoked
+// token ID, and the time before which each user's tokens were revoked. A
+// published list is never changed; changes publish a copy.
+type revocationList struct {
+	tokens   map[string]time.Time
+	users    map[uint]models.UserTokenRevocation
+	loadedAt time.Time
+}
+
+var (
+	// revocations is read by every request without locking, while
+	// revocationsMu is held by the one caller reloading or changing it
+	revocations   atomic.Pointer[revocationList]
+	revocationsMu sync.Mutex
+)
+
+// RevokeToken adds a token ID (jti) to the revocation list until expiresAt.
+// The list is stored in the database so it survives restarts and is shared
+// by every instance.
+func RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+
+	revoked := models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}
+	if err := database.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error; err != nil {
+		return err
+	}
+	cacheRevocation(func(l *revocationList) { l.tokens[jti] = expiresAt })
+	return nil
+}
+
+// RevokeUserTokens revokes every token issued to the user so far, such as
+// when they deactivate their account. The revocation is kept until all of
+// those tokens have expired, even if the account is reactivated.
+func RevokeUserTokens(ctx context.Context, userID uint) error {
+	// Token issue times are in whole seconds, so tokens issued in the
+	// current second are revoked too
+	revokedBefore := time.Now().Truncate(time.Second).Add(time.Second)
+	cfg := config.Get().JWT
+	revocation := models.UserTokenRevocation{
+		UserID:        userID,
+		RevokedBefore: revokedBefore,
+		ExpiresAt:     revokedBefore.Add(max(cfg.Expiration, cfg.ImpersonationTTL)),
+	}
+	err := database.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"revoked_before", "expires_at"}),
+	}).Create(&revocation).Error
+	if err != nil {
+		return err
+	}
+	cacheRevocation(func(l *revocationList) { l.users[userID] = revocation })
+	return nil
+}
+
+// IsTokenRevoked reports whether the token ID is on the revocation list, or
+// the token was issued at issuedAt before all of the user's tokens were
+// revoked. The list is cached, so revocations made on other instances take
+// up to revocationRefresh to apply.
+func IsTokenRevoked(ctx context.Context, jti string, userID uint, issuedAt time.Time) (bool, error) {
+	l, err := loadedRevocations(ctx)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if expiresAt, ok := l.tokens[jti]; ok && jti != "" && now.Before(expiresAt) {
+		return true, nil
+	}
+	revocation, ok := l.users[userID]
+	return ok && issuedAt.Before(revocation.RevokedBefore) && now.Before(revocation.ExpiresAt), nil
+}
+
+// loadedRevocations returns the revocation list, read again once it is
+// older than revocationRefresh by one caller while the others wait for it.
+// If it cannot be read, the list read before is used for another interval.
+func loadedRevocations(ctx context.Context) (*revocationList, error) {
+	if l := revocations.Load(); l != nil && time.Since(l.loadedAt) < revocationRefresh {
+		return l, nil
+	}
+
+	revocationsMu.Lock()
+	defer revocationsMu.Unlock()
+
+	// Another caller may have read the list while this one waited
+	current := revocations.Load()
+	if current != nil && time.Since(current.loadedAt) < revocationRefresh {
+		return current, nil
+	}
+	loaded, err := loadRevocations(ctx)
+	if err != nil {
+		if current == nil {
+			return nil, err
+		}
+		logging.FromContext(ctx).Warn("failed to read token revocations", "error", err)
+		stale := *current
+		stale.loadedAt = time.Now()
+		loaded = &stale
+	}
+	revocations.Store(loaded)
+	return loaded, nil
+}
+
+// loadRevocations reads the revocations that have not expired
+func loadRevocations(ctx context.Context) (*revocationList, error) {
+	l := &revocationList{tokens: map[string]time.Time{}, users: map[uint]models.UserTokenRevocation{}, loadedAt: time.Now()}
+	if database.GetDB() == nil {
+		return l, nil
+	}
+
+	var tokens []models.RevokedToken
+	if err := database.WithContext(ctx).Where("expires_at > ?", l.loadedAt).Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("error reading revoked tokens: %v", err)
+	}
+	for _, token := range tokens {
+		l.tokens[token.JTI] = token.ExpiresAt
+	}
+	var users []models.UserTokenRevocation
+	if err := database.WithContext(ctx).Where("expires_at > ?", l.loadedAt).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("error reading user token revocations: %v", err)
+	}
+	for _, revocation := range users {
+		l.users[revocation.UserID] = revocation
+	}
+	return l, nil
+}
+
+// cacheRevocation applies a revocation made by this instance to a copy of
+// the cached list, if one is loaded, and publishes it so it applies here at
+// once
+func cacheRevocation(apply func(*revocationList)) {
+	revocationsMu.Lock()
+	defer revocationsMu.Unlock()
+
+	current := revocations.Load()
+	if current == nil {
+		return
+	}
+	l := &revocationList{tokens: maps.Clone(current.tokens), users: maps.Clone(current.users), loadedAt: current.loadedAt}
+	apply(l)
+	revocations.Store(l)
+}
+
+// PurgeExpiredRevocations deletes revocations of tokens that have expired
+// and returns how many were removed
+func PurgeExpiredRevocations(ctx context.Context) (int64, error) {
+	now := time.Now()
+	result := database.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.RevokedToken{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	users := database.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.UserTokenRevocation{})
+	return result.RowsAffected + users.RowsAffected, users.Error
+}
@@ -0,0 +1,138 @@
+// Package visibility leaves the fields a role may not see out of responses.
+// Fields name the roles that see them in a visible tag, such as
+//
+//	CostPrice *float64 `json:",omitempty" visible:"admin"`
+//
+// and fields without one are seen by everyone, anonymous users included.
+// Redact zeroes the fields hidden from the role, so tagged fields should be
+// omitempty for them to be left out rather than rendered empty.
+package visibility
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Tag is the struct tag listing, comma-separated, the roles seeing a field
+const Tag = "visible"
+
+// tagged caches, by type, whether values of the type hold tagged fields
+var tagged sync.Map
+
+// Redact returns a copy of v with the fields role may not see zeroed, in
+// v's structs and in those it holds through pointers, slices, arrays and
+// maps; v is left as it is. Values without tagged fields are returned as
+// they are.
+func Redact(v interface{}, role string) interface{} {
+	if v == nil {
+		return nil
+	}
+	return redact(reflect.ValueOf(v), role).Interface()
+}
+
+// Visible reports whether role sees a field with the given visible tag
+func Visible(tag reflect.StructTag, role string) bool {
+	roles, ok := tag.Lookup(Tag)
+	if !ok {
+		return true
+	}
+	for _, r := range strings.Split(roles, ",") {
+		if strings.TrimSpace(r) == role && role != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func redact(v reflect.Value, role string) reflect.Value {
+	t := v.Type()
+	if !hasTags(t) {
+		return v
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(redact(v.Elem(), role))
+		return p
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		s := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(redact(v.Index(i), role))
+		}
+		return s
+	case reflect.Array:
+		a := reflect.New(t).Elem()
+		for i := 0; i < v.Len(); i++ {
+			a.Index(i).Set(redact(v.Index(i), role))
+		}
+		return a
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		m := reflect.MakeMapWithSize(t, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), redact(iter.Value(), role))
+		}
+		return m
+	case reflect.Struct:
+		s := reflect.New(t).Elem()
+		s.Set(v)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if !Visible(field.Tag, role) {
+				s.Field(i).Set(reflect.Zero(field.Type))
+			} else {
+				s.Field(i).Set(redact(v.Field(i), role))
+			}
+		}
+		return s
+	}
+	return v
+}
+
+// hasTags reports whether values of type t hold tagged fields
+func hasTags(t reflect.Type) bool {
+	if found, ok := tagged.Load(t); ok {
+		return found.(bool)
+	}
+	found := scan(t, map[reflect.Type]bool{})
+	tagged.Store(t, found)
+	return found
+}
+
+// scan looks for tagged fields in t, skipping the types already seen, as
+// models refer to each other
+func scan(t reflect.Type, seen map[reflect.Type]bool) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return scan(t.Elem(), seen)
+	case reflect.Struct:
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if _, ok := field.Tag.Lookup(Tag); ok || scan(field.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"ecommerce-backend/resilience"
+	"ecommerce-backend/webhooks/signature"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const deliveryTimeout = 10 * time.Second
+
+// Event is the payload delivered to webhook endpoints
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Sandbox   bool        `json:"sandbox"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// client propagates the trace context of the caller to webhook endpoints, retrying
+// failed requests and failing fast while an endpoint is down. It connects
+// directly, never through a proxy, and refuses non-public addresses at dial
+// time so a host re-resolving to one after ValidateURL is still refused.
+var client = &http.Client{
+	Transport: resilience.Transport("webhooks", deliveryTimeout, otelhttp.NewTransport(publicTransport())),
+}
+
+func publicTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !public(addr.Addr()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr.Addr().Unmap())
+			}
+			return nil
+		},
+	}).DialContext
+	return transport
+}
+
+// ErrForbiddenAddress is returned for webhook hosts resolving to a private,
+// loopback, link-local or otherwise non-public address
+var ErrForbiddenAddress = errors.New("webhook host resolves to a non-public address")
+
+// ValidateURL checks that raw is an https URL whose host resolves only to
+// public addresses, so webhooks cannot be aimed at the server's own network
+func ValidateURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return errors.New("webhook url must be an https url")
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("webhook host %s cannot be resolved", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !public(addr) {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr.Unmap())
+		}
+	}
+	return nil
+}
+
+func public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !addr.IsLoopback() && !addr.IsLinkLocalUnicast()
+}
+
+// Deliver posts the event as JSON to the given URL, signed with the
+// endpoint's secret in the signature.Header header. Any non-2xx response is
+// treated as a failed delivery, as is a URL ValidateURL rejects.
+func Deliver(ctx context.Context, url, secret string, event Event) error {
+	if err := ValidateURL(ctx, url); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding webhook event: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", event.Type)
+	req.Header.Set(signature.Header, signature.Sign(body, secret, time.Now()))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error delivering webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}